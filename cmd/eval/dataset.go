@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"ai-search-service/internal/gateway"
+)
+
+// Item is one dataset entry: what to summarize and the reference summary
+// it is scored against
+type Item struct {
+	ID        string `json:"id"`
+	Query     string `json:"query"` // searched in pipeline mode
+	Text      string `json:"text"`  // summarized in inference mode
+	Reference string `json:"reference"`
+}
+
+// Variant is one configuration to compare. In inference mode Model picks
+// the Ollama model and Prompt wraps each item's text, with {text} marking
+// where it goes. Pipeline searches use their tenant's model, chosen with
+// Headers (e.g. X-API-Key), and may be Deterministic. Sampling applies to
+// both.
+type Variant struct {
+	Name          string                  `json:"name"`
+	Model         string                  `json:"model,omitempty"`
+	Prompt        string                  `json:"prompt,omitempty"`
+	Sampling      *gateway.SamplingParams `json:"sampling,omitempty"`
+	Deterministic bool                    `json:"deterministic,omitempty"`
+	Headers       map[string]string       `json:"headers,omitempty"`
+}
+
+// readDataset reads one item per line, skipping blank lines. Items need a
+// reference and, depending on the mode, a query or a text; missing IDs
+// become line numbers.
+func readDataset(path, mode string) ([]Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []Item
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var item Item
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if item.ID == "" {
+			item.ID = strconv.Itoa(line)
+		}
+		switch {
+		case strings.TrimSpace(item.Reference) == "":
+			return nil, fmt.Errorf("line %d: no reference summary", line)
+		case mode == "pipeline" && strings.TrimSpace(item.Query) == "":
+			return nil, fmt.Errorf("line %d: no query to search", line)
+		case mode == "inference" && strings.TrimSpace(item.Text) == "":
+			return nil, fmt.Errorf("line %d: no text to summarize", line)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no items in %s", path)
+	}
+	return items, nil
+}
+
+// readVariants reads a JSON array of variants with unique names
+func readVariants(path string) ([]Variant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var variants []Variant
+	if err := json.Unmarshal(data, &variants); err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no variants in %s", path)
+	}
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		if v.Name == "" || seen[v.Name] {
+			return nil, fmt.Errorf("variant names must be set and unique, got %q", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return variants, nil
+}
+
+// prompt wraps an item's text in the variant's prompt
+func (v Variant) prompt(text string) string {
+	if v.Prompt == "" {
+		return text
+	}
+	if !strings.Contains(v.Prompt, "{text}") {
+		return v.Prompt + "\n\n" + text
+	}
+	return strings.ReplaceAll(v.Prompt, "{text}", text)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ai-search-service/internal/ollama"
+)
+
+// judgePrompt asks the judge model to rate a summary against the reference
+const judgePrompt = `You are grading a search result summary against a reference summary.
+
+Question or source: %s
+
+Reference summary:
+%s
+
+Candidate summary:
+%s
+
+Rate the candidate from 1 (wrong or unrelated) to 5 (as accurate and complete as the reference). Penalize claims the reference does not support. Answer with JSON only: {"score": <1-5>, "reason": "<one sentence>"}`
+
+// firstScore finds the score in answers that are not the requested JSON
+var firstScore = regexp.MustCompile(`[1-5]`)
+
+// judge has the judge model rate the summary, returning the score (1 to 5)
+// and the model's reason
+func judge(ctx context.Context, client *ollama.Client, item Item, summary string) (float64, string, error) {
+	source := item.Query
+	if source == "" {
+		source = item.Text
+	}
+	req := client.NewRequest(fmt.Sprintf(judgePrompt, source, item.Reference, summary), 0)
+	req.Options.Temperature = 0
+	resp, err := client.Generate(ctx, req)
+	if err != nil {
+		return 0, "", err
+	}
+	answer := strings.TrimSpace(resp.Response)
+
+	var verdict struct {
+		Score  float64 `json:"score"`
+		Reason string  `json:"reason"`
+	}
+	if start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}"); start >= 0 && end > start {
+		if json.Unmarshal([]byte(answer[start:end+1]), &verdict) == nil && verdict.Score >= 1 && verdict.Score <= 5 {
+			return verdict.Score, verdict.Reason, nil
+		}
+	}
+	if match := firstScore.FindString(answer); match != "" {
+		score, _ := strconv.ParseFloat(match, 64)
+		return score, answer, nil
+	}
+	return 0, "", fmt.Errorf("no score in the judge's answer %q", answer)
+}
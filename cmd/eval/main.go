@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/ollama"
+	"ai-search-service/internal/testharness"
+	pb "ai-search-service/proto"
+)
+
+// eval runs a dataset of queries with reference summaries through the search
+// pipeline, or the inference service directly, once per variant and reports
+// how close each variant's summaries come to the references
+func main() {
+	datasetFile := flag.String("dataset", "", "JSON lines file of {id, query, text, reference} items (required)")
+	variantsFile := flag.String("variants", "", "JSON file with the variants to compare (default: one variant with no overrides)")
+	mode := flag.String("mode", "pipeline", "what summarizes: pipeline (the gateway's search API) or inference (the Summarize RPC on each item's text)")
+	baseURL := flag.String("url", "http://localhost:8080", "gateway base URL (pipeline mode)")
+	inferenceAddr := flag.String("inference", "localhost:8083", "inference service address (inference mode and -bertscore)")
+	inProcess := flag.Bool("in-process", false, "run against in-memory services with fake upstreams instead of -url and -inference")
+	numResults := flag.Int("num-results", 5, "search results per query (pipeline mode)")
+	maxLength := flag.Int("max-length", 400, "summary length limit in characters (inference mode)")
+	bertScore := flag.Bool("bertscore", false, "also compute BERTScore with the inference service's embedding model")
+	judgeAddr := flag.String("judge-ollama", "localhost:11434", "Ollama host:port of the judge model")
+	judgeModel := flag.String("judge-model", "", "Ollama model rating each summary against its reference (empty disables the judge)")
+	timeout := flag.Duration("timeout", 2*time.Minute, "per-summary timeout")
+	format := flag.String("format", "text", "report format: text, json or csv")
+	out := flag.String("out", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	if *datasetFile == "" {
+		log.Fatalf("-dataset is required")
+	}
+	if *mode != "pipeline" && *mode != "inference" {
+		log.Fatalf("Unknown mode %q (want pipeline or inference)", *mode)
+	}
+	items, err := readDataset(*datasetFile, *mode)
+	if err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+	variants := []Variant{{Name: "default"}}
+	if *variantsFile != "" {
+		if variants, err = readVariants(*variantsFile); err != nil {
+			log.Fatalf("Failed to read variants: %v", err)
+		}
+	}
+
+	var dialOpts []grpc.DialOption
+	if *inProcess {
+		logger.InitLogger("error")
+		h, err := testharness.Start()
+		if err != nil {
+			log.Fatalf("Failed to start in-process services: %v", err)
+		}
+		defer h.Close()
+		*baseURL = h.Gateway.URL
+		*inferenceAddr = h.Config.GetInferenceAddress()
+		*judgeAddr = strings.TrimPrefix(h.Ollama.URL, "http://")
+		dialOpts = append(dialOpts, h.DialOption())
+	}
+
+	e := &evaluator{
+		mode:       *mode,
+		baseURL:    strings.TrimRight(*baseURL, "/"),
+		numResults: *numResults,
+		maxLength:  *maxLength,
+		timeout:    *timeout,
+	}
+	if *mode == "inference" || *bertScore {
+		conn, err := grpc.Dial(*inferenceAddr, append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, dialOpts...)...)
+		if err != nil {
+			log.Fatalf("Failed to connect to the inference service: %v", err)
+		}
+		defer conn.Close()
+		e.inference = pb.NewInferenceServiceClient(conn)
+		e.bertScore = *bertScore
+	}
+	if *judgeModel != "" {
+		host, port, err := net.SplitHostPort(*judgeAddr)
+		if err != nil {
+			log.Fatalf("Invalid -judge-ollama %q: %v", *judgeAddr, err)
+		}
+		portNum, _ := strconv.Atoi(port)
+		e.judge = ollama.NewClient(config.OllamaConfig{Host: host, Port: portNum, Model: *judgeModel, MaxTokens: 128, Timeout: *timeout})
+	}
+
+	// Stop early on Ctrl-C but still report what was scored
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Evaluating %d variants on %d items in %s mode\n", len(variants), len(items), *mode)
+	started := time.Now()
+	var results []VariantResult
+	for _, variant := range variants {
+		results = append(results, e.run(ctx, variant, items))
+	}
+	report := buildReport(results, started, *mode, len(items))
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "text":
+		report.writeText(w)
+	case "json":
+		err = report.writeJSON(w)
+	case "csv":
+		err = report.writeCSV(w)
+	default:
+		log.Fatalf("Unknown format %q (want text, json or csv)", *format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
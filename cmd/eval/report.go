@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// metricOrder lists the reported metrics; bertscore_f1 and judge only
+// appear when computed
+var metricOrder = []string{"rouge1", "rouge2", "rougeL", "bertscore_f1", "judge"}
+
+// Report compares the variants of an evaluation run
+type Report struct {
+	StartedAt time.Time         `json:"started_at"`
+	Duration  float64           `json:"duration_seconds"`
+	Mode      string            `json:"mode"`
+	Items     int               `json:"items"`
+	Baseline  string            `json:"baseline"` // the first variant, which the others are compared with
+	Best      map[string]string `json:"best"`     // the variant with the highest mean of each metric
+	Variants  []VariantReport   `json:"variants"`
+}
+
+// VariantReport holds a variant's mean scores over the items it summarized
+type VariantReport struct {
+	Name         string             `json:"name"`
+	Config       Variant            `json:"config"`
+	Summarized   int                `json:"summarized"`
+	Errors       int                `json:"errors"`
+	Judged       int                `json:"judged,omitempty"`
+	Metrics      map[string]float64 `json:"metrics"`
+	Delta        map[string]float64 `json:"delta,omitempty"` // against the baseline
+	MeanLatency  float64            `json:"mean_latency_ms"`
+	P95LatencyMs float64            `json:"p95_latency_ms"`
+	Items        []ItemResult       `json:"items"`
+}
+
+func buildReport(results []VariantResult, started time.Time, mode string, items int) *Report {
+	report := &Report{
+		StartedAt: started,
+		Duration:  time.Since(started).Seconds(),
+		Mode:      mode,
+		Items:     items,
+		Best:      make(map[string]string),
+	}
+	for _, result := range results {
+		report.Variants = append(report.Variants, summarize(result))
+	}
+	if len(report.Variants) == 0 {
+		return report
+	}
+
+	baseline := report.Variants[0]
+	report.Baseline = baseline.Name
+	for i := range report.Variants[1:] {
+		v := &report.Variants[i+1]
+		v.Delta = make(map[string]float64)
+		for metric, value := range v.Metrics {
+			if base, ok := baseline.Metrics[metric]; ok {
+				v.Delta[metric] = value - base
+			}
+		}
+	}
+	for _, metric := range metricOrder {
+		best, bestValue := "", 0.0
+		for _, v := range report.Variants {
+			if value, ok := v.Metrics[metric]; ok && (best == "" || value > bestValue) {
+				best, bestValue = v.Name, value
+			}
+		}
+		if best != "" {
+			report.Best[metric] = best
+		}
+	}
+	return report
+}
+
+func summarize(result VariantResult) VariantReport {
+	v := VariantReport{Name: result.Variant.Name, Config: result.Variant, Metrics: make(map[string]float64), Items: result.Items}
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	add := func(metric string, value float64) {
+		sums[metric] += value
+		counts[metric]++
+	}
+	var latencies []float64
+	for _, r := range result.Items {
+		if r.Summary == "" {
+			v.Errors++
+			continue
+		}
+		v.Summarized++
+		latencies = append(latencies, r.LatencyMs)
+		add("rouge1", r.Rouge1)
+		add("rouge2", r.Rouge2)
+		add("rougeL", r.RougeL)
+		if r.BERT != nil {
+			add("bertscore_f1", r.BERT.F1)
+		}
+		if r.Judge != nil {
+			add("judge", *r.Judge)
+			v.Judged++
+		}
+	}
+	for metric, sum := range sums {
+		v.Metrics[metric] = sum / float64(counts[metric])
+	}
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		total := 0.0
+		for _, l := range latencies {
+			total += l
+		}
+		v.MeanLatency = total / float64(len(latencies))
+		v.P95LatencyMs = latencies[min(int(0.95*float64(len(latencies))+0.5), len(latencies))-1]
+	}
+	return v
+}
+
+// metrics are the metrics any variant has, in report order
+func (r *Report) metrics() []string {
+	var present []string
+	for _, metric := range metricOrder {
+		for _, v := range r.Variants {
+			if _, ok := v.Metrics[metric]; ok {
+				present = append(present, metric)
+				break
+			}
+		}
+	}
+	return present
+}
+
+func (r *Report) writeText(w io.Writer) {
+	metrics := r.metrics()
+	fmt.Fprintf(w, "%d items in %s mode, %.1fs; deltas against %s\n\n", r.Items, r.Mode, r.Duration, r.Baseline)
+	fmt.Fprintf(w, "%-20s %6s %6s", "variant", "ok", "errors")
+	for _, metric := range metrics {
+		fmt.Fprintf(w, " %16s", metric)
+	}
+	fmt.Fprintf(w, " %10s %10s\n", "mean(ms)", "p95(ms)")
+	for _, v := range r.Variants {
+		fmt.Fprintf(w, "%-20s %6d %6d", v.Name, v.Summarized, v.Errors)
+		for _, metric := range metrics {
+			value, ok := v.Metrics[metric]
+			switch delta, hasDelta := v.Delta[metric]; {
+			case !ok:
+				fmt.Fprintf(w, " %16s", "-")
+			case hasDelta:
+				fmt.Fprintf(w, " %16s", fmt.Sprintf("%.3f (%+.3f)", value, delta))
+			default:
+				fmt.Fprintf(w, " %16.3f", value)
+			}
+		}
+		fmt.Fprintf(w, " %10.1f %10.1f\n", v.MeanLatency, v.P95LatencyMs)
+	}
+	if len(r.Variants) > 1 {
+		fmt.Fprintln(w, "\nBest variant by metric:")
+		for _, metric := range metrics {
+			fmt.Fprintf(w, "  %-14s %s\n", metric, r.Best[metric])
+		}
+	}
+}
+
+func (r *Report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeCSV writes one row per variant so runs can be appended and compared;
+// per-item results are only in the JSON report
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"started_at", "mode", "variant", "summarized", "errors", "mean_latency_ms", "p95_latency_ms"}
+	for _, metric := range metricOrder {
+		header = append(header, metric, metric+"_delta")
+	}
+	cw.Write(header)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, v := range r.Variants {
+		row := []string{r.StartedAt.Format(time.RFC3339), r.Mode, v.Name, strconv.Itoa(v.Summarized), strconv.Itoa(v.Errors), f(v.MeanLatency), f(v.P95LatencyMs)}
+		for _, metric := range metricOrder {
+			value, delta := "", ""
+			if m, ok := v.Metrics[metric]; ok {
+				value = f(m)
+			}
+			if d, ok := v.Delta[metric]; ok {
+				delta = f(d)
+			}
+			row = append(row, value, delta)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
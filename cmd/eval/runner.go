@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"ai-search-service/internal/gateway"
+	"ai-search-service/internal/ollama"
+	pb "ai-search-service/proto"
+)
+
+// ItemResult is how one variant did on one item
+type ItemResult struct {
+	ID        string  `json:"id"`
+	Summary   string  `json:"summary"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+
+	Rouge1 float64    `json:"rouge1"` // F1 of each
+	Rouge2 float64    `json:"rouge2"`
+	RougeL float64    `json:"rougeL"`
+	BERT   *BERTScore `json:"bertscore,omitempty"`
+
+	Judge       *float64 `json:"judge,omitempty"` // 1 to 5
+	JudgeReason string   `json:"judge_reason,omitempty"`
+	JudgeError  string   `json:"judge_error,omitempty"`
+}
+
+// VariantResult holds a variant's results on every item
+type VariantResult struct {
+	Variant Variant
+	Items   []ItemResult
+}
+
+// evaluator summarizes items the configured way and scores the summaries
+type evaluator struct {
+	mode       string
+	baseURL    string
+	numResults int
+	maxLength  int
+	timeout    time.Duration
+	client     http.Client
+
+	inference pb.InferenceServiceClient // inference mode and BERTScore
+	bertScore bool
+	judge     *ollama.Client // nil without a judge model
+}
+
+// run summarizes and scores every item with the variant, one at a time so
+// latencies are not skewed by the evaluation's own load
+func (e *evaluator) run(ctx context.Context, variant Variant, items []Item) VariantResult {
+	result := VariantResult{Variant: variant}
+	for n, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "\r%s: %d/%d", variant.Name, n+1, len(items))
+		result.Items = append(result.Items, e.evaluate(ctx, variant, item))
+	}
+	fmt.Fprintln(os.Stderr)
+	return result
+}
+
+func (e *evaluator) evaluate(ctx context.Context, variant Variant, item Item) ItemResult {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	r := ItemResult{ID: item.ID}
+	start := time.Now()
+	var err error
+	if e.mode == "inference" {
+		r.Summary, err = e.summarizeText(ctx, variant, item)
+	} else {
+		r.Summary, err = e.search(ctx, variant, item)
+	}
+	r.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+
+	r.Rouge1 = rougeN(r.Summary, item.Reference, 1)
+	r.Rouge2 = rougeN(r.Summary, item.Reference, 2)
+	r.RougeL = rougeL(r.Summary, item.Reference)
+	if e.bertScore {
+		score, err := bertScore(ctx, e.inference, r.Summary, item.Reference)
+		if err != nil {
+			r.Error = fmt.Sprintf("bertscore: %v", err)
+		} else {
+			r.BERT = score
+		}
+	}
+	if e.judge != nil {
+		score, reason, err := judge(ctx, e.judge, item, r.Summary)
+		if err != nil {
+			r.JudgeError = err.Error()
+		} else {
+			r.Judge, r.JudgeReason = &score, reason
+		}
+	}
+	return r
+}
+
+// search runs the item's query through the gateway's JSON search API
+func (e *evaluator) search(ctx context.Context, variant Variant, item Item) (string, error) {
+	body, _ := json.Marshal(gateway.SearchRequest{
+		Query:         item.Query,
+		NumResults:    e.numResults,
+		Sampling:      variant.Sampling,
+		Deterministic: variant.Deterministic,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/v1/search", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Every run must reach the model, not a cached response
+	req.Header.Set("Cache-Control", "no-cache")
+	for k, v := range variant.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var out gateway.SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("status %d: %w", resp.StatusCode, err)
+	}
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("status %d: %s", resp.StatusCode, out.Error)
+	case out.Error != "":
+		return "", fmt.Errorf("summary failed: %s", out.Error)
+	case out.Summary == "":
+		return "", fmt.Errorf("no summary")
+	}
+	return out.Summary, nil
+}
+
+// summarizeText sends the item's text, wrapped in the variant's prompt, to
+// the inference service's Summarize RPC
+func (e *evaluator) summarizeText(ctx context.Context, variant Variant, item Item) (string, error) {
+	req := &pb.SummarizeRequest{
+		RequestId:    "eval_" + item.ID,
+		ModelName:    variant.Model,
+		MaxLength:    int32(e.maxLength),
+		OriginalText: variant.prompt(item.Text),
+	}
+	if s := variant.Sampling; s != nil {
+		req.Sampling = &pb.SamplingParams{
+			Temperature:      s.Temperature,
+			TopP:             s.TopP,
+			FrequencyPenalty: s.FrequencyPenalty,
+			PresencePenalty:  s.PresencePenalty,
+			Stop:             s.Stop,
+			Seed:             s.Seed,
+		}
+	}
+	resp, err := e.inference.Summarize(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success || resp.Summary == "" {
+		return "", fmt.Errorf("summarize failed: %s", resp.Error)
+	}
+	return resp.Summary, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	pb "ai-search-service/proto"
+)
+
+// tokens splits text into lowercase words, dropping punctuation
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// f1 is the harmonic mean of precision and recall
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// rougeN is the ROUGE-N F1 of a candidate against a reference: the overlap
+// of their n-grams, each counted at most as often as in the other text
+func rougeN(candidate, reference string, n int) float64 {
+	ngrams := func(words []string) (map[string]int, int) {
+		counts := make(map[string]int)
+		total := 0
+		for i := 0; i+n <= len(words); i++ {
+			counts[strings.Join(words[i:i+n], " ")]++
+			total++
+		}
+		return counts, total
+	}
+	cand, candTotal := ngrams(tokens(candidate))
+	ref, refTotal := ngrams(tokens(reference))
+	if candTotal == 0 || refTotal == 0 {
+		return 0
+	}
+	overlap := 0
+	for gram, count := range cand {
+		overlap += min(count, ref[gram])
+	}
+	return f1(float64(overlap)/float64(candTotal), float64(overlap)/float64(refTotal))
+}
+
+// rougeL is the ROUGE-L F1: the longest common subsequence of words
+// relative to each text's length
+func rougeL(candidate, reference string) float64 {
+	cand, ref := tokens(candidate), tokens(reference)
+	if len(cand) == 0 || len(ref) == 0 {
+		return 0
+	}
+	// One row of the LCS table at a time
+	prev := make([]int, len(ref)+1)
+	curr := make([]int, len(ref)+1)
+	for i := 1; i <= len(cand); i++ {
+		for j := 1; j <= len(ref); j++ {
+			if cand[i-1] == ref[j-1] {
+				curr[j] = prev[j-1] + 1
+			} else {
+				curr[j] = max(prev[j], curr[j-1])
+			}
+		}
+		prev, curr = curr, prev
+	}
+	lcs := float64(prev[len(ref)])
+	return f1(lcs/float64(len(cand)), lcs/float64(len(ref)))
+}
+
+// BERTScore is the greedy embedding match of a candidate and a reference
+type BERTScore struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// bertScore matches each word of either text to its most similar word of
+// the other by cosine similarity, as BERTScore does. The words are embedded
+// on their own by the inference service's embedding model, not in context
+// by BERT, so scores are comparable between variants rather than with
+// published BERTScore numbers.
+func bertScore(ctx context.Context, inference pb.InferenceServiceClient, candidate, reference string) (*BERTScore, error) {
+	cand, ref := tokens(candidate), tokens(reference)
+	if len(cand) == 0 || len(ref) == 0 {
+		return &BERTScore{}, nil
+	}
+	index := make(map[string]int)
+	var words []string
+	for _, word := range append(append([]string(nil), cand...), ref...) {
+		if _, ok := index[word]; !ok {
+			index[word] = len(words)
+			words = append(words, word)
+		}
+	}
+	resp, err := inference.Embed(ctx, &pb.EmbedRequest{Texts: words})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(words) {
+		return nil, fmt.Errorf("got %d embeddings for %d words", len(resp.Embeddings), len(words))
+	}
+	vector := func(word string) []float32 { return resp.Embeddings[index[word]].Values }
+
+	// greedy is the mean, over from's words, of the best similarity in to
+	greedy := func(from, to []string) float64 {
+		total := 0.0
+		for _, a := range from {
+			best := -1.0
+			for _, b := range to {
+				best = math.Max(best, cosine(vector(a), vector(b)))
+			}
+			total += best
+		}
+		return total / float64(len(from))
+	}
+	precision, recall := greedy(cand, ref), greedy(ref, cand)
+	return &BERTScore{Precision: precision, Recall: recall, F1: f1(precision, recall)}, nil
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
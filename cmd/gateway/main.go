@@ -8,7 +8,6 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"ai-search-service/internal/config"
 	"ai-search-service/internal/gateway"
@@ -32,28 +31,35 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.New()
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
-
 	// Initialize gateway
 	gw, err := gateway.NewGateway(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize gateway: %v", err)
 	}
 
+	router := gin.New()
+	router.Use(gw.AccessLog())
+	router.Use(gin.Recovery())
+
 	// Setup routes
 	setupRoutes(router, gw)
 
 	// Create HTTP server
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Gateway.Port),
-		Handler: router,
+	server, err := gateway.NewHTTPServer(cfg, fmt.Sprintf(":%d", cfg.Gateway.Port), router)
+	if err != nil {
+		log.Fatalf("Failed to configure server: %v", err)
 	}
 
+	// pprof and runtime diagnostics on the admin port, when enabled
+	diagServer := gw.ServeDiagnostics()
+
 	// Start server in goroutine
 	go func() {
-		log.Printf("Gateway server starting on port %d", cfg.Gateway.Port)
+		scheme := "HTTP"
+		if server.TLS() {
+			scheme = "HTTPS"
+		}
+		log.Printf("Gateway server starting on port %d (%s)", cfg.Gateway.Port, scheme)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
@@ -66,10 +72,17 @@ func main() {
 
 	log.Println("Shutting down gateway server...")
 
-	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Graceful shutdown: let searches in flight finish, then close the server
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Gateway.Server.ShutdownTimeout)
 	defer cancel()
 
+	server.SetKeepAlivesEnabled(false)
+	if err := gw.Drain(ctx); err != nil {
+		log.Printf("Searches still in flight after %s: %v", cfg.Gateway.Server.ShutdownTimeout, err)
+	}
+	if diagServer != nil {
+		diagServer.Close()
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
@@ -81,22 +94,41 @@ func setupRoutes(router *gin.Engine, gw *gateway.Gateway) {
 	// Health check
 	router.GET("/health", gw.HealthCheck)
 
+	// Kubernetes liveness, readiness and startup probes
+	gw.RegisterProbeRoutes(router)
+
 	// Metrics endpoint
 	router.GET("/metrics", gw.Metrics)
 
 	// API routes
-	api := router.Group("/api/v1")
-	{
-		// Single search endpoint (handles both streaming and non-streaming)
-		api.POST("/search", gw.Search)  // Non-streaming: JSON body
-		api.GET("/search", gw.Search)   // Streaming: query params + Accept: text/event-stream
-
-		// Utility endpoints
-		api.POST("/validate", gw.ValidateInput)
-	}
+	gw.RegisterAPIRoutes(router.Group("/api/v1"))
+
+	// OpenSearch description and GET /search?q= for browsers
+	gw.RegisterBrowserRoutes(router)
+
+	// HTML fragments of the results page for HTMX swaps
+	gw.RegisterFragmentRoutes(router)
+
+	// Public pages of shared searches
+	gw.RegisterShareRoutes(router)
+
+	// Exports kept by the filesystem object store, at signed URLs
+	gw.RegisterExportRoutes(router)
+
+	// Result thumbnails, proxied instead of hotlinked
+	gw.RegisterImageRoutes(router)
+
+	// OIDC login for the web UI
+	gw.RegisterLoginRoutes(router)
+
+	// Swagger UI for the OpenAPI spec
+	gw.RegisterDocsRoutes(router)
+
+	// Fault injection admin (non-production only)
+	gw.RegisterAdminRoutes(router.Group("/admin"))
 
 	// Serve static files
 	router.Static("/static", "./web/static")
 	router.LoadHTMLGlob("web/templates/*")
-	router.GET("/", gw.Index)
+	router.GET("/", gw.RequireLogin, gw.Index)
 }
@@ -6,6 +6,8 @@ Token-native processing with beam search
 """
 
 import asyncio
+import hashlib
+import json
 import logging
 import signal
 import sys
@@ -44,6 +46,13 @@ logging.basicConfig(
 logger = logging.getLogger(__name__)
 
 
+def tokenizer_version(tokenizer) -> str:
+    """Fingerprint of a tokenizer's vocabulary, as the tokenizer service
+    reports it: every token with its ID, special tokens included"""
+    vocab = sorted(tokenizer.get_vocab().items(), key=lambda item: item[1])
+    return hashlib.sha256(json.dumps(vocab, ensure_ascii=False).encode()).hexdigest()[:16]
+
+
 class RequestContext:
     """Tracks individual inference requests for concurrency control"""
     def __init__(self, request_id: str):
@@ -97,7 +106,8 @@ class InferenceService(pb2_grpc.InferenceServiceServicer):
             # Load tokenizer first
             from transformers import AutoTokenizer
             self.tokenizer = AutoTokenizer.from_pretrained(model_name)
-            logger.info(f"✅ Tokenizer loaded: vocab size {len(self.tokenizer)}")
+            self.tokenizer_version = tokenizer_version(self.tokenizer)
+            logger.info(f"✅ Tokenizer loaded: vocab size {len(self.tokenizer)}, version {self.tokenizer_version}")
             
             # Load model with explicit CPU placement
             from transformers import AutoModelForSeq2SeqLM
@@ -182,12 +192,34 @@ class InferenceService(pb2_grpc.InferenceServiceServicer):
         logger.info(f"Inference request {request_id} completed "
                    f"(active: {active_count}/{self.max_concurrent_requests})")
     
+    def _check_tokenizer_version(self, request, context) -> bool:
+        """Refuse token IDs from a tokenizer with another vocabulary than the
+        model's, which would be summarized as other words. Token IDs without
+        a version are refused too, since nothing shows they match."""
+        if not request.token_ids or request.tokenizer_version == self.tokenizer_version:
+            return True
+        if not request.tokenizer_version:
+            logger.error(f"Refusing tokens of request {request.request_id}: no tokenizer version, "
+                         f"the model's is {self.tokenizer_version}")
+            context.set_code(grpc.StatusCode.FAILED_PRECONDITION)
+            context.set_details(f"token IDs have no tokenizer version, the model's is {self.tokenizer_version}")
+            return False
+        logger.error(f"Refusing tokens of request {request.request_id}: tokenizer version "
+                     f"{request.tokenizer_version}, the model's is {self.tokenizer_version}")
+        context.set_code(grpc.StatusCode.FAILED_PRECONDITION)
+        context.set_details(f"token IDs are from tokenizer version {request.tokenizer_version}, "
+                            f"the model's is {self.tokenizer_version}")
+        return False
+
     def Summarize(self, request, context):
         """
         Process summarization request with token-native processing
         Industry standard: Token IDs in, summary text + generated tokens out
         """
         start_time = time.time()
+
+        if not self._check_tokenizer_version(request, context):
+            return pb2.SummarizeResponse(success=False, error="tokenizer version mismatch")
         
         # Check capacity
         if not self._check_capacity():
@@ -240,7 +272,8 @@ class InferenceService(pb2_grpc.InferenceServiceServicer):
                 summary=summary,
                 success=True,
                 tokens_used=tokens_used,
-                confidence=0.90,  # Higher confidence for real BART
+                # confidence stays 0 (unknown): the summarization pipeline does
+                # not expose token scores, so the gateway scores from the sources
                 generated_token_ids=generated_tokens
             )
             
@@ -263,6 +296,9 @@ class InferenceService(pb2_grpc.InferenceServiceServicer):
         Real-time token generation with BART
         """
         start_time = time.time()
+
+        if not self._check_tokenizer_version(request, context):
+            return
         
         # Check capacity
         if not self._check_capacity():
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/diagnostics"
+	"ai-search-service/internal/faults"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/probes"
+	"ai-search-service/internal/services/inference"
+	pb "ai-search-service/proto"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize logger
+	logger.InitLogger(cfg.LogLevel)
+
+	// Create listener
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Services.Inference.Port))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	// Create gRPC server
+	s := grpc.NewServer(faults.ServerOptions(cfg, "inference")...)
+
+	// Initialize inference service
+	inferenceService, err := inference.NewInferenceService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create inference service: %v", err)
+	}
+
+	// Register service
+	pb.RegisterInferenceServiceServer(s, inferenceService)
+
+	// Preload models; HealthCheck reports "warming_up" until this completes
+	inferenceService.StartWarmup()
+
+	// Kubernetes probes on their own HTTP port; startup waits for warm-up
+	probe := probes.New("inference", cfg.Probes.Timeout)
+	probe.StartupCheck(func(ctx context.Context) error {
+		if !inferenceService.GetWarmupStatus().Ready() {
+			return errors.New("warming up models")
+		}
+		return nil
+	})
+	probe.AddCheck("inference", probes.Health(func(ctx context.Context) (*pb.HealthCheckResponse, error) {
+		return inferenceService.HealthCheck(ctx, &pb.HealthCheckRequest{})
+	}))
+	probeServer := probe.Serve(cfg.Services.Inference.ProbePort)
+
+	// pprof and runtime diagnostics on the admin port, when enabled
+	diag, err := diagnostics.New(cfg, "inference", diagnostics.Sources{})
+	if err != nil {
+		log.Fatalf("Failed to configure diagnostics: %v", err)
+	}
+	diagServer := diag.Serve(cfg.Services.Inference.AdminPort)
+
+	// Start server in goroutine
+	go func() {
+		log.Printf("Inference service starting on port %d", cfg.Services.Inference.Port)
+		if err := s.Serve(lis); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+	probe.MarkStarted()
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down inference service...")
+	s.GracefulStop()
+	inferenceService.Close()
+	if probeServer != nil {
+		probeServer.Close()
+	}
+	if diagServer != nil {
+		diagServer.Close()
+	}
+	log.Println("Inference service shutdown complete")
+}
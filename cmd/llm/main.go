@@ -8,10 +8,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"ai-search-service/internal/config"
+	"ai-search-service/internal/diagnostics"
+	"ai-search-service/internal/faults"
 	"ai-search-service/internal/logger"
+	"ai-search-service/internal/probes"
 	"ai-search-service/internal/services/llm"
 	pb "ai-search-service/proto"
 
@@ -37,7 +39,7 @@ func main() {
 	}
 
 	// Create gRPC server
-	s := grpc.NewServer()
+	s := grpc.NewServer(faults.ServerOptions(cfg, "llm")...)
 
 	// Initialize LLM service
 	llmService, err := llm.NewLLMService(cfg)
@@ -48,6 +50,23 @@ func main() {
 	// Register service
 	pb.RegisterLLMOrchestratorServiceServer(s, llmService)
 
+	// Kubernetes probes on their own HTTP port; not ready while draining or
+	// when the tokenizer or inference service is unreachable
+	probe := probes.New("llm", cfg.Probes.Timeout)
+	probe.AddCheck("llm", probes.Health(func(ctx context.Context) (*pb.HealthCheckResponse, error) {
+		return llmService.HealthCheck(ctx, &pb.HealthCheckRequest{})
+	}))
+	probe.AddCheck("tokenizer", probes.Health(llmService.TokenizerHealth))
+	probe.AddCheck("inference", probes.Health(llmService.InferenceHealth))
+	probeServer := probe.Serve(cfg.Services.LLM.ProbePort)
+
+	// pprof and runtime diagnostics on the admin port, when enabled
+	diag, err := diagnostics.New(cfg, "llm", diagnostics.Sources{Conns: llmService.Connections(), Stats: llmService.Stats})
+	if err != nil {
+		log.Fatalf("Failed to configure diagnostics: %v", err)
+	}
+	diagServer := diag.Serve(cfg.Services.LLM.AdminPort)
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("LLM Orchestrator service starting on port %d", cfg.Services.LLM.Port)
@@ -55,6 +74,7 @@ func main() {
 			log.Fatalf("Failed to serve: %v", err)
 		}
 	}()
+	probe.MarkStarted()
 
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -73,21 +93,31 @@ func main() {
 		log.Println("Context cancelled, shutting down...")
 	}
 
-	// Graceful shutdown with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Graceful shutdown: refuse new requests and let streams in flight
+	// finish, cancelling whatever is left once the timeout passes
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.LLM.ShutdownTimeout)
 	defer shutdownCancel()
 
+	llmService.Drain()
 	done := make(chan struct{})
 	go func() {
-		llmService.Stop()
 		s.GracefulStop()
 		close(done)
 	}()
 
 	select {
 	case <-done:
+		llmService.Stop()
 		log.Println("LLM Orchestrator Service stopped gracefully")
 	case <-shutdownCtx.Done():
-		log.Println("Shutdown timeout exceeded, forcing exit")
+		log.Println("Shutdown timeout exceeded, cancelling streams in flight")
+		llmService.Stop()
+		s.Stop()
+	}
+	if probeServer != nil {
+		probeServer.Close()
+	}
+	if diagServer != nil {
+		diagServer.Close()
 	}
 }
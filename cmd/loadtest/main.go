@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/testharness"
+)
+
+var defaultQueries = []string{
+	"what is retrieval augmented generation",
+	"golang concurrency patterns",
+	"kubernetes horizontal pod autoscaler",
+	"how do transformers work",
+	"best practices for grpc streaming",
+}
+
+// loadtest drives the gateway search API and reports latency percentiles
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "gateway base URL")
+	inProcess := flag.Bool("in-process", false, "run against in-memory services with fake upstreams instead of -url")
+	rps := flag.Float64("rps", 0, "target requests per second (0 = as fast as the workers allow)")
+	concurrency := flag.Int("concurrency", 10, "number of concurrent workers")
+	duration := flag.Duration("duration", 30*time.Second, "test duration")
+	streamRatio := flag.Float64("stream-ratio", 0.5, "fraction of requests that use SSE streaming (0..1)")
+	timeout := flag.Duration("timeout", 60*time.Second, "per-request timeout")
+	queriesFile := flag.String("queries", "", "file with one query per line (default: built-in set)")
+	format := flag.String("format", "text", "report format: text, json or csv")
+	out := flag.String("out", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	if *concurrency <= 0 {
+		log.Fatalf("-concurrency must be positive")
+	}
+	if *streamRatio < 0 || *streamRatio > 1 {
+		log.Fatalf("-stream-ratio must be between 0 and 1")
+	}
+
+	queries := defaultQueries
+	if *queriesFile != "" {
+		var err error
+		if queries, err = readQueries(*queriesFile); err != nil {
+			log.Fatalf("Failed to read queries: %v", err)
+		}
+	}
+
+	if *inProcess {
+		logger.InitLogger("error")
+		h, err := testharness.Start()
+		if err != nil {
+			log.Fatalf("Failed to start in-process services: %v", err)
+		}
+		defer h.Close()
+		*baseURL = h.Gateway.URL
+	}
+
+	r := &runner{
+		baseURL: strings.TrimRight(*baseURL, "/"),
+		client: &http.Client{
+			Timeout:   *timeout,
+			Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+		},
+		queries:     queries,
+		streamRatio: *streamRatio,
+	}
+
+	// Stop early on Ctrl-C but still print what was collected
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Load testing %s for %v (concurrency %d, rps %s, stream ratio %.2f)\n",
+		r.baseURL, *duration, *concurrency, formatRPS(*rps), *streamRatio)
+
+	started := time.Now()
+	r.run(ctx, *concurrency, *rps, *duration)
+	report := buildReport(r.results, started, time.Since(started), *concurrency, *rps, r.dropped)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var err error
+	switch *format {
+	case "text":
+		report.writeText(w)
+	case "json":
+		err = report.writeJSON(w)
+	case "csv":
+		err = report.writeCSV(w)
+	default:
+		log.Fatalf("Unknown format %q (want text, json or csv)", *format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
+
+func readQueries(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var queries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if q := strings.TrimSpace(scanner.Text()); q != "" && !strings.HasPrefix(q, "#") {
+			queries = append(queries, q)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries in %s", path)
+	}
+	return queries, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Report summarizes a load test run
+type Report struct {
+	StartedAt   time.Time             `json:"started_at"`
+	Duration    float64               `json:"duration_seconds"`
+	Concurrency int                   `json:"concurrency"`
+	TargetRPS   float64               `json:"target_rps"`
+	AchievedRPS float64               `json:"achieved_rps"`
+	Dropped     int                   `json:"dropped"`
+	Modes       map[string]ModeReport `json:"modes"` // "all", "streaming", "non_streaming"
+}
+
+// ModeReport holds latency percentiles and errors for one request mode
+type ModeReport struct {
+	Requests  int            `json:"requests"`
+	Errors    int            `json:"errors"`
+	ErrorRate float64        `json:"error_rate"`
+	LatencyMs Percentiles    `json:"latency_ms"`
+	TTFTMs    *Percentiles   `json:"ttft_ms,omitempty"`
+	ErrorsBy  map[string]int `json:"errors_by_type,omitempty"`
+}
+
+// Percentiles in milliseconds
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+var modeOrder = []string{"all", "streaming", "non_streaming"}
+
+func buildReport(results []result, started time.Time, elapsed time.Duration, concurrency int, rps float64, dropped int) *Report {
+	report := &Report{
+		StartedAt:   started,
+		Duration:    elapsed.Seconds(),
+		Concurrency: concurrency,
+		TargetRPS:   rps,
+		Dropped:     dropped,
+		Modes:       make(map[string]ModeReport),
+	}
+	if elapsed > 0 {
+		report.AchievedRPS = float64(len(results)) / elapsed.Seconds()
+	}
+
+	byMode := map[string][]result{"all": results}
+	for _, r := range results {
+		if r.Streaming {
+			byMode["streaming"] = append(byMode["streaming"], r)
+		} else {
+			byMode["non_streaming"] = append(byMode["non_streaming"], r)
+		}
+	}
+	for mode, rs := range byMode {
+		if len(rs) > 0 {
+			report.Modes[mode] = summarize(rs)
+		}
+	}
+	return report
+}
+
+func summarize(results []result) ModeReport {
+	m := ModeReport{Requests: len(results), ErrorsBy: make(map[string]int)}
+	var latencies, ttfts []time.Duration
+	for _, r := range results {
+		if r.Err != "" {
+			m.Errors++
+			m.ErrorsBy[r.Err]++
+			continue
+		}
+		latencies = append(latencies, r.Latency)
+		if r.TTFT > 0 {
+			ttfts = append(ttfts, r.TTFT)
+		}
+	}
+	m.ErrorRate = float64(m.Errors) / float64(m.Requests)
+	m.LatencyMs = percentiles(latencies)
+	if len(ttfts) > 0 {
+		p := percentiles(ttfts)
+		m.TTFTMs = &p
+	}
+	return m
+}
+
+// percentiles uses the nearest-rank method over successful requests
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	rank := func(p float64) float64 {
+		idx := int(p*float64(len(durations))+0.5) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(durations) {
+			idx = len(durations) - 1
+		}
+		return ms(durations[idx])
+	}
+	return Percentiles{P50: rank(0.50), P95: rank(0.95), P99: rank(0.99), Max: ms(durations[len(durations)-1])}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (r *Report) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Duration %.1fs, concurrency %d, target RPS %s, achieved RPS %.2f, dropped %d\n\n",
+		r.Duration, r.Concurrency, formatRPS(r.TargetRPS), r.AchievedRPS, r.Dropped)
+	fmt.Fprintf(w, "%-14s %8s %8s %7s %9s %9s %9s %10s %10s\n",
+		"mode", "requests", "errors", "err%", "p50(ms)", "p95(ms)", "p99(ms)", "ttft50", "ttft99")
+	for _, mode := range modeOrder {
+		m, ok := r.Modes[mode]
+		if !ok {
+			continue
+		}
+		ttft50, ttft99 := "-", "-"
+		if m.TTFTMs != nil {
+			ttft50 = fmt.Sprintf("%.1f", m.TTFTMs.P50)
+			ttft99 = fmt.Sprintf("%.1f", m.TTFTMs.P99)
+		}
+		fmt.Fprintf(w, "%-14s %8d %8d %6.2f%% %9.1f %9.1f %9.1f %10s %10s\n",
+			mode, m.Requests, m.Errors, m.ErrorRate*100, m.LatencyMs.P50, m.LatencyMs.P95, m.LatencyMs.P99, ttft50, ttft99)
+	}
+	if all, ok := r.Modes["all"]; ok && len(all.ErrorsBy) > 0 {
+		fmt.Fprintln(w, "\nErrors by type:")
+		for kind, n := range all.ErrorsBy {
+			fmt.Fprintf(w, "  %-20s %d\n", kind, n)
+		}
+	}
+}
+
+func (r *Report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeCSV writes one row per mode so runs can be appended and compared
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"started_at", "mode", "requests", "errors", "error_rate", "achieved_rps",
+		"p50_ms", "p95_ms", "p99_ms", "max_ms", "ttft_p50_ms", "ttft_p95_ms", "ttft_p99_ms"})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	for _, mode := range modeOrder {
+		m, ok := r.Modes[mode]
+		if !ok {
+			continue
+		}
+		ttft := []string{"", "", ""}
+		if m.TTFTMs != nil {
+			ttft = []string{f(m.TTFTMs.P50), f(m.TTFTMs.P95), f(m.TTFTMs.P99)}
+		}
+		cw.Write(append([]string{
+			r.StartedAt.Format(time.RFC3339), mode, strconv.Itoa(m.Requests), strconv.Itoa(m.Errors),
+			f(m.ErrorRate), f(r.AchievedRPS), f(m.LatencyMs.P50), f(m.LatencyMs.P95), f(m.LatencyMs.P99), f(m.LatencyMs.Max),
+		}, ttft...))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatRPS(rps float64) string {
+	if rps <= 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(rps, 'f', -1, 64)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// result is the outcome of a single search request
+type result struct {
+	Streaming bool
+	Latency   time.Duration
+	TTFT      time.Duration // time to first summary token; streaming only
+	Err       string
+}
+
+// runner drives search requests against the gateway
+type runner struct {
+	baseURL     string
+	client      *http.Client
+	queries     []string
+	streamRatio float64
+
+	mu      sync.Mutex
+	results []result
+	dropped int // requests the rate limiter could not hand to a free worker
+}
+
+// run issues requests for the given duration. With rps > 0 requests are paced
+// at that rate across the workers; otherwise every worker loops back to back.
+// Requests in flight when the duration ends are allowed to finish.
+func (r *runner) run(ctx context.Context, concurrency int, rps float64, duration time.Duration) {
+	dispatchCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for range jobs {
+				r.record(r.do(ctx, rng))
+			}
+		}(time.Now().UnixNano() + int64(w))
+	}
+
+	if rps > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rps))
+		defer ticker.Stop()
+	pace:
+		for {
+			select {
+			case <-dispatchCtx.Done():
+				break pace
+			case <-ticker.C:
+				select {
+				case jobs <- struct{}{}:
+				default:
+					// All workers busy: count it rather than queueing, so latency
+					// numbers are not hiding client-side backlog
+					r.mu.Lock()
+					r.dropped++
+					r.mu.Unlock()
+				}
+			}
+		}
+	} else {
+	loop:
+		for {
+			select {
+			case <-dispatchCtx.Done():
+				break loop
+			case jobs <- struct{}{}:
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+func (r *runner) record(res result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, res)
+}
+
+func (r *runner) do(ctx context.Context, rng *rand.Rand) result {
+	query := r.queries[rng.Intn(len(r.queries))]
+	if rng.Float64() < r.streamRatio {
+		return r.doStreaming(ctx, query)
+	}
+	return r.doNonStreaming(ctx, query)
+}
+
+// doNonStreaming posts a JSON search and waits for the complete response
+func (r *runner) doNonStreaming(ctx context.Context, query string) result {
+	start := time.Now()
+	res := result{}
+
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "num_results": 5})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+"/api/v1/search", bytes.NewReader(body))
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		res.Err = classifyError(ctx, err)
+		res.Latency = time.Since(start)
+		return res
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Summary string `json:"summary"`
+		Error   string `json:"error"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+	res.Latency = time.Since(start)
+
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		res.Err = fmt.Sprintf("http_%d", resp.StatusCode)
+	case decodeErr != nil:
+		res.Err = "bad_response"
+	case out.Summary == "":
+		res.Err = "empty_summary"
+	}
+	return res
+}
+
+// doStreaming runs a GET SSE search, timing the first token and the final event
+func (r *runner) doStreaming(ctx context.Context, query string) result {
+	start := time.Now()
+	res := result{Streaming: true}
+
+	params := url.Values{"query": {query}, "num_results": {"5"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/api/v1/search?"+params.Encode(), nil)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		res.Err = classifyError(ctx, err)
+		res.Latency = time.Since(start)
+		return res
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		res.Err = fmt.Sprintf("http_%d", resp.StatusCode)
+		res.Latency = time.Since(start)
+		return res
+	}
+
+	completed := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "event:") {
+			continue
+		}
+		switch strings.TrimSpace(strings.TrimPrefix(line, "event:")) {
+		case "token":
+			if res.TTFT == 0 {
+				res.TTFT = time.Since(start)
+			}
+		case "error":
+			res.Err = "sse_error"
+		case "complete":
+			completed = true
+		}
+	}
+	res.Latency = time.Since(start)
+
+	if res.Err == "" && scanner.Err() != nil {
+		res.Err = classifyError(ctx, scanner.Err())
+	}
+	if res.Err == "" && !completed {
+		res.Err = "incomplete_stream"
+	}
+	return res
+}
+
+func classifyError(ctx context.Context, err error) string {
+	if ctx.Err() != nil {
+		return "cancelled"
+	}
+	if strings.Contains(err.Error(), "Client.Timeout") || strings.Contains(err.Error(), "deadline exceeded") {
+		return "timeout"
+	}
+	return "transport"
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"os"
@@ -8,7 +9,10 @@ import (
 	"syscall"
 
 	"ai-search-service/internal/config"
+	"ai-search-service/internal/diagnostics"
+	"ai-search-service/internal/faults"
 	"ai-search-service/internal/logger"
+	"ai-search-service/internal/probes"
 	"ai-search-service/internal/services/safety"
 	pb "ai-search-service/proto"
 
@@ -32,7 +36,7 @@ func main() {
 	}
 
 	// Create gRPC server
-	s := grpc.NewServer()
+	s := grpc.NewServer(faults.ServerOptions(cfg, "safety")...)
 
 	// Initialize safety service
 	safetyService, err := safety.NewSafetyService(cfg)
@@ -43,6 +47,20 @@ func main() {
 	// Register service
 	pb.RegisterSafetyServiceServer(s, safetyService)
 
+	// Kubernetes probes on their own HTTP port
+	probe := probes.New("safety", cfg.Probes.Timeout)
+	probe.AddCheck("safety", probes.Health(func(ctx context.Context) (*pb.HealthCheckResponse, error) {
+		return safetyService.HealthCheck(ctx, &pb.HealthCheckRequest{})
+	}))
+	probeServer := probe.Serve(cfg.Services.Safety.ProbePort)
+
+	// pprof and runtime diagnostics on the admin port, when enabled
+	diag, err := diagnostics.New(cfg, "safety", diagnostics.Sources{})
+	if err != nil {
+		log.Fatalf("Failed to configure diagnostics: %v", err)
+	}
+	diagServer := diag.Serve(cfg.Services.Safety.AdminPort)
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("Safety service starting on port 8084")
@@ -50,6 +68,7 @@ func main() {
 			log.Fatalf("Failed to serve: %v", err)
 		}
 	}()
+	probe.MarkStarted()
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -58,5 +77,11 @@ func main() {
 
 	log.Println("Shutting down safety service...")
 	s.GracefulStop()
+	if probeServer != nil {
+		probeServer.Close()
+	}
+	if diagServer != nil {
+		diagServer.Close()
+	}
 	log.Println("Safety service shutdown complete")
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"os"
@@ -8,7 +9,10 @@ import (
 	"syscall"
 
 	"ai-search-service/internal/config"
+	"ai-search-service/internal/diagnostics"
+	"ai-search-service/internal/faults"
 	"ai-search-service/internal/logger"
+	"ai-search-service/internal/probes"
 	"ai-search-service/internal/services/search"
 	pb "ai-search-service/proto"
 
@@ -32,7 +36,7 @@ func main() {
 	}
 
 	// Create gRPC server
-	s := grpc.NewServer()
+	s := grpc.NewServer(faults.ServerOptions(cfg, "search")...)
 
 	// Initialize search service
 	searchService, err := search.NewSearchService(cfg)
@@ -43,6 +47,20 @@ func main() {
 	// Register service
 	pb.RegisterSearchServiceServer(s, searchService)
 
+	// Kubernetes probes on their own HTTP port
+	probe := probes.New("search", cfg.Probes.Timeout)
+	probe.AddCheck("search", probes.Health(func(ctx context.Context) (*pb.HealthCheckResponse, error) {
+		return searchService.HealthCheck(ctx, &pb.HealthCheckRequest{})
+	}))
+	probeServer := probe.Serve(cfg.Services.Search.ProbePort)
+
+	// pprof and runtime diagnostics on the admin port, when enabled
+	diag, err := diagnostics.New(cfg, "search", diagnostics.Sources{Conns: searchService.Connections()})
+	if err != nil {
+		log.Fatalf("Failed to configure diagnostics: %v", err)
+	}
+	diagServer := diag.Serve(cfg.Services.Search.AdminPort)
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("Search service starting on port 8081")
@@ -50,6 +68,7 @@ func main() {
 			log.Fatalf("Failed to serve: %v", err)
 		}
 	}()
+	probe.MarkStarted()
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -58,5 +77,11 @@ func main() {
 
 	log.Println("Shutting down search service...")
 	s.GracefulStop()
+	if probeServer != nil {
+		probeServer.Close()
+	}
+	if diagServer != nil {
+		diagServer.Close()
+	}
 	log.Println("Search service shutdown complete")
 }
@@ -6,7 +6,10 @@ Mac-optimized with proper Hugging Face tokenizers
 """
 
 import asyncio
+import hashlib
+import json
 import logging
+import re
 import signal
 import sys
 import time
@@ -14,6 +17,10 @@ import os
 from typing import Optional
 
 import grpc
+from functools import lru_cache
+from jinja2.exceptions import TemplateError
+from jinja2.ext import loopcontrols
+from jinja2.sandbox import ImmutableSandboxedEnvironment
 from transformers import AutoTokenizer
 
 # Import generated protobuf code
@@ -34,6 +41,115 @@ logging.basicConfig(
 logger = logging.getLogger(__name__)
 
 
+class IncrementalDecoder:
+    """
+    Stateful streaming decoder for one generation request.
+    Decoding tokens one at a time splits words made of several BPE pieces and
+    multi-byte UTF-8 characters spread across byte-level tokens. This keeps every
+    token seen so far and re-decodes a small window, emitting only the text that
+    is complete: output ending in U+FFFD (an unfinished byte sequence) is held
+    back until the following tokens resolve it.
+    """
+
+    def __init__(self, tokenizer, skip_special_tokens: bool):
+        self.tokenizer = tokenizer
+        self.skip_special_tokens = skip_special_tokens
+        self.token_ids = []
+        self.prefix_offset = 0  # start of the context window re-decoded for merging
+        self.read_offset = 0    # end of the text already emitted
+
+    def _decode(self, token_ids) -> str:
+        # No space clean-up: it depends on surrounding text and would make
+        # fragments disagree with a full decode
+        return self.tokenizer.decode(
+            token_ids,
+            skip_special_tokens=self.skip_special_tokens,
+            clean_up_tokenization_spaces=False
+        )
+
+    def add(self, token_ids) -> str:
+        """Consume new tokens and return the newly completed text"""
+        self.token_ids.extend(token_ids)
+        prefix_text = self._decode(self.token_ids[self.prefix_offset:self.read_offset])
+        new_text = self._decode(self.token_ids[self.prefix_offset:])
+
+        if len(new_text) <= len(prefix_text) or new_text.endswith("\ufffd"):
+            # Incomplete character or merge - wait for more tokens
+            return ""
+
+        self.prefix_offset = self.read_offset
+        self.read_offset = len(self.token_ids)
+        return new_text[len(prefix_text):]
+
+    def flush(self) -> str:
+        """Return whatever is still held back at the end of generation"""
+        prefix_text = self._decode(self.token_ids[self.prefix_offset:self.read_offset])
+        new_text = self._decode(self.token_ids[self.prefix_offset:])
+        self.prefix_offset = self.read_offset = len(self.token_ids)
+        return new_text[len(prefix_text):]
+
+
+# Prompt templates assembled by BuildPrompt; keep in sync with internal/prompt.
+# Models with chat roles get "system" as the system message and "user" as the
+# user message, others the two concatenated. "truncate" is the variable cut
+# when a prompt exceeds the context window.
+TEMPLATES = {
+    "summarize": {
+        "system": "{{instruction}}",
+        "user": "{{user_instruction}}{{results}}",
+        "truncate": "results",
+    },
+    "summarize_continue": {
+        "system": "{{instruction}}",
+        "user": "{{user_instruction}}{{results}}\n\nSummary so far: {{summary}}",
+        "truncate": "results",
+    },
+}
+
+# Encoder-decoder models: the prompt is framed by their own special tokens
+SEQ2SEQ_PREFIXES = ("facebook/bart", "google-t5/", "t5-", "google/flan-t5")
+
+_PLACEHOLDER = re.compile(r"\{\{\s*(\w+)\s*\}\}")
+
+
+def render_template(template: str, variables) -> str:
+    """Fill {{name}} placeholders; variables that are not set render empty"""
+    return _PLACEHOLDER.sub(lambda m: variables.get(m.group(1), ""), template)
+
+
+def join_prompt(system: str, user: str) -> str:
+    """The system and user parts as one text, as Template.Text joins them in
+    internal/prompt: the system part's trailing whitespace becomes a blank line"""
+    system = system.rstrip()
+    return system + "\n\n" + user if system else user
+
+
+def tokenizer_version(tokenizer) -> str:
+    """Fingerprint of a tokenizer's vocabulary: every token with its ID,
+    special tokens included. Token IDs mean the same to a model only when
+    this matches the version it is pinned to (inference.tokenizer_pins)."""
+    vocab = sorted(tokenizer.get_vocab().items(), key=lambda item: item[1])
+    return hashlib.sha256(json.dumps(vocab, ensure_ascii=False).encode()).hexdigest()[:16]
+
+
+def _raise_exception(message):
+    raise TemplateError(message)
+
+
+def _tojson(value, ensure_ascii=False, indent=None, separators=None, sort_keys=False):
+    # Hugging Face's tojson rather than Jinja's, which escapes HTML characters
+    return json.dumps(value, ensure_ascii=ensure_ascii, indent=indent, separators=separators, sort_keys=sort_keys)
+
+
+@lru_cache(maxsize=32)
+def compile_chat_template(source: str):
+    """Compile a chat template in the Jinja environment Hugging Face uses for them"""
+    env = ImmutableSandboxedEnvironment(trim_blocks=True, lstrip_blocks=True, extensions=[loopcontrols])
+    env.filters["tojson"] = _tojson
+    env.globals["raise_exception"] = _raise_exception
+    return env.from_string(source)
+
+
 class TokenizerService(pb2_grpc.TokenizerServiceServicer):
     """
     Python-based tokenizer service with real BART tokenization
@@ -42,6 +158,7 @@ class TokenizerService(pb2_grpc.TokenizerServiceServicer):
     
     def __init__(self):
         self.tokenizers = {}
+        self.versions = {}  # tokenizer_version by model
         self._initialize_tokenizers()
     
     def _initialize_tokenizers(self):
@@ -57,7 +174,8 @@ class TokenizerService(pb2_grpc.TokenizerServiceServicer):
                 logger.info(f"Loading tokenizer: {model}")
                 self.tokenizers[model] = AutoTokenizer.from_pretrained(model, use_fast=True)
                 vocab_size = len(self.tokenizers[model])
-                logger.info(f"✅ {model} loaded - vocab size: {vocab_size}")
+                self.versions[model] = tokenizer_version(self.tokenizers[model])
+                logger.info(f"✅ {model} loaded - vocab size: {vocab_size}, tokenizer version: {self.versions[model]}")
             except Exception as e:
                 logger.error(f"Failed to load {model}: {e}")
         
@@ -109,8 +227,16 @@ class TokenizerService(pb2_grpc.TokenizerServiceServicer):
             token_ids = encoding['input_ids']
             token_strings = tokenizer.convert_ids_to_tokens(token_ids)
             
-            # Handle truncation
-            was_truncated = len(token_ids) >= max_length
+            # Handle truncation: count the whole text so callers see by how much it overflowed
+            original_count = len(tokenizer(
+                request.text,
+                truncation=False,
+                return_tensors=None,
+                add_special_tokens=request.include_special_tokens
+            )['input_ids'])
+            was_truncated = original_count > len(token_ids)
+            if was_truncated:
+                logger.warning(f"Text truncated from {original_count} to {len(token_ids)} tokens for model '{actual_model}'")
             truncated_text = request.text if not was_truncated else tokenizer.decode(token_ids, skip_special_tokens=True)
             
             processing_time = (time.time() - start_time) * 1000
@@ -126,7 +252,10 @@ class TokenizerService(pb2_grpc.TokenizerServiceServicer):
                 model_used=actual_model,
                 processing_time_ms=processing_time,
                 cache_status=cache_status,
-                success=True
+                success=True,
+                original_token_count=original_count,
+                context_window=max_length,
+                tokenizer_version=self.versions[actual_model]
             )
             
         except Exception as e:
@@ -182,6 +311,172 @@ class TokenizerService(pb2_grpc.TokenizerServiceServicer):
                 error=str(e)
             )
     
+    def _model_family(self, tokenizer, model_name: str) -> str:
+        """How prompts of a model get their special tokens"""
+        if getattr(tokenizer, "chat_template", None):
+            return "chat"
+        if model_name.startswith(SEQ2SEQ_PREFIXES):
+            return "seq2seq"
+        return "causal"
+
+    def _encode_prompt(self, tokenizer, family: str, template, variables, chat=None):
+        """Render a template and tokenize it for the model family. Returns
+        the token IDs and the assembled text, special tokens included.
+        chat is a configured chat template with its BOS and EOS tokens."""
+        system = render_template(template["system"], variables)
+        user = render_template(template["user"], variables)
+
+        if family == "chat":
+            # The chat template adds BOS, roles and the assistant turn the
+            # summary is generated in
+            def render(messages):
+                if chat is None:
+                    return tokenizer.apply_chat_template(messages, tokenize=False, add_generation_prompt=True)
+                chat_template, bos_token, eos_token = chat
+                return chat_template.render(
+                    messages=messages,
+                    bos_token=bos_token or tokenizer.bos_token or "",
+                    eos_token=eos_token or tokenizer.eos_token or "",
+                    add_generation_prompt=True
+                )
+
+            if not system:
+                text = render([{"role": "user", "content": user}])
+            else:
+                try:
+                    text = render([{"role": "system", "content": system}, {"role": "user", "content": user}])
+                except TemplateError:
+                    # Templates without a system role get it folded into the
+                    # user message, as prompt.FoldSystem does
+                    text = render([{"role": "user", "content": join_prompt(system, user)}])
+            return tokenizer(text, add_special_tokens=False)["input_ids"], text
+
+        if family == "seq2seq":
+            # BART: <s> ... </s>; T5: ... </s>
+            token_ids = tokenizer(join_prompt(system, user), add_special_tokens=True)["input_ids"]
+        else:
+            # Causal models continue the prompt: BOS when the model has one,
+            # never EOS, which would tell the model the text is finished
+            token_ids = tokenizer(join_prompt(system, user), add_special_tokens=False)["input_ids"]
+            if tokenizer.bos_token_id is not None:
+                token_ids = [tokenizer.bos_token_id] + token_ids
+        return token_ids, tokenizer.decode(token_ids, skip_special_tokens=False)
+
+    def BuildPrompt(self, request, context):
+        """Assemble a prompt template into token IDs with the model's special tokens"""
+        start_time = time.time()
+
+        template = TEMPLATES.get(request.template_id)
+        if template is None:
+            context.set_code(grpc.StatusCode.INVALID_ARGUMENT)
+            context.set_details(f"Unknown prompt template: {request.template_id}")
+            return pb2.BuildPromptResponse()
+
+        try:
+            tokenizer = self._get_tokenizer(request.model_name)
+            actual_model = request.model_name if request.model_name in self.tokenizers else self.default_model
+            family = self._model_family(tokenizer, actual_model)
+            chat = None
+            if request.chat_template:
+                family = "chat"
+                chat = (compile_chat_template(request.chat_template), request.bos_token, request.eos_token)
+            max_length = min(request.max_tokens, 1024) if request.max_tokens > 0 else 1024
+
+            variables = dict(request.variables)
+            token_ids, text = self._encode_prompt(tokenizer, family, template, variables, chat)
+            original_count = len(token_ids)
+
+            # Over the context window: cut the truncatable variable, so the
+            # instruction, what follows it and the special tokens are kept
+            truncate = template["truncate"]
+            for _ in range(3):
+                overflow = len(token_ids) - max_length
+                if overflow <= 0 or not variables.get(truncate):
+                    break
+                ids = tokenizer(variables[truncate], add_special_tokens=False)["input_ids"]
+                variables[truncate] = tokenizer.decode(ids[:max(len(ids) - overflow, 0)], skip_special_tokens=True)
+                token_ids, text = self._encode_prompt(tokenizer, family, template, variables, chat)
+            if len(token_ids) > max_length:
+                token_ids = token_ids[:max_length]
+            was_truncated = len(token_ids) < original_count
+            if was_truncated:
+                logger.warning(f"Prompt {request.template_id} truncated from {original_count} to {len(token_ids)} tokens for model '{actual_model}'")
+
+            processing_time = (time.time() - start_time) * 1000
+            logger.info(f"✅ Prompt {request.template_id} built for {family} model '{actual_model}': {len(token_ids)} tokens ({processing_time:.2f}ms)")
+
+            return pb2.BuildPromptResponse(
+                tokens=pb2.TokenizeResponse(
+                    token_ids=token_ids,
+                    token_strings=tokenizer.convert_ids_to_tokens(token_ids),
+                    token_count=len(token_ids),
+                    was_truncated=was_truncated,
+                    model_used=actual_model,
+                    processing_time_ms=processing_time,
+                    cache_status="disabled",
+                    success=True,
+                    original_token_count=original_count,
+                    context_window=max_length,
+                    tokenizer_version=self.versions[actual_model]
+                ),
+                prompt=text,
+                model_family=family
+            )
+
+        except TemplateError as e:
+            # A chat template that does not parse or render
+            logger.error(f"Building prompt {request.template_id} failed in its chat template: {e}")
+            context.set_code(grpc.StatusCode.INVALID_ARGUMENT)
+            context.set_details(f"invalid chat template: {str(e)}")
+            return pb2.BuildPromptResponse(
+                tokens=pb2.TokenizeResponse(success=False, error=str(e))
+            )
+        except Exception as e:
+            logger.error(f"Building prompt {request.template_id} failed: {e}")
+            context.set_code(grpc.StatusCode.INTERNAL)
+            context.set_details(f"Building prompt failed: {str(e)}")
+            return pb2.BuildPromptResponse(
+                tokens=pb2.TokenizeResponse(success=False, error=str(e))
+            )
+
+    async def DecodeStream(self, request_iterator, context):
+        """Stateful streaming detokenization - one stream per generation request"""
+        decoder = None
+        request_id = ""
+        token_count = 0
+
+        try:
+            async for request in request_iterator:
+                if decoder is None:
+                    request_id = request.request_id
+                    tokenizer = self._get_tokenizer(request.model_name)
+                    decoder = IncrementalDecoder(tokenizer, request.skip_special_tokens)
+                    logger.info(f"Starting decode stream {request_id} for model '{request.model_name}'")
+
+                token_count += len(request.token_ids)
+                text = decoder.add(list(request.token_ids)) if request.token_ids else ""
+                if request.is_final:
+                    text += decoder.flush()
+
+                yield pb2.DecodeStreamResponse(
+                    text=text,
+                    token_count=token_count,
+                    success=True
+                )
+
+                if request.is_final:
+                    break
+
+            logger.info(f"✅ Decode stream {request_id} complete: {token_count} tokens")
+
+        except Exception as e:
+            logger.error(f"Decode stream {request_id} failed: {e}")
+            yield pb2.DecodeStreamResponse(
+                token_count=token_count,
+                success=False,
+                error=str(e)
+            )
+
     def BatchTokenize(self, request, context):
         """Batch tokenization (simple implementation)"""
         responses = []
@@ -227,7 +522,8 @@ class TokenizerService(pb2_grpc.TokenizerServiceServicer):
                 vocab_size=vocab_size,
                 special_tokens=special_tokens,
                 encoding_name=tokenizer.__class__.__name__,
-                model_name=actual_model
+                model_name=actual_model,
+                tokenizer_version=self.versions[actual_model]
             )
             
         except Exception as e:
@@ -0,0 +1,148 @@
+// Package answers looks up structured data for queries that ask for it:
+// current weather, stock quotes and sports results from provider APIs.
+// Providers recognize their queries from the wording alone; the pipeline
+// shows their data next to the summary, or in place of it when the query
+// asks for nothing more, and grounds the summary in it.
+package answers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"ai-search-service/internal/config"
+)
+
+// Kinds of structured data
+const (
+	KindWeather = "weather"
+	KindStock   = "stock"
+	KindSports  = "sports"
+)
+
+// Data is a provider's structured answer to a query
+type Data struct {
+	Kind     string `json:"kind"`
+	Provider string `json:"provider"`
+	Title    string `json:"title"`    // what the data is about, e.g. "Paris, FR"
+	Headline string `json:"headline"` // the answer in a line, e.g. "18°C, light rain"
+	Facts    []Fact `json:"facts,omitempty"`
+	AsOf     string `json:"as_of,omitempty"`  // RFC 3339 time the data was observed
+	Source   string `json:"source,omitempty"` // page the data can be checked on
+}
+
+// Fact is one labelled value of the data
+type Fact struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Text renders the data as one line of prose
+func (d *Data) Text() string {
+	var b strings.Builder
+	b.WriteString(d.Title + ": " + d.Headline + ".")
+	for i, fact := range d.Facts {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(fact.Label + ": " + fact.Value)
+	}
+	if d.AsOf != "" {
+		b.WriteString(" (as of " + d.AsOf + ")")
+	}
+	return b.String()
+}
+
+// Lookup fetches the data for a recognized query. It returns nil without an
+// error when there turns out to be none, e.g. an unknown city or ticker.
+type Lookup func(ctx context.Context) (*Data, error)
+
+// Provider answers one kind of query with data from an API
+type Provider interface {
+	Name() string
+	// Match recognizes a query for the provider without doing any I/O,
+	// returning nil for other queries
+	Match(query string) Lookup
+}
+
+// narrativeWords ask for more than the data: an explanation, news or advice
+var narrativeWords = map[string]bool{
+	"why": true, "explain": true, "news": true, "analysis": true,
+	"should": true, "outlook": true, "history": true, "impact": true,
+	"affect": true, "compare": true, "vs": true, "versus": true,
+	"predict": true, "prediction": true,
+}
+
+// WantsNarrative reports whether a query asks for a written answer beyond
+// the structured data, which is then summarized with the data as context
+func WantsNarrative(query string) bool {
+	for _, word := range strings.Fields(normalize(query)) {
+		if narrativeWords[strings.Trim(word, ".,;:!'\"")] {
+			return true
+		}
+	}
+	return false
+}
+
+// Configured returns the built-in providers enabled in cfg
+func Configured(cfg *config.Config) []Provider {
+	providersCfg := cfg.Gateway.AnswerProviders
+	var providers []Provider
+	if providersCfg.Weather.Enabled && providersCfg.Weather.APIKey != "" {
+		providers = append(providers, newOpenWeather(cfg, providersCfg.Weather))
+	}
+	if providersCfg.Stocks.Enabled {
+		providers = append(providers, newYahooFinance(cfg, providersCfg.Stocks))
+	}
+	if providersCfg.Sports.Enabled {
+		providers = append(providers, newSportsDB(cfg, providersCfg.Sports))
+	}
+	return providers
+}
+
+// normalize lowercases a query and collapses its whitespace, dropping a
+// trailing question mark
+func normalize(query string) string {
+	return strings.TrimRight(strings.ToLower(strings.Join(strings.Fields(query), " ")), "?")
+}
+
+// matchSubject returns the first submatch of the first pattern matching q
+func matchSubject(patterns []*regexp.Regexp, q string) (string, bool) {
+	for _, pattern := range patterns {
+		if m := pattern.FindStringSubmatch(q); m != nil {
+			return strings.TrimSpace(m[1]), true
+		}
+	}
+	return "", false
+}
+
+// getJSON decodes the JSON response to a GET of url into v, reporting
+// whether the resource exists
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return true, nil
+}
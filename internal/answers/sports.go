@@ -0,0 +1,129 @@
+package answers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+)
+
+var (
+	// lastResultPatterns capture the team a query asks the latest result for
+	lastResultPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^(?:the )?([a-z][a-z0-9 .&'-]*?) (?:score|scores|result|results|last game|last match)(?: today| yesterday| last night)?$`),
+		regexp.MustCompile(`^did (?:the )?([a-z][a-z0-9 .&'-]*?) (?:win|lose|play)(?: today| yesterday| last night)?$`),
+	}
+	// nextGamePatterns capture the team a query asks the next game for
+	nextGamePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^(?:the )?([a-z][a-z0-9 .&'-]*?) (?:next game|next match|fixtures?|schedule)$`),
+		regexp.MustCompile(`^when (?:do|does|is|are) (?:the )?([a-z][a-z0-9 .&'-]*?) (?:play(?:ing)? )?next(?: game| match)?$`),
+	}
+)
+
+// sportsDB reports a team's last result or next game from TheSportsDB
+type sportsDB struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newSportsDB(cfg *config.Config, providerCfg config.AnswerProviderConfig) *sportsDB {
+	return &sportsDB{
+		baseURL: strings.TrimSuffix(providerCfg.URL, "/"),
+		client:  &http.Client{Transport: upstream.WrapTransport(cfg, "sportsdb", nil)},
+	}
+}
+
+func (p *sportsDB) Name() string { return "sportsdb" }
+
+func (p *sportsDB) Match(query string) Lookup {
+	q := normalize(query)
+	if team, ok := matchSubject(nextGamePatterns, q); ok {
+		return func(ctx context.Context) (*Data, error) {
+			return p.game(ctx, team, true)
+		}
+	}
+	if team, ok := matchSubject(lastResultPatterns, q); ok {
+		return func(ctx context.Context) (*Data, error) {
+			return p.game(ctx, team, false)
+		}
+	}
+	return nil
+}
+
+// sportsEvent is an event of TheSportsDB's events responses
+type sportsEvent struct {
+	ID        string `json:"idEvent"`
+	League    string `json:"strLeague"`
+	HomeTeam  string `json:"strHomeTeam"`
+	AwayTeam  string `json:"strAwayTeam"`
+	HomeScore string `json:"intHomeScore"`
+	AwayScore string `json:"intAwayScore"`
+	Date      string `json:"dateEvent"`
+	Time      string `json:"strTime"`
+	Venue     string `json:"strVenue"`
+}
+
+// game looks up the team's next game or last result; an unknown team, or
+// one without such a game, has no answer
+func (p *sportsDB) game(ctx context.Context, team string, next bool) (*Data, error) {
+	var teams struct {
+		Teams []struct {
+			ID   string `json:"idTeam"`
+			Name string `json:"strTeam"`
+		} `json:"teams"`
+	}
+	found, err := getJSON(ctx, p.client, p.baseURL+"/searchteams.php?t="+url.QueryEscape(team), &teams)
+	if err != nil {
+		return nil, fmt.Errorf("sportsdb team search %w", err)
+	}
+	if !found || len(teams.Teams) == 0 {
+		return nil, nil
+	}
+	id, name := teams.Teams[0].ID, teams.Teams[0].Name
+
+	var events struct {
+		Events  []sportsEvent `json:"events"`  // eventsnext
+		Results []sportsEvent `json:"results"` // eventslast
+	}
+	endpoint, title := "/eventslast.php", name+" last result"
+	if next {
+		endpoint, title = "/eventsnext.php", name+" next game"
+	}
+	found, err = getJSON(ctx, p.client, p.baseURL+endpoint+"?id="+url.QueryEscape(id), &events)
+	if err != nil {
+		return nil, fmt.Errorf("sportsdb events %w", err)
+	}
+	list := events.Results
+	if next {
+		list = events.Events
+	}
+	if !found || len(list) == 0 {
+		return nil, nil
+	}
+
+	event := list[0]
+	headline := event.HomeTeam + " vs " + event.AwayTeam
+	if !next && event.HomeScore != "" && event.AwayScore != "" {
+		headline = fmt.Sprintf("%s %s - %s %s", event.HomeTeam, event.HomeScore, event.AwayScore, event.AwayTeam)
+	}
+	data := &Data{Kind: KindSports, Provider: p.Name(), Title: title, Headline: headline}
+	for _, fact := range []Fact{
+		{Label: "League", Value: event.League},
+		{Label: "Date", Value: event.Date},
+		{Label: "Time", Value: event.Time},
+		{Label: "Venue", Value: event.Venue},
+	} {
+		if fact.Value != "" && (next || fact.Label != "Time") {
+			data.Facts = append(data.Facts, fact)
+		}
+	}
+	if event.ID != "" {
+		data.Source = "https://www.thesportsdb.com/event/" + url.PathEscape(event.ID)
+	}
+	return data, nil
+}
@@ -0,0 +1,122 @@
+package answers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+)
+
+// tickerPatterns capture the ticker symbol a query asks the quote for
+var tickerPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?:^|\s)\$([a-z]{1,5}(?:\.[a-z]{1,2})?)\b`),
+	regexp.MustCompile(`\b([a-z]{1,5}(?:\.[a-z]{1,2})?) (?:stock|share|shares|quote)\b`),
+	regexp.MustCompile(`\b(?:stock|share) price (?:of|for) ([a-z]{1,5}(?:\.[a-z]{1,2})?)$`),
+}
+
+// notTickers are short words that precede "stock" without naming one
+var notTickers = map[string]bool{
+	"the": true, "a": true, "my": true, "our": true, "your": true, "his": true,
+	"her": true, "this": true, "that": true, "which": true, "what": true,
+	"best": true, "top": true, "buy": true, "sell": true, "is": true,
+	"in": true, "of": true, "why": true, "how": true, "penny": true,
+	"tech": true, "bank": true, "ai": true,
+}
+
+// yahooFinance quotes stocks from the Yahoo Finance chart API
+type yahooFinance struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newYahooFinance(cfg *config.Config, providerCfg config.AnswerProviderConfig) *yahooFinance {
+	return &yahooFinance{
+		baseURL: strings.TrimSuffix(providerCfg.URL, "/"),
+		client:  &http.Client{Transport: upstream.WrapTransport(cfg, "yahoo_finance", nil)},
+	}
+}
+
+func (p *yahooFinance) Name() string { return "yahoo_finance" }
+
+func (p *yahooFinance) Match(query string) Lookup {
+	symbol, ok := matchSubject(tickerPatterns, normalize(query))
+	if !ok || notTickers[symbol] {
+		return nil
+	}
+	return func(ctx context.Context) (*Data, error) {
+		return p.quote(ctx, strings.ToUpper(symbol))
+	}
+}
+
+// yahooChartResponse is the part of the chart response used
+type yahooChartResponse struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				Symbol             string  `json:"symbol"`
+				Currency           string  `json:"currency"`
+				ExchangeName       string  `json:"exchangeName"`
+				LongName           string  `json:"longName"`
+				ShortName          string  `json:"shortName"`
+				RegularMarketPrice float64 `json:"regularMarketPrice"`
+				PreviousClose      float64 `json:"chartPreviousClose"`
+				DayHigh            float64 `json:"regularMarketDayHigh"`
+				DayLow             float64 `json:"regularMarketDayLow"`
+				RegularMarketTime  int64   `json:"regularMarketTime"`
+			} `json:"meta"`
+		} `json:"result"`
+	} `json:"chart"`
+}
+
+// quote looks up the latest price of symbol; an unknown symbol has no answer
+func (p *yahooFinance) quote(ctx context.Context, symbol string) (*Data, error) {
+	var resp yahooChartResponse
+	found, err := getJSON(ctx, p.client, p.baseURL+"/v8/finance/chart/"+url.PathEscape(symbol)+"?range=1d&interval=1d", &resp)
+	if err != nil {
+		return nil, fmt.Errorf("yahoo finance %w", err)
+	}
+	if !found || len(resp.Chart.Result) == 0 || resp.Chart.Result[0].Meta.RegularMarketPrice == 0 {
+		return nil, nil
+	}
+
+	meta := resp.Chart.Result[0].Meta
+	name := meta.LongName
+	if name == "" {
+		name = meta.ShortName
+	}
+	title := meta.Symbol
+	if name != "" {
+		title = name + " (" + meta.Symbol + ")"
+	}
+	headline := fmt.Sprintf("%.2f %s", meta.RegularMarketPrice, meta.Currency)
+	if meta.PreviousClose > 0 {
+		change := meta.RegularMarketPrice - meta.PreviousClose
+		headline += fmt.Sprintf(" %+.2f (%+.2f%%)", change, change/meta.PreviousClose*100)
+	}
+	data := &Data{
+		Kind:     KindStock,
+		Provider: p.Name(),
+		Title:    title,
+		Headline: headline,
+		Source:   "https://finance.yahoo.com/quote/" + url.PathEscape(meta.Symbol),
+	}
+	if meta.ExchangeName != "" {
+		data.Facts = append(data.Facts, Fact{Label: "Exchange", Value: meta.ExchangeName})
+	}
+	if meta.PreviousClose > 0 {
+		data.Facts = append(data.Facts, Fact{Label: "Previous close", Value: fmt.Sprintf("%.2f", meta.PreviousClose)})
+	}
+	if meta.DayLow > 0 && meta.DayHigh > 0 {
+		data.Facts = append(data.Facts, Fact{Label: "Day range", Value: fmt.Sprintf("%.2f - %.2f", meta.DayLow, meta.DayHigh)})
+	}
+	if meta.RegularMarketTime > 0 {
+		data.AsOf = time.Unix(meta.RegularMarketTime, 0).UTC().Format(time.RFC3339)
+	}
+	return data, nil
+}
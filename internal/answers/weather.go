@@ -0,0 +1,112 @@
+package answers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+)
+
+// weatherPatterns capture the place a query asks the current weather for
+var weatherPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:weather|temperature) (?:in|for|at) ([a-z][a-z .,'-]*?)(?: (?:today|now|right now|currently))?$`),
+	regexp.MustCompile(`^([a-z][a-z .,'-]*?) (?:weather|temperature)(?: (?:today|now|right now|currently))?$`),
+}
+
+// openWeather reports current conditions from the OpenWeather API
+type openWeather struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func newOpenWeather(cfg *config.Config, providerCfg config.AnswerProviderConfig) *openWeather {
+	return &openWeather{
+		baseURL: strings.TrimSuffix(providerCfg.URL, "/"),
+		apiKey:  providerCfg.APIKey,
+		client:  &http.Client{Transport: upstream.WrapTransport(cfg, "openweather", nil)},
+	}
+}
+
+func (p *openWeather) Name() string { return "openweather" }
+
+func (p *openWeather) Match(query string) Lookup {
+	place, ok := matchSubject(weatherPatterns, normalize(query))
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context) (*Data, error) {
+		return p.current(ctx, place)
+	}
+}
+
+// openWeatherResponse is the part of the current weather response used
+type openWeatherResponse struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Dt   int64  `json:"dt"`
+	Sys  struct {
+		Country string `json:"country"`
+	} `json:"sys"`
+	Weather []struct {
+		Description string `json:"description"`
+	} `json:"weather"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+	} `json:"wind"`
+}
+
+// current looks up the weather at place in metric units; an unknown place
+// has no answer
+func (p *openWeather) current(ctx context.Context, place string) (*Data, error) {
+	params := url.Values{"q": {place}, "appid": {p.apiKey}, "units": {"metric"}}
+	var resp openWeatherResponse
+	found, err := getJSON(ctx, p.client, p.baseURL+"/weather?"+params.Encode(), &resp)
+	if err != nil {
+		return nil, fmt.Errorf("openweather %w", err)
+	}
+	if !found || resp.Name == "" {
+		return nil, nil
+	}
+
+	title := resp.Name
+	if resp.Sys.Country != "" {
+		title += ", " + resp.Sys.Country
+	}
+	headline := fmt.Sprintf("%.0f°C", resp.Main.Temp)
+	if len(resp.Weather) > 0 {
+		headline += ", " + resp.Weather[0].Description
+	}
+	data := &Data{
+		Kind:     KindWeather,
+		Provider: p.Name(),
+		Title:    title,
+		Headline: headline,
+		Facts: []Fact{
+			{Label: "Feels like", Value: fmt.Sprintf("%.0f°C", resp.Main.FeelsLike)},
+			{Label: "Low / high", Value: fmt.Sprintf("%.0f°C / %.0f°C", resp.Main.TempMin, resp.Main.TempMax)},
+			{Label: "Humidity", Value: fmt.Sprintf("%d%%", resp.Main.Humidity)},
+			{Label: "Wind", Value: fmt.Sprintf("%.1f m/s", resp.Wind.Speed)},
+		},
+	}
+	if resp.Dt > 0 {
+		data.AsOf = time.Unix(resp.Dt, 0).UTC().Format(time.RFC3339)
+	}
+	if resp.ID > 0 {
+		data.Source = fmt.Sprintf("https://openweathermap.org/city/%d", resp.ID)
+	}
+	return data, nil
+}
@@ -0,0 +1,44 @@
+// Package auth identifies callers of the gateway's admin endpoints and web
+// UI: roles, and the verification of OpenID Connect tokens.
+package auth
+
+import "fmt"
+
+// Role orders what a caller may do; each role includes the ones below it
+type Role int
+
+const (
+	RoleNone     Role = iota
+	RoleViewer        // reads admin state
+	RoleOperator      // also changes runtime state
+	RoleAdmin         // also manages tenants and reads the audit log
+)
+
+var roleNames = map[Role]string{RoleNone: "none", RoleViewer: "viewer", RoleOperator: "operator", RoleAdmin: "admin"}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// Allows reports whether the role includes required
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// ParseRole parses viewer, operator or admin
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if n == name && role != RoleNone {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q (want viewer, operator or admin)", name)
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Subject string `json:"subject"` // key name or token subject
+	Email   string `json:"email,omitempty"`
+	Role    Role   `json:"-"`
+	Method  string `json:"method"` // api_key, admin_token or oidc
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Session is a logged-in web UI user, kept client-side in a signed cookie
+type Session struct {
+	Subject string    `json:"sub"`
+	Email   string    `json:"email,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Role    string    `json:"role,omitempty"` // from the OIDC role claim; empty for none
+	Expires time.Time `json:"exp"`
+}
+
+// Sealer signs values into tamper-proof cookie strings. Values are signed,
+// not encrypted: they must not hold secrets the user may not see.
+type Sealer struct {
+	secret []byte
+}
+
+// NewSealer signs with an HMAC-SHA256 key derived from secret
+func NewSealer(secret string) *Sealer {
+	key := sha256.Sum256([]byte(secret))
+	return &Sealer{secret: key[:]}
+}
+
+// Seal encodes v as base64url JSON followed by its signature
+func (s *Sealer) Seal(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + s.sign(payload), nil
+}
+
+// Open verifies a sealed value and decodes it into v
+func (s *Sealer) Open(sealed string, v interface{}) error {
+	payload, signature, ok := strings.Cut(sealed, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return errors.New("invalid signature")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *Sealer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -4,23 +4,654 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Environment string         `mapstructure:"environment"`
-	LogLevel    string         `mapstructure:"log_level"`
-	Gateway     GatewayConfig  `mapstructure:"gateway"`
-	Services    ServicesConfig `mapstructure:"services"`
-	Google      GoogleConfig   `mapstructure:"google"`
-	LLM         LLMConfig      `mapstructure:"llm"`
+	Environment    string               `mapstructure:"environment"`
+	LogLevel       string               `mapstructure:"log_level"`
+	UpstreamMode   string               `mapstructure:"upstream_mode"` // live, record or replay for external APIs
+	FixturesDir    string               `mapstructure:"fixtures_dir"`
+	Gateway        GatewayConfig        `mapstructure:"gateway"`
+	Services       ServicesConfig       `mapstructure:"services"`
+	Google         GoogleConfig         `mapstructure:"google"`
+	Elasticsearch  ElasticsearchConfig  `mapstructure:"elasticsearch"`
+	VectorStore    VectorStoreConfig    `mapstructure:"vector_store"`
+	Ranking        RankingConfig        `mapstructure:"ranking"`
+	LLM            LLMConfig            `mapstructure:"llm"`
+	Ollama         OllamaConfig         `mapstructure:"ollama"`
+	VLLM           VLLMConfig           `mapstructure:"vllm"`
+	Inference      InferenceConfig      `mapstructure:"inference"`
+	FaultInjection FaultInjectionConfig `mapstructure:"fault_injection"`
+	Redis          RedisConfig          `mapstructure:"redis"`
+	Budget         BudgetConfig         `mapstructure:"budget"`
+	Notifications  NotificationsConfig  `mapstructure:"notifications"`
+	Safety         SafetyConfig         `mapstructure:"safety"`
+	Probes         ProbesConfig         `mapstructure:"probes"`
+	Diagnostics    DiagnosticsConfig    `mapstructure:"diagnostics"`
+	Politeness     PolitenessConfig     `mapstructure:"politeness"`
+	FeatureFlags   FeatureFlagsConfig   `mapstructure:"feature_flags"`
+	Privacy        PrivacyConfig        `mapstructure:"privacy"`
+	Encryption     EncryptionConfig     `mapstructure:"encryption"`
+	Store          StoreConfig          `mapstructure:"store"`
+	Events         EventsConfig         `mapstructure:"events"`
 }
 
 type GatewayConfig struct {
-	Port    int           `mapstructure:"port"`
-	Timeout time.Duration `mapstructure:"timeout"`
+	Port            int                   `mapstructure:"port"`
+	AdminPort       int                   `mapstructure:"admin_port"` // diagnostics, when enabled
+	Timeout         time.Duration         `mapstructure:"timeout"`
+	StreamBuffer    StreamBufferConfig    `mapstructure:"stream_buffer"`
+	Partials        PartialsConfig        `mapstructure:"partial_results"`
+	Idempotency     IdempotencyConfig     `mapstructure:"idempotency"`
+	Coalescing      CoalescingConfig      `mapstructure:"coalescing"`
+	ResponseCache   ResponseCacheConfig   `mapstructure:"response_cache"`
+	Ingestion       IngestionConfig       `mapstructure:"ingestion"`
+	Batch           BatchConfig           `mapstructure:"batch"`
+	Scheduler       SchedulerConfig       `mapstructure:"scheduler"`
+	Exports         ExportsConfig         `mapstructure:"exports"`
+	OpenSearch      OpenSearchConfig      `mapstructure:"opensearch"`
+	HTMLSearch      HTMLSearchConfig      `mapstructure:"html_search"`
+	Docs            DocsConfig            `mapstructure:"docs"`
+	GraphQL         GraphQLConfig         `mapstructure:"graphql"`
+	Suggest         SuggestConfig         `mapstructure:"suggest"`
+	Tenancy         TenancyConfig         `mapstructure:"tenancy"`
+	RBAC            RBACConfig            `mapstructure:"rbac"`
+	OIDC            OIDCConfig            `mapstructure:"oidc"`
+	Login           LoginConfig           `mapstructure:"login"`
+	Sharing         SharingConfig         `mapstructure:"sharing"`
+	Instant         InstantConfig         `mapstructure:"instant_answers"`
+	AnswerProviders AnswerProvidersConfig `mapstructure:"answer_providers"`
+	KnowledgePanel  KnowledgePanelConfig  `mapstructure:"knowledge_panel"`
+	RelatedSearches RelatedSearchesConfig `mapstructure:"related_searches"`
+	Reranking       RerankingConfig       `mapstructure:"reranking"`
+	Snippets        SnippetsConfig        `mapstructure:"snippets"`
+	ModelRouting    ModelRoutingConfig    `mapstructure:"model_routing"`
+	PromptAudit     PromptAuditConfig     `mapstructure:"prompt_audit"`
+	Retention       RetentionConfig       `mapstructure:"retention"`
+	Maintenance     MaintenanceConfig     `mapstructure:"maintenance"`
+	ImageProxy      ImageProxyConfig      `mapstructure:"image_proxy"`
+	Locale          LocaleConfig          `mapstructure:"locale"`
+	Sampling        SamplingConfig        `mapstructure:"sampling"`
+	Shadow          ShadowConfig          `mapstructure:"shadow"`
+	Limits          LimitsConfig          `mapstructure:"limits"`
+	Server          ServerConfig          `mapstructure:"server"`
+
+	// MemoryStoreEntries bounds each in-memory store used without Redis
+	// (idempotency, partial results, exports, ingestion jobs, shares, stream
+	// buffers); the least recently used entries are evicted beyond it
+	MemoryStoreEntries int `mapstructure:"memory_store_entries"`
+
+	// PipelinePlugins are Go plugins (.so) that register custom pipeline stages
+	PipelinePlugins []string `mapstructure:"pipeline_plugins"`
+}
+
+// ResponseCacheConfig keeps completed JSON search responses in memory for
+// repeated queries, with Cache-Control semantics: a response is served fresh
+// for MaxAge, then stale for StaleWhileRevalidate while one background run
+// refreshes it. Tenants may set their own policy; a zero MaxAge disables
+// caching.
+type ResponseCacheConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
+	MaxEntries           int           `mapstructure:"max_entries"`
+	MaxAge               time.Duration `mapstructure:"max_age"`
+	StaleWhileRevalidate time.Duration `mapstructure:"stale_while_revalidate"`
+}
+
+// IngestionConfig controls POST /api/v1/documents, which extracts, chunks and
+// embeds documents into the vector store. Job status is kept for JobTTL.
+// Only the first MaxPages pages of PDF and DOCX documents are extracted, and
+// at most MaxInflatedBytes are decompressed from each.
+type IngestionConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	JobTTL           time.Duration `mapstructure:"job_ttl"`
+	MaxDocumentBytes int           `mapstructure:"max_document_bytes"`
+	MaxBulkDocuments int           `mapstructure:"max_bulk_documents"`
+	MaxPages         int           `mapstructure:"max_pages"`          // 0 = all
+	MaxInflatedBytes int64         `mapstructure:"max_inflated_bytes"` // 0 = unlimited
+}
+
+// BatchConfig controls POST /api/v1/batch, which summarizes a JSONL or CSV
+// file of queries in the background. Jobs and their results are kept for
+// JobTTL. At most MaxRunning jobs run at once, each MaxConcurrent searches
+// at a time; later jobs wait their turn.
+type BatchConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	JobTTL        time.Duration `mapstructure:"job_ttl"`
+	MaxQueries    int           `mapstructure:"max_queries"`
+	MaxFileBytes  int64         `mapstructure:"max_file_bytes"`
+	MaxRunning    int           `mapstructure:"max_running"`
+	MaxConcurrent int           `mapstructure:"max_concurrent"`
+}
+
+// SchedulerConfig controls saved queries that re-run on a schedule (news
+// monitoring). Each run's summary is compared with the previous one and the
+// query's subscribers are notified when it changed substantively.
+type SchedulerConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	TickInterval  time.Duration `mapstructure:"tick_interval"`  // how often due queries are looked for
+	MaxConcurrent int           `mapstructure:"max_concurrent"` // runs in flight at once
+	MaxQueries    int           `mapstructure:"max_queries"`
+	MinInterval   time.Duration `mapstructure:"min_interval"` // shortest schedule accepted
+	RunHistory    int           `mapstructure:"run_history"`  // runs kept per query
+
+	// ChangeThreshold is the share of summary words (0..1) that must differ
+	// from the previous run; a run citing MinNewSources new URLs also counts
+	ChangeThreshold float64 `mapstructure:"change_threshold"`
+	MinNewSources   int     `mapstructure:"min_new_sources"`
+}
+
+// ExportsConfig controls GET /api/v1/search/:task_id/export. Completed
+// searches stay exportable for TTL; PDFs are rendered from the HTML report by
+// a Gotenberg-compatible service at PDFRendererURL (empty disables PDF).
+type ExportsConfig struct {
+	Enabled        bool                `mapstructure:"enabled"`
+	TTL            time.Duration       `mapstructure:"ttl"`
+	PDFRendererURL string              `mapstructure:"pdf_renderer_url"`
+	PDFTimeout     time.Duration       `mapstructure:"pdf_timeout"`
+	Storage        ObjectStorageConfig `mapstructure:"storage"`
+}
+
+// ObjectStorageConfig streams rendered exports to object storage, answering
+// with a signed download URL valid for URLTTL instead of the file. The s3
+// backend speaks the S3 API of AWS, MinIO and GCS (through its HMAC
+// interoperability keys); filesystem keeps the files in Directory and serves
+// them from the gateway under /exports, signed with SigningKey. Empty
+// Backend returns exports in the response.
+type ObjectStorageConfig struct {
+	Backend    string        `mapstructure:"backend"`  // s3, filesystem or empty
+	Endpoint   string        `mapstructure:"endpoint"` // e.g. s3.amazonaws.com, minio:9000, storage.googleapis.com
+	Region     string        `mapstructure:"region"`
+	Bucket     string        `mapstructure:"bucket"`
+	Prefix     string        `mapstructure:"prefix"` // prepended to every object key
+	AccessKey  string        `mapstructure:"access_key"`
+	SecretKey  string        `mapstructure:"secret_key"`
+	Insecure   bool          `mapstructure:"insecure"` // plain HTTP to the endpoint
+	Directory  string        `mapstructure:"directory"`
+	SigningKey string        `mapstructure:"signing_key"`
+	URLTTL     time.Duration `mapstructure:"url_ttl"`
+}
+
+// OpenSearchConfig describes the service to browsers in /opensearch.xml so
+// it can be added as a search engine
+type OpenSearchConfig struct {
+	ShortName   string `mapstructure:"short_name"`
+	Description string `mapstructure:"description"`
+	BaseURL     string `mapstructure:"base_url"` // public URL of the gateway; empty uses the request's host
+}
+
+// HTMLSearchConfig serves /results?q=, a results page rendered by the
+// gateway and streamed as it fills in, for clients without JavaScript, and
+// the HTML fragments under /fragments for HTMX swaps
+type HTMLSearchConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	FragmentMaxAge time.Duration `mapstructure:"fragment_max_age"` // how long browsers may reuse a completed search's fragments
+}
+
+// DocsConfig serves the OpenAPI spec at /api/v1/openapi.json and Swagger UI
+// for it at /docs
+type DocsConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	SwaggerUIURL string `mapstructure:"swagger_ui_url"` // swagger-ui-dist assets; point at a self-hosted copy when offline
+}
+
+// GraphQLConfig serves /api/v1/graphql, which queries searches, search
+// history, quota usage and models with the fields the client selects, and
+// subscribes to streamed searches
+type GraphQLConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MaxDepth    int  `mapstructure:"max_depth"`    // of nested selections; 0 for no limit
+	MaxSearches int  `mapstructure:"max_searches"` // search fields per request, each a pipeline run; 0 for no limit
+}
+
+// SuggestConfig controls GET /api/v1/suggest, which completes a prefix from
+// the tenant's earlier searches and the web provider. A query is only
+// suggested once MinCount searches have used it, so one-off queries are never
+// shown to others, and queries that look like personal data are not recorded.
+type SuggestConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	MaxResults int  `mapstructure:"max_results"`
+	MinCount   int  `mapstructure:"min_count"`
+	MaxEntries int  `mapstructure:"max_entries"` // distinct queries remembered per tenant
+	// MaxQueryWords skips recording longer queries, which are more likely
+	// to identify the person who typed them
+	MaxQueryWords int           `mapstructure:"max_query_words"`
+	Timeout       time.Duration `mapstructure:"timeout"` // for provider suggestions and the safety filter
+}
+
+// TenancyConfig serves the gateway as a shared platform. Each request is
+// resolved to a tenant by its X-API-Key header or its subdomain of
+// BaseDomain; requests matching neither use the default tenant unless
+// RequireTenant is set.
+type TenancyConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	RequireTenant bool   `mapstructure:"require_tenant"`
+	BaseDomain    string `mapstructure:"base_domain"`
+	// AdminToken authorizes the tenant admin API under /admin/tenants;
+	// empty disables it
+	AdminToken string         `mapstructure:"admin_token"`
+	Tenants    []TenantConfig `mapstructure:"tenants"` // created or updated at startup
+}
+
+// TenantConfig is a tenant's identity and policies. Zero values fall back to
+// the service defaults.
+type TenantConfig struct {
+	ID        string   `mapstructure:"id"`
+	Name      string   `mapstructure:"name"`
+	Subdomain string   `mapstructure:"subdomain"`
+	APIKeys   []string `mapstructure:"api_keys"`
+	// Sources limits the search providers: "web", "elasticsearch" or the
+	// vector store backend
+	Sources         []string `mapstructure:"sources"`
+	ForceSafeSearch bool     `mapstructure:"force_safe_search"`
+	BlockedTerms    []string `mapstructure:"blocked_terms"` // rejected in queries and suggestions
+	// BlockedPatterns are regular expressions blocked like BlockedTerms;
+	// AllowedTerms are exempt from the inappropriate-content filters, e.g.
+	// drug names for a medical deployment
+	BlockedPatterns []string `mapstructure:"blocked_patterns"`
+	AllowedTerms    []string `mapstructure:"allowed_terms"`
+	Model           string   `mapstructure:"model"`
+	MaxTokens       int32    `mapstructure:"max_tokens"`
+	NumResults      int      `mapstructure:"num_results"`
+	// Searches allowed per minute and per day; 0 is unlimited
+	RequestsPerMinute int `mapstructure:"requests_per_minute"`
+	RequestsPerDay    int `mapstructure:"requests_per_day"`
+	// Cache replaces gateway.response_cache's policy; nil keeps it
+	Cache *CachePolicyConfig `mapstructure:"cache"`
+}
+
+// CachePolicyConfig is a tenant's response cache policy
+type CachePolicyConfig struct {
+	MaxAge               time.Duration `mapstructure:"max_age"`
+	StaleWhileRevalidate time.Duration `mapstructure:"stale_while_revalidate"`
+}
+
+// RBACConfig protects the /admin endpoints with roles: viewers read,
+// operators also change runtime state such as injected faults, admins also
+// manage tenants and read the audit log. Callers authenticate with one of
+// APIKeys in X-API-Key or with an OIDC token as Authorization: Bearer;
+// tenancy.admin_token always acts as an admin.
+type RBACConfig struct {
+	Enabled      bool            `mapstructure:"enabled"`
+	APIKeys      []RBACKeyConfig `mapstructure:"api_keys"`
+	AuditEntries int             `mapstructure:"audit_entries"` // admin actions kept for GET /admin/audit
+}
+
+// RBACKeyConfig is an admin API key and the role it grants
+type RBACKeyConfig struct {
+	Name string `mapstructure:"name"` // recorded in the audit log
+	Key  string `mapstructure:"key"`
+	Role string `mapstructure:"role"` // viewer, operator or admin
+}
+
+// OIDCConfig trusts tokens issued by an OpenID Connect provider (Google,
+// Azure AD, Keycloak, ...). RoleClaim lists the token's groups or roles;
+// the first of Admins, Operators and Viewers containing one of them sets
+// the caller's role.
+type OIDCConfig struct {
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"` // tokens must be issued for it
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"` // defaults to <request host>/auth/callback
+	Scopes       []string `mapstructure:"scopes"`
+	RoleClaim    string   `mapstructure:"role_claim"`
+	Admins       []string `mapstructure:"admins"`
+	Operators    []string `mapstructure:"operators"`
+	Viewers      []string `mapstructure:"viewers"`
+}
+
+// LoginConfig requires web UI users to log in through the OIDC provider.
+// Sessions live in a cookie signed with SessionSecret; the API keeps
+// accepting API keys for programmatic access.
+type LoginConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	SessionSecret string        `mapstructure:"session_secret"` // shared by all replicas; random per process if empty
+	SessionTTL    time.Duration `mapstructure:"session_ttl"`
+	CookieName    string        `mapstructure:"cookie_name"`
+	SecureCookie  bool          `mapstructure:"secure_cookie"` // also set automatically behind HTTPS
+	// HistoryEntries is how many recent searches are kept per user
+	HistoryEntries int `mapstructure:"history_entries"`
+}
+
+// SharingConfig controls POST /api/v1/search/:task_id/share, which turns a
+// completed search into a read-only page anyone with the link may view.
+// Links are signed with Secret and expire after TTL, at most MaxTTL.
+type SharingConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Secret  string        `mapstructure:"secret"` // shared by all replicas; random per process if empty
+	TTL     time.Duration `mapstructure:"ttl"`
+	MaxTTL  time.Duration `mapstructure:"max_ttl"`
+}
+
+// InstantConfig answers calculations, unit conversions and definitions in
+// the gateway without searching or summarizing. Definitions come from a
+// dictionaryapi.dev-compatible API at DictionaryURL (empty disables them);
+// currencies convert with CurrencyRates, units of each currency per US
+// dollar (empty disables currency conversion).
+type InstantConfig struct {
+	Enabled           bool               `mapstructure:"enabled"`
+	DictionaryURL     string             `mapstructure:"dictionary_url"`
+	DictionaryTimeout time.Duration      `mapstructure:"dictionary_timeout"`
+	CurrencyRates     map[string]float64 `mapstructure:"currency_rates"`
+}
+
+// AnswerProvidersConfig enables the built-in structured answer providers:
+// current weather from OpenWeather (needs an APIKey), stock quotes from the
+// Yahoo Finance chart API and sports results from TheSportsDB. Each is
+// queried only for queries recognized as asking for its data.
+type AnswerProvidersConfig struct {
+	Weather AnswerProviderConfig `mapstructure:"weather"`
+	Stocks  AnswerProviderConfig `mapstructure:"stocks"`
+	Sports  AnswerProviderConfig `mapstructure:"sports"`
+}
+
+// AnswerProviderConfig is a provider's API base URL and, where it needs one, key
+type AnswerProviderConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	URL     string `mapstructure:"url"`
+	APIKey  string `mapstructure:"api_key"`
+}
+
+// KnowledgePanelConfig extracts the entity a query is about (name, type,
+// key facts and an image) from its top MaxResults search results while the
+// summary is generated. It costs an extra model call per search.
+type KnowledgePanelConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	MaxResults int  `mapstructure:"max_results"`
+}
+
+// RelatedSearchesConfig suggests up to Max related searches built from the
+// phrases that stand out in the titles and snippets of the search results
+// (TF-IDF). With Polish the generation model rewords them as natural queries
+// while the summary is generated, an extra model call per search.
+type RelatedSearchesConfig struct {
+	Enabled  bool           `mapstructure:"enabled"`
+	Max      int            `mapstructure:"max"`
+	Polish   bool           `mapstructure:"polish"`
+	Prefetch PrefetchConfig `mapstructure:"prefetch"`
+}
+
+// RerankingConfig reorders search results by their relevance to the query,
+// scored by the inference service (inference.rerank), before they are
+// delivered and summarized. Results scoring under MinScore are dropped, but
+// at least MinResults are kept. It costs an extra model call per search.
+type RerankingConfig struct {
+	Enabled    bool    `mapstructure:"enabled"`
+	MinScore   float64 `mapstructure:"min_score"`
+	MinResults int     `mapstructure:"min_results"`
+}
+
+// SnippetsConfig cleans search result snippets before they go into the
+// summarization prompt: URLs and markup are stripped, and sentences of
+// boilerplate (cookie banners, newsletter prompts) or instructing the model
+// are dropped. Boilerplate and Injection add phrases to the built-in ones.
+type SnippetsConfig struct {
+	Enabled     bool                     `mapstructure:"enabled"`
+	Boilerplate []string                 `mapstructure:"boilerplate"`
+	Injection   []string                 `mapstructure:"injection"`
+	Compression SnippetCompressionConfig `mapstructure:"compression"`
+}
+
+// SnippetCompressionConfig shortens the snippets of the summarization
+// prompt to their sentences most similar to the query, embedded by the
+// inference service (inference.embedding), so more sources fit in the
+// context window. Snippets over MaxWords are cut to about MaxWords.
+type SnippetCompressionConfig struct {
+	Enabled  bool `mapstructure:"enabled"`
+	MaxWords int  `mapstructure:"max_words"`
+}
+
+// ModelRoutingConfig routes summaries that name no model between a small,
+// cheap model and a large one by how demanding they are. A summary goes to
+// LargeModel when its prompt is estimated at over MaxSmallTokens tokens,
+// when it draws on over MaxSmallSources usable sources, or when its query
+// is of one of ComplexQueryTypes (question, comparison, navigational or
+// exploratory); otherwise to SmallModel. A limit of 0 does not route on it.
+// Tenants' and callers' models are kept.
+type ModelRoutingConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	SmallModel        string   `mapstructure:"small_model"`
+	LargeModel        string   `mapstructure:"large_model"`
+	MaxSmallTokens    int      `mapstructure:"max_small_tokens"`
+	MaxSmallSources   int      `mapstructure:"max_small_sources"`
+	ComplexQueryTypes []string `mapstructure:"complex_query_types"`
+}
+
+// PromptAuditConfig keeps the prompt and output of every summarization,
+// keyed by request ID and tenant, for Retention, and serves them to admins
+// from /admin/prompts. Email addresses, phone, card and ID numbers, IP
+// addresses and secrets are redacted before a record is stored;
+// RedactPatterns are regular expressions redacted on top of them.
+type PromptAuditConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Retention      time.Duration `mapstructure:"retention"`
+	RedactPatterns []string      `mapstructure:"redact_patterns"`
+}
+
+// RetentionConfig bounds how long the gateway keeps data about searches and
+// their users. Every JanitorInterval (0 disables the janitor) entries older
+// than History are dropped from users' search histories and the suggestion
+// history, older than AuditLog from the admin audit log, and older than
+// Caches from the response cache; prompt records are kept for
+// prompt_audit.retention. A zero period keeps entries until their store
+// evicts them.
+type RetentionConfig struct {
+	JanitorInterval time.Duration `mapstructure:"janitor_interval"`
+	History         time.Duration `mapstructure:"history"`
+	AuditLog        time.Duration `mapstructure:"audit_log"`
+	Caches          time.Duration `mapstructure:"caches"`
+}
+
+// MaintenanceConfig schedules the gateway's maintenance jobs, such as the
+// retention janitor. Jobs maps a job to a schedule (a cron expression,
+// @daily or @every <duration>) overriding its default, or to "off". With Redis
+// every scheduled run executes on one replica, which gives it at most
+// LockTTL before another may take over its next run.
+type MaintenanceConfig struct {
+	LockTTL time.Duration     `mapstructure:"lock_ttl"`
+	Jobs    map[string]string `mapstructure:"jobs"`
+}
+
+// PrefetchConfig fetches the search results (not summaries) of the first
+// Queries related searches in the background once a summary completes, so
+// following one skips the search service. Results are kept for TTL. To
+// never compete with interactive traffic, prefetching is skipped while more
+// than MaxInFlight searches are in flight, and at most MaxConcurrent
+// prefetches run at once.
+type PrefetchConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Queries       int           `mapstructure:"queries"`
+	MaxEntries    int           `mapstructure:"max_entries"`
+	TTL           time.Duration `mapstructure:"ttl"`
+	MaxConcurrent int           `mapstructure:"max_concurrent"`
+	MaxInFlight   int           `mapstructure:"max_in_flight"`
+}
+
+// LocaleConfig picks the language and country web results are searched
+// for. A request's explicit locale wins; otherwise, with AcceptLanguage,
+// the browser's preferred language is used, and else Default (empty leaves
+// the choice to the provider).
+type LocaleConfig struct {
+	AcceptLanguage bool   `mapstructure:"accept_language"`
+	Default        string `mapstructure:"default"` // BCP 47 tag, e.g. "en-US"
+}
+
+// SamplingConfig bounds the sampling parameters a search request may set
+// for its summary (temperature, top_p, frequency_penalty, presence_penalty
+// and stop sequences). Requests outside the ranges are rejected with 400.
+// Deterministic requests sample greedily with their seed, or else with
+// DeterministicSeed.
+type SamplingConfig struct {
+	Temperature       RangeConfig `mapstructure:"temperature"`
+	TopP              RangeConfig `mapstructure:"top_p"`
+	FrequencyPenalty  RangeConfig `mapstructure:"frequency_penalty"`
+	PresencePenalty   RangeConfig `mapstructure:"presence_penalty"`
+	MaxStop           int         `mapstructure:"max_stop"`        // stop sequences per request; 0 = none allowed
+	MaxStopLength     int         `mapstructure:"max_stop_length"` // bytes per stop sequence
+	DeterministicSeed int64       `mapstructure:"deterministic_seed"`
+}
+
+// ShadowConfig mirrors Percent of live summaries to another LLM
+// orchestrator at Address (empty for the primary one), optionally with a
+// different Model, after the primary summary is delivered. Both outputs and
+// latencies are appended to Output as JSON lines (empty logs them). At most
+// MaxConcurrent shadow requests run at once; the rest are skipped.
+type ShadowConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Percent       float64       `mapstructure:"percent"` // 0 to 100
+	Address       string        `mapstructure:"address"` // host:port
+	Model         string        `mapstructure:"model"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+	MaxConcurrent int           `mapstructure:"max_concurrent"`
+	Output        string        `mapstructure:"output"`
+}
+
+// RangeConfig is an inclusive range of allowed values
+type RangeConfig struct {
+	Min float64 `mapstructure:"min"`
+	Max float64 `mapstructure:"max"`
+}
+
+// ImageProxyConfig serves result thumbnails and knowledge panel images
+// through GET /img/:token so browsers never load third-party URLs. The
+// gateway rewrites image URLs to tokens signed with Secret, so only images
+// it returned can be fetched. Images must be JPEG, PNG, GIF or WebP of at
+// most MaxBytes; their metadata (EXIF, XMP, text chunks) is stripped and
+// up to CacheEntries of them are kept for CacheTTL, which is also what
+// browsers are told to cache them for. Private, loopback and link-local
+// addresses are refused unless AllowPrivate is set.
+type ImageProxyConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Secret       string        `mapstructure:"secret"` // shared by all replicas; random per process if empty
+	MaxBytes     int64         `mapstructure:"max_bytes"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	CacheEntries int           `mapstructure:"cache_entries"`
+	CacheTTL     time.Duration `mapstructure:"cache_ttl"`
+	AllowPrivate bool          `mapstructure:"allow_private"`
+}
+
+// LimitsConfig bounds what clients may send to the gateway. Bodies over
+// MaxBodyBytes (document ingestion has its own limit) and queries over
+// MaxQueryLength characters get a 413; larger num_results are clamped to
+// MaxNumResults. Clients must send their headers within ReadHeaderTimeout
+// and their body within BodyTimeout, or get a 408.
+type LimitsConfig struct {
+	MaxBodyBytes      int64         `mapstructure:"max_body_bytes"`
+	MaxQueryLength    int           `mapstructure:"max_query_length"`
+	MaxNumResults     int           `mapstructure:"max_num_results"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	BodyTimeout       time.Duration `mapstructure:"body_timeout"`
+}
+
+// ServerConfig tunes the gateway's HTTP server. WriteTimeout bounds every
+// response but event streams, which get StreamWriteTimeout (0 for none).
+// The gateway serves HTTPS with the certificate in TLSCertFile or one
+// obtained through Autocert. HTTP/2 is negotiated over TLS when HTTP2 is
+// set; H2C also accepts it in cleartext, for clients inside the cluster.
+type ServerConfig struct {
+	IdleTimeout          time.Duration  `mapstructure:"idle_timeout"`
+	WriteTimeout         time.Duration  `mapstructure:"write_timeout"`
+	StreamWriteTimeout   time.Duration  `mapstructure:"stream_write_timeout"`
+	HTTP2                bool           `mapstructure:"http2"`
+	H2C                  bool           `mapstructure:"h2c"`
+	MaxConcurrentStreams uint32         `mapstructure:"max_concurrent_streams"` // per HTTP/2 connection
+	TLSCertFile          string         `mapstructure:"tls_cert_file"`
+	TLSKeyFile           string         `mapstructure:"tls_key_file"`
+	Autocert             AutocertConfig `mapstructure:"autocert"`
+	// HTTPRedirectPort, when serving HTTPS, listens for plain HTTP and
+	// redirects it to HTTPS, answering ACME HTTP-01 challenges; 0 disables it
+	HTTPRedirectPort int `mapstructure:"http_redirect_port"`
+	// ShutdownTimeout is how long searches in flight may take to finish on
+	// shutdown before their connections are closed
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+// AutocertConfig obtains and renews the gateway's certificate from an ACME
+// CA such as Let's Encrypt for Domains, caching it in CacheDir
+type AutocertConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Domains      []string `mapstructure:"domains"`
+	Email        string   `mapstructure:"email"` // contact for expiry notices
+	CacheDir     string   `mapstructure:"cache_dir"`
+	DirectoryURL string   `mapstructure:"directory_url"` // empty for Let's Encrypt production
+}
+
+// CoalescingConfig controls collapsing identical concurrent searches onto a
+// single pipeline run whose results are fanned out to every waiter
+type CoalescingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// IdempotencyConfig controls Idempotency-Key handling on POST /api/v1/search:
+// responses, JSON or completed event streams, are kept for TTL and replayed
+// to retries with the same key
+type IdempotencyConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	TTL     time.Duration `mapstructure:"ttl"`
+}
+
+// PartialsConfig controls persistence of summaries from streams that died
+// before completing, served by GET /api/v1/search/partial/:id
+type PartialsConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	TTL     time.Duration `mapstructure:"ttl"`
+}
+
+// StreamBufferConfig controls buffering of recent SSE events so a client that
+// reconnects with Last-Event-ID resumes instead of re-running the pipeline.
+// Events are kept in Redis when redis.host is set, in memory otherwise.
+type StreamBufferConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	MaxEvents int           `mapstructure:"max_events"` // per stream
+	TTL       time.Duration `mapstructure:"ttl"`
+}
+
+// NotificationsConfig lists the channels alerts and scheduled digests are
+// delivered to. Each channel subscribes to events (scheduled_digest,
+// safety_block, overload) at or above its minimum severity.
+type NotificationsConfig struct {
+	SMTP     SMTPConfig            `mapstructure:"smtp"`
+	Channels []NotificationChannel `mapstructure:"channels"`
+	Overload OverloadAlertConfig   `mapstructure:"overload"`
+}
+
+// SMTPConfig is the mail server email channels and saved-query recipients
+// are sent through; an empty host disables email
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// NotificationChannel is one delivery target
+type NotificationChannel struct {
+	Name        string        `mapstructure:"name"`
+	Type        string        `mapstructure:"type"` // slack, email or webhook
+	URL         string        `mapstructure:"url"`  // Slack incoming webhook or webhook endpoint
+	To          []string      `mapstructure:"to"`   // email recipients
+	Events      []string      `mapstructure:"events"`
+	MinSeverity string        `mapstructure:"min_severity"` // info, warning or critical
+	Cooldown    time.Duration `mapstructure:"cooldown"`     // minimum gap between repeats of the same alert
+}
+
+// OverloadAlertConfig raises an overload alert once the LLM orchestrator has
+// rejected Rejections requests at its concurrency limit within Window
+type OverloadAlertConfig struct {
+	Window     time.Duration `mapstructure:"window"`
+	Rejections int           `mapstructure:"rejections"`
+}
+
+// RedisConfig locates the shared Redis instance; an empty host disables it
+type RedisConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
 }
 
 type ServicesConfig struct {
@@ -35,17 +666,542 @@ type ServiceConfig struct {
 	Host    string        `mapstructure:"host"`
 	Port    int           `mapstructure:"port"`
 	Timeout time.Duration `mapstructure:"timeout"`
+	// ProbePort serves /healthz, /readyz and /startupz over HTTP next to the
+	// gRPC port, for Kubernetes probes; 0 disables it
+	ProbePort int `mapstructure:"probe_port"`
+	// AdminPort serves pprof and the other diagnostics when enabled
+	AdminPort int `mapstructure:"admin_port"`
 }
 
 type GoogleConfig struct {
-	APIKey string `mapstructure:"api_key"`
-	CX     string `mapstructure:"cx"`
+	APIKey  string `mapstructure:"api_key"`
+	CX      string `mapstructure:"cx"`
+	BaseURL string `mapstructure:"base_url"` // Custom Search endpoint; overridden for fakes and replay
+	// SuggestURL is the autocomplete endpoint queried for provider
+	// suggestions; empty keeps typed prefixes from leaving the service
+	SuggestURL string `mapstructure:"suggest_url"`
+	// PaywallDomains are sites, with their subdomains, whose results are
+	// treated as previews of paywalled articles. Results elsewhere are
+	// flagged by their meta tags or a sign-in prompt in the snippet.
+	PaywallDomains []string `mapstructure:"paywall_domains"`
+	// RestrictLanguage sends lr with a request's locale, keeping results to
+	// its language; gl and hl are always sent
+	RestrictLanguage bool `mapstructure:"restrict_language"`
 }
 
+// SafetyConfig holds the safety service's configurable filters
+type SafetyConfig struct {
+	// SuggestionDenylist holds phrases, matched case-insensitively on word
+	// boundaries, that are never offered as query suggestions
+	SuggestionDenylist []string `mapstructure:"suggestion_denylist"`
+	// PolicyCacheEntries bounds the tenant and API key policies kept compiled
+	PolicyCacheEntries int `mapstructure:"policy_cache_entries"`
+	// Toxicity grades what SanitizeOutput does with inappropriate content
+	Toxicity ToxicityConfig `mapstructure:"toxicity"`
+	// BatchWorkers bounds the texts of a batch RPC checked at once
+	BatchWorkers int `mapstructure:"batch_workers"`
+	// MaxBatchSize rejects larger batches; 0 allows any size
+	MaxBatchSize int `mapstructure:"max_batch_size"`
+}
+
+// ToxicityConfig sets the scores, from 0 to 1, at which AI output is
+// annotated, has the matching categories rewritten, or is withheld. A
+// threshold of 0 disables its action.
+type ToxicityConfig struct {
+	AnnotateThreshold float64 `mapstructure:"annotate_threshold"`
+	RewriteThreshold  float64 `mapstructure:"rewrite_threshold"`
+	BlockThreshold    float64 `mapstructure:"block_threshold"`
+	// Weights override how much a single match adds to a category's score
+	Weights map[string]float64 `mapstructure:"weights"`
+}
+
+// ElasticsearchConfig connects an Elasticsearch or OpenSearch index as an
+// internal knowledge source whose hits are blended with web results
+type ElasticsearchConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	URL      string        `mapstructure:"url"`
+	Index    string        `mapstructure:"index"`
+	Username string        `mapstructure:"username"`
+	Password string        `mapstructure:"password"`
+	APIKey   string        `mapstructure:"api_key"` // sent as "Authorization: ApiKey"; takes precedence over basic auth
+	Timeout  time.Duration `mapstructure:"timeout"`
+
+	// Document fields mapped onto search results; title and content are queried
+	TitleField   string `mapstructure:"title_field"`
+	URLField     string `mapstructure:"url_field"`
+	ContentField string `mapstructure:"content_field"`
+	FragmentSize int    `mapstructure:"fragment_size"` // highlighted snippet length in characters
+
+	// Weight is the index's share of the blended results, between 0 and 1;
+	// web search gets the rest and 1 serves index hits only
+	Weight float64 `mapstructure:"weight"`
+}
+
+// VectorStoreConfig connects a vector database as an internal knowledge
+// source: the query is embedded through the inference service's Embed RPC and
+// the nearest documents are blended with web results
+type VectorStoreConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Backend string        `mapstructure:"backend"` // qdrant, weaviate or pgvector
+	URL     string        `mapstructure:"url"`     // Qdrant or Weaviate HTTP endpoint
+	APIKey  string        `mapstructure:"api_key"`
+	DSN     string        `mapstructure:"dsn"`    // pgvector connection string
+	Driver  string        `mapstructure:"driver"` // database/sql driver registered for pgvector
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Collection is the Qdrant collection, Weaviate class or pgvector table
+	Collection     string  `mapstructure:"collection"`
+	TopK           int     `mapstructure:"top_k"`
+	ScoreThreshold float64 `mapstructure:"score_threshold"` // minimum cosine similarity; 0 keeps every hit
+	EmbeddingModel string  `mapstructure:"embedding_model"` // empty uses the inference default
+
+	// Payload fields (pgvector columns) mapped onto search results
+	TitleField     string `mapstructure:"title_field"`
+	URLField       string `mapstructure:"url_field"`
+	ContentField   string `mapstructure:"content_field"`
+	EmbeddingField string `mapstructure:"embedding_field"` // pgvector only
+	SnippetLength  int    `mapstructure:"snippet_length"`
+	HashField      string `mapstructure:"hash_field"` // content hash of the source document, for dedup
+
+	// Ingested documents are split into chunks of about ChunkSize characters,
+	// each overlapping the previous one by ChunkOverlap
+	ChunkSize    int `mapstructure:"chunk_size"`
+	ChunkOverlap int `mapstructure:"chunk_overlap"`
+
+	// Weight is the store's share of the blended results, as for Elasticsearch
+	Weight float64 `mapstructure:"weight"`
+}
+
+// RankingConfig controls how web and internal results are fused. Scores are
+// normalized per source to 0..1 either way; "interleave" alternates sources
+// in proportion to their weights, "score" sorts by weight times score.
+type RankingConfig struct {
+	Method string `mapstructure:"method"` // interleave or score
+}
 
 type LLMConfig struct {
 	MaxWorkers   int `mapstructure:"max_workers"`
 	MaxQueueSize int `mapstructure:"max_queue_size"`
+
+	// Streaming detokenization: flush after this many tokens or once the oldest
+	// buffered token has waited this long
+	DetokenizeBatchSize     int           `mapstructure:"detokenize_batch_size"`
+	DetokenizeFlushInterval time.Duration `mapstructure:"detokenize_flush_interval"`
+
+	// Request bookkeeping: finished requests stay queryable for RequestTTL;
+	// streams and in-flight requests older than StreamTTL are reaped as leaked
+	RequestTTL   time.Duration `mapstructure:"request_ttl"`
+	StreamTTL    time.Duration `mapstructure:"stream_ttl"`
+	ReapInterval time.Duration `mapstructure:"reap_interval"`
+	// MaxFinishedRequests bounds the finished requests kept for GetStatus;
+	// the oldest are dropped beyond it even before RequestTTL
+	MaxFinishedRequests int `mapstructure:"max_finished_requests"`
+
+	// ShutdownTimeout is how long streams in flight may take to finish on
+	// shutdown before they are cancelled; keep it at least the gateway's
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	SummaryCache SummaryCacheConfig `mapstructure:"summary_cache"`
+	StreamGuard  StreamGuardConfig  `mapstructure:"stream_guard"`
+
+	StreamRecovery StreamRecoveryConfig `mapstructure:"stream_recovery"`
+	StreamPool     StreamPoolConfig     `mapstructure:"stream_pool"`
+
+	// ChatTemplates format the prompts of instruction-tuned models; models
+	// without one get the tokenizer's formatting for their family
+	ChatTemplates []ChatTemplateConfig `mapstructure:"chat_templates"`
+
+	Timeouts LLMTimeoutsConfig `mapstructure:"timeouts"`
+}
+
+// SummaryCacheConfig keeps the orchestrator's summaries keyed by a hash of
+// what produced them: the model, the prompt template version, the packed
+// prompt and the generation parameters. Queries the gateway caches apart,
+// such as paraphrases reranked to the same sources, share a summary.
+type SummaryCacheConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	MaxEntries int           `mapstructure:"max_entries"`
+	TTL        time.Duration `mapstructure:"ttl"`
+}
+
+// StreamGuardConfig ends summary streams that degenerate instead of
+// streaming until the timeout: once the last MaxRepeats runs of a phrase of
+// up to MaxNGram words repeat back to back, or the summary passes MaxWords,
+// generation stops and the stream ends flagged as truncated.
+type StreamGuardConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	MaxNGram   int  `mapstructure:"max_ngram"`
+	MaxRepeats int  `mapstructure:"max_repeats"`
+	MaxWords   int  `mapstructure:"max_words"` // 0 for no limit
+}
+
+// StreamRecoveryConfig continues summary streams whose vLLM stream breaks
+// off partway: the orchestrator asks the inference service's Ollama backend
+// to continue from the text streamed so far, which costs an LLM call of the
+// request's budget. Without it the client gets the partial summary and an
+// error.
+type StreamRecoveryConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// StreamPoolConfig keeps Size inference sessions (SummarizeSession streams)
+// open and ready for summary streams to claim, so a summary does not wait
+// for its stream to be set up. Each CheckInterval, sessions that broke or
+// have been idle over MaxIdle are closed and the pool is refilled. When
+// sessions fail to open, refilling waits a CheckInterval, then twice as long
+// after each further failure, up to MaxBackoff. Against an inference
+// service without sessions, streams are opened per request.
+type StreamPoolConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Size          int           `mapstructure:"size"`
+	MaxIdle       time.Duration `mapstructure:"max_idle"`
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	MaxBackoff    time.Duration `mapstructure:"max_backoff"`
+}
+
+// ChatTemplateConfig formats the prompts of Model with a chat template.
+// Template is a built-in (chatml, llama3, phi3) or the Jinja chat_template
+// of the model's tokenizer_config.json, which the tokenizer renders.
+// BOSToken and EOSToken fill its bos_token and eos_token, overriding a
+// built-in's.
+type ChatTemplateConfig struct {
+	Model    string `mapstructure:"model"`
+	Template string `mapstructure:"template"`
+	BOSToken string `mapstructure:"bos_token"`
+	EOSToken string `mapstructure:"eos_token"`
+}
+
+// LLMTimeoutsConfig bounds an orchestrator request and each of its stages.
+// A request has Request to finish, less when its caller's deadline is
+// sooner; each stage gets its own timeout or what is left of the request's
+// time, whichever ends first, and 0 leaves a stage only the latter.
+// Inference bounds a whole generation, streamed or not. A stage that runs
+// out of time fails the request with <stage>_timeout as its error code, or
+// request_timeout when the request's own deadline passed; a detokenization
+// that times out falls back to the inference text instead.
+type LLMTimeoutsConfig struct {
+	Request    time.Duration `mapstructure:"request"`
+	Tokenize   time.Duration `mapstructure:"tokenize"`
+	Inference  time.Duration `mapstructure:"inference"`
+	Detokenize time.Duration `mapstructure:"detokenize"`
+}
+
+// OllamaConfig configures the Ollama backend used for text-only inference
+type OllamaConfig struct {
+	Host        string        `mapstructure:"host"`
+	Port        int           `mapstructure:"port"`
+	Model       string        `mapstructure:"model"`
+	Temperature float64       `mapstructure:"temperature"`
+	MaxTokens   int           `mapstructure:"max_tokens"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	KeepAlive   time.Duration `mapstructure:"keep_alive"` // how long Ollama keeps the model loaded
+}
+
+// VLLMConfig configures the vLLM backend used for token-native inference
+type VLLMConfig struct {
+	Host    string        `mapstructure:"host"`
+	Port    int           `mapstructure:"port"`
+	Model   string        `mapstructure:"model"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// InferenceConfig holds inference service behaviour independent of the backend
+type InferenceConfig struct {
+	Backend     BackendConfig     `mapstructure:"backend"`
+	Triton      TritonConfig      `mapstructure:"triton"`
+	ONNX        ONNXConfig        `mapstructure:"onnx"`
+	LlamaCpp    LlamaCppConfig    `mapstructure:"llamacpp"`
+	Warmup      WarmupConfig      `mapstructure:"warmup"`
+	PrefixCache PrefixCacheConfig `mapstructure:"prefix_cache"`
+	Embedding   EmbeddingConfig   `mapstructure:"embedding"`
+	Entities    EntitiesConfig    `mapstructure:"entities"`
+	Related     RelatedConfig     `mapstructure:"related_searches"`
+	Rerank      RerankConfig      `mapstructure:"rerank"`
+	ModelSwitch ModelSwitchConfig `mapstructure:"model_switch"`
+
+	TokenizerPins []TokenizerPinConfig `mapstructure:"tokenizer_pins"`
+}
+
+// TokenizerPinConfig pins a model to the vocabulary it was trained with,
+// the tokenizer_version the tokenizer reports for it (GetVocabularyInfo).
+// Token-prompted summaries of the model carrying another version are
+// refused, so a tokenizer and model upgraded out of step fail loudly
+// instead of summarizing token IDs that now stand for other words.
+type TokenizerPinConfig struct {
+	Model   string `mapstructure:"model"`
+	Version string `mapstructure:"version"`
+}
+
+// BackendConfig selects the engine of token-prompted summaries, vLLM, a
+// BART deployment on Triton Inference Server, a small model run in
+// process with ONNX Runtime (onnx-local) or a GGUF model on a llama.cpp
+// server (llamacpp), and how it is reached. vLLM
+// is called over its OpenAI-compatible HTTP API at vllm.host:vllm.port
+// (http), or the VLLMGenerationService of a gRPC frontend such as vLLM's or
+// Triton's at GRPCAddress (grpc); embeddings and vLLM reranks keep using
+// the HTTP API. Triton is called at inference.triton's url or grpc_address.
+type BackendConfig struct {
+	Engine      string `mapstructure:"engine"`       // vllm, triton, onnx-local or llamacpp
+	Transport   string `mapstructure:"transport"`    // http or grpc
+	GRPCAddress string `mapstructure:"grpc_address"` // vLLM's, host:port; empty uses vllm.host and vllm.port
+	TLS         bool   `mapstructure:"tls"`          // grpc: verify the server with the system roots
+}
+
+// TritonConfig locates a summarization model (an ONNX or TensorRT BART with
+// its generation loop, e.g. an ensemble) on Triton Inference Server. The
+// prompt's token IDs are sent as InputName, with an all-ones attention mask
+// as MaskName and the summary length as MaxLengthName when they are set.
+// The first sequence of OutputName is the summary's token IDs: the decoder
+// start and BOS tokens are dropped, as are pads, and it ends at EOS.
+type TritonConfig struct {
+	URL           string        `mapstructure:"url"`          // HTTP endpoint, e.g. http://localhost:8000
+	GRPCAddress   string        `mapstructure:"grpc_address"` // host:port, with inference.backend.transport grpc
+	Model         string        `mapstructure:"model"`
+	ModelVersion  string        `mapstructure:"model_version"` // empty lets the server's version policy choose
+	InputName     string        `mapstructure:"input_name"`
+	MaskName      string        `mapstructure:"attention_mask_name"` // empty sends no mask
+	MaxLengthName string        `mapstructure:"max_length_name"`     // empty leaves the length to the model
+	OutputName    string        `mapstructure:"output_name"`
+	Datatype      string        `mapstructure:"datatype"` // of the ID tensors: INT64 or INT32
+	BOSTokenID    int32         `mapstructure:"bos_token_id"`
+	PadTokenID    int32         `mapstructure:"pad_token_id"`
+	EOSTokenID    int32         `mapstructure:"eos_token_id"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+}
+
+// ONNXConfig locates a small summarization model run in process with ONNX
+// Runtime, for deployments without an inference server. The model is an
+// ONNX export with its generation loop built in (onnxruntime's BeamSearch
+// operator, as convert_generation produces): it takes input_ids and the
+// search parameters and outputs the generated sequences, whose first is
+// mapped to the summary's token IDs as with Triton. Only binaries built
+// with the onnx tag and cgo include the runtime.
+type ONNXConfig struct {
+	LibraryPath    string  `mapstructure:"library_path"` // onnxruntime shared library; empty uses the platform's default name
+	ModelPath      string  `mapstructure:"model_path"`
+	Model          string  `mapstructure:"model"` // reported as the summaries' model
+	MinLength      int32   `mapstructure:"min_length"`
+	NumBeams       int32   `mapstructure:"num_beams"`
+	LengthPenalty  float32 `mapstructure:"length_penalty"`
+	IntraOpThreads int     `mapstructure:"intra_op_threads"` // 0 lets ONNX Runtime choose
+	Concurrency    int     `mapstructure:"concurrency"`      // generations run at once; more wait
+	BOSTokenID     int32   `mapstructure:"bos_token_id"`
+	PadTokenID     int32   `mapstructure:"pad_token_id"`
+	EOSTokenID     int32   `mapstructure:"eos_token_id"`
+}
+
+// LlamaCppConfig locates a llama.cpp server (llama-server) for a quantized
+// GGUF model, which takes vLLM's place: it is sent the same token ID
+// prompts over its OpenAI-compatible API, so the tokenizer must use the
+// model's vocabulary. With ServerPath set the inference service runs the
+// server itself on URL's host and port, loading ModelPath with the
+// context length, threads and GPU layers configured; otherwise it uses
+// one already running at URL.
+type LlamaCppConfig struct {
+	URL           string        `mapstructure:"url"`            // e.g. http://127.0.0.1:8080
+	Model         string        `mapstructure:"model"`          // alias the server reports and summaries name
+	ServerPath    string        `mapstructure:"server_path"`    // llama-server binary; empty uses a running server
+	ModelPath     string        `mapstructure:"model_path"`     // the GGUF file
+	ContextLength int           `mapstructure:"context_length"` // 0 uses the model's
+	Threads       int           `mapstructure:"threads"`        // 0 lets llama.cpp choose
+	GPULayers     int           `mapstructure:"gpu_layers"`     // layers offloaded to the GPU; 0 runs on the CPU
+	Timeout       time.Duration `mapstructure:"timeout"`
+}
+
+// EmbeddingConfig selects the model behind the Embed RPC
+type EmbeddingConfig struct {
+	Backend string `mapstructure:"backend"` // ollama or vllm
+	Model   string `mapstructure:"model"`
+}
+
+// EntitiesConfig selects the model behind the ExtractEntities RPC: a
+// generation model prompted for JSON (vllm or ollama; an empty Model uses
+// the backend's current one) or a Hugging Face token classification model
+// served at NERURL (ner), which finds names but no attributes
+type EntitiesConfig struct {
+	Backend   string `mapstructure:"backend"` // vllm, ollama or ner
+	Model     string `mapstructure:"model"`
+	NERURL    string `mapstructure:"ner_url"`
+	MaxTokens int    `mapstructure:"max_tokens"` // of the generated JSON
+}
+
+// RelatedConfig selects the generation model behind the
+// PolishRelatedSearches RPC (vllm or ollama; an empty Model uses the
+// backend's current one)
+type RelatedConfig struct {
+	Backend   string `mapstructure:"backend"` // vllm or ollama
+	Model     string `mapstructure:"model"`
+	MaxTokens int    `mapstructure:"max_tokens"` // of all the rewritten searches
+}
+
+// RerankConfig selects the model behind the Rerank RPC: a cross-encoder
+// served at URL with the rerank API of vLLM, Infinity or Jina
+// (cross_encoder), or a generation model asked to grade each result (vllm or
+// ollama). An empty Model uses the one the server or backend serves.
+type RerankConfig struct {
+	Backend   string `mapstructure:"backend"` // cross_encoder, vllm or ollama
+	Model     string `mapstructure:"model"`
+	URL       string `mapstructure:"url"`
+	MaxTokens int    `mapstructure:"max_tokens"` // of the generated grades
+}
+
+// ModelSwitchConfig governs the SwitchModel RPC. The target is sent the
+// warm-up prompt and then CanaryPrompt, whose answer must contain
+// CanaryExpect (case-insensitively; empty accepts any answer), each within
+// Timeout. For Window after the cutover, once MinRequests have been served
+// the previous target is restored if the error rate exceeds the previous
+// target's by more than MaxErrorRateIncrease.
+type ModelSwitchConfig struct {
+	CanaryPrompt         string        `mapstructure:"canary_prompt"`
+	CanaryExpect         string        `mapstructure:"canary_expect"`
+	CanaryMaxTokens      int           `mapstructure:"canary_max_tokens"`
+	Timeout              time.Duration `mapstructure:"timeout"`
+	Window               time.Duration `mapstructure:"window"`
+	MinRequests          int           `mapstructure:"min_requests"`
+	MaxErrorRateIncrease float64       `mapstructure:"max_error_rate_increase"` // 0 to 1
+}
+
+// WarmupConfig controls the model warm-up run at startup and before model switches
+type WarmupConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Prompt    string        `mapstructure:"prompt"`
+	MaxTokens int           `mapstructure:"max_tokens"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// PrefixCacheConfig controls the shared summarization instruction prefix. Every
+// prompt carries the instruction; enabled puts it first, as the system part,
+// and keeps it byte-identical across requests so vLLM's prefix caching (and
+// Ollama's KV cache reuse) skip re-processing it. Disabled, it starts the
+// user part instead.
+type PrefixCacheConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Instruction string `mapstructure:"instruction"`
+	MaxEntries  int    `mapstructure:"max_entries"` // prefixes tracked for hit-rate metrics
+}
+
+// FaultInjectionConfig enables the chaos hooks (admin endpoint, X-Fault headers
+// and gRPC interceptors). They are never active in production.
+type FaultInjectionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// ProbesConfig tunes the liveness, readiness and startup endpoints
+type ProbesConfig struct {
+	// Timeout bounds each readiness check, such as a downstream HealthCheck
+	// or a Redis ping
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// DiagnosticsConfig serves pprof, expvar, profile snapshots and a runtime
+// summary on each service's admin port. Keep the ports off public networks.
+type DiagnosticsConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Token       string `mapstructure:"token"`        // bearer token every request must carry
+	SnapshotDir string `mapstructure:"snapshot_dir"` // where POST /debug/snapshot writes profiles
+}
+
+// PolitenessConfig governs fetching pages from the sites search results point
+// to. Requests carry UserAgent, whose product token is what robots.txt
+// groups are matched against. With RespectRobots each site's robots.txt is
+// fetched (within RobotsTimeout) and kept for RobotsTTL, for up to
+// CacheEntries hosts. Requests to one host start at least PerHostInterval
+// apart, or the site's Crawl-delay, and a 429 or 503 keeps the host idle for
+// its Retry-After; neither wait exceeds MaxHostDelay. At most MaxConcurrency
+// requests are in flight across all hosts.
+type PolitenessConfig struct {
+	UserAgent       string        `mapstructure:"user_agent"`
+	RespectRobots   bool          `mapstructure:"respect_robots"`
+	RobotsTimeout   time.Duration `mapstructure:"robots_timeout"`
+	RobotsTTL       time.Duration `mapstructure:"robots_ttl"`
+	CacheEntries    int           `mapstructure:"cache_entries"`
+	PerHostInterval time.Duration `mapstructure:"per_host_interval"`
+	MaxHostDelay    time.Duration `mapstructure:"max_host_delay"`
+	MaxConcurrency  int           `mapstructure:"max_concurrency"` // 0 = unlimited
+}
+
+// FeatureFlagsConfig dark-launches features: each flag turns its feature on
+// for Percent of traffic and always for its Tenants and APIKeys. Operators
+// override flags at runtime through /admin/flags; overrides are kept in
+// Redis, when configured, and each service reloads them every
+// RefreshInterval. Features without a flag are on.
+type FeatureFlagsConfig struct {
+	RefreshInterval time.Duration       `mapstructure:"refresh_interval"`
+	Flags           []FeatureFlagConfig `mapstructure:"flags"`
+}
+
+// FeatureFlagConfig is one flag, named after the feature it controls: a
+// built-in one (knowledge_panel, polish_related, prefetch, prefix_cache,
+// rerank) or a custom pipeline stage
+type FeatureFlagConfig struct {
+	Name    string   `mapstructure:"name"`
+	Enabled bool     `mapstructure:"enabled"` // false turns the feature off for everyone
+	Percent float64  `mapstructure:"percent"` // 0 to 100
+	Tenants []string `mapstructure:"tenants"`
+	APIKeys []string `mapstructure:"api_keys"`
+}
+
+// PrivacyConfig anonymizes client IPs before they are logged, stored in the
+// admin audit log or passed on to other services: "truncate" keeps the
+// IPv4Prefix or IPv6Prefix network, "hash" replaces each IP with a hash
+// keyed by HashKey, "off" keeps IPs verbatim
+type PrivacyConfig struct {
+	IPMode     string `mapstructure:"ip_mode"` // off, truncate or hash
+	IPv4Prefix int    `mapstructure:"ipv4_prefix"`
+	IPv6Prefix int    `mapstructure:"ipv6_prefix"`
+	HashKey    string `mapstructure:"hash_key"` // random per process when empty
+}
+
+// EncryptionConfig encrypts what is kept at rest in Redis, the users' search
+// histories, with AES-256-GCM envelopes: each value has a data key of its
+// own, wrapped by a deployment key. Keys[0] wraps new values; the others
+// only open values wrapped before a rotation. No keys keeps plaintext.
+type EncryptionConfig struct {
+	Keys []EncryptionKeyConfig `mapstructure:"keys"`
+}
+
+// EncryptionKeyConfig is a deployment key and the ID stored with the values
+// it wraps
+type EncryptionKeyConfig struct {
+	ID  string `mapstructure:"id"`
+	Key string `mapstructure:"key"` // 32 bytes, base64 encoded
+}
+
+// StoreConfig keeps the subsystems listed in Subsystems (audit_log, usage,
+// saved_queries and tenants) in Postgres instead of Redis or memory. The
+// memory backend stands in for Postgres in tests.
+type StoreConfig struct {
+	Backend      string   `mapstructure:"backend"` // postgres or memory; empty disables the store
+	DSN          string   `mapstructure:"dsn"`
+	Driver       string   `mapstructure:"driver"` // database/sql driver, pgx by default
+	MaxOpenConns int      `mapstructure:"max_open_conns"`
+	Migrate      bool     `mapstructure:"migrate"` // apply pending migrations at startup
+	Subsystems   []string `mapstructure:"subsystems"`
+}
+
+// EventsConfig publishes analytics events (search.completed,
+// saved_query.run, admin.action) through the store's outbox: each event is
+// written in the transaction of the change it reports, and a relay
+// publishes the outbox to the bus every RelayInterval. It needs a store
+// backend.
+type EventsConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Bus           string        `mapstructure:"bus"`     // redis (a stream) or webhook
+	Stream        string        `mapstructure:"stream"`  // Redis stream key
+	MaxLen        int64         `mapstructure:"max_len"` // approximate cap of the Redis stream; 0 = unbounded
+	URL           string        `mapstructure:"url"`     // webhook endpoint
+	Timeout       time.Duration `mapstructure:"timeout"`
+	RelayInterval time.Duration `mapstructure:"relay_interval"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	Retention     time.Duration `mapstructure:"retention"` // how long published events stay in the outbox
+}
+
+// BudgetConfig caps the cost of a single search request. Zero means unlimited.
+type BudgetConfig struct {
+	MaxInputTokens  int32 `mapstructure:"max_input_tokens"`  // prompt tokens sent to inference
+	MaxOutputTokens int32 `mapstructure:"max_output_tokens"` // tokens the model may generate
+	MaxFetchedPages int   `mapstructure:"max_fetched_pages"` // search results fetched
+	MaxLLMCalls     int32 `mapstructure:"max_llm_calls"`     // inference calls, continuations included
 }
 
 func LoadConfig() (*Config, error) {
@@ -79,7 +1235,6 @@ func LoadConfig() (*Config, error) {
 	return &config, nil
 }
 
-
 // GetInferenceAddress returns the inference service address
 func (c *Config) GetInferenceAddress() string {
 	return fmt.Sprintf("%s:%d", c.Services.Inference.Host, c.Services.Inference.Port)
@@ -95,18 +1250,257 @@ func (c *Config) GetLLMAddress() string {
 	return fmt.Sprintf("%s:%d", c.Services.LLM.Host, c.Services.LLM.Port)
 }
 
+// GetOllamaURL returns the base URL of the Ollama HTTP API
+func (c *Config) GetOllamaURL() string {
+	return fmt.Sprintf("http://%s:%d", c.Ollama.Host, c.Ollama.Port)
+}
+
+// GetVLLMURL returns the base URL of the vLLM OpenAI-compatible API
+func (c *Config) GetVLLMURL() string {
+	return fmt.Sprintf("http://%s:%d", c.VLLM.Host, c.VLLM.Port)
+}
+
+// GetVLLMGRPCAddress returns the address of vLLM's gRPC frontend
+func (c *Config) GetVLLMGRPCAddress() string {
+	if c.Inference.Backend.GRPCAddress != "" {
+		return c.Inference.Backend.GRPCAddress
+	}
+	return fmt.Sprintf("%s:%d", c.VLLM.Host, c.VLLM.Port)
+}
+
+// GetRedisAddress returns the Redis address, or "" when Redis is not configured
+func (c *Config) GetRedisAddress() string {
+	if c.Redis.Host == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.Redis.Host, c.Redis.Port)
+}
+
+// FaultInjectionEnabled reports whether fault injection may be used; it is
+// always off in production regardless of configuration
+func (c *Config) FaultInjectionEnabled() bool {
+	return c.FaultInjection.Enabled && c.Environment != "production"
+}
+
+// DefaultSummarizationInstruction is the static prompt prefix shared by every
+// summarization request
+const DefaultSummarizationInstruction = "Summarize the following search results concisely. The summary should be informative and capture the key points.\n\n"
+
 func setDefaults() {
 	// Environment
 	viper.SetDefault("environment", "development")
 	viper.SetDefault("log_level", "info")
+	viper.SetDefault("upstream_mode", "live")
+	viper.SetDefault("fixtures_dir", "fixtures")
+	viper.SetDefault("fault_injection.enabled", false)
+	viper.SetDefault("probes.timeout", "2s")
+	viper.SetDefault("diagnostics.enabled", false)
+	viper.SetDefault("diagnostics.snapshot_dir", "diagnostics")
+	viper.SetDefault("politeness.user_agent", "AISearchBot/1.0")
+	viper.SetDefault("politeness.respect_robots", true)
+	viper.SetDefault("politeness.robots_timeout", "5s")
+	viper.SetDefault("politeness.robots_ttl", "24h")
+	viper.SetDefault("politeness.cache_entries", 10000)
+	viper.SetDefault("politeness.per_host_interval", "1s")
+	viper.SetDefault("politeness.max_host_delay", "30s")
+	viper.SetDefault("politeness.max_concurrency", 16)
 
 	// Gateway
 	viper.SetDefault("gateway.port", 8080)
+	viper.SetDefault("gateway.admin_port", 6080)
+	viper.SetDefault("gateway.memory_store_entries", 10000)
 	viper.SetDefault("gateway.timeout", "30s")
+	viper.SetDefault("gateway.stream_buffer.enabled", true)
+	viper.SetDefault("gateway.stream_buffer.max_events", 1000)
+	viper.SetDefault("gateway.stream_buffer.ttl", "5m")
+	viper.SetDefault("gateway.partial_results.enabled", true)
+	viper.SetDefault("gateway.partial_results.ttl", "1h")
+	viper.SetDefault("gateway.idempotency.enabled", true)
+	viper.SetDefault("gateway.idempotency.ttl", "10m")
+	viper.SetDefault("gateway.coalescing.enabled", true)
+	viper.SetDefault("gateway.response_cache.enabled", false)
+	viper.SetDefault("gateway.response_cache.max_entries", 1000)
+	viper.SetDefault("gateway.response_cache.max_age", "1m")
+	viper.SetDefault("gateway.response_cache.stale_while_revalidate", "5m")
+	viper.SetDefault("gateway.ingestion.enabled", true)
+	viper.SetDefault("gateway.ingestion.job_ttl", "24h")
+	viper.SetDefault("gateway.ingestion.max_document_bytes", 10<<20)
+	viper.SetDefault("gateway.ingestion.max_bulk_documents", 100)
+	viper.SetDefault("gateway.ingestion.max_pages", 200)
+	viper.SetDefault("gateway.ingestion.max_inflated_bytes", 50<<20)
+	viper.SetDefault("gateway.batch.enabled", true)
+	viper.SetDefault("gateway.batch.job_ttl", "24h")
+	viper.SetDefault("gateway.batch.max_queries", 1000)
+	viper.SetDefault("gateway.batch.max_file_bytes", 1<<20)
+	viper.SetDefault("gateway.batch.max_running", 2)
+	viper.SetDefault("gateway.batch.max_concurrent", 4)
+	viper.SetDefault("gateway.scheduler.enabled", true)
+	viper.SetDefault("gateway.scheduler.tick_interval", "30s")
+	viper.SetDefault("gateway.scheduler.max_concurrent", 2)
+	viper.SetDefault("gateway.scheduler.max_queries", 100)
+	viper.SetDefault("gateway.scheduler.min_interval", "5m")
+	viper.SetDefault("gateway.scheduler.run_history", 20)
+	viper.SetDefault("gateway.scheduler.change_threshold", 0.3)
+	viper.SetDefault("gateway.scheduler.min_new_sources", 2)
+	viper.SetDefault("gateway.exports.enabled", true)
+	viper.SetDefault("gateway.exports.ttl", "24h")
+	viper.SetDefault("gateway.exports.pdf_timeout", "30s")
+	viper.SetDefault("gateway.exports.storage.backend", "")
+	viper.SetDefault("gateway.exports.storage.region", "us-east-1")
+	viper.SetDefault("gateway.exports.storage.prefix", "exports/")
+	viper.SetDefault("gateway.exports.storage.directory", "./data/exports")
+	viper.SetDefault("gateway.exports.storage.url_ttl", "15m")
+	viper.SetDefault("gateway.opensearch.short_name", "AI Search")
+	viper.SetDefault("gateway.opensearch.description", "Web search with AI-powered summaries")
+	viper.SetDefault("gateway.html_search.enabled", true)
+	viper.SetDefault("gateway.html_search.fragment_max_age", "5m")
+	viper.SetDefault("feature_flags.refresh_interval", "10s")
+	viper.SetDefault("store.backend", "")
+	viper.SetDefault("store.dsn", "")
+	viper.SetDefault("store.driver", "pgx")
+	viper.SetDefault("store.max_open_conns", 10)
+	viper.SetDefault("store.migrate", true)
+	viper.SetDefault("store.subsystems", []string{})
+	viper.SetDefault("events.enabled", false)
+	viper.SetDefault("events.bus", "redis")
+	viper.SetDefault("events.stream", "ai-search:events")
+	viper.SetDefault("events.max_len", 100000)
+	viper.SetDefault("events.url", "")
+	viper.SetDefault("events.timeout", "10s")
+	viper.SetDefault("events.relay_interval", "1s")
+	viper.SetDefault("events.batch_size", 100)
+	viper.SetDefault("events.retention", "168h")
+	viper.SetDefault("privacy.ip_mode", "off")
+	viper.SetDefault("privacy.ipv4_prefix", 24)
+	viper.SetDefault("privacy.ipv6_prefix", 48)
+	viper.SetDefault("privacy.hash_key", "")
+	viper.SetDefault("gateway.docs.enabled", true)
+	viper.SetDefault("gateway.docs.swagger_ui_url", "https://unpkg.com/swagger-ui-dist@5")
+	viper.SetDefault("gateway.graphql.enabled", true)
+	viper.SetDefault("gateway.graphql.max_depth", 8)
+	viper.SetDefault("gateway.graphql.max_searches", 3)
+	viper.SetDefault("gateway.suggest.enabled", true)
+	viper.SetDefault("gateway.suggest.max_results", 8)
+	viper.SetDefault("gateway.suggest.min_count", 2)
+	viper.SetDefault("gateway.suggest.max_entries", 10000)
+	viper.SetDefault("gateway.suggest.max_query_words", 8)
+	viper.SetDefault("gateway.suggest.timeout", "500ms")
+	viper.SetDefault("gateway.tenancy.enabled", false)
+	viper.SetDefault("gateway.tenancy.require_tenant", false)
+	viper.SetDefault("gateway.rbac.enabled", false)
+	viper.SetDefault("gateway.rbac.audit_entries", 1000)
+	viper.SetDefault("gateway.oidc.role_claim", "groups")
+	viper.SetDefault("gateway.oidc.scopes", []string{"openid", "email", "profile"})
+	viper.SetDefault("gateway.login.enabled", false)
+	viper.SetDefault("gateway.login.session_ttl", "12h")
+	viper.SetDefault("gateway.login.cookie_name", "ai_search_session")
+	viper.SetDefault("gateway.login.secure_cookie", false)
+	viper.SetDefault("gateway.login.history_entries", 100)
+	viper.SetDefault("gateway.sharing.enabled", true)
+	viper.SetDefault("gateway.sharing.ttl", "72h")
+	viper.SetDefault("gateway.sharing.max_ttl", "720h")
+	viper.SetDefault("gateway.instant_answers.enabled", true)
+	viper.SetDefault("gateway.instant_answers.dictionary_url", "https://api.dictionaryapi.dev/api/v2/entries/en")
+	viper.SetDefault("gateway.instant_answers.dictionary_timeout", "2s")
+	viper.SetDefault("gateway.answer_providers.weather.enabled", true)
+	viper.SetDefault("gateway.answer_providers.weather.url", "https://api.openweathermap.org/data/2.5")
+	viper.SetDefault("gateway.answer_providers.stocks.enabled", true)
+	viper.SetDefault("gateway.answer_providers.stocks.url", "https://query1.finance.yahoo.com")
+	viper.SetDefault("gateway.answer_providers.sports.enabled", true)
+	viper.SetDefault("gateway.answer_providers.sports.url", "https://www.thesportsdb.com/api/v1/json/3")
+	viper.SetDefault("gateway.knowledge_panel.enabled", false)
+	viper.SetDefault("gateway.knowledge_panel.max_results", 5)
+	viper.SetDefault("gateway.related_searches.enabled", true)
+	viper.SetDefault("gateway.related_searches.max", 6)
+	viper.SetDefault("gateway.related_searches.polish", false)
+	viper.SetDefault("gateway.related_searches.prefetch.enabled", false)
+	viper.SetDefault("gateway.related_searches.prefetch.queries", 2)
+	viper.SetDefault("gateway.related_searches.prefetch.max_entries", 500)
+	viper.SetDefault("gateway.related_searches.prefetch.ttl", "10m")
+	viper.SetDefault("gateway.related_searches.prefetch.max_concurrent", 2)
+	viper.SetDefault("gateway.related_searches.prefetch.max_in_flight", 20)
+	viper.SetDefault("gateway.reranking.enabled", false)
+	viper.SetDefault("gateway.reranking.min_score", 0.1)
+	viper.SetDefault("gateway.reranking.min_results", 3)
+	viper.SetDefault("gateway.snippets.enabled", true)
+	viper.SetDefault("gateway.snippets.compression.enabled", false)
+	viper.SetDefault("gateway.snippets.compression.max_words", 40)
+	viper.SetDefault("gateway.model_routing.enabled", false)
+	viper.SetDefault("gateway.model_routing.small_model", "sshleifer/distilbart-cnn-12-6")
+	viper.SetDefault("gateway.model_routing.large_model", "facebook/bart-large-cnn")
+	viper.SetDefault("gateway.model_routing.max_small_tokens", 600)
+	viper.SetDefault("gateway.model_routing.max_small_sources", 4)
+	viper.SetDefault("gateway.model_routing.complex_query_types", []string{"comparison"})
+	viper.SetDefault("gateway.prompt_audit.enabled", false)
+	viper.SetDefault("gateway.prompt_audit.retention", "720h")
+	viper.SetDefault("gateway.retention.janitor_interval", "10m")
+	viper.SetDefault("gateway.retention.history", "2160h")
+	viper.SetDefault("gateway.retention.audit_log", "8760h")
+	viper.SetDefault("gateway.retention.caches", "24h")
+	viper.SetDefault("gateway.maintenance.lock_ttl", "15m")
+	viper.SetDefault("gateway.locale.accept_language", true)
+	viper.SetDefault("gateway.locale.default", "")
+	viper.SetDefault("gateway.sampling.temperature.min", 0)
+	viper.SetDefault("gateway.sampling.temperature.max", 2)
+	viper.SetDefault("gateway.sampling.top_p.min", 0)
+	viper.SetDefault("gateway.sampling.top_p.max", 1)
+	viper.SetDefault("gateway.sampling.frequency_penalty.min", -2)
+	viper.SetDefault("gateway.sampling.frequency_penalty.max", 2)
+	viper.SetDefault("gateway.sampling.presence_penalty.min", -2)
+	viper.SetDefault("gateway.sampling.presence_penalty.max", 2)
+	viper.SetDefault("gateway.sampling.max_stop", 4)
+	viper.SetDefault("gateway.sampling.max_stop_length", 64)
+	viper.SetDefault("gateway.sampling.deterministic_seed", 42)
+	viper.SetDefault("gateway.shadow.enabled", false)
+	viper.SetDefault("gateway.shadow.percent", 1)
+	viper.SetDefault("gateway.shadow.address", "")
+	viper.SetDefault("gateway.shadow.model", "")
+	viper.SetDefault("gateway.shadow.timeout", "60s")
+	viper.SetDefault("gateway.shadow.max_concurrent", 4)
+	viper.SetDefault("gateway.shadow.output", "shadow.jsonl")
+	viper.SetDefault("gateway.image_proxy.enabled", true)
+	viper.SetDefault("gateway.image_proxy.max_bytes", 2<<20)
+	viper.SetDefault("gateway.image_proxy.timeout", "5s")
+	viper.SetDefault("gateway.image_proxy.cache_entries", 500)
+	viper.SetDefault("gateway.image_proxy.cache_ttl", "24h")
+	viper.SetDefault("gateway.limits.max_body_bytes", 1<<20)
+	viper.SetDefault("gateway.limits.max_query_length", 500)
+	viper.SetDefault("gateway.limits.max_num_results", 20)
+	viper.SetDefault("gateway.limits.read_header_timeout", "10s")
+	viper.SetDefault("gateway.limits.body_timeout", "30s")
+	viper.SetDefault("gateway.server.idle_timeout", "120s")
+	viper.SetDefault("gateway.server.write_timeout", "60s")
+	viper.SetDefault("gateway.server.stream_write_timeout", "10m")
+	viper.SetDefault("gateway.server.http2", true)
+	viper.SetDefault("gateway.server.h2c", false)
+	viper.SetDefault("gateway.server.max_concurrent_streams", 250)
+	viper.SetDefault("gateway.server.http_redirect_port", 0)
+	viper.SetDefault("gateway.server.shutdown_timeout", "30s")
+	viper.SetDefault("gateway.server.autocert.enabled", false)
+	viper.SetDefault("gateway.server.autocert.cache_dir", "autocert-cache")
+
+	// Redis
+	viper.SetDefault("redis.host", "")
+	viper.SetDefault("redis.port", 6379)
+	viper.SetDefault("redis.db", 0)
+
+	// Per-request budget
+	viper.SetDefault("budget.max_input_tokens", 1024)
+	viper.SetDefault("budget.max_output_tokens", 256)
+	viper.SetDefault("budget.max_fetched_pages", 10)
+	viper.SetDefault("budget.max_llm_calls", 3)
+
+	// Notifications
+	viper.SetDefault("notifications.smtp.port", 587)
+	viper.SetDefault("notifications.smtp.from", "alerts@localhost")
+	viper.SetDefault("notifications.overload.window", "1m")
+	viper.SetDefault("notifications.overload.rejections", 20)
 
 	// Services
 	viper.SetDefault("services.search.host", "localhost")
 	viper.SetDefault("services.search.port", 8081)
+	viper.SetDefault("services.search.probe_port", 9081)
+	viper.SetDefault("services.search.admin_port", 6081)
 	viper.SetDefault("services.search.timeout", "10s")
 
 	viper.SetDefault("services.tokenizer.host", "localhost")
@@ -115,24 +1509,166 @@ func setDefaults() {
 
 	viper.SetDefault("services.inference.host", "localhost")
 	viper.SetDefault("services.inference.port", 8083)
+	viper.SetDefault("services.inference.probe_port", 9083)
+	viper.SetDefault("services.inference.admin_port", 6083)
 	viper.SetDefault("services.inference.timeout", "30s")
 
 	viper.SetDefault("services.safety.host", "localhost")
 	viper.SetDefault("services.safety.port", 8084)
+	viper.SetDefault("services.safety.probe_port", 9084)
+	viper.SetDefault("services.safety.admin_port", 6084)
 	viper.SetDefault("services.safety.timeout", "5s")
+	viper.SetDefault("safety.policy_cache_entries", 1000)
+	viper.SetDefault("safety.toxicity.annotate_threshold", 0.2)
+	viper.SetDefault("safety.toxicity.rewrite_threshold", 0.3)
+	viper.SetDefault("safety.toxicity.block_threshold", 0.9)
+	viper.SetDefault("safety.batch_workers", 8)
+	viper.SetDefault("safety.max_batch_size", 256)
 
 	viper.SetDefault("services.llm.host", "localhost")
 	viper.SetDefault("services.llm.port", 8086)
+	viper.SetDefault("services.llm.probe_port", 9086)
+	viper.SetDefault("services.llm.admin_port", 6086)
 	viper.SetDefault("services.llm.timeout", "30s")
 
-
 	// Google
 	viper.SetDefault("google.api_key", "")
 	viper.SetDefault("google.cx", "")
+	viper.SetDefault("google.base_url", "https://www.googleapis.com/customsearch/v1")
+	viper.SetDefault("google.suggest_url", "")
+	viper.SetDefault("google.restrict_language", true)
+	viper.SetDefault("google.paywall_domains", []string{
+		"wsj.com", "ft.com", "nytimes.com", "economist.com", "bloomberg.com", "washingtonpost.com",
+		"barrons.com", "thetimes.co.uk", "telegraph.co.uk", "newyorker.com", "theatlantic.com", "hbr.org",
+	})
+
+	// Elasticsearch / OpenSearch
+	viper.SetDefault("elasticsearch.enabled", false)
+	viper.SetDefault("elasticsearch.url", "http://localhost:9200")
+	viper.SetDefault("elasticsearch.index", "documents")
+	viper.SetDefault("elasticsearch.timeout", "5s")
+	viper.SetDefault("elasticsearch.title_field", "title")
+	viper.SetDefault("elasticsearch.url_field", "url")
+	viper.SetDefault("elasticsearch.content_field", "content")
+	viper.SetDefault("elasticsearch.fragment_size", 200)
+	viper.SetDefault("elasticsearch.weight", 0.3)
+
+	// Vector store
+	viper.SetDefault("vector_store.enabled", false)
+	viper.SetDefault("vector_store.backend", "qdrant")
+	viper.SetDefault("vector_store.url", "http://localhost:6333")
+	viper.SetDefault("vector_store.driver", "pgx")
+	viper.SetDefault("vector_store.timeout", "5s")
+	viper.SetDefault("vector_store.collection", "documents")
+	viper.SetDefault("vector_store.top_k", 5)
+	viper.SetDefault("vector_store.score_threshold", 0.5)
+	viper.SetDefault("vector_store.title_field", "title")
+	viper.SetDefault("vector_store.url_field", "url")
+	viper.SetDefault("vector_store.content_field", "content")
+	viper.SetDefault("vector_store.embedding_field", "embedding")
+	viper.SetDefault("vector_store.snippet_length", 200)
+	viper.SetDefault("vector_store.hash_field", "content_hash")
+	viper.SetDefault("vector_store.chunk_size", 1000)
+	viper.SetDefault("vector_store.chunk_overlap", 200)
+	viper.SetDefault("vector_store.weight", 0.3)
+
+	// Ranking
+	viper.SetDefault("ranking.method", "interleave")
 
 	// LLM
 	viper.SetDefault("llm.max_workers", 10)
 	viper.SetDefault("llm.max_queue_size", 10000)
+	viper.SetDefault("llm.detokenize_batch_size", 8)
+	viper.SetDefault("llm.detokenize_flush_interval", "100ms")
+	viper.SetDefault("llm.request_ttl", "1h")
+	viper.SetDefault("llm.stream_ttl", "10m")
+	viper.SetDefault("llm.reap_interval", "1m")
+	viper.SetDefault("llm.max_finished_requests", 10000)
+	viper.SetDefault("llm.shutdown_timeout", "30s")
+	viper.SetDefault("llm.summary_cache.enabled", false)
+	viper.SetDefault("llm.summary_cache.max_entries", 1000)
+	viper.SetDefault("llm.summary_cache.ttl", "1h")
+	viper.SetDefault("llm.stream_guard.enabled", true)
+	viper.SetDefault("llm.stream_guard.max_ngram", 4)
+	viper.SetDefault("llm.stream_guard.max_repeats", 4)
+	viper.SetDefault("llm.stream_guard.max_words", 400)
+	viper.SetDefault("llm.stream_recovery.enabled", true)
+	viper.SetDefault("llm.stream_pool.enabled", true)
+	viper.SetDefault("llm.stream_pool.size", 4)
+	viper.SetDefault("llm.stream_pool.max_idle", "5m")
+	viper.SetDefault("llm.stream_pool.check_interval", "5s")
+	viper.SetDefault("llm.stream_pool.max_backoff", "1m")
+	viper.SetDefault("llm.timeouts.request", "5m")
+	viper.SetDefault("llm.timeouts.tokenize", "10s")
+	viper.SetDefault("llm.timeouts.inference", "2m")
+	viper.SetDefault("llm.timeouts.detokenize", "10s")
+
+	// Ollama
+	viper.SetDefault("ollama.host", "localhost")
+	viper.SetDefault("ollama.port", 11434)
+	viper.SetDefault("ollama.model", "llama3.2")
+	viper.SetDefault("ollama.temperature", 0.3)
+	viper.SetDefault("ollama.max_tokens", 256)
+	viper.SetDefault("ollama.timeout", "60s")
+	viper.SetDefault("ollama.keep_alive", "5m")
+
+	// vLLM
+	viper.SetDefault("vllm.host", "localhost")
+	viper.SetDefault("vllm.port", 8000)
+	viper.SetDefault("vllm.model", "facebook/bart-large-cnn")
+	viper.SetDefault("vllm.timeout", "60s")
+
+	// Inference
+	viper.SetDefault("inference.backend.engine", "vllm")
+	viper.SetDefault("inference.backend.transport", "http")
+	viper.SetDefault("inference.triton.url", "http://localhost:8000")
+	viper.SetDefault("inference.triton.grpc_address", "localhost:8001")
+	viper.SetDefault("inference.triton.model", "bart_summarizer")
+	viper.SetDefault("inference.triton.input_name", "input_ids")
+	viper.SetDefault("inference.triton.attention_mask_name", "attention_mask")
+	viper.SetDefault("inference.triton.output_name", "output_ids")
+	viper.SetDefault("inference.triton.datatype", "INT64")
+	viper.SetDefault("inference.triton.bos_token_id", 0)
+	viper.SetDefault("inference.triton.pad_token_id", 1)
+	viper.SetDefault("inference.triton.eos_token_id", 2)
+	viper.SetDefault("inference.triton.timeout", "60s")
+	viper.SetDefault("inference.llamacpp.url", "http://127.0.0.1:8080")
+	viper.SetDefault("inference.llamacpp.model", "qwen2.5-1.5b-instruct-q4_k_m")
+	viper.SetDefault("inference.llamacpp.model_path", "models/qwen2.5-1.5b-instruct-q4_k_m.gguf")
+	viper.SetDefault("inference.llamacpp.context_length", 4096)
+	viper.SetDefault("inference.llamacpp.timeout", "120s")
+	viper.SetDefault("inference.onnx.model_path", "models/distilbart-cnn-6-6-beamsearch.onnx")
+	viper.SetDefault("inference.onnx.model", "distilbart-cnn-6-6")
+	viper.SetDefault("inference.onnx.min_length", 10)
+	viper.SetDefault("inference.onnx.num_beams", 2)
+	viper.SetDefault("inference.onnx.length_penalty", 1.0)
+	viper.SetDefault("inference.onnx.concurrency", 1)
+	viper.SetDefault("inference.onnx.bos_token_id", 0)
+	viper.SetDefault("inference.onnx.pad_token_id", 1)
+	viper.SetDefault("inference.onnx.eos_token_id", 2)
+	viper.SetDefault("inference.warmup.enabled", true)
+	viper.SetDefault("inference.warmup.prompt", "Summarize: The quick brown fox jumps over the lazy dog.")
+	viper.SetDefault("inference.warmup.max_tokens", 16)
+	viper.SetDefault("inference.warmup.timeout", "5m")
+	viper.SetDefault("inference.prefix_cache.enabled", false)
+	viper.SetDefault("inference.prefix_cache.instruction", DefaultSummarizationInstruction)
+	viper.SetDefault("inference.prefix_cache.max_entries", 1024)
+	viper.SetDefault("inference.embedding.backend", "ollama")
+	viper.SetDefault("inference.embedding.model", "nomic-embed-text")
+	viper.SetDefault("inference.entities.backend", "vllm")
+	viper.SetDefault("inference.entities.max_tokens", 256)
+	viper.SetDefault("inference.related_searches.backend", "vllm")
+	viper.SetDefault("inference.related_searches.max_tokens", 128)
+	viper.SetDefault("inference.rerank.backend", "cross_encoder")
+	viper.SetDefault("inference.rerank.url", "http://localhost:8006/v1/rerank")
+	viper.SetDefault("inference.rerank.max_tokens", 64)
+	viper.SetDefault("inference.model_switch.canary_prompt", "What is the capital of France? Answer in one word.")
+	viper.SetDefault("inference.model_switch.canary_expect", "Paris")
+	viper.SetDefault("inference.model_switch.canary_max_tokens", 16)
+	viper.SetDefault("inference.model_switch.timeout", "2m")
+	viper.SetDefault("inference.model_switch.window", "10m")
+	viper.SetDefault("inference.model_switch.min_requests", 20)
+	viper.SetDefault("inference.model_switch.max_error_rate_increase", 0.05)
 }
 
 func overrideWithEnv() {
@@ -142,17 +1678,118 @@ func overrideWithEnv() {
 	if val := os.Getenv("LOG_LEVEL"); val != "" {
 		viper.Set("log_level", val)
 	}
+	if val := os.Getenv("UPSTREAM_MODE"); val != "" {
+		viper.Set("upstream_mode", val)
+	}
+	if val := os.Getenv("FIXTURES_DIR"); val != "" {
+		viper.Set("fixtures_dir", val)
+	}
+	if val := os.Getenv("FAULT_INJECTION_ENABLED"); val != "" {
+		viper.Set("fault_injection.enabled", val == "true")
+	}
 	if val := os.Getenv("GATEWAY_PORT"); val != "" {
 		if port, err := strconv.Atoi(val); err == nil {
 			viper.Set("gateway.port", port)
 		}
 	}
+	if val := os.Getenv("REDIS_HOST"); val != "" {
+		viper.Set("redis.host", val)
+	}
+	if val := os.Getenv("REDIS_PORT"); val != "" {
+		if port, err := strconv.Atoi(val); err == nil {
+			viper.Set("redis.port", port)
+		}
+	}
+	if val := os.Getenv("REDIS_PASSWORD"); val != "" {
+		viper.Set("redis.password", val)
+	}
+	if val := os.Getenv("OIDC_ISSUER"); val != "" {
+		viper.Set("gateway.oidc.issuer", val)
+	}
+	if val := os.Getenv("OIDC_CLIENT_ID"); val != "" {
+		viper.Set("gateway.oidc.client_id", val)
+	}
+	if val := os.Getenv("OIDC_CLIENT_SECRET"); val != "" {
+		viper.Set("gateway.oidc.client_secret", val)
+	}
+	if val := os.Getenv("SESSION_SECRET"); val != "" {
+		viper.Set("gateway.login.session_secret", val)
+	}
+	if val := os.Getenv("TLS_CERT_FILE"); val != "" {
+		viper.Set("gateway.server.tls_cert_file", val)
+	}
+	if val := os.Getenv("TLS_KEY_FILE"); val != "" {
+		viper.Set("gateway.server.tls_key_file", val)
+	}
+	if val := os.Getenv("SHARE_SECRET"); val != "" {
+		viper.Set("gateway.sharing.secret", val)
+	}
+	if val := os.Getenv("PRIVACY_HASH_KEY"); val != "" {
+		viper.Set("privacy.hash_key", val)
+	}
+	if val := os.Getenv("EXPORT_STORAGE_ACCESS_KEY"); val != "" {
+		viper.Set("gateway.exports.storage.access_key", val)
+	}
+	if val := os.Getenv("EXPORT_STORAGE_SECRET_KEY"); val != "" {
+		viper.Set("gateway.exports.storage.secret_key", val)
+	}
+	if val := os.Getenv("EXPORT_SIGNING_KEY"); val != "" {
+		viper.Set("gateway.exports.storage.signing_key", val)
+	}
+	if val := os.Getenv("STORE_DSN"); val != "" {
+		viper.Set("store.dsn", val)
+	}
+	if val := os.Getenv("ENCRYPTION_KEYS"); val != "" {
+		// id:key pairs separated by commas, the primary key first
+		var keys []map[string]interface{}
+		for _, pair := range strings.Split(val, ",") {
+			id, key, _ := strings.Cut(strings.TrimSpace(pair), ":")
+			keys = append(keys, map[string]interface{}{"id": id, "key": key})
+		}
+		viper.Set("encryption.keys", keys)
+	}
+	if val := os.Getenv("DIAGNOSTICS_TOKEN"); val != "" {
+		viper.Set("diagnostics.token", val)
+	}
+	if val := os.Getenv("TENANT_ADMIN_TOKEN"); val != "" {
+		viper.Set("gateway.tenancy.admin_token", val)
+	}
+	if val := os.Getenv("PDF_RENDERER_URL"); val != "" {
+		viper.Set("gateway.exports.pdf_renderer_url", val)
+	}
+	if val := os.Getenv("SMTP_HOST"); val != "" {
+		viper.Set("notifications.smtp.host", val)
+	}
+	if val := os.Getenv("SMTP_PASSWORD"); val != "" {
+		viper.Set("notifications.smtp.password", val)
+	}
 	if val := os.Getenv("GOOGLE_API_KEY"); val != "" {
 		viper.Set("google.api_key", val)
 	}
 	if val := os.Getenv("GOOGLE_CX"); val != "" {
 		viper.Set("google.cx", val)
 	}
+	if val := os.Getenv("ELASTICSEARCH_URL"); val != "" {
+		viper.Set("elasticsearch.url", val)
+	}
+	if val := os.Getenv("ELASTICSEARCH_PASSWORD"); val != "" {
+		viper.Set("elasticsearch.password", val)
+	}
+	if val := os.Getenv("ELASTICSEARCH_API_KEY"); val != "" {
+		viper.Set("elasticsearch.api_key", val)
+	}
+	if val := os.Getenv("VECTOR_STORE_URL"); val != "" {
+		viper.Set("vector_store.url", val)
+	}
+	if val := os.Getenv("VECTOR_STORE_API_KEY"); val != "" {
+		viper.Set("vector_store.api_key", val)
+	}
+	if val := os.Getenv("VECTOR_STORE_DSN"); val != "" {
+		viper.Set("vector_store.dsn", val)
+	}
+	if val := os.Getenv("OPENWEATHER_API_KEY"); val != "" {
+		viper.Set("gateway.answer_providers.weather.api_key", val)
+	}
 	if val := os.Getenv("SEARCH_HOST"); val != "" {
 		viper.Set("services.search.host", val)
 	}
@@ -178,4 +1815,23 @@ func overrideWithEnv() {
 			viper.Set("services.llm.port", port)
 		}
 	}
+	if val := os.Getenv("OLLAMA_HOST"); val != "" {
+		viper.Set("ollama.host", val)
+	}
+	if val := os.Getenv("OLLAMA_PORT"); val != "" {
+		if port, err := strconv.Atoi(val); err == nil {
+			viper.Set("ollama.port", port)
+		}
+	}
+	if val := os.Getenv("OLLAMA_MODEL"); val != "" {
+		viper.Set("ollama.model", val)
+	}
+	if val := os.Getenv("VLLM_HOST"); val != "" {
+		viper.Set("vllm.host", val)
+	}
+	if val := os.Getenv("VLLM_PORT"); val != "" {
+		if port, err := strconv.Atoi(val); err == nil {
+			viper.Set("vllm.port", port)
+		}
+	}
 }
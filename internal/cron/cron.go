@@ -0,0 +1,355 @@
+// Package cron runs maintenance jobs on cron schedules. Every replica
+// schedules every job, but with Redis each scheduled run is claimed by one
+// replica, and the outcome of each job's last run is shared so that any
+// replica reports it.
+package cron
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// Off in maintenance.jobs disables a job
+const Off = "off"
+
+// Errors of Trigger
+var (
+	ErrUnknownJob = errors.New("cron: unknown job")
+	ErrRunning    = errors.New("cron: the job is running")
+)
+
+// Func is the work of a job; ctx ends after maintenance.lock_ttl
+type Func func(ctx context.Context) error
+
+// Run is the outcome of a run of a job
+type Run struct {
+	Replica   string    `json:"replica"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration_seconds"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Status describes a job for introspection. Counts are of this replica
+// since it started; LastRun is the last run on any replica.
+type Status struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run"`
+	LastRun  *Run      `json:"last_run,omitempty"`
+	Runs     int       `json:"runs"`
+	Failures int       `json:"failures"`
+	Skipped  int       `json:"skipped"` // runs another replica claimed or the previous run still held
+}
+
+// Backend coordinates the replicas running the same jobs
+type Backend interface {
+	// Claim takes the run of job scheduled at the given time for ttl,
+	// reporting false when another replica took it
+	Claim(ctx context.Context, job string, at time.Time, ttl time.Duration) (bool, error)
+	SaveRun(ctx context.Context, job string, run *Run) error
+	// LastRuns returns the last run of every job that ran
+	LastRuns(ctx context.Context) (map[string]*Run, error)
+}
+
+// Scheduler runs the jobs added to it once started
+type Scheduler struct {
+	config  config.MaintenanceConfig
+	backend Backend
+	replica string
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	ctx     context.Context // set by Start
+	started bool
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	fn       Func
+
+	// guarded by Scheduler.mu
+	running                 bool
+	next                    time.Time
+	runs, failures, skipped int
+	last                    *Run
+}
+
+// New returns a scheduler whose runs are claimed in Redis when a client is
+// given, and by this process alone otherwise
+func New(cfg config.MaintenanceConfig, client *redis.Client) *Scheduler {
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = 15 * time.Minute
+	}
+	var backend Backend = &memoryBackend{runs: make(map[string]*Run)}
+	if client != nil {
+		backend = &redisBackend{client: client}
+	}
+	return &Scheduler{config: cfg, backend: backend, replica: replicaName(), jobs: make(map[string]*job)}
+}
+
+// replicaName tells the replicas apart in the runs they report
+func replicaName() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gateway"
+	}
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// Add registers a job on its default schedule, unless maintenance.jobs
+// overrides it; a job set to "off" is not added
+func (s *Scheduler) Add(name, defaultSpec string, fn Func) error {
+	spec := defaultSpec
+	if override, ok := s.config.Jobs[name]; ok {
+		spec = override
+	}
+	if spec == Off {
+		logger.GetLogger().Infof("Maintenance job %s is off", name)
+		return nil
+	}
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("maintenance job %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("maintenance job %s is added twice", name)
+	}
+	j := &job{name: name, spec: spec, schedule: schedule, fn: fn}
+	s.jobs[name] = j
+	if s.started {
+		go s.loop(s.ctx, j)
+	}
+	return nil
+}
+
+// Start runs the jobs on their schedules until ctx ends
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.started = ctx, true
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+// Jobs reports every job, by name
+func (s *Scheduler) Jobs(ctx context.Context) ([]Status, error) {
+	last, err := s.backend.LastRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := Status{
+			Name:     j.name,
+			Schedule: j.spec,
+			Running:  j.running,
+			NextRun:  j.next,
+			LastRun:  last[j.name],
+			Runs:     j.runs,
+			Failures: j.failures,
+			Skipped:  j.skipped,
+		}
+		if status.LastRun == nil {
+			status.LastRun = j.last
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses, nil
+}
+
+// Trigger runs a job now on this replica, outside its schedule
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	running := ok && j.running
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		return ErrUnknownJob
+	case running:
+		return ErrRunning
+	}
+	go s.run(context.Background(), j, time.Time{})
+	return nil
+}
+
+// loop runs a job at each of its scheduled times until ctx ends
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := s.nextRun(j, time.Now())
+		s.mu.Lock()
+		j.next = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.run(ctx, j, next)
+	}
+}
+
+// nextRun is the job's first run after now. Intervals are counted from the
+// zero time rather than from now, so that every replica arrives at the same
+// run times and claims the same runs.
+func (s *Scheduler) nextRun(j *job, now time.Time) time.Time {
+	if every, ok := j.schedule.(Every); ok {
+		return now.Truncate(time.Duration(every)).Add(time.Duration(every))
+	}
+	return j.schedule.Next(now)
+}
+
+// run executes the job, claiming its run at the scheduled time first; a
+// zero time is a triggered run, which is not claimed
+func (s *Scheduler) run(ctx context.Context, j *job, at time.Time) {
+	log := logger.GetLogger()
+	s.mu.Lock()
+	if j.running {
+		j.skipped++
+		s.mu.Unlock()
+		monitoring.RecordMaintenanceRun(j.name, "skipped", 0)
+		return
+	}
+	j.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		j.running = false
+		s.mu.Unlock()
+	}()
+
+	if !at.IsZero() {
+		claimed, err := s.backend.Claim(ctx, j.name, at, s.config.LockTTL)
+		if err != nil {
+			log.Errorf("Failed to claim maintenance job %s: %v", j.name, err)
+		}
+		if err != nil || !claimed {
+			s.mu.Lock()
+			j.skipped++
+			s.mu.Unlock()
+			monitoring.RecordMaintenanceRun(j.name, "skipped", 0)
+			return
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, s.config.LockTTL)
+	defer cancel()
+	run := &Run{Replica: s.replica, StartedAt: time.Now().UTC()}
+	err := j.fn(runCtx)
+	run.Duration = time.Since(run.StartedAt).Seconds()
+	outcome := "succeeded"
+	if err != nil {
+		run.Error, outcome = err.Error(), "failed"
+		log.Errorf("Maintenance job %s failed after %.1fs: %v", j.name, run.Duration, err)
+	}
+	monitoring.RecordMaintenanceRun(j.name, outcome, run.Duration)
+
+	s.mu.Lock()
+	j.runs++
+	if err != nil {
+		j.failures++
+	}
+	j.last = run
+	s.mu.Unlock()
+
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelSave()
+	if err := s.backend.SaveRun(saveCtx, j.name, run); err != nil {
+		log.Warnf("Failed to record the run of maintenance job %s: %v", j.name, err)
+	}
+}
+
+// redisBackend claims runs with SET NX; the claim expires rather than
+// being released, so a replica whose clock lags cannot run it again
+type redisBackend struct {
+	client *redis.Client
+}
+
+const redisRunsKey = "cron:runs"
+
+func (b *redisBackend) Claim(ctx context.Context, job string, at time.Time, ttl time.Duration) (bool, error) {
+	key := "cron:claim:" + job + ":" + strconv.FormatInt(at.UnixNano(), 10)
+	return b.client.SetNX(ctx, key, 1, ttl).Result()
+}
+
+func (b *redisBackend) SaveRun(ctx context.Context, job string, run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return b.client.HSet(ctx, redisRunsKey, job, data).Err()
+}
+
+func (b *redisBackend) LastRuns(ctx context.Context) (map[string]*Run, error) {
+	values, err := b.client.HGetAll(ctx, redisRunsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	runs := make(map[string]*Run, len(values))
+	for job, data := range values {
+		var run Run
+		if err := json.Unmarshal([]byte(data), &run); err != nil {
+			return nil, fmt.Errorf("invalid run of %s: %w", job, err)
+		}
+		runs[job] = &run
+	}
+	return runs, nil
+}
+
+// memoryBackend serves a single replica, which runs every run it schedules
+type memoryBackend struct {
+	mu   sync.Mutex
+	runs map[string]*Run
+}
+
+func (b *memoryBackend) Claim(ctx context.Context, job string, at time.Time, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (b *memoryBackend) SaveRun(ctx context.Context, job string, run *Run) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.runs[job] = run
+	return nil
+}
+
+func (b *memoryBackend) LastRuns(ctx context.Context) (map[string]*Run, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	runs := make(map[string]*Run, len(b.runs))
+	for job, run := range b.runs {
+		copied := *run
+		runs[job] = &copied
+	}
+	return runs, nil
+}
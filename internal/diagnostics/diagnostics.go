@@ -0,0 +1,205 @@
+// Package diagnostics serves runtime debugging endpoints on an admin-only
+// port: net/http/pprof profiles, expvar, profile snapshots written to disk
+// and a summary of goroutines, GC and gRPC connections. They exist to chase
+// leaks in production, such as requests the orchestrator keeps tracking, so
+// every request must carry the diagnostics token.
+package diagnostics
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	rpprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+)
+
+// Sources are what a service adds to its diagnostics summary
+type Sources struct {
+	// Conns are the service's gRPC client connections, by backend name
+	Conns map[string]*grpc.ClientConn
+	// Stats returns service-specific counters, e.g. tracked requests
+	Stats func() map[string]interface{}
+}
+
+// Diagnostics serves one service's diagnostics endpoints
+type Diagnostics struct {
+	service string
+	config  config.DiagnosticsConfig
+	sources Sources
+	started time.Time
+}
+
+// New returns nil when diagnostics are disabled. They cannot be enabled
+// without a token.
+func New(cfg *config.Config, service string, sources Sources) (*Diagnostics, error) {
+	diagCfg := cfg.Diagnostics
+	if !diagCfg.Enabled {
+		return nil, nil
+	}
+	if diagCfg.Token == "" {
+		return nil, errors.New("diagnostics.enabled requires diagnostics.token")
+	}
+	return &Diagnostics{service: service, config: diagCfg, sources: sources, started: time.Now()}, nil
+}
+
+// Handler serves /debug/pprof/, /debug/vars, POST /debug/snapshot and
+// /admin/diagnostics, each requiring the diagnostics token
+func (d *Diagnostics) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/snapshot", d.Snapshot)
+	mux.HandleFunc("/admin/diagnostics", d.Summary)
+	return d.requireToken(mux)
+}
+
+func (d *Diagnostics) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(d.config.Token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "Diagnostics token required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve starts the diagnostics endpoints on port in the background. It
+// returns nil when diagnostics are disabled or port is 0.
+func (d *Diagnostics) Serve(port int) *http.Server {
+	if d == nil || port == 0 {
+		return nil
+	}
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           d.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.GetLogger().Infof("Diagnostics for %s listening on port %d", d.service, port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.GetLogger().Errorf("Diagnostics server for %s failed: %v", d.service, err)
+		}
+	}()
+	return server
+}
+
+// Summary reports goroutines, memory and GC statistics, the state of each
+// gRPC connection and the service's own counters
+func (d *Diagnostics) Summary(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	gcSummary := map[string]interface{}{
+		"num_gc":      gc.NumGC,
+		"pause_total": gc.PauseTotal.String(),
+		"next_gc":     mem.NextGC,
+	}
+	if len(gc.Pause) > 0 {
+		gcSummary["last_pause"] = gc.Pause[0].String()
+	}
+	if !gc.LastGC.IsZero() {
+		gcSummary["last_gc"] = gc.LastGC.UTC()
+	}
+
+	conns := make(map[string]string, len(d.sources.Conns))
+	for name, conn := range d.sources.Conns {
+		conns[name] = conn.GetState().String()
+	}
+
+	summary := map[string]interface{}{
+		"service":    d.service,
+		"uptime":     time.Since(d.started).Round(time.Second).String(),
+		"go_version": runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"memory": map[string]interface{}{
+			"heap_alloc":   mem.HeapAlloc,
+			"heap_inuse":   mem.HeapInuse,
+			"heap_objects": mem.HeapObjects,
+			"sys":          mem.Sys,
+		},
+		"gc":               gcSummary,
+		"grpc_connections": conns,
+	}
+	if d.sources.Stats != nil {
+		summary["stats"] = d.sources.Stats()
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// snapshotProfiles are the profiles POST /debug/snapshot can write
+var snapshotProfiles = map[string]bool{"goroutine": true, "heap": true}
+
+// Snapshot writes a goroutine or heap profile (?profile=, default goroutine)
+// to diagnostics.snapshot_dir, so the state at the time of a leak can be
+// compared with a later one using go tool pprof -base
+func (d *Diagnostics) Snapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"error": "Use POST"})
+		return
+	}
+	name := r.URL.Query().Get("profile")
+	if name == "" {
+		name = "goroutine"
+	}
+	if !snapshotProfiles[name] {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "profile must be goroutine or heap"})
+		return
+	}
+	if name == "heap" {
+		runtime.GC() // profile live objects as of now
+	}
+
+	if err := os.MkdirAll(d.config.SnapshotDir, 0o700); err != nil {
+		logger.GetLogger().Errorf("Failed to create snapshot directory: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": "Failed to write snapshot"})
+		return
+	}
+	path := filepath.Join(d.config.SnapshotDir, fmt.Sprintf("%s-%s-%s.pb.gz", d.service, name, time.Now().UTC().Format("20060102T150405.000")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err == nil {
+		err = rpprof.Lookup(name).WriteTo(file, 0)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to write %s snapshot: %v", name, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": "Failed to write snapshot"})
+		return
+	}
+	logger.GetLogger().Infof("Wrote %s snapshot to %s", name, path)
+	result := map[string]interface{}{"profile": name, "path": path, "goroutines": runtime.NumGoroutine()}
+	if info, err := os.Stat(path); err == nil {
+		result["bytes"] = info.Size()
+	}
+	writeJSON(w, http.StatusCreated, result)
+}
+
+func writeJSON(w http.ResponseWriter, code int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
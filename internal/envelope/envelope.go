@@ -0,0 +1,200 @@
+// Package envelope encrypts values kept at rest with AES-256-GCM envelopes:
+// each value is encrypted with a random data key of its own, which is in
+// turn encrypted (wrapped) with one of the deployment's keys. Keys rotate by
+// configuring a new primary key ahead of the old ones, which keep opening
+// the values they wrapped until Rewrap has moved every value to the primary
+// key. Values that were stored before encryption was enabled are opened as
+// they are.
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ai-search-service/internal/config"
+)
+
+// prefix starts every sealed value: "enc1.<key id>.<wrapped data key>.<ciphertext>"
+const prefix = "enc1."
+
+// Keyring seals values with its primary key and opens those sealed with any
+// of its keys. A nil Keyring leaves values in plaintext.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// New returns the keyring of cfg, nil when no keys are configured. Keys are
+// 32 bytes, base64 encoded; the first one is the primary key.
+func New(cfg config.EncryptionConfig) (*Keyring, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, nil
+	}
+	k := &Keyring{primary: cfg.Keys[0].ID, keys: make(map[string]cipher.AEAD)}
+	for _, key := range cfg.Keys {
+		if key.ID == "" || strings.Contains(key.ID, ".") {
+			return nil, fmt.Errorf("envelope: key IDs must be non-empty and free of dots, got %q", key.ID)
+		}
+		if _, ok := k.keys[key.ID]; ok {
+			return nil, fmt.Errorf("envelope: duplicate key ID %q", key.ID)
+		}
+		secret, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil || len(secret) != 32 {
+			return nil, fmt.Errorf("envelope: key %q must be 32 bytes, base64 encoded", key.ID)
+		}
+		if k.keys[key.ID], err = newAEAD(secret); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Primary is the ID of the key new values are sealed with
+func (k *Keyring) Primary() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// Sealed reports whether data is a sealed value
+func Sealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(prefix))
+}
+
+// Seal encrypts plaintext under a new data key wrapped by the primary key
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := k.wrap(k.primary, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(aead, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(prefix + k.primary + "." + wrapped + "." + ciphertext), nil
+}
+
+// Open decrypts a sealed value, returning any other data as it is
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	if !Sealed(data) {
+		return data, nil
+	}
+	id, wrapped, ciphertext, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, ciphertext, nil)
+}
+
+// Rewrap moves a value to the primary key: a value sealed with another key
+// has its data key wrapped again, leaving its ciphertext as it is, and a
+// plaintext value is sealed. It reports
+// whether the value changed; values already under the primary key do not.
+func (k *Keyring) Rewrap(data []byte) ([]byte, bool, error) {
+	if k == nil {
+		return data, false, nil
+	}
+	if !Sealed(data) {
+		sealed, err := k.Seal(data)
+		return sealed, err == nil, err
+	}
+	id, wrapped, ciphertext, err := parse(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if id == k.primary {
+		return data, false, nil
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return nil, false, err
+	}
+	if wrapped, err = k.wrap(k.primary, dataKey); err != nil {
+		return nil, false, err
+	}
+	return []byte(prefix + k.primary + "." + wrapped + "." + ciphertext), true, nil
+}
+
+// wrap encrypts a data key with the key id
+func (k *Keyring) wrap(id string, dataKey []byte) (string, error) {
+	return seal(k.keys[id], dataKey, []byte(id))
+}
+
+// unwrap decrypts a data key wrapped by the key id
+func (k *Keyring) unwrap(id, wrapped string) ([]byte, error) {
+	if k == nil {
+		return nil, errors.New("envelope: the value is encrypted but no keys are configured")
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("envelope: the value is encrypted with key %q, which is not configured", id)
+	}
+	return open(aead, wrapped, []byte(id))
+}
+
+// parse splits a sealed value into its key ID, wrapped data key and
+// ciphertext
+func parse(data []byte) (id, wrapped, ciphertext string, err error) {
+	parts := strings.Split(strings.TrimPrefix(string(data), prefix), ".")
+	if len(parts) != 3 {
+		return "", "", "", errors.New("envelope: malformed sealed value")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// seal encrypts plaintext with a random nonce, returned base64url encoded
+// ahead of the ciphertext
+func seal(aead cipher.AEAD, plaintext, additional []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, additional)), nil
+}
+
+// open decrypts what seal returned
+func open(aead cipher.AEAD, sealed string, additional []byte) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, errors.New("envelope: malformed sealed value")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
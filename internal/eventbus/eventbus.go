@@ -0,0 +1,108 @@
+// Package eventbus publishes the events relayed from the store's outbox to
+// a Redis stream or a webhook. Delivery is at least once: a relay that
+// crashes after publishing a batch, before marking it published, publishes
+// it again, so consumers drop events whose ID they have seen.
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/store"
+)
+
+// Buses of events.bus
+const (
+	BusRedis   = "redis"
+	BusWebhook = "webhook"
+)
+
+// Publisher delivers a batch of events, all or none as far as it can tell
+type Publisher interface {
+	Publish(ctx context.Context, events []*store.OutboxEvent) error
+}
+
+// New returns the publisher of the configured bus; the Redis stream needs
+// client
+func New(cfg config.EventsConfig, client *redis.Client) (Publisher, error) {
+	switch cfg.Bus {
+	case BusRedis:
+		if client == nil {
+			return nil, fmt.Errorf("eventbus: the redis bus needs redis.host")
+		}
+		if cfg.Stream == "" {
+			return nil, fmt.Errorf("eventbus: the redis bus needs a stream")
+		}
+		return &redisStream{client: client, stream: cfg.Stream, maxLen: cfg.MaxLen}, nil
+	case BusWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("eventbus: the webhook bus needs a url")
+		}
+		return &webhook{url: cfg.URL, client: &http.Client{Timeout: cfg.Timeout}}, nil
+	default:
+		return nil, fmt.Errorf("eventbus: unknown bus %q (redis or webhook)", cfg.Bus)
+	}
+}
+
+// redisStream adds each event to a Redis stream as its id, topic, key,
+// created_at and data fields
+type redisStream struct {
+	client *redis.Client
+	stream string
+	maxLen int64 // approximate; 0 keeps every entry
+}
+
+func (s *redisStream) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, event := range events {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: s.stream,
+				MaxLen: s.maxLen,
+				Approx: s.maxLen > 0,
+				Values: map[string]interface{}{
+					"id":         event.ID,
+					"topic":      event.Topic,
+					"key":        event.Key,
+					"created_at": event.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+					"data":       string(event.Data),
+				},
+			})
+		}
+		return nil
+	})
+	return err
+}
+
+// webhook posts each batch as {"events": [...]}, published when answered
+// with a 2xx status
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhook) Publish(ctx context.Context, events []*store.OutboxEvent) error {
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("eventbus: webhook answered %s", resp.Status)
+	}
+	return nil
+}
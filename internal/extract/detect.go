@@ -0,0 +1,70 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// docxType is the MIME type of Word documents
+const docxType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// genericTypes say nothing about the content; servers send them for
+// downloads of any kind
+var genericTypes = map[string]bool{
+	"": true, "application/octet-stream": true, "binary/octet-stream": true,
+	"application/download": true, "application/x-download": true, "application/force-download": true,
+	"application/zip": true, "application/x-zip-compressed": true,
+}
+
+// Detect returns the media type to extract data as. The bytes win for
+// PDF and DOCX, which servers often label as generic downloads or even as
+// HTML; other generic or missing types are sniffed as HTML or plain text.
+// Otherwise the declared type is kept.
+func Detect(contentType string, data []byte) (string, error) {
+	mediaType := ""
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q", ErrUnsupported, contentType)
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return "application/pdf", nil
+	case isDOCX(data):
+		return docxType, nil
+	case !genericTypes[mediaType]:
+		return mediaType, nil
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	switch sniffed {
+	case "text/html", "text/plain":
+		return sniffed, nil
+	}
+	if mediaType == "" {
+		mediaType = sniffed
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupported, mediaType)
+}
+
+// isDOCX reports whether data is a ZIP archive holding a Word document
+func isDOCX(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return false
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+	for _, file := range archive.File {
+		if file.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errInflatedLimit stops reading a part at Limits.MaxInflatedBytes
+var errInflatedLimit = errors.New("inflated size limit reached")
+
+// maxDOCXParts bounds the entries of a DOCX archive looked through
+const maxDOCXParts = 10000
+
+// docxText extracts the paragraphs of a Word document's main part, one per
+// line, and its title from the core properties. Headers, footers, comments
+// and embedded objects are not read.
+func docxText(data []byte, limits Limits) (*Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DOCX: %w", err)
+	}
+	if len(archive.File) > maxDOCXParts {
+		return nil, fmt.Errorf("DOCX has %d parts, over the limit of %d", len(archive.File), maxDOCXParts)
+	}
+	var body, core *zip.File
+	for _, file := range archive.File {
+		switch file.Name {
+		case "word/document.xml":
+			body = file
+		case "docProps/core.xml":
+			core = file
+		}
+	}
+	if body == nil {
+		return nil, fmt.Errorf("DOCX has no word/document.xml")
+	}
+
+	var budget *int64 // nil when unlimited
+	if limits.MaxInflatedBytes > 0 {
+		budget = &limits.MaxInflatedBytes
+	}
+	doc := &Document{}
+	if err := readPart(body, budget, func(r io.Reader) error {
+		return docxBody(r, doc, limits.MaxPages)
+	}); err != nil {
+		if !errors.Is(err, errInflatedLimit) {
+			return nil, fmt.Errorf("failed to read DOCX: %w", err)
+		}
+		doc.Truncated = true
+	}
+	if core != nil {
+		// A missing or malformed title is not worth failing the document
+		readPart(core, budget, func(r io.Reader) error {
+			doc.Title, err = docxTitle(r)
+			return err
+		})
+	}
+	return doc, nil
+}
+
+// readPart decompresses a part through read, charging its bytes against
+// budget unless it is nil
+func readPart(file *zip.File, budget *int64, read func(io.Reader) error) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if budget == nil {
+		return read(rc)
+	}
+	return read(&budgetReader{r: rc, remaining: budget})
+}
+
+// budgetReader fails with errInflatedLimit once remaining is spent
+type budgetReader struct {
+	r         io.Reader
+	remaining *int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if *b.remaining <= 0 {
+		return 0, errInflatedLimit
+	}
+	if int64(len(p)) > *b.remaining {
+		p = p[:*b.remaining]
+	}
+	n, err := b.r.Read(p)
+	*b.remaining -= int64(n)
+	return n, err
+}
+
+// docxBody writes the text runs of word/document.xml into doc, stopping
+// after maxPages pages, counted by explicit page breaks, when positive
+func docxBody(r io.Reader, doc *Document, maxPages int) error {
+	var text strings.Builder
+	defer func() { doc.Text = text.String() }()
+	pages := 1
+	inText := false
+
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteByte('\t')
+			case "cr":
+				text.WriteByte('\n')
+			case "br":
+				if attr(t, "type") != "page" {
+					text.WriteByte('\n')
+					continue
+				}
+				if pages++; maxPages > 0 && pages > maxPages {
+					doc.Truncated = true
+					return nil
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// docxTitle reads dc:title from docProps/core.xml
+func docxTitle(r io.Reader) (string, error) {
+	var core struct {
+		Title string `xml:"title"`
+	}
+	if err := xml.NewDecoder(r).Decode(&core); err != nil {
+		return "", err
+	}
+	return core.Title, nil
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+// Package extract turns documents (plain text, HTML, PDF or DOCX) into the
+// plain text that is chunked and embedded into the internal corpus, or
+// summarized when a search result links to one.
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// ErrUnsupported is returned for content types that cannot be extracted
+var ErrUnsupported = errors.New("unsupported content type")
+
+// Document is the text extracted from a document. Title is empty unless the
+// document declares one (an HTML <title>, the PDF Info dictionary or the
+// DOCX core properties).
+type Document struct {
+	Title string
+	Text  string
+	// Truncated is set when a limit stopped extraction; Text holds what was
+	// read up to it
+	Truncated bool
+}
+
+// Limits bound the work of extracting a document, whose size the caller
+// has already checked. Zero means unlimited.
+type Limits struct {
+	// MaxPages is how many PDF pages or DOCX pages (counted by their
+	// explicit page breaks) are read. PDF pages are counted by their text content
+	// streams, which most exporters write one per page.
+	MaxPages int
+	// MaxInflatedBytes bounds the bytes decompressed from PDF streams and
+	// DOCX parts, so a small compressed document cannot expand without end
+	MaxInflatedBytes int64
+}
+
+// Text extracts the text of data according to its MIME type, as detected
+// by Detect
+func Text(contentType string, data []byte, limits Limits) (*Document, error) {
+	mediaType, err := Detect(contentType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc *Document
+	switch mediaType {
+	case "text/plain", "text/markdown":
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("text is not valid UTF-8")
+		}
+		doc = &Document{Text: string(data)}
+	case "text/html", "application/xhtml+xml":
+		doc, err = htmlText(data)
+	case "application/pdf":
+		doc, err = pdfText(data, limits)
+	case docxType:
+		doc, err = docxText(data, limits)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, mediaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Title = strings.TrimSpace(doc.Title)
+	doc.Text = strings.TrimSpace(doc.Text)
+	if doc.Text == "" {
+		return nil, fmt.Errorf("no text found in %s document", mediaType)
+	}
+	return doc, nil
+}
+
+// skippedElements hold no readable text
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true, "head": true,
+}
+
+// blockElements end a line of text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "pre": true, "blockquote": true,
+}
+
+// htmlText keeps the visible text of an HTML page, one line per block element
+func htmlText(data []byte) (*Document, error) {
+	doc := &Document{}
+	var text strings.Builder
+	skipping := 0 // depth inside skipped elements
+	inTitle := false
+
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, fmt.Errorf("failed to parse HTML: %w", err)
+			}
+			doc.Text = text.String()
+			return doc, nil
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case tag == "title":
+				inTitle = true
+			case skippedElements[tag]:
+				skipping++
+			case blockElements[tag]:
+				text.WriteByte('\n')
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case tag == "title":
+				inTitle = false
+			case skippedElements[tag] && skipping > 0:
+				skipping--
+			case blockElements[tag]:
+				text.WriteByte('\n')
+			}
+		case html.SelfClosingTagToken:
+			if name, _ := z.TagName(); blockElements[string(name)] {
+				text.WriteByte('\n')
+			}
+		case html.TextToken:
+			if inTitle {
+				doc.Title += string(z.Text())
+				continue
+			}
+			if skipping == 0 {
+				text.Write(z.Text())
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// pdfText extracts the text drawn by the page content streams of a PDF.
+// It handles uncompressed and FlateDecode streams whose fonts use a single
+// byte encoding or UTF-16 strings, which covers most text-based exports;
+// scanned pages and CID-keyed fonts without a usable encoding yield no text.
+// Streams past the limits are not read and mark the document truncated.
+func pdfText(data []byte, limits Limits) (*Document, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF document")
+	}
+	doc := &Document{}
+	if m := pdfTitle.FindSubmatch(data); m != nil {
+		doc.Title = decodePDFString(m[1])
+	}
+
+	var text strings.Builder
+	pages := 0
+	inflated := limits.MaxInflatedBytes
+	for _, stream := range pdfStreams(data) {
+		if limits.MaxPages > 0 && pages == limits.MaxPages {
+			doc.Truncated = true
+			break
+		}
+		content, ok := decodeStream(stream.dict, stream.data, inflated)
+		if !ok {
+			continue
+		}
+		if bytes.Contains(content, []byte("BT")) {
+			showText(&text, content)
+			text.WriteByte('\n')
+			pages++
+		}
+		if limits.MaxInflatedBytes > 0 {
+			if inflated -= int64(len(content)); inflated <= 0 {
+				doc.Truncated = true
+				break
+			}
+		}
+	}
+	doc.Text = text.String()
+	if strings.TrimSpace(doc.Text) == "" {
+		return nil, fmt.Errorf("PDF has no extractable text (scanned pages or unsupported font encoding)")
+	}
+	return doc, nil
+}
+
+// pdfTitle finds a literal /Title string in the document information dictionary
+var pdfTitle = regexp.MustCompile(`/Title\s*\(((?:\\.|[^\\)])*)\)`)
+
+type pdfStream struct {
+	dict []byte
+	data []byte
+}
+
+// pdfStreams returns every stream object with the dictionary before it
+func pdfStreams(data []byte) []pdfStream {
+	var streams []pdfStream
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			return streams
+		}
+		start := pos + i + len("stream")
+		// "endstream" also contains "stream"; skip it
+		if pos+i >= 3 && bytes.HasSuffix(data[:pos+i], []byte("end")) {
+			pos = start
+			continue
+		}
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		dictStart := bytes.LastIndex(data[:pos+i], []byte("obj"))
+		if dictStart < 0 {
+			dictStart = 0
+		}
+		streams = append(streams, pdfStream{dict: data[dictStart : pos+i], data: data[start : start+end]})
+		pos = start + end + len("endstream")
+	}
+}
+
+// decodeStream inflates a content stream, to at most max bytes when
+// positive. Fonts, images, XMP metadata and streams with filters other than
+// FlateDecode are skipped.
+func decodeStream(dict, data []byte, max int64) ([]byte, bool) {
+	for _, skip := range []string{"/Length1", "/Length2", "/Length3", "/Subtype", "/Type /XRef", "/Type/XRef", "/Type /ObjStm", "/Type/ObjStm", "/Metadata"} {
+		if bytes.Contains(dict, []byte(skip)) {
+			return nil, false
+		}
+	}
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return data, true
+	}
+	if bytes.Count(dict, []byte("Decode")) != 1 || !bytes.Contains(dict, []byte("/FlateDecode")) {
+		return nil, false
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	defer r.Close()
+	var src io.Reader = r
+	if max > 0 {
+		src = io.LimitReader(r, max)
+	}
+	out, err := io.ReadAll(src)
+	if err != nil && len(out) == 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+// showText writes the strings shown by Tj, TJ, ' and " operators, starting a
+// new line on line moves
+func showText(w *strings.Builder, content []byte) {
+	var operands [][]byte // pending string operands
+	var array [][]byte    // strings inside a TJ array
+	inArray := false
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next := literalString(content, i)
+			if inArray {
+				array = append(array, s)
+			} else {
+				operands = append(operands, s)
+			}
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			// Inline dictionary, not a hex string
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return
+			}
+			s := hexString(content[i+1 : i+end])
+			if inArray {
+				array = append(array, s)
+			} else {
+				operands = append(operands, s)
+			}
+			i += end + 1
+		case c == '[':
+			inArray, array = true, nil
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '%':
+			// Comment to end of line
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isPDFLetter(c) || c == '\'' || c == '"':
+			start := i
+			for i < len(content) && (isPDFLetter(content[i]) || content[i] == '*' || content[i] == '\'' || content[i] == '"') {
+				i++
+			}
+			switch string(content[start:i]) {
+			case "Tj":
+				writeStrings(w, operands)
+			case "TJ":
+				writeStrings(w, array)
+				array = nil
+			case "'", "\"":
+				w.WriteByte('\n')
+				writeStrings(w, operands)
+			case "T*", "Td", "TD", "ET":
+				w.WriteByte('\n')
+			}
+			operands = operands[:0]
+		default:
+			i++
+		}
+	}
+}
+
+func isPDFLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func writeStrings(w *strings.Builder, strs [][]byte) {
+	for _, s := range strs {
+		w.WriteString(decodePDFString(s))
+	}
+}
+
+// literalString parses a (...) string starting at content[i], returning its
+// bytes and the index after the closing parenthesis
+func literalString(content []byte, i int) ([]byte, int) {
+	var out []byte
+	depth := 0
+	for i++; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(content) {
+				return out, i
+			}
+			switch e := content[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(content) && j < i+3 && content[j] >= '0' && content[j] <= '7' {
+						j++
+					}
+					v, _ := strconv.ParseUint(string(content[i:j]), 8, 8)
+					out = append(out, byte(v))
+					i = j - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth == 0 {
+				return out, i + 1
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out, i
+}
+
+func hexString(h []byte) []byte {
+	var digits []byte
+	for _, c := range h {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// decodePDFString reads UTF-16BE strings (with a byte order mark) and treats
+// anything else as a single-byte Latin-1 compatible encoding
+func decodePDFString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
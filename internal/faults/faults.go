@@ -0,0 +1,226 @@
+// Package faults injects latency, errors and dropped streams into gRPC calls so
+// circuit breakers, timeouts and fallbacks can be exercised in tests and
+// staging. Faults come from two places: rules registered on an Injector (the
+// gateway exposes them on /admin/faults) and X-Fault request headers, which the
+// gateway forwards as gRPC metadata to the backend services. Nothing here is
+// wired up unless config.FaultInjectionEnabled() is true.
+package faults
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Fault describes what to inject into matching RPCs
+type Fault struct {
+	Method      string  `json:"method"`                // full method, method or service name; "" or "*" matches every RPC
+	LatencyMs   int     `json:"latency_ms,omitempty"`  // delay before the call proceeds
+	Code        string  `json:"code,omitempty"`        // gRPC status code to fail with, e.g. "unavailable"
+	Message     string  `json:"message,omitempty"`     // error message; defaults to "injected fault"
+	DropAfter   int     `json:"drop_after,omitempty"`  // streams: fail with Code after this many messages
+	Probability float64 `json:"probability,omitempty"` // chance of applying the fault; 0 means always
+}
+
+// Parse reads a fault from its header form, e.g.
+// "method=Summarize;latency_ms=500;code=unavailable;probability=0.5"
+func Parse(spec string) (Fault, error) {
+	var f Fault
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Fault{}, fmt.Errorf("invalid fault field %q", part)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "method":
+			f.Method = value
+		case "latency_ms":
+			f.LatencyMs, err = strconv.Atoi(value)
+		case "code":
+			f.Code = value
+		case "message":
+			f.Message = value
+		case "drop_after":
+			f.DropAfter, err = strconv.Atoi(value)
+		case "probability":
+			f.Probability, err = strconv.ParseFloat(value, 64)
+		default:
+			return Fault{}, fmt.Errorf("unknown fault field %q", key)
+		}
+		if err != nil {
+			return Fault{}, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+	}
+	return f, f.Validate()
+}
+
+// Validate checks the fault can be applied
+func (f Fault) Validate() error {
+	if f.LatencyMs < 0 || f.DropAfter < 0 {
+		return fmt.Errorf("latency_ms and drop_after must not be negative")
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("probability must be between 0 and 1")
+	}
+	if f.Code != "" {
+		if _, err := parseCode(f.Code); err != nil {
+			return err
+		}
+	} else if f.DropAfter > 0 {
+		return fmt.Errorf("drop_after requires a code")
+	}
+	if f.LatencyMs == 0 && f.Code == "" {
+		return fmt.Errorf("fault injects nothing: set latency_ms or code")
+	}
+	return nil
+}
+
+// Matches reports whether the fault targets the given full gRPC method
+// ("/search.SearchService/Search")
+func (f Fault) Matches(fullMethod string) bool {
+	m := strings.Trim(f.Method, "/")
+	if m == "" || m == "*" {
+		return true
+	}
+	trimmed := strings.TrimPrefix(fullMethod, "/")
+	service, method, _ := strings.Cut(trimmed, "/")
+	return m == trimmed || m == method || m == service || strings.HasSuffix(service, "."+m)
+}
+
+// err returns the injected status error, or nil for latency-only faults
+func (f Fault) err() error {
+	if f.Code == "" {
+		return nil
+	}
+	code, _ := parseCode(f.Code)
+	msg := f.Message
+	if msg == "" {
+		msg = "injected fault"
+	}
+	return status.Error(code, msg)
+}
+
+// parseCode accepts gRPC code names in any case, with or without underscores
+// ("unavailable", "DEADLINE_EXCEEDED", "ResourceExhausted")
+func parseCode(name string) (codes.Code, error) {
+	want := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == want {
+			if c == codes.OK {
+				break
+			}
+			return c, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown or non-error gRPC code %q", name)
+}
+
+// Injector holds the fault rules for one service
+type Injector struct {
+	service string
+	mu      sync.RWMutex
+	rules   []Fault
+}
+
+// NewInjector creates an injector with no rules; service labels metrics
+func NewInjector(service string) *Injector {
+	return &Injector{service: service}
+}
+
+// Rules returns a copy of the active rules
+func (i *Injector) Rules() []Fault {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]Fault(nil), i.rules...)
+}
+
+// Add validates and registers a rule
+func (i *Injector) Add(f Fault) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = append(i.rules, f)
+	return nil
+}
+
+// Clear removes every rule
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = nil
+}
+
+// selectFaults returns the faults from candidates that target the method and
+// win their probability roll
+func selectFaults(fullMethod string, candidates []Fault) []Fault {
+	var selected []Fault
+	for _, f := range candidates {
+		if !f.Matches(fullMethod) {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			continue
+		}
+		selected = append(selected, f)
+	}
+	return selected
+}
+
+// plan is the combined effect of the faults selected for one call
+type plan struct {
+	latency   time.Duration
+	err       error // returned before the call proceeds
+	dropErr   error // returned once dropAfter messages have been exchanged
+	dropAfter int
+}
+
+func newPlan(faults []Fault) plan {
+	var p plan
+	for _, f := range faults {
+		p.latency += time.Duration(f.LatencyMs) * time.Millisecond
+		switch {
+		case f.Code != "" && f.DropAfter > 0:
+			if p.dropErr == nil || f.DropAfter < p.dropAfter {
+				p.dropErr, p.dropAfter = f.err(), f.DropAfter
+			}
+		case f.Code != "" && p.err == nil:
+			p.err = f.err()
+		}
+	}
+	return p
+}
+
+// before applies the latency and immediate error of the plan
+func (p plan) before(ctx context.Context, recordFn func(kind string)) error {
+	if p.latency > 0 {
+		recordFn("latency")
+		timer := time.NewTimer(p.latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	}
+	if p.err != nil {
+		recordFn("error")
+		return p.err
+	}
+	return nil
+}
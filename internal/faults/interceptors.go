@@ -0,0 +1,162 @@
+package faults
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// HeaderName is the HTTP header carrying per-request faults; it may repeat
+const HeaderName = "X-Fault"
+
+// metadataKey carries per-request faults from the gateway to backend services
+const metadataKey = "x-fault"
+
+// OutgoingContext forwards the X-Fault headers of an HTTP request as gRPC
+// metadata so the backend services apply them
+func OutgoingContext(ctx context.Context, header http.Header) context.Context {
+	specs := header.Values(HeaderName)
+	if len(specs) == 0 {
+		return ctx
+	}
+	kv := make([]string, 0, 2*len(specs))
+	for _, spec := range specs {
+		kv = append(kv, metadataKey, spec)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// fromIncoming parses the faults forwarded in request metadata; invalid specs
+// are logged and skipped
+func fromIncoming(ctx context.Context) []Fault {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	var faults []Fault
+	for _, spec := range md.Get(metadataKey) {
+		f, err := Parse(spec)
+		if err != nil {
+			logger.GetLogger().Warnf("Ignoring invalid fault %q: %v", spec, err)
+			continue
+		}
+		faults = append(faults, f)
+	}
+	return faults
+}
+
+// ServerOptions returns the interceptors that apply forwarded faults on a
+// backend gRPC server, or nil when fault injection is disabled
+func ServerOptions(cfg *config.Config, service string) []grpc.ServerOption {
+	if !cfg.FaultInjectionEnabled() {
+		return nil
+	}
+	logger.GetLogger().Warnf("Fault injection enabled for %s", service)
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(service)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(service)),
+	}
+}
+
+// UnaryServerInterceptor applies faults forwarded in request metadata
+func UnaryServerInterceptor(service string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p := newPlan(selectFaults(info.FullMethod, fromIncoming(ctx)))
+		if err := p.before(ctx, recorder(service, info.FullMethod)); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor applies faults forwarded in request metadata; drops
+// fail the stream after the configured number of sent messages
+func StreamServerInterceptor(service string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		record := recorder(service, info.FullMethod)
+		p := newPlan(selectFaults(info.FullMethod, fromIncoming(ss.Context())))
+		if err := p.before(ss.Context(), record); err != nil {
+			return err
+		}
+		if p.dropErr != nil {
+			ss = &droppingServerStream{ServerStream: ss, plan: p, record: record}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// UnaryClientInterceptor applies the injector's rules to outgoing calls
+func (i *Injector) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p := newPlan(selectFaults(method, i.Rules()))
+		if err := p.before(ctx, recorder(i.service, method)); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor applies the injector's rules to outgoing streams;
+// drops fail the stream after the configured number of received messages
+func (i *Injector) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		record := recorder(i.service, method)
+		p := newPlan(selectFaults(method, i.Rules()))
+		if err := p.before(ctx, record); err != nil {
+			return nil, err
+		}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil || p.dropErr == nil {
+			return cs, err
+		}
+		return &droppingClientStream{ClientStream: cs, plan: p, record: record}, nil
+	}
+}
+
+func recorder(service, method string) func(kind string) {
+	return func(kind string) {
+		logger.GetLogger().Debugf("Injecting %s fault into %s", kind, method)
+		monitoring.RecordFaultInjected(service, method, kind)
+	}
+}
+
+type droppingServerStream struct {
+	grpc.ServerStream
+	plan   plan
+	record func(kind string)
+	sent   int
+}
+
+func (s *droppingServerStream) SendMsg(m interface{}) error {
+	if s.sent >= s.plan.dropAfter {
+		s.record("drop")
+		return s.plan.dropErr
+	}
+	s.sent++
+	return s.ServerStream.SendMsg(m)
+}
+
+type droppingClientStream struct {
+	grpc.ClientStream
+	plan     plan
+	record   func(kind string)
+	received int
+}
+
+func (s *droppingClientStream) RecvMsg(m interface{}) error {
+	if s.received >= s.plan.dropAfter {
+		s.record("drop")
+		return s.plan.dropErr
+	}
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.received++
+	return nil
+}
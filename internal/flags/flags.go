@@ -0,0 +1,354 @@
+// Package flags rolls features out gradually. A flag turns its feature on
+// for a percentage of traffic and always for the tenants and API keys it
+// targets; turning it off is a kill switch. Flags come from config and can
+// be overridden at runtime, the overrides kept in Redis so every service
+// picks them up without a deploy. The gateway evaluates flags for the
+// requests it serves and forwards who a request runs as to the backend
+// services as gRPC metadata, so they evaluate the same flags the same way.
+package flags
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/metadata"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// Built-in features under flags. A custom pipeline stage is flagged by its
+// name, so a new stage such as citations can be dark-launched by
+// defining a flag of that name.
+const (
+	KnowledgePanel = "knowledge_panel" // extracting the knowledge panel
+	PolishRelated  = "polish_related"  // the model rewording related searches
+	Prefetch       = "prefetch"        // prefetching the results of related searches
+	PrefixCache    = "prefix_cache"    // the orchestrator's instruction prefix hint
+	Rerank         = "rerank"          // reordering results by their relevance
+)
+
+// Flag is a feature's rollout
+type Flag struct {
+	Name    string  `json:"name"`
+	Enabled bool    `json:"enabled"` // false turns the feature off for everyone
+	Percent float64 `json:"percent"` // share of traffic it is on for, 0 to 100
+	// Tenants and API keys the feature is always on for. Keys are given in
+	// APIKeys and kept only as their SHA-256 hashes in KeyHashes.
+	Tenants   []string `json:"tenants,omitempty"`
+	APIKeys   []string `json:"api_keys,omitempty"`
+	KeyHashes []string `json:"key_hashes,omitempty"`
+	// Source is where the flag comes from in listings: config or override
+	Source string `json:"source,omitempty"`
+}
+
+// Validate checks the flag and replaces its API keys by their hashes
+func (f *Flag) Validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if f.Percent < 0 || f.Percent > 100 {
+		return fmt.Errorf("percent of flag %s must be between 0 and 100", f.Name)
+	}
+	for _, key := range f.APIKeys {
+		f.KeyHashes = append(f.KeyHashes, HashKey(key))
+	}
+	f.APIKeys = nil
+	return nil
+}
+
+// on decides the flag for a subject
+func (f *Flag) on(s Subject) bool {
+	if !f.Enabled {
+		return false
+	}
+	for _, tenant := range f.Tenants {
+		if s.Tenant != "" && tenant == s.Tenant {
+			return true
+		}
+	}
+	for _, hash := range f.KeyHashes {
+		if s.Key != "" && hash == s.Key {
+			return true
+		}
+	}
+	if f.Percent >= 100 {
+		return true
+	}
+	if s.Bucket == "" {
+		return rand.Float64()*100 < f.Percent
+	}
+	// Hashing the flag with the bucket keeps a caller's answer stable while
+	// spreading the callers differently for each flag
+	h := fnv.New32a()
+	h.Write([]byte(f.Name + "/" + s.Bucket))
+	return float64(h.Sum32()%10000) < f.Percent*100
+}
+
+// Subject is who a request runs as
+type Subject struct {
+	Tenant string
+	Key    string // SHA-256 hash of the API key; empty without one
+	// Bucket places the subject in percentage rollouts: the key hash, or
+	// the client address without a key. Empty decides each request anew.
+	Bucket string
+}
+
+// HashKey returns the hash flags target an API key by
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Metadata keys carrying the subject from the gateway to backend services
+const (
+	metadataTenant = "x-flag-tenant"
+	metadataKey    = "x-flag-key"
+	metadataBucket = "x-flag-bucket"
+)
+
+// OutgoingContext forwards the subject as gRPC metadata
+func OutgoingContext(ctx context.Context, s Subject) context.Context {
+	kv := make([]string, 0, 6)
+	for _, pair := range [][2]string{{metadataTenant, s.Tenant}, {metadataKey, s.Key}, {metadataBucket, s.Bucket}} {
+		if pair[1] != "" {
+			kv = append(kv, pair[0], pair[1])
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// IncomingSubject reads the subject forwarded in request metadata
+func IncomingSubject(ctx context.Context) Subject {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Subject{}
+	}
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	return Subject{Tenant: first(metadataTenant), Key: first(metadataKey), Bucket: first(metadataBucket)}
+}
+
+// Store keeps the runtime overrides
+type Store interface {
+	Set(ctx context.Context, flag Flag) error
+	Delete(ctx context.Context, name string) (found bool, err error)
+	All(ctx context.Context) (map[string]Flag, error)
+}
+
+// Flags evaluates the configured flags with their overrides applied
+type Flags struct {
+	defaults  map[string]Flag
+	overrides Store
+
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// New loads the configured flags. Overrides are kept in Redis when client
+// is not nil and reloaded each feature_flags.refresh_interval, and else in
+// memory for this process only.
+func New(cfg *config.Config, client *redis.Client) (*Flags, error) {
+	f := &Flags{defaults: make(map[string]Flag)}
+	for _, flagCfg := range cfg.FeatureFlags.Flags {
+		flag := Flag{
+			Name:    flagCfg.Name,
+			Enabled: flagCfg.Enabled,
+			Percent: flagCfg.Percent,
+			Tenants: flagCfg.Tenants,
+			APIKeys: flagCfg.APIKeys,
+			Source:  "config",
+		}
+		if err := flag.Validate(); err != nil {
+			return nil, fmt.Errorf("feature flag: %w", err)
+		}
+		if _, dup := f.defaults[flag.Name]; dup {
+			return nil, fmt.Errorf("feature flag %s is defined twice", flag.Name)
+		}
+		f.defaults[flag.Name] = flag
+	}
+
+	if client != nil {
+		f.overrides = &redisStore{client: client}
+	} else {
+		f.overrides = &memoryStore{flags: make(map[string]Flag)}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := f.reload(ctx); err != nil {
+		logger.GetLogger().Warnf("Failed to load feature flag overrides, using the configured flags: %v", err)
+		f.flags = f.defaults
+	}
+	if client != nil && cfg.FeatureFlags.RefreshInterval > 0 {
+		go f.refresh(cfg.FeatureFlags.RefreshInterval)
+	}
+	return f, nil
+}
+
+// Enabled reports whether the feature is on for the subject. Features
+// without a flag are on, so their own configuration decides.
+func (f *Flags) Enabled(name string, s Subject) bool {
+	if f == nil {
+		return true
+	}
+	f.mu.RLock()
+	flag, ok := f.flags[name]
+	f.mu.RUnlock()
+	if !ok {
+		return true
+	}
+	on := flag.on(s)
+	monitoring.RecordFeatureFlag(name, on)
+	return on
+}
+
+// List returns the flags in effect, sorted by name
+func (f *Flags) List() []Flag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	list := make([]Flag, 0, len(f.flags))
+	for _, flag := range f.flags {
+		list = append(list, flag)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Override replaces a flag, or defines a new one, until the override is
+// removed
+func (f *Flags) Override(ctx context.Context, flag Flag) (Flag, error) {
+	if err := flag.Validate(); err != nil {
+		return Flag{}, err
+	}
+	flag.Source = "override"
+	if err := f.overrides.Set(ctx, flag); err != nil {
+		return Flag{}, err
+	}
+	return flag, f.reload(ctx)
+}
+
+// RemoveOverride restores a flag to its configuration, or removes a flag
+// defined only by an override
+func (f *Flags) RemoveOverride(ctx context.Context, name string) (bool, error) {
+	found, err := f.overrides.Delete(ctx, name)
+	if err != nil || !found {
+		return found, err
+	}
+	return true, f.reload(ctx)
+}
+
+// reload applies the stored overrides to the configured flags
+func (f *Flags) reload(ctx context.Context) error {
+	overrides, err := f.overrides.All(ctx)
+	if err != nil {
+		return err
+	}
+	flags := make(map[string]Flag, len(f.defaults)+len(overrides))
+	for name, flag := range f.defaults {
+		flags[name] = flag
+	}
+	for name, flag := range overrides {
+		flags[name] = flag
+	}
+	f.mu.Lock()
+	f.flags = flags
+	f.mu.Unlock()
+	return nil
+}
+
+// refresh reloads the overrides other replicas made
+func (f *Flags) refresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := f.reload(ctx); err != nil {
+			logger.GetLogger().Warnf("Failed to reload feature flag overrides: %v", err)
+		}
+		cancel()
+	}
+}
+
+// redisFlagsKey is the hash of overrides, one JSON-encoded flag per field
+const redisFlagsKey = "feature_flags"
+
+type redisStore struct {
+	client *redis.Client
+}
+
+func (s *redisStore) Set(ctx context.Context, flag Flag) error {
+	data, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, redisFlagsKey, flag.Name, data).Err()
+}
+
+func (s *redisStore) Delete(ctx context.Context, name string) (bool, error) {
+	n, err := s.client.HDel(ctx, redisFlagsKey, name).Result()
+	return n > 0, err
+}
+
+func (s *redisStore) All(ctx context.Context) (map[string]Flag, error) {
+	fields, err := s.client.HGetAll(ctx, redisFlagsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	flags := make(map[string]Flag, len(fields))
+	for name, data := range fields {
+		var flag Flag
+		if err := json.Unmarshal([]byte(data), &flag); err != nil {
+			logger.GetLogger().Warnf("Ignoring invalid feature flag override %s: %v", name, err)
+			continue
+		}
+		flags[name] = flag
+	}
+	return flags, nil
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	flags map[string]Flag
+}
+
+func (s *memoryStore) Set(ctx context.Context, flag Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[flag.Name] = flag
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, found := s.flags[name]
+	delete(s.flags, name)
+	return found, nil
+}
+
+func (s *memoryStore) All(ctx context.Context) (map[string]Flag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flags := make(map[string]Flag, len(s.flags))
+	for name, flag := range s.flags {
+		flags[name] = flag
+	}
+	return flags, nil
+}
@@ -0,0 +1,653 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/objectstore"
+	"ai-search-service/internal/pipeline"
+	pb "ai-search-service/proto"
+)
+
+// BatchJob is the progress of a file of queries summarized in the
+// background, served by GET /api/v1/batch/:id
+type BatchJob struct {
+	ID         string    `json:"id"`
+	Tenant     string    `json:"tenant"`
+	Owner      string    `json:"owner,omitempty"` // the logged-in user who submitted it, or key:<id> of the API key
+	Status     string    `json:"status"`          // queued, running, completed or failed (every query failed)
+	Total      int       `json:"total"`
+	Completed  int       `json:"completed"`
+	Failed     int       `json:"failed"`
+	Progress   float64   `json:"progress"`              // share of the queries run, 0..1
+	ResultsURL string    `json:"results_url,omitempty"` // once finished
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BatchItem is one query of a batch job and, once run, its outcome; the
+// results file has one per line, in the order of the input
+type BatchItem struct {
+	Line       int            `json:"line"`
+	ID         string         `json:"id,omitempty"` // the input's id, to match results to queries
+	Query      string         `json:"query"`
+	NumResults int            `json:"num_results,omitempty"`
+	SafeSearch bool           `json:"safe_search,omitempty"`
+	Status     string         `json:"status"` // pending, completed or failed
+	Summary    string         `json:"summary,omitempty"`
+	Results    []SearchResult `json:"results,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// BatchStore keeps batch jobs and their queries for batch.job_ttl
+type BatchStore interface {
+	Save(ctx context.Context, job *BatchJob) error
+	// Get returns found=false for unknown or expired job IDs
+	Get(ctx context.Context, id string) (job *BatchJob, found bool, err error)
+	SaveItems(ctx context.Context, jobID string, items ...*BatchItem) error
+	// Items returns a job's queries in the order of the input
+	Items(ctx context.Context, jobID string) ([]*BatchItem, error)
+	// EraseUser deletes the jobs a user of the tenant submitted, returning
+	// how many it deleted
+	EraseUser(ctx context.Context, tenant, user string) (int, error)
+}
+
+// batching runs batch jobs, at most batch.max_running at once
+type batching struct {
+	config config.BatchConfig
+	store  BatchStore
+	slots  chan struct{}
+}
+
+// newBatching returns nil when batch jobs are disabled
+func newBatching(cfg *config.Config, client *redis.Client) *batching {
+	batchCfg := cfg.Gateway.Batch
+	if !batchCfg.Enabled {
+		return nil
+	}
+	b := &batching{config: batchCfg, slots: make(chan struct{}, max(batchCfg.MaxRunning, 1))}
+	if client != nil {
+		b.store = &redisBatchStore{client: client, ttl: batchCfg.JobTTL}
+	} else {
+		b.store = &memoryBatchStore{jobs: lru.New[string, *memoryBatch]("gateway_batch_jobs", cfg.Gateway.MemoryStoreEntries, batchCfg.JobTTL)}
+	}
+	return b
+}
+
+// Formats of batch files
+const (
+	batchJSONL = "jsonl"
+	batchCSV   = "csv"
+)
+
+// batchFormat tells the format of a batch file from its content type or
+// file name; "" when it is neither JSONL nor CSV
+func batchFormat(contentType, filename string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return batchJSONL
+	case "text/csv":
+		return batchCSV
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".ndjson":
+		return batchJSONL
+	case ".csv":
+		return batchCSV
+	}
+	return ""
+}
+
+// parseBatch reads the queries of a batch file: JSONL lines are objects
+// with query and optional id, num_results and safe_search, or bare query
+// strings; CSV files name the same columns in a header row
+func parseBatch(format string, body io.Reader, maxQueries int) ([]*BatchItem, error) {
+	var items []*BatchItem
+	add := func(line int, item *BatchItem) error {
+		item.Query = strings.TrimSpace(item.Query)
+		if item.Query == "" {
+			return fmt.Errorf("line %d: query must not be empty", line)
+		}
+		if maxQueries > 0 && len(items) == maxQueries {
+			return fmt.Errorf("a batch may hold at most %d queries", maxQueries)
+		}
+		item.Line, item.Status = line, "pending"
+		items = append(items, item)
+		return nil
+	}
+
+	if format == batchCSV {
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("line 1: %w", err)
+		}
+		columns := make(map[string]int)
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF")))] = i
+		}
+		if _, ok := columns["query"]; !ok {
+			return nil, errors.New("line 1: the header row has no query column")
+		}
+		for line := 2; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			field := func(name string) string {
+				if i, ok := columns[name]; ok && i < len(record) {
+					return strings.TrimSpace(record[i])
+				}
+				return ""
+			}
+			item := &BatchItem{Query: field("query"), ID: field("id")}
+			if value := field("num_results"); value != "" {
+				if item.NumResults, err = strconv.Atoi(value); err != nil || item.NumResults < 0 {
+					return nil, fmt.Errorf("line %d: num_results must be a number of at least 0", line)
+				}
+			}
+			if value := field("safe_search"); value != "" {
+				if item.SafeSearch, err = strconv.ParseBool(value); err != nil {
+					return nil, fmt.Errorf("line %d: safe_search must be true or false", line)
+				}
+			}
+			if err := add(line, item); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			item := &BatchItem{}
+			var err error
+			if strings.HasPrefix(text, `"`) {
+				err = json.Unmarshal([]byte(text), &item.Query)
+			} else {
+				err = json.Unmarshal([]byte(text), item)
+			}
+			if err != nil || item.NumResults < 0 {
+				return nil, fmt.Errorf("line %d: expected a query string or an object with query, id, num_results and safe_search", line)
+			}
+			if err := add(line, item); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("the file holds no queries")
+	}
+	return items, nil
+}
+
+// SubmitBatch accepts a JSONL (application/x-ndjson) or CSV (text/csv)
+// file of queries, as the body or the file field of a form, and summarizes
+// them in the background as the calling tenant. The response carries the
+// job ID to poll; GET /api/v1/batch/:id/results downloads the summaries
+// once it finished.
+func (g *Gateway) SubmitBatch(c *gin.Context) {
+	if g.batches == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch jobs are disabled"})
+		return
+	}
+	cfg := g.batches.config
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxFileBytes+64<<10) // room for the form's envelope
+
+	body, format := io.Reader(c.Request.Body), batchFormat(c.GetHeader("Content-Type"), "")
+	if c.ContentType() == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Batch files may be at most %d bytes", cfg.MaxFileBytes)})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Send the queries in the file field"})
+			return
+		}
+		defer file.Close()
+		body, format = io.LimitReader(file, cfg.MaxFileBytes+1), batchFormat(header.Header.Get("Content-Type"), header.Filename)
+	}
+	if format == "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Send the queries as JSONL (application/x-ndjson) or CSV (text/csv)"})
+		return
+	}
+
+	items, err := parseBatch(format, body, cfg.MaxQueries)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Batch files may be at most %d bytes", cfg.MaxFileBytes)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch file: " + err.Error()})
+		return
+	}
+	for _, item := range items {
+		if max, tooLong := g.checkQueryLength(item.Query); tooLong {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid batch file: line %d: query exceeds %d characters", item.Line, max)})
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	tenant := g.tenant(c)
+	job := &BatchJob{
+		ID:        "batch_" + randomToken(12),
+		Tenant:    tenant.ID,
+		Owner:     g.dataSubject(c),
+		Status:    "queued",
+		Total:     len(items),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	ctx := c.Request.Context()
+	err = g.batches.store.SaveItems(ctx, job.ID, items...)
+	if err == nil {
+		err = g.batches.store.Save(ctx, job)
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to store batch job: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the batch job"})
+		return
+	}
+
+	go g.runBatch(tenant, job, items)
+
+	c.Header("Location", "/api/v1/batch/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":  job.ID,
+		"status":  job.Status,
+		"queries": job.Total,
+	})
+}
+
+// GetBatch returns the progress of a batch job of the tenant
+func (g *Gateway) GetBatch(c *gin.Context) {
+	if job := g.batchJob(c); job != nil {
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// BatchResults downloads the outcome of every query of a finished batch
+// job as JSONL, in the order of the input; with gateway.exports.storage it
+// is stored and answered with a signed URL like exports
+func (g *Gateway) BatchResults(c *gin.Context) {
+	job := g.batchJob(c)
+	if job == nil {
+		return
+	}
+	if job.ResultsURL == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "The batch job has not finished", "status": job.Status, "progress": job.Progress})
+		return
+	}
+	items, err := g.batches.store.Items(c.Request.Context(), job.ID)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load the results of batch job %s: %v", job.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the results"})
+		return
+	}
+
+	filename := job.ID + ".jsonl"
+	if g.objects != nil {
+		reader, writer := io.Pipe()
+		go func() { writer.CloseWithError(writeBatchResults(writer, items)) }()
+		defer reader.Close()
+		g.storeObject(c, job.Tenant+"/"+filename, objectstore.Download{Filename: filename, ContentType: "application/x-ndjson"}, reader)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	if err := writeBatchResults(c.Writer, items); err != nil {
+		logger.GetLogger().Warnf("Failed to send the results of batch job %s: %v", job.ID, err)
+	}
+}
+
+func writeBatchResults(w io.Writer, items []*BatchItem) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchJob loads the batch job named by the request, answering 404 when it
+// is unknown, expired or another tenant's
+func (g *Gateway) batchJob(c *gin.Context) *BatchJob {
+	if g.batches == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch jobs are disabled"})
+		return nil
+	}
+	job, found, err := g.batches.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load batch job %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the batch job"})
+		return nil
+	}
+	if !found || job.Tenant != g.tenant(c).ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No batch job with this ID"})
+		return nil
+	}
+	return job
+}
+
+// runBatch waits for a free slot, then runs the job's queries
+// batch.max_concurrent at a time, saving each outcome and the job's
+// progress as it goes
+func (g *Gateway) runBatch(tenant *Tenant, job *BatchJob, items []*BatchItem) {
+	log := logger.GetLogger()
+	var mu sync.Mutex
+	save := func(item *BatchItem) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if item != nil {
+			if err := g.batches.store.SaveItems(ctx, job.ID, item); err != nil {
+				log.Warnf("Failed to store line %d of batch job %s: %v", item.Line, job.ID, err)
+			}
+		}
+		job.UpdatedAt = time.Now().UTC()
+		job.Progress = float64(job.Completed+job.Failed) / float64(job.Total)
+		if err := g.batches.store.Save(ctx, job); err != nil {
+			log.Warnf("Failed to update batch job %s: %v", job.ID, err)
+		}
+	}
+
+	g.batches.slots <- struct{}{}
+	defer func() { <-g.batches.slots }()
+	job.Status = "running"
+	save(nil)
+
+	sem := make(chan struct{}, max(g.batches.config.MaxConcurrent, 1))
+	var wg sync.WaitGroup
+	for _, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item *BatchItem) {
+			defer func() { <-sem; wg.Done() }()
+			g.runBatchItem(tenant, job, item)
+			monitoring.RecordBatchQuery(item.Status)
+			mu.Lock()
+			defer mu.Unlock()
+			if item.Status == "completed" {
+				job.Completed++
+			} else {
+				job.Failed++
+			}
+			save(item)
+		}(item)
+	}
+	wg.Wait()
+
+	job.Status = "completed"
+	if job.Failed == job.Total {
+		job.Status = "failed"
+	}
+	job.ResultsURL = "/api/v1/batch/" + job.ID + "/results"
+	save(nil)
+	log.Infof("Batch job %s %s: %d completed, %d failed", job.ID, job.Status, job.Completed, job.Failed)
+}
+
+// runBatchItem searches one query of a batch as its tenant. A per-minute
+// quota that runs out is waited for; longer windows fail the query.
+func (g *Gateway) runBatchItem(tenant *Tenant, job *BatchJob, item *BatchItem) {
+	for {
+		message, retryAfter, exceeded := g.chargeTenantQuota(context.Background(), tenant)
+		if !exceeded {
+			break
+		}
+		if retryAfter > 60 {
+			item.Status, item.Error = "failed", message
+			return
+		}
+		time.Sleep(time.Duration(retryAfter) * time.Second)
+	}
+
+	emit := &collectEmitter{}
+	g.pipeline.Run(g.batchRequest(tenant, job, item), emit)
+	item.Results, item.Summary = emit.results, emit.summary
+	if emit.err != nil {
+		item.Status, item.Error = "failed", emit.err.Message
+		return
+	}
+	item.Status = "completed"
+}
+
+// batchRequest describes the search of a batch query; its prompt record is
+// kept under the job ID and line
+func (g *Gateway) batchRequest(tenant *Tenant, job *BatchJob, item *BatchItem) *pipeline.Request {
+	req := &pipeline.Request{
+		Query:      item.Query,
+		SafeSearch: item.SafeSearch,
+		NumResults: g.clampTenantNumResults(tenant, item.NumResults),
+		ClientIP:   "batch",
+		Mode:       "batch",
+		MaxTokens:  150,
+		Snippets:   g.snippets,
+		Router:     g.modelRouter,
+		StageContext: func(stage pipeline.StageName) (context.Context, context.CancelFunc) {
+			if timeout := g.stageTimeout(stage); timeout > 0 {
+				return context.WithTimeout(context.Background(), timeout)
+			}
+			return context.WithCancel(context.Background())
+		},
+	}
+	g.applyReranking(req, flags.Subject{Tenant: tenant.ID})
+	req.CompressWords = g.compressWords()
+	if prompts := g.prompts; prompts != nil {
+		requestID := fmt.Sprintf("%s:%d", job.ID, item.Line)
+		req.Audit = func(llmReq *pb.LLMRequest, generated, served string, err error) {
+			prompts.record(g.promptRecord(requestID, tenant.ID, job.Owner, req.Mode, item.Query, llmReq, generated, served, err))
+		}
+	}
+	tenant.apply(req)
+	return req
+}
+
+type redisBatchStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func redisBatchKey(id string) string {
+	return "batch:job:" + id
+}
+
+func redisBatchItemsKey(id string) string {
+	return "batch:items:" + id
+}
+
+func (s *redisBatchStore) Save(ctx context.Context, job *BatchJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisBatchKey(job.ID), data, s.ttl).Err()
+}
+
+func (s *redisBatchStore) Get(ctx context.Context, id string) (*BatchJob, bool, error) {
+	data, err := s.client.Get(ctx, redisBatchKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var job BatchJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, false, fmt.Errorf("invalid batch job: %w", err)
+	}
+	return &job, true, nil
+}
+
+// SaveItems keeps the items in a hash by line, expiring with the job
+func (s *redisBatchStore) SaveItems(ctx context.Context, jobID string, items ...*BatchItem) error {
+	values := make([]interface{}, 0, 2*len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		values = append(values, strconv.Itoa(item.Line), data)
+	}
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, redisBatchItemsKey(jobID), values...)
+	pipe.Expire(ctx, redisBatchItemsKey(jobID), s.ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisBatchStore) Items(ctx context.Context, jobID string) ([]*BatchItem, error) {
+	values, err := s.client.HGetAll(ctx, redisBatchItemsKey(jobID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*BatchItem, 0, len(values))
+	for _, data := range values {
+		var item BatchItem
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			return nil, fmt.Errorf("invalid batch item: %w", err)
+		}
+		items = append(items, &item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Line < items[j].Line })
+	return items, nil
+}
+
+func (s *redisBatchStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return scanRedis(ctx, s.client, redisBatchKey("*"), func(key string) (int, error) {
+		data, err := s.client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var job BatchJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			return 0, fmt.Errorf("invalid batch job: %w", err)
+		}
+		if job.Tenant != tenant || job.Owner != user {
+			return 0, nil
+		}
+		if err := s.client.Del(ctx, key, redisBatchItemsKey(job.ID)).Err(); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	})
+}
+
+type memoryBatch struct {
+	mu    sync.Mutex
+	job   BatchJob
+	items map[int]BatchItem
+}
+
+type memoryBatchStore struct {
+	jobs *lru.Cache[string, *memoryBatch]
+	mu   sync.Mutex // creating a job's entry
+}
+
+func (s *memoryBatchStore) entry(id string) *memoryBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch, ok := s.jobs.Get(id)
+	if !ok {
+		batch = &memoryBatch{job: BatchJob{ID: id}, items: make(map[int]BatchItem)}
+		s.jobs.Add(id, batch)
+	}
+	return batch
+}
+
+func (s *memoryBatchStore) Save(ctx context.Context, job *BatchJob) error {
+	batch := s.entry(job.ID)
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	batch.job = *job
+	return nil
+}
+
+func (s *memoryBatchStore) Get(ctx context.Context, id string) (*BatchJob, bool, error) {
+	batch, ok := s.jobs.Get(id)
+	if !ok {
+		return nil, false, nil
+	}
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	if batch.job.Status == "" {
+		return nil, false, nil // items stored ahead of the job
+	}
+	job := batch.job
+	return &job, true, nil
+}
+
+func (s *memoryBatchStore) SaveItems(ctx context.Context, jobID string, items ...*BatchItem) error {
+	batch := s.entry(jobID)
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	for _, item := range items {
+		saved := *item
+		saved.Results = append([]SearchResult(nil), item.Results...)
+		batch.items[item.Line] = saved
+	}
+	return nil
+}
+
+func (s *memoryBatchStore) Items(ctx context.Context, jobID string) ([]*BatchItem, error) {
+	batch, ok := s.jobs.Get(jobID)
+	if !ok {
+		return nil, nil
+	}
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	items := make([]*BatchItem, 0, len(batch.items))
+	for _, item := range batch.items {
+		item := item
+		items = append(items, &item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Line < items[j].Line })
+	return items, nil
+}
+
+func (s *memoryBatchStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return s.jobs.RemoveFunc(func(id string, batch *memoryBatch) bool {
+		batch.mu.Lock()
+		defer batch.mu.Unlock()
+		return batch.job.Tenant == tenant && batch.job.Owner == user
+	}), nil
+}
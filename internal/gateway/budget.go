@@ -0,0 +1,50 @@
+package gateway
+
+import (
+	"fmt"
+
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// BudgetReport is the "budget_exceeded" outcome returned when a request hit
+// one of its cost ceilings and work was skipped
+type BudgetReport struct {
+	Outcome string   `json:"outcome"`
+	Limits  []string `json:"limits"`
+	Skipped []string `json:"skipped"`
+}
+
+// capFetchedPages lowers the number of search results to the budget
+func (g *Gateway) capFetchedPages(numResults int) (int, *BudgetReport) {
+	limit := g.config.Budget.MaxFetchedPages
+	if limit <= 0 || numResults <= limit {
+		return numResults, nil
+	}
+	monitoring.RecordBudgetExceeded("gateway", "max_fetched_pages")
+	return limit, &BudgetReport{
+		Outcome: "budget_exceeded",
+		Limits:  []string{"max_fetched_pages"},
+		Skipped: []string{fmt.Sprintf("fetched %d of %d requested results", limit, numResults)},
+	}
+}
+
+// merge adds the limits the LLM orchestrator hit; either side may be nil
+func (r *BudgetReport) merge(outcome *pb.BudgetOutcome) *BudgetReport {
+	if outcome == nil || len(outcome.Limits) == 0 {
+		return r
+	}
+	if r == nil {
+		r = &BudgetReport{Outcome: "budget_exceeded"}
+	}
+	r.Limits = append(r.Limits, outcome.Limits...)
+	r.Skipped = append(r.Skipped, outcome.Skipped...)
+	return r
+}
+
+// sendBudget emits the budget_exceeded event ahead of completion, if any limit was hit
+func (s *eventStream) sendBudget(report *BudgetReport) {
+	if report != nil {
+		s.send("budget_exceeded", report)
+	}
+}
@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+)
+
+// responseCacheKey holds the key a JSON search's response is cached under
+const responseCacheKey = "response_cache_key"
+
+// CacheControl is a tenant's response cache policy in seconds, named after
+// Cache-Control's max-age and stale-while-revalidate. A zero max_age
+// disables caching.
+type CacheControl struct {
+	MaxAge               int `json:"max_age"`
+	StaleWhileRevalidate int `json:"stale_while_revalidate"`
+}
+
+// cachedResponse is a completed search response and when it was stored. The
+// task ID is not kept; every request served from it gets its own.
+type cachedResponse struct {
+	response SearchResponse
+	stored   time.Time
+}
+
+// responseCache keeps completed JSON search responses for repeated queries,
+// serving them fresh, then stale while one background run refreshes them
+type responseCache struct {
+	config  config.ResponseCacheConfig
+	entries *lru.Cache[string, *cachedResponse]
+
+	mu         sync.Mutex
+	refreshing map[string]bool // keys being refreshed in the background
+}
+
+// newResponseCache returns nil when the response cache is disabled
+func newResponseCache(cfg *config.Config) *responseCache {
+	cacheCfg := cfg.Gateway.ResponseCache
+	if !cacheCfg.Enabled {
+		return nil
+	}
+	return &responseCache{
+		config:     cacheCfg,
+		entries:    lru.New[string, *cachedResponse]("gateway_responses", cacheCfg.MaxEntries, 0),
+		refreshing: make(map[string]bool),
+	}
+}
+
+// policy returns how long the tenant's responses are fresh and then stale
+func (rc *responseCache) policy(tenant *Tenant) (maxAge, stale time.Duration) {
+	if tenant.Cache != nil {
+		return time.Duration(tenant.Cache.MaxAge) * time.Second, time.Duration(tenant.Cache.StaleWhileRevalidate) * time.Second
+	}
+	return rc.config.MaxAge, rc.config.StaleWhileRevalidate
+}
+
+// cachedJSON answers a JSON search from the response cache when it holds a
+// fresh response, or a stale one within stale_while_revalidate, which is
+// then refreshed in the background. Otherwise the pipeline runs and its
+// response is cached. Requests with Cache-Control: no-cache skip the lookup.
+func (g *Gateway) cachedJSON(c *gin.Context, key, query string, safeSearch bool, numResults int) {
+	rc := g.responses
+	if rc == nil {
+		g.processNonStreamingJSON(c, query, safeSearch, numResults)
+		return
+	}
+	maxAge, stale := rc.policy(g.tenant(c))
+	if maxAge <= 0 {
+		g.processNonStreamingJSON(c, query, safeSearch, numResults)
+		return
+	}
+
+	if entry, ok := rc.entries.Get(key); ok && !strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache") {
+		switch age := time.Since(entry.stored); {
+		case age < maxAge:
+			g.serveCached(c, entry, age, "hit")
+			return
+		case age < maxAge+stale:
+			g.serveCached(c, entry, age, "stale")
+			g.refreshCached(c, key, query, safeSearch, numResults)
+			return
+		}
+	}
+	monitoring.RecordResponseCache("miss")
+	c.Header("X-Cache", "MISS")
+	c.Set(responseCacheKey, key)
+	g.processNonStreamingJSON(c, query, safeSearch, numResults)
+}
+
+// serveCached sends a cached response, recorded as the requester's own search
+func (g *Gateway) serveCached(c *gin.Context, entry *cachedResponse, age time.Duration, outcome string) {
+	monitoring.RecordResponseCache(outcome)
+	resp := entry.response
+	resp.TaskID = g.searchCompleted(c, newStreamID(), resp.Query, resp.SearchResults, taskSummary(resp.Summary, resp.Structured))
+	c.Header("Age", strconv.Itoa(int(age.Seconds())))
+	c.Header("X-Cache", strings.ToUpper(outcome))
+	c.JSON(http.StatusOK, resp)
+}
+
+// refreshCached reruns a stale search in the background, with the tenant,
+// locale and sampling of the request that found it stale, and replaces the
+// cached response on success. One refresh runs per key at a time.
+func (g *Gateway) refreshCached(c *gin.Context, key, query string, safeSearch bool, numResults int) {
+	rc := g.responses
+	rc.mu.Lock()
+	if rc.refreshing[key] {
+		rc.mu.Unlock()
+		return
+	}
+	rc.refreshing[key] = true
+	rc.mu.Unlock()
+
+	background := detached(c)
+	background.Set(responseCacheKey, key)
+	background.Set(streamIDKey, newStreamID()) // the refresh is a search of its own
+	go func() {
+		defer func() {
+			rc.mu.Lock()
+			delete(rc.refreshing, key)
+			rc.mu.Unlock()
+		}()
+		numResults, budget := g.capFetchedPages(numResults)
+		emit := &jsonEmitter{g: g, c: background, budget: budget, background: true}
+		req := g.pipelineRequest(background, "refresh", query, safeSearch, numResults)
+		req.Prefetch, req.Shadow = nil, nil
+		g.pipeline.Run(req, emit)
+	}()
+}
+
+// cacheResponse stores a completed response when the request's search is
+// being cached
+func (g *Gateway) cacheResponse(c *gin.Context, resp *SearchResponse) {
+	key, ok := c.Get(responseCacheKey)
+	if !ok || g.responses == nil {
+		return
+	}
+	entry := &cachedResponse{response: *resp, stored: time.Now()}
+	entry.response.TaskID = ""
+	g.responses.entries.Add(key.(string), entry)
+}
+
+// expire drops the responses stored before the given time, returning how
+// many it dropped
+func (rc *responseCache) expire(before time.Time) int {
+	return rc.entries.RemoveFunc(func(key string, entry *cachedResponse) bool {
+		return entry.stored.Before(before)
+	})
+}
+
+// refreshFailed records a background refresh that kept the stale response
+func refreshFailed(query string, err string) {
+	logger.GetLogger().Warnf("Failed to refresh the cached response for %q: %s", query, err)
+	monitoring.RecordResponseCache("refresh_failed")
+}
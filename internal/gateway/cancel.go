@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+const (
+	// streamCancelKey is the gin context key carrying the running stream's
+	// *cancellableStream
+	streamCancelKey = "stream_cancel"
+
+	// streamCancelChannel relays cancels to the replica running the stream,
+	// as "<stream ID>/<tenant>"
+	streamCancelChannel = "ai_search:stream_cancel"
+
+	// streamOwnerTTL bounds how long a crashed replica's streams still
+	// accept cancels
+	streamOwnerTTL = time.Hour
+)
+
+func redisStreamOwnerKey(streamID string) string {
+	return "ai_search:stream_owner:" + streamID
+}
+
+// streamCancels tracks the streaming searches this replica runs so a client
+// can stop one with POST /api/v1/search/:task_id/cancel. With Redis, each
+// stream's tenant is also kept under its ID and cancels for streams of other
+// replicas are relayed to them.
+type streamCancels struct {
+	mu      sync.Mutex
+	streams map[string]*cancellableStream
+	redis   *redis.Client // nil when only this replica's streams can be cancelled
+}
+
+// cancellableStream is the base context of one stream's backend calls
+type cancellableStream struct {
+	tenant    string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	cancelled atomic.Bool
+}
+
+func newStreamCancels(client *redis.Client) *streamCancels {
+	s := &streamCancels{streams: make(map[string]*cancellableStream), redis: client}
+	if client != nil {
+		go s.listen(context.Background())
+	}
+	return s
+}
+
+// track makes the stream cancellable until the returned function is called.
+// The stream's backend calls derive from its context (see requestContext),
+// so a cancel aborts the one in flight, down to the inference backend.
+func (s *streamCancels) track(c *gin.Context, tenant, id string) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &cancellableStream{tenant: tenant, ctx: ctx, cancel: cancel}
+	c.Set(streamCancelKey, stream)
+
+	s.mu.Lock()
+	s.streams[id] = stream
+	s.mu.Unlock()
+	if s.redis != nil {
+		if err := s.redis.Set(ctx, redisStreamOwnerKey(id), tenant, streamOwnerTTL).Err(); err != nil {
+			logger.GetLogger().Warnf("Failed to register stream %s for cancellation: %v", id, err)
+		}
+	}
+
+	return func() {
+		s.mu.Lock()
+		delete(s.streams, id)
+		s.mu.Unlock()
+		if s.redis != nil {
+			s.redis.Del(context.Background(), redisStreamOwnerKey(id))
+		}
+		cancel()
+	}
+}
+
+// cancelLocal cancels the tenant's stream if this replica runs it
+func (s *streamCancels) cancelLocal(tenant, id string) bool {
+	s.mu.Lock()
+	stream, ok := s.streams[id]
+	s.mu.Unlock()
+	if !ok || stream.tenant != tenant {
+		return false
+	}
+	stream.cancelled.Store(true)
+	stream.cancel()
+	return true
+}
+
+// forward relays a cancel to the replica running the tenant's stream,
+// reporting whether any replica does
+func (s *streamCancels) forward(ctx context.Context, tenant, id string) (bool, error) {
+	owner, err := s.redis.Get(ctx, redisStreamOwnerKey(id)).Result()
+	if err == redis.Nil || (err == nil && owner != tenant) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, s.redis.Publish(ctx, streamCancelChannel, id+"/"+tenant).Err()
+}
+
+// listen cancels the streams other replicas relay cancels for
+func (s *streamCancels) listen(ctx context.Context) {
+	sub := s.redis.Subscribe(ctx, streamCancelChannel)
+	defer sub.Close()
+	for msg := range sub.Channel() {
+		id, tenant, _ := strings.Cut(msg.Payload, "/")
+		if s.cancelLocal(tenant, id) {
+			logger.GetLogger().Infof("Cancelled stream %s on request of another replica", id)
+		}
+	}
+}
+
+// streamCancelled reports whether the request's stream was cancelled by
+// its client
+func streamCancelled(c *gin.Context) bool {
+	if value, ok := c.Get(streamCancelKey); ok {
+		return value.(*cancellableStream).cancelled.Load()
+	}
+	return false
+}
+
+// streamBaseContext is the context a request's backend calls derive from:
+// its stream's, when it is cancellable
+func streamBaseContext(c *gin.Context) context.Context {
+	if value, ok := c.Get(streamCancelKey); ok {
+		return value.(*cancellableStream).ctx
+	}
+	return context.Background()
+}
+
+// detached is the request with backend calls that outlive a cancel of its
+// stream and its client leaving, for keeping what the stream had produced
+func detached(c *gin.Context) *gin.Context {
+	copied := c.Copy()
+	delete(copied.Keys, streamCancelKey)
+	copied.Request = c.Request.WithContext(context.WithoutCancel(c.Request.Context()))
+	return copied
+}
+
+// CancelSearch stops a streaming search of the tenant. Its backend calls
+// are aborted, freeing their capacity, and the stream ends with a
+// "cancelled" event instead of the summary. A stream another replica runs is
+// cancelled by that replica, so the request is only accepted.
+func (g *Gateway) CancelSearch(c *gin.Context) {
+	id := c.Param("task_id")
+	tenant := g.tenant(c).ID
+
+	if g.cancels.cancelLocal(tenant, id) {
+		monitoring.RecordStreamCancellation("cancelled")
+		logger.GetLogger().Infof("Stream %s cancelled by the client", id)
+		c.JSON(http.StatusOK, gin.H{"request_id": id, "status": "cancelled"})
+		return
+	}
+	if g.cancels.redis != nil {
+		found, err := g.cancels.forward(c.Request.Context(), tenant, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel the search"})
+			return
+		}
+		if found {
+			monitoring.RecordStreamCancellation("forwarded")
+			c.JSON(http.StatusAccepted, gin.H{"request_id": id, "status": "cancelling"})
+			return
+		}
+	}
+	monitoring.RecordStreamCancellation("not_found")
+	c.JSON(http.StatusNotFound, gin.H{"error": "No running search with this ID"})
+}
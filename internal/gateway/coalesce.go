@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/monitoring"
+)
+
+// streamIDKey is the gin context key carrying a pre-assigned SSE stream ID
+const streamIDKey = "stream_id"
+
+// coalescer collapses identical concurrent searches onto one pipeline run.
+// SSE followers read the leader's events from the event buffer; JSON
+// followers get a copy of the leader's response.
+type coalescer struct {
+	mu      sync.Mutex
+	streams map[string]string  // coalescing key -> stream ID of the running SSE pipeline
+	flights map[string]*flight // coalescing key -> running JSON pipeline
+}
+
+// newCoalescer returns nil when request coalescing is disabled
+func newCoalescer(cfg *config.Config) *coalescer {
+	if !cfg.Gateway.Coalescing.Enabled {
+		return nil
+	}
+	return &coalescer{streams: make(map[string]string), flights: make(map[string]*flight)}
+}
+
+// coalesceKey identifies requests that produce the same output: the tenant,
+// mode, parameters and variant (see coalesceVariant) must match and queries
+// are compared case- and space-insensitively
+func coalesceKey(tenant *Tenant, mode, query string, safeSearch bool, numResults int, variant string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	return fmt.Sprintf("%s|%s|%t|%d|%s|%s", tenant.ID, mode, safeSearch, numResults, variant, normalized)
+}
+
+func (co *coalescer) streaming(key, streamID string) bool {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	return co.streams[key] == streamID
+}
+
+// coalesceStream runs the SSE pipeline for the first request with key; while
+// it runs, identical requests follow its buffered events instead
+func (g *Gateway) coalesceStream(c *gin.Context, mode, key string, run func()) {
+	co := g.coalescer
+	if co == nil || g.eventBuffer == nil {
+		run()
+		return
+	}
+
+	co.mu.Lock()
+	if streamID, ok := co.streams[key]; ok {
+		co.mu.Unlock()
+		monitoring.RecordCoalescedRequest("gateway", mode, "follower")
+		g.followStream(c, streamID, 0, func() bool { return co.streaming(key, streamID) })
+		return
+	}
+	streamID := newStreamID()
+	co.streams[key] = streamID
+	co.mu.Unlock()
+
+	defer func() {
+		co.mu.Lock()
+		delete(co.streams, key)
+		co.mu.Unlock()
+	}()
+
+	monitoring.RecordCoalescedRequest("gateway", mode, "leader")
+	c.Set(streamIDKey, streamID)
+	run()
+}
+
+// coalesceJSON runs the JSON pipeline for the first request with key; identical
+// requests arriving meanwhile receive a copy of its response
+func (g *Gateway) coalesceJSON(c *gin.Context, key string, run func(c *gin.Context)) {
+	co := g.coalescer
+	if co == nil {
+		run(c)
+		return
+	}
+
+	co.mu.Lock()
+	if f, ok := co.flights[key]; ok {
+		co.mu.Unlock()
+		monitoring.RecordCoalescedRequest("gateway", "json", "follower")
+		f.replayTo(c)
+		return
+	}
+	f := &flight{done: make(chan struct{}), resp: &CachedResponse{Status: http.StatusInternalServerError}}
+	co.flights[key] = f
+	co.mu.Unlock()
+
+	defer func() {
+		co.mu.Lock()
+		delete(co.flights, key)
+		co.mu.Unlock()
+		close(f.done)
+	}()
+
+	monitoring.RecordCoalescedRequest("gateway", "json", "leader")
+	f.resp = record(c, run)
+}
@@ -0,0 +1,361 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/extract"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// DocumentRequest is one document to ingest into the internal corpus. Text
+// formats go in Content; binary formats such as PDF go base64-encoded in Data.
+type DocumentRequest struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"` // text/plain (default), text/html, application/pdf or DOCX
+	Content     string `json:"content,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+// BulkDocumentRequest ingests several documents as one job
+type BulkDocumentRequest struct {
+	Documents []DocumentRequest `json:"documents" binding:"required"`
+}
+
+// IngestionJob is the status of an ingestion request, served by
+// GET /api/v1/documents/jobs/:id
+type IngestionJob struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"` // pending, running, completed or failed (every document failed)
+	Documents  []DocumentStatus `json:"documents"`
+	Ingested   int              `json:"ingested"`
+	Duplicates int              `json:"duplicates"`
+	Failed     int              `json:"failed"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// DocumentStatus is the outcome of one document in a job
+type DocumentStatus struct {
+	Title       string `json:"title"`
+	URL         string `json:"url,omitempty"`
+	Status      string `json:"status"` // pending, ingested, duplicate or failed
+	ContentHash string `json:"content_hash,omitempty"`
+	Chunks      int32  `json:"chunks,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Truncated   bool   `json:"truncated,omitempty"` // only the pages within the ingestion limits were read
+
+	text string // extracted text waiting to be ingested
+}
+
+// JobStore persists ingestion job status keyed by job ID
+type JobStore interface {
+	Save(ctx context.Context, job *IngestionJob) error
+	// Get returns found=false for unknown or expired job IDs
+	Get(ctx context.Context, id string) (job *IngestionJob, found bool, err error)
+}
+
+// newJobStore returns the configured store, or nil when ingestion is disabled
+func newJobStore(cfg *config.Config, client *redis.Client) JobStore {
+	ingestCfg := cfg.Gateway.Ingestion
+	if !ingestCfg.Enabled {
+		return nil
+	}
+	if client != nil {
+		return &redisJobStore{client: client, ttl: ingestCfg.JobTTL}
+	}
+	return &memoryJobStore{jobs: lru.New[string, IngestionJob]("gateway_ingestion_jobs", cfg.Gateway.MemoryStoreEntries, ingestCfg.JobTTL)}
+}
+
+type redisJobStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func redisJobKey(id string) string {
+	return "ingest:job:" + id
+}
+
+func (s *redisJobStore) Save(ctx context.Context, job *IngestionJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisJobKey(job.ID), data, s.ttl).Err()
+}
+
+func (s *redisJobStore) Get(ctx context.Context, id string) (*IngestionJob, bool, error) {
+	data, err := s.client.Get(ctx, redisJobKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var job IngestionJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, false, fmt.Errorf("invalid ingestion job: %w", err)
+	}
+	return &job, true, nil
+}
+
+type memoryJobStore struct {
+	jobs *lru.Cache[string, IngestionJob]
+}
+
+func (s *memoryJobStore) Save(ctx context.Context, job *IngestionJob) error {
+	saved := *job
+	saved.Documents = append([]DocumentStatus(nil), job.Documents...)
+	s.jobs.Add(job.ID, saved)
+	return nil
+}
+
+func (s *memoryJobStore) Get(ctx context.Context, id string) (*IngestionJob, bool, error) {
+	job, ok := s.jobs.Get(id)
+	if !ok {
+		return nil, false, nil
+	}
+	return &job, true, nil
+}
+
+// IngestDocument accepts one document, either as a JSON DocumentRequest or
+// as a raw text/plain, text/html or application/pdf body with title and url
+// query parameters. Ingestion runs in the background; the response carries
+// the job ID to poll.
+func (g *Gateway) IngestDocument(c *gin.Context) {
+	if !g.ingestionAvailable(c) {
+		return
+	}
+	limit := int64(g.config.Gateway.Ingestion.MaxDocumentBytes)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit*4/3+4096) // base64 in JSON
+
+	var doc DocumentRequest
+	if c.ContentType() == "application/json" {
+		if !bindJSON(c, &doc) {
+			return
+		}
+	} else {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Document too large"})
+			return
+		}
+		doc = DocumentRequest{Title: c.Query("title"), URL: c.Query("url"), ContentType: c.GetHeader("Content-Type"), Data: data}
+	}
+
+	status := g.extractDocument(doc)
+	if status.Status == "failed" {
+		code := http.StatusBadRequest
+		if strings.Contains(status.Error, extract.ErrUnsupported.Error()) {
+			code = http.StatusUnsupportedMediaType
+		}
+		c.JSON(code, gin.H{"error": status.Error})
+		return
+	}
+	g.startIngestion(c, []DocumentStatus{status})
+}
+
+// IngestDocuments accepts a BulkDocumentRequest as one job. Documents that
+// cannot be extracted are marked failed without failing the others.
+func (g *Gateway) IngestDocuments(c *gin.Context) {
+	if !g.ingestionAvailable(c) {
+		return
+	}
+	ingestCfg := g.config.Gateway.Ingestion
+	limit := int64(ingestCfg.MaxDocumentBytes) * int64(ingestCfg.MaxBulkDocuments)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit*4/3+4096)
+
+	var req BulkDocumentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if len(req.Documents) == 0 || len(req.Documents) > ingestCfg.MaxBulkDocuments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Send between 1 and %d documents", ingestCfg.MaxBulkDocuments)})
+		return
+	}
+
+	docs := make([]DocumentStatus, len(req.Documents))
+	for i, doc := range req.Documents {
+		docs[i] = g.extractDocument(doc)
+	}
+	g.startIngestion(c, docs)
+}
+
+// GetIngestionJob returns the status of an ingestion job
+func (g *Gateway) GetIngestionJob(c *gin.Context) {
+	if g.jobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document ingestion is disabled"})
+		return
+	}
+	job, found, err := g.jobs.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load ingestion job %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load ingestion job"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No ingestion job with this ID"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (g *Gateway) ingestionAvailable(c *gin.Context) bool {
+	if g.jobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document ingestion is disabled"})
+		return false
+	}
+	if !g.config.VectorStore.Enabled {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No vector store is configured"})
+		return false
+	}
+	return true
+}
+
+// extractDocument turns a request into plain text, marking it failed if the
+// content type is unsupported or no text could be extracted
+func (g *Gateway) extractDocument(doc DocumentRequest) DocumentStatus {
+	status := DocumentStatus{Title: doc.Title, URL: doc.URL, Status: "pending"}
+	fail := func(msg string) DocumentStatus {
+		status.Status, status.Error = "failed", msg
+		monitoring.RecordDocumentIngested("gateway", "failed")
+		return status
+	}
+
+	data := doc.Data
+	if len(data) == 0 {
+		data = []byte(doc.Content)
+	}
+	if len(data) == 0 {
+		return fail("Document is empty")
+	}
+	if len(data) > g.config.Gateway.Ingestion.MaxDocumentBytes {
+		return fail(fmt.Sprintf("Document exceeds %d bytes", g.config.Gateway.Ingestion.MaxDocumentBytes))
+	}
+	contentType := doc.ContentType
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+
+	ingestCfg := g.config.Gateway.Ingestion
+	extracted, err := extract.Text(contentType, data, extract.Limits{MaxPages: ingestCfg.MaxPages, MaxInflatedBytes: ingestCfg.MaxInflatedBytes})
+	if err != nil {
+		return fail(err.Error())
+	}
+	if status.Title == "" {
+		status.Title = extracted.Title
+	}
+	if status.Title == "" {
+		status.Title = status.URL
+	}
+	status.text = extracted.Text
+	status.Truncated = extracted.Truncated
+	return status
+}
+
+// startIngestion stores a new job and ingests its documents in the background
+func (g *Gateway) startIngestion(c *gin.Context, docs []DocumentStatus) {
+	now := time.Now()
+	job := &IngestionJob{
+		ID:        fmt.Sprintf("ingest_%d", now.UnixNano()),
+		Status:    "pending",
+		Documents: docs,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, doc := range docs {
+		if doc.Status == "failed" {
+			job.Failed++
+		}
+	}
+	if err := g.jobs.Save(c.Request.Context(), job); err != nil {
+		logger.GetLogger().Errorf("Failed to store ingestion job: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start ingestion"})
+		return
+	}
+
+	go g.runIngestion(job)
+
+	c.Header("Location", "/api/v1/documents/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":    job.ID,
+		"status":    job.Status,
+		"documents": len(docs),
+	})
+}
+
+// runIngestion sends each extracted document to the search service, saving
+// the job after every document so progress can be polled
+func (g *Gateway) runIngestion(job *IngestionJob) {
+	log := logger.GetLogger()
+	save := func() {
+		job.UpdatedAt = time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := g.jobs.Save(ctx, job); err != nil {
+			log.Warnf("Failed to update ingestion job %s: %v", job.ID, err)
+		}
+	}
+
+	job.Status = "running"
+	save()
+
+	for i := range job.Documents {
+		doc := &job.Documents[i]
+		if doc.Status != "pending" {
+			continue
+		}
+		resp, err := g.ingestText(doc)
+		doc.text = ""
+		switch {
+		case err != nil:
+			doc.Status, doc.Error = "failed", status.Convert(err).Message()
+			job.Failed++
+		case resp.Duplicate:
+			doc.Status, doc.ContentHash = "duplicate", resp.ContentHash
+			job.Duplicates++
+		default:
+			doc.Status, doc.ContentHash, doc.Chunks = "ingested", resp.ContentHash, resp.Chunks
+			job.Ingested++
+		}
+		monitoring.RecordDocumentIngested("gateway", doc.Status)
+		save()
+	}
+
+	job.Status = "completed"
+	if job.Failed == len(job.Documents) {
+		job.Status = "failed"
+	}
+	save()
+	log.Infof("Ingestion job %s %s: %d ingested, %d duplicates, %d failed", job.ID, job.Status, job.Ingested, job.Duplicates, job.Failed)
+}
+
+func (g *Gateway) ingestText(doc *DocumentStatus) (*pb.IngestDocumentResponse, error) {
+	ctx := context.Background()
+	cancel := context.CancelFunc(func() {})
+	if timeout := g.config.Services.Search.Timeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	resp, err := g.searchClient.IngestDocument(ctx, &pb.IngestDocumentRequest{Title: doc.Title, Url: doc.URL, Text: doc.text})
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("ingestion timed out")
+	}
+	return resp, err
+}
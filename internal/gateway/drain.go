@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// drainRetryAfter is the Retry-After sent to searches refused while draining,
+// by when a load balancer should have taken the replica out of rotation
+const drainRetryAfter = 5 * time.Second
+
+// drainer tracks in-flight searches so shutdown can let them finish. Once
+// draining, new searches are refused and streams in progress are told the
+// server is going away.
+type drainer struct {
+	mu       sync.Mutex
+	draining bool
+	deadline time.Time // by when in-flight searches must finish
+	inFlight sync.WaitGroup
+	active   atomic.Int64  // searches in flight, for capacity checks
+	started  chan struct{} // closed when draining begins
+}
+
+func newDrainer() *drainer {
+	return &drainer{started: make(chan struct{})}
+}
+
+// admit registers a search, returning false once draining has begun
+func (d *drainer) admit() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inFlight.Add(1)
+	d.active.Add(1)
+	return true
+}
+
+// done ends a search registered by admit
+func (d *drainer) done() {
+	d.active.Add(-1)
+	d.inFlight.Done()
+}
+
+// searching returns how many searches are in flight
+func (d *drainer) searching() int {
+	return int(d.active.Load())
+}
+
+// isDraining returns whether draining has begun and its deadline
+func (d *drainer) isDraining() (bool, time.Time) {
+	select {
+	case <-d.started:
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return true, d.deadline
+	default:
+		return false, time.Time{}
+	}
+}
+
+// Drain stops accepting searches and waits for those in flight until ctx is
+// done, returning ctx's error if some were still running. Call it before
+// shutting the HTTP server down: Shutdown closes idle connections at once
+// but would cut off event streams when its own deadline passes.
+func (g *Gateway) Drain(ctx context.Context) error {
+	d := g.drain
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		d.deadline, _ = ctx.Deadline()
+		close(d.started)
+	}
+	d.mu.Unlock()
+	logger.GetLogger().Info("Draining gateway: refusing new searches, waiting for those in flight")
+
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.GetLogger().Info("All in-flight searches finished")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackSearch counts a search as in flight until it is handled, answering
+// 503 with Retry-After once the gateway is draining
+func (g *Gateway) trackSearch(c *gin.Context) {
+	if !g.drain.admit() {
+		monitoring.RecordRequestRejected("draining")
+		monitoring.RecordRequest("gateway", "search", "rejected")
+		c.Header("Retry-After", strconv.Itoa(int(drainRetryAfter.Seconds())))
+		c.Header("Connection", "close")
+		message := "Server is shutting down, retry shortly"
+		if c.Request.Method == http.MethodGet || strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+			c.Status(http.StatusServiceUnavailable)
+			c.SSEvent("error", gin.H{"message": message})
+			c.Abort()
+		} else {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": message})
+		}
+		return
+	}
+	defer g.drain.done()
+	c.Next()
+}
+
+// noticeShutdown writes a server_shutting_down event to a stream once, when
+// draining has begun. The event carries no ID and is not buffered: it is
+// about this connection, not the stream a reconnect would resume.
+func (g *Gateway) noticeShutdown(c *gin.Context, sent *bool) {
+	if *sent {
+		return
+	}
+	draining, deadline := g.drain.isDraining()
+	if !draining {
+		return
+	}
+	*sent = true
+	data := gin.H{"message": "Server is shutting down; the search in progress will be allowed to finish"}
+	if !deadline.IsZero() {
+		data["deadline"] = deadline.Unix()
+	}
+	c.Render(-1, sse.Event{Event: "server_shutting_down", Data: data})
+	c.Writer.Flush()
+}
@@ -0,0 +1,262 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/store"
+)
+
+// openStore connects the configured store backend; nil without one. The
+// subsystems listed in store.subsystems keep their data in its repositories
+// through the adapters below, in place of Redis or memory.
+func openStore(cfg *config.Config) (*store.Store, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db, err := store.Open(ctx, cfg.Store)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		logger.GetLogger().Infof("Keeping %v in the %s store", cfg.Store.Subsystems, cfg.Store.Backend)
+	}
+	return db, nil
+}
+
+// decodeAll unmarshals stored JSON values of one type
+func decodeAll[T any](values [][]byte, kind string) ([]*T, error) {
+	out := make([]*T, 0, len(values))
+	for _, data := range values {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", kind, err)
+		}
+		out = append(out, &v)
+	}
+	return out, nil
+}
+
+// decodeOne unmarshals a stored JSON value when it was found
+func decodeOne[T any](data []byte, found bool, err error, kind string) (*T, bool, error) {
+	if err != nil || !found {
+		return nil, false, err
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, false, fmt.Errorf("invalid %s: %w", kind, err)
+	}
+	return &v, true, nil
+}
+
+// durableAuditLog keeps the admin audit log as the "audit:admin" event stream
+type durableAuditLog struct {
+	events store.Events
+	max    int
+	outbox *store.Store // with events, each entry is written with its admin.action event in one transaction
+}
+
+func (l *durableAuditLog) Append(ctx context.Context, entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if l.outbox == nil {
+		return l.append(ctx, l.events, entry, data)
+	}
+	event, err := newAdminActionEvent(entry)
+	if err != nil {
+		return err
+	}
+	err = l.outbox.Atomic(ctx, func(tx *store.Tx) error {
+		if err := l.append(ctx, tx.Events, entry, data); err != nil {
+			return err
+		}
+		return tx.Outbox.Add(ctx, event)
+	})
+	if err == nil {
+		monitoring.RecordOutboxEvents("written", 1)
+	}
+	return err
+}
+
+func (l *durableAuditLog) append(ctx context.Context, events store.Events, entry *AuditEntry, data []byte) error {
+	if err := events.Append(ctx, redisAuditKey, entry.Time, data); err != nil {
+		return err
+	}
+	if l.max > 0 {
+		_, err := events.Trim(ctx, redisAuditKey, l.max)
+		return err
+	}
+	return nil
+}
+
+func (l *durableAuditLog) Recent(ctx context.Context, limit int) ([]*AuditEntry, error) {
+	values, err := l.events.Recent(ctx, redisAuditKey, limit)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[AuditEntry](values, "audit entry")
+}
+
+func (l *durableAuditLog) Expire(ctx context.Context, before time.Time) (int, error) {
+	return l.events.Expire(ctx, redisAuditKey, before)
+}
+
+// durableQuotaCounter keeps the tenants' usage windows as counters
+type durableQuotaCounter struct {
+	counters store.Counters
+}
+
+func (q *durableQuotaCounter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return q.counters.Add(ctx, key, 1, ttl)
+}
+
+func (q *durableQuotaCounter) Count(ctx context.Context, key string) (int64, error) {
+	return q.counters.Get(ctx, key)
+}
+
+// durableTenantStore keeps tenants in the "tenants" collection, found by
+// "key:<hash>" and "subdomain:<subdomain>"
+type durableTenantStore struct {
+	records store.Records
+}
+
+func (s *durableTenantStore) Save(ctx context.Context, tenant *Tenant) error {
+	data, err := json.Marshal(tenant)
+	if err != nil {
+		return err
+	}
+	var lookups []string
+	for _, key := range tenant.Keys {
+		lookups = append(lookups, "key:"+key.Hash)
+	}
+	if tenant.Subdomain != "" {
+		lookups = append(lookups, "subdomain:"+tenant.Subdomain)
+	}
+	return s.records.Put(ctx, redisTenantsKey, tenant.ID, data, lookups...)
+}
+
+func (s *durableTenantStore) Get(ctx context.Context, id string) (*Tenant, bool, error) {
+	data, found, err := s.records.Get(ctx, redisTenantsKey, id)
+	return decodeOne[Tenant](data, found, err, "tenant")
+}
+
+func (s *durableTenantStore) ByKey(ctx context.Context, keyHash string) (*Tenant, bool, error) {
+	data, found, err := s.records.Find(ctx, redisTenantsKey, "key:"+keyHash)
+	return decodeOne[Tenant](data, found, err, "tenant")
+}
+
+func (s *durableTenantStore) BySubdomain(ctx context.Context, subdomain string) (*Tenant, bool, error) {
+	data, found, err := s.records.Find(ctx, redisTenantsKey, "subdomain:"+subdomain)
+	return decodeOne[Tenant](data, found, err, "tenant")
+}
+
+func (s *durableTenantStore) List(ctx context.Context) ([]*Tenant, error) {
+	values, err := s.records.List(ctx, redisTenantsKey)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[Tenant](values, "tenant")
+}
+
+func (s *durableTenantStore) Delete(ctx context.Context, id string) error {
+	_, err := s.records.Delete(ctx, redisTenantsKey, id)
+	return err
+}
+
+// durableQueryStore keeps saved queries in the "scheduler:queries"
+// collection, each query's runs as a "scheduler:runs:<id>" event stream and
+// claims as counters expiring with them
+type durableQueryStore struct {
+	records  store.Records
+	events   store.Events
+	counters store.Counters
+	outbox   *store.Store // with events, each run is written with its saved_query.run event in one transaction
+}
+
+func (s *durableQueryStore) SaveQuery(ctx context.Context, query *SavedQuery) error {
+	data, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	return s.records.Put(ctx, redisQueriesKey, query.ID, data)
+}
+
+func (s *durableQueryStore) GetQuery(ctx context.Context, id string) (*SavedQuery, bool, error) {
+	data, found, err := s.records.Get(ctx, redisQueriesKey, id)
+	return decodeOne[SavedQuery](data, found, err, "saved query")
+}
+
+func (s *durableQueryStore) ListQueries(ctx context.Context) ([]*SavedQuery, error) {
+	values, err := s.records.List(ctx, redisQueriesKey)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := decodeAll[SavedQuery](values, "saved query")
+	if err != nil {
+		return nil, err
+	}
+	sortQueries(queries)
+	return queries, nil
+}
+
+func (s *durableQueryStore) DeleteQuery(ctx context.Context, id string) error {
+	if _, err := s.records.Delete(ctx, redisQueriesKey, id); err != nil {
+		return err
+	}
+	return s.events.Drop(ctx, redisRunsKey(id))
+}
+
+func (s *durableQueryStore) AddRun(ctx context.Context, run *QueryRun, keep int) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if s.outbox == nil {
+		return addRun(ctx, s.events, run, data, keep)
+	}
+	event, err := newQueryRunEvent(run)
+	if err != nil {
+		return err
+	}
+	err = s.outbox.Atomic(ctx, func(tx *store.Tx) error {
+		if err := addRun(ctx, tx.Events, run, data, keep); err != nil {
+			return err
+		}
+		return tx.Outbox.Add(ctx, event)
+	})
+	if err == nil {
+		monitoring.RecordOutboxEvents("written", 1)
+	}
+	return err
+}
+
+func addRun(ctx context.Context, events store.Events, run *QueryRun, data []byte, keep int) error {
+	if err := events.Append(ctx, redisRunsKey(run.QueryID), run.StartedAt, data); err != nil {
+		return err
+	}
+	if keep > 0 {
+		_, err := events.Trim(ctx, redisRunsKey(run.QueryID), keep)
+		return err
+	}
+	return nil
+}
+
+func (s *durableQueryStore) Runs(ctx context.Context, queryID string) ([]*QueryRun, error) {
+	values, err := s.events.Recent(ctx, redisRunsKey(queryID), 0)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[QueryRun](values, "query run")
+}
+
+// Claim succeeds for the replica whose claim starts the counter
+func (s *durableQueryStore) Claim(ctx context.Context, queryID string, ttl time.Duration) (bool, error) {
+	claims, err := s.counters.Add(ctx, "scheduler:claim:"+queryID, 1, ttl)
+	return claims == 1, err
+}
@@ -0,0 +1,361 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+)
+
+// stageStatus is the SSE status type announced as each stage begins
+var stageStatus = map[pipeline.StageName]string{
+	pipeline.StageValidate:  "validating",
+	pipeline.StageSearch:    "searching",
+	pipeline.StageSummarize: "summarizing",
+}
+
+// pipelineRequest describes a search for the pipeline engine, with stage
+// contexts carrying this request's timeouts and fault headers
+func (g *Gateway) pipelineRequest(c *gin.Context, mode, query string, safeSearch bool, numResults int) *pipeline.Request {
+	req := &pipeline.Request{
+		Query:      query,
+		SafeSearch: safeSearch,
+		NumResults: numResults,
+		ClientIP:   g.clientIP(c),
+		Locale:     g.searchLocale(c),
+		Mode:       mode,
+		MaxTokens:  150,
+		Snippets:   g.snippets,
+		Router:     g.modelRouter,
+		StageContext: func(stage pipeline.StageName) (context.Context, context.CancelFunc) {
+			return g.stageContext(c, stage)
+		},
+	}
+	subject := g.flagSubject(c)
+	if g.config.Gateway.KnowledgePanel.Enabled && g.flags.Enabled(flags.KnowledgePanel, subject) {
+		req.PanelResults = g.config.Gateway.KnowledgePanel.MaxResults
+	}
+	if related := g.config.Gateway.RelatedSearches; related.Enabled {
+		req.RelatedSearches = related.Max
+		req.PolishRelated = related.Polish && g.flags.Enabled(flags.PolishRelated, subject)
+	}
+	g.applyReranking(req, subject)
+	req.CompressWords = g.compressWords()
+	if sampling := requestSampling(c); sampling != nil {
+		req.Sampling = sampling.proto()
+	}
+	if g.prefetcher != nil && g.flags.Enabled(flags.Prefetch, subject) {
+		req.Prefetch = g.prefetchHook(c, req)
+	}
+	req.StageEnabled = func(name string) bool { return g.flags.Enabled(name, subject) }
+	if g.shadow != nil && g.shadow.sampled() {
+		req.Shadow = g.shadowHook(c, query)
+	}
+	if g.prompts != nil {
+		req.Audit = g.auditHook(c, mode, query)
+	}
+	g.tenant(c).apply(req)
+	req.Safety = g.safetyPolicy(c)
+	return req
+}
+
+// compressWords is the length the snippets of summarization prompts are
+// compressed to, 0 when compression is disabled
+func (g *Gateway) compressWords() int {
+	if compression := g.config.Gateway.Snippets.Compression; compression.Enabled {
+		return compression.MaxWords
+	}
+	return 0
+}
+
+// searchCompleted records a finished search for suggestions and the user's
+// history, keeps it for export and publishes it, returning its task ID
+func (g *Gateway) searchCompleted(c *gin.Context, taskID, query string, results []SearchResult, summary string) string {
+	g.recordQuery(c, query)
+	taskID = g.saveTask(g.tenant(c), g.dataSubject(c), taskID, query, results, summary)
+	g.recordUserSearch(c, taskID, query, len(results))
+	g.publish(topicSearchCompleted, taskID, &searchCompletedEvent{
+		TaskID:     taskID,
+		Tenant:     g.tenant(c).ID,
+		Query:      query,
+		Results:    len(results),
+		Summarized: summary != "",
+	})
+	return taskID
+}
+
+// taskSummary is the summary kept with a completed search: the provider's
+// data when it replaced the summary
+func taskSummary(text string, structured *answers.Data) string {
+	if text == "" && structured != nil {
+		return structured.Text()
+	}
+	return text
+}
+
+// sseEmitter holds what the SSE emitters share: status, search result and
+// structured answer events
+type sseEmitter struct {
+	g          *Gateway
+	c          *gin.Context
+	events     *eventStream
+	query      string
+	budget     *BudgetReport // limits hit before the pipeline ran
+	results    []SearchResult
+	structured *answers.Data
+}
+
+func (g *Gateway) newSSEEmitter(c *gin.Context, budget *BudgetReport) sseEmitter {
+	return sseEmitter{g: g, c: c, events: g.newEventStream(c), budget: budget}
+}
+
+func (e *sseEmitter) Started(query string) {
+	e.query = query
+	e.events.send("status", gin.H{
+		"type":       "started",
+		"request_id": e.events.id,
+		"query":      query,
+		"timestamp":  time.Now().Unix(),
+	})
+}
+
+// sendCancelled ends a stream its client cancelled with POST
+// /search/:task_id/cancel
+func (e *sseEmitter) sendCancelled() {
+	e.events.send("cancelled", gin.H{"type": "cancelled", "request_id": e.events.id})
+}
+
+func (e *sseEmitter) Stage(stage pipeline.StageName) {
+	if status, ok := stageStatus[stage]; ok {
+		e.events.send("status", gin.H{"type": status})
+	}
+}
+
+func (e *sseEmitter) Results(results []pipeline.Result) {
+	results = e.g.proxyResultImages(results)
+	e.results = results
+	e.events.send("search_results", gin.H{
+		"type":    "search_results",
+		"results": results,
+	})
+}
+
+func (e *sseEmitter) Structured(data *answers.Data) {
+	e.structured = data
+	e.events.send("structured_answer", gin.H{
+		"type":   "structured_answer",
+		"answer": data,
+	})
+}
+
+func (e *sseEmitter) KnowledgePanel(panel *pipeline.KnowledgePanel) {
+	e.events.send("knowledge_panel", gin.H{
+		"type":  "knowledge_panel",
+		"panel": e.g.proxyPanelImage(panel),
+	})
+}
+
+// sseTokenEmitter streams the summary token by token. If the stream dies or
+// the client goes away, what was generated is kept as a partial result that
+// can be fetched from /search/partial/:id or continued.
+type sseTokenEmitter struct {
+	sseEmitter
+	summary     strings.Builder
+	summarizing bool
+	completed   bool
+	saved       bool // a partial result was already stored
+}
+
+func (e *sseTokenEmitter) Stage(stage pipeline.StageName) {
+	e.summarizing = e.summarizing || stage == pipeline.StageSummarize
+	e.sseEmitter.Stage(stage)
+}
+
+func (e *sseTokenEmitter) Token(token string, position int32) {
+	e.summary.WriteString(token)
+	e.events.sendToken(token, position)
+}
+
+func (e *sseTokenEmitter) Summary(summary pipeline.Summary) {
+	e.completed = true
+	if summary.Sanitized {
+		e.events.send("summary_sanitized", gin.H{
+			"type":             "summary_sanitized",
+			"message":          "Summary was filtered for safety",
+			"original_length":  summary.OriginalLength,
+			"sanitized_length": len(summary.Text),
+			"warnings":         summary.Warnings,
+		})
+	}
+	data := gin.H{"type": "summary", "confidence": summary.Confidence, "answer": summary.Answer, "related_searches": summary.RelatedSearches}
+	if summary.Safety != nil {
+		data["safety"] = summary.Safety
+	}
+	if summary.Truncated != "" {
+		data["truncated"] = true
+		data["truncated_reason"] = summary.Truncated
+	}
+	if summary.Recovered {
+		data["recovered"] = true
+	}
+	if summary.InputTruncated {
+		data["input_truncated"] = true
+	}
+	e.events.send("summary", data)
+	e.events.sendBudget(e.budget.merge(summary.Budget))
+	e.events.sendComplete(e.g.searchCompleted(e.c, e.events.id, e.query, e.results, taskSummary(summary.Text, e.structured)))
+}
+
+func (e *sseTokenEmitter) Fail(err *pipeline.Error) {
+	cancelled := streamCancelled(e.c)
+	if err.Stage == pipeline.StageSummarize {
+		message := err.Message
+		if cancelled {
+			message = "cancelled by the client"
+		} else if e.c.Request.Context().Err() != nil {
+			message = "client disconnected"
+		}
+		e.g.savePartial(e.c, e.events.id, e.query, e.results, e.summary.String(), false, message, 1)
+		e.saved = true
+	}
+	if cancelled {
+		e.sendCancelled()
+		return
+	}
+	e.events.send("error", gin.H{"message": err.Message, "request_id": e.events.id})
+}
+
+// finish keeps the partial result of a stream whose client disconnected
+// while it was being summarized
+func (e *sseTokenEmitter) finish() {
+	if e.summarizing && !e.saved && e.c.Request.Context().Err() != nil {
+		e.g.savePartial(e.c, e.events.id, e.query, e.results, e.summary.String(), e.completed, "client disconnected", 1)
+	}
+}
+
+// sseBatchEmitter sends the search results first, then the complete summary
+// in one event
+type sseBatchEmitter struct {
+	sseEmitter
+}
+
+func (e *sseBatchEmitter) Summary(summary pipeline.Summary) {
+	e.sendSummary(summary, e.budget.merge(summary.Budget))
+}
+
+func (e *sseBatchEmitter) Fail(err *pipeline.Error) {
+	if streamCancelled(e.c) {
+		e.sendCancelled()
+		return
+	}
+	// A failed summary still completes the search with a placeholder
+	if err.Status == http.StatusOK {
+		e.sendSummary(pipeline.Summary{Text: err.Message}, e.budget)
+		return
+	}
+	e.events.send("error", gin.H{"message": err.Message})
+}
+
+func (e *sseBatchEmitter) sendSummary(summary pipeline.Summary, budget *BudgetReport) {
+	data := gin.H{
+		"type": "summary_complete", // Different type to distinguish from streaming
+		"text": summary.Text,
+	}
+	if summary.Confidence != nil {
+		data["confidence"] = summary.Confidence
+	}
+	if summary.Answer != nil {
+		data["answer"] = summary.Answer
+	}
+	if len(summary.RelatedSearches) > 0 {
+		data["related_searches"] = summary.RelatedSearches
+	}
+	if summary.Safety != nil {
+		data["safety"] = summary.Safety
+	}
+	if summary.InputTruncated {
+		data["input_truncated"] = true
+	}
+	e.events.send("summary", data)
+	e.events.sendBudget(budget)
+	e.events.sendComplete(e.g.searchCompleted(e.c, e.events.id, e.query, e.results, taskSummary(summary.Text, e.structured)))
+}
+
+// jsonEmitter writes the whole search as one JSON response. In the
+// background it only refreshes the response cache.
+type jsonEmitter struct {
+	g          *Gateway
+	c          *gin.Context
+	query      string
+	budget     *BudgetReport
+	results    []SearchResult
+	structured *answers.Data
+	panel      *pipeline.KnowledgePanel
+	background bool
+}
+
+func (e *jsonEmitter) Started(query string)              { e.query = query }
+func (e *jsonEmitter) Stage(stage pipeline.StageName)    {}
+func (e *jsonEmitter) Results(results []pipeline.Result) { e.results = e.g.proxyResultImages(results) }
+func (e *jsonEmitter) Structured(data *answers.Data)     { e.structured = data }
+
+func (e *jsonEmitter) KnowledgePanel(panel *pipeline.KnowledgePanel) {
+	e.panel = e.g.proxyPanelImage(panel)
+}
+
+func (e *jsonEmitter) Summary(summary pipeline.Summary) {
+	resp := e.response(summary, e.budget.merge(summary.Budget))
+	e.g.cacheResponse(e.c, resp)
+	if e.background {
+		monitoring.RecordResponseCache("refreshed")
+		return
+	}
+	e.respond(resp)
+}
+
+func (e *jsonEmitter) Fail(err *pipeline.Error) {
+	if e.background {
+		refreshFailed(e.query, err.Message)
+		return
+	}
+	// A failed summary still returns the search results with a placeholder,
+	// but is not cached
+	if err.Status == http.StatusOK {
+		e.respond(e.response(pipeline.Summary{Text: err.Message}, e.budget))
+		return
+	}
+	e.c.JSON(err.Status, gin.H{"error": err.Message})
+}
+
+func (e *jsonEmitter) response(summary pipeline.Summary, budget *BudgetReport) *SearchResponse {
+	return &SearchResponse{
+		Query:           e.query,
+		Status:          "completed",
+		SearchResults:   e.results,
+		Summary:         summary.Text,
+		Budget:          budget,
+		Confidence:      summary.Confidence,
+		Answer:          summary.Answer,
+		Structured:      e.structured,
+		KnowledgePanel:  e.panel,
+		RelatedSearches: summary.RelatedSearches,
+		Safety:          summary.Safety,
+	}
+}
+
+func (e *jsonEmitter) respond(resp *SearchResponse) {
+	e.complete(resp)
+	e.c.JSON(http.StatusOK, resp)
+}
+
+// complete records the finished search and sets its task ID
+func (e *jsonEmitter) complete(resp *SearchResponse) {
+	resp.TaskID = e.g.searchCompleted(e.c, newStreamID(), e.query, e.results, taskSummary(resp.Summary, e.structured))
+}
@@ -0,0 +1,158 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+)
+
+// BufferedEvent is an SSE event kept for replay to reconnecting clients
+type BufferedEvent struct {
+	Seq   int64           `json:"seq"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// EventBuffer stores the recent events of each SSE stream
+type EventBuffer interface {
+	// Append records an event for the stream
+	Append(ctx context.Context, streamID string, event BufferedEvent) error
+	// Since returns the events after seq; found is false when the stream is
+	// unknown or has expired
+	Since(ctx context.Context, streamID string, seq int64) (events []BufferedEvent, found bool, err error)
+}
+
+// newRedisClient connects to the configured Redis, or returns nil when
+// redis.host is not set and state is kept in memory instead
+func newRedisClient(cfg *config.Config) *redis.Client {
+	addr := cfg.GetRedisAddress()
+	if addr == "" {
+		return nil
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+}
+
+// newEventBuffer returns the configured buffer, or nil when buffering is disabled
+func newEventBuffer(cfg *config.Config, client *redis.Client) EventBuffer {
+	bufCfg := cfg.Gateway.StreamBuffer
+	if !bufCfg.Enabled {
+		return nil
+	}
+	if client != nil {
+		logger.GetLogger().Infof("Buffering SSE events in Redis at %s", cfg.GetRedisAddress())
+		return &redisEventBuffer{client: client, maxEvents: bufCfg.MaxEvents, ttl: bufCfg.TTL}
+	}
+	logger.GetLogger().Info("Buffering SSE events in memory (redis.host not set)")
+	return newMemoryEventBuffer(bufCfg.MaxEvents, cfg.Gateway.MemoryStoreEntries, bufCfg.TTL)
+}
+
+// redisEventBuffer keeps each stream in a capped, expiring Redis list so any
+// gateway replica can resume it
+type redisEventBuffer struct {
+	client    *redis.Client
+	maxEvents int
+	ttl       time.Duration
+}
+
+func redisStreamKey(streamID string) string {
+	return "sse:stream:" + streamID
+}
+
+func (b *redisEventBuffer) Append(ctx context.Context, streamID string, event BufferedEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	key := redisStreamKey(streamID)
+	_, err = b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, data)
+		if b.maxEvents > 0 {
+			pipe.LTrim(ctx, key, int64(-b.maxEvents), -1)
+		}
+		pipe.Expire(ctx, key, b.ttl)
+		return nil
+	})
+	return err
+}
+
+func (b *redisEventBuffer) Since(ctx context.Context, streamID string, seq int64) ([]BufferedEvent, bool, error) {
+	raw, err := b.client.LRange(ctx, redisStreamKey(streamID), 0, -1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(raw) == 0 {
+		return nil, false, nil
+	}
+	var events []BufferedEvent
+	for _, item := range raw {
+		var event BufferedEvent
+		if err := json.Unmarshal([]byte(item), &event); err != nil {
+			return nil, true, fmt.Errorf("invalid buffered event: %w", err)
+		}
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, true, nil
+}
+
+// memoryEventBuffer is the single-replica fallback used without Redis
+type memoryEventBuffer struct {
+	maxEvents int
+
+	mu      sync.Mutex // guards the events of each stream
+	streams *lru.Cache[string, *memoryStream]
+}
+
+type memoryStream struct {
+	events []BufferedEvent
+}
+
+func newMemoryEventBuffer(maxEvents, maxStreams int, ttl time.Duration) *memoryEventBuffer {
+	return &memoryEventBuffer{maxEvents: maxEvents, streams: lru.New[string, *memoryStream]("gateway_stream_buffer", maxStreams, ttl)}
+}
+
+func (b *memoryEventBuffer) Append(ctx context.Context, streamID string, event BufferedEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams.Get(streamID)
+	if !ok {
+		s = &memoryStream{}
+	}
+	s.events = append(s.events, event)
+	if b.maxEvents > 0 && len(s.events) > b.maxEvents {
+		s.events = s.events[len(s.events)-b.maxEvents:]
+	}
+	b.streams.Add(streamID, s) // every event extends the stream's TTL
+	return nil
+}
+
+func (b *memoryEventBuffer) Since(ctx context.Context, streamID string, seq int64) ([]BufferedEvent, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams.Get(streamID)
+	if !ok {
+		return nil, false, nil
+	}
+	var events []BufferedEvent
+	for _, event := range s.events {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, true, nil
+}
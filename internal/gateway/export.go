@@ -0,0 +1,396 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/objectstore"
+)
+
+// CompletedSearch is a finished search kept for GET /api/v1/search/:task_id/export
+type CompletedSearch struct {
+	TaskID        string         `json:"task_id"`
+	Tenant        string         `json:"tenant"`
+	User          string         `json:"user,omitempty"` // the logged-in user who searched, or key:<id> of the API key
+	Query         string         `json:"query"`
+	SearchResults []SearchResult `json:"search_results"`
+	Summary       string         `json:"summary"`
+	CompletedAt   time.Time      `json:"completed_at"`
+}
+
+// TaskStore persists completed searches keyed by task ID
+type TaskStore interface {
+	Save(ctx context.Context, task *CompletedSearch) error
+	// Get returns found=false for unknown or expired task IDs
+	Get(ctx context.Context, id string) (task *CompletedSearch, found bool, err error)
+	// EraseUser deletes the searches of a user of the tenant, returning how
+	// many it deleted
+	EraseUser(ctx context.Context, tenant, user string) (int, error)
+}
+
+// newTaskStore returns the configured store, or nil when exports are disabled
+func newTaskStore(cfg *config.Config, client *redis.Client) TaskStore {
+	exportCfg := cfg.Gateway.Exports
+	if !exportCfg.Enabled {
+		return nil
+	}
+	if client != nil {
+		return &redisTaskStore{client: client, ttl: exportCfg.TTL}
+	}
+	return &memoryTaskStore{tasks: lru.New[string, CompletedSearch]("gateway_exports", cfg.Gateway.MemoryStoreEntries, exportCfg.TTL)}
+}
+
+type redisTaskStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func redisTaskKey(id string) string {
+	return "export:task:" + id
+}
+
+func (s *redisTaskStore) Save(ctx context.Context, task *CompletedSearch) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisTaskKey(task.TaskID), data, s.ttl).Err()
+}
+
+func (s *redisTaskStore) Get(ctx context.Context, id string) (*CompletedSearch, bool, error) {
+	data, err := s.client.Get(ctx, redisTaskKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var task CompletedSearch
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, false, fmt.Errorf("invalid completed search: %w", err)
+	}
+	return &task, true, nil
+}
+
+func (s *redisTaskStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return eraseRedis(ctx, s.client, redisTaskKey("*"), func(data []byte) (bool, error) {
+		var task CompletedSearch
+		if err := json.Unmarshal(data, &task); err != nil {
+			return false, fmt.Errorf("invalid completed search: %w", err)
+		}
+		return task.Tenant == tenant && task.User == user, nil
+	})
+}
+
+type memoryTaskStore struct {
+	tasks *lru.Cache[string, CompletedSearch]
+}
+
+func (s *memoryTaskStore) Save(ctx context.Context, task *CompletedSearch) error {
+	s.tasks.Add(task.TaskID, *task)
+	return nil
+}
+
+func (s *memoryTaskStore) Get(ctx context.Context, id string) (*CompletedSearch, bool, error) {
+	task, ok := s.tasks.Get(id)
+	if !ok {
+		return nil, false, nil
+	}
+	return &task, true, nil
+}
+
+func (s *memoryTaskStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return s.tasks.RemoveFunc(func(id string, task CompletedSearch) bool {
+		return task.Tenant == tenant && task.User == user
+	}), nil
+}
+
+// saveTask keeps a completed search for export and returns its task ID, or
+// "" when exports are disabled or it could not be stored
+func (g *Gateway) saveTask(tenant *Tenant, user, taskID, query string, results []SearchResult, summary string) string {
+	if g.tasks == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := g.tasks.Save(ctx, &CompletedSearch{
+		TaskID:        taskID,
+		Tenant:        tenant.ID,
+		User:          user,
+		Query:         query,
+		SearchResults: results,
+		Summary:       summary,
+		CompletedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		logger.GetLogger().Warnf("Failed to keep completed search %s for export: %v", taskID, err)
+		return ""
+	}
+	return taskID
+}
+
+// sendComplete ends a stream, naming the task ID to export it by if kept
+func (s *eventStream) sendComplete(taskID string) {
+	event := gin.H{"type": "complete"}
+	if taskID != "" {
+		event["task_id"] = taskID
+	}
+	s.send("complete", event)
+}
+
+// exportFormats maps each format to its content type and file extension
+var exportFormats = map[string]struct{ contentType, ext string }{
+	"md":   {"text/markdown; charset=utf-8", "md"},
+	"html": {"text/html; charset=utf-8", "html"},
+	"pdf":  {"application/pdf", "pdf"},
+}
+
+// ExportSearch renders a completed search as a downloadable Markdown, HTML
+// or PDF report with the query, the cited sources and the summary
+func (g *Gateway) ExportSearch(c *gin.Context) {
+	if g.tasks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exports are disabled"})
+		return
+	}
+	format := c.DefaultQuery("format", "md")
+	spec, ok := exportFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be md, html or pdf"})
+		return
+	}
+	if format == "pdf" && g.config.Gateway.Exports.PDFRendererURL == "" {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "PDF export is not configured"})
+		return
+	}
+
+	task, found, err := g.tasks.Get(c.Request.Context(), c.Param("task_id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load completed search %s: %v", c.Param("task_id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search"})
+		return
+	}
+	if !found || task.Tenant != g.tenant(c).ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Search not found or expired"})
+		return
+	}
+
+	report, err := g.renderReport(c.Request.Context(), task, format)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to render %s report for %s: %v", format, task.TaskID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to render report"})
+		return
+	}
+	defer report.Close()
+
+	filename := fmt.Sprintf("search-%s.%s", task.TaskID, spec.ext)
+	if g.objects != nil {
+		g.storeObject(c, task.Tenant+"/"+filename, objectstore.Download{Filename: filename, ContentType: spec.contentType}, report)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.DataFromReader(http.StatusOK, -1, spec.contentType, report, nil)
+}
+
+// renderReport renders a completed search in an export format; PDFs stream
+// from the renderer
+func (g *Gateway) renderReport(ctx context.Context, task *CompletedSearch, format string) (io.ReadCloser, error) {
+	switch format {
+	case "md":
+		return io.NopCloser(strings.NewReader(markdownReport(task))), nil
+	case "html":
+		page, err := htmlReport(task)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(page)), nil
+	default:
+		return g.pdfReport(ctx, task)
+	}
+}
+
+// storeObject streams body to object storage and redirects to a signed URL
+// downloading it, valid for exports.storage.url_ttl; the JSON body repeats
+// the URL for clients that do not follow redirects
+func (g *Gateway) storeObject(c *gin.Context, key string, download objectstore.Download, body io.Reader) {
+	ctx := c.Request.Context()
+	download.TTL = g.config.Gateway.Exports.Storage.URLTTL
+	size, err := g.objects.Put(ctx, key, download.ContentType, body)
+	var location string
+	if err == nil {
+		location, err = g.objects.URL(ctx, key, download)
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to store %s: %v", key, err)
+		monitoring.RecordStoredObject("failed", 0)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to store the file"})
+		return
+	}
+	monitoring.RecordStoredObject("stored", size)
+
+	// Gateway paths stay relative in Location, so they follow the host the
+	// client used
+	url := location
+	if strings.HasPrefix(location, "/") {
+		url = g.publicBaseURL(c) + location
+	}
+	c.Header("Location", location)
+	c.JSON(http.StatusSeeOther, gin.H{
+		"url":        url,
+		"size":       size,
+		"expires_at": time.Now().Add(download.TTL).UTC(),
+	})
+}
+
+// RegisterExportRoutes serves the files of the filesystem object store at
+// the signed URLs it hands out
+func (g *Gateway) RegisterExportRoutes(router gin.IRoutes) {
+	files, ok := g.objects.(*objectstore.Filesystem)
+	if !ok {
+		return
+	}
+	router.GET("/exports/*key", func(c *gin.Context) {
+		files.Serve(c.Writer, c.Request, strings.TrimPrefix(c.Param("key"), "/"))
+	})
+}
+
+// sourceLabel describes where a result came from, for internal documents
+func sourceLabel(r SearchResult) string {
+	if r.Paywalled {
+		return "Article preview"
+	}
+	if !r.Internal {
+		return ""
+	}
+	if r.Source != "" {
+		return "Internal · " + r.Source
+	}
+	return "Internal"
+}
+
+var markdownLinkText = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "\n", " ")
+var markdownLinkURL = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20")
+
+func markdownReport(task *CompletedSearch) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", strings.ReplaceAll(task.Query, "\n", " "))
+	fmt.Fprintf(&b, "_Searched %s · %d sources_\n\n", task.CompletedAt.Format("2006-01-02 15:04 MST"), len(task.SearchResults))
+	fmt.Fprintf(&b, "## Summary\n\n%s\n\n## Sources\n", task.Summary)
+	for i, r := range task.SearchResults {
+		fmt.Fprintf(&b, "\n%d. [%s](%s)", i+1, markdownLinkText.Replace(r.Title), markdownLinkURL.Replace(r.URL))
+		if label := sourceLabel(r); label != "" {
+			fmt.Fprintf(&b, " — _%s_", label)
+		}
+		b.WriteString("\n")
+		if snippet := strings.TrimSpace(r.Snippet); snippet != "" {
+			fmt.Fprintf(&b, "   > %s\n", strings.ReplaceAll(snippet, "\n", " "))
+		}
+	}
+	return b.String()
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc":    func(i int) int { return i + 1 },
+	"source": sourceLabel,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Query}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 48rem; margin: 2rem auto; color: #222; line-height: 1.5; }
+.meta, .snippet { color: #666; }
+.summary { white-space: pre-wrap; }
+.badge { font-size: 0.8em; background: #eef; border-radius: 3px; padding: 0 0.3em; }
+</style>
+</head>
+<body>
+<h1>{{.Query}}</h1>
+<p class="meta">Searched {{.CompletedAt.Format "2006-01-02 15:04 MST"}} · {{len .SearchResults}} sources</p>
+<h2>Summary</h2>
+<p class="summary">{{.Summary}}</p>
+<h2>Sources</h2>
+<ol>
+{{- range $i, $r := .SearchResults}}
+<li id="source-{{inc $i}}"><a href="{{$r.URL}}">{{$r.Title}}</a>{{with source $r}} <span class="badge">{{.}}</span>{{end}}
+{{- with $r.Snippet}}<div class="snippet">{{.}}</div>{{end}}</li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+func htmlReport(task *CompletedSearch) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, task); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfReport converts the HTML report with a Gotenberg-compatible renderer,
+// returning the PDF as the renderer streams it
+func (g *Gateway) pdfReport(ctx context.Context, task *CompletedSearch) (io.ReadCloser, error) {
+	page, err := htmlReport(task)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("files", "index.html")
+	if err != nil {
+		return nil, err
+	}
+	file.Write(page)
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	exportCfg := g.config.Gateway.Exports
+	ctx, cancel := context.WithTimeout(ctx, exportCfg.PDFTimeout)
+	endpoint := strings.TrimRight(exportCfg.PDFRendererURL, "/") + "/forms/chromium/convert/html"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("PDF renderer returned status %d", resp.StatusCode)
+	}
+	return &cancelingBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelingBody ends the request of a response body once it is closed
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
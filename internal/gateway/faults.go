@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/faults"
+	"ai-search-service/internal/flags"
+)
+
+// RegisterAdminRoutes registers the admin endpoints: the tenant admin API,
+// the audit log, the diagnostics summary, model switches, the prompt audit
+// export and history rewrapping (for admins, with RBAC or
+// tenancy.admin_token) and, when
+// fault injection is enabled (never in production), the fault endpoints. With RBAC enabled viewers may read
+// faults and operators change them; every change is audited.
+func (g *Gateway) RegisterAdminRoutes(admin *gin.RouterGroup) {
+	admin.Use(g.limitBody, g.auditAdmin)
+	g.registerTenantAdminRoutes(admin)
+	if g.access != nil {
+		admin.GET("/audit", g.authorize(auth.RoleAdmin), g.ListAuditLog)
+	}
+	if g.diagnostics != nil {
+		admin.GET("/diagnostics", g.authorize(auth.RoleAdmin), gin.WrapF(g.diagnostics.Summary))
+	}
+	if g.access != nil || g.config.Gateway.Tenancy.AdminToken != "" {
+		admin.POST("/models/switch", g.authorize(auth.RoleAdmin), g.SwitchModel)
+	}
+	g.registerFlagAdminRoutes(admin)
+	g.registerPromptAdminRoutes(admin)
+	g.registerEncryptionAdminRoutes(admin)
+	g.registerEventAdminRoutes(admin)
+	g.registerMaintenanceAdminRoutes(admin)
+	if g.faults == nil {
+		return
+	}
+	read, write := gin.HandlerFunc(allowAll), gin.HandlerFunc(allowAll)
+	if g.access != nil {
+		read, write = g.authorize(auth.RoleViewer), g.authorize(auth.RoleOperator)
+	}
+	admin.GET("/faults", read, g.ListFaults)
+	admin.POST("/faults", write, g.AddFault)
+	admin.DELETE("/faults", write, g.ClearFaults)
+}
+
+// requestContext is the base context for backend calls made on behalf of a
+// request; it carries who the request runs as, for feature flags, and the
+// request's X-Fault headers when injection is enabled
+func (g *Gateway) requestContext(c *gin.Context) context.Context {
+	ctx := flags.OutgoingContext(streamBaseContext(c), g.flagSubject(c))
+	if g.faults == nil {
+		return ctx
+	}
+	return faults.OutgoingContext(ctx, c.Request.Header)
+}
+
+func (g *Gateway) ListFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"faults": g.faults.Rules()})
+}
+
+func (g *Gateway) AddFault(c *gin.Context) {
+	var fault faults.Fault
+	if !bindJSON(c, &fault) {
+		return
+	}
+	if err := g.faults.Add(fault); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"faults": g.faults.Rules()})
+}
+
+func (g *Gateway) ClearFaults(c *gin.Context) {
+	g.faults.Clear()
+	c.JSON(http.StatusOK, gin.H{"faults": []faults.Fault{}})
+}
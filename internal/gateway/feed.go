@@ -0,0 +1,224 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/logger"
+)
+
+// newFeedToken returns a random token authorizing reads of one query's feed
+func newFeedToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// QueryFeed serves the digests of a saved query as an RSS 2.0 (default) or
+// Atom feed for feed readers. Feed readers cannot send headers, so the
+// query's feed token is taken from the token parameter as well as from an
+// Authorization: Bearer header.
+func (g *Gateway) QueryFeed(c *gin.Context) {
+	query := g.savedQuery(c)
+	if query == nil {
+		return
+	}
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if query.FeedToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(query.FeedToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "A valid feed token is required"})
+		return
+	}
+	format := c.DefaultQuery("format", "rss")
+	if format != "rss" && format != "atom" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be rss or atom"})
+		return
+	}
+
+	runs, err := g.scheduler.store.Runs(c.Request.Context(), query.ID)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load runs of %s: %v", query.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load runs"})
+		return
+	}
+	// Entries are the digests: the baseline and every substantive change
+	var digests []*QueryRun
+	for _, run := range runs {
+		if run.Baseline || run.Changed {
+			digests = append(digests, run)
+		}
+	}
+
+	base := requestBaseURL(c)
+	if format == "atom" {
+		c.Header("Content-Type", "application/atom+xml; charset=utf-8")
+		c.Status(http.StatusOK)
+		writeXML(c, atomFeed(base, query, digests))
+		return
+	}
+	c.Header("Content-Type", "application/rss+xml; charset=utf-8")
+	c.Status(http.StatusOK)
+	writeXML(c, rssFeed(base, query, digests))
+}
+
+// RotateFeedToken replaces a saved query's feed token, revoking the old
+// feed URL, and returns the new token
+func (g *Gateway) RotateFeedToken(c *gin.Context) {
+	query := g.savedQuery(c)
+	if query == nil {
+		return
+	}
+	query.FeedToken = newFeedToken()
+	if err := g.scheduler.store.SaveQuery(c.Request.Context(), query); err != nil {
+		logger.GetLogger().Errorf("Failed to rotate feed token of %s: %v", query.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate feed token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"feed_token": query.FeedToken})
+}
+
+// requestBaseURL is the scheme and host the client reached the gateway on
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func writeXML(c *gin.Context, feed interface{}) {
+	c.Writer.WriteString(xml.Header)
+	enc := xml.NewEncoder(c.Writer)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		logger.GetLogger().Errorf("Failed to write feed: %v", err)
+	}
+}
+
+func digestTitle(query *SavedQuery, run *QueryRun) string {
+	if run.Baseline {
+		return fmt.Sprintf("%s: first digest", query.Query)
+	}
+	return fmt.Sprintf("%s: update (%d new sources)", query.Query, len(run.NewSources))
+}
+
+// digestHTML renders a run's summary and cited sources as entry content
+func digestHTML(run *QueryRun) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>%s</p>\n<ol>\n", html.EscapeString(run.Summary))
+	for _, r := range run.Results {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a>", html.EscapeString(r.URL), html.EscapeString(r.Title))
+		if label := sourceLabel(r); label != "" {
+			fmt.Fprintf(&b, " (%s)", html.EscapeString(label))
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>")
+	return b.String()
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func rssFeed(base string, query *SavedQuery, runs []*QueryRun) *rss {
+	feed := &rss{Version: "2.0", Channel: rssChannel{
+		Title:       query.Query,
+		Link:        base + "/",
+		Description: fmt.Sprintf("Summary digests of the saved query %q (%s)", query.Query, query.Schedule),
+	}}
+	if len(runs) > 0 {
+		feed.Channel.LastBuildDate = runs[0].StartedAt.UTC().Format(time.RFC1123Z)
+	}
+	for _, run := range runs {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       digestTitle(query, run),
+			Description: digestHTML(run),
+			GUID:        rssGUID{Value: query.ID + "/" + run.ID},
+			PubDate:     run.StartedAt.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return feed
+}
+
+type atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func atomFeed(base string, query *SavedQuery, runs []*QueryRun) *atom {
+	id := base + "/api/v1/saved-queries/" + query.ID
+	feed := &atom{
+		ID:      id,
+		Title:   query.Query,
+		Updated: query.CreatedAt.UTC().Format(time.RFC3339),
+		// The self link leaves out the token so it is not republished
+		Links: []atomLink{{Href: base + "/"}, {Rel: "self", Href: id + "/feed?format=atom"}},
+	}
+	if len(runs) > 0 {
+		feed.Updated = runs[0].StartedAt.UTC().Format(time.RFC3339)
+	}
+	for _, run := range runs {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      id + "/runs#" + run.ID,
+			Title:   digestTitle(query, run),
+			Updated: run.StartedAt.UTC().Format(time.RFC3339),
+			Content: atomContent{Type: "html", Value: digestHTML(run)},
+		})
+	}
+	return feed
+}
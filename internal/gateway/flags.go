@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/logger"
+)
+
+// flagSubject is who a request runs as for feature flags: its tenant and
+// API key, with rollouts bucketed by the key or else the client address
+func (g *Gateway) flagSubject(c *gin.Context) flags.Subject {
+	s := flags.Subject{Tenant: g.tenant(c).ID, Bucket: c.ClientIP()}
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		s.Key = flags.HashKey(key)
+		s.Bucket = s.Key
+	}
+	return s
+}
+
+// registerFlagAdminRoutes registers the feature flag API. Viewers may list
+// the flags and operators override them; without RBAC only
+// tenancy.admin_token is accepted, and the API is not served if it is unset.
+func (g *Gateway) registerFlagAdminRoutes(admin *gin.RouterGroup) {
+	if g.access == nil && g.config.Gateway.Tenancy.AdminToken == "" {
+		return
+	}
+	admin.GET("/flags", g.authorize(auth.RoleViewer), g.ListFlags)
+	admin.PUT("/flags/:name", g.authorize(auth.RoleOperator), g.OverrideFlag)
+	admin.DELETE("/flags/:name", g.authorize(auth.RoleOperator), g.RemoveFlagOverride)
+}
+
+// ListFlags returns the flags in effect, each naming whether it comes from
+// config or an override
+func (g *Gateway) ListFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"flags": g.flags.List()})
+}
+
+// OverrideFlag replaces a flag at runtime, or defines one for a feature
+// config has no flag for. Other replicas pick it up within
+// feature_flags.refresh_interval.
+func (g *Gateway) OverrideFlag(c *gin.Context) {
+	var flag flags.Flag
+	if !bindJSON(c, &flag) {
+		return
+	}
+	flag.Name = c.Param("name")
+	if err := flag.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	flag, err := g.flags.Override(c.Request.Context(), flag)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to override feature flag %s: %v", c.Param("name"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to override flag"})
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// RemoveFlagOverride restores a flag to its configuration
+func (g *Gateway) RemoveFlagOverride(c *gin.Context) {
+	found, err := g.flags.RemoveOverride(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to remove feature flag override %s: %v", c.Param("name"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove override"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flag has no override"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"flags": g.flags.List()})
+}
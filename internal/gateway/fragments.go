@@ -0,0 +1,218 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+)
+
+// RegisterFragmentRoutes serves the pieces of the results page as HTML
+// fragments for HTMX swaps: a search streamed as one SSE event per fragment,
+// and the result list and summary of a completed search
+func (g *Gateway) RegisterFragmentRoutes(router gin.IRoutes) {
+	router.GET("/fragments/search", g.RequireLogin, g.resolveTenant, g.trackSearch, g.streamTimeout, g.SearchFragments)
+	router.GET("/fragments/tasks/:task_id/results", g.RequireLogin, g.resolveTenant, g.TaskFragment("results"))
+	router.GET("/fragments/tasks/:task_id/summary", g.RequireLogin, g.resolveTenant, g.TaskFragment("summary"))
+}
+
+// renderFragment renders one template of the results page, as it would
+// appear in the page
+func renderFragment(name string, data interface{}) (string, bool) {
+	var buf bytes.Buffer
+	if err := resultsTemplate.ExecuteTemplate(&buf, name, data); err != nil {
+		logger.GetLogger().Warnf("Failed to render the %s fragment: %v", name, err)
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// sendFragment sends a fragment as an SSE event, which the HTMX SSE
+// extension swaps in with sse-swap="<event>"
+func sendFragment(c *gin.Context, event, name string, data interface{}) {
+	if fragment, ok := renderFragment(name, data); ok {
+		writeHTMLEvent(c, event, fragment)
+	}
+}
+
+// sseLineBreaks are the line breaks event data is split at
+var sseLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// writeHTMLEvent writes an SSE event with HTML data. Each data line starts
+// with the one space clients strip, so a token's leading space is kept.
+func writeHTMLEvent(c *gin.Context, event, data string) {
+	var b strings.Builder
+	b.WriteString("event: " + event + "\n")
+	for _, line := range strings.Split(sseLineBreaks.Replace(data), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	c.Writer.WriteString(b.String())
+	c.Writer.Flush()
+}
+
+// SearchFragments serves /fragments/search?q=&safe_search=&num_results=:
+// the search streamed as SSE events whose data are the fragments of the
+// results page. Events are results, structured, panel, instant, token
+// (escaped text to append to the draft summary), summary and error, and the
+// stream always ends with done, carrying the task ID of a completed search.
+// Rejections are an error fragment with the status the API would have
+// returned.
+func (g *Gateway) SearchFragments(c *gin.Context) {
+	start := time.Now()
+	if !g.config.Gateway.HTMLSearch.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HTML search is disabled"})
+		return
+	}
+	query := strings.TrimSpace(c.Query("q"))
+	safeSearch := c.Query("safe_search") == "true"
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	reject := func(status int, message string) {
+		fragment, _ := renderFragment("error", message)
+		c.Data(status, "text/html; charset=utf-8", []byte(fragment))
+	}
+	if query == "" {
+		reject(http.StatusBadRequest, "q must not be empty")
+		return
+	}
+	if status, message := g.admitHTMLSearch(c, query); status != 0 {
+		reject(status, message)
+		return
+	}
+	numResults, _ := strconv.Atoi(c.Query("num_results"))
+	numResults = g.clampNumResults(c, numResults)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	emit := &fragmentEmitter{g: g, c: c}
+	if answer := g.instantAnswer(c, query, safeSearch); answer != nil {
+		sendFragment(c, "instant", "instant", answer)
+	} else {
+		numResults, emit.budget = g.capFetchedPages(numResults)
+		g.pipeline.Run(g.pipelineRequest(c, "html", query, safeSearch, numResults), emit)
+	}
+	sendFragment(c, "done", "done", emit.taskID)
+
+	monitoring.RecordRequest("gateway", "search", "success")
+	monitoring.RecordRequestDuration("gateway", "search", time.Since(start))
+}
+
+// fragmentEmitter streams a search as the results page's fragments
+type fragmentEmitter struct {
+	g          *Gateway
+	c          *gin.Context
+	query      string
+	budget     *BudgetReport
+	results    []SearchResult
+	structured *answers.Data
+	taskID     string
+}
+
+func (e *fragmentEmitter) Started(query string) { e.query = query }
+
+func (e *fragmentEmitter) Stage(stage pipeline.StageName) {}
+
+func (e *fragmentEmitter) Results(results []pipeline.Result) {
+	e.results = e.g.proxyResultImages(results)
+	sendFragment(e.c, "results", "results", e.results)
+}
+
+func (e *fragmentEmitter) Structured(data *answers.Data) {
+	e.structured = data
+	sendFragment(e.c, "structured", "structured", data)
+}
+
+func (e *fragmentEmitter) KnowledgePanel(panel *pipeline.KnowledgePanel) {
+	sendFragment(e.c, "panel", "panel", e.g.proxyPanelImage(panel))
+}
+
+func (e *fragmentEmitter) Token(token string, position int32) {
+	writeHTMLEvent(e.c, "token", template.HTMLEscapeString(token))
+}
+
+func (e *fragmentEmitter) Summary(summary pipeline.Summary) {
+	end := summaryEnd{
+		Final:      summary.Text,
+		Confidence: summary.Confidence,
+		Budget:     e.budget.merge(summary.Budget),
+		Related:    summary.RelatedSearches,
+	}
+	if summary.Sanitized {
+		end.Note = "Summary was filtered for safety"
+	}
+	if summary.Text != "" || len(end.Related) > 0 {
+		sendFragment(e.c, "summary", "summary_fragment", end)
+	}
+	e.taskID = e.g.searchCompleted(e.c, newStreamID(), e.query, e.results, taskSummary(summary.Text, e.structured))
+}
+
+func (e *fragmentEmitter) Fail(err *pipeline.Error) {
+	// A failed summary still completes the search with a placeholder
+	if err.Status == http.StatusOK {
+		sendFragment(e.c, "summary", "summary_fragment", summaryEnd{Final: err.Message, Budget: e.budget})
+		return
+	}
+	sendFragment(e.c, "error", "error", err.Message)
+}
+
+// TaskFragment serves the result list or the summary of a completed search
+// of the caller's tenant. The fragment does not change once the search has
+// completed, so browsers may reuse it for html_search.fragment_max_age and
+// revalidate it by its ETag after that.
+func (g *Gateway) TaskFragment(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !g.config.Gateway.HTMLSearch.Enabled || g.tasks == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Fragments are disabled"})
+			return
+		}
+		task, found, err := g.tasks.Get(c.Request.Context(), c.Param("task_id"))
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to load completed search %s: %v", c.Param("task_id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search"})
+			return
+		}
+		if !found || task.Tenant != g.tenant(c).ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Search not found or expired"})
+			return
+		}
+
+		var fragment string
+		var ok bool
+		if name == "results" {
+			fragment, ok = renderFragment("results", task.SearchResults)
+		} else {
+			fragment, ok = renderFragment("summary_fragment", summaryEnd{Final: task.Summary})
+		}
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render fragment"})
+			return
+		}
+
+		sum := sha256.Sum256([]byte(fragment))
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(g.config.Gateway.HTMLSearch.FragmentMaxAge.Seconds())))
+		c.Header("ETag", etag)
+		c.Header("Vary", "Cookie, "+APIKeyHeader)
+		c.Header("X-Content-Type-Options", "nosniff")
+		if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(fragment))
+	}
+}
@@ -13,9 +13,23 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/auth"
 	"ai-search-service/internal/config"
+	"ai-search-service/internal/cron"
+	"ai-search-service/internal/diagnostics"
+	"ai-search-service/internal/faults"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/graphql"
+	"ai-search-service/internal/instant"
 	"ai-search-service/internal/logger"
 	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/notify"
+	"ai-search-service/internal/objectstore"
+	"ai-search-service/internal/pipeline"
+	"ai-search-service/internal/privacy"
+	"ai-search-service/internal/probes"
+	"ai-search-service/internal/store"
 	pb "ai-search-service/proto"
 )
 
@@ -26,21 +40,60 @@ type Gateway struct {
 	inferenceClient pb.InferenceServiceClient
 	llmClient       pb.LLMOrchestratorServiceClient
 	metrics         *monitoring.MetricsCollector
+	faults          *faults.Injector // nil unless fault injection is enabled
+	eventBuffer     EventBuffer      // nil when SSE resumption is disabled
+	partials        PartialStore     // nil when partial results are not kept
+	cancels         *streamCancels
+	idempotency     *idempotency   // nil when Idempotency-Key handling is disabled
+	jobs            JobStore       // nil when document ingestion is disabled
+	tasks           TaskStore      // nil when search exports are disabled
+	history         QueryHistory   // nil when suggestions are disabled
+	tenants         TenantStore    // nil when multi-tenancy is disabled
+	quotas          QuotaCounter   // nil when multi-tenancy is disabled
+	access          *accessControl // nil when RBAC is disabled
+	oidc            *auth.Provider // nil when no OIDC issuer is configured
+	login           *webLogin      // nil when web UI login is disabled
+	sharing         *sharing       // nil when sharing is disabled
+	scheduler       *scheduler     // nil when scheduled queries are disabled
+	batches         *batching      // nil when batch jobs are disabled
+	notifier        *notify.Dispatcher
+	coalescer       *coalescer               // nil when request coalescing is disabled
+	responses       *responseCache           // nil when the response cache is disabled
+	prefetcher      *prefetcher              // nil when related search prefetching is disabled
+	shadow          *shadower                // nil when shadow traffic is disabled
+	instant         *instant.Answerer        // nil when instant answers are disabled
+	images          *imageProxy              // nil when the image proxy is disabled
+	snippets        *pipeline.SnippetCleaner // nil when snippet cleaning is disabled
+	modelRouter     *pipeline.ModelRouter    // nil when model routing is disabled
+	prompts         *promptAudit             // nil when the prompt audit is disabled
+	graphql         *graphql.Schema          // nil when the GraphQL endpoint is disabled
+	pipeline        *pipeline.Engine
+	flags           *flags.Flags
+	db              *store.Store      // nil when no store backend is configured
+	outbox          *eventOutbox      // nil when events are disabled
+	objects         objectstore.Store // nil when exports are returned in the response
+	maintenance     *cron.Scheduler
+	ips             *privacy.Anonymizer
+	drain           *drainer
+	probes          *probes.Probes
+	diagnostics     *diagnostics.Diagnostics // nil when diagnostics are disabled
 }
 
-
-type SearchResult struct {
-	Title      string `json:"title"`
-	URL        string `json:"url"`
-	Snippet    string `json:"snippet"`
-	DisplayURL string `json:"display_url"`
-}
+type SearchResult = pipeline.Result
 
 type SearchRequest struct {
 	Query      string `json:"query" binding:"required"`
 	SafeSearch bool   `json:"safe_search"`
 	Streaming  bool   `json:"streaming"`
 	NumResults int    `json:"num_results"`
+	// Locale is the BCP 47 language and country to search in, e.g. "de-CH";
+	// empty uses Accept-Language
+	Locale string `json:"locale,omitempty"`
+	// Sampling tunes the summary's generation within gateway.sampling
+	Sampling *SamplingParams `json:"sampling,omitempty"`
+	// Deterministic samples greedily with a fixed seed, so identical
+	// results reproduce the exact summary
+	Deterministic bool `json:"deterministic,omitempty"`
 }
 
 type SearchResponse struct {
@@ -48,20 +101,53 @@ type SearchResponse struct {
 	Status        string         `json:"status"`
 	SearchResults []SearchResult `json:"search_results,omitempty"`
 	Summary       string         `json:"summary,omitempty"`
+	TaskID        string         `json:"task_id,omitempty"` // for GET /api/v1/search/:task_id/export
 	Error         string         `json:"error,omitempty"`
+	Budget        *BudgetReport  `json:"budget,omitempty"`
+	// Confidence rates how well the search results support the summary
+	Confidence *pipeline.Confidence `json:"confidence,omitempty"`
+	// Answer is the detected query type and the format of the summary
+	Answer *pipeline.Answer `json:"answer,omitempty"`
+	// InstantAnswer replaces the search results and summary for
+	// calculations, conversions and definitions
+	InstantAnswer *instant.Answer `json:"instant_answer,omitempty"`
+	// Structured is an answer provider's data (weather, stocks, sports),
+	// shown with the summary or, when it answers the query, instead of it
+	Structured *answers.Data `json:"structured_answer,omitempty"`
+	// KnowledgePanel is the entity the query is about, when enabled
+	KnowledgePanel *pipeline.KnowledgePanel `json:"knowledge_panel,omitempty"`
+	// RelatedSearches are queries to suggest next, built from the results
+	RelatedSearches []string `json:"related_searches,omitempty"`
+	// Safety is the summary's toxicity scores and what the safety filter
+	// did about them, when it matched anything
+	Safety *pipeline.SafetyReport `json:"safety,omitempty"`
 }
 
-func NewGateway(cfg *config.Config) (*Gateway, error) {
+// NewGateway connects to the backend services. dialOpts are added to every
+// connection (e.g. an in-memory dialer in the test harness).
+func NewGateway(cfg *config.Config, dialOpts ...grpc.DialOption) (*Gateway, error) {
 	// Initialize metrics collector
 	metricsCollector, err := monitoring.NewMetricsCollector("gateway")
 	if err != nil {
 		logger.GetLogger().Warnf("Failed to initialize metrics collector: %v", err)
 	}
 
+	dialOpts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, dialOpts...)
+
+	var faultInjector *faults.Injector
+	if cfg.FaultInjectionEnabled() {
+		logger.GetLogger().Warn("Fault injection enabled: /admin/faults and X-Fault headers are active")
+		faultInjector = faults.NewInjector("gateway")
+		dialOpts = append(dialOpts,
+			grpc.WithChainUnaryInterceptor(faultInjector.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(faultInjector.StreamClientInterceptor()),
+		)
+	}
+
 	// Connect to LLM orchestrator service
 	llmConn, err := grpc.Dial(
 		cfg.GetLLMAddress(),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		dialOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to LLM orchestrator service: %w", err)
@@ -70,7 +156,7 @@ func NewGateway(cfg *config.Config) (*Gateway, error) {
 	// Initialize gRPC clients
 	searchConn, err := grpc.Dial(
 		fmt.Sprintf("%s:%d", cfg.Services.Search.Host, cfg.Services.Search.Port),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		dialOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to search service: %w", err)
@@ -78,7 +164,7 @@ func NewGateway(cfg *config.Config) (*Gateway, error) {
 
 	safetyConn, err := grpc.Dial(
 		fmt.Sprintf("%s:%d", cfg.Services.Safety.Host, cfg.Services.Safety.Port),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		dialOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to safety service: %w", err)
@@ -86,26 +172,221 @@ func NewGateway(cfg *config.Config) (*Gateway, error) {
 
 	inferenceConn, err := grpc.Dial(
 		fmt.Sprintf("%s:%d", cfg.Services.Inference.Host, cfg.Services.Inference.Port),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		dialOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to inference service: %w", err)
 	}
 
+	if err := pipeline.DefaultRegistry.LoadPlugins(cfg.Gateway.PipelinePlugins); err != nil {
+		return nil, err
+	}
+	for _, provider := range answers.Configured(cfg) {
+		pipeline.DefaultRegistry.RegisterProvider(provider)
+	}
+
+	redisClient := newRedisClient(cfg)
+	db, err := openStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	outbox, err := newEventOutbox(cfg, redisClient, db)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier, err := notify.New(cfg.Notifications)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := newAccessControl(cfg, redisClient, db)
+	if err != nil {
+		return nil, err
+	}
+
+	login, err := newWebLogin(cfg, redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	featureFlags, err := flags.New(cfg, redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	searchClient := pb.NewSearchServiceClient(searchConn)
+	safetyClient := pb.NewSafetyServiceClient(safetyConn)
+	llmClient := pb.NewLLMOrchestratorServiceClient(llmConn)
+	inferenceClient := pb.NewInferenceServiceClient(inferenceConn)
+
 	// Initialize gateway
 	g := &Gateway{
 		config:          cfg,
-		searchClient:    pb.NewSearchServiceClient(searchConn),
-		safetyClient:    pb.NewSafetyServiceClient(safetyConn),
-		inferenceClient: pb.NewInferenceServiceClient(inferenceConn),
-		llmClient:       pb.NewLLMOrchestratorServiceClient(llmConn),
+		searchClient:    searchClient,
+		safetyClient:    safetyClient,
+		inferenceClient: inferenceClient,
+		llmClient:       llmClient,
 		metrics:         metricsCollector,
+		faults:          faultInjector,
+		eventBuffer:     newEventBuffer(cfg, redisClient),
+		partials:        newPartialStore(cfg, redisClient),
+		cancels:         newStreamCancels(redisClient),
+		idempotency:     newIdempotency(cfg, redisClient),
+		jobs:            newJobStore(cfg, redisClient),
+		tasks:           newTaskStore(cfg, redisClient),
+		history:         newQueryHistory(cfg, redisClient),
+		tenants:         newTenantStore(cfg, redisClient, db),
+		quotas:          newQuotaCounter(cfg, redisClient, db),
+		access:          access,
+		oidc:            auth.NewProvider(cfg.Gateway.OIDC),
+		login:           login,
+		sharing:         newSharing(cfg, redisClient),
+		coalescer:       newCoalescer(cfg),
+		responses:       newResponseCache(cfg),
+		instant:         instant.New(cfg),
+		images:          newImageProxy(cfg),
+		pipeline:        pipeline.NewEngine(safetyClient, searchClient, llmClient, inferenceClient),
+		notifier:        notifier,
+		flags:           featureFlags,
+		drain:           newDrainer(),
+		db:              db,
+		outbox:          outbox,
+	}
+	g.prefetcher = newPrefetcher(cfg, g.pipeline)
+	if snippets := cfg.Gateway.Snippets; snippets.Enabled {
+		g.snippets = pipeline.NewSnippetCleaner(snippets.Boilerplate, snippets.Injection)
+	}
+	if routing := cfg.Gateway.ModelRouting; routing.Enabled {
+		if routing.SmallModel == "" || routing.LargeModel == "" {
+			return nil, fmt.Errorf("gateway.model_routing needs both a small_model and a large_model")
+		}
+		g.modelRouter = pipeline.NewModelRouter(routing.SmallModel, routing.LargeModel, routing.MaxSmallTokens, routing.MaxSmallSources, routing.ComplexQueryTypes)
+	}
+	if g.prompts, err = newPromptAudit(cfg, redisClient); err != nil {
+		return nil, err
+	}
+	if g.ips, err = privacy.New(cfg.Privacy); err != nil {
+		return nil, err
+	}
+	if cfg.Gateway.Exports.Enabled {
+		if g.objects, err = objectstore.New(cfg.Gateway.Exports.Storage); err != nil {
+			return nil, err
+		}
+	}
+	g.shadow, err = newShadower(cfg, llmClient, dialOpts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Gateway.GraphQL.Enabled {
+		if g.graphql, err = g.newGraphQLSchema(); err != nil {
+			return nil, err
+		}
+	}
+	g.probes = g.newProbes(redisClient)
+	g.diagnostics, err = diagnostics.New(cfg, "gateway", diagnostics.Sources{Conns: map[string]*grpc.ClientConn{
+		"search":    searchConn,
+		"safety":    safetyConn,
+		"llm":       llmConn,
+		"inference": inferenceConn,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	g.scheduler = newScheduler(g, withRunEvents(cfg, db, newQueryStore(cfg, redisClient, db)))
+	if g.scheduler != nil {
+		go g.scheduler.loop(context.Background())
+	}
+	g.batches = newBatching(cfg, redisClient)
+	if g.outbox != nil {
+		go g.outbox.relay(context.Background())
+	}
+	if g.maintenance, err = g.newMaintenance(redisClient); err != nil {
+		return nil, err
 	}
+	g.maintenance.Start(context.Background())
 
 	return g, nil
 }
 
+// RegisterAPIRoutes registers the /api/v1 handlers on the given group. All
+// but the OpenAPI spec, the GraphQL schema and the feed route, which feed
+// readers reach with the feed token alone, run as the tenant resolved from the request and,
+// with web UI login, as the logged-in user. Parameters and JSON bodies are
+// validated against the OpenAPI spec. Request bodies are bounded by
+// gateway.limits, except document uploads and batch files which their
+// handlers bound themselves.
+func (g *Gateway) RegisterAPIRoutes(root *gin.RouterGroup) {
+	root.GET("/openapi.json", g.OpenAPISpec)
+	root.GET("/graphql/schema.graphql", g.GraphQLSchema)
+	root.GET("/saved-queries/:id/feed", g.validateParams, g.QueryFeed)
+	tenanted := root.Group("", g.loadSession, g.resolveTenant, g.validateParams)
+
+	// Document ingestion into the vector store
+	tenanted.POST("/documents", g.IngestDocument)
+	tenanted.POST("/documents/bulk", g.IngestDocuments)
+	tenanted.GET("/documents/jobs/:id", g.GetIngestionJob)
+
+	// Batch summarization of a file of queries
+	tenanted.POST("/batch", g.SubmitBatch)
+	tenanted.GET("/batch/:id", g.GetBatch)
+	tenanted.GET("/batch/:id/results", g.BatchResults)
+
+	api := tenanted.Group("", g.limitBody)
+
+	// Single search endpoint (handles both streaming and non-streaming)
+	// Event streams get server.stream_write_timeout instead of write_timeout;
+	// searches are refused once the gateway drains for shutdown
+	api.POST("/search", g.trackSearch, g.streamTimeout, g.Search) // Non-streaming: JSON body
+	api.GET("/search", g.trackSearch, g.streamTimeout, g.Search)  // Streaming: query params + Accept: text/event-stream
+
+	// GraphQL over search, history, usage and models; subscriptions stream
+	api.POST("/graphql", g.trackSearch, g.streamTimeout, g.GraphQL)
+	api.GET("/graphql", g.trackSearch, g.streamTimeout, g.GraphQL)
+
+	// Utility endpoints
+	api.POST("/validate", g.ValidateInput)
+	api.GET("/suggest", g.Suggest)
+	api.GET("/usage", g.Usage)
+
+	// Partial results of interrupted streams
+	api.GET("/search/partial/:id", g.GetPartial)
+	api.GET("/search/partial/:id/continue", g.trackSearch, g.streamTimeout, g.ContinuePartial)
+
+	// Stopping a running streaming search
+	api.POST("/search/:task_id/cancel", g.CancelSearch)
+
+	// Reports of completed searches
+	api.GET("/search/:task_id/export", g.ExportSearch)
+	api.POST("/search/:task_id/share", g.ShareSearch)
+	api.DELETE("/search/:task_id/share/:share_id", g.RevokeShare)
+
+	// Saved queries re-run on a schedule (news monitoring)
+	api.POST("/saved-queries", g.CreateSavedQuery)
+	api.GET("/saved-queries", g.ListSavedQueries)
+	api.GET("/saved-queries/:id", g.GetSavedQuery)
+	api.DELETE("/saved-queries/:id", g.DeleteSavedQuery)
+	api.GET("/saved-queries/:id/runs", g.ListQueryRuns)
+	api.POST("/saved-queries/:id/run", g.RunSavedQuery)
+	api.POST("/saved-queries/:id/feed-token", g.RotateFeedToken)
+
+	// The logged-in web UI user
+	api.GET("/me", g.CurrentUser)
+	api.GET("/me/history", g.UserSearchHistory)
+
+	// Erasure of everything kept about a user (GDPR)
+	api.DELETE("/users/:id/data", g.DeleteUserData)
+}
+
 func (g *Gateway) HealthCheck(c *gin.Context) {
+	if draining, _ := g.drain.isDraining(); draining {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":    "draining",
+			"service":   "gateway",
+			"timestamp": time.Now().Unix(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "healthy",
 		"service":   "gateway",
@@ -115,7 +396,9 @@ func (g *Gateway) HealthCheck(c *gin.Context) {
 
 func (g *Gateway) Index(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.html", gin.H{
-		"title": "AI Search Engine",
+		"title":      "AI Search Engine",
+		"user":       g.user(c),
+		"htmlSearch": g.config.Gateway.HTMLSearch.Enabled, // the form's target without JavaScript
 	})
 }
 
@@ -124,17 +407,16 @@ func (g *Gateway) ValidateInput(c *gin.Context) {
 		Text string `json:"text" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), g.config.Services.Safety.Timeout)
+	ctx, cancel := g.stageContext(c, pipeline.StageValidate)
 	defer cancel()
 
 	resp, err := g.safetyClient.ValidateInput(ctx, &pb.ValidateInputRequest{
 		Text:     req.Text,
-		ClientIp: c.ClientIP(),
+		ClientIp: g.clientIP(c),
 	})
 
 	if err != nil {
@@ -157,11 +439,11 @@ func (g *Gateway) Metrics(c *gin.Context) {
 func (g *Gateway) Search(c *gin.Context) {
 	start := time.Now()
 	log := logger.GetLogger()
-	
+
 	// Debug: Log request details
-	log.Infof("🔍 Search request - Method: %s, Accept: %s, ContentType: %s", 
+	log.Infof("🔍 Search request - Method: %s, Accept: %s, ContentType: %s",
 		c.Request.Method, c.GetHeader("Accept"), c.GetHeader("Content-Type"))
-	
+
 	// Determine mode based on request method and parameters
 	if c.Request.Method == "GET" {
 		// GET requests with query params are streaming mode
@@ -183,64 +465,96 @@ func (g *Gateway) searchWithStreaming(c *gin.Context, start time.Time) {
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Cache-Control")
-	
+	c.Header("Access-Control-Allow-Headers", "Cache-Control, Last-Event-ID")
+
+	// Reconnecting client: replay buffered events instead of restarting the pipeline
+	if g.resumeStream(c) {
+		return
+	}
+
 	// Get query parameters
 	query := c.Query("query")
 	safeSearchStr := c.Query("safe_search")
 	numResultsStr := c.Query("num_results")
-	
+
 	if query == "" {
 		c.SSEvent("error", gin.H{"message": "Query parameter required"})
 		return
 	}
-	
+	if g.queryTooLong(c, query, true) {
+		return
+	}
+
 	// Parse parameters
 	safeSearch := safeSearchStr == "true"
-	numResults := 5
+	numResults := 0
 	if numResultsStr != "" {
 		if parsed, err := strconv.Atoi(numResultsStr); err == nil {
 			numResults = parsed
 		}
 	}
-	
+	numResults = g.clampNumResults(c, numResults)
+
 	// Check system capacity
 	if !g.checkSystemCapacity() {
 		monitoring.RecordRequest("gateway", "search", "rejected")
 		c.SSEvent("error", gin.H{
-			"message": "System overloaded, please try again later",
+			"message":     "System overloaded, please try again later",
 			"retry_after": 30,
 		})
 		return
 	}
-	
+	if g.overQuota(c, true) {
+		return
+	}
+
 	// Record metrics
 	monitoring.RecordRequest("gateway", "search", "success")
 	monitoring.RecordRequestDuration("gateway", "search", time.Since(start))
-	
-	// Start processing and stream results immediately
-	g.processAndStreamSearch(c, query, safeSearch, numResults)
+
+	// Start processing and stream results immediately; identical concurrent
+	// queries share one pipeline run
+	g.coalesceStream(c, "stream", coalesceKey(g.tenant(c), "stream", query, safeSearch, numResults, g.coalesceVariant(c)), func() {
+		g.processAndStreamSearch(c, query, safeSearch, numResults)
+	})
 }
 
 // searchWithoutStreaming handles non-streaming requests with SSE (search results first, then complete summary)
 func (g *Gateway) searchWithoutStreaming(c *gin.Context, start time.Time) {
 	log := logger.GetLogger()
 	log.Infof("📝 Non-streaming function called - parsing JSON body")
-	
+
 	var req SearchRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Errorf("Failed to parse JSON body: %v", err)
+	if !bindJSON(c, &req) {
+		log.Warnf("Rejected search request body with status %d", c.Writer.Status())
 		monitoring.RecordRequest("gateway", "search", "error")
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	
+
 	log.Infof("✅ Parsed JSON - Query: %s, SafeSearch: %t, NumResults: %d", req.Query, req.SafeSearch, req.NumResults)
-	
+	if req.Locale != "" {
+		c.Set(localeKey, req.Locale)
+	}
+	if req.Sampling != nil {
+		if errs := req.Sampling.validate(g.config.Gateway.Sampling); len(errs) > 0 {
+			monitoring.RecordRequest("gateway", "search", "error")
+			rejectFields(c, errs)
+			return
+		}
+		c.Set(samplingKey, req.Sampling)
+	}
+	if req.Deterministic {
+		c.Set(samplingKey, req.Sampling.deterministic(g.config.Gateway.Sampling.DeterministicSeed))
+	}
+
 	// Check if client wants SSE (Accept header includes text/event-stream)
 	acceptHeader := c.GetHeader("Accept")
 	wantsSSE := strings.Contains(acceptHeader, "text/event-stream")
-	
+	if g.queryTooLong(c, req.Query, wantsSSE) {
+		return
+	}
+	numResults := g.clampNumResults(c, req.NumResults)
+
 	// Check system capacity
 	if !g.checkSystemCapacity() {
 		monitoring.RecordRequest("gateway", "search", "rejected")
@@ -250,7 +564,7 @@ func (g *Gateway) searchWithoutStreaming(c *gin.Context, start time.Time) {
 			c.Header("Cache-Control", "no-cache")
 			c.Header("Connection", "keep-alive")
 			c.SSEvent("error", gin.H{
-				"message": "System overloaded, please try again later",
+				"message":     "System overloaded, please try again later",
 				"retry_after": 30,
 			})
 		} else {
@@ -261,32 +575,42 @@ func (g *Gateway) searchWithoutStreaming(c *gin.Context, start time.Time) {
 		}
 		return
 	}
-	
+
 	if wantsSSE {
 		// Set SSE headers for non-streaming mode (like streaming, but complete summary)
 		c.Header("Content-Type", "text/event-stream")
 		c.Header("Cache-Control", "no-cache")
 		c.Header("Connection", "keep-alive")
 		c.Header("X-Accel-Buffering", "no") // Disable nginx buffering
-		
-		// Process search with SSE events (search results first, then complete AI summary)
-		numResults := req.NumResults
-		if numResults == 0 {
-			numResults = 5
+
+		if g.resumeStream(c) {
+			return
 		}
-		
-		g.processNonStreamingSSE(c, req.Query, req.SafeSearch, numResults)
+
+		// Process search with SSE events (search results first, then complete AI summary)
+		g.idempotent(c, req, "sse", func(c *gin.Context) {
+			if g.overQuota(c, true) {
+				return
+			}
+			g.coalesceStream(c, "sse", coalesceKey(g.tenant(c), "sse", req.Query, req.SafeSearch, numResults, g.coalesceVariant(c)), func() {
+				g.processNonStreamingSSE(c, req.Query, req.SafeSearch, numResults)
+			})
+		})
 	} else {
-		// Process as regular JSON response (non-SSE mode)
-		numResults := req.NumResults
-		if numResults == 0 {
-			numResults = 5
-		}
-		
-		// Process the search synchronously and return JSON
-		g.processNonStreamingJSON(c, req.Query, req.SafeSearch, numResults)
+		// Process the search synchronously and return JSON; identical concurrent
+		// queries share one pipeline run, and repeated ones may be answered
+		// from the response cache
+		g.idempotent(c, req, "json", func(c *gin.Context) {
+			if g.overQuota(c, false) {
+				return
+			}
+			key := coalesceKey(g.tenant(c), "json", req.Query, req.SafeSearch, numResults, g.coalesceVariant(c))
+			g.coalesceJSON(c, key, func(c *gin.Context) {
+				g.cachedJSON(c, key, req.Query, req.SafeSearch, numResults)
+			})
+		})
 	}
-	
+
 	// Record metrics
 	monitoring.RecordRequest("gateway", "search", "success")
 	monitoring.RecordRequestDuration("gateway", "search", time.Since(start))
@@ -294,464 +618,36 @@ func (g *Gateway) searchWithoutStreaming(c *gin.Context, start time.Time) {
 
 // processAndStreamSearch handles streaming search with immediate response
 func (g *Gateway) processAndStreamSearch(c *gin.Context, query string, safeSearch bool, numResults int) {
-	ctx := context.Background()
-	log := logger.GetLogger()
-	
-	// 1. Send initial status
-	c.SSEvent("status", gin.H{
-		"type": "started",
-		"query": query,
-		"timestamp": time.Now().Unix(),
-	})
-	c.Writer.Flush()
-	
-	// 2. Validate input
-	c.SSEvent("status", gin.H{"type": "validating"})
-	c.Writer.Flush()
-	
-	safetyResp, err := g.safetyClient.ValidateInput(ctx, &pb.ValidateInputRequest{
-		Text:       query,
-		ClientIp:   c.ClientIP(),
-		SafeSearch: safeSearch,
-	})
-	if err != nil {
-		log.Errorf("Safety validation failed: %v", err)
-		c.SSEvent("error", gin.H{"message": "Safety validation failed"})
-		return
-	}
-	
-	if !safetyResp.IsSafe {
-		c.SSEvent("error", gin.H{"message": "Query contains unsafe content"})
-		return
-	}
-	
-	// 3. Perform search
-	c.SSEvent("status", gin.H{"type": "searching"})
-	c.Writer.Flush()
-	
-	searchResp, err := g.searchClient.Search(ctx, &pb.SearchRequest{
-		Query:      safetyResp.SanitizedText,
-		SafeSearch: safeSearch,
-		NumResults: int32(numResults),
-	})
-	if err != nil {
-		log.Errorf("Search failed: %v", err)
-		c.SSEvent("error", gin.H{"message": "Search failed"})
-		return
-	}
-	
-	if !searchResp.Success {
-		c.SSEvent("error", gin.H{"message": searchResp.Error})
+	if g.streamInstantAnswer(c, query, safeSearch) {
 		return
 	}
-	
-	// 4. Stream search results immediately
-	searchResults := make([]SearchResult, len(searchResp.Results))
-	for i, result := range searchResp.Results {
-		searchResults[i] = SearchResult{
-			Title:      result.Title,
-			URL:        result.Url,
-			Snippet:    result.Snippet,
-			DisplayURL: result.DisplayUrl,
-		}
-	}
-	
-	c.SSEvent("search_results", gin.H{
-		"type": "search_results",
-		"results": searchResults,
-	})
-	c.Writer.Flush()
-	
-	// 5. Start AI summarization
-	c.SSEvent("status", gin.H{"type": "summarizing"})
-	c.Writer.Flush()
-	
-	// Prepare text for summarization
-	var textToSummarize string
-	for _, result := range searchResults {
-		textToSummarize += result.Title + " " + result.Snippet + " "
-	}
-	
-	// Submit LLM request to orchestrator service
-	llmReq := &pb.LLMRequest{
-		Id:        fmt.Sprintf("stream_%d", time.Now().UnixNano()),
-		Text:      textToSummarize,
-		MaxTokens: 150,
-		Stream:    true,
-		CreatedAt: time.Now().Unix(),
-	}
-	
-	// Process the request using streaming method
-	ctx, cancel := context.WithTimeout(context.Background(), g.config.Services.LLM.Timeout)
-	defer cancel()
-	
-	stream, err := g.llmClient.StreamRequest(ctx, llmReq)
-	if err != nil {
-		log.Errorf("Failed to start LLM stream: %v", err)
-		c.SSEvent("error", gin.H{"message": "Failed to start AI summarization"})
-		return
-	}
-
-	// Collect tokens for safety validation
-	var completeSummary strings.Builder
-	
-	// Stream tokens as they arrive
-	for {
-		response, err := stream.Recv()
-		if err != nil {
-			if err.Error() == "EOF" {
-				// Stream completed - validate and send final summary
-				finalSummary := completeSummary.String()
-				if finalSummary != "" {
-					safetyCtx, safetyCancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer safetyCancel()
-					
-					sanitizeResp, err := g.safetyClient.SanitizeOutput(safetyCtx, &pb.SanitizeOutputRequest{
-						Text: finalSummary,
-					})
-					if err != nil {
-						log.Errorf("Streaming output sanitization failed: %v", err)
-						c.SSEvent("error", gin.H{"message": "Summary sanitization failed"})
-						return
-					}
-					
-					if len(sanitizeResp.Warnings) > 0 {
-						log.Warnf("Streaming AI output sanitized with warnings: %v", sanitizeResp.Warnings)
-					}
-					
-					// Send sanitized summary if different from original
-					if sanitizeResp.SanitizedText != finalSummary {
-						log.Warnf("AI output was modified by safety filter")
-						c.SSEvent("summary_sanitized", gin.H{
-							"type": "summary_sanitized", 
-							"original_length": len(finalSummary),
-							"sanitized_length": len(sanitizeResp.SanitizedText),
-							"warnings": sanitizeResp.Warnings,
-						})
-					}
-				}
-				
-				c.SSEvent("complete", gin.H{"type": "complete"})
-				return
-			}
-			log.Errorf("Stream error: %v", err)
-			c.SSEvent("error", gin.H{"message": "Streaming error"})
-			return
-		}
-
-		// Handle error in response
-		if response.Error != "" {
-			c.SSEvent("error", gin.H{"message": response.Error})
-			return
-		}
-
-		// Send token if available and collect for safety validation
-		if response.Token != "" {
-			// Collect token for final safety check
-			completeSummary.WriteString(response.Token)
-			
-			// Send token to user for real-time display
-			c.SSEvent("token", gin.H{
-				"type": "token",
-				"token": response.Token,
-				"position": response.Position,
-			})
-			c.Writer.Flush()
-		}
-
-		// Check if final
-		if response.IsFinal {
-			// Validate complete summary before finalizing
-			finalSummary := completeSummary.String()
-			if finalSummary != "" {
-				safetyCtx, safetyCancel := context.WithTimeout(context.Background(), 5*time.Second)
-				defer safetyCancel()
-				
-				sanitizeResp, err := g.safetyClient.SanitizeOutput(safetyCtx, &pb.SanitizeOutputRequest{
-					Text: finalSummary,
-				})
-				if err != nil {
-					log.Errorf("Streaming output sanitization failed: %v", err)
-					c.SSEvent("error", gin.H{"message": "Summary sanitization failed"})
-					return
-				}
-				
-				if len(sanitizeResp.Warnings) > 0 {
-					log.Warnf("Streaming AI output sanitized with warnings: %v", sanitizeResp.Warnings)
-				}
-				
-				// Check if content was modified by safety filter
-				if sanitizeResp.SanitizedText != finalSummary {
-					log.Warnf("AI output was modified by safety filter - notifying user")
-					c.SSEvent("summary_sanitized", gin.H{
-						"type": "summary_sanitized", 
-						"message": "Summary was filtered for safety",
-						"warnings": sanitizeResp.Warnings,
-					})
-				}
-			}
-			
-			c.SSEvent("summary", gin.H{"type": "summary"})
-			c.SSEvent("complete", gin.H{"type": "complete"})
-			return
-		}
-	}
+	numResults, budget := g.capFetchedPages(numResults)
+	emit := &sseTokenEmitter{sseEmitter: g.newSSEEmitter(c, budget)}
+	defer g.cancels.track(c, g.tenant(c).ID, emit.events.id)()
+	g.pipeline.Run(g.pipelineRequest(c, "stream", query, safeSearch, numResults), emit)
+	emit.finish()
 }
 
-
 // processNonStreamingSSE handles non-streaming search with SSE (search results first, then complete AI summary)
 func (g *Gateway) processNonStreamingSSE(c *gin.Context, query string, safeSearch bool, numResults int) {
-	ctx := context.Background()
-	log := logger.GetLogger()
-	
-	// 1. Send initial status
-	c.SSEvent("status", gin.H{
-		"type": "started",
-		"query": query,
-		"timestamp": time.Now().Unix(),
-	})
-	c.Writer.Flush()
-	
-	// 2. Validate input
-	c.SSEvent("status", gin.H{"type": "validating"})
-	c.Writer.Flush()
-	
-	safetyResp, err := g.safetyClient.ValidateInput(ctx, &pb.ValidateInputRequest{
-		Text:       query,
-		ClientIp:   c.ClientIP(),
-		SafeSearch: safeSearch,
-	})
-	if err != nil {
-		log.Errorf("Safety validation failed: %v", err)
-		c.SSEvent("error", gin.H{"message": "Safety validation failed"})
-		return
-	}
-	
-	if !safetyResp.IsSafe {
-		c.SSEvent("error", gin.H{"message": "Query contains unsafe content"})
-		return
-	}
-	
-	// 3. Perform search
-	c.SSEvent("status", gin.H{"type": "searching"})
-	c.Writer.Flush()
-	
-	searchResp, err := g.searchClient.Search(ctx, &pb.SearchRequest{
-		Query:      safetyResp.SanitizedText,
-		SafeSearch: safeSearch,
-		NumResults: int32(numResults),
-	})
-	if err != nil {
-		log.Errorf("Search failed: %v", err)
-		c.SSEvent("error", gin.H{"message": "Search failed"})
-		return
-	}
-	
-	if !searchResp.Success {
-		c.SSEvent("error", gin.H{"message": searchResp.Error})
-		return
-	}
-	
-	// 4. IMMEDIATELY stream search results (like streaming mode)
-	searchResults := make([]SearchResult, len(searchResp.Results))
-	for i, result := range searchResp.Results {
-		searchResults[i] = SearchResult{
-			Title:      result.Title,
-			URL:        result.Url,
-			Snippet:    result.Snippet,
-			DisplayURL: result.DisplayUrl,
-		}
-	}
-	
-	c.SSEvent("search_results", gin.H{
-		"type": "search_results",
-		"results": searchResults,
-	})
-	c.Writer.Flush()
-	
-	log.Infof("🔍 Non-streaming SSE: Search results sent, now generating complete AI summary...")
-	
-	// 5. Start AI summarization
-	c.SSEvent("status", gin.H{"type": "summarizing"})
-	c.Writer.Flush()
-	
-	// Prepare text for summarization
-	var textToSummarize string
-	for _, result := range searchResults {
-		textToSummarize += result.Title + " " + result.Snippet + " "
-	}
-	
-	// Submit NON-STREAMING LLM request (complete summary, not token-by-token)
-	llmReq := &pb.LLMRequest{
-		Id:        fmt.Sprintf("nonstream_sse_%d", time.Now().UnixNano()),
-		Text:      textToSummarize,
-		MaxTokens: 150,
-		Stream:    false, // Key difference: complete summary at once
-		CreatedAt: time.Now().Unix(),
-	}
-	
-	// Get complete AI summary
-	response, err := g.llmClient.ProcessRequest(ctx, llmReq)
-	if err != nil {
-		log.Errorf("Failed to process LLM request: %v", err)
-		c.SSEvent("error", gin.H{"message": "AI summarization failed"})
+	if g.streamInstantAnswer(c, query, safeSearch) {
 		return
 	}
-	
-	var summary string
-	if response.Error != "" {
-		log.Infof("LLM response has error: %s", response.Error)
-		summary = "Summary unavailable"
-	} else {
-		rawSummary := response.Summary
-		if rawSummary == "" {
-			// Reconstruct from tokens
-			for _, token := range response.Tokens {
-				rawSummary += token
-			}
-		}
-		
-		// CRITICAL: Sanitize AI output before returning to user
-		safetyCtx, safetyCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer safetyCancel()
-		
-		sanitizeResp, err := g.safetyClient.SanitizeOutput(safetyCtx, &pb.SanitizeOutputRequest{
-			Text: rawSummary,
-		})
-		
-		if err != nil {
-			log.Errorf("Failed to sanitize AI output: %v", err)
-			summary = "Summary sanitization failed"
-		} else {
-			summary = sanitizeResp.SanitizedText
-		}
-	}
-	
-	// 6. Send complete AI summary at once (not token-by-token like streaming)
-	c.SSEvent("summary", gin.H{
-		"type": "summary_complete", // Different type to distinguish from streaming
-		"text": summary,
-	})
-	c.Writer.Flush()
-	
-	log.Infof("✅ Non-streaming SSE completed - sent search results first, then complete AI summary")
-	
-	// 7. Send completion signal
-	c.SSEvent("complete", gin.H{"type": "complete"})
-	c.Writer.Flush()
+	numResults, budget := g.capFetchedPages(numResults)
+	emit := &sseBatchEmitter{sseEmitter: g.newSSEEmitter(c, budget)}
+	defer g.cancels.track(c, g.tenant(c).ID, emit.events.id)()
+	g.pipeline.Run(g.pipelineRequest(c, "nonstream_sse", query, safeSearch, numResults), emit)
 }
 
 // processNonStreamingJSON handles non-streaming search with JSON response
 func (g *Gateway) processNonStreamingJSON(c *gin.Context, query string, safeSearch bool, numResults int) {
-	ctx := context.Background()
-	log := logger.GetLogger()
-	
-	// 1. Validate input
-	safetyResp, err := g.safetyClient.ValidateInput(ctx, &pb.ValidateInputRequest{
-		Text:       query,
-		ClientIp:   c.ClientIP(),
-		SafeSearch: safeSearch,
-	})
-	if err != nil {
-		log.Errorf("Safety validation failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Safety validation failed"})
+	if answer := g.instantAnswer(c, query, safeSearch); answer != nil {
+		c.JSON(http.StatusOK, SearchResponse{Query: query, Status: "completed", InstantAnswer: answer})
 		return
 	}
-	
-	if !safetyResp.IsSafe {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query contains unsafe content"})
-		return
-	}
-	
-	// 2. Perform search
-	searchResp, err := g.searchClient.Search(ctx, &pb.SearchRequest{
-		Query:      safetyResp.SanitizedText,
-		SafeSearch: safeSearch,
-		NumResults: int32(numResults),
-	})
-	if err != nil {
-		log.Errorf("Search failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
-		return
-	}
-	
-	if !searchResp.Success {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": searchResp.Error})
-		return
-	}
-	
-	// 3. Convert search results
-	searchResults := make([]SearchResult, len(searchResp.Results))
-	for i, result := range searchResp.Results {
-		searchResults[i] = SearchResult{
-			Title:      result.Title,
-			URL:        result.Url,
-			Snippet:    result.Snippet,
-			DisplayURL: result.DisplayUrl,
-		}
-	}
-	
-	// 4. Generate AI summary
-	var textToSummarize string
-	for _, result := range searchResults {
-		textToSummarize += result.Title + " " + result.Snippet + " "
-	}
-	
-	// Submit NON-STREAMING LLM request
-	llmReq := &pb.LLMRequest{
-		Id:        fmt.Sprintf("json_%d", time.Now().UnixNano()),
-		Text:      textToSummarize,
-		MaxTokens: 150,
-		Stream:    false,
-		CreatedAt: time.Now().Unix(),
-	}
-	
-	// Get complete AI summary
-	response, err := g.llmClient.ProcessRequest(ctx, llmReq)
-	if err != nil {
-		log.Errorf("Failed to process LLM request: %v", err)
-		c.JSON(http.StatusOK, SearchResponse{
-			Query:         query,
-			Status:        "completed",
-			SearchResults: searchResults,
-			Summary:       "AI summarization failed",
-		})
-		return
-	}
-	
-	var summary string
-	if response.Error != "" {
-		log.Infof("LLM response has error: %s", response.Error)
-		summary = "Summary unavailable"
-	} else {
-		rawSummary := response.Summary
-		if rawSummary == "" {
-			// Reconstruct from tokens
-			for _, token := range response.Tokens {
-				rawSummary += token
-			}
-		}
-		
-		// Sanitize AI output
-		sanitizeResp, err := g.safetyClient.SanitizeOutput(ctx, &pb.SanitizeOutputRequest{
-			Text: rawSummary,
-		})
-		
-		if err != nil {
-			log.Errorf("Failed to sanitize AI output: %v", err)
-			summary = "Summary sanitization failed"
-		} else {
-			summary = sanitizeResp.SanitizedText
-		}
-	}
-	
-	// 5. Return complete response
-	c.JSON(http.StatusOK, SearchResponse{
-		Query:         query,
-		Status:        "completed",
-		SearchResults: searchResults,
-		Summary:       summary,
-	})
+	numResults, budget := g.capFetchedPages(numResults)
+	emit := &jsonEmitter{g: g, c: c, budget: budget}
+	g.pipeline.Run(g.pipelineRequest(c, "json", query, safeSearch, numResults), emit)
 }
 
 // checkSystemCapacity checks if the system can handle more requests
@@ -0,0 +1,543 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/graphql"
+	"ai-search-service/internal/instant"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+	pb "ai-search-service/proto"
+)
+
+// graphqlRequestKey holds the *graphqlRequest in the context resolvers get
+type graphqlRequestKey struct{}
+
+// graphqlRequest is the HTTP request a GraphQL operation runs for
+type graphqlRequest struct {
+	c        *gin.Context
+	searches int // search fields resolved so far
+}
+
+func requestOf(p graphql.ResolveParams) *graphqlRequest {
+	return p.Context.Value(graphqlRequestKey{}).(*graphqlRequest)
+}
+
+// graphqlError is a field error carrying the HTTP status the REST endpoint
+// would have answered with
+func graphqlError(status int, format string, args ...interface{}) *graphql.Error {
+	return &graphql.Error{
+		Message:    fmt.Sprintf(format, args...),
+		Extensions: map[string]interface{}{"status": status},
+	}
+}
+
+// GraphQL serves /api/v1/graphql. Queries come as a JSON body of POST
+// ({"query", "operationName", "variables"}) or as the same parameters of
+// GET, variables JSON encoded, and are answered with JSON. Subscriptions
+// need Accept: text/event-stream and are answered with a "next" event per
+// result and a "complete" event at the end, as in the graphql-sse protocol;
+// queries sent that way get a single "next". Requests that do not parse or
+// validate get a 400, field errors a 200 with the errors next to the data.
+func (g *Gateway) GraphQL(c *gin.Context) {
+	start := time.Now()
+	if g.graphql == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GraphQL is disabled"})
+		return
+	}
+	var req graphql.Request
+	if c.Request.Method == http.MethodGet {
+		req.Query, req.OperationName = c.Query("query"), c.Query("operationName")
+		if vars := c.Query("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				rejectFields(c, []FieldError{{Field: "variables", In: "query", Message: "must be a JSON object"}})
+				return
+			}
+		}
+	} else if !bindJSON(c, &req) {
+		monitoring.RecordRequest("gateway", "graphql", "error")
+		return
+	}
+
+	stream := strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+	op, errs := g.graphql.Prepare(&req)
+	if errs == nil && op.Kind() == "subscription" && !stream {
+		errs = []*graphql.Error{{Message: "Subscriptions are delivered as an event stream; request them with Accept: text/event-stream"}}
+	}
+	if errs != nil {
+		monitoring.RecordRequest("gateway", "graphql", "error")
+		writeGraphQL(c, stream, http.StatusBadRequest, &graphql.Response{Errors: errs})
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), graphqlRequestKey{}, &graphqlRequest{c: c})
+	if op.Kind() != "subscription" {
+		writeGraphQL(c, stream, http.StatusOK, op.Execute(ctx))
+	} else {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		results, errs := op.Subscribe(ctx)
+		if errs != nil {
+			writeGraphQL(c, true, http.StatusOK, &graphql.Response{Errors: errs})
+			return
+		}
+		for result := range results {
+			c.SSEvent("next", result)
+			c.Writer.Flush()
+		}
+		c.SSEvent("complete", "")
+	}
+	monitoring.RecordRequest("gateway", "graphql", "success")
+	monitoring.RecordRequestDuration("gateway", "graphql", time.Since(start))
+}
+
+// writeGraphQL answers with one response, as JSON or as a "next" event
+// followed by "complete"
+func writeGraphQL(c *gin.Context, stream bool, status int, resp *graphql.Response) {
+	if !stream {
+		c.JSON(status, resp)
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(status)
+	c.SSEvent("next", resp)
+	c.SSEvent("complete", "")
+	c.Writer.Flush()
+}
+
+// GraphQLSchema serves the schema of /api/v1/graphql in the schema
+// definition language at /api/v1/graphql/schema.graphql, for code
+// generators; the endpoint does not answer introspection queries
+func (g *Gateway) GraphQLSchema(c *gin.Context) {
+	if g.graphql == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GraphQL is disabled"})
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(g.graphql.SDL()+"\n"))
+}
+
+// searchEvent is an event of a search subscription: the events of the
+// streaming search endpoint as one type, Type telling them apart
+type searchEvent struct {
+	Type             string                   `json:"type"`
+	Query            string                   `json:"query,omitempty"`
+	Status           string                   `json:"status,omitempty"`
+	SearchResults    []SearchResult           `json:"search_results,omitempty"`
+	StructuredAnswer *answers.Data            `json:"structured_answer,omitempty"`
+	KnowledgePanel   *pipeline.KnowledgePanel `json:"knowledge_panel,omitempty"`
+	InstantAnswer    *instant.Answer          `json:"instant_answer,omitempty"`
+	Token            string                   `json:"token,omitempty"`
+	Position         int32                    `json:"position,omitempty"`
+	Summary          string                   `json:"summary,omitempty"`
+	Sanitized        bool                     `json:"sanitized,omitempty"`
+	Confidence       *pipeline.Confidence     `json:"confidence,omitempty"`
+	Answer           *pipeline.Answer         `json:"answer,omitempty"`
+	RelatedSearches  []string                 `json:"related_searches,omitempty"`
+	Budget           *BudgetReport            `json:"budget,omitempty"`
+	TaskID           string                   `json:"task_id,omitempty"`
+	Message          string                   `json:"message,omitempty"`
+}
+
+// searchArgs is a search field's arguments once admitted
+type searchArgs struct {
+	query      string
+	safeSearch bool
+	numResults int
+}
+
+// admitSearch checks a search field against gateway.graphql.max_searches,
+// the query length limit and the tenant's quota, which it is charged to
+func (g *Gateway) admitSearch(r *graphqlRequest, args map[string]interface{}) (*searchArgs, error) {
+	query := args["query"].(string)
+	r.searches++
+	if max := g.config.Gateway.GraphQL.MaxSearches; max > 0 && r.searches > max {
+		return nil, graphqlError(http.StatusBadRequest, "A request may search at most %d times", max)
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, graphqlError(http.StatusBadRequest, "query must not be empty")
+	}
+	if max, tooLong := g.checkQueryLength(query); tooLong {
+		return nil, graphqlError(http.StatusRequestEntityTooLarge, "Query exceeds %d characters", max)
+	}
+	if message, retryAfter, exceeded := g.chargeQuota(r.c); exceeded {
+		err := graphqlError(http.StatusTooManyRequests, "%s", message)
+		err.Extensions["retryAfter"] = retryAfter
+		return nil, err
+	}
+	locale, _ := args["locale"].(string)
+	r.c.Set(localeKey, locale)
+	numResults, _ := args["numResults"].(int)
+	safeSearch, _ := args["safeSearch"].(bool)
+	return &searchArgs{query: query, safeSearch: safeSearch, numResults: g.clampNumResults(r.c, numResults)}, nil
+}
+
+// graphqlSearch runs a search field to completion, like a JSON search
+func (g *Gateway) graphqlSearch(p graphql.ResolveParams) (interface{}, error) {
+	r := requestOf(p)
+	args, err := g.admitSearch(r, p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if answer := g.instantAnswer(r.c, args.query, args.safeSearch); answer != nil {
+		return &SearchResponse{Query: args.query, Status: "completed", InstantAnswer: answer}, nil
+	}
+	numResults, budget := g.capFetchedPages(args.numResults)
+	emit := &graphqlSearchEmitter{jsonEmitter: jsonEmitter{g: g, c: r.c, budget: budget}}
+	g.pipeline.Run(g.pipelineRequest(r.c, "graphql", args.query, args.safeSearch, numResults), emit)
+	if emit.err != nil {
+		return nil, graphqlError(emit.err.Status, "%s", emit.err.Message)
+	}
+	return emit.resp, nil
+}
+
+// graphqlSearchEmitter keeps a search's response for a query field instead
+// of writing it
+type graphqlSearchEmitter struct {
+	jsonEmitter
+	resp *SearchResponse
+	err  *pipeline.Error
+}
+
+func (e *graphqlSearchEmitter) Summary(summary pipeline.Summary) {
+	e.resp = e.response(summary, e.budget.merge(summary.Budget))
+	e.complete(e.resp)
+}
+
+func (e *graphqlSearchEmitter) Fail(err *pipeline.Error) {
+	// A failed summary still returns the search results with a placeholder
+	if err.Status == http.StatusOK {
+		e.resp = e.response(pipeline.Summary{Text: err.Message}, e.budget)
+		e.complete(e.resp)
+		return
+	}
+	e.err = err
+}
+
+// subscribeSearch streams a search field's pipeline run, token by token
+func (g *Gateway) subscribeSearch(p graphql.ResolveParams) (<-chan interface{}, error) {
+	r := requestOf(p)
+	args, err := g.admitSearch(r, p.Args)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan interface{})
+	go func() {
+		defer close(events)
+		emit := &graphqlStreamEmitter{g: g, c: r.c, ctx: p.Context, events: events}
+		if answer := g.instantAnswer(r.c, args.query, args.safeSearch); answer != nil {
+			emit.send(&searchEvent{Type: "INSTANT_ANSWER", Query: args.query, InstantAnswer: answer})
+			return
+		}
+		numResults, budget := g.capFetchedPages(args.numResults)
+		emit.budget = budget
+		g.pipeline.Run(g.pipelineRequest(r.c, "graphql_stream", args.query, args.safeSearch, numResults), emit)
+	}()
+	return events, nil
+}
+
+// graphqlStreamEmitter turns a pipeline run into subscription events
+type graphqlStreamEmitter struct {
+	g          *Gateway
+	c          *gin.Context
+	ctx        context.Context
+	events     chan<- interface{}
+	query      string
+	budget     *BudgetReport
+	results    []SearchResult
+	structured *answers.Data
+}
+
+// send delivers an event unless the client has gone away
+func (e *graphqlStreamEmitter) send(event *searchEvent) {
+	select {
+	case e.events <- event:
+	case <-e.ctx.Done():
+	}
+}
+
+func (e *graphqlStreamEmitter) Started(query string) {
+	e.query = query
+	e.send(&searchEvent{Type: "STARTED", Query: query})
+}
+
+func (e *graphqlStreamEmitter) Stage(stage pipeline.StageName) {
+	if status, ok := stageStatus[stage]; ok {
+		e.send(&searchEvent{Type: "STATUS", Status: status})
+	}
+}
+
+func (e *graphqlStreamEmitter) Results(results []pipeline.Result) {
+	e.results = e.g.proxyResultImages(results)
+	e.send(&searchEvent{Type: "RESULTS", SearchResults: e.results})
+}
+
+func (e *graphqlStreamEmitter) Structured(data *answers.Data) {
+	e.structured = data
+	e.send(&searchEvent{Type: "STRUCTURED_ANSWER", StructuredAnswer: data})
+}
+
+func (e *graphqlStreamEmitter) KnowledgePanel(panel *pipeline.KnowledgePanel) {
+	e.send(&searchEvent{Type: "KNOWLEDGE_PANEL", KnowledgePanel: e.g.proxyPanelImage(panel)})
+}
+
+func (e *graphqlStreamEmitter) Token(token string, position int32) {
+	e.send(&searchEvent{Type: "TOKEN", Token: token, Position: position})
+}
+
+func (e *graphqlStreamEmitter) Summary(summary pipeline.Summary) {
+	e.send(&searchEvent{
+		Type:            "SUMMARY",
+		Summary:         summary.Text,
+		Sanitized:       summary.Sanitized,
+		Confidence:      summary.Confidence,
+		Answer:          summary.Answer,
+		RelatedSearches: summary.RelatedSearches,
+		Budget:          e.budget.merge(summary.Budget),
+		TaskID:          e.g.searchCompleted(e.c, newStreamID(), e.query, e.results, taskSummary(summary.Text, e.structured)),
+	})
+}
+
+func (e *graphqlStreamEmitter) Fail(err *pipeline.Error) {
+	e.send(&searchEvent{Type: "ERROR", Message: err.Message})
+}
+
+// graphqlHistory is the logged-in user's recent searches
+func (g *Gateway) graphqlHistory(p graphql.ResolveParams) (interface{}, error) {
+	c := requestOf(p).c
+	session := g.user(c)
+	if session == nil {
+		return nil, graphqlError(http.StatusUnauthorized, "Not logged in")
+	}
+	limit := p.Args["limit"].(int)
+	if limit <= 0 {
+		return nil, graphqlError(http.StatusBadRequest, "limit must be a positive number")
+	}
+	entries, err := g.login.history.Recent(p.Context, g.historyKey(c, session), limit)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load search history of %s: %v", session.Subject, err)
+		return nil, graphqlError(http.StatusInternalServerError, "Failed to load search history")
+	}
+	return entries, nil
+}
+
+// graphqlUsage is the calling tenant's quota usage
+func (g *Gateway) graphqlUsage(p graphql.ResolveParams) (interface{}, error) {
+	c := requestOf(p).c
+	windows, err := g.quotaUsage(c)
+	if err != nil {
+		return nil, graphqlError(http.StatusInternalServerError, "Failed to read usage")
+	}
+	return map[string]interface{}{"tenant": g.tenant(c).ID, "quotas": windows}, nil
+}
+
+// graphqlModels is the model each generation backend serves
+func (g *Gateway) graphqlModels(p graphql.ResolveParams) (interface{}, error) {
+	resp, err := g.inferenceClient.ListModels(p.Context, &pb.ListModelsRequest{})
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to list models: %v", err)
+		return nil, graphqlError(http.StatusBadGateway, "Failed to list models")
+	}
+	return resp.Models, nil
+}
+
+var dateTime = &graphql.Scalar{
+	Name:        "DateTime",
+	Description: "A time in RFC 3339 format, in UTC.",
+	Serialize: func(v interface{}) (interface{}, error) {
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("DateTime cannot represent %v", v)
+		}
+		if t.IsZero() {
+			return nil, nil
+		}
+		return t.UTC().Format(time.RFC3339), nil
+	},
+	Parse: func(v interface{}) (interface{}, error) {
+		s, _ := v.(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("DateTime cannot represent %v", v)
+		}
+		return t, nil
+	},
+}
+
+// newGraphQLSchema declares the schema of /api/v1/graphql. Its fields are
+// those of the REST responses, camel-cased: search_results is
+// searchResults.
+func (g *Gateway) newGraphQLSchema() (*graphql.Schema, error) {
+	str, nonNullStr := graphql.String, graphql.NonNull(graphql.String)
+	strings := graphql.NonNull(graphql.ListOf(nonNullStr))
+	listOf := func(t graphql.Type) graphql.Type {
+		return graphql.NonNull(graphql.ListOf(graphql.NonNull(t)))
+	}
+
+	result := &graphql.Object{Name: "Result", Description: "A search result.", Fields: []*graphql.Field{
+		{Name: "title", Type: nonNullStr},
+		{Name: "url", Type: nonNullStr},
+		{Name: "snippet", Type: nonNullStr},
+		{Name: "displayUrl", Type: nonNullStr},
+		{Name: "source", Type: str, Description: "The provider that returned the result."},
+		{Name: "internal", Type: graphql.NonNull(graphql.Boolean), Description: "Whether the result is from the internal corpus rather than the web."},
+		{Name: "score", Type: graphql.Float, Description: "Normalized within its source, 0 to 1."},
+		{Name: "imageUrl", Type: str},
+		{Name: "paywalled", Type: graphql.NonNull(graphql.Boolean)},
+		{Name: "relevanceScore", Type: graphql.Float, Description: "The reranking model's grade for the query, 0 to 1."},
+	}}
+	confidence := &graphql.Object{Name: "Confidence", Description: "How well the search results support the summary.", Fields: []*graphql.Field{
+		{Name: "level", Type: nonNullStr, Description: "high, medium or low."},
+		{Name: "score", Type: graphql.NonNull(graphql.Float)},
+		{Name: "sources", Type: graphql.NonNull(graphql.Int)},
+		{Name: "words", Type: graphql.NonNull(graphql.Int)},
+		{Name: "modelProbability", Type: graphql.Float},
+		{Name: "groundedness", Type: graphql.NonNull(graphql.Float)},
+		{Name: "agreement", Type: graphql.NonNull(graphql.Float)},
+	}}
+	answer := &graphql.Object{Name: "Answer", Description: "The detected query type and the format of the summary.", Fields: []*graphql.Field{
+		{Name: "queryType", Type: nonNullStr},
+		{Name: "format", Type: nonNullStr},
+	}}
+	definition := &graphql.Object{Name: "Definition", Fields: []*graphql.Field{
+		{Name: "partOfSpeech", Type: str},
+		{Name: "definition", Type: nonNullStr},
+		{Name: "example", Type: str},
+	}}
+	instantAnswer := &graphql.Object{Name: "InstantAnswer", Description: "A calculation, conversion or definition, answered without searching.", Fields: []*graphql.Field{
+		{Name: "kind", Type: nonNullStr},
+		{Name: "input", Type: nonNullStr},
+		{Name: "result", Type: nonNullStr},
+		{Name: "value", Type: graphql.Float},
+		{Name: "unit", Type: str},
+		{Name: "definitions", Type: listOf(definition)},
+		{Name: "source", Type: str},
+	}}
+	fact := &graphql.Object{Name: "Fact", Fields: []*graphql.Field{
+		{Name: "label", Type: nonNullStr},
+		{Name: "value", Type: nonNullStr},
+	}}
+	structured := &graphql.Object{Name: "StructuredAnswer", Description: "An answer provider's data: weather, stocks or sports.", Fields: []*graphql.Field{
+		{Name: "kind", Type: nonNullStr},
+		{Name: "provider", Type: nonNullStr},
+		{Name: "title", Type: nonNullStr},
+		{Name: "headline", Type: nonNullStr},
+		{Name: "facts", Type: listOf(fact)},
+		{Name: "asOf", Type: str},
+		{Name: "source", Type: str},
+	}}
+	attribute := &graphql.Object{Name: "PanelAttribute", Fields: []*graphql.Field{
+		{Name: "name", Type: nonNullStr},
+		{Name: "value", Type: nonNullStr},
+	}}
+	panel := &graphql.Object{Name: "KnowledgePanel", Description: "The entity the query is about.", Fields: []*graphql.Field{
+		{Name: "name", Type: nonNullStr},
+		{Name: "type", Type: nonNullStr},
+		{Name: "description", Type: str},
+		{Name: "attributes", Type: listOf(attribute)},
+		{Name: "imageUrl", Type: str},
+		{Name: "sourceUrl", Type: str},
+	}}
+	budget := &graphql.Object{Name: "Budget", Description: "The per-request limits hit and the steps skipped because of them.", Fields: []*graphql.Field{
+		{Name: "outcome", Type: nonNullStr},
+		{Name: "limits", Type: strings},
+		{Name: "skipped", Type: strings},
+	}}
+	search := &graphql.Object{Name: "Search", Description: "A completed search, as POST /api/v1/search returns it.", Fields: []*graphql.Field{
+		{Name: "query", Type: nonNullStr},
+		{Name: "status", Type: nonNullStr},
+		{Name: "taskId", Type: str, Description: "Exports and shares the search while it is kept."},
+		{Name: "summary", Type: str},
+		{Name: "searchResults", Type: listOf(result)},
+		{Name: "confidence", Type: confidence},
+		{Name: "answer", Type: answer},
+		{Name: "instantAnswer", Type: instantAnswer, Description: "Replaces the results and summary."},
+		{Name: "structuredAnswer", Type: structured},
+		{Name: "knowledgePanel", Type: panel},
+		{Name: "relatedSearches", Type: strings},
+		{Name: "budget", Type: budget},
+	}}
+	historyEntry := &graphql.Object{Name: "HistoryEntry", Description: "One of the logged-in user's searches.", Fields: []*graphql.Field{
+		{Name: "taskId", Type: str},
+		{Name: "query", Type: nonNullStr},
+		{Name: "results", Type: graphql.NonNull(graphql.Int)},
+		{Name: "searchedAt", Type: graphql.NonNull(dateTime)},
+	}}
+	quota := &graphql.Object{Name: "Quota", Fields: []*graphql.Field{
+		{Name: "window", Type: nonNullStr, Description: "minute or day."},
+		{Name: "limit", Type: graphql.NonNull(graphql.Int)},
+		{Name: "used", Type: graphql.NonNull(graphql.Int), Description: "Includes searches rejected for exceeding it."},
+		{Name: "remaining", Type: graphql.NonNull(graphql.Int)},
+		{Name: "resetsAt", Type: graphql.NonNull(dateTime)},
+	}}
+	usage := &graphql.Object{Name: "Usage", Fields: []*graphql.Field{
+		{Name: "tenant", Type: nonNullStr},
+		{Name: "quotas", Type: listOf(quota), Description: "Empty for tenants without quotas."},
+	}}
+	model := &graphql.Object{Name: "Model", Description: "The model an inference backend generates summaries with.", Fields: []*graphql.Field{
+		{Name: "backend", Type: nonNullStr, Description: "vllm or ollama."},
+		{Name: "model", Type: nonNullStr},
+		{Name: "warmed", Type: graphql.NonNull(graphql.Boolean), Description: "Whether it answered the warm-up prompt at startup."},
+		{Name: "watchedUntil", Type: dateTime, Description: "While set, a switch to the model is rolled back if its error rate regresses.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if until := p.Source.(*pb.ModelInfo).WatchUntil; until > 0 {
+					return time.Unix(until, 0), nil
+				}
+				return nil, nil
+			}},
+	}}
+	eventType := &graphql.Enum{Name: "SearchEventType", Values: []string{
+		"STARTED", "STATUS", "RESULTS", "STRUCTURED_ANSWER", "KNOWLEDGE_PANEL", "INSTANT_ANSWER", "TOKEN", "SUMMARY", "ERROR",
+	}}
+	event := &graphql.Object{Name: "SearchEvent", Description: "An event of a streamed search; type says which fields it sets. The stream ends after SUMMARY, ERROR or INSTANT_ANSWER.", Fields: []*graphql.Field{
+		{Name: "type", Type: graphql.NonNull(eventType)},
+		{Name: "query", Type: str, Description: "STARTED and INSTANT_ANSWER."},
+		{Name: "status", Type: str, Description: "STATUS: the stage the search reached, e.g. searching or summarizing."},
+		{Name: "searchResults", Type: graphql.ListOf(graphql.NonNull(result)), Description: "RESULTS."},
+		{Name: "structuredAnswer", Type: structured, Description: "STRUCTURED_ANSWER."},
+		{Name: "knowledgePanel", Type: panel, Description: "KNOWLEDGE_PANEL."},
+		{Name: "instantAnswer", Type: instantAnswer, Description: "INSTANT_ANSWER."},
+		{Name: "token", Type: str, Description: "TOKEN: the next piece of the summary."},
+		{Name: "position", Type: graphql.Int, Description: "TOKEN."},
+		{Name: "summary", Type: str, Description: "SUMMARY: the whole summary, as filtered for safety."},
+		{Name: "sanitized", Type: graphql.Boolean, Description: "SUMMARY: whether the safety filter changed the streamed tokens."},
+		{Name: "confidence", Type: confidence, Description: "SUMMARY."},
+		{Name: "answer", Type: answer, Description: "SUMMARY."},
+		{Name: "relatedSearches", Type: graphql.ListOf(nonNullStr), Description: "SUMMARY."},
+		{Name: "budget", Type: budget, Description: "SUMMARY."},
+		{Name: "taskId", Type: str, Description: "SUMMARY."},
+		{Name: "message", Type: str, Description: "ERROR."},
+	}}
+
+	searchArgs := []*graphql.Arg{
+		{Name: "query", Type: nonNullStr},
+		{Name: "safeSearch", Type: graphql.Boolean, Default: false},
+		{Name: "numResults", Type: graphql.Int, Description: "Defaults to the tenant's number of results."},
+		{Name: "locale", Type: str, Description: "BCP 47 language and country, e.g. de-CH; defaults to Accept-Language."},
+	}
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{Name: "search", Type: search, Args: searchArgs, Resolve: g.graphqlSearch,
+			Description: "Runs a search; each one counts against the tenant's quota and gateway.graphql.max_searches."},
+		{Name: "history", Type: graphql.ListOf(graphql.NonNull(historyEntry)), Resolve: g.graphqlHistory,
+			Args:        []*graphql.Arg{{Name: "limit", Type: graphql.Int, Default: 20}},
+			Description: "The logged-in user's recent searches, newest first."},
+		{Name: "usage", Type: usage, Resolve: g.graphqlUsage, Description: "The calling tenant's quota usage."},
+		{Name: "models", Type: graphql.ListOf(graphql.NonNull(model)), Resolve: g.graphqlModels},
+	}}
+	subscription := &graphql.Object{Name: "Subscription", Fields: []*graphql.Field{
+		{Name: "search", Type: graphql.NonNull(event), Args: searchArgs, Subscribe: g.subscribeSearch,
+			Description: "Streams a search as GET /api/v1/search does, with the summary token by token."},
+	}}
+	return graphql.NewSchema(query, subscription, g.config.Gateway.GraphQL.MaxDepth)
+}
@@ -0,0 +1,302 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+)
+
+// IdempotencyHeader lets clients retry POST /search without re-running the pipeline
+const IdempotencyHeader = "Idempotency-Key"
+
+// CachedResponse is a completed response stored under an idempotency key
+type CachedResponse struct {
+	Fingerprint string `json:"fingerprint"` // hash of the request the key was first used with
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	User        string `json:"user,omitempty"` // dataSubject of the request; "" for anonymous ones
+}
+
+// ResponseCache stores completed responses by idempotency key, which starts
+// with the tenant ID and a colon
+type ResponseCache interface {
+	Get(ctx context.Context, key string) (resp *CachedResponse, found bool, err error)
+	Set(ctx context.Context, key string, resp *CachedResponse) error
+	// EraseUser deletes the responses of a user of the tenant, returning
+	// how many it deleted
+	EraseUser(ctx context.Context, tenant, user string) (int, error)
+}
+
+// idempotency deduplicates requests sharing an Idempotency-Key: concurrent
+// callers join the in-flight pipeline, later ones get the cached response
+type idempotency struct {
+	cache ResponseCache
+
+	mu       sync.Mutex
+	inflight map[string]*flight
+}
+
+// flight is a pipeline run other callers with the same key can wait on
+type flight struct {
+	fingerprint string
+	done        chan struct{}
+	resp        *CachedResponse
+}
+
+// newIdempotency returns nil when idempotency keys are disabled
+func newIdempotency(cfg *config.Config, client *redis.Client) *idempotency {
+	idemCfg := cfg.Gateway.Idempotency
+	if !idemCfg.Enabled {
+		return nil
+	}
+	var cache ResponseCache
+	if client != nil {
+		cache = &redisResponseCache{client: client, ttl: idemCfg.TTL}
+	} else {
+		cache = &memoryResponseCache{responses: lru.New[string, *CachedResponse]("gateway_idempotency", cfg.Gateway.MemoryStoreEntries, idemCfg.TTL)}
+	}
+	return &idempotency{cache: cache, inflight: make(map[string]*flight)}
+}
+
+// requestFingerprint identifies the request body a key was used with and
+// the format, json or sse, its response was sent in
+func requestFingerprint(req SearchRequest, format string) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(append([]byte(format+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotent runs handle for a POST /search in format, unless the request
+// carries an Idempotency-Key already used: retries then get the first
+// response, JSON or an event stream, without handle running again, so they
+// neither start a pipeline nor count against the quota. Keys are namespaced
+// per tenant.
+func (g *Gateway) idempotent(c *gin.Context, req SearchRequest, format string, handle func(c *gin.Context)) {
+	key := c.GetHeader(IdempotencyHeader)
+	if key == "" || g.idempotency == nil {
+		handle(c)
+		return
+	}
+	g.idempotency.serve(c, g.tenant(c).ID+":"+key, g.dataSubject(c), requestFingerprint(req, format), handle)
+}
+
+// serve runs handle at most once per key: the first caller runs it while
+// recording the response, concurrent callers wait for that response and
+// later callers within the TTL get it from the cache. The response is kept
+// as user's.
+func (i *idempotency) serve(c *gin.Context, key, user, fingerprint string, handle func(c *gin.Context)) {
+	log := logger.GetLogger()
+
+	if f, ok := i.join(key); ok {
+		i.wait(c, f, fingerprint)
+		return
+	}
+
+	cached, found, err := i.cache.Get(c.Request.Context(), key)
+	if err != nil {
+		log.Warnf("Idempotency cache lookup failed for key %s: %v", key, err)
+	}
+	if found {
+		if cached.Fingerprint != fingerprint {
+			monitoring.RecordIdempotentRequest("gateway", "conflict")
+			keyConflict(c)
+			return
+		}
+		monitoring.RecordIdempotentRequest("gateway", "replayed")
+		replay(c, cached)
+		return
+	}
+
+	// Another caller may have started the pipeline while the cache was checked
+	i.mu.Lock()
+	if f, ok := i.inflight[key]; ok {
+		i.mu.Unlock()
+		i.wait(c, f, fingerprint)
+		return
+	}
+	f := &flight{fingerprint: fingerprint, done: make(chan struct{})}
+	i.inflight[key] = f
+	i.mu.Unlock()
+
+	// Waiters are released even if the pipeline panics; they then get a 500
+	f.resp = &CachedResponse{Status: http.StatusInternalServerError}
+	defer func() {
+		i.mu.Lock()
+		delete(i.inflight, key)
+		i.mu.Unlock()
+		close(f.done)
+	}()
+
+	monitoring.RecordIdempotentRequest("gateway", "executed")
+	resp := record(c, handle)
+	resp.Fingerprint, resp.User = fingerprint, user
+	if replayable(c, resp) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := i.cache.Set(ctx, key, resp); err != nil {
+			log.Warnf("Failed to cache response for idempotency key %s: %v", key, err)
+		}
+		cancel()
+	}
+	f.resp = resp
+}
+
+func (i *idempotency) join(key string) (*flight, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	f, ok := i.inflight[key]
+	return f, ok
+}
+
+// wait attaches to an in-flight pipeline and writes its response once done
+func (i *idempotency) wait(c *gin.Context, f *flight, fingerprint string) {
+	if f.fingerprint != fingerprint {
+		monitoring.RecordIdempotentRequest("gateway", "conflict")
+		keyConflict(c)
+		return
+	}
+	monitoring.RecordIdempotentRequest("gateway", "joined")
+	f.replayTo(c)
+}
+
+// replayTo writes the flight's response once it completes
+func (f *flight) replayTo(c *gin.Context) {
+	select {
+	case <-f.done:
+		replay(c, f.resp)
+	case <-c.Request.Context().Done():
+		// Client gave up; the pipeline keeps running for the other callers
+	}
+}
+
+// record runs handle while capturing the response it writes
+func record(c *gin.Context, handle func(c *gin.Context)) *CachedResponse {
+	recorder := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	handle(c)
+	c.Writer = recorder.ResponseWriter
+
+	return &CachedResponse{
+		Status:      recorder.Status(),
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+	}
+}
+
+// replayable reports whether resp is kept for retries. Server errors and
+// quota rejections are not, so a retry runs the search again, and neither
+// is an event stream that ended other than complete, as one that failed,
+// was rejected or lost its client.
+func replayable(c *gin.Context, resp *CachedResponse) bool {
+	if resp.Status >= http.StatusInternalServerError || resp.Status == http.StatusTooManyRequests {
+		return false
+	}
+	if strings.HasPrefix(resp.ContentType, "text/event-stream") {
+		return c.GetString(lastEventKey) == "complete"
+	}
+	return true
+}
+
+func replay(c *gin.Context, resp *CachedResponse) {
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(resp.Status, resp.ContentType, resp.Body)
+}
+
+func keyConflict(c *gin.Context) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error": fmt.Sprintf("%s was already used with a different request", IdempotencyHeader),
+	})
+}
+
+// recordingWriter copies the response body while writing it through
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+type redisResponseCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func redisIdempotencyKey(key string) string {
+	return "search:idempotency:" + key
+}
+
+func (r *redisResponseCache) Get(ctx context.Context, key string) (*CachedResponse, bool, error) {
+	data, err := r.client.Get(ctx, redisIdempotencyKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var resp CachedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false, fmt.Errorf("invalid cached response: %w", err)
+	}
+	return &resp, true, nil
+}
+
+func (r *redisResponseCache) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, redisIdempotencyKey(key), data, r.ttl).Err()
+}
+
+func (r *redisResponseCache) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return eraseRedis(ctx, r.client, redisIdempotencyKey(tenant+":*"), func(data []byte) (bool, error) {
+		var resp CachedResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return false, fmt.Errorf("invalid cached response: %w", err)
+		}
+		return resp.User == user, nil
+	})
+}
+
+type memoryResponseCache struct {
+	responses *lru.Cache[string, *CachedResponse]
+}
+
+func (m *memoryResponseCache) Get(ctx context.Context, key string) (*CachedResponse, bool, error) {
+	resp, ok := m.responses.Get(key)
+	return resp, ok, nil
+}
+
+func (m *memoryResponseCache) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	m.responses.Add(key, resp)
+	return nil
+}
+
+func (m *memoryResponseCache) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return m.responses.RemoveFunc(func(key string, resp *CachedResponse) bool {
+		return strings.HasPrefix(key, tenant+":") && resp.User == user
+	}), nil
+}
@@ -0,0 +1,213 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformedImage = errors.New("malformed image")
+
+// stripImageMetadata removes what an image carries besides its pixels and
+// how to display them: EXIF (camera, location), XMP, IPTC, comments and text
+// chunks. Colour profiles and animation settings are kept. A malformed image
+// is an error rather than being served as is.
+func stripImageMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	case "image/gif":
+		return stripGIF(data)
+	}
+	return nil, errMalformedImage
+}
+
+// jpegDropped are the JPEG segments removed: APP1 (EXIF, XMP), APP13
+// (IPTC, Photoshop) and comments
+var jpegDropped = map[byte]bool{0xE1: true, 0xED: true, 0xFE: true}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errMalformedImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	for pos := 2; ; {
+		if pos+2 > len(data) || data[pos] != 0xFF {
+			return nil, errMalformedImage
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // fill byte
+			pos++
+			continue
+		}
+		if marker == 0xD9 || (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			// Segments without a length
+			out.Write(data[pos : pos+2])
+			pos += 2
+			if marker == 0xD9 {
+				return out.Bytes(), nil
+			}
+			continue
+		}
+		if pos+4 > len(data) {
+			return nil, errMalformedImage
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) || end < pos+4 {
+			return nil, errMalformedImage
+		}
+		if marker == 0xDA {
+			// Start of scan: the compressed data runs to the end
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		}
+		if !jpegDropped[marker] {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+}
+
+// pngDropped are the PNG chunks removed
+var pngDropped = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errMalformedImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(signature)
+	for pos := len(signature); pos < len(data); {
+		if pos+12 > len(data) {
+			return nil, errMalformedImage
+		}
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end > len(data) || end < pos+12 {
+			return nil, errMalformedImage
+		}
+		chunk := string(data[pos+4 : pos+8])
+		if !pngDropped[chunk] {
+			out.Write(data[pos:end])
+		}
+		pos = end
+		if chunk == "IEND" {
+			break
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// VP8X flags announcing the metadata chunks of a WebP image
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformedImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, errMalformedImage
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2 // chunks are padded to an even size
+		if end > len(data) || end < pos+8 {
+			if pos+8+size != len(data) { // some encoders omit the final pad byte
+				return nil, errMalformedImage
+			}
+			end = len(data)
+		}
+		switch fourCC := string(data[pos : pos+4]); fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= webpFlagXMP | webpFlagEXIF
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
+
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, errMalformedImage
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1) // global colour table
+	}
+	if pos > len(data) {
+		return nil, errMalformedImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:pos])
+
+	// subBlocks returns the end of the data sub-blocks starting at pos
+	subBlocks := func(pos int) (int, error) {
+		for pos < len(data) {
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return pos, nil
+			}
+		}
+		return 0, errMalformedImage
+	}
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B: // trailer
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x21: // extension
+			if pos+2 > len(data) {
+				return nil, errMalformedImage
+			}
+			label := data[pos+1]
+			end, err := subBlocks(pos + 2)
+			if err != nil {
+				return nil, err
+			}
+			// Comments and XMP go; animation loops and frame timing stay
+			xmp := label == 0xFF && end > pos+14 && string(data[pos+3:pos+14]) == "XMP DataXMP"
+			if label != 0xFE && !xmp {
+				out.Write(data[pos:end])
+			}
+			pos = end
+		case 0x2C: // image descriptor
+			if pos+10 > len(data) {
+				return nil, errMalformedImage
+			}
+			start, flags := pos, data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1) // local colour table
+			}
+			end, err := subBlocks(pos + 1) // after the LZW minimum code size
+			if err != nil {
+				return nil, err
+			}
+			out.Write(data[start:end])
+			pos = end
+		default:
+			return nil, errMalformedImage
+		}
+	}
+	return nil, errMalformedImage
+}
@@ -0,0 +1,246 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+	"ai-search-service/internal/upstream"
+)
+
+// imageTypes are the image formats served, by sniffed content type. SVG is
+// not among them: it can carry scripts.
+var imageTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true, "image/webp": true}
+
+// errPrivateAddress refuses fetches from addresses inside the deployment
+var errPrivateAddress = errors.New("refusing to fetch from a private address")
+
+// proxiedImage is a fetched image with its metadata stripped
+type proxiedImage struct {
+	contentType string
+	body        []byte
+	etag        string
+}
+
+// imageProxy fetches the images the gateway returned, by signed token, so
+// browsers load them from the gateway instead of third-party hosts
+type imageProxy struct {
+	config config.ImageProxyConfig
+	sealer *auth.Sealer
+	client *http.Client
+	cache  *lru.Cache[string, *proxiedImage]
+}
+
+// newImageProxy returns nil when the image proxy is disabled
+func newImageProxy(cfg *config.Config) *imageProxy {
+	proxyCfg := cfg.Gateway.ImageProxy
+	if !proxyCfg.Enabled {
+		return nil
+	}
+	secret := proxyCfg.Secret
+	if secret == "" {
+		logger.GetLogger().Warn("No image_proxy.secret set: image URLs stop working on restart and are not valid across replicas")
+		secret = randomToken(32)
+	}
+	dialer := &net.Dialer{Timeout: proxyCfg.Timeout}
+	if !proxyCfg.AllowPrivate {
+		// Checked on the resolved address, so DNS cannot point a public name inside
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   proxyCfg.Timeout,
+		ResponseHeaderTimeout: proxyCfg.Timeout,
+		MaxIdleConnsPerHost:   4,
+	}
+	return &imageProxy{
+		config: proxyCfg,
+		sealer: auth.NewSealer(secret),
+		client: &http.Client{
+			Timeout:   proxyCfg.Timeout,
+			Transport: upstream.WrapTransport(cfg, "image_proxy", transport),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				return checkImageURL(req.URL)
+			},
+		},
+		cache: lru.New[string, *proxiedImage]("gateway_images", proxyCfg.CacheEntries, proxyCfg.CacheTTL),
+	}
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// checkImageURL accepts absolute http(s) URLs without credentials
+func checkImageURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return fmt.Errorf("not an http(s) image URL: %s", u.Redacted())
+	}
+	return nil
+}
+
+// RegisterImageRoutes serves the proxied images when the proxy is enabled
+func (g *Gateway) RegisterImageRoutes(router gin.IRoutes) {
+	if g.images == nil {
+		return
+	}
+	router.GET("/img/:token", g.ProxyImage)
+}
+
+// proxyURL returns the gateway path serving the image at raw, or raw itself
+// when the proxy is disabled; URLs it cannot proxy are dropped
+func (g *Gateway) proxyURL(raw string) string {
+	if g.images == nil || raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || checkImageURL(u) != nil {
+		return ""
+	}
+	token, err := g.images.sealer.Seal(raw)
+	if err != nil {
+		return ""
+	}
+	return "/img/" + token
+}
+
+// proxyResultImages returns the results with their thumbnails proxied,
+// leaving the pipeline's copy untouched
+func (g *Gateway) proxyResultImages(results []pipeline.Result) []pipeline.Result {
+	if g.images == nil {
+		return results
+	}
+	proxied := make([]pipeline.Result, len(results))
+	for i, result := range results {
+		result.ImageURL = g.proxyURL(result.ImageURL)
+		proxied[i] = result
+	}
+	return proxied
+}
+
+// proxyPanelImage returns the panel with its image proxied
+func (g *Gateway) proxyPanelImage(panel *pipeline.KnowledgePanel) *pipeline.KnowledgePanel {
+	if g.images == nil || panel == nil {
+		return panel
+	}
+	proxied := *panel
+	proxied.ImageURL = g.proxyURL(panel.ImageURL)
+	return &proxied
+}
+
+// ProxyImage serves an image the gateway returned, fetched once and cached
+// with its metadata stripped. Browsers may cache it for image_proxy.cache_ttl.
+func (g *Gateway) ProxyImage(c *gin.Context) {
+	var raw string
+	if err := g.images.sealer.Open(c.Param("token"), &raw); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	image, cached := g.images.cache.Get(raw)
+	if !cached {
+		var err error
+		image, err = g.images.fetch(c.Request.Context(), raw)
+		if err != nil {
+			logger.GetLogger().Warnf("Image proxy failed to fetch %s: %v", raw, err)
+			monitoring.RecordImageProxy("failed")
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Image unavailable"})
+			return
+		}
+		g.images.cache.Add(raw, image)
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(g.images.config.CacheTTL.Seconds())))
+	c.Header("ETag", image.etag)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, image.etag) {
+		monitoring.RecordImageProxy("not_modified")
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if cached {
+		monitoring.RecordImageProxy("cached")
+	} else {
+		monitoring.RecordImageProxy("fetched")
+	}
+	c.Data(http.StatusOK, image.contentType, image.body)
+}
+
+// fetch downloads the image at raw, checking its size and format and
+// stripping its metadata
+func (p *imageProxy) fetch(ctx context.Context, raw string) (*proxiedImage, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImageURL(u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/jpeg, image/png, image/gif, image/webp")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("returned status %d", resp.StatusCode)
+	}
+	if length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil && length > p.config.MaxBytes {
+		return nil, fmt.Errorf("image of %d bytes is over the %d byte limit", length, p.config.MaxBytes)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, p.config.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > p.config.MaxBytes {
+		return nil, fmt.Errorf("image is over the %d byte limit", p.config.MaxBytes)
+	}
+
+	// The declared type is not trusted; the bytes decide
+	contentType := http.DetectContentType(body)
+	if !imageTypes[contentType] {
+		return nil, fmt.Errorf("content type %s is not a supported image", contentType)
+	}
+	body, err = stripImageMetadata(contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	return &proxiedImage{contentType: contentType, body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`}, nil
+}
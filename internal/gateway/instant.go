@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/instant"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+	pb "ai-search-service/proto"
+)
+
+// instantAnswer answers calculations, unit conversions and definitions
+// without searching or summarizing, returning nil for any other query. The
+// query still passes the safety service and the tenant's blocked terms
+// first. A failed lookup or a word the dictionary does not know falls
+// through to a normal search.
+func (g *Gateway) instantAnswer(c *gin.Context, query string, safeSearch bool) *instant.Answer {
+	if g.instant == nil {
+		return nil
+	}
+	lookup := g.instant.Match(query)
+	if lookup == nil {
+		return nil
+	}
+	log := logger.GetLogger()
+	req := g.pipelineRequest(c, "instant", query, safeSearch, 0)
+
+	validateCtx, cancel := req.StageContext(pipeline.StageValidate)
+	validation, err := g.safetyClient.ValidateInput(validateCtx, &pb.ValidateInputRequest{
+		Text:       query,
+		ClientIp:   req.ClientIP,
+		SafeSearch: req.SafeSearch,
+		Policy:     req.Safety,
+	})
+	cancel()
+	if err != nil || !validation.IsSafe {
+		// The pipeline's validate stage reports the failure or rejection
+		return nil
+	}
+
+	ctx, cancel := g.stageContext(c, pipeline.StageSearch)
+	defer cancel()
+	answer, err := lookup.Answer(ctx)
+	switch {
+	case err != nil:
+		log.Warnf("Instant %s lookup failed, searching instead: %v", lookup.Kind, err)
+		monitoring.RecordInstantAnswer(lookup.Kind, "failed")
+	case answer == nil:
+		monitoring.RecordInstantAnswer(lookup.Kind, "no_answer")
+	default:
+		monitoring.RecordInstantAnswer(lookup.Kind, "answered")
+	}
+	return answer
+}
+
+// streamInstantAnswer sends an instant answer as an instant_answer event in
+// place of the search results and summary, reporting whether there was one
+func (g *Gateway) streamInstantAnswer(c *gin.Context, query string, safeSearch bool) bool {
+	answer := g.instantAnswer(c, query, safeSearch)
+	if answer == nil {
+		return false
+	}
+	emit := g.newSSEEmitter(c, nil)
+	emit.Started(query)
+	emit.events.send("instant_answer", gin.H{"type": "instant_answer", "answer": answer})
+	emit.events.sendComplete("")
+	return true
+}
@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/monitoring"
+)
+
+// limitBody caps request bodies at limits.max_body_bytes, answering 413 up
+// front when the declared length is over, and gives clients
+// limits.body_timeout to send them. Handlers decode with bindJSON to turn
+// bodies that turn out too large or too slow into 413 and 408 responses.
+func (g *Gateway) limitBody(c *gin.Context) {
+	limits := g.config.Gateway.Limits
+	if limits.MaxBodyBytes > 0 && c.Request.ContentLength > limits.MaxBodyBytes {
+		monitoring.RecordRequestRejected("body_too_large")
+		c.Header("Connection", "close")
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":          fmt.Sprintf("Request body exceeds %d bytes", limits.MaxBodyBytes),
+			"max_body_bytes": limits.MaxBodyBytes,
+		})
+		return
+	}
+	if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+		c.Next()
+		return
+	}
+	if limits.MaxBodyBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxBodyBytes)
+	}
+	if limits.BodyTimeout > 0 {
+		// The deadline is lifted as soon as the body has been read: the
+		// server keeps reading the connection in the background to notice
+		// disconnects, which must not time out during a long stream
+		rc := http.NewResponseController(c.Writer)
+		if rc.SetReadDeadline(time.Now().Add(limits.BodyTimeout)) == nil {
+			c.Request.Body = &deadlineBody{ReadCloser: c.Request.Body, rc: rc}
+			defer rc.SetReadDeadline(time.Time{})
+		}
+	}
+	c.Next()
+}
+
+// deadlineBody clears the connection's read deadline once the body ends
+type deadlineBody struct {
+	io.ReadCloser
+	rc *http.ResponseController
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.rc.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
+
+// bindJSON decodes the request body into v, writing a 413 for bodies over
+// the limit, a 408 for clients too slow to send them and a 400 listing the
+// field errors of invalid ones, checked against the route's schema in the
+// OpenAPI spec. It returns false when it wrote an error.
+func bindJSON(c *gin.Context, v interface{}) bool {
+	var body []byte
+	var err error
+	if c.Request.Body != nil {
+		body, err = io.ReadAll(c.Request.Body)
+	}
+	if err == nil {
+		if errs := validateBody(c, body); len(errs) > 0 {
+			rejectFields(c, errs)
+			return false
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err = c.ShouldBindJSON(v); err == nil {
+			return true
+		}
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		monitoring.RecordRequestRejected("body_too_large")
+		c.Header("Connection", "close")
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":          fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit),
+			"max_body_bytes": tooLarge.Limit,
+		})
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		monitoring.RecordRequestRejected("body_timeout")
+		c.Header("Connection", "close")
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request body was not received in time"})
+	default:
+		rejectFields(c, bindingErrors(err))
+	}
+	return false
+}
+
+// queryTooLong writes a 413 and returns true if query is longer than
+// limits.max_query_length characters
+func (g *Gateway) queryTooLong(c *gin.Context, query string, sse bool) bool {
+	max, tooLong := g.checkQueryLength(query)
+	if !tooLong {
+		return false
+	}
+	message := fmt.Sprintf("Query exceeds %d characters", max)
+	if sse {
+		c.Status(http.StatusRequestEntityTooLarge)
+		c.SSEvent("error", gin.H{"message": message, "max_query_length": max})
+	} else {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": message, "max_query_length": max})
+	}
+	return true
+}
+
+// checkQueryLength reports whether query is longer than the limit of
+// limits.max_query_length characters, counting the rejection if it is
+func (g *Gateway) checkQueryLength(query string) (max int, tooLong bool) {
+	max = g.config.Gateway.Limits.MaxQueryLength
+	if max <= 0 || utf8.RuneCountInString(query) <= max {
+		return max, false
+	}
+	monitoring.RecordRequestRejected("query_too_long")
+	monitoring.RecordRequest("gateway", "search", "rejected")
+	return max, true
+}
+
+// clampNumResults bounds a requested number of results by
+// limits.max_num_results, using the tenant's default when none was asked for
+func (g *Gateway) clampNumResults(c *gin.Context, numResults int) int {
+	return g.clampTenantNumResults(g.tenant(c), numResults)
+}
+
+// clampTenantNumResults is clampNumResults for work run outside a request
+func (g *Gateway) clampTenantNumResults(tenant *Tenant, numResults int) int {
+	if numResults <= 0 {
+		numResults = tenant.defaultNumResults()
+	}
+	if max := g.config.Gateway.Limits.MaxNumResults; max > 0 && numResults > max {
+		return max
+	}
+	return numResults
+}
@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localeKey holds the locale a request's JSON body asked for
+const localeKey = "search_locale"
+
+// searchLocale returns the locale to search in: the request's explicit
+// locale (in its JSON body or the locale query parameter), else its
+// preferred Accept-Language, else the configured default. Unrecognized
+// locales are ignored.
+func (g *Gateway) searchLocale(c *gin.Context) string {
+	explicit := c.Query("locale")
+	if body, ok := c.Get(localeKey); ok {
+		explicit = body.(string)
+	}
+	if locale := normalizeLocale(explicit); locale != "" {
+		return locale
+	}
+	if g.config.Gateway.Locale.AcceptLanguage {
+		if locale := acceptedLocale(c.GetHeader("Accept-Language")); locale != "" {
+			return locale
+		}
+	}
+	return normalizeLocale(g.config.Gateway.Locale.Default)
+}
+
+// normalizeLocale returns a BCP 47 tag as "ll" or "ll-CC": a two or three
+// letter language with an optional two letter country. Scripts and variants
+// are dropped, as are numeric regions such as the 419 of "es-419". Anything
+// else yields "".
+func normalizeLocale(tag string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	language := strings.ToLower(parts[0])
+	if (len(language) != 2 && len(language) != 3) || !isLetters(language) {
+		return ""
+	}
+	for _, part := range parts[1:] {
+		if len(part) == 2 && isLetters(part) {
+			return language + "-" + strings.ToUpper(part)
+		}
+	}
+	return language
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptedLocale returns the most preferred locale of an Accept-Language
+// header, e.g. "fr-CH" for "fr-CH, fr;q=0.9, en;q=0.8"
+func acceptedLocale(header string) string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var accepted []weighted
+	for _, entry := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if locale := normalizeLocale(tag); locale != "" && q > 0 {
+			accepted = append(accepted, weighted{locale, q})
+		}
+	}
+	// Stable, so equal weights keep the header's order
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+	if len(accepted) == 0 {
+		return ""
+	}
+	return accepted[0].locale
+}
@@ -0,0 +1,518 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/envelope"
+	"ai-search-service/internal/logger"
+)
+
+// sessionContextKey is the gin context key of the logged-in *auth.Session
+const sessionContextKey = "session"
+
+// loginStateTTL bounds how long a login may take at the provider
+const loginStateTTL = 10 * time.Minute
+
+// webLogin signs session cookies and keeps each user's recent searches
+type webLogin struct {
+	config  config.LoginConfig
+	sealer  *auth.Sealer
+	history UserHistory
+	keys    *envelope.Keyring // encrypts the history in Redis; nil for plaintext
+}
+
+// newWebLogin returns nil when web UI login is disabled
+func newWebLogin(cfg *config.Config, client *redis.Client) (*webLogin, error) {
+	loginCfg := cfg.Gateway.Login
+	if !loginCfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Gateway.OIDC.Issuer == "" || cfg.Gateway.OIDC.ClientID == "" {
+		return nil, fmt.Errorf("login requires gateway.oidc.issuer and client_id")
+	}
+	secret := loginCfg.SessionSecret
+	if secret == "" {
+		logger.GetLogger().Warn("No login.session_secret set: sessions end on restart and are not shared between replicas")
+		secret = randomToken(32)
+	}
+	keys, err := envelope.New(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	login := &webLogin{config: loginCfg, sealer: auth.NewSealer(secret), keys: keys}
+	if client != nil {
+		login.history = &redisUserHistory{client: client, max: loginCfg.HistoryEntries, keys: keys}
+	} else {
+		login.history = &memoryUserHistory{max: loginCfg.HistoryEntries, users: make(map[string][]*HistoryEntry)}
+	}
+	return login, nil
+}
+
+func randomToken(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RegisterLoginRoutes registers the OIDC login flow when web UI login is enabled
+func (g *Gateway) RegisterLoginRoutes(router gin.IRoutes) {
+	if g.login == nil {
+		return
+	}
+	router.GET("/auth/login", g.Login)
+	router.GET("/auth/callback", g.LoginCallback)
+	router.POST("/auth/logout", g.Logout)
+}
+
+// loginState travels through the provider in a signed cookie, binding the
+// callback to the browser that started the login
+type loginState struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"` // PKCE code verifier
+	Next     string    `json:"next"`
+	Expires  time.Time `json:"exp"`
+}
+
+func (g *Gateway) loginCookieName() string {
+	return g.login.config.CookieName + "_login"
+}
+
+func (g *Gateway) setCookie(c *gin.Context, name, value, path string, maxAge int) {
+	secure := g.login.config.SecureCookie || strings.HasPrefix(requestBaseURL(c), "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, path, "", secure, true)
+}
+
+// redirectURL is where the provider sends the browser back to
+func (g *Gateway) redirectURL(c *gin.Context) string {
+	if u := g.config.Gateway.OIDC.RedirectURL; u != "" {
+		return u
+	}
+	return requestBaseURL(c) + "/auth/callback"
+}
+
+// localPath keeps post-login redirects on this site
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// Login starts the authorization code flow, returning to ?next= afterwards
+func (g *Gateway) Login(c *gin.Context) {
+	state := loginState{
+		State:    randomToken(16),
+		Nonce:    randomToken(16),
+		Verifier: randomToken(32),
+		Next:     localPath(c.DefaultQuery("next", "/")),
+		Expires:  time.Now().Add(loginStateTTL),
+	}
+	target, err := g.oidc.AuthCodeURL(c.Request.Context(), g.redirectURL(c), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to start login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "The identity provider is unavailable"})
+		return
+	}
+	sealed, err := g.login.sealer.Seal(state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	g.setCookie(c, g.loginCookieName(), sealed, "/auth", int(loginStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, target)
+}
+
+// LoginCallback redeems the provider's code, verifies the ID token and
+// starts a session
+func (g *Gateway) LoginCallback(c *gin.Context) {
+	log := logger.GetLogger()
+	var state loginState
+	cookie, err := c.Cookie(g.loginCookieName())
+	if err != nil || g.login.sealer.Open(cookie, &state) != nil || time.Now().After(state.Expires) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login expired, please try again"})
+		return
+	}
+	g.setCookie(c, g.loginCookieName(), "", "/auth", -1)
+	if c.Query("state") != state.State {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login state does not match"})
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		log.Warnf("Login refused by the identity provider: %s %s", e, c.Query("error_description"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was refused: " + e})
+		return
+	}
+
+	claims, err := g.oidc.Exchange(c.Request.Context(), c.Query("code"), g.redirectURL(c), state.Verifier, state.Nonce)
+	if err != nil {
+		log.Warnf("Login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+	session := auth.Session{
+		Subject: claims.String("sub"),
+		Email:   claims.String("email"),
+		Name:    claims.String("name"),
+		Expires: time.Now().Add(g.login.config.SessionTTL),
+	}
+	if role := g.oidc.Role(claims); role != auth.RoleNone {
+		session.Role = role.String()
+	}
+	sealed, err := g.login.sealer.Seal(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+	g.setCookie(c, g.login.config.CookieName, sealed, "/", int(g.login.config.SessionTTL.Seconds()))
+	log.Infof("User %s logged in", session.Subject)
+	c.Redirect(http.StatusFound, state.Next)
+}
+
+// Logout ends the session
+func (g *Gateway) Logout(c *gin.Context) {
+	g.setCookie(c, g.login.config.CookieName, "", "/", -1)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// readSession returns the request's valid session, or nil
+func (g *Gateway) readSession(c *gin.Context) *auth.Session {
+	if g.login == nil {
+		return nil
+	}
+	cookie, err := c.Cookie(g.login.config.CookieName)
+	if err != nil || cookie == "" {
+		return nil
+	}
+	var session auth.Session
+	if err := g.login.sealer.Open(cookie, &session); err != nil || time.Now().After(session.Expires) || session.Subject == "" {
+		return nil
+	}
+	return &session
+}
+
+// loadSession identifies the logged-in user of an API request, if any
+func (g *Gateway) loadSession(c *gin.Context) {
+	if session := g.readSession(c); session != nil {
+		c.Set(sessionContextKey, session)
+	}
+	c.Next()
+}
+
+// user returns the logged-in user of the request, or nil
+func (g *Gateway) user(c *gin.Context) *auth.Session {
+	if value, ok := c.Get(sessionContextKey); ok {
+		return value.(*auth.Session)
+	}
+	return nil
+}
+
+// RequireLogin sends web UI visitors without a session to the login flow
+func (g *Gateway) RequireLogin(c *gin.Context) {
+	if g.login == nil {
+		c.Next()
+		return
+	}
+	session := g.readSession(c)
+	if session == nil {
+		c.Redirect(http.StatusFound, "/auth/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+		c.Abort()
+		return
+	}
+	c.Set(sessionContextKey, session)
+	c.Next()
+}
+
+// CurrentUser returns the logged-in user (GET /api/v1/me)
+func (g *Gateway) CurrentUser(c *gin.Context) {
+	session := g.user(c)
+	if session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not logged in"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"subject": session.Subject,
+		"email":   session.Email,
+		"name":    session.Name,
+		"role":    session.Role,
+		"tenant":  g.tenant(c).ID,
+	})
+}
+
+// UserSearchHistory returns the logged-in user's recent searches, newest
+// first (GET /api/v1/me/history?limit=20)
+func (g *Gateway) UserSearchHistory(c *gin.Context) {
+	session := g.user(c)
+	if session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not logged in"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	entries, err := g.login.history.Recent(c.Request.Context(), g.historyKey(c, session), limit)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load search history of %s: %v", session.Subject, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"searches": entries})
+}
+
+// registerEncryptionAdminRoutes registers the rewrap endpoint for admins when
+// the search histories are encrypted
+func (g *Gateway) registerEncryptionAdminRoutes(admin *gin.RouterGroup) {
+	if g.login == nil || g.login.keys == nil || (g.access == nil && g.config.Gateway.Tenancy.AdminToken == "") {
+		return
+	}
+	admin.POST("/encryption/rewrap", g.authorize(auth.RoleAdmin), g.RewrapHistory)
+}
+
+// RewrapHistory moves every stored search history entry to the primary
+// encryption key, so that the keys it replaced can be removed after a
+// rotation (POST /admin/encryption/rewrap). Entries stored before encryption
+// was enabled are encrypted.
+func (g *Gateway) RewrapHistory(c *gin.Context) {
+	rewrapped, err := g.login.history.Rewrap(c.Request.Context())
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to rewrap the search histories after %d entries: %v", rewrapped, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rewrap all entries; retry to rewrap the rest", "rewrapped": rewrapped})
+		return
+	}
+	logger.GetLogger().Infof("Rewrapped %d search history entries with key %s", rewrapped, g.login.keys.Primary())
+	c.JSON(http.StatusOK, gin.H{"primary_key": g.login.keys.Primary(), "rewrapped": rewrapped})
+}
+
+// historyKey keeps a user's searches apart per tenant
+func (g *Gateway) historyKey(c *gin.Context, session *auth.Session) string {
+	return g.tenant(c).ID + ":" + session.Subject
+}
+
+// recordUserSearch adds a completed search to the logged-in user's history
+func (g *Gateway) recordUserSearch(c *gin.Context, taskID, query string, results int) {
+	session := g.user(c)
+	if g.login == nil || session == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	entry := &HistoryEntry{TaskID: taskID, Query: query, Results: results, SearchedAt: time.Now().UTC()}
+	if err := g.login.history.Add(ctx, g.historyKey(c, session), entry); err != nil {
+		logger.GetLogger().Warnf("Failed to record search history of %s: %v", session.Subject, err)
+	}
+}
+
+// HistoryEntry is one of a user's searches
+type HistoryEntry struct {
+	TaskID     string    `json:"task_id,omitempty"` // exportable while kept
+	Query      string    `json:"query"`
+	Results    int       `json:"results"`
+	SearchedAt time.Time `json:"searched_at"`
+}
+
+// UserHistory keeps each user's most recent searches
+type UserHistory interface {
+	Add(ctx context.Context, user string, entry *HistoryEntry) error
+	// Recent returns up to limit entries, newest first
+	Recent(ctx context.Context, user string, limit int) ([]*HistoryEntry, error)
+	// Delete drops a user's history, returning how many entries it held
+	Delete(ctx context.Context, user string) (int, error)
+	// Expire drops every user's entries searched before the given time,
+	// returning how many it dropped
+	Expire(ctx context.Context, before time.Time) (int, error)
+	// Rewrap moves every entry stored at rest to the primary encryption
+	// key, returning how many entries it changed
+	Rewrap(ctx context.Context) (int, error)
+}
+
+// redisUserHistory keeps each user's entries in a list, newest first,
+// sealed by keys when encryption is configured
+type redisUserHistory struct {
+	client *redis.Client
+	max    int
+	keys   *envelope.Keyring
+}
+
+func redisUserHistoryKey(user string) string {
+	return "history:user:" + user
+}
+
+func (h *redisUserHistory) Add(ctx context.Context, user string, entry *HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if data, err = h.keys.Seal(data); err != nil {
+		return err
+	}
+	pipe := h.client.TxPipeline()
+	pipe.LPush(ctx, redisUserHistoryKey(user), data)
+	if h.max > 0 {
+		pipe.LTrim(ctx, redisUserHistoryKey(user), 0, int64(h.max-1))
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (h *redisUserHistory) Recent(ctx context.Context, user string, limit int) ([]*HistoryEntry, error) {
+	values, err := h.client.LRange(ctx, redisUserHistoryKey(user), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*HistoryEntry, 0, len(values))
+	for _, value := range values {
+		entry, err := h.entry([]byte(value))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// entry opens and decodes a stored entry
+func (h *redisUserHistory) entry(data []byte) (*HistoryEntry, error) {
+	data, err := h.keys.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	var entry HistoryEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid history entry: %w", err)
+	}
+	return &entry, nil
+}
+
+func (h *redisUserHistory) Delete(ctx context.Context, user string) (int, error) {
+	pipe := h.client.TxPipeline()
+	length := pipe.LLen(ctx, redisUserHistoryKey(user))
+	pipe.Del(ctx, redisUserHistoryKey(user))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(length.Val()), nil
+}
+
+func (h *redisUserHistory) Expire(ctx context.Context, before time.Time) (int, error) {
+	return scanRedis(ctx, h.client, redisUserHistoryKey("*"), func(key string) (int, error) {
+		return expireRedisList(ctx, h.client, key, func(data []byte) (bool, error) {
+			entry, err := h.entry(data)
+			if err != nil {
+				return false, err
+			}
+			return entry.SearchedAt.Before(before), nil
+		})
+	})
+}
+
+// Rewrap rewrites each user's list in a transaction that fails, to be
+// retried, when the user searches meanwhile
+func (h *redisUserHistory) Rewrap(ctx context.Context) (int, error) {
+	if h.keys == nil {
+		return 0, nil
+	}
+	return scanRedis(ctx, h.client, redisUserHistoryKey("*"), func(key string) (int, error) {
+		rewrapped := 0
+		err := h.client.Watch(ctx, func(tx *redis.Tx) error {
+			values, err := tx.LRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				for i, value := range values {
+					data, changed, err := h.keys.Rewrap([]byte(value))
+					if err != nil {
+						return err
+					}
+					if changed {
+						pipe.LSet(ctx, key, int64(i), data)
+						rewrapped++
+					}
+				}
+				return nil
+			})
+			return err
+		}, key)
+		if err != nil {
+			return 0, err
+		}
+		return rewrapped, nil
+	})
+}
+
+type memoryUserHistory struct {
+	max   int
+	mu    sync.Mutex
+	users map[string][]*HistoryEntry // newest first
+}
+
+func (h *memoryUserHistory) Add(ctx context.Context, user string, entry *HistoryEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := append([]*HistoryEntry{entry}, h.users[user]...)
+	if h.max > 0 && len(entries) > h.max {
+		entries = entries[:h.max]
+	}
+	h.users[user] = entries
+	return nil
+}
+
+func (h *memoryUserHistory) Recent(ctx context.Context, user string, limit int) ([]*HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := h.users[user]
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return append([]*HistoryEntry(nil), entries...), nil
+}
+
+func (h *memoryUserHistory) Delete(ctx context.Context, user string) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	deleted := len(h.users[user])
+	delete(h.users, user)
+	return deleted, nil
+}
+
+func (h *memoryUserHistory) Expire(ctx context.Context, before time.Time) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	expired := 0
+	for user, entries := range h.users {
+		kept := len(entries)
+		for kept > 0 && entries[kept-1].SearchedAt.Before(before) {
+			kept--
+		}
+		expired += len(entries) - kept
+		if kept == 0 {
+			delete(h.users, user)
+		} else {
+			h.users[user] = entries[:kept]
+		}
+	}
+	return expired, nil
+}
+
+// Rewrap has nothing to do: the memory store keeps nothing at rest
+func (h *memoryUserHistory) Rewrap(ctx context.Context) (int, error) {
+	return 0, nil
+}
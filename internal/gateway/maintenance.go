@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/cron"
+	"ai-search-service/internal/logger"
+)
+
+// newMaintenance schedules the gateway's maintenance jobs; with Redis each
+// run executes on one replica
+func (g *Gateway) newMaintenance(client *redis.Client) (*cron.Scheduler, error) {
+	maintenance := cron.New(g.config.Gateway.Maintenance, client)
+
+	// The retention janitor; janitor_interval 0 turns it off unless
+	// maintenance.jobs schedules it
+	retention := cron.Off
+	if interval := g.config.Gateway.Retention.JanitorInterval; interval > 0 {
+		retention = "@every " + interval.String()
+	}
+	if err := maintenance.Add("retention", retention, func(ctx context.Context) error {
+		_, err := g.sweep(ctx)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return maintenance, nil
+}
+
+func (g *Gateway) registerMaintenanceAdminRoutes(admin *gin.RouterGroup) {
+	if g.access == nil && g.config.Gateway.Tenancy.AdminToken == "" {
+		return
+	}
+	admin.GET("/maintenance", g.authorize(auth.RoleViewer), g.MaintenanceJobs)
+	admin.POST("/maintenance/:job/run", g.authorize(auth.RoleAdmin), g.RunMaintenanceJob)
+}
+
+// MaintenanceJobs reports each maintenance job's schedule, next run and
+// last run on any replica (GET /admin/maintenance)
+func (g *Gateway) MaintenanceJobs(c *gin.Context) {
+	jobs, err := g.maintenance.Jobs(c.Request.Context())
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load maintenance job runs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the maintenance jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// RunMaintenanceJob starts a maintenance job on this replica now, outside
+// its schedule (POST /admin/maintenance/:job/run)
+func (g *Gateway) RunMaintenanceJob(c *gin.Context) {
+	switch err := g.maintenance.Trigger(c.Param("job")); {
+	case errors.Is(err, cron.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No maintenance job with this name"})
+	case errors.Is(err, cron.ErrRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "The job is running"})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"job": c.Param("job"), "status": "started"})
+	}
+}
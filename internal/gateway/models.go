@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// SwitchModelRequest is the body of POST /admin/models/switch
+type SwitchModelRequest struct {
+	Backend string `json:"backend" binding:"required"` // vllm or ollama
+	Model   string `json:"model"`                      // empty keeps the current default model
+	URL     string `json:"url"`                        // vLLM server; empty keeps the current one
+}
+
+// SwitchModelResponse reports the target serving the backend after a switch
+type SwitchModelResponse struct {
+	Switched      bool       `json:"switched"`
+	Error         string     `json:"error,omitempty"` // why the target was refused
+	Model         string     `json:"model"`
+	URL           string     `json:"url,omitempty"`
+	PreviousModel string     `json:"previous_model"`
+	PreviousURL   string     `json:"previous_url,omitempty"`
+	CanaryOutput  string     `json:"canary_output,omitempty"`
+	WatchUntil    *time.Time `json:"watch_until,omitempty"` // regressions roll back until then
+}
+
+// SwitchModel cuts an inference backend over to another model or vLLM
+// server once the inference service has warmed it and it passed the canary
+// prompt. A refused target answers 409 with the reason and the previous
+// target stays active.
+func (g *Gateway) SwitchModel(c *gin.Context) {
+	var req SwitchModelRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	resp, err := g.inferenceClient.SwitchModel(c.Request.Context(), &pb.SwitchModelRequest{
+		Backend: req.Backend,
+		Model:   req.Model,
+		Url:     req.URL,
+	})
+	if err != nil {
+		code := http.StatusBadGateway
+		switch status.Code(err) {
+		case codes.InvalidArgument:
+			code = http.StatusBadRequest
+		case codes.FailedPrecondition:
+			code = http.StatusConflict
+		default:
+			logger.GetLogger().Errorf("Failed to switch the %s model: %v", req.Backend, err)
+		}
+		c.JSON(code, gin.H{"error": status.Convert(err).Message()})
+		return
+	}
+
+	out := SwitchModelResponse{
+		Switched:      resp.Switched,
+		Error:         resp.Error,
+		Model:         resp.Model,
+		URL:           resp.Url,
+		PreviousModel: resp.PreviousModel,
+		PreviousURL:   resp.PreviousUrl,
+		CanaryOutput:  resp.CanaryOutput,
+	}
+	if !resp.Switched {
+		c.JSON(http.StatusConflict, out)
+		return
+	}
+	until := time.Unix(resp.WatchUntil, 0).UTC()
+	out.WatchUntil = &until
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIYAML describes every route the gateway serves. It is written by
+// hand next to the handlers; the e2e suite checks it against the router,
+// and requests are validated against its parameters and schemas.
+//
+//go:embed openapi.yaml
+var openAPIYAML []byte
+
+// apiSpec is the parsed spec, with its operations indexed by method and
+// path for request validation
+type apiSpec struct {
+	doc        map[string]interface{}
+	json       []byte // served to code generators and Swagger UI
+	operations map[string]*operation
+}
+
+var loadAPISpec = sync.OnceValues(func() (*apiSpec, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(openAPIYAML, &doc); err != nil {
+		return nil, fmt.Errorf("invalid openapi.yaml: %w", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi.yaml: %w", err)
+	}
+	spec := &apiSpec{doc: doc, json: data}
+	spec.operations = spec.indexOperations()
+	return spec, nil
+})
+
+// RegisterDocsRoutes serves Swagger UI for the OpenAPI spec at /docs
+func (g *Gateway) RegisterDocsRoutes(router gin.IRoutes) {
+	router.GET("/docs", g.Docs)
+}
+
+// OpenAPISpec serves the OpenAPI 3 spec of the gateway's endpoints at
+// /api/v1/openapi.json, for SDK generators
+func (g *Gateway) OpenAPISpec(c *gin.Context) {
+	if !g.config.Gateway.Docs.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API docs are disabled"})
+		return
+	}
+	spec, err := loadAPISpec()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec.json)
+}
+
+// Docs serves Swagger UI, loaded from gateway.docs.swagger_ui_url, browsing
+// the spec at /api/v1/openapi.json
+func (g *Gateway) Docs(c *gin.Context) {
+	cfg := g.config.Gateway.Docs
+	if !cfg.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API docs are disabled"})
+		return
+	}
+	var buf bytes.Buffer
+	err := docsTemplate.Execute(&buf, map[string]string{
+		"assets": cfg.SwaggerUIURL,
+		"spec":   g.publicBaseURL(c) + "/api/v1/openapi.json",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render API docs"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>AI Search API</title>
+<link rel="stylesheet" href="{{.assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.assets}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({ url: {{.spec}}, dom_id: "#swagger-ui", deepLinking: true });
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterBrowserRoutes serves what browsers need to use the service as a
+// search engine: the OpenSearch description, a GET /search?q= entry point
+// and the server-rendered results page, which runs as the request's tenant
+func (g *Gateway) RegisterBrowserRoutes(router gin.IRoutes) {
+	router.GET("/opensearch.xml", g.OpenSearchDescription)
+	router.GET("/search", g.SearchRedirect)
+	router.GET("/results", g.RequireLogin, g.resolveTenant, g.trackSearch, g.streamTimeout, g.ResultsPage)
+}
+
+type openSearchDescription struct {
+	XMLName       xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName     string          `xml:"ShortName"`
+	Description   string          `xml:"Description"`
+	InputEncoding string          `xml:"InputEncoding"`
+	URLs          []openSearchURL `xml:"Url"`
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Method   string `xml:"method,attr,omitempty"`
+	Rel      string `xml:"rel,attr,omitempty"`
+	Template string `xml:"template,attr"`
+}
+
+// OpenSearchDescription serves /opensearch.xml, pointing browsers at the
+// search page, the suggestion endpoint and this document itself
+func (g *Gateway) OpenSearchDescription(c *gin.Context) {
+	cfg := g.config.Gateway.OpenSearch
+	base := g.publicBaseURL(c)
+
+	desc := openSearchDescription{
+		ShortName:     cfg.ShortName,
+		Description:   cfg.Description,
+		InputEncoding: "UTF-8",
+		URLs: []openSearchURL{
+			{Type: "text/html", Method: "get", Template: base + "/search?q={searchTerms}"},
+			{Type: "application/opensearchdescription+xml", Rel: "self", Template: base + "/opensearch.xml"},
+		},
+	}
+	if g.history != nil {
+		desc.URLs = append(desc.URLs, openSearchURL{Type: suggestionsContentType, Method: "get", Template: base + "/api/v1/suggest?q={searchTerms}"})
+	}
+	c.Header("Content-Type", "application/opensearchdescription+xml; charset=utf-8")
+	c.Status(http.StatusOK)
+	writeXML(c, desc)
+}
+
+// SearchRedirect sends GET /search?q= to the search page, which runs the query
+func (g *Gateway) SearchRedirect(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	c.Redirect(http.StatusFound, "/?q="+url.QueryEscape(query))
+}
@@ -0,0 +1,270 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/eventbus"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/store"
+)
+
+// Topics of the analytics events
+const (
+	topicSearchCompleted = "search.completed"
+	topicQueryRun        = "saved_query.run"
+	topicAdminAction     = "admin.action"
+)
+
+// eventOutbox relays the analytics events written to the store's outbox to
+// the event bus. Events are written with the change they report, in one
+// transaction where the change is kept in the store, so a crash loses
+// neither; the relay publishes them at least once.
+type eventOutbox struct {
+	config config.EventsConfig
+	db     *store.Store
+	bus    eventbus.Publisher
+}
+
+// newEventOutbox returns the outbox when events are enabled; they need a
+// store backend
+func newEventOutbox(cfg *config.Config, client *redis.Client, db *store.Store) (*eventOutbox, error) {
+	if !cfg.Events.Enabled {
+		return nil, nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("events need a store backend (store.backend)")
+	}
+	bus, err := eventbus.New(cfg.Events, client)
+	if err != nil {
+		return nil, err
+	}
+	return &eventOutbox{config: cfg.Events, db: db, bus: bus}, nil
+}
+
+// newEvent describes an event of the given topic; key groups the events
+// about one thing, e.g. a saved query's runs
+func newEvent(topic, key string, data interface{}) (*store.OutboxEvent, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s event: %w", topic, err)
+	}
+	return &store.OutboxEvent{Topic: topic, Key: key, Data: encoded}, nil
+}
+
+// add writes an event on its own, for changes not kept in the store
+func (o *eventOutbox) add(ctx context.Context, event *store.OutboxEvent) error {
+	if err := o.db.Outbox.Add(ctx, event); err != nil {
+		return err
+	}
+	monitoring.RecordOutboxEvents("written", 1)
+	return nil
+}
+
+// relay publishes the outbox every relay_interval until ctx ends
+func (o *eventOutbox) relay(ctx context.Context) {
+	ticker := time.NewTicker(o.config.RelayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := o.flush(ctx); err != nil {
+				logger.GetLogger().Warnf("Failed to publish events, retrying in %s: %v", o.config.RelayInterval, err)
+			}
+		}
+	}
+}
+
+// flush publishes the pending events batch by batch, returning how many it
+// published
+func (o *eventOutbox) flush(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		n, err := o.db.Outbox.Relay(ctx, o.config.BatchSize, func(ctx context.Context, events []*store.OutboxEvent) error {
+			ctx, cancel := context.WithTimeout(ctx, o.config.Timeout)
+			defer cancel()
+			err := o.bus.Publish(ctx, events)
+			if err != nil {
+				monitoring.RecordOutboxEvents("failed", len(events))
+			}
+			return err
+		})
+		published += n
+		monitoring.RecordOutboxEvents("published", n)
+		if err != nil || n < o.config.BatchSize {
+			return published, err
+		}
+	}
+}
+
+// publish writes an event for a change the gateway does not keep in the
+// store; without events it does nothing
+func (g *Gateway) publish(topic, key string, data interface{}) {
+	if g.outbox == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	event, err := newEvent(topic, key, data)
+	if err == nil {
+		err = g.outbox.add(ctx, event)
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to write %s event: %v", topic, err)
+	}
+}
+
+// searchCompletedEvent is the data of a search.completed event
+type searchCompletedEvent struct {
+	TaskID     string `json:"task_id"`
+	Tenant     string `json:"tenant"`
+	Query      string `json:"query"`
+	Results    int    `json:"results"`
+	Summarized bool   `json:"summarized"`
+}
+
+// queryRunEvent is the data of a saved_query.run event
+type queryRunEvent struct {
+	RunID     string    `json:"run_id"`
+	QueryID   string    `json:"query_id"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration_seconds"`
+	Results   int       `json:"results"`
+	Changed   bool      `json:"changed"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func newQueryRunEvent(run *QueryRun) (*store.OutboxEvent, error) {
+	return newEvent(topicQueryRun, run.QueryID, &queryRunEvent{
+		RunID:     run.ID,
+		QueryID:   run.QueryID,
+		StartedAt: run.StartedAt,
+		Duration:  run.Duration,
+		Results:   len(run.Results),
+		Changed:   run.Changed,
+		Error:     run.Error,
+	})
+}
+
+func newAdminActionEvent(entry *AuditEntry) (*store.OutboxEvent, error) {
+	return newEvent(topicAdminAction, entry.Actor, entry)
+}
+
+// withAuditEvents makes an audit log write an admin.action event with each
+// entry: in the entry's transaction when the store keeps the audit log
+func withAuditEvents(cfg *config.Config, db *store.Store, audit AuditLog) AuditLog {
+	if !cfg.Events.Enabled || db == nil {
+		return audit
+	}
+	if durable, ok := audit.(*durableAuditLog); ok {
+		durable.outbox = db
+		return durable
+	}
+	return &outboxAuditLog{AuditLog: audit, outbox: db.Outbox}
+}
+
+// outboxAuditLog writes the events of an audit log kept outside the store
+// after its entries
+type outboxAuditLog struct {
+	AuditLog
+	outbox store.Outbox
+}
+
+func (l *outboxAuditLog) Append(ctx context.Context, entry *AuditEntry) error {
+	if err := l.AuditLog.Append(ctx, entry); err != nil {
+		return err
+	}
+	event, err := newAdminActionEvent(entry)
+	if err != nil {
+		return err
+	}
+	if err := l.outbox.Add(ctx, event); err != nil {
+		return err
+	}
+	monitoring.RecordOutboxEvents("written", 1)
+	return nil
+}
+
+// withRunEvents makes a query store write a saved_query.run event with each
+// run: in the run's transaction when the store keeps saved queries
+func withRunEvents(cfg *config.Config, db *store.Store, queries QueryStore) QueryStore {
+	if queries == nil || !cfg.Events.Enabled || db == nil {
+		return queries
+	}
+	if durable, ok := queries.(*durableQueryStore); ok {
+		durable.outbox = db
+		return durable
+	}
+	return &outboxQueryStore{QueryStore: queries, outbox: db.Outbox}
+}
+
+// outboxQueryStore writes the events of saved query runs kept outside the
+// store after the runs
+type outboxQueryStore struct {
+	QueryStore
+	outbox store.Outbox
+}
+
+func (s *outboxQueryStore) AddRun(ctx context.Context, run *QueryRun, keep int) error {
+	if err := s.QueryStore.AddRun(ctx, run, keep); err != nil {
+		return err
+	}
+	event, err := newQueryRunEvent(run)
+	if err != nil {
+		return err
+	}
+	if err := s.outbox.Add(ctx, event); err != nil {
+		return err
+	}
+	monitoring.RecordOutboxEvents("written", 1)
+	return nil
+}
+
+func (g *Gateway) registerEventAdminRoutes(admin *gin.RouterGroup) {
+	if g.outbox == nil || (g.access == nil && g.config.Gateway.Tenancy.AdminToken == "") {
+		return
+	}
+	admin.GET("/events", g.authorize(auth.RoleViewer), g.EventOutbox)
+	admin.POST("/events/relay", g.authorize(auth.RoleAdmin), g.RelayEvents)
+}
+
+// EventOutbox reports the event bus and how many events wait to be
+// published (GET /admin/events)
+func (g *Gateway) EventOutbox(c *gin.Context) {
+	pending, err := g.db.Outbox.Pending(c.Request.Context())
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to count pending events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the outbox"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bus": g.outbox.config.Bus, "pending": pending})
+}
+
+// RelayEvents publishes the pending events now rather than at the next
+// relay_interval (POST /admin/events/relay)
+func (g *Gateway) RelayEvents(c *gin.Context) {
+	published, err := g.outbox.flush(c.Request.Context())
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to publish events after %d: %v", published, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to publish the events to the bus", "published": published})
+		return
+	}
+	pending, err := g.db.Outbox.Pending(c.Request.Context())
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to count pending events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the outbox"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"published": published, "pending": pending})
+}
@@ -0,0 +1,293 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/pipeline"
+	pb "ai-search-service/proto"
+)
+
+// PartialResult is what a streaming request had produced when it died, kept
+// so the client can fetch it or continue the summary from that point
+type PartialResult struct {
+	RequestID     string         `json:"request_id"`
+	Query         string         `json:"query"`
+	SearchResults []SearchResult `json:"search_results,omitempty"`
+	Summary       string         `json:"summary"`   // sanitized text generated so far
+	Complete      bool           `json:"complete"`  // the summary finished but the client had gone away
+	Reason        string         `json:"reason"`    // why the stream ended early
+	LLMCalls      int32          `json:"llm_calls"` // summarization calls spent so far, counted against the budget
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Tenant        string         `json:"tenant,omitempty"`
+	User          string         `json:"user,omitempty"` // dataSubject of the request; "" for anonymous ones
+}
+
+// PartialStore persists partial results keyed by request ID
+type PartialStore interface {
+	Save(ctx context.Context, result *PartialResult) error
+	// Get returns found=false for unknown or expired request IDs
+	Get(ctx context.Context, requestID string) (result *PartialResult, found bool, err error)
+	// EraseUser deletes the partial results of a user of the tenant,
+	// returning how many it deleted
+	EraseUser(ctx context.Context, tenant, user string) (int, error)
+}
+
+// newPartialStore returns the configured store, or nil when partial results are disabled
+func newPartialStore(cfg *config.Config, client *redis.Client) PartialStore {
+	partialCfg := cfg.Gateway.Partials
+	if !partialCfg.Enabled {
+		return nil
+	}
+	if client != nil {
+		return &redisPartialStore{client: client, ttl: partialCfg.TTL}
+	}
+	return &memoryPartialStore{results: lru.New[string, PartialResult]("gateway_partials", cfg.Gateway.MemoryStoreEntries, partialCfg.TTL)}
+}
+
+type redisPartialStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func redisPartialKey(requestID string) string {
+	return "search:partial:" + requestID
+}
+
+func (s *redisPartialStore) Save(ctx context.Context, result *PartialResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisPartialKey(result.RequestID), data, s.ttl).Err()
+}
+
+func (s *redisPartialStore) Get(ctx context.Context, requestID string) (*PartialResult, bool, error) {
+	data, err := s.client.Get(ctx, redisPartialKey(requestID)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var result PartialResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false, fmt.Errorf("invalid partial result: %w", err)
+	}
+	return &result, true, nil
+}
+
+func (s *redisPartialStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return eraseRedis(ctx, s.client, redisPartialKey("*"), func(data []byte) (bool, error) {
+		var result PartialResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return false, fmt.Errorf("invalid partial result: %w", err)
+		}
+		return result.Tenant == tenant && result.User == user, nil
+	})
+}
+
+type memoryPartialStore struct {
+	results *lru.Cache[string, PartialResult]
+}
+
+func (s *memoryPartialStore) Save(ctx context.Context, result *PartialResult) error {
+	s.results.Add(result.RequestID, *result)
+	return nil
+}
+
+func (s *memoryPartialStore) Get(ctx context.Context, requestID string) (*PartialResult, bool, error) {
+	result, ok := s.results.Get(requestID)
+	if !ok {
+		return nil, false, nil
+	}
+	return &result, true, nil
+}
+
+func (s *memoryPartialStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return s.results.RemoveFunc(func(requestID string, result PartialResult) bool {
+		return result.Tenant == tenant && result.User == user
+	}), nil
+}
+
+// savePartial persists what an interrupted stream generated. The summary is
+// sanitized first since it is served later without another safety pass.
+func (g *Gateway) savePartial(c *gin.Context, requestID, query string, results []SearchResult, summary string, complete bool, reason string, llmCalls int32) {
+	if g.partials == nil {
+		return
+	}
+	log := logger.GetLogger()
+
+	if summary != "" {
+		// The partial summary of a cancelled or abandoned stream is kept too
+		ctx, cancel := g.stageContext(detached(c), pipeline.StageSanitize)
+		sanitizeResp, err := g.safetyClient.SanitizeOutput(ctx, &pb.SanitizeOutputRequest{Text: summary, Policy: g.safetyPolicy(c)})
+		cancel()
+		if err != nil {
+			log.Errorf("Failed to sanitize partial summary for %s: %v", requestID, err)
+			summary = ""
+			reason += "; partial summary could not be sanitized"
+		} else {
+			summary = sanitizeResp.SanitizedText
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := g.partials.Save(ctx, &PartialResult{
+		RequestID:     requestID,
+		Query:         query,
+		SearchResults: results,
+		Summary:       summary,
+		Complete:      complete,
+		Reason:        reason,
+		LLMCalls:      llmCalls,
+		UpdatedAt:     time.Now(),
+		Tenant:        g.tenant(c).ID,
+		User:          g.dataSubject(c),
+	})
+	if err != nil {
+		log.Warnf("Failed to persist partial result for %s: %v", requestID, err)
+		return
+	}
+	log.Infof("Persisted partial result for %s (%d chars, %s)", requestID, len(summary), reason)
+}
+
+func (g *Gateway) lookupPartial(c *gin.Context) (*PartialResult, bool) {
+	if g.partials == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partial results are disabled"})
+		return nil, false
+	}
+	partial, found, err := g.partials.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load partial result %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load partial result"})
+		return nil, false
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No partial result for this request"})
+		return nil, false
+	}
+	return partial, true
+}
+
+// GetPartial returns the partial result of an interrupted streaming request
+func (g *Gateway) GetPartial(c *gin.Context) {
+	if partial, ok := g.lookupPartial(c); ok {
+		c.JSON(http.StatusOK, partial)
+	}
+}
+
+// ContinuePartial streams the rest of an interrupted summary over SSE. The
+// partial summary is sent first, then only newly generated tokens.
+func (g *Gateway) ContinuePartial(c *gin.Context) {
+	partial, ok := g.lookupPartial(c)
+	if !ok {
+		return
+	}
+	log := logger.GetLogger()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	events := g.newEventStream(c)
+	events.send("status", gin.H{"type": "continuing", "request_id": events.id, "continues": partial.RequestID})
+	events.send("partial", gin.H{"type": "partial", "summary": partial.Summary})
+	if partial.Complete {
+		events.send("complete", gin.H{"type": "complete"})
+		return
+	}
+
+	ctx, cancel := g.stageContext(c, pipeline.StageSummarize)
+	defer cancel()
+
+	llmReq := &pb.LLMRequest{
+		Id:            fmt.Sprintf("continue_%d", time.Now().UnixNano()),
+		Text:          pipeline.SummarizationText(g.snippets.Clean(partial.SearchResults)),
+		MaxTokens:     150,
+		Stream:        true,
+		CreatedAt:     time.Now().Unix(),
+		Continuation:  partial.Summary,
+		PriorLlmCalls: partial.LLMCalls,
+	}
+	// The continuation is recorded in the prompt audit like a search
+	audit := func(*pb.LLMRequest, string, string, error) {}
+	if g.prompts != nil {
+		audit = g.auditHook(c, "continue", partial.Query)
+	}
+	stream, err := g.llmClient.StreamRequest(ctx, llmReq)
+	if err != nil {
+		log.Errorf("Failed to start continuation of %s: %v", partial.RequestID, err)
+		audit(llmReq, "", "", err)
+		events.send("error", gin.H{"message": "Failed to continue AI summarization"})
+		return
+	}
+
+	var summary strings.Builder
+	var budget *BudgetReport
+	summary.WriteString(partial.Summary)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF || (err == nil && response.IsFinal && response.Error == "") {
+			if err == nil && response.Token != "" {
+				summary.WriteString(response.Token)
+				events.sendToken(response.Token, response.Position)
+			}
+			if err == nil {
+				budget = budget.merge(response.Budget)
+			}
+			break
+		}
+		if err != nil || response.Error != "" {
+			reason := "continuation stream failed"
+			if err != nil {
+				log.Errorf("Continuation stream error for %s: %v", partial.RequestID, err)
+			} else {
+				reason = response.Error
+			}
+			g.savePartial(c, events.id, partial.Query, partial.SearchResults, summary.String(), false, reason, partial.LLMCalls+1)
+			audit(llmReq, summary.String(), "", errors.New(reason))
+			events.send("error", gin.H{"message": "Streaming error", "request_id": events.id})
+			return
+		}
+		if response.Token != "" {
+			summary.WriteString(response.Token)
+			events.sendToken(response.Token, response.Position)
+		}
+	}
+
+	// The continued summary is checked as a whole, like a regular stream
+	safetyCtx, safetyCancel := g.stageContext(c, pipeline.StageSanitize)
+	defer safetyCancel()
+	sanitizeResp, err := g.safetyClient.SanitizeOutput(safetyCtx, &pb.SanitizeOutputRequest{Text: summary.String(), Policy: g.safetyPolicy(c)})
+	if err != nil {
+		log.Errorf("Continued output sanitization failed: %v", err)
+		audit(llmReq, summary.String(), "", err)
+		events.send("error", gin.H{"message": "Summary sanitization failed"})
+		return
+	}
+	audit(llmReq, summary.String(), sanitizeResp.SanitizedText, nil)
+	if sanitizeResp.SanitizedText != summary.String() {
+		events.send("summary_sanitized", gin.H{
+			"type":     "summary_sanitized",
+			"message":  "Summary was filtered for safety",
+			"warnings": sanitizeResp.Warnings,
+		})
+	}
+	events.sendBudget(budget)
+	events.send("complete", gin.H{"type": "complete"})
+}
@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+)
+
+// prefetcher fetches the results of a completed search's related searches
+// in the background, within the capacity interactive searches leave
+type prefetcher struct {
+	config config.PrefetchConfig
+	slots  chan struct{} // one per running prefetch
+}
+
+// newPrefetcher returns nil when prefetching is disabled, enabling the
+// engine's result cache otherwise
+func newPrefetcher(cfg *config.Config, engine *pipeline.Engine) *prefetcher {
+	prefetchCfg := cfg.Gateway.RelatedSearches.Prefetch
+	if !cfg.Gateway.RelatedSearches.Enabled || !prefetchCfg.Enabled {
+		return nil
+	}
+	engine.EnableResultCache(prefetchCfg.MaxEntries, prefetchCfg.TTL)
+	return &prefetcher{config: prefetchCfg, slots: make(chan struct{}, max(prefetchCfg.MaxConcurrent, 1))}
+}
+
+// prefetchHook returns the Prefetch hook of a pipeline request: each of the
+// first related searches is searched with the request's tenant, locale and
+// parameters, unless the gateway is busy
+func (g *Gateway) prefetchHook(c *gin.Context, req *pipeline.Request) func(related []string) {
+	return func(related []string) {
+		p := g.prefetcher
+		if len(related) > p.config.Queries {
+			related = related[:p.config.Queries]
+		}
+		background := detached(c)
+		for _, query := range related {
+			if g.drain.searching() > p.config.MaxInFlight {
+				monitoring.RecordPrefetch("skipped_busy")
+				continue
+			}
+			select {
+			case p.slots <- struct{}{}:
+			default:
+				monitoring.RecordPrefetch("skipped_busy")
+				continue
+			}
+			prefetch := g.pipelineRequest(background, "prefetch", query, req.SafeSearch, req.NumResults)
+			prefetch.Prefetch = nil
+			go func() {
+				defer func() { <-p.slots }()
+				if err := g.pipeline.Prefetch(prefetch); err != nil {
+					logger.GetLogger().Warnf("Failed to prefetch results for %q: %v", prefetch.Query, err)
+				}
+			}()
+		}
+	}
+}
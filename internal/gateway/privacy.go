@@ -0,0 +1,29 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIP is the request's client IP as privacy.ip_mode lets it be logged,
+// stored or passed on
+func (g *Gateway) clientIP(c *gin.Context) string {
+	return g.ips.IP(c.ClientIP())
+}
+
+// AccessLog logs each request in gin's format, with the client IP
+// anonymized as privacy.ip_mode says
+func (g *Gateway) AccessLog() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			g.ips.IP(param.ClientIP),
+			param.Method,
+			param.Path,
+			param.ErrorMessage,
+		)
+	})
+}
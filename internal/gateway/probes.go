@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+
+	"ai-search-service/internal/probes"
+	pb "ai-search-service/proto"
+)
+
+// newProbes checks that every backend service answers and can take traffic
+// (the inference service has warmed up its models), that Redis is reachable
+// when configured and that the gateway is not draining
+func (g *Gateway) newProbes(client *redis.Client) *probes.Probes {
+	p := probes.New("gateway", g.config.Probes.Timeout)
+	backends := map[string]func(context.Context, *pb.HealthCheckRequest, ...grpc.CallOption) (*pb.HealthCheckResponse, error){
+		"search":    g.searchClient.HealthCheck,
+		"safety":    g.safetyClient.HealthCheck,
+		"llm":       g.llmClient.HealthCheck,
+		"inference": g.inferenceClient.HealthCheck,
+	}
+	for name, healthCheck := range backends {
+		healthCheck := healthCheck
+		p.AddCheck(name, probes.Health(func(ctx context.Context) (*pb.HealthCheckResponse, error) {
+			return healthCheck(ctx, &pb.HealthCheckRequest{})
+		}))
+	}
+	if client != nil {
+		p.AddCheck("redis", func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		})
+	}
+	if g.db != nil {
+		p.AddCheck("store", g.db.Ping)
+	}
+	p.AddCheck("shutdown", func(ctx context.Context) error {
+		if draining, _ := g.drain.isDraining(); draining {
+			return errors.New("draining")
+		}
+		return nil
+	})
+	p.MarkStarted()
+	return p
+}
+
+// RegisterProbeRoutes serves the Kubernetes probes, /healthz, /readyz and
+// /startupz. /health stays as the summary for humans and dashboards.
+func (g *Gateway) RegisterProbeRoutes(router gin.IRoutes) {
+	router.GET("/healthz", gin.WrapF(g.probes.Liveness))
+	router.GET("/readyz", gin.WrapF(g.probes.Readiness))
+	router.GET("/startupz", gin.WrapF(g.probes.Startup))
+}
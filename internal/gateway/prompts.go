@@ -0,0 +1,490 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// PromptRecord is one summarization as the model saw and answered it, kept
+// for tenants' AI-usage audits. Personal data is redacted from every text.
+type PromptRecord struct {
+	RequestID string    `json:"request_id"`
+	Tenant    string    `json:"tenant"`
+	User      string    `json:"user,omitempty"` // the logged-in user who searched, or key:<id> of the API key
+	Mode      string    `json:"mode"`
+	Query     string    `json:"query"`
+	Model     string    `json:"model,omitempty"`  // empty for the default model
+	Prompt    string    `json:"prompt"`           // the text sent to the LLM orchestrator
+	Output    string    `json:"output"`           // as generated
+	Served    string    `json:"served,omitempty"` // as served, when the safety filter changed it
+	Error     string    `json:"error,omitempty"`
+	Redacted  int       `json:"redacted"` // spans of personal data replaced
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptStore persists prompt records by tenant and request ID
+type PromptStore interface {
+	Save(ctx context.Context, record *PromptRecord) error
+	// Get returns found=false for unknown or expired records
+	Get(ctx context.Context, tenant, requestID string) (record *PromptRecord, found bool, err error)
+	// List returns up to limit of the tenant's records created in
+	// [from, to), oldest first
+	List(ctx context.Context, tenant string, from, to time.Time, limit int) ([]*PromptRecord, error)
+	// Expire drops every tenant's records created before the given time,
+	// returning how many it dropped
+	Expire(ctx context.Context, before time.Time) (int, error)
+	// EraseUser deletes the records of a user of the tenant, returning how
+	// many it deleted
+	EraseUser(ctx context.Context, tenant, user string) (int, error)
+}
+
+// promptAudit records the summarizations of searches when
+// gateway.prompt_audit is enabled
+type promptAudit struct {
+	store     PromptStore
+	redact    []redaction
+	retention time.Duration
+}
+
+// redaction replaces the matches of a pattern with a placeholder; when
+// applies is set, only the matches it accepts
+type redaction struct {
+	pattern     *regexp.Regexp
+	placeholder string
+	applies     func(match string) bool
+}
+
+// piiRedactions match personal data and secrets, most specific first so a
+// card number is not taken for a phone number
+var piiRedactions = []redaction{
+	{pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), placeholder: "[EMAIL]"},
+	{pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), placeholder: "[SSN]"},
+	{pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), placeholder: "[CARD]"},
+	{pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`), placeholder: "[PHONE]"},
+	{pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), placeholder: "[IP]"},
+	// API keys and tokens: long runs mixing letters and digits
+	{pattern: regexp.MustCompile(`\b[A-Za-z0-9_-]{24,}\b`), placeholder: "[SECRET]", applies: func(match string) bool {
+		return strings.ContainsAny(match, "0123456789") && strings.IndexFunc(match, unicode.IsLetter) >= 0
+	}},
+}
+
+// redactText replaces the personal data in text, returning how many spans
+// it replaced
+func (a *promptAudit) redactText(text string) (string, int) {
+	count := 0
+	for _, r := range a.redact {
+		text = r.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if r.applies != nil && !r.applies(match) {
+				return match
+			}
+			count++
+			return r.placeholder
+		})
+	}
+	return text, count
+}
+
+// newPromptAudit returns the configured prompt audit, or nil when it is
+// disabled
+func newPromptAudit(cfg *config.Config, client *redis.Client) (*promptAudit, error) {
+	auditCfg := cfg.Gateway.PromptAudit
+	if !auditCfg.Enabled {
+		return nil, nil
+	}
+	a := &promptAudit{redact: piiRedactions, retention: auditCfg.Retention}
+	for _, pattern := range auditCfg.RedactPatterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gateway.prompt_audit.redact_patterns entry %q: %w", pattern, err)
+		}
+		a.redact = append(slices.Clip(a.redact), redaction{pattern: compiled, placeholder: "[REDACTED]"})
+	}
+	if client != nil {
+		a.store = &redisPromptStore{client: client, retention: auditCfg.Retention}
+	} else {
+		a.store = &memoryPromptStore{max: cfg.Gateway.MemoryStoreEntries, retention: auditCfg.Retention, records: make(map[string][]*PromptRecord)}
+	}
+	return a, nil
+}
+
+// record redacts and stores a summarization, counting the outcome
+func (a *promptAudit) record(record *PromptRecord) {
+	total := 0
+	for _, text := range []*string{&record.Query, &record.Prompt, &record.Output, &record.Served, &record.Error} {
+		var n int
+		*text, n = a.redactText(*text)
+		total += n
+	}
+	record.Redacted = total
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := a.store.Save(ctx, record); err != nil {
+		logger.GetLogger().Errorf("Failed to record the prompt of request %s: %v", record.RequestID, err)
+		monitoring.RecordPromptAudit("failed")
+		return
+	}
+	if total > 0 {
+		monitoring.RecordPromptAudit("redacted")
+	} else {
+		monitoring.RecordPromptAudit("recorded")
+	}
+}
+
+// requestID identifies a search in its prompt record: the ID of its event
+// stream, or one assigned here and sent as X-Request-ID
+func (g *Gateway) requestID(c *gin.Context) string {
+	if id := c.GetString(streamIDKey); id != "" {
+		return id
+	}
+	id := newStreamID()
+	c.Set(streamIDKey, id)
+	c.Header("X-Request-ID", id)
+	return id
+}
+
+// auditHook returns the Audit hook of a search's pipeline request, which
+// records the summarization under the request's ID and tenant
+func (g *Gateway) auditHook(c *gin.Context, mode, query string) func(*pb.LLMRequest, string, string, error) {
+	requestID, tenant, user := g.requestID(c), g.tenant(c).ID, g.dataSubject(c)
+	return func(llmReq *pb.LLMRequest, generated, served string, err error) {
+		g.prompts.record(g.promptRecord(requestID, tenant, user, mode, query, llmReq, generated, served, err))
+	}
+}
+
+// promptRecord describes a summarization; served is only kept when the
+// safety filter changed the generated text
+func (g *Gateway) promptRecord(requestID, tenant, user, mode, query string, llmReq *pb.LLMRequest, generated, served string, err error) *PromptRecord {
+	record := &PromptRecord{
+		RequestID: requestID,
+		Tenant:    tenant,
+		User:      user,
+		Mode:      mode,
+		Query:     query,
+		Model:     llmReq.Model,
+		Prompt:    llmReq.Text,
+		Output:    generated,
+		CreatedAt: time.Now().UTC(),
+	}
+	if served != generated {
+		record.Served = served
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
+
+type redisPromptStore struct {
+	client    *redis.Client
+	retention time.Duration
+}
+
+func redisPromptKey(tenant, requestID string) string {
+	return "prompts:record:" + tenant + ":" + requestID
+}
+
+// redisPromptIndexKey is a sorted set of a tenant's request IDs scored by
+// creation time in nanoseconds
+func redisPromptIndexKey(tenant string) string {
+	return "prompts:index:" + tenant
+}
+
+func (s *redisPromptStore) Save(ctx context.Context, record *PromptRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	index := redisPromptIndexKey(record.Tenant)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, redisPromptKey(record.Tenant, record.RequestID), data, s.retention)
+	pipe.ZAdd(ctx, index, redis.Z{Score: float64(record.CreatedAt.UnixNano()), Member: record.RequestID})
+	pipe.ZRemRangeByScore(ctx, index, "-inf", "("+strconv.FormatInt(record.CreatedAt.Add(-s.retention).UnixNano(), 10))
+	pipe.Expire(ctx, index, s.retention)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisPromptStore) Get(ctx context.Context, tenant, requestID string) (*PromptRecord, bool, error) {
+	data, err := s.client.Get(ctx, redisPromptKey(tenant, requestID)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var record PromptRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, false, fmt.Errorf("invalid prompt record: %w", err)
+	}
+	return &record, true, nil
+}
+
+func (s *redisPromptStore) List(ctx context.Context, tenant string, from, to time.Time, limit int) ([]*PromptRecord, error) {
+	ids, err := s.client.ZRangeByScore(ctx, redisPromptIndexKey(tenant), &redis.ZRangeBy{
+		Min:   strconv.FormatInt(from.UnixNano(), 10),
+		Max:   "(" + strconv.FormatInt(to.UnixNano(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = redisPromptKey(tenant, id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*PromptRecord, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // expired since the index was trimmed
+		}
+		var record PromptRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, fmt.Errorf("invalid prompt record: %w", err)
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// Expire trims the tenants' indexes; the records expire on their own
+func (s *redisPromptStore) Expire(ctx context.Context, before time.Time) (int, error) {
+	return scanRedis(ctx, s.client, redisPromptIndexKey("*"), func(index string) (int, error) {
+		n, err := s.client.ZRemRangeByScore(ctx, index, "-inf", "("+strconv.FormatInt(before.UnixNano(), 10)).Result()
+		return int(n), err
+	})
+}
+
+func (s *redisPromptStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return scanRedis(ctx, s.client, redisPromptKey(tenant, "*"), func(key string) (int, error) {
+		data, err := s.client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var record PromptRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return 0, fmt.Errorf("invalid prompt record: %w", err)
+		}
+		if record.User != user {
+			return 0, nil
+		}
+		pipe := s.client.TxPipeline()
+		pipe.Del(ctx, key)
+		pipe.ZRem(ctx, redisPromptIndexKey(tenant), record.RequestID)
+		_, err = pipe.Exec(ctx)
+		return 1, err
+	})
+}
+
+// memoryPromptStore keeps up to max records per tenant, dropping the oldest
+// and those older than the retention period
+type memoryPromptStore struct {
+	max       int
+	retention time.Duration
+	mu        sync.Mutex
+	records   map[string][]*PromptRecord // by tenant, oldest first
+}
+
+func (s *memoryPromptStore) Save(ctx context.Context, record *PromptRecord) error {
+	saved := *record
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := append(s.current(record.Tenant), &saved)
+	if s.max > 0 && len(records) > s.max {
+		records = records[len(records)-s.max:]
+	}
+	s.records[record.Tenant] = records
+	return nil
+}
+
+// current drops the tenant's records older than the retention period and
+// returns the rest; s.mu must be held
+func (s *memoryPromptStore) current(tenant string) []*PromptRecord {
+	s.expire(tenant, time.Now().Add(-s.retention))
+	return s.records[tenant]
+}
+
+// expire drops the tenant's records created before the given time,
+// returning how many it dropped; s.mu must be held
+func (s *memoryPromptStore) expire(tenant string, before time.Time) int {
+	records := s.records[tenant]
+	expired := 0
+	for expired < len(records) && records[expired].CreatedAt.Before(before) {
+		expired++
+	}
+	if expired == len(records) {
+		delete(s.records, tenant)
+	} else {
+		s.records[tenant] = records[expired:]
+	}
+	return expired
+}
+
+func (s *memoryPromptStore) Expire(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := 0
+	for tenant := range s.records {
+		expired += s.expire(tenant, before)
+	}
+	return expired, nil
+}
+
+func (s *memoryPromptStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.records[tenant]
+	kept := make([]*PromptRecord, 0, len(records))
+	for _, record := range records {
+		if record.User != user {
+			kept = append(kept, record)
+		}
+	}
+	s.records[tenant] = kept
+	return len(records) - len(kept), nil
+}
+
+func (s *memoryPromptStore) Get(ctx context.Context, tenant, requestID string) (*PromptRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.current(tenant) {
+		if record.RequestID == requestID {
+			found := *record
+			return &found, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (s *memoryPromptStore) List(ctx context.Context, tenant string, from, to time.Time, limit int) ([]*PromptRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []*PromptRecord
+	for _, record := range s.current(tenant) {
+		if len(records) == limit {
+			break
+		}
+		if !record.CreatedAt.Before(from) && record.CreatedAt.Before(to) {
+			found := *record
+			records = append(records, &found)
+		}
+	}
+	return records, nil
+}
+
+// registerPromptAdminRoutes registers the prompt audit export for admins,
+// with RBAC or tenancy.admin_token, when the prompt audit is enabled
+func (g *Gateway) registerPromptAdminRoutes(admin *gin.RouterGroup) {
+	if g.prompts == nil || (g.access == nil && g.config.Gateway.Tenancy.AdminToken == "") {
+		return
+	}
+	admin.GET("/prompts", g.authorize(auth.RoleAdmin), g.ListPrompts)
+	admin.GET("/prompts/:request_id", g.authorize(auth.RoleAdmin), g.GetPrompt)
+}
+
+// ListPrompts exports a tenant's prompt records created in [from, to),
+// oldest first, as JSON or, with format=jsonl, as a JSON Lines download
+// (GET /admin/prompts?tenant=acme&from=...&to=...&limit=1000&format=json).
+// The range defaults to the retention period up to now.
+func (g *Gateway) ListPrompts(c *gin.Context) {
+	now := time.Now().UTC()
+	from, ok := timeParam(c, "from", now.Add(-g.prompts.retention))
+	if !ok {
+		return
+	}
+	to, ok := timeParam(c, "to", now.Add(time.Second))
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "1000"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or jsonl"})
+		return
+	}
+	tenant := c.DefaultQuery("tenant", defaultTenantID)
+	records, err := g.prompts.store.List(c.Request.Context(), tenant, from, to, limit)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to list the prompt records of tenant %s: %v", tenant, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read prompt records"})
+		return
+	}
+	if format == "json" {
+		if records == nil {
+			records = []*PromptRecord{}
+		}
+		c.JSON(http.StatusOK, gin.H{"tenant": tenant, "records": records})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="prompts-%s-%s.jsonl"`, tenant, now.Format("20060102T150405Z")))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			logger.GetLogger().Warnf("Failed to write the prompt export of tenant %s: %v", tenant, err)
+			return
+		}
+	}
+}
+
+// GetPrompt returns one prompt record of a tenant
+// (GET /admin/prompts/:request_id?tenant=acme)
+func (g *Gateway) GetPrompt(c *gin.Context) {
+	tenant := c.DefaultQuery("tenant", defaultTenantID)
+	record, found, err := g.prompts.store.Get(c.Request.Context(), tenant, c.Param("request_id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to read prompt record %s: %v", c.Param("request_id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read prompt record"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt record not found or expired"})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// timeParam parses an RFC 3339 query parameter, answering 400 when it is
+// malformed
+func timeParam(c *gin.Context, name string, fallback time.Time) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 time"})
+		return time.Time{}, false
+	}
+	return t, true
+}
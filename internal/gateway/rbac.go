@@ -0,0 +1,282 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/store"
+)
+
+// principalContextKey is the gin context key of the authorized *auth.Principal
+const principalContextKey = "principal"
+
+// accessControl holds the admin API keys and the audit log of role-based
+// access control
+type accessControl struct {
+	keys  []adminKey
+	audit AuditLog
+}
+
+type adminKey struct {
+	name string
+	hash string
+	role auth.Role
+}
+
+// newAccessControl returns nil when RBAC is disabled
+func newAccessControl(cfg *config.Config, client *redis.Client, db *store.Store) (*accessControl, error) {
+	rbacCfg := cfg.Gateway.RBAC
+	if !rbacCfg.Enabled {
+		return nil, nil
+	}
+	access := &accessControl{}
+	for _, key := range rbacCfg.APIKeys {
+		role, err := auth.ParseRole(key.Role)
+		if err != nil {
+			return nil, fmt.Errorf("rbac key %q: %w", key.Name, err)
+		}
+		if key.Key == "" {
+			return nil, fmt.Errorf("rbac key %q has no key", key.Name)
+		}
+		access.keys = append(access.keys, adminKey{name: key.Name, hash: hashAPIKey(key.Key), role: role})
+	}
+	if db.Keeps(store.AuditLog) {
+		access.audit = &durableAuditLog{events: db.Events, max: rbacCfg.AuditEntries}
+	} else if client != nil {
+		access.audit = &redisAuditLog{client: client, max: rbacCfg.AuditEntries}
+	} else {
+		access.audit = &memoryAuditLog{max: rbacCfg.AuditEntries}
+	}
+	access.audit = withAuditEvents(cfg, db, access.audit)
+	return access, nil
+}
+
+// principal authenticates the caller of an admin endpoint: the tenancy
+// admin token, or with RBAC enabled an admin API key, an OIDC token or the
+// session of a web UI user holding a role. It returns nil for anonymous or
+// unrecognized callers.
+func (g *Gateway) principal(c *gin.Context) *auth.Principal {
+	bearer := ""
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		bearer = strings.TrimPrefix(header, "Bearer ")
+	}
+	if token := g.config.Gateway.Tenancy.AdminToken; token != "" && bearer != "" &&
+		subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+		return &auth.Principal{Subject: "admin_token", Role: auth.RoleAdmin, Method: "admin_token"}
+	}
+	if g.access == nil {
+		return nil
+	}
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		hash := hashAPIKey(key)
+		for _, k := range g.access.keys {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(k.hash)) == 1 {
+				return &auth.Principal{Subject: k.name, Role: k.role, Method: "api_key"}
+			}
+		}
+		return nil
+	}
+	if bearer != "" && g.oidc != nil {
+		p, err := g.oidc.Principal(c.Request.Context(), bearer)
+		if err != nil {
+			logger.GetLogger().Warnf("Rejected admin token: %v", err)
+			return nil
+		}
+		return p
+	}
+	if session := g.readSession(c); session != nil && session.Role != "" {
+		role, err := auth.ParseRole(session.Role)
+		if err != nil {
+			return nil
+		}
+		return &auth.Principal{Subject: session.Subject, Email: session.Email, Role: role, Method: "session"}
+	}
+	return nil
+}
+
+// authorize admits callers holding at least role, answering 401 to
+// unauthenticated and 403 to insufficiently privileged callers
+func (g *Gateway) authorize(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := g.principal(c)
+		if p == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		c.Set(principalContextKey, p)
+		if !p.Role.Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Requires the %s role", role)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowAll admits every caller; fault endpoints stay open without RBAC, as
+// fault injection is never enabled in production
+func allowAll(c *gin.Context) {
+	c.Next()
+}
+
+// AuditEntry records one admin action, including denied attempts
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"` // key name or token subject; empty when unauthenticated
+	Email      string    `json:"email,omitempty"`
+	Role       string    `json:"role,omitempty"`
+	AuthMethod string    `json:"auth_method,omitempty"`
+	Action     string    `json:"action"` // method and route, e.g. "POST /admin/tenants/:id/keys"
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	ClientIP   string    `json:"client_ip"`
+}
+
+// AuditLog keeps the most recent admin actions
+type AuditLog interface {
+	Append(ctx context.Context, entry *AuditEntry) error
+	// Recent returns up to limit entries, newest first
+	Recent(ctx context.Context, limit int) ([]*AuditEntry, error)
+	// Expire drops the entries recorded before the given time, returning
+	// how many it dropped
+	Expire(ctx context.Context, before time.Time) (int, error)
+}
+
+// auditAdmin records every request to the admin endpoints that changes
+// state, once it has been handled
+func (g *Gateway) auditAdmin(c *gin.Context) {
+	c.Next()
+	if g.access == nil || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		return
+	}
+	entry := &AuditEntry{
+		Time:     time.Now().UTC(),
+		Action:   c.Request.Method + " " + c.FullPath(),
+		Path:     c.Request.URL.Path,
+		Status:   c.Writer.Status(),
+		ClientIP: g.clientIP(c),
+	}
+	if value, ok := c.Get(principalContextKey); ok {
+		p := value.(*auth.Principal)
+		entry.Actor, entry.Email, entry.Role, entry.AuthMethod = p.Subject, p.Email, p.Role.String(), p.Method
+	}
+	logger.GetLogger().Infof("Admin action %s %s by %q (%s): %d", c.Request.Method, entry.Path, entry.Actor, entry.Role, entry.Status)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := g.access.audit.Append(ctx, entry); err != nil {
+		logger.GetLogger().Errorf("Failed to write audit entry: %v", err)
+	}
+}
+
+// ListAuditLog returns the most recent admin actions, newest first
+// (GET /admin/audit?limit=100)
+func (g *Gateway) ListAuditLog(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	entries, err := g.access.audit.Recent(c.Request.Context(), limit)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to read audit log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+const redisAuditKey = "audit:admin"
+
+type redisAuditLog struct {
+	client *redis.Client
+	max    int
+}
+
+func (l *redisAuditLog) Append(ctx context.Context, entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	pipe := l.client.TxPipeline()
+	pipe.LPush(ctx, redisAuditKey, data)
+	if l.max > 0 {
+		pipe.LTrim(ctx, redisAuditKey, 0, int64(l.max-1))
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (l *redisAuditLog) Recent(ctx context.Context, limit int) ([]*AuditEntry, error) {
+	values, err := l.client.LRange(ctx, redisAuditKey, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*AuditEntry, 0, len(values))
+	for _, value := range values {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (l *redisAuditLog) Expire(ctx context.Context, before time.Time) (int, error) {
+	return expireRedisList(ctx, l.client, redisAuditKey, func(data []byte) (bool, error) {
+		var entry AuditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return false, fmt.Errorf("invalid audit entry: %w", err)
+		}
+		return entry.Time.Before(before), nil
+	})
+}
+
+type memoryAuditLog struct {
+	max     int
+	mu      sync.Mutex
+	entries []*AuditEntry // oldest first
+}
+
+func (l *memoryAuditLog) Append(ctx context.Context, entry *AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if l.max > 0 && len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+	return nil
+}
+
+func (l *memoryAuditLog) Recent(ctx context.Context, limit int) ([]*AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]*AuditEntry, 0, limit)
+	for i := len(l.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, l.entries[i])
+	}
+	return entries, nil
+}
+
+func (l *memoryAuditLog) Expire(ctx context.Context, before time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expired := 0
+	for expired < len(l.entries) && l.entries[expired].Time.Before(before) {
+		expired++
+	}
+	l.entries = l.entries[expired:]
+	return expired, nil
+}
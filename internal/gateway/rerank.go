@@ -0,0 +1,18 @@
+package gateway
+
+import (
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/pipeline"
+)
+
+// applyReranking has the pipeline rerank req's results when reranking is
+// enabled and its flag is on for subject
+func (g *Gateway) applyReranking(req *pipeline.Request, subject flags.Subject) {
+	reranking := g.config.Gateway.Reranking
+	if !reranking.Enabled || !g.flags.Enabled(flags.Rerank, subject) {
+		return
+	}
+	req.Rerank = true
+	req.MinRelevance = reranking.MinScore
+	req.MinResults = reranking.MinResults
+}
@@ -0,0 +1,333 @@
+package gateway
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+)
+
+// resultsTemplate renders /results in pieces written as the search
+// progresses. The browser shows each piece as it arrives; CSS moves the
+// summary above the results that arrived before it and hides the draft
+// summary when the safety filter replaced it, so the page needs no script.
+var resultsTemplate = template.Must(template.New("results").Funcs(template.FuncMap{
+	"source": sourceLabel,
+	"join":   strings.Join,
+}).Parse(`
+{{- define "head" -}}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{with .Query}}{{.}} - {{end}}{{.Title}}</title>
+<link rel="search" type="application/opensearchdescription+xml" href="/opensearch.xml" title="{{.Title}}">
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 48rem; margin: 1rem auto; padding: 0 1rem; color: #222; line-height: 1.5; }
+form.search { display: flex; gap: 0.5rem; flex-wrap: wrap; align-items: center; margin-bottom: 1rem; }
+form.search input[type=search] { flex: 1 1 20rem; padding: 0.5rem; font-size: 1rem; }
+main { display: flex; flex-direction: column; }
+.instant, .structured { order: -3; }
+.summary { order: -2; }
+.panel { order: -1; }
+.instant, .structured, .summary, .panel { background: #f6f7fb; border-radius: 6px; padding: 0 1rem; margin-bottom: 1rem; }
+main:has(.summary, .done) .pending { display: none; }
+.summary:has(.final) .draft { display: none; }
+.draft:empty::before { content: "Summarizing…"; color: #666; }
+.draft, .final { white-space: pre-wrap; }
+.meta, .url, .snippet, .pending { color: #666; }
+.url { font-size: 0.9em; }
+.results li { margin-bottom: 0.75rem; }
+.results img, .panel img { float: right; max-width: 6rem; max-height: 6rem; margin-left: 0.5rem; }
+.badge { font-size: 0.8em; background: #eef; border-radius: 3px; padding: 0 0.3em; }
+.error { color: #b00020; }
+dt { font-weight: bold; }
+</style>
+</head>
+<body>
+<form class="search" action="/results" method="get" role="search">
+<input type="search" name="q" value="{{.Query}}" placeholder="Search the web" aria-label="Search" required>
+<label><input type="checkbox" name="safe_search" value="true"{{if .SafeSearch}} checked{{end}}> Safe search</label>
+<button type="submit">Search</button>
+</form>
+<main>
+{{if .Query}}<p class="pending">Searching…</p>
+{{end}}
+{{- end}}
+
+{{- define "results" -}}
+<section class="results-list">
+<h2>Sources</h2>
+<ol class="results">
+{{- range .}}
+<li>{{with .ImageURL}}<img src="{{.}}" alt="" loading="lazy">{{end}}<a href="{{.URL}}">{{.Title}}</a>{{with source .}} <span class="badge">{{.}}</span>{{end}}
+<div class="url">{{.DisplayURL}}</div>
+{{- with .Snippet}}<div class="snippet">{{.}}</div>{{end}}</li>
+{{- end}}
+</ol>
+</section>
+{{end}}
+
+{{- define "structured" -}}
+<section class="structured">
+<h2>{{.Title}}</h2>
+<p>{{.Headline}}</p>
+{{- with .Facts}}
+<dl>{{range .}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>{{end}}</dl>
+{{- end}}
+<p class="meta">{{.Provider}}{{with .AsOf}} · {{.}}{{end}}{{with .Source}} · <a href="{{.}}">Source</a>{{end}}</p>
+</section>
+{{end}}
+
+{{- define "panel" -}}
+<aside class="panel">
+<h2>{{.Name}}</h2>
+{{- with .ImageURL}}<img src="{{.}}" alt="">{{end}}
+{{- with .Description}}
+<p>{{.}}</p>
+{{- end}}
+{{- with .Attributes}}
+<dl>{{range .}}<dt>{{.Name}}</dt><dd>{{.Value}}</dd>{{end}}</dl>
+{{- end}}
+{{- with .SourceURL}}
+<p class="meta"><a href="{{.}}">Source</a></p>
+{{- end}}
+</aside>
+{{end}}
+
+{{- define "instant" -}}
+<section class="instant">
+<p class="meta">{{.Input}}</p>
+<h2>{{.Result}}</h2>
+{{- with .Definitions}}
+<ol>{{range .}}<li>{{with .PartOfSpeech}}<em>{{.}}</em> {{end}}{{.Text}}{{with .Example}} <span class="meta">“{{.}}”</span>{{end}}</li>{{end}}</ol>
+{{- end}}
+</section>
+{{end}}
+
+{{- define "summary" -}}
+<section class="summary">
+<h2>Summary</h2>
+<p class="draft">
+{{- end}}
+
+{{- define "summary_end" -}}
+</p>
+{{- with .Final}}
+<p class="final">{{.}}</p>
+{{- end}}
+{{- with .Note}}
+<p class="meta">{{.}}</p>
+{{- end}}
+{{- with .Confidence}}
+<p class="meta">Confidence: {{.Level}} · {{.Sources}} sources</p>
+{{- end}}
+{{- with .Budget}}
+<p class="meta">Stopped early ({{join .Limits ", "}}): {{join .Skipped "; "}}</p>
+{{- end}}
+</section>
+{{- with .Related}}
+<nav class="related">
+<h2>Related searches</h2>
+<ul>{{range .}}<li><a href="/results?q={{.}}">{{.}}</a></li>{{end}}</ul>
+</nav>
+{{- end}}
+{{end}}
+
+{{- define "summary_fragment" -}}
+{{template "summary"}}{{template "summary_end" .}}
+{{- end}}
+
+{{- define "error" -}}
+<p class="error">{{.}}</p>
+{{end}}
+
+{{- define "done" -}}
+<span class="done"{{with .}} data-task-id="{{.}}"{{end}}></span>
+{{- end}}
+
+{{- define "foot" -}}
+{{template "done" ""}}
+</main>
+</body>
+</html>
+{{end}}`))
+
+// resultsPage is the data of the page's head
+type resultsPage struct {
+	Title      string
+	Query      string
+	SafeSearch bool
+}
+
+// summaryEnd closes the summary: Final replaces the streamed draft
+type summaryEnd struct {
+	Final      string
+	Note       string
+	Confidence *pipeline.Confidence
+	Budget     *BudgetReport
+	Related    []string
+}
+
+// renderResults writes one piece of the page and sends it to the client
+func renderResults(c *gin.Context, name string, data interface{}) {
+	if err := resultsTemplate.ExecuteTemplate(c.Writer, name, data); err != nil {
+		logger.GetLogger().Warnf("Failed to render %s of the results page: %v", name, err)
+	}
+	c.Writer.Flush()
+}
+
+// ResultsPage serves /results?q=&safe_search=&num_results=: the search
+// rendered by the gateway and streamed as chunked HTML, the results first
+// and then the summary as it is generated, for clients without JavaScript
+// and a faster first paint. Rejections are rendered into the page with the
+// status the API would have returned.
+func (g *Gateway) ResultsPage(c *gin.Context) {
+	start := time.Now()
+	if !g.config.Gateway.HTMLSearch.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HTML search is disabled"})
+		return
+	}
+	page := resultsPage{
+		Title:      g.config.Gateway.OpenSearch.ShortName,
+		Query:      strings.TrimSpace(c.Query("q")),
+		SafeSearch: c.Query("safe_search") == "true",
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("X-Accel-Buffering", "no")
+
+	if page.Query == "" {
+		c.Status(http.StatusOK)
+		renderResults(c, "head", page)
+		renderResults(c, "foot", nil)
+		return
+	}
+	// Rejections are known before the first byte, so they keep their status
+	if status, message := g.admitHTMLSearch(c, page.Query); status != 0 {
+		c.Status(status)
+		renderResults(c, "head", page)
+		renderResults(c, "error", message)
+		renderResults(c, "foot", nil)
+		return
+	}
+	numResults, _ := strconv.Atoi(c.Query("num_results"))
+	numResults = g.clampNumResults(c, numResults)
+
+	c.Status(http.StatusOK)
+	renderResults(c, "head", page)
+	if answer := g.instantAnswer(c, page.Query, page.SafeSearch); answer != nil {
+		renderResults(c, "instant", answer)
+	} else {
+		numResults, budget := g.capFetchedPages(numResults)
+		emit := &htmlEmitter{g: g, c: c, budget: budget}
+		g.pipeline.Run(g.pipelineRequest(c, "html", page.Query, page.SafeSearch, numResults), emit)
+	}
+	renderResults(c, "foot", nil)
+
+	monitoring.RecordRequest("gateway", "search", "success")
+	monitoring.RecordRequestDuration("gateway", "search", time.Since(start))
+}
+
+// admitHTMLSearch applies the API's query limit and the tenant's quota to
+// a search of the HTML endpoints, returning the status and message of a
+// rejection, or 0 when the search may run
+func (g *Gateway) admitHTMLSearch(c *gin.Context, query string) (int, string) {
+	if max, tooLong := g.checkQueryLength(query); tooLong {
+		monitoring.RecordRequest("gateway", "search", "rejected")
+		return http.StatusRequestEntityTooLarge, "Query exceeds " + strconv.Itoa(max) + " characters"
+	}
+	if message, retryAfter, exceeded := g.chargeQuota(c); exceeded {
+		monitoring.RecordRequest("gateway", "search", "rejected")
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		return http.StatusTooManyRequests, message
+	}
+	return 0, ""
+}
+
+// htmlEmitter renders a search into the results page as it runs, streaming
+// the summary token by token
+type htmlEmitter struct {
+	g           *Gateway
+	c           *gin.Context
+	query       string
+	budget      *BudgetReport
+	results     []SearchResult
+	structured  *answers.Data
+	summarizing bool // the summary section is open
+}
+
+func (e *htmlEmitter) Started(query string) { e.query = query }
+
+func (e *htmlEmitter) Stage(stage pipeline.StageName) {
+	if stage == pipeline.StageSummarize {
+		e.openSummary()
+	}
+}
+
+func (e *htmlEmitter) Results(results []pipeline.Result) {
+	e.results = e.g.proxyResultImages(results)
+	renderResults(e.c, "results", e.results)
+}
+
+func (e *htmlEmitter) Structured(data *answers.Data) {
+	e.structured = data
+	renderResults(e.c, "structured", data)
+}
+
+func (e *htmlEmitter) KnowledgePanel(panel *pipeline.KnowledgePanel) {
+	renderResults(e.c, "panel", e.g.proxyPanelImage(panel))
+}
+
+func (e *htmlEmitter) Token(token string, position int32) {
+	e.openSummary()
+	e.c.Writer.WriteString(template.HTMLEscapeString(token))
+	e.c.Writer.Flush()
+}
+
+func (e *htmlEmitter) Summary(summary pipeline.Summary) {
+	end := summaryEnd{
+		Confidence: summary.Confidence,
+		Budget:     e.budget.merge(summary.Budget),
+		Related:    summary.RelatedSearches,
+	}
+	if summary.Sanitized {
+		end.Final, end.Note = summary.Text, "Summary was filtered for safety"
+	}
+	if e.summarizing || summary.Text != "" || len(end.Related) > 0 {
+		e.openSummary()
+		renderResults(e.c, "summary_end", end)
+	}
+	e.g.searchCompleted(e.c, newStreamID(), e.query, e.results, taskSummary(summary.Text, e.structured))
+}
+
+func (e *htmlEmitter) Fail(err *pipeline.Error) {
+	// A failed summary still completes the search with a placeholder
+	if err.Status == http.StatusOK {
+		e.openSummary()
+		renderResults(e.c, "summary_end", summaryEnd{Final: err.Message, Budget: e.budget})
+		return
+	}
+	if e.summarizing {
+		renderResults(e.c, "summary_end", summaryEnd{})
+	}
+	renderResults(e.c, "error", err.Message)
+}
+
+// openSummary starts the summary section the tokens are written into
+func (e *htmlEmitter) openSummary() {
+	if !e.summarizing {
+		e.summarizing = true
+		renderResults(e.c, "summary", nil)
+	}
+}
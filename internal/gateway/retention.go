@@ -0,0 +1,282 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/objectstore"
+)
+
+// sweep drops the data older than its retention period from every store
+// that keeps it, returning how many entries it dropped per kind of data. It
+// is the retention maintenance job, run every retention.janitor_interval;
+// the kinds of data it failed to drop are retried at the next run.
+func (g *Gateway) sweep(ctx context.Context) (map[string]int, error) {
+	retention := g.config.Gateway.Retention
+	now := time.Now()
+	removed := map[string]int{}
+	var failed []string
+	expire := func(data string, period time.Duration, drop func(ctx context.Context, before time.Time) (int, error)) {
+		if period <= 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		n, err := drop(ctx, now.Add(-period))
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to remove %s older than %s: %v", data, period, err)
+			failed = append(failed, data)
+		}
+		if n > 0 {
+			removed[data] += n
+			monitoring.RecordRetentionRemoved(data, n)
+		}
+	}
+
+	if g.login != nil {
+		expire("history", retention.History, g.login.history.Expire)
+	}
+	if g.history != nil {
+		expire("suggestions", retention.History, g.history.Expire)
+	}
+	if g.access != nil {
+		expire("audit_log", retention.AuditLog, g.access.audit.Expire)
+	}
+	if g.prompts != nil {
+		expire("prompts", g.prompts.retention, g.prompts.store.Expire)
+	}
+	if g.responses != nil {
+		expire("caches", retention.Caches, func(ctx context.Context, before time.Time) (int, error) {
+			return g.responses.expire(before), nil
+		})
+	}
+	if files, ok := g.objects.(*objectstore.Filesystem); ok {
+		expire("exports", g.config.Gateway.Exports.TTL, files.Expire)
+	}
+	if g.outbox != nil {
+		expire("events", g.config.Events.Retention, g.db.Outbox.Purge)
+	}
+	if g.db != nil {
+		// Usage windows and scheduler claims go once they expire
+		if n, err := g.db.Counters.Prune(ctx); err != nil {
+			logger.GetLogger().Errorf("Failed to prune expired counters: %v", err)
+			failed = append(failed, "counters")
+		} else if n > 0 {
+			removed["counters"] += n
+			monitoring.RecordRetentionRemoved("counters", n)
+		}
+	}
+	if len(removed) > 0 {
+		logger.GetLogger().Infof("Retention janitor removed %v", removed)
+	}
+	if len(failed) > 0 {
+		return removed, fmt.Errorf("failed to remove expired %s", strings.Join(failed, ", "))
+	}
+	return removed, nil
+}
+
+// dataSubject is whom the data a request leaves behind belongs to: the
+// logged-in user, or "key:<id>" for the tenant API key it was made with;
+// "" for anonymous requests
+func (g *Gateway) dataSubject(c *gin.Context) string {
+	if session := g.user(c); session != nil {
+		return session.Subject
+	}
+	if hash := c.GetString(apiKeyContextKey); hash != "" {
+		for _, key := range g.tenant(c).Keys {
+			if key.Hash == hash {
+				return "key:" + key.ID
+			}
+		}
+	}
+	return ""
+}
+
+// DeleteUserData erases everything the gateway keeps about one of the
+// tenant's users (DELETE /api/v1/users/:id/data): their search history,
+// exportable and shared searches, saved queries with their runs, prompt
+// records, partial summaries and the responses kept for Idempotency-Key
+// retries. The response lists what is retained, and why. Callers may erase
+// their own data: a logged-in user theirs, a tenant API key what was left
+// with it ("key:<id>"); "me" names the caller. Admins (the admin role or
+// tenancy.admin_token) may erase any of the tenant's users'.
+func (g *Gateway) DeleteUserData(c *gin.Context) {
+	caller := g.dataSubject(c)
+	user := c.Param("id")
+	if user == "me" {
+		user = caller
+	}
+	if p := g.principal(c); p == nil || !p.Role.Allows(auth.RoleAdmin) {
+		switch {
+		case caller == "":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Log in or use an API key of the tenant"})
+			return
+		case caller != user:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins may erase the data of others"})
+			return
+		}
+	} else if user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admins name the user to erase"})
+		return
+	}
+
+	tenant := g.tenant(c).ID
+	erased, err := g.eraseUser(c.Request.Context(), tenant, user)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to erase the data of %s in tenant %s: %v", user, tenant, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase all data; retry to erase the rest", "erased": erased})
+		return
+	}
+	logger.GetLogger().Infof("Erased the data of %s in tenant %s: %v", user, tenant, erased)
+	c.JSON(http.StatusOK, gin.H{"user": user, "tenant": tenant, "erased": erased, "retained": g.retainedUserData()})
+}
+
+// eraseUser deletes a user's data from every store that keeps it, stopping
+// at the first failure, and returns how many entries it deleted per store.
+// Erasing is idempotent, so a failed erasure is completed by retrying it.
+func (g *Gateway) eraseUser(ctx context.Context, tenant, user string) (map[string]int, error) {
+	erased := map[string]int{}
+	erase := func(data string, drop func() (int, error)) error {
+		n, err := drop()
+		erased[data] += n
+		monitoring.RecordUserDataErased(data, n)
+		return err
+	}
+
+	if g.login != nil {
+		if err := erase("history", func() (int, error) { return g.login.history.Delete(ctx, tenant+":"+user) }); err != nil {
+			return erased, err
+		}
+	}
+	if g.tasks != nil {
+		if err := erase("exports", func() (int, error) { return g.tasks.EraseUser(ctx, tenant, user) }); err != nil {
+			return erased, err
+		}
+	}
+	if g.sharing != nil {
+		if err := erase("shares", func() (int, error) { return g.sharing.store.EraseUser(ctx, tenant, user) }); err != nil {
+			return erased, err
+		}
+	}
+	if g.scheduler != nil {
+		if err := erase("saved_queries", func() (int, error) { return g.scheduler.eraseUser(ctx, tenant, user) }); err != nil {
+			return erased, err
+		}
+	}
+	if g.batches != nil {
+		if err := erase("batches", func() (int, error) { return g.batches.store.EraseUser(ctx, tenant, user) }); err != nil {
+			return erased, err
+		}
+	}
+	if g.prompts != nil {
+		if err := erase("prompts", func() (int, error) { return g.prompts.store.EraseUser(ctx, tenant, user) }); err != nil {
+			return erased, err
+		}
+	}
+	if g.partials != nil {
+		if err := erase("partials", func() (int, error) { return g.partials.EraseUser(ctx, tenant, user) }); err != nil {
+			return erased, err
+		}
+	}
+	if g.idempotency != nil {
+		if err := erase("idempotent_responses", func() (int, error) { return g.idempotency.cache.EraseUser(ctx, tenant, user) }); err != nil {
+			return erased, err
+		}
+	}
+	return erased, nil
+}
+
+// retainedUserData describes the data an erasure leaves in place, by store,
+// and why: it is not tied to users, or expires on its own shortly
+func (g *Gateway) retainedUserData() map[string]string {
+	retained := map[string]string{}
+	if g.eventBuffer != nil {
+		retained["stream_events"] = fmt.Sprintf("kept by stream for resumption, expires after %s", g.config.Gateway.StreamBuffer.TTL)
+	}
+	if g.responses != nil {
+		retained["response_cache"] = "shared by the tenant's users, not tied to one" + keptFor(g.config.Gateway.Retention.Caches)
+	}
+	if g.outbox != nil {
+		retained["events"] = "analytics events name no user" + keptFor(g.config.Events.Retention)
+	}
+	if g.history != nil {
+		retained["suggestions"] = "counts of queries the tenant's users searched, not tied to users"
+	}
+	if g.access != nil {
+		retained["audit_log"] = "kept as the record of admin actions"
+	}
+	return retained
+}
+
+// keptFor describes a retention period
+func keptFor(period time.Duration) string {
+	if period <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(", removed after %s", period)
+}
+
+// scanRedis calls each with every key matching pattern and returns the sum
+// of what it returned
+func scanRedis(ctx context.Context, client *redis.Client, pattern string, each func(key string) (int, error)) (int, error) {
+	total := 0
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := each(iter.Val())
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, iter.Err()
+}
+
+// eraseRedis deletes the keys matching pattern whose values match
+func eraseRedis(ctx context.Context, client *redis.Client, pattern string, match func(data []byte) (bool, error)) (int, error) {
+	return scanRedis(ctx, client, pattern, func(key string) (int, error) {
+		data, err := client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if ok, err := match(data); err != nil || !ok {
+			return 0, err
+		}
+		return 1, client.Del(ctx, key).Err()
+	})
+}
+
+// expireRedisList drops the expired entries at the tail of a list kept
+// newest first. Entries pushed meanwhile land at the head and are kept.
+func expireRedisList(ctx context.Context, client *redis.Client, key string, expired func(data []byte) (bool, error)) (int, error) {
+	values, err := client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := len(values) - 1; i >= 0; i-- {
+		ok, err := expired([]byte(values[i]))
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, client.LTrim(ctx, key, 0, int64(-n-1)).Err()
+}
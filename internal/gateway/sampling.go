@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/config"
+	pb "ai-search-service/proto"
+)
+
+// samplingKey holds the validated sampling parameters of a request's body
+const samplingKey = "search_sampling"
+
+// SamplingParams tune how the summary is generated; unset ones keep the
+// model's defaults
+type SamplingParams struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"` // fixes the random sampling
+}
+
+// validate checks the parameters against the configured ranges, returning
+// an error for each one outside them
+func (p *SamplingParams) validate(cfg config.SamplingConfig) []FieldError {
+	var errs []FieldError
+	fail := func(name, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: "sampling." + name, In: "body", Message: fmt.Sprintf(format, args...)})
+	}
+	for _, param := range []struct {
+		name    string
+		value   *float32
+		allowed config.RangeConfig
+	}{
+		{"temperature", p.Temperature, cfg.Temperature},
+		{"top_p", p.TopP, cfg.TopP},
+		{"frequency_penalty", p.FrequencyPenalty, cfg.FrequencyPenalty},
+		{"presence_penalty", p.PresencePenalty, cfg.PresencePenalty},
+	} {
+		if param.value == nil {
+			continue
+		}
+		if value := float64(*param.value); value < param.allowed.Min || value > param.allowed.Max {
+			fail(param.name, "must be between %g and %g", param.allowed.Min, param.allowed.Max)
+		}
+	}
+	if len(p.Stop) > cfg.MaxStop {
+		fail("stop", "allows at most %d sequences", cfg.MaxStop)
+	}
+	for i, stop := range p.Stop {
+		if stop == "" || len(stop) > cfg.MaxStopLength {
+			fail(fmt.Sprintf("stop[%d]", i), "must be 1 to %d bytes", cfg.MaxStopLength)
+		}
+	}
+	return errs
+}
+
+// proto converts the parameters for the LLM request
+func (p *SamplingParams) proto() *pb.SamplingParams {
+	return &pb.SamplingParams{
+		Temperature:      p.Temperature,
+		TopP:             p.TopP,
+		FrequencyPenalty: p.FrequencyPenalty,
+		PresencePenalty:  p.PresencePenalty,
+		Stop:             p.Stop,
+		Seed:             p.Seed,
+	}
+}
+
+// deterministic returns the parameters with greedy sampling (temperature 0,
+// top_p 1) and a fixed seed, the request's own or else seed, so the same
+// results always yield the same summary. p may be nil.
+func (p *SamplingParams) deterministic(seed int64) *SamplingParams {
+	fixed := SamplingParams{}
+	if p != nil {
+		fixed = *p
+	}
+	temperature, topP := float32(0), float32(1)
+	fixed.Temperature, fixed.TopP = &temperature, &topP
+	if fixed.Seed == nil {
+		fixed.Seed = &seed
+	}
+	return &fixed
+}
+
+// fingerprint identifies the parameters within a coalescing key
+func (p *SamplingParams) fingerprint() string {
+	value := func(v *float32) string {
+		if v == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%g", *v)
+	}
+	seed := "-"
+	if p.Seed != nil {
+		seed = fmt.Sprint(*p.Seed)
+	}
+	return fmt.Sprintf("%s,%s,%s,%s,%s,%q", value(p.Temperature), value(p.TopP),
+		value(p.FrequencyPenalty), value(p.PresencePenalty), seed, strings.Join(p.Stop, "\x00"))
+}
+
+// requestSampling returns the sampling parameters of the request's body,
+// nil when it set none
+func requestSampling(c *gin.Context) *SamplingParams {
+	if sampling, ok := c.Get(samplingKey); ok {
+		return sampling.(*SamplingParams)
+	}
+	return nil
+}
+
+// coalesceVariant is what, besides the query, must match for requests to
+// share a pipeline run: the locale searched in, the sampling parameters and
+// the safety policy, which differs for API keys with their own
+func (g *Gateway) coalesceVariant(c *gin.Context) string {
+	variant := g.searchLocale(c)
+	if sampling := requestSampling(c); sampling != nil {
+		variant += "|" + sampling.fingerprint()
+	}
+	if policy := g.safetyPolicy(c); policy != nil && policy.Id != g.tenant(c).ID {
+		variant += "|" + policy.Id
+	}
+	return variant
+}
@@ -0,0 +1,794 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/cron"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/notify"
+	"ai-search-service/internal/pipeline"
+	"ai-search-service/internal/store"
+	pb "ai-search-service/proto"
+)
+
+// SavedQuery is a search that the scheduler re-runs on its schedule,
+// notifying Webhook, Email and the digest channels when the summary changes
+// substantively
+type SavedQuery struct {
+	ID         string    `json:"id"`
+	Tenant     string    `json:"tenant"`          // runs under this tenant's policies
+	Owner      string    `json:"owner,omitempty"` // the logged-in user who saved it, or key:<id> of the API key
+	Query      string    `json:"query" binding:"required"`
+	Schedule   string    `json:"schedule" binding:"required"` // cron expression, @daily, "@every 6h", ...
+	SafeSearch bool      `json:"safe_search"`
+	NumResults int       `json:"num_results"`
+	Webhook    string    `json:"webhook,omitempty"` // receives a JSON ChangeNotification
+	Email      []string  `json:"email,omitempty"`
+	FeedToken  string    `json:"feed_token,omitempty"` // authorizes GET /saved-queries/:id/feed
+	CreatedAt  time.Time `json:"created_at"`
+	NextRun    time.Time `json:"next_run"`
+	LastRun    time.Time `json:"last_run"`
+}
+
+// QueryRun is one execution of a saved query
+type QueryRun struct {
+	ID         string         `json:"id"`
+	QueryID    string         `json:"query_id"`
+	StartedAt  time.Time      `json:"started_at"`
+	Duration   float64        `json:"duration_seconds"`
+	Results    []SearchResult `json:"results,omitempty"`
+	Summary    string         `json:"summary,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Change     float64        `json:"change"`                // share of summary words that differ from the previous run
+	NewSources []string       `json:"new_sources,omitempty"` // URLs the previous run did not cite
+	Changed    bool           `json:"changed"`               // substantive change from the previous run
+	Baseline   bool           `json:"baseline,omitempty"`    // first successful run, which later runs are compared against
+	Notified   bool           `json:"notified,omitempty"`
+	NotifyErr  string         `json:"notify_error,omitempty"`
+}
+
+// ChangeNotification is posted to a saved query's webhook
+type ChangeNotification struct {
+	QueryID         string         `json:"query_id"`
+	Query           string         `json:"query"`
+	RunID           string         `json:"run_id"`
+	Summary         string         `json:"summary"`
+	PreviousSummary string         `json:"previous_summary"`
+	Change          float64        `json:"change"`
+	NewSources      []string       `json:"new_sources,omitempty"`
+	Results         []SearchResult `json:"results"`
+	RanAt           time.Time      `json:"ran_at"`
+}
+
+// QueryStore persists saved queries and their recent runs
+type QueryStore interface {
+	SaveQuery(ctx context.Context, query *SavedQuery) error
+	// GetQuery returns found=false for unknown IDs
+	GetQuery(ctx context.Context, id string) (query *SavedQuery, found bool, err error)
+	ListQueries(ctx context.Context) ([]*SavedQuery, error)
+	DeleteQuery(ctx context.Context, id string) error
+	// AddRun records a run, keeping the newest keep runs of its query
+	AddRun(ctx context.Context, run *QueryRun, keep int) error
+	// Runs returns a query's runs, newest first
+	Runs(ctx context.Context, queryID string) ([]*QueryRun, error)
+	// Claim reserves the due run of a query for ttl so that only one gateway
+	// replica executes it
+	Claim(ctx context.Context, queryID string, ttl time.Duration) (bool, error)
+}
+
+// newQueryStore returns the configured store, or nil when the scheduler is disabled
+func newQueryStore(cfg *config.Config, client *redis.Client, db *store.Store) QueryStore {
+	if !cfg.Gateway.Scheduler.Enabled {
+		return nil
+	}
+	if db.Keeps(store.SavedQueries) {
+		return &durableQueryStore{records: db.Records, events: db.Events, counters: db.Counters}
+	}
+	if client != nil {
+		return &redisQueryStore{client: client}
+	}
+	return &memoryQueryStore{queries: make(map[string]*SavedQuery), runs: make(map[string][]*QueryRun)}
+}
+
+const redisQueriesKey = "scheduler:queries"
+
+func redisRunsKey(queryID string) string {
+	return "scheduler:runs:" + queryID
+}
+
+type redisQueryStore struct {
+	client *redis.Client
+}
+
+func (s *redisQueryStore) SaveQuery(ctx context.Context, query *SavedQuery) error {
+	data, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, redisQueriesKey, query.ID, data).Err()
+}
+
+func (s *redisQueryStore) GetQuery(ctx context.Context, id string) (*SavedQuery, bool, error) {
+	data, err := s.client.HGet(ctx, redisQueriesKey, id).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var query SavedQuery
+	if err := json.Unmarshal(data, &query); err != nil {
+		return nil, false, fmt.Errorf("invalid saved query: %w", err)
+	}
+	return &query, true, nil
+}
+
+func (s *redisQueryStore) ListQueries(ctx context.Context) ([]*SavedQuery, error) {
+	all, err := s.client.HGetAll(ctx, redisQueriesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]*SavedQuery, 0, len(all))
+	for id, data := range all {
+		var query SavedQuery
+		if err := json.Unmarshal([]byte(data), &query); err != nil {
+			logger.GetLogger().Warnf("Skipping invalid saved query %s: %v", id, err)
+			continue
+		}
+		queries = append(queries, &query)
+	}
+	sortQueries(queries)
+	return queries, nil
+}
+
+func (s *redisQueryStore) DeleteQuery(ctx context.Context, id string) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, redisQueriesKey, id)
+		pipe.Del(ctx, redisRunsKey(id))
+		return nil
+	})
+	return err
+}
+
+func (s *redisQueryStore) AddRun(ctx context.Context, run *QueryRun, keep int) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, redisRunsKey(run.QueryID), data)
+		pipe.LTrim(ctx, redisRunsKey(run.QueryID), 0, int64(keep-1))
+		return nil
+	})
+	return err
+}
+
+func (s *redisQueryStore) Runs(ctx context.Context, queryID string) ([]*QueryRun, error) {
+	items, err := s.client.LRange(ctx, redisRunsKey(queryID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*QueryRun, 0, len(items))
+	for _, item := range items {
+		var run QueryRun
+		if err := json.Unmarshal([]byte(item), &run); err != nil {
+			return nil, fmt.Errorf("invalid query run: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+	return runs, nil
+}
+
+func (s *redisQueryStore) Claim(ctx context.Context, queryID string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, "scheduler:claim:"+queryID, 1, ttl).Result()
+}
+
+type memoryQueryStore struct {
+	mu      sync.Mutex
+	queries map[string]*SavedQuery
+	runs    map[string][]*QueryRun
+}
+
+func (s *memoryQueryStore) SaveQuery(ctx context.Context, query *SavedQuery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := *query
+	s.queries[query.ID] = &saved
+	return nil
+}
+
+func (s *memoryQueryStore) GetQuery(ctx context.Context, id string) (*SavedQuery, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query, ok := s.queries[id]
+	if !ok {
+		return nil, false, nil
+	}
+	copied := *query
+	return &copied, true, nil
+}
+
+func (s *memoryQueryStore) ListQueries(ctx context.Context) ([]*SavedQuery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries := make([]*SavedQuery, 0, len(s.queries))
+	for _, query := range s.queries {
+		copied := *query
+		queries = append(queries, &copied)
+	}
+	sortQueries(queries)
+	return queries, nil
+}
+
+func (s *memoryQueryStore) DeleteQuery(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queries, id)
+	delete(s.runs, id)
+	return nil
+}
+
+func (s *memoryQueryStore) AddRun(ctx context.Context, run *QueryRun, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := append([]*QueryRun{run}, s.runs[run.QueryID]...)
+	if keep > 0 && len(runs) > keep {
+		runs = runs[:keep]
+	}
+	s.runs[run.QueryID] = runs
+	return nil
+}
+
+func (s *memoryQueryStore) Runs(ctx context.Context, queryID string) ([]*QueryRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*QueryRun(nil), s.runs[queryID]...), nil
+}
+
+// Claim always succeeds: a memory store is never shared between replicas
+func (s *memoryQueryStore) Claim(ctx context.Context, queryID string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func sortQueries(queries []*SavedQuery) {
+	sort.Slice(queries, func(i, j int) bool { return queries[i].CreatedAt.Before(queries[j].CreatedAt) })
+}
+
+// scheduler runs saved queries through the pipeline when they are due
+type scheduler struct {
+	g      *Gateway
+	config config.SchedulerConfig
+	store  QueryStore
+	slots  chan struct{} // limits concurrent runs
+}
+
+// newScheduler returns nil when the scheduler is disabled
+func newScheduler(g *Gateway, store QueryStore) *scheduler {
+	if store == nil {
+		return nil
+	}
+	cfg := g.config.Gateway.Scheduler
+	concurrent := cfg.MaxConcurrent
+	if concurrent <= 0 {
+		concurrent = 1
+	}
+	return &scheduler{
+		g:      g,
+		config: cfg,
+		store:  store,
+		slots:  make(chan struct{}, concurrent),
+	}
+}
+
+// loop starts the due queries every tick until ctx is done
+func (s *scheduler) loop(ctx context.Context) {
+	interval := s.config.TickInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tick(ctx)
+		}
+	}
+}
+
+func (s *scheduler) tick(ctx context.Context) {
+	log := logger.GetLogger()
+	queries, err := s.store.ListQueries(ctx)
+	if err != nil {
+		log.Errorf("Failed to list saved queries: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, query := range queries {
+		if now.Before(query.NextRun) {
+			continue
+		}
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			return // all slots busy; the rest stay due for the next tick
+		}
+
+		claimed, err := s.store.Claim(ctx, query.ID, s.claimTTL())
+		if err != nil || !claimed {
+			if err != nil {
+				log.Errorf("Failed to claim saved query %s: %v", query.ID, err)
+			}
+			<-s.slots
+			continue
+		}
+		sched, err := cron.Parse(query.Schedule)
+		if err != nil {
+			log.Errorf("Saved query %s has an invalid schedule: %v", query.ID, err)
+			<-s.slots
+			continue
+		}
+		query.NextRun = sched.Next(now)
+		if err := s.store.SaveQuery(ctx, query); err != nil {
+			log.Errorf("Failed to reschedule saved query %s: %v", query.ID, err)
+		}
+
+		go func(query *SavedQuery) {
+			defer func() { <-s.slots }()
+			s.run(context.Background(), query)
+		}(query)
+	}
+}
+
+// claimTTL covers one run end to end, so a replica that claimed a run and
+// died does not block the query for longer than that
+func (s *scheduler) claimTTL() time.Duration {
+	services := s.g.config.Services
+	return services.Safety.Timeout*2 + services.Search.Timeout + services.LLM.Timeout + time.Minute
+}
+
+// run executes the query, compares the outcome with the previous successful
+// run and notifies subscribers of a substantive change
+func (s *scheduler) run(ctx context.Context, query *SavedQuery) *QueryRun {
+	log := logger.GetLogger()
+	start := time.Now()
+	run := &QueryRun{ID: fmt.Sprintf("run_%d", start.UnixNano()), QueryID: query.ID, StartedAt: start}
+
+	if tenant, err := s.tenantOf(ctx, query); err != nil {
+		run.Error = err.Error()
+	} else {
+		emit := &collectEmitter{}
+		s.g.pipeline.Run(s.pipelineRequest(tenant, query, run.ID), emit)
+		run.Results, run.Summary = emit.results, emit.summary
+		if emit.err != nil {
+			run.Error = emit.err.Message
+		}
+	}
+	run.Duration = time.Since(start).Seconds()
+
+	previous, err := s.lastSuccessful(ctx, query.ID)
+	if err != nil {
+		log.Warnf("Failed to load previous runs of %s: %v", query.ID, err)
+	}
+	if run.Error == "" && previous != nil {
+		run.Change = summaryChange(previous.Summary, run.Summary)
+		run.NewSources = newSources(previous.Results, run.Results)
+		run.Changed = run.Change >= s.config.ChangeThreshold ||
+			(s.config.MinNewSources > 0 && len(run.NewSources) >= s.config.MinNewSources)
+	}
+	run.Baseline = run.Error == "" && previous == nil && err == nil
+	if run.Changed {
+		if err := s.notify(ctx, query, previous, run); err != nil {
+			run.NotifyErr = err.Error()
+			log.Warnf("Failed to notify subscribers of %s: %v", query.ID, err)
+		} else {
+			run.Notified = true
+		}
+	}
+
+	if err := s.store.AddRun(ctx, run, s.config.RunHistory); err != nil {
+		log.Errorf("Failed to store run of saved query %s: %v", query.ID, err)
+	}
+	// Reload so a schedule change made during the run is kept
+	if current, found, err := s.store.GetQuery(ctx, query.ID); err == nil && found {
+		current.LastRun = start
+		if err := s.store.SaveQuery(ctx, current); err != nil {
+			log.Warnf("Failed to update saved query %s: %v", query.ID, err)
+		}
+	}
+	log.Infof("Saved query %s ran in %.1fs (change %.2f, %d new sources, changed %v)", query.ID, run.Duration, run.Change, len(run.NewSources), run.Changed)
+	return run
+}
+
+// tenantID is the tenant owning the query; queries saved before
+// multi-tenancy belong to the default tenant
+func (q *SavedQuery) tenantID() string {
+	if q.Tenant == "" {
+		return defaultTenantID
+	}
+	return q.Tenant
+}
+
+// tenantOf loads the tenant a saved query runs as
+func (s *scheduler) tenantOf(ctx context.Context, query *SavedQuery) (*Tenant, error) {
+	id := query.tenantID()
+	if s.g.tenants == nil {
+		return &Tenant{ID: id}, nil
+	}
+	tenant, found, err := s.g.tenants.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenant %s: %w", id, err)
+	}
+	if !found {
+		if id == defaultTenantID {
+			return &Tenant{ID: id}, nil
+		}
+		return nil, fmt.Errorf("tenant %s no longer exists", id)
+	}
+	return tenant, nil
+}
+
+// pipelineRequest describes a run of a saved query; its prompt record is
+// kept under the run's ID
+func (s *scheduler) pipelineRequest(tenant *Tenant, query *SavedQuery, runID string) *pipeline.Request {
+	numResults := query.NumResults
+	if numResults <= 0 {
+		numResults = tenant.defaultNumResults()
+	}
+	req := &pipeline.Request{
+		Query:      query.Query,
+		SafeSearch: query.SafeSearch,
+		NumResults: numResults,
+		ClientIP:   "scheduler",
+		Mode:       "scheduled",
+		MaxTokens:  150,
+		Snippets:   s.g.snippets,
+		StageContext: func(stage pipeline.StageName) (context.Context, context.CancelFunc) {
+			if timeout := s.g.stageTimeout(stage); timeout > 0 {
+				return context.WithTimeout(context.Background(), timeout)
+			}
+			return context.WithCancel(context.Background())
+		},
+	}
+	s.g.applyReranking(req, flags.Subject{Tenant: tenant.ID})
+	req.CompressWords = s.g.compressWords()
+	if prompts := s.g.prompts; prompts != nil {
+		req.Audit = func(llmReq *pb.LLMRequest, generated, served string, err error) {
+			prompts.record(s.g.promptRecord(runID, tenant.ID, query.Owner, req.Mode, query.Query, llmReq, generated, served, err))
+		}
+	}
+	tenant.apply(req)
+	return req
+}
+
+func (s *scheduler) lastSuccessful(ctx context.Context, queryID string) (*QueryRun, error) {
+	runs, err := s.store.Runs(ctx, queryID)
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		if run.Error == "" {
+			return run, nil
+		}
+	}
+	return nil, nil
+}
+
+// collectEmitter keeps the outcome of a pipeline run. A provider's data
+// that replaced the summary is compared between runs in its place.
+type collectEmitter struct {
+	results    []SearchResult
+	structured *answers.Data
+	summary    string
+	err        *pipeline.Error
+}
+
+func (e *collectEmitter) Started(query string)              {}
+func (e *collectEmitter) Stage(stage pipeline.StageName)    {}
+func (e *collectEmitter) Results(results []pipeline.Result) { e.results = results }
+func (e *collectEmitter) Structured(data *answers.Data)     { e.structured = data }
+func (e *collectEmitter) Fail(err *pipeline.Error)          { e.err = err }
+
+func (e *collectEmitter) KnowledgePanel(panel *pipeline.KnowledgePanel) {}
+
+func (e *collectEmitter) Summary(summary pipeline.Summary) {
+	e.summary = taskSummary(summary.Text, e.structured)
+}
+
+// summaryChange is the share of distinct words in either summary that are
+// not in both (Jaccard distance), ignoring case and punctuation
+func summaryChange(previous, current string) float64 {
+	a, b := wordSet(previous), wordSet(current)
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return 1 - float64(shared)/float64(len(a)+len(b)-shared)
+}
+
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if word = strings.Trim(word, ".,;:!?\"'()[]"); word != "" {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// newSources lists the URLs in current that previous did not cite
+func newSources(previous, current []SearchResult) []string {
+	seen := make(map[string]bool, len(previous))
+	for _, result := range previous {
+		seen[strings.TrimSuffix(result.URL, "/")] = true
+	}
+	var added []string
+	for _, result := range current {
+		if key := strings.TrimSuffix(result.URL, "/"); key != "" && !seen[key] {
+			seen[key] = true
+			added = append(added, result.URL)
+		}
+	}
+	return added
+}
+
+// notify delivers the change to the query's webhook and email recipients
+// and to the channels subscribed to digests
+func (s *scheduler) notify(ctx context.Context, query *SavedQuery, previous, run *QueryRun) error {
+	notifier := s.g.notifier
+	msg := notify.Message{
+		Event:    notify.EventDigest,
+		Severity: notify.Info,
+		Title:    fmt.Sprintf("Update for %q", query.Query),
+		Text:     run.Summary,
+		Key:      query.ID,
+		Payload: ChangeNotification{
+			QueryID:         query.ID,
+			Query:           query.Query,
+			RunID:           run.ID,
+			Summary:         run.Summary,
+			PreviousSummary: previous.Summary,
+			Change:          run.Change,
+			NewSources:      run.NewSources,
+			Results:         run.Results,
+			RanAt:           run.StartedAt,
+		},
+	}
+	for _, source := range run.NewSources {
+		msg.Fields = append(msg.Fields, notify.Field{Name: "New source", Value: source})
+	}
+
+	var errs []error
+	if query.Webhook != "" {
+		if err := notifier.Webhook(query.Webhook).Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if len(query.Email) > 0 {
+		if err := notifier.Email(query.Email).Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	if err := notifier.Notify(ctx, msg); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// CreateSavedQuery registers a query to re-run on its schedule. The first
+// run, which later runs are compared against, happens on the next tick.
+func (g *Gateway) CreateSavedQuery(c *gin.Context) {
+	if g.scheduler == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled queries are disabled"})
+		return
+	}
+	var query SavedQuery
+	if !bindJSON(c, &query) || g.queryTooLong(c, query.Query, false) {
+		return
+	}
+	if query.NumResults > 0 {
+		query.NumResults = g.clampNumResults(c, query.NumResults)
+	}
+	if err := g.scheduler.validate(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := g.tenantQueries(c)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to list saved queries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save query"})
+		return
+	}
+	if max := g.config.Gateway.Scheduler.MaxQueries; max > 0 && len(existing) >= max {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("At most %d saved queries are allowed", max)})
+		return
+	}
+
+	now := time.Now()
+	query.ID = fmt.Sprintf("sq_%d", now.UnixNano())
+	query.Tenant = g.tenant(c).ID
+	query.Owner = g.dataSubject(c)
+	query.FeedToken = newFeedToken()
+	query.CreatedAt, query.NextRun, query.LastRun = now, now, time.Time{}
+	if err := g.scheduler.store.SaveQuery(ctx, &query); err != nil {
+		logger.GetLogger().Errorf("Failed to save query: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save query"})
+		return
+	}
+	c.JSON(http.StatusCreated, query)
+}
+
+// validate checks the schedule, its frequency and the notification targets
+func (s *scheduler) validate(query *SavedQuery) error {
+	sched, err := cron.Parse(query.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	first := sched.Next(time.Now())
+	if first.IsZero() {
+		return fmt.Errorf("schedule %q never runs", query.Schedule)
+	}
+	if gap := sched.Next(first).Sub(first); gap < s.config.MinInterval {
+		return fmt.Errorf("schedule runs every %s; the minimum is %s", gap, s.config.MinInterval)
+	}
+	if query.Webhook != "" {
+		u, err := url.Parse(query.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook must be an http(s) URL")
+		}
+	}
+	if len(query.Email) > 0 && s.g.config.Notifications.SMTP.Host == "" {
+		return fmt.Errorf("email needs notifications.smtp.host to be configured")
+	}
+	for _, address := range query.Email {
+		if !strings.Contains(address, "@") || strings.ContainsAny(address, "\r\n") {
+			return fmt.Errorf("invalid email address %q", address)
+		}
+	}
+	return nil
+}
+
+// ListSavedQueries returns the tenant's saved queries
+func (g *Gateway) ListSavedQueries(c *gin.Context) {
+	if g.scheduler == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled queries are disabled"})
+		return
+	}
+	queries, err := g.tenantQueries(c)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to list saved queries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list saved queries"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"queries": queries})
+}
+
+// GetSavedQuery returns a saved query
+func (g *Gateway) GetSavedQuery(c *gin.Context) {
+	if query := g.savedQuery(c); query != nil {
+		c.JSON(http.StatusOK, query)
+	}
+}
+
+// DeleteSavedQuery removes a saved query and its runs
+func (g *Gateway) DeleteSavedQuery(c *gin.Context) {
+	query := g.savedQuery(c)
+	if query == nil {
+		return
+	}
+	if err := g.scheduler.store.DeleteQuery(c.Request.Context(), query.ID); err != nil {
+		logger.GetLogger().Errorf("Failed to delete saved query %s: %v", query.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete saved query"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// eraseUser deletes the saved queries a user of the tenant owns, with
+// their runs, returning how many it deleted
+func (s *scheduler) eraseUser(ctx context.Context, tenant, user string) (int, error) {
+	queries, err := s.store.ListQueries(ctx)
+	if err != nil {
+		return 0, err
+	}
+	erased := 0
+	for _, query := range queries {
+		if query.Tenant != tenant || query.Owner != user {
+			continue
+		}
+		if err := s.store.DeleteQuery(ctx, query.ID); err != nil {
+			return erased, err
+		}
+		erased++
+	}
+	return erased, nil
+}
+
+// ListQueryRuns returns the recent runs of a saved query, newest first
+func (g *Gateway) ListQueryRuns(c *gin.Context) {
+	query := g.savedQuery(c)
+	if query == nil {
+		return
+	}
+	runs, err := g.scheduler.store.Runs(c.Request.Context(), query.ID)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load runs of %s: %v", query.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load runs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// RunSavedQuery runs a saved query now, outside its schedule, and returns the run
+func (g *Gateway) RunSavedQuery(c *gin.Context) {
+	query := g.savedQuery(c)
+	if query == nil {
+		return
+	}
+	c.JSON(http.StatusOK, g.scheduler.run(c.Request.Context(), query))
+}
+
+// savedQuery loads the query named by the :id parameter, writing the error
+// response and returning nil if there is none
+func (g *Gateway) savedQuery(c *gin.Context) *SavedQuery {
+	if g.scheduler == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled queries are disabled"})
+		return nil
+	}
+	query, found, err := g.scheduler.store.GetQuery(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load saved query %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load saved query"})
+		return nil
+	}
+	// The feed route runs without a resolved tenant; its token authorizes it
+	if _, resolved := c.Get(tenantContextKey); resolved && found && query.tenantID() != g.tenant(c).ID {
+		found = false
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No saved query with this ID"})
+		return nil
+	}
+	return query
+}
+
+// tenantQueries returns the saved queries of the request's tenant
+func (g *Gateway) tenantQueries(c *gin.Context) ([]*SavedQuery, error) {
+	queries, err := g.scheduler.store.ListQueries(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	tenant := g.tenant(c).ID
+	owned := make([]*SavedQuery, 0, len(queries))
+	for _, query := range queries {
+		if query.tenantID() == tenant {
+			owned = append(owned, query)
+		}
+	}
+	return owned, nil
+}
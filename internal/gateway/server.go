@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+)
+
+// HTTPServer is the gateway's HTTP or HTTPS server. When serving HTTPS it
+// may also listen for plain HTTP to redirect it.
+type HTTPServer struct {
+	*http.Server
+	tls               bool
+	certFile, keyFile string       // empty with autocert
+	redirect          *http.Server // nil unless serving HTTPS with server.http_redirect_port
+}
+
+// NewHTTPServer builds the gateway's server with the timeouts of
+// gateway.limits and gateway.server. It serves HTTPS with tls_cert_file or
+// a certificate from autocert. HTTP/2 is negotiated with ALPN when serving
+// HTTPS and, with server.h2c, spoken in cleartext to internal clients.
+func NewHTTPServer(cfg *config.Config, addr string, handler http.Handler) (*HTTPServer, error) {
+	serverCfg := cfg.Gateway.Server
+	h2 := &http2.Server{MaxConcurrentStreams: serverCfg.MaxConcurrentStreams, IdleTimeout: serverCfg.IdleTimeout}
+	if serverCfg.H2C {
+		handler = h2c.NewHandler(handler, h2)
+	}
+	s := &HTTPServer{Server: &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Gateway.Limits.ReadHeaderTimeout,
+		WriteTimeout:      serverCfg.WriteTimeout,
+		IdleTimeout:       serverCfg.IdleTimeout,
+	}}
+
+	var challenges func(http.Handler) http.Handler
+	switch {
+	case serverCfg.Autocert.Enabled:
+		manager, err := newCertManager(serverCfg.Autocert)
+		if err != nil {
+			return nil, err
+		}
+		s.TLSConfig = manager.TLSConfig()
+		s.tls, challenges = true, manager.HTTPHandler
+	case serverCfg.TLSCertFile != "":
+		if serverCfg.TLSKeyFile == "" {
+			return nil, errors.New("server.tls_cert_file requires server.tls_key_file")
+		}
+		s.tls, s.certFile, s.keyFile = true, serverCfg.TLSCertFile, serverCfg.TLSKeyFile
+	}
+
+	if serverCfg.HTTP2 {
+		if err := http2.ConfigureServer(s.Server, h2); err != nil {
+			return nil, err
+		}
+	} else {
+		// A non-nil, empty map turns off the built-in HTTP/2 over TLS
+		s.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		if s.TLSConfig != nil {
+			s.TLSConfig.NextProtos = withoutProto(s.TLSConfig.NextProtos, "h2")
+		}
+	}
+
+	if s.tls && serverCfg.HTTPRedirectPort > 0 {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server address %q: %w", addr, err)
+		}
+		var redirect http.Handler = httpsRedirect(port)
+		if challenges != nil {
+			redirect = challenges(redirect)
+		}
+		s.redirect = &http.Server{
+			Addr:              fmt.Sprintf(":%d", serverCfg.HTTPRedirectPort),
+			Handler:           redirect,
+			ReadHeaderTimeout: cfg.Gateway.Limits.ReadHeaderTimeout,
+			WriteTimeout:      serverCfg.WriteTimeout,
+			IdleTimeout:       serverCfg.IdleTimeout,
+		}
+	}
+	return s, nil
+}
+
+// TLS reports whether the server serves HTTPS
+func (s *HTTPServer) TLS() bool {
+	return s.tls
+}
+
+// ListenAndServe serves until Shutdown, over HTTPS when configured
+func (s *HTTPServer) ListenAndServe() error {
+	if s.redirect != nil {
+		go func() {
+			if err := s.redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.GetLogger().Errorf("HTTP redirect server failed: %v", err)
+			}
+		}()
+	}
+	if !s.tls {
+		return s.Server.ListenAndServe()
+	}
+	return s.Server.ListenAndServeTLS(s.certFile, s.keyFile)
+}
+
+// Shutdown gracefully stops the server and the redirect listener
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	if s.redirect != nil {
+		s.redirect.Shutdown(ctx)
+	}
+	return s.Server.Shutdown(ctx)
+}
+
+// newCertManager obtains certificates for the configured domains only, so
+// arbitrary Host headers cannot trigger issuance
+func newCertManager(cfg config.AutocertConfig) (*autocert.Manager, error) {
+	if len(cfg.Domains) == 0 {
+		return nil, errors.New("server.autocert requires at least one domain")
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.CacheDir != "" {
+		manager.Cache = autocert.DirCache(cfg.CacheDir)
+	}
+	if cfg.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+	return manager, nil
+}
+
+// httpsRedirect permanently redirects requests to the same URL over HTTPS on
+// port, keeping the method and body (308)
+func httpsRedirect(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" && port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func withoutProto(protos []string, proto string) []string {
+	var kept []string
+	for _, p := range protos {
+		if p != proto {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// streamTimeout replaces the server's write timeout on event stream
+// requests with server.stream_write_timeout, so long summaries are not cut
+// off mid-stream
+func (g *Gateway) streamTimeout(c *gin.Context) {
+	if c.Request.Method != http.MethodGet && !strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		c.Next()
+		return
+	}
+	var deadline time.Time
+	if timeout := g.config.Gateway.Server.StreamWriteTimeout; timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.GetLogger().Warnf("Failed to set stream write deadline: %v", err)
+	}
+	c.Next()
+}
+
+// ServeDiagnostics starts pprof and the other diagnostics on
+// gateway.admin_port, returning nil when they are disabled
+func (g *Gateway) ServeDiagnostics() *http.Server {
+	return g.diagnostics.Serve(g.config.Gateway.AdminPort)
+}
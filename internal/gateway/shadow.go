@@ -0,0 +1,166 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// shadowRecord is a mirrored summary next to the primary one, one JSON line
+// of gateway.shadow.output
+type shadowRecord struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Tenant    string    `json:"tenant"`
+	Query     string    `json:"query"`
+
+	Model     string `json:"model,omitempty"`
+	Summary   string `json:"summary"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+
+	ShadowModel     string `json:"shadow_model,omitempty"`
+	ShadowSummary   string `json:"shadow_summary"`
+	ShadowLatencyMs int64  `json:"shadow_latency_ms"`
+	ShadowError     string `json:"shadow_error,omitempty"`
+}
+
+// shadower mirrors a share of live summaries to an alternate LLM backend
+// once the primary summary is done, so the mirror never delays or changes
+// what the user gets
+type shadower struct {
+	config config.ShadowConfig
+	llm    pb.LLMOrchestratorServiceClient
+	slots  chan struct{} // one per running shadow request
+
+	mu     sync.Mutex
+	output *os.File // nil logs the records instead
+}
+
+// newShadower returns nil when shadowing is disabled. Without an address
+// the primary LLM orchestrator is mirrored to.
+func newShadower(cfg *config.Config, primary pb.LLMOrchestratorServiceClient, dialOpts []grpc.DialOption) (*shadower, error) {
+	shadowCfg := cfg.Gateway.Shadow
+	if !shadowCfg.Enabled {
+		return nil, nil
+	}
+	s := &shadower{config: shadowCfg, llm: primary, slots: make(chan struct{}, max(shadowCfg.MaxConcurrent, 1))}
+	if shadowCfg.Address != "" {
+		conn, err := grpc.Dial(shadowCfg.Address, dialOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to shadow LLM orchestrator: %w", err)
+		}
+		s.llm = pb.NewLLMOrchestratorServiceClient(conn)
+	}
+	if shadowCfg.Output != "" {
+		output, err := os.OpenFile(shadowCfg.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open shadow output: %w", err)
+		}
+		s.output = output
+	}
+	logger.GetLogger().Infof("Shadowing %g%% of summaries to %q (model %q)", shadowCfg.Percent, shadowCfg.Address, shadowCfg.Model)
+	return s, nil
+}
+
+// sampled picks whether a live search is mirrored
+func (s *shadower) sampled() bool {
+	return rand.Float64()*100 < s.config.Percent
+}
+
+// shadowHook returns the Shadow hook of a live search's pipeline request:
+// the LLM request is copied, marked as shadow traffic and sent in the
+// background, unless the shadow backend already has its fill
+func (g *Gateway) shadowHook(c *gin.Context, query string) func(*pb.LLMRequest, string, time.Duration, error) {
+	s := g.shadow
+	tenant := g.tenant(c).ID
+	return func(llmReq *pb.LLMRequest, summary string, latency time.Duration, err error) {
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			monitoring.RecordShadow("skipped_busy")
+			return
+		}
+		mirror := proto.Clone(llmReq).(*pb.LLMRequest)
+		mirror.Id += "_shadow"
+		mirror.Stream = false
+		mirror.Shadow = true
+		if s.config.Model != "" {
+			mirror.Model = s.config.Model
+		}
+		record := shadowRecord{
+			Time:        time.Now(),
+			RequestID:   llmReq.Id,
+			Tenant:      tenant,
+			Query:       query,
+			Model:       llmReq.Model,
+			Summary:     summary,
+			LatencyMs:   latency.Milliseconds(),
+			ShadowModel: mirror.Model,
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		go func() {
+			defer func() { <-s.slots }()
+			s.mirror(mirror, record, latency)
+		}()
+	}
+}
+
+// mirror sends the shadow request and records its outcome next to the
+// primary one
+func (s *shadower) mirror(req *pb.LLMRequest, record shadowRecord, primary time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := s.llm.ProcessRequest(ctx, req)
+	latency := time.Since(start)
+	record.ShadowLatencyMs = latency.Milliseconds()
+	switch {
+	case err != nil:
+		record.ShadowError = err.Error()
+	case resp.Error != "":
+		record.ShadowError = resp.Error
+	default:
+		record.ShadowSummary = resp.Summary
+	}
+	if record.ShadowError != "" {
+		monitoring.RecordShadow("failed")
+	} else {
+		monitoring.RecordShadow("mirrored")
+		monitoring.RecordShadowLatency(primary, latency)
+	}
+	s.write(record)
+}
+
+// write appends the record to the output, or logs it without one
+func (s *shadower) write(record shadowRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		logger.GetLogger().Warnf("Failed to encode shadow record: %v", err)
+		return
+	}
+	if s.output == nil {
+		logger.GetLogger().Infof("Shadow summary: %s", line)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.output.Write(append(line, '\n')); err != nil {
+		logger.GetLogger().Warnf("Failed to write shadow record: %v", err)
+	}
+}
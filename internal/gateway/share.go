@@ -0,0 +1,282 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+)
+
+// Share is a completed search published at a signed link. It keeps its own
+// copy of the search, so links may outlive the export TTL.
+type Share struct {
+	ID        string          `json:"id"`
+	Search    CompletedSearch `json:"search"`
+	CreatedBy string          `json:"created_by,omitempty"` // the logged-in user who shared it, or key:<id> of the API key
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// shareLink is what a share URL carries, signed so it cannot be forged or
+// extended
+type shareLink struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"exp"`
+}
+
+// ShareStore keeps shares until they expire or are revoked
+type ShareStore interface {
+	Save(ctx context.Context, share *Share) error
+	// Get returns found=false for unknown, expired or revoked shares
+	Get(ctx context.Context, id string) (share *Share, found bool, err error)
+	Delete(ctx context.Context, id string) error
+	// EraseUser deletes the shares a user of the tenant created or whose
+	// search they ran, returning how many it deleted
+	EraseUser(ctx context.Context, tenant, user string) (int, error)
+}
+
+// sharing signs share links and keeps the shares they point to
+type sharing struct {
+	config config.SharingConfig
+	sealer *auth.Sealer
+	store  ShareStore
+}
+
+// newSharing returns nil when sharing or exports are disabled
+func newSharing(cfg *config.Config, client *redis.Client) *sharing {
+	shareCfg := cfg.Gateway.Sharing
+	if !shareCfg.Enabled || !cfg.Gateway.Exports.Enabled {
+		return nil
+	}
+	secret := shareCfg.Secret
+	if secret == "" {
+		logger.GetLogger().Warn("No sharing.secret set: share links stop working on restart and are not valid across replicas")
+		secret = randomToken(32)
+	}
+	s := &sharing{config: shareCfg, sealer: auth.NewSealer(secret)}
+	if client != nil {
+		s.store = &redisShareStore{client: client}
+	} else {
+		s.store = &memoryShareStore{shares: lru.New[string, Share]("gateway_shares", cfg.Gateway.MemoryStoreEntries, 0)}
+	}
+	return s
+}
+
+// RegisterShareRoutes serves the public pages of shared searches
+func (g *Gateway) RegisterShareRoutes(router gin.IRoutes) {
+	router.GET("/shared/:token", g.SharedSearch)
+}
+
+// ShareSearch publishes a completed search of the tenant at a signed link
+// valid for ?ttl= (default sharing.ttl, at most sharing.max_ttl)
+func (g *Gateway) ShareSearch(c *gin.Context) {
+	if g.sharing == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sharing is disabled"})
+		return
+	}
+	ttl := g.sharing.config.TTL
+	if value := c.Query("ttl"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be a positive duration such as 24h"})
+			return
+		}
+		ttl = parsed
+	}
+	if max := g.sharing.config.MaxTTL; max > 0 && ttl > max {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ttl may be at most %s", max)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	task, found, err := g.tasks.Get(ctx, c.Param("task_id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load completed search %s: %v", c.Param("task_id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search"})
+		return
+	}
+	if !found || task.Tenant != g.tenant(c).ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Search not found or expired"})
+		return
+	}
+
+	now := time.Now().UTC()
+	share := &Share{ID: "shr_" + randomToken(12), Search: *task, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	share.CreatedBy = g.dataSubject(c)
+	token, err := g.sharing.sealer.Seal(shareLink{ID: share.ID, Expires: share.ExpiresAt})
+	if err == nil {
+		err = g.sharing.store.Save(ctx, share)
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to share search %s: %v", task.TaskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share search"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         share.ID,
+		"url":        g.publicBaseURL(c) + "/shared/" + token,
+		"expires_at": share.ExpiresAt,
+	})
+}
+
+// RevokeShare stops a share link of the tenant's search from working
+func (g *Gateway) RevokeShare(c *gin.Context) {
+	if g.sharing == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sharing is disabled"})
+		return
+	}
+	ctx := c.Request.Context()
+	share, found, err := g.sharing.store.Get(ctx, c.Param("share_id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load share %s: %v", c.Param("share_id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share"})
+		return
+	}
+	if !found || share.Search.TaskID != c.Param("task_id") || share.Search.Tenant != g.tenant(c).ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+		return
+	}
+	if err := g.sharing.store.Delete(ctx, share.ID); err != nil {
+		logger.GetLogger().Errorf("Failed to revoke share %s: %v", share.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SharedSearch renders a shared search as a read-only page with its results,
+// summary and cited sources. The signed link is the only credential.
+func (g *Gateway) SharedSearch(c *gin.Context) {
+	if g.sharing == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sharing is disabled"})
+		return
+	}
+	var link shareLink
+	if err := g.sharing.sealer.Open(c.Param("token"), &link); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid share link"})
+		return
+	}
+	if time.Now().After(link.Expires) {
+		c.JSON(http.StatusGone, gin.H{"error": "This share link has expired"})
+		return
+	}
+	share, found, err := g.sharing.store.Get(c.Request.Context(), link.ID)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load share %s: %v", link.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared search"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusGone, gin.H{"error": "This share link has been revoked"})
+		return
+	}
+	page, err := htmlReport(&share.Search)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to render share %s: %v", share.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render shared search"})
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// publicBaseURL is the gateway's public URL, from opensearch.base_url or the
+// request's host
+func (g *Gateway) publicBaseURL(c *gin.Context) string {
+	if base := strings.TrimRight(g.config.Gateway.OpenSearch.BaseURL, "/"); base != "" {
+		return base
+	}
+	return requestBaseURL(c)
+}
+
+type redisShareStore struct {
+	client *redis.Client
+}
+
+func redisShareKey(id string) string {
+	return "share:" + id
+}
+
+func (s *redisShareStore) Save(ctx context.Context, share *Share) error {
+	data, err := json.Marshal(share)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisShareKey(share.ID), data, time.Until(share.ExpiresAt)).Err()
+}
+
+func (s *redisShareStore) Get(ctx context.Context, id string) (*Share, bool, error) {
+	data, err := s.client.Get(ctx, redisShareKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var share Share
+	if err := json.Unmarshal(data, &share); err != nil {
+		return nil, false, fmt.Errorf("invalid share: %w", err)
+	}
+	return &share, true, nil
+}
+
+func (s *redisShareStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, redisShareKey(id)).Err()
+}
+
+func (s *redisShareStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return eraseRedis(ctx, s.client, redisShareKey("*"), func(data []byte) (bool, error) {
+		var share Share
+		if err := json.Unmarshal(data, &share); err != nil {
+			return false, fmt.Errorf("invalid share: %w", err)
+		}
+		return share.sharedBy(tenant, user), nil
+	})
+}
+
+// memoryShareStore expires each share at its own ExpiresAt
+type memoryShareStore struct {
+	shares *lru.Cache[string, Share]
+}
+
+func (s *memoryShareStore) Save(ctx context.Context, share *Share) error {
+	s.shares.AddWithTTL(share.ID, *share, time.Until(share.ExpiresAt))
+	return nil
+}
+
+func (s *memoryShareStore) Get(ctx context.Context, id string) (*Share, bool, error) {
+	share, ok := s.shares.Get(id)
+	if !ok {
+		return nil, false, nil
+	}
+	return &share, true, nil
+}
+
+func (s *memoryShareStore) Delete(ctx context.Context, id string) error {
+	s.shares.Remove(id)
+	return nil
+}
+
+func (s *memoryShareStore) EraseUser(ctx context.Context, tenant, user string) (int, error) {
+	return s.shares.RemoveFunc(func(id string, share Share) bool {
+		return share.sharedBy(tenant, user)
+	}), nil
+}
+
+// sharedBy reports whether a user of the tenant created the share or ran
+// its search
+func (s *Share) sharedBy(tenant, user string) bool {
+	return s.Search.Tenant == tenant && (s.CreatedBy == user || s.Search.User == user)
+}
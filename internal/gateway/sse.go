@@ -0,0 +1,208 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/sseframe"
+)
+
+// resumePollInterval is how often a resumed stream checks the buffer for
+// events still being produced by the original request
+const resumePollInterval = 100 * time.Millisecond
+
+// eventStream writes numbered SSE events. Event IDs are "<stream>:<seq>", so
+// the Last-Event-ID a client sends on reconnect identifies both the stream and
+// the position to resume from.
+type eventStream struct {
+	c        *gin.Context
+	g        *Gateway
+	id       string
+	seq      int64
+	buffer   EventBuffer // nil when buffering is disabled
+	notified bool        // server_shutting_down was sent
+}
+
+// newEventStream starts a stream, using the ID pre-assigned by request
+// coalescing when there is one. The ID also identifies the search's prompt
+// record.
+func (g *Gateway) newEventStream(c *gin.Context) *eventStream {
+	id := c.GetString(streamIDKey)
+	if id == "" {
+		id = newStreamID()
+		c.Set(streamIDKey, id)
+	}
+	s := &eventStream{c: c, g: g, id: id, buffer: g.eventBuffer}
+	c.Header("X-Stream-ID", s.id)
+	return s
+}
+
+func newStreamID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// send writes the event to the client and records it for resumption. The
+// event is buffered even if the client has gone away, so a reconnect picks
+// up everything produced in the meantime.
+func (s *eventStream) send(event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	s.seq++
+	buffered := BufferedEvent{Seq: s.seq, Event: event, Data: payload}
+
+	s.record(buffered)
+	s.g.noticeShutdown(s.c, &s.notified)
+	writeEvent(s.c, s.id, buffered)
+}
+
+// sendToken is send for token events, which make up most of a stream. The
+// event is encoded into a pooled buffer without a map or reflection; only
+// the copy kept for resumption is allocated.
+func (s *eventStream) sendToken(token string, position int32) {
+	buf := sseframe.GetBuffer()
+	defer sseframe.PutBuffer(buf)
+	buf.B = sseframe.AppendTokenData(buf.B, token, position)
+	payload := buf.B
+	s.seq++
+
+	if s.buffer != nil {
+		s.record(BufferedEvent{Seq: s.seq, Event: "token", Data: append(json.RawMessage(nil), payload...)})
+	}
+	s.g.noticeShutdown(s.c, &s.notified)
+	buf.B = sseframe.AppendEvent(buf.B, s.id, s.seq, "token", payload)
+	sse.Event{}.WriteContentType(s.c.Writer)
+	s.c.Writer.Write(buf.B[len(payload):])
+	s.c.Writer.Flush()
+}
+
+// record buffers an event for resumption
+func (s *eventStream) record(event BufferedEvent) {
+	if s.buffer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.buffer.Append(ctx, s.id, event); err != nil {
+		logger.GetLogger().Warnf("Failed to buffer SSE event for stream %s: %v", s.id, err)
+	}
+}
+
+// lastEventKey is the gin context key carrying the name of the last event
+// written, telling how the stream ended
+const lastEventKey = "sse_last_event"
+
+func writeEvent(c *gin.Context, streamID string, event BufferedEvent) {
+	c.Set(lastEventKey, event.Event)
+	c.Render(-1, sse.Event{
+		Id:    streamID + ":" + strconv.FormatInt(event.Seq, 10),
+		Event: event.Event,
+		Data:  event.Data,
+	})
+	c.Writer.Flush()
+}
+
+// parseEventID splits a Last-Event-ID into stream ID and sequence number
+func parseEventID(id string) (string, int64, bool) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil || seq < 0 {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
+
+// isTerminalEvent reports whether no further events follow on the stream
+func isTerminalEvent(event string) bool {
+	return event == "complete" || event == "error" || event == "cancelled"
+}
+
+// resumeStream serves a reconnect carrying Last-Event-ID: buffered events after
+// that ID are replayed, then the stream is followed until it completes. It
+// returns false when the request is not a resume or the stream is no longer
+// buffered, in which case the caller runs the pipeline from scratch.
+func (g *Gateway) resumeStream(c *gin.Context) bool {
+	lastID := c.GetHeader("Last-Event-ID")
+	if g.eventBuffer == nil || lastID == "" {
+		return false
+	}
+	log := logger.GetLogger()
+
+	streamID, seq, ok := parseEventID(lastID)
+	if !ok {
+		log.Warnf("Ignoring malformed Last-Event-ID %q", lastID)
+		monitoring.RecordSSEResume("gateway", "invalid")
+		return false
+	}
+
+	_, found, err := g.eventBuffer.Since(c.Request.Context(), streamID, seq)
+	if err != nil || !found {
+		if err != nil {
+			log.Warnf("Failed to read buffered stream %s: %v", streamID, err)
+		}
+		monitoring.RecordSSEResume("gateway", "expired")
+		return false
+	}
+
+	log.Infof("Resuming SSE stream %s after event %d", streamID, seq)
+	monitoring.RecordSSEResume("gateway", "resumed")
+	g.followStream(c, streamID, seq, nil)
+	return true
+}
+
+// followStream writes the buffered events of a stream after seq and keeps
+// polling for new ones until a terminal event. pending reports whether a
+// stream that is not buffered yet is still expected to appear (a coalesced
+// pipeline that has not sent its first event); nil means it never will.
+func (g *Gateway) followStream(c *gin.Context, streamID string, seq int64, pending func() bool) {
+	log := logger.GetLogger()
+	c.Header("X-Stream-ID", streamID)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), g.config.Services.LLM.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(resumePollInterval)
+	defer ticker.Stop()
+	notified := false
+	for {
+		g.noticeShutdown(c, &notified)
+		events, found, err := g.eventBuffer.Since(ctx, streamID, seq)
+		if err != nil || (!found && (pending == nil || !pending())) {
+			if err != nil {
+				log.Warnf("Failed to read buffered stream %s: %v", streamID, err)
+			}
+			writeEvent(c, streamID, BufferedEvent{Seq: seq + 1, Event: "error", Data: json.RawMessage(`{"message":"Stream is no longer available"}`)})
+			return
+		}
+		for _, event := range events {
+			writeEvent(c, streamID, event)
+			seq = event.Seq
+			if isTerminalEvent(event.Event) {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/pipeline"
+)
+
+// stageTimeout is the configured timeout of the backend a stage calls
+func (g *Gateway) stageTimeout(stage pipeline.StageName) time.Duration {
+	switch stage {
+	case pipeline.StageValidate, pipeline.StageSanitize:
+		return g.config.Services.Safety.Timeout
+	case pipeline.StageSearch:
+		return g.config.Services.Search.Timeout
+	case pipeline.StageSummarize:
+		return g.config.Services.LLM.Timeout
+	case pipeline.StageExtract, pipeline.StageRelated, pipeline.StageRerank, pipeline.StageCompress:
+		return g.config.Services.Inference.Timeout
+	}
+	return 0
+}
+
+// stageContext derives the context for one pipeline stage from the request
+// and the stage's configured timeout; a zero timeout means no deadline. The
+// stage ends when its client disconnects or cancels the stream
+// (CancelSearch), and carries the request's flag and fault metadata.
+func (g *Gateway) stageContext(c *gin.Context, stage pipeline.StageName) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(g.requestContext(c))
+	stop := context.AfterFunc(c.Request.Context(), cancel)
+	release := func() {
+		stop()
+		cancel()
+	}
+	if timeout := g.stageTimeout(stage); timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		return ctx, func() {
+			cancelTimeout()
+			release()
+		}
+	}
+	return ctx, release
+}
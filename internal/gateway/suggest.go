@@ -0,0 +1,326 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// suggestionsContentType is the response type of the browser suggestion
+// protocol: a JSON array of the query and its completions
+const suggestionsContentType = "application/x-suggestions+json"
+
+// QueryHistory counts the queries of completed searches per tenant
+type QueryHistory interface {
+	Record(ctx context.Context, tenant, query string) error
+	// Complete returns the tenant's queries that start with prefix and were
+	// searched at least minCount times, most searched first
+	Complete(ctx context.Context, tenant, prefix string, minCount int) ([]string, error)
+	// Expire drops every tenant's queries last searched before the given
+	// time, returning how many it dropped
+	Expire(ctx context.Context, before time.Time) (int, error)
+}
+
+// newQueryHistory returns the configured store, or nil when suggestions are disabled
+func newQueryHistory(cfg *config.Config, client *redis.Client) QueryHistory {
+	suggestCfg := cfg.Gateway.Suggest
+	if !suggestCfg.Enabled {
+		return nil
+	}
+	if client != nil {
+		return &redisQueryHistory{client: client, maxEntries: suggestCfg.MaxEntries}
+	}
+	return &memoryQueryHistory{maxEntries: suggestCfg.MaxEntries, tenants: make(map[string]map[string]*suggestion)}
+}
+
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// redisQueryHistory keeps three sorted sets per tenant: search counts, the
+// queries in lexical order for prefix lookups, and last use for eviction
+type redisQueryHistory struct {
+	client     *redis.Client
+	maxEntries int
+}
+
+func redisHistoryKey(tenant, set string) string {
+	return "suggest:" + tenant + ":" + set
+}
+
+func (s *redisQueryHistory) Record(ctx context.Context, tenant, query string) error {
+	counts, lex, recent := redisHistoryKey(tenant, "counts"), redisHistoryKey(tenant, "lex"), redisHistoryKey(tenant, "recent")
+	pipe := s.client.TxPipeline()
+	pipe.ZIncrBy(ctx, counts, 1, query)
+	pipe.ZAdd(ctx, lex, redis.Z{Member: query})
+	pipe.ZAdd(ctx, recent, redis.Z{Score: float64(time.Now().UnixNano()), Member: query})
+	size := pipe.ZCard(ctx, recent)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	excess := size.Val() - int64(s.maxEntries)
+	if s.maxEntries <= 0 || excess <= 0 {
+		return nil
+	}
+	stale, err := s.client.ZRange(ctx, recent, 0, excess-1).Result()
+	if err != nil {
+		return err
+	}
+	members := make([]interface{}, len(stale))
+	for i, member := range stale {
+		members[i] = member
+	}
+	pipe = s.client.TxPipeline()
+	for _, key := range []string{counts, lex, recent} {
+		pipe.ZRem(ctx, key, members...)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisQueryHistory) Complete(ctx context.Context, tenant, prefix string, minCount int) ([]string, error) {
+	queries, err := s.client.ZRangeByLex(ctx, redisHistoryKey(tenant, "lex"), &redis.ZRangeBy{
+		Min: "[" + prefix, Max: "[" + prefix + "\xff", Count: 1000,
+	}).Result()
+	if err != nil || len(queries) == 0 {
+		return nil, err
+	}
+	counts, err := s.client.ZMScore(ctx, redisHistoryKey(tenant, "counts"), queries...).Result()
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]suggestion, 0, len(queries))
+	for i, query := range queries {
+		if int(counts[i]) >= minCount && query != prefix {
+			matches = append(matches, suggestion{query: query, count: int(counts[i])})
+		}
+	}
+	return rankSuggestions(matches), nil
+}
+
+func (s *redisQueryHistory) Expire(ctx context.Context, before time.Time) (int, error) {
+	return scanRedis(ctx, s.client, redisHistoryKey("*", "recent"), func(recent string) (int, error) {
+		stale, err := s.client.ZRangeByScore(ctx, recent, &redis.ZRangeBy{
+			Min: "-inf", Max: "(" + strconv.FormatInt(before.UnixNano(), 10),
+		}).Result()
+		if err != nil || len(stale) == 0 {
+			return 0, err
+		}
+		members := make([]interface{}, len(stale))
+		for i, member := range stale {
+			members[i] = member
+		}
+		tenant := strings.TrimSuffix(strings.TrimPrefix(recent, "suggest:"), ":recent")
+		pipe := s.client.TxPipeline()
+		for _, key := range []string{redisHistoryKey(tenant, "counts"), redisHistoryKey(tenant, "lex"), recent} {
+			pipe.ZRem(ctx, key, members...)
+		}
+		_, err = pipe.Exec(ctx)
+		return len(stale), err
+	})
+}
+
+type suggestion struct {
+	query    string
+	count    int
+	lastUsed time.Time
+}
+
+type memoryQueryHistory struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	tenants map[string]map[string]*suggestion // tenant -> normalized query -> usage
+}
+
+// Record counts a search, dropping the tenant's least recently used queries
+// beyond maxEntries
+func (s *memoryQueryHistory) Record(ctx context.Context, tenant, query string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queries := s.tenants[tenant]
+	if queries == nil {
+		queries = make(map[string]*suggestion)
+		s.tenants[tenant] = queries
+	}
+	if entry, ok := queries[query]; ok {
+		entry.count++
+		entry.lastUsed = time.Now()
+		return nil
+	}
+	if s.maxEntries > 0 && len(queries) >= s.maxEntries {
+		var oldest *suggestion
+		for _, entry := range queries {
+			if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+				oldest = entry
+			}
+		}
+		delete(queries, oldest.query)
+	}
+	queries[query] = &suggestion{query: query, count: 1, lastUsed: time.Now()}
+	return nil
+}
+
+func (s *memoryQueryHistory) Complete(ctx context.Context, tenant, prefix string, minCount int) ([]string, error) {
+	s.mu.Lock()
+	var matches []suggestion
+	for query, entry := range s.tenants[tenant] {
+		if entry.count >= minCount && query != prefix && strings.HasPrefix(query, prefix) {
+			matches = append(matches, *entry)
+		}
+	}
+	s.mu.Unlock()
+	return rankSuggestions(matches), nil
+}
+
+func (s *memoryQueryHistory) Expire(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := 0
+	for _, queries := range s.tenants {
+		for query, entry := range queries {
+			if entry.lastUsed.Before(before) {
+				delete(queries, query)
+				expired++
+			}
+		}
+	}
+	return expired, nil
+}
+
+// rankSuggestions orders the most searched first, then the most recent
+func rankSuggestions(matches []suggestion) []string {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].count != matches[j].count {
+			return matches[i].count > matches[j].count
+		}
+		if !matches[i].lastUsed.Equal(matches[j].lastUsed) {
+			return matches[i].lastUsed.After(matches[j].lastUsed)
+		}
+		return matches[i].query < matches[j].query
+	})
+	queries := make([]string, len(matches))
+	for i, match := range matches {
+		queries[i] = match.query
+	}
+	return queries
+}
+
+// privatePatterns match queries that look like they carry personal data:
+// email addresses, phone, card or ID numbers, URLs and tokens
+var privatePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\S+@\S+\.\w+`),
+	regexp.MustCompile(`\d[\d\s().-]{5,}\d`),
+	regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://`),
+	regexp.MustCompile(`[A-Za-z0-9_-]{24,}`),
+}
+
+// recordable reports whether a query may enter the suggestion history
+func (g *Gateway) recordable(query string) bool {
+	if max := g.config.Gateway.Suggest.MaxQueryWords; max > 0 && len(strings.Fields(query)) > max {
+		return false
+	}
+	for _, pattern := range privatePatterns {
+		if pattern.MatchString(query) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordQuery adds the query of a completed search to its tenant's history
+func (g *Gateway) recordQuery(c *gin.Context, query string) {
+	if g.history == nil {
+		return
+	}
+	query = normalizeQuery(query)
+	if query == "" || !g.recordable(query) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := g.history.Record(ctx, g.tenant(c).ID, query); err != nil {
+		logger.GetLogger().Warnf("Failed to record query for suggestions: %v", err)
+	}
+}
+
+// Suggest completes GET /api/v1/suggest?q=prefix in the browser suggestion
+// format, ["prefix", ["completion", ...]]: the tenant's frequent queries
+// first, then the web provider's completions. Every suggestion passes the
+// safety service's filter and the tenant's blocked terms; if the filter is
+// unavailable none are returned.
+func (g *Gateway) Suggest(c *gin.Context) {
+	if g.history == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestions are disabled"})
+		return
+	}
+	tenant := g.tenant(c)
+	log := logger.GetLogger()
+	cfg := g.config.Gateway.Suggest
+	rawPrefix := c.Query("q")
+	prefix := normalizeQuery(rawPrefix)
+
+	completions := []string{}
+	if prefix != "" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout)
+		defer cancel()
+
+		candidates, err := g.history.Complete(ctx, tenant.ID, prefix, cfg.MinCount)
+		if err != nil {
+			log.Warnf("Failed to load query history for suggestions: %v", err)
+		}
+		provider, err := g.searchClient.Suggest(ctx, &pb.SuggestRequest{Query: prefix, MaxResults: int32(cfg.MaxResults)})
+		if err != nil {
+			log.Warnf("Provider suggestions failed: %v", err)
+		} else {
+			candidates = append(candidates, provider.Suggestions...)
+		}
+
+		seen := map[string]bool{prefix: true}
+		var unique []string
+		for _, candidate := range candidates {
+			if key := normalizeQuery(candidate); !seen[key] {
+				seen[key] = true
+				unique = append(unique, candidate)
+			}
+		}
+		if len(unique) > 0 {
+			filtered, err := g.safetyClient.FilterSuggestions(ctx, &pb.FilterSuggestionsRequest{
+				Suggestions: unique,
+				Policy:      g.safetyPolicy(c),
+			})
+			if err != nil {
+				log.Warnf("Failed to filter suggestions, returning none: %v", err)
+			} else {
+				completions = append(completions, filtered.Allowed...)
+			}
+		}
+		if cfg.MaxResults > 0 && len(completions) > cfg.MaxResults {
+			completions = completions[:cfg.MaxResults]
+		}
+	}
+
+	body, err := json.Marshal([]interface{}{rawPrefix, completions})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode suggestions"})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=60")
+	c.Header("Vary", APIKeyHeader)
+	c.Data(http.StatusOK, suggestionsContentType+"; charset=utf-8", body)
+}
@@ -0,0 +1,231 @@
+package gateway
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/auth"
+	"ai-search-service/internal/logger"
+)
+
+// registerTenantAdminRoutes registers the tenant admin API when
+// multi-tenancy is enabled. Viewers may read tenants and admins change them;
+// without RBAC only tenancy.admin_token is accepted, and the API is not
+// served if it is unset.
+func (g *Gateway) registerTenantAdminRoutes(admin *gin.RouterGroup) {
+	if g.tenants == nil || (g.access == nil && g.config.Gateway.Tenancy.AdminToken == "") {
+		return
+	}
+	read, write := g.authorize(auth.RoleViewer), g.authorize(auth.RoleAdmin)
+	tenants := admin.Group("/tenants")
+	tenants.GET("", read, g.ListTenants)
+	tenants.POST("", write, g.CreateTenant)
+	tenants.GET("/:id", read, g.GetTenant)
+	tenants.PUT("/:id", write, g.UpdateTenant)
+	tenants.DELETE("/:id", write, g.DeleteTenant)
+	tenants.POST("/:id/keys", write, g.CreateTenantKey)
+	tenants.DELETE("/:id/keys/:key_id", write, g.RevokeTenantKey)
+	tenants.PUT("/:id/keys/:key_id/safety", write, g.SetKeySafety)
+}
+
+// ListTenants returns every tenant
+func (g *Gateway) ListTenants(c *gin.Context) {
+	tenants, err := g.tenants.List(c.Request.Context())
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to list tenants: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tenants"})
+		return
+	}
+	for i, tenant := range tenants {
+		tenants[i] = tenant.public()
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": tenants})
+}
+
+// CreateTenant adds a tenant. It has no API keys until one is created.
+func (g *Gateway) CreateTenant(c *gin.Context) {
+	var tenant Tenant
+	if !bindJSON(c, &tenant) {
+		return
+	}
+	if err := tenant.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	if _, found, err := g.tenants.Get(ctx, tenant.ID); err != nil || found {
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to load tenant %s: %v", tenant.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tenant"})
+		} else {
+			c.JSON(http.StatusConflict, gin.H{"error": "A tenant with this ID exists"})
+		}
+		return
+	}
+	if !g.subdomainAvailable(c, &tenant) {
+		return
+	}
+	tenant.Keys, tenant.CreatedAt = nil, time.Now().UTC()
+	if err := g.tenants.Save(ctx, &tenant); err != nil {
+		logger.GetLogger().Errorf("Failed to save tenant %s: %v", tenant.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tenant"})
+		return
+	}
+	c.JSON(http.StatusCreated, tenant.public())
+}
+
+// GetTenant returns a tenant
+func (g *Gateway) GetTenant(c *gin.Context) {
+	if tenant := g.adminTenant(c); tenant != nil {
+		c.JSON(http.StatusOK, tenant.public())
+	}
+}
+
+// UpdateTenant replaces a tenant's name, subdomain, policies and quotas; its
+// ID, keys and creation time are kept
+func (g *Gateway) UpdateTenant(c *gin.Context) {
+	existing := g.adminTenant(c)
+	if existing == nil {
+		return
+	}
+	var tenant Tenant
+	if !bindJSON(c, &tenant) {
+		return
+	}
+	tenant.ID, tenant.Keys, tenant.CreatedAt = existing.ID, existing.Keys, existing.CreatedAt
+	if err := tenant.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !g.subdomainAvailable(c, &tenant) {
+		return
+	}
+	g.saveTenant(c, &tenant, http.StatusOK)
+}
+
+// DeleteTenant removes a tenant, revoking its keys. Its stored searches and
+// history expire or remain unreachable.
+func (g *Gateway) DeleteTenant(c *gin.Context) {
+	tenant := g.adminTenant(c)
+	if tenant == nil {
+		return
+	}
+	if err := g.tenants.Delete(c.Request.Context(), tenant.ID); err != nil {
+		logger.GetLogger().Errorf("Failed to delete tenant %s: %v", tenant.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tenant"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateTenantKey issues an API key for a tenant. The key is only ever
+// returned in this response.
+func (g *Gateway) CreateTenantKey(c *gin.Context) {
+	tenant := g.adminTenant(c)
+	if tenant == nil {
+		return
+	}
+	key, record := newAPIKey()
+	tenant.Keys = append(tenant.Keys, record)
+	if err := g.tenants.Save(c.Request.Context(), tenant); err != nil {
+		logger.GetLogger().Errorf("Failed to save key of tenant %s: %v", tenant.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create key"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": record.ID, "key": key, "prefix": record.Prefix, "created_at": record.CreatedAt})
+}
+
+// RevokeTenantKey deletes one of a tenant's API keys
+func (g *Gateway) RevokeTenantKey(c *gin.Context) {
+	tenant := g.adminTenant(c)
+	if tenant == nil {
+		return
+	}
+	keys := tenant.Keys[:0]
+	for _, key := range tenant.Keys {
+		if key.ID != c.Param("key_id") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == len(tenant.Keys) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No key with this ID"})
+		return
+	}
+	tenant.Keys = keys
+	g.saveTenant(c, tenant, http.StatusOK)
+}
+
+// SetKeySafety replaces the terms and patterns an API key blocks and allows
+// on top of its tenant's; empty lists leave the key with the tenant's policy
+func (g *Gateway) SetKeySafety(c *gin.Context) {
+	tenant := g.adminTenant(c)
+	if tenant == nil {
+		return
+	}
+	var overrides SafetyOverrides
+	if !bindJSON(c, &overrides) {
+		return
+	}
+	if err := overrides.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range tenant.Keys {
+		if tenant.Keys[i].ID != c.Param("key_id") {
+			continue
+		}
+		tenant.Keys[i].Safety = &overrides
+		if len(overrides.BlockedTerms) == 0 && len(overrides.BlockedPatterns) == 0 && len(overrides.AllowedTerms) == 0 {
+			tenant.Keys[i].Safety = nil
+		}
+		g.saveTenant(c, tenant, http.StatusOK)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "No key with this ID"})
+}
+
+func (g *Gateway) saveTenant(c *gin.Context, tenant *Tenant, status int) {
+	if err := g.tenants.Save(c.Request.Context(), tenant); err != nil {
+		logger.GetLogger().Errorf("Failed to save tenant %s: %v", tenant.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tenant"})
+		return
+	}
+	c.JSON(status, tenant.public())
+}
+
+// subdomainAvailable writes a 409 and returns false if another tenant uses
+// the tenant's subdomain
+func (g *Gateway) subdomainAvailable(c *gin.Context, tenant *Tenant) bool {
+	if tenant.Subdomain == "" {
+		return true
+	}
+	owner, found, err := g.tenants.BySubdomain(c.Request.Context(), tenant.Subdomain)
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to look up subdomain %s: %v", tenant.Subdomain, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tenant"})
+		return false
+	}
+	if found && owner.ID != tenant.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another tenant uses this subdomain"})
+		return false
+	}
+	return true
+}
+
+// adminTenant loads the tenant named by the :id parameter, writing the error
+// response and returning nil if there is none
+func (g *Gateway) adminTenant(c *gin.Context) *Tenant {
+	tenant, found, err := g.tenants.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to load tenant %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tenant"})
+		return nil
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No tenant with this ID"})
+		return nil
+	}
+	return tenant
+}
@@ -0,0 +1,740 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/pipeline"
+	"ai-search-service/internal/store"
+	pb "ai-search-service/proto"
+)
+
+// APIKeyHeader carries the key a request's tenant is resolved from
+const APIKeyHeader = "X-API-Key"
+
+// tenantContextKey is the gin context key of the resolved *Tenant
+const tenantContextKey = "tenant"
+
+// apiKeyContextKey is the gin context key of the hash of the API key the
+// tenant was resolved from
+const apiKeyContextKey = "api_key_hash"
+
+// defaultTenantID owns requests that name no tenant. Creating a tenant with
+// this ID applies its policies to them.
+const defaultTenantID = "default"
+
+var (
+	tenantIDPattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+	subdomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+// Tenant is an isolated customer of the gateway: its searches run under its
+// policies and quotas, and its query history, completed searches, saved
+// queries and cached responses are visible to it alone
+type Tenant struct {
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	Subdomain         string   `json:"subdomain,omitempty"`
+	Sources           []string `json:"sources,omitempty"`
+	ForceSafeSearch   bool     `json:"force_safe_search"`
+	BlockedTerms      []string `json:"blocked_terms,omitempty"`
+	BlockedPatterns   []string `json:"blocked_patterns,omitempty"`
+	AllowedTerms      []string `json:"allowed_terms,omitempty"`
+	Model             string   `json:"model,omitempty"`
+	MaxTokens         int32    `json:"max_tokens,omitempty"`
+	NumResults        int      `json:"num_results,omitempty"`
+	RequestsPerMinute int      `json:"requests_per_minute,omitempty"`
+	RequestsPerDay    int      `json:"requests_per_day,omitempty"`
+	// Cache replaces gateway.response_cache's policy; nil keeps it
+	Cache     *CacheControl `json:"cache,omitempty"`
+	Keys      []APIKey      `json:"keys,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// APIKey identifies a tenant's key without revealing it; only the SHA-256 of
+// the key is stored
+type APIKey struct {
+	ID        string    `json:"id"`
+	Prefix    string    `json:"prefix"` // first characters, to tell keys apart
+	Hash      string    `json:"hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Safety adds to the tenant's safety policy for requests with this key
+	Safety *SafetyOverrides `json:"safety,omitempty"`
+}
+
+// SafetyOverrides are the terms and patterns an API key blocks and allows
+// on top of its tenant's
+type SafetyOverrides struct {
+	BlockedTerms    []string `json:"blocked_terms,omitempty"`
+	BlockedPatterns []string `json:"blocked_patterns,omitempty"`
+	AllowedTerms    []string `json:"allowed_terms,omitempty"`
+}
+
+// validate checks that the blocked patterns compile
+func (o *SafetyOverrides) validate() error {
+	return validatePatterns(o.BlockedPatterns)
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("blocked pattern %q is not a valid regular expression: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// public returns a copy safe to show to admins, without key hashes
+func (t *Tenant) public() *Tenant {
+	out := *t
+	out.Keys = make([]APIKey, len(t.Keys))
+	for i, key := range t.Keys {
+		key.Hash = ""
+		out.Keys[i] = key
+	}
+	return &out
+}
+
+// validate checks the fields admins and the config may set
+func (t *Tenant) validate() error {
+	if !tenantIDPattern.MatchString(t.ID) {
+		return fmt.Errorf("id must be 1-63 lowercase letters, digits, '-' or '_'")
+	}
+	if t.Subdomain != "" && !subdomainPattern.MatchString(t.Subdomain) {
+		return fmt.Errorf("subdomain must be a lowercase DNS label")
+	}
+	if t.MaxTokens < 0 || t.NumResults < 0 || t.RequestsPerMinute < 0 || t.RequestsPerDay < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if t.Cache != nil && (t.Cache.MaxAge < 0 || t.Cache.StaleWhileRevalidate < 0) {
+		return fmt.Errorf("cache ages must not be negative")
+	}
+	return validatePatterns(t.BlockedPatterns)
+}
+
+// apply sets the tenant's policies on a pipeline request: allowed sources,
+// the summarization model and token limit, and safe search
+func (t *Tenant) apply(req *pipeline.Request) {
+	req.Sources = t.Sources
+	req.Model = t.Model
+	if t.MaxTokens > 0 {
+		req.MaxTokens = t.MaxTokens
+	}
+	if t.ForceSafeSearch {
+		req.SafeSearch = true
+	}
+}
+
+// defaultNumResults is the result count of searches that do not ask for one
+func (t *Tenant) defaultNumResults() int {
+	if t.NumResults > 0 {
+		return t.NumResults
+	}
+	return 5
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func keyPrefix(key string) string {
+	if len(key) > 8 {
+		return key[:8]
+	}
+	return key
+}
+
+// newAPIKey returns a random key and its stored record
+func newAPIKey() (string, APIKey) {
+	b := make([]byte, 24)
+	rand.Read(b)
+	key := "sk_" + hex.EncodeToString(b)
+	id := make([]byte, 6)
+	rand.Read(id)
+	return key, APIKey{ID: "key_" + hex.EncodeToString(id), Prefix: keyPrefix(key), Hash: hashAPIKey(key), CreatedAt: time.Now().UTC()}
+}
+
+// TenantStore persists tenants with lookups by API key hash and subdomain
+type TenantStore interface {
+	Save(ctx context.Context, tenant *Tenant) error
+	// Get, ByKey and BySubdomain return found=false for unknown tenants
+	Get(ctx context.Context, id string) (tenant *Tenant, found bool, err error)
+	ByKey(ctx context.Context, keyHash string) (tenant *Tenant, found bool, err error)
+	BySubdomain(ctx context.Context, subdomain string) (tenant *Tenant, found bool, err error)
+	List(ctx context.Context) ([]*Tenant, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// newTenantStore returns the configured store with the config's tenants
+// created or updated, or nil when multi-tenancy is disabled
+func newTenantStore(cfg *config.Config, client *redis.Client, db *store.Store) TenantStore {
+	tenancyCfg := cfg.Gateway.Tenancy
+	if !tenancyCfg.Enabled {
+		return nil
+	}
+	var tenants TenantStore
+	switch {
+	case db.Keeps(store.Tenants):
+		tenants = &durableTenantStore{records: db.Records}
+	case client != nil:
+		tenants = &redisTenantStore{client: client}
+	default:
+		tenants = &memoryTenantStore{tenants: make(map[string]*Tenant)}
+	}
+	seedTenants(tenants, tenancyCfg.Tenants)
+	return tenants
+}
+
+// seedTenants writes the configured tenants. Config fields replace the
+// stored ones; keys created through the admin API are kept.
+func seedTenants(store TenantStore, tenants []config.TenantConfig) {
+	log := logger.GetLogger()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, tc := range tenants {
+		tenant := &Tenant{
+			ID:                tc.ID,
+			Name:              tc.Name,
+			Subdomain:         tc.Subdomain,
+			Sources:           tc.Sources,
+			ForceSafeSearch:   tc.ForceSafeSearch,
+			BlockedTerms:      tc.BlockedTerms,
+			BlockedPatterns:   tc.BlockedPatterns,
+			AllowedTerms:      tc.AllowedTerms,
+			Model:             tc.Model,
+			MaxTokens:         tc.MaxTokens,
+			NumResults:        tc.NumResults,
+			RequestsPerMinute: tc.RequestsPerMinute,
+			RequestsPerDay:    tc.RequestsPerDay,
+			CreatedAt:         time.Now().UTC(),
+		}
+		if tc.Cache != nil {
+			tenant.Cache = &CacheControl{
+				MaxAge:               int(tc.Cache.MaxAge.Seconds()),
+				StaleWhileRevalidate: int(tc.Cache.StaleWhileRevalidate.Seconds()),
+			}
+		}
+		if err := tenant.validate(); err != nil {
+			log.Errorf("Skipping configured tenant %q: %v", tc.ID, err)
+			continue
+		}
+		existing, found, err := store.Get(ctx, tc.ID)
+		if err != nil {
+			log.Errorf("Failed to load tenant %s: %v", tc.ID, err)
+			continue
+		}
+		if found {
+			tenant.CreatedAt = existing.CreatedAt
+			for _, key := range existing.Keys {
+				if !strings.HasPrefix(key.ID, "cfg_") {
+					tenant.Keys = append(tenant.Keys, key)
+				}
+			}
+		}
+		for _, raw := range tc.APIKeys {
+			hash := hashAPIKey(raw)
+			tenant.Keys = append(tenant.Keys, APIKey{ID: "cfg_" + hash[:8], Prefix: keyPrefix(raw), Hash: hash, CreatedAt: tenant.CreatedAt})
+		}
+		if err := store.Save(ctx, tenant); err != nil {
+			log.Errorf("Failed to save configured tenant %s: %v", tc.ID, err)
+		}
+	}
+}
+
+// redisTenantStore keeps tenants as JSON in the "tenants" hash, with
+// "tenants:keys" and "tenants:subdomains" mapping key hashes and subdomains
+// to tenant IDs
+type redisTenantStore struct {
+	client *redis.Client
+}
+
+const (
+	redisTenantsKey    = "tenants"
+	redisTenantKeysKey = "tenants:keys"
+	redisSubdomainsKey = "tenants:subdomains"
+)
+
+func (s *redisTenantStore) Save(ctx context.Context, tenant *Tenant) error {
+	data, err := json.Marshal(tenant)
+	if err != nil {
+		return err
+	}
+	previous, found, err := s.Get(ctx, tenant.ID)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	if found {
+		unindexTenant(ctx, pipe, previous)
+	}
+	pipe.HSet(ctx, redisTenantsKey, tenant.ID, data)
+	for _, key := range tenant.Keys {
+		pipe.HSet(ctx, redisTenantKeysKey, key.Hash, tenant.ID)
+	}
+	if tenant.Subdomain != "" {
+		pipe.HSet(ctx, redisSubdomainsKey, tenant.Subdomain, tenant.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func unindexTenant(ctx context.Context, pipe redis.Pipeliner, tenant *Tenant) {
+	for _, key := range tenant.Keys {
+		pipe.HDel(ctx, redisTenantKeysKey, key.Hash)
+	}
+	if tenant.Subdomain != "" {
+		pipe.HDel(ctx, redisSubdomainsKey, tenant.Subdomain)
+	}
+}
+
+func (s *redisTenantStore) Get(ctx context.Context, id string) (*Tenant, bool, error) {
+	data, err := s.client.HGet(ctx, redisTenantsKey, id).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var tenant Tenant
+	if err := json.Unmarshal(data, &tenant); err != nil {
+		return nil, false, fmt.Errorf("invalid tenant: %w", err)
+	}
+	return &tenant, true, nil
+}
+
+func (s *redisTenantStore) lookup(ctx context.Context, index, field string) (*Tenant, bool, error) {
+	id, err := s.client.HGet(ctx, index, field).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *redisTenantStore) ByKey(ctx context.Context, keyHash string) (*Tenant, bool, error) {
+	return s.lookup(ctx, redisTenantKeysKey, keyHash)
+}
+
+func (s *redisTenantStore) BySubdomain(ctx context.Context, subdomain string) (*Tenant, bool, error) {
+	return s.lookup(ctx, redisSubdomainsKey, subdomain)
+}
+
+func (s *redisTenantStore) List(ctx context.Context) ([]*Tenant, error) {
+	values, err := s.client.HVals(ctx, redisTenantsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	tenants := make([]*Tenant, 0, len(values))
+	for _, value := range values {
+		var tenant Tenant
+		if err := json.Unmarshal([]byte(value), &tenant); err != nil {
+			return nil, fmt.Errorf("invalid tenant: %w", err)
+		}
+		tenants = append(tenants, &tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func (s *redisTenantStore) Delete(ctx context.Context, id string) error {
+	tenant, found, err := s.Get(ctx, id)
+	if err != nil || !found {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	unindexTenant(ctx, pipe, tenant)
+	pipe.HDel(ctx, redisTenantsKey, id)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+type memoryTenantStore struct {
+	mu      sync.Mutex
+	tenants map[string]*Tenant
+}
+
+func copyTenant(tenant *Tenant) *Tenant {
+	out := *tenant
+	out.Keys = append([]APIKey(nil), tenant.Keys...)
+	return &out
+}
+
+func (s *memoryTenantStore) Save(ctx context.Context, tenant *Tenant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[tenant.ID] = copyTenant(tenant)
+	return nil
+}
+
+func (s *memoryTenantStore) Get(ctx context.Context, id string) (*Tenant, bool, error) {
+	return s.find(func(t *Tenant) bool { return t.ID == id })
+}
+
+func (s *memoryTenantStore) ByKey(ctx context.Context, keyHash string) (*Tenant, bool, error) {
+	return s.find(func(t *Tenant) bool {
+		for _, key := range t.Keys {
+			if key.Hash == keyHash {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (s *memoryTenantStore) BySubdomain(ctx context.Context, subdomain string) (*Tenant, bool, error) {
+	return s.find(func(t *Tenant) bool { return t.Subdomain == subdomain })
+}
+
+func (s *memoryTenantStore) find(match func(*Tenant) bool) (*Tenant, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tenant := range s.tenants {
+		if match(tenant) {
+			return copyTenant(tenant), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (s *memoryTenantStore) List(ctx context.Context) ([]*Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tenants := make([]*Tenant, 0, len(s.tenants))
+	for _, tenant := range s.tenants {
+		tenants = append(tenants, copyTenant(tenant))
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func (s *memoryTenantStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tenants, id)
+	return nil
+}
+
+// resolveTenant identifies the request's tenant by its X-API-Key header,
+// then by its subdomain of tenancy.base_domain, and stores it in the context.
+// Unknown keys and subdomains are rejected; requests naming no tenant use
+// the default tenant unless tenancy.require_tenant is set and the request
+// has no logged-in user.
+func (g *Gateway) resolveTenant(c *gin.Context) {
+	if g.tenants == nil {
+		c.Next()
+		return
+	}
+	ctx := c.Request.Context()
+	var tenant *Tenant
+	var found bool
+	var err error
+	switch key, subdomain := c.GetHeader(APIKeyHeader), g.requestSubdomain(c); {
+	case key != "":
+		tenant, found, err = g.tenants.ByKey(ctx, hashAPIKey(key))
+		if err == nil && !found {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+		c.Set(apiKeyContextKey, hashAPIKey(key))
+	case subdomain != "":
+		tenant, found, err = g.tenants.BySubdomain(ctx, subdomain)
+		if err == nil && !found {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Unknown tenant"})
+			return
+		}
+	case g.config.Gateway.Tenancy.RequireTenant && g.user(c) == nil:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "An API key is required"})
+		return
+	default:
+		tenant, found, err = g.tenants.Get(ctx, defaultTenantID)
+		if err == nil && !found {
+			tenant = &Tenant{ID: defaultTenantID}
+		}
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to resolve tenant: %v", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to resolve tenant"})
+		return
+	}
+	c.Set(tenantContextKey, tenant)
+	c.Next()
+}
+
+// requestSubdomain returns the label in front of tenancy.base_domain in the
+// request's host, or "" if the host is not a direct subdomain of it
+func (g *Gateway) requestSubdomain(c *gin.Context) string {
+	base := strings.ToLower(strings.Trim(g.config.Gateway.Tenancy.BaseDomain, "."))
+	if base == "" {
+		return ""
+	}
+	host := strings.ToLower(c.Request.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label := strings.TrimSuffix(host, "."+base)
+	if label == host || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// tenant returns the request's tenant; without multi-tenancy every request
+// belongs to an unrestricted default tenant
+func (g *Gateway) tenant(c *gin.Context) *Tenant {
+	if value, ok := c.Get(tenantContextKey); ok {
+		return value.(*Tenant)
+	}
+	return &Tenant{ID: defaultTenantID}
+}
+
+// safetyPolicy is what the request's tenant and API key add to the safety
+// filters, or nil when they add nothing. A key with its own overrides gets a
+// policy of its own, which the safety service compiles separately.
+func (g *Gateway) safetyPolicy(c *gin.Context) *pb.SafetyPolicy {
+	tenant := g.tenant(c)
+	policy := &pb.SafetyPolicy{
+		Id:              tenant.ID,
+		BlockedTerms:    tenant.BlockedTerms,
+		BlockedPatterns: tenant.BlockedPatterns,
+		AllowedTerms:    tenant.AllowedTerms,
+	}
+	if hash := c.GetString(apiKeyContextKey); hash != "" {
+		for _, key := range tenant.Keys {
+			if key.Hash == hash && key.Safety != nil {
+				policy.Id = tenant.ID + "/" + key.ID
+				policy.BlockedTerms = append(append([]string(nil), policy.BlockedTerms...), key.Safety.BlockedTerms...)
+				policy.BlockedPatterns = append(append([]string(nil), policy.BlockedPatterns...), key.Safety.BlockedPatterns...)
+				policy.AllowedTerms = append(append([]string(nil), policy.AllowedTerms...), key.Safety.AllowedTerms...)
+			}
+		}
+	}
+	if len(policy.BlockedTerms) == 0 && len(policy.BlockedPatterns) == 0 && len(policy.AllowedTerms) == 0 {
+		return nil
+	}
+	return policy
+}
+
+// QuotaCounter counts requests in fixed windows
+type QuotaCounter interface {
+	// Incr adds a request to the window named key, which expires after ttl,
+	// and returns the window's count
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Count returns the window's count without adding to it
+	Count(ctx context.Context, key string) (int64, error)
+}
+
+func newQuotaCounter(cfg *config.Config, client *redis.Client, db *store.Store) QuotaCounter {
+	if !cfg.Gateway.Tenancy.Enabled {
+		return nil
+	}
+	if db.Keeps(store.Usage) {
+		return &durableQuotaCounter{counters: db.Counters}
+	}
+	if client != nil {
+		return &redisQuotaCounter{client: client}
+	}
+	return &memoryQuotaCounter{windows: make(map[string]*quotaWindow)}
+}
+
+type redisQuotaCounter struct {
+	client *redis.Client
+}
+
+func (q *redisQuotaCounter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := q.client.TxPipeline()
+	count := pipe.Incr(ctx, "quota:"+key)
+	pipe.ExpireNX(ctx, "quota:"+key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+func (q *redisQuotaCounter) Count(ctx context.Context, key string) (int64, error) {
+	count, err := q.client.Get(ctx, "quota:"+key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
+type quotaWindow struct {
+	count   int64
+	expires time.Time
+}
+
+type memoryQuotaCounter struct {
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+func (q *memoryQuotaCounter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	for k, w := range q.windows {
+		if now.After(w.expires) {
+			delete(q.windows, k)
+		}
+	}
+	w, ok := q.windows[key]
+	if !ok {
+		w = &quotaWindow{expires: now.Add(ttl)}
+		q.windows[key] = w
+	}
+	w.count++
+	return w.count, nil
+}
+
+func (q *memoryQuotaCounter) Count(ctx context.Context, key string) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w, ok := q.windows[key]; ok && time.Now().Before(w.expires) {
+		return w.count, nil
+	}
+	return 0, nil
+}
+
+// tenantQuota is one of a tenant's quotas and the fixed window it is
+// counted in at a given moment
+type tenantQuota struct {
+	name  string
+	limit int
+	start time.Time
+	size  time.Duration
+}
+
+// quotas returns the tenant's per-minute and per-day quotas at now; a zero
+// limit means none
+func (t *Tenant) quotas(now time.Time) []tenantQuota {
+	return []tenantQuota{
+		{"minute", t.RequestsPerMinute, now.Truncate(time.Minute), time.Minute},
+		{"day", t.RequestsPerDay, now.Truncate(24 * time.Hour), 24 * time.Hour},
+	}
+}
+
+func (w tenantQuota) key(tenant *Tenant) string {
+	return fmt.Sprintf("%s:%s:%d", tenant.ID, w.name, w.start.Unix())
+}
+
+// overQuota counts a search against the tenant's per-minute and per-day
+// quotas. Once either is exhausted it sets Retry-After, writes the rejection
+// as an SSE error event or a 429 and returns true. Counter failures let the
+// search through.
+func (g *Gateway) overQuota(c *gin.Context, sse bool) bool {
+	message, retryAfter, exceeded := g.chargeQuota(c)
+	if !exceeded {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	if sse {
+		c.SSEvent("error", gin.H{"message": message, "retry_after": retryAfter})
+	} else {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": retryAfter})
+	}
+	return true
+}
+
+// chargeQuota counts a search against the tenant's quotas, returning the
+// rejection and the seconds until the exhausted window resets once either
+// is exhausted
+func (g *Gateway) chargeQuota(c *gin.Context) (message string, retryAfter int, exceeded bool) {
+	return g.chargeTenantQuota(c.Request.Context(), g.tenant(c))
+}
+
+// chargeTenantQuota is chargeQuota for searches run outside a request
+func (g *Gateway) chargeTenantQuota(ctx context.Context, tenant *Tenant) (message string, retryAfter int, exceeded bool) {
+	if g.quotas == nil || (tenant.RequestsPerMinute <= 0 && tenant.RequestsPerDay <= 0) {
+		return "", 0, false
+	}
+	now := time.Now().UTC()
+	for _, w := range tenant.quotas(now) {
+		if w.limit <= 0 {
+			continue
+		}
+		count, err := g.quotas.Incr(ctx, w.key(tenant), w.size)
+		if err != nil {
+			logger.GetLogger().Warnf("Quota check failed for tenant %s: %v", tenant.ID, err)
+			return "", 0, false
+		}
+		if count <= int64(w.limit) {
+			continue
+		}
+		monitoring.RecordQuotaRejection(tenant.ID, w.name)
+		monitoring.RecordRequest("gateway", "search", "rejected")
+		retryAfter = int(w.start.Add(w.size).Sub(now).Seconds()) + 1
+		return fmt.Sprintf("Quota of %d searches per %s exceeded", w.limit, w.name), retryAfter, true
+	}
+	return "", 0, false
+}
+
+// QuotaUsage is how much of one of its quotas a tenant has used
+type QuotaUsage struct {
+	Window    string    `json:"window"` // minute or day
+	Limit     int       `json:"limit"`
+	Used      int64     `json:"used"` // includes searches rejected for exceeding it
+	Remaining int64     `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Usage reports the calling tenant's quota usage (GET /api/v1/usage).
+// Tenants without quotas, and gateways without multi-tenancy, have no
+// windows to report.
+func (g *Gateway) Usage(c *gin.Context) {
+	windows, err := g.quotaUsage(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read usage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tenant": g.tenant(c).ID, "quotas": windows})
+}
+
+// quotaUsage reads the calling tenant's usage of each of its quotas
+func (g *Gateway) quotaUsage(c *gin.Context) ([]QuotaUsage, error) {
+	tenant := g.tenant(c)
+	windows := []QuotaUsage{}
+	if g.quotas == nil {
+		return windows, nil
+	}
+	now := time.Now().UTC()
+	for _, w := range tenant.quotas(now) {
+		if w.limit <= 0 {
+			continue
+		}
+		used, err := g.quotas.Count(c.Request.Context(), w.key(tenant))
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to read quota usage of tenant %s: %v", tenant.ID, err)
+			return nil, err
+		}
+		windows = append(windows, QuotaUsage{
+			Window:    w.name,
+			Limit:     w.limit,
+			Used:      used,
+			Remaining: max(int64(w.limit)-used, 0),
+			ResetsAt:  w.start.Add(w.size),
+		})
+	}
+	return windows, nil
+}
@@ -0,0 +1,367 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FieldError is one reason a request was rejected: the parameter or body
+// field at fault, e.g. "num_results", "sampling.temperature" or
+// "documents[1].content", and what is wrong with it
+type FieldError struct {
+	Field   string `json:"field,omitempty"` // empty for the body as a whole
+	In      string `json:"in"`              // query, path or body
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + " " + e.Message
+}
+
+// rejectFields writes a 400 listing every field error, with "error"
+// summarizing them for clients that only read that. Event stream requests
+// get it as an error event.
+func rejectFields(c *gin.Context, errs []FieldError) {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.String()
+	}
+	message := "Invalid request: " + strings.Join(messages, "; ")
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		c.Status(http.StatusBadRequest)
+		c.SSEvent("error", gin.H{"message": message, "errors": errs})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": message, "errors": errs})
+}
+
+// operation is what the spec says an endpoint accepts
+type operation struct {
+	params []parameter
+	body   map[string]interface{} // JSON request body schema; nil when there is none
+}
+
+// parameter is a query or path parameter
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	schema   map[string]interface{}
+}
+
+// indexOperations keys the spec's operations by method and gin route, e.g.
+// "GET /api/v1/search/:task_id/export"
+func (s *apiSpec) indexOperations() map[string]*operation {
+	operations := make(map[string]*operation)
+	paths, _ := s.doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		item, _ := item.(map[string]interface{})
+		route := ginRoute(path)
+		for method, op := range item {
+			op, ok := op.(map[string]interface{})
+			if !ok || method == "parameters" {
+				continue
+			}
+			o := &operation{}
+			for _, list := range []interface{}{item["parameters"], op["parameters"]} {
+				params, _ := list.([]interface{})
+				for _, p := range params {
+					p := s.resolve(p)
+					in, _ := p["in"].(string)
+					if in != "query" && in != "path" {
+						continue
+					}
+					name, _ := p["name"].(string)
+					required, _ := p["required"].(bool)
+					schema, _ := p["schema"].(map[string]interface{})
+					o.params = append(o.params, parameter{name: name, in: in, required: required, schema: schema})
+				}
+			}
+			if body := s.resolve(op["requestBody"]); body != nil {
+				content, _ := body["content"].(map[string]interface{})
+				media, _ := content["application/json"].(map[string]interface{})
+				o.body, _ = media["schema"].(map[string]interface{})
+			}
+			operations[strings.ToUpper(method)+" "+route] = o
+		}
+	}
+	return operations
+}
+
+// ginRoute turns an OpenAPI path template into the gin route serving it
+func ginRoute(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			segments[i] = ":" + s[1:len(s)-1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// requestOperation is the spec's operation for the request's route, nil
+// when it has none
+func requestOperation(c *gin.Context) (*apiSpec, *operation) {
+	spec, err := loadAPISpec()
+	if err != nil {
+		return nil, nil
+	}
+	return spec, spec.operations[c.Request.Method+" "+c.FullPath()]
+}
+
+// validateParams rejects requests whose query or path parameters do not
+// match the operation's parameters in the spec: a required one missing, a
+// number that does not parse or is out of range, a value not in an enum.
+// Parameters the spec does not know are ignored.
+func (g *Gateway) validateParams(c *gin.Context) {
+	spec, op := requestOperation(c)
+	if op == nil {
+		return
+	}
+	var errs []FieldError
+	for _, p := range op.params {
+		var raw string
+		var present bool
+		if p.in == "path" {
+			raw = c.Param(p.name)
+			present = raw != ""
+		} else {
+			raw, present = c.GetQuery(p.name)
+		}
+		if !present {
+			if p.required {
+				errs = append(errs, FieldError{Field: p.name, In: p.in, Message: "is required"})
+			}
+			continue
+		}
+		value, err := paramValue(raw, spec.resolve(p.schema))
+		if err != nil {
+			errs = append(errs, FieldError{Field: p.name, In: p.in, Message: err.Error()})
+			continue
+		}
+		errs = spec.check(p.schema, value, p.name, p.in, errs)
+	}
+	if len(errs) > 0 {
+		rejectFields(c, errs)
+		c.Abort()
+	}
+}
+
+// paramValue converts a parameter to the JSON type of its schema
+func paramValue(raw string, schema map[string]interface{}) (interface{}, error) {
+	switch schema["type"] {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		return json.Number(raw), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		switch raw {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, errors.New("must be true or false")
+	}
+	return raw, nil
+}
+
+// validateBody checks a JSON request body against the operation's schema
+func validateBody(c *gin.Context, body []byte) []FieldError {
+	spec, op := requestOperation(c)
+	if op == nil || op.body == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []FieldError{{In: "body", Message: "a JSON body is required"}}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []FieldError{{In: "body", Message: "is not valid JSON: " + err.Error()}}
+	}
+	return spec.check(op.body, value, "", "body", nil)
+}
+
+// bindingErrors turns a decoding or binding failure the schema did not
+// catch into field errors
+func bindingErrors(err error) []FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []FieldError{{Field: typeErr.Field, In: "body", Message: "has the wrong type: " + typeErr.Value}}
+	}
+	return []FieldError{{In: "body", Message: err.Error()}}
+}
+
+// resolve follows a local $ref ("#/components/schemas/Tenant")
+func (s *apiSpec) resolve(node interface{}) map[string]interface{} {
+	m, _ := node.(map[string]interface{})
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return m
+	}
+	var target interface{} = s.doc
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		parent, _ := target.(map[string]interface{})
+		target = parent[token]
+	}
+	return s.resolve(target)
+}
+
+// check validates a JSON value, decoded with UseNumber, against a schema,
+// appending an error for each violation. It covers the keywords the spec
+// uses: type, required, properties, additionalProperties, items, allOf,
+// enum, minimum, maximum, minLength, maxLength, minItems and maxItems.
+// Null is accepted for any field, as the handlers treat it as absent.
+func (s *apiSpec) check(schema map[string]interface{}, value interface{}, field, in string, errs []FieldError) []FieldError {
+	schema = s.resolve(schema)
+	if schema == nil || value == nil {
+		return errs
+	}
+	fail := func(format string, args ...interface{}) []FieldError {
+		return append(errs, FieldError{Field: field, In: in, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, sub := range asList(schema["allOf"]) {
+		errs = s.check(s.resolve(sub), value, field, in, errs)
+	}
+
+	switch schema["type"] {
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fail("must be an object")
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return fail("must be an array")
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fail("must be a string")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be true or false")
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			return fail("must be an integer")
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return fail("must be a number")
+		}
+	}
+
+	if enum := asList(schema["enum"]); len(enum) > 0 {
+		allowed := make([]string, len(enum))
+		found := false
+		for i, e := range enum {
+			allowed[i] = fmt.Sprint(e)
+			found = found || allowed[i] == fmt.Sprint(value)
+		}
+		if !found {
+			return fail("must be one of %s", strings.Join(allowed, ", "))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range asList(schema["required"]) {
+			if name, ok := name.(string); ok {
+				if _, present := v[name]; !present {
+					errs = append(errs, FieldError{Field: joinField(field, name), In: in, Message: "is required"})
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				errs = s.check(property, v[name], joinField(field, name), in, errs)
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				errs = s.check(additional, v[name], joinField(field, name), in, errs)
+			} else if schema["additionalProperties"] == false {
+				errs = append(errs, FieldError{Field: joinField(field, name), In: in, Message: "is not a known field"})
+			}
+		}
+	case []interface{}:
+		if min, ok := asNumber(schema["minItems"]); ok && float64(len(v)) < min {
+			errs = fail("must have at least %g items", min)
+		}
+		if max, ok := asNumber(schema["maxItems"]); ok && float64(len(v)) > max {
+			errs = fail("must have at most %g items", max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = s.check(items, item, fmt.Sprintf("%s[%d]", field, i), in, errs)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := asNumber(schema["minLength"]); ok && length < min {
+			if min == 1 {
+				return fail("must not be empty")
+			}
+			return fail("must be at least %g characters", min)
+		}
+		if max, ok := asNumber(schema["maxLength"]); ok && length > max {
+			return fail("must be at most %g characters", max)
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if min, ok := asNumber(schema["minimum"]); ok && n < min {
+			return fail("must be at least %g", min)
+		}
+		if max, ok := asNumber(schema["maximum"]); ok && n > max {
+			return fail("must be at most %g", max)
+		}
+	}
+	return errs
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func asList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// asNumber reads a numeric keyword, which YAML decodes as int or float64
+func asNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,556 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Operation is a validated request with its variables coerced, ready to
+// execute or subscribe to
+type Operation struct {
+	schema *Schema
+	doc    *document
+	def    *operationDef
+	vars   map[string]interface{}
+}
+
+// Prepare parses and validates a request and coerces its variables. The
+// errors are the response to send when it fails.
+func (s *Schema) Prepare(req *Request) (*Operation, []*Error) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return nil, []*Error{err}
+	}
+	def, errs := (&validator{schema: s, doc: doc}).validate(req.OperationName)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	vars := make(map[string]interface{})
+	for _, vd := range def.vars {
+		provided, ok := req.Variables[vd.name]
+		var err error
+		switch {
+		case ok:
+			if provided, err = coerceInput(provided, vd.resolved); err == nil {
+				vars[vd.name] = provided
+			}
+		case vd.def != nil:
+			vars[vd.name], err = coerceLiteral(vd.def, vd.resolved, nil)
+		case isNonNull(vd.resolved):
+			err = fmt.Errorf("of required type %q was not provided", vd.typ.String())
+		}
+		if err != nil {
+			errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" %v.", vd.name, err), Locations: []Location{vd.loc}})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return &Operation{schema: s, doc: doc, def: def, vars: vars}, nil
+}
+
+// Kind is query or subscription
+func (o *Operation) Kind() string { return o.def.kind }
+
+// Name is the operation's name, empty for an anonymous one
+func (o *Operation) Name() string { return o.def.name }
+
+// Execute runs a query. Its root fields are resolved one after another, so
+// resolvers may share request state.
+func (o *Operation) Execute(ctx context.Context) *Response {
+	if o.def.kind != "query" {
+		return &Response{Errors: []*Error{{Message: "A " + o.def.kind + " cannot be executed as a query.", Locations: []Location{o.def.loc}}}}
+	}
+	e := &executor{ctx: ctx, op: o}
+	data, ok := e.selectionSet(o.schema.Query, nil, o.def.selections, nil)
+	if !ok {
+		return e.response(nil)
+	}
+	return e.response(data)
+}
+
+// Subscribe starts a subscription's event stream and returns a response
+// per event. The channel is closed when the stream ends or ctx is done.
+func (o *Operation) Subscribe(ctx context.Context) (<-chan *Response, []*Error) {
+	root := o.schema.Subscription
+	if o.def.kind != "subscription" || root == nil {
+		return nil, []*Error{{Message: "A " + o.def.kind + " has no event stream.", Locations: []Location{o.def.loc}}}
+	}
+	e := &executor{ctx: ctx, op: o}
+	groups := e.collect(root, o.def.selections, nil, make(map[string]bool))
+	if len(groups) == 0 {
+		return nil, []*Error{{Message: "The subscription selects no field.", Locations: []Location{o.def.loc}}}
+	}
+	group := groups[0]
+	f := group.fields[0]
+	def := root.Field(f.name)
+	path := []interface{}{group.key}
+	args, err := e.args(def, f)
+	if err == nil && def.Subscribe == nil {
+		err = fmt.Errorf("Subscription field %q has no event stream", def.Name)
+	}
+	var events <-chan interface{}
+	if err == nil {
+		events, err = def.Subscribe(ResolveParams{Context: ctx, Args: args, Path: path})
+	}
+	if err != nil {
+		e.fail(err, f.loc, path)
+		return nil, e.errs
+	}
+
+	out := make(chan *Response)
+	go func() {
+		defer close(out)
+		for event := range events {
+			e := &executor{ctx: ctx, op: o}
+			var data interface{}
+			if value, ok := e.complete(def.Type, group.fields, event, path); ok {
+				data = object{{group.key, value}}
+			}
+			select {
+			case out <- e.response(data):
+			case <-ctx.Done():
+				for range events {
+					// let the source finish
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// executor executes one operation, or one event of a subscription,
+// collecting the field errors
+type executor struct {
+	ctx  context.Context
+	op   *Operation
+	errs []*Error
+}
+
+func (e *executor) response(data interface{}) *Response {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		e.errs = append(e.errs, &Error{Message: "Failed to encode the result: " + err.Error()})
+		raw = []byte("null")
+	}
+	return &Response{Data: raw, Errors: e.errs}
+}
+
+// fail records a field error at the field's location and path
+func (e *executor) fail(err error, loc Location, path []interface{}) {
+	gqlErr := &Error{Message: err.Error()}
+	var resolverErr *Error
+	if errors.As(err, &resolverErr) {
+		copied := *resolverErr
+		gqlErr = &copied
+	}
+	if len(gqlErr.Locations) == 0 {
+		gqlErr.Locations = []Location{loc}
+	}
+	if gqlErr.Path == nil {
+		gqlErr.Path = append([]interface{}(nil), path...)
+	}
+	e.errs = append(e.errs, gqlErr)
+}
+
+// fieldGroup is the fields that make one entry of the response
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+func addField(groups []*fieldGroup, f *field) []*fieldGroup {
+	for _, g := range groups {
+		if g.key == f.key() {
+			g.fields = append(g.fields, f)
+			return groups
+		}
+	}
+	return append(groups, &fieldGroup{key: f.key(), fields: []*field{f}})
+}
+
+// collect groups the fields selected on obj by response key, in order,
+// through fragments and leaving out those @skip or @include drop
+func (e *executor) collect(obj *Object, selections []selection, groups []*fieldGroup, visited map[string]bool) []*fieldGroup {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if e.included(sel.directives) {
+				groups = addField(groups, sel)
+			}
+		case *fragmentSpread:
+			if visited[sel.name] || !e.included(sel.directives) {
+				continue
+			}
+			visited[sel.name] = true
+			if f := e.op.doc.fragments[sel.name]; f != nil && f.on == obj.Name {
+				groups = e.collect(obj, f.selections, groups, visited)
+			}
+		case *inlineFragment:
+			if e.included(sel.directives) && (sel.on == "" || sel.on == obj.Name) {
+				groups = e.collect(obj, sel.selections, groups, visited)
+			}
+		}
+	}
+	return groups
+}
+
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		if len(d.args) == 0 {
+			continue
+		}
+		v, _ := coerceLiteral(d.args[0].value, NonNull(Boolean), e.op.vars)
+		if cond, _ := v.(bool); cond == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// selectionSet resolves the fields selected on obj; false means a
+// non-null field was null and the whole object must be
+func (e *executor) selectionSet(obj *Object, source interface{}, selections []selection, path []interface{}) (object, bool) {
+	groups := e.collect(obj, selections, nil, make(map[string]bool))
+	out := make(object, 0, len(groups))
+	for _, g := range groups {
+		fieldPath := append(path[:len(path):len(path)], g.key)
+		value, ok := e.field(obj, source, g, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, entry{g.key, value})
+	}
+	return out, true
+}
+
+func (e *executor) field(obj *Object, source interface{}, g *fieldGroup, path []interface{}) (interface{}, bool) {
+	f := g.fields[0]
+	if f.name == "__typename" {
+		return obj.Name, true
+	}
+	def := obj.Field(f.name)
+	args, err := e.args(def, f)
+	var value interface{}
+	if err == nil {
+		value, err = resolve(def, ResolveParams{Context: e.ctx, Source: source, Args: args, Path: path})
+	}
+	if err != nil {
+		e.fail(err, f.loc, path)
+		return nil, !isNonNull(def.Type)
+	}
+	return e.complete(def.Type, g.fields, value, path)
+}
+
+// resolve calls the field's resolver, turning a panic into a field error
+func resolve(def *Field, p ResolveParams) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, fmt.Errorf("internal error resolving %s: %v", def.Name, r)
+		}
+	}()
+	if def.Resolve != nil {
+		return def.Resolve(p)
+	}
+	return defaultResolve(p.Source, def.Name)
+}
+
+// complete converts a resolved value to its response form; false means it
+// is null where the type does not allow it, so the parent must be null
+func (e *executor) complete(t Type, fields []*field, value interface{}, path []interface{}) (interface{}, bool) {
+	nn, required := t.(*nonNull)
+	if required {
+		t = nn.of
+	}
+	v, ok := e.completeValue(t, fields, value, path)
+	if ok && v == nil && required {
+		e.fail(fmt.Errorf("Cannot return null for non-nullable field %q", fields[0].name), fields[0].loc, path)
+		ok = false
+	}
+	if !ok {
+		return nil, !required
+	}
+	return v, true
+}
+
+func (e *executor) completeValue(t Type, fields []*field, value interface{}, path []interface{}) (interface{}, bool) {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, true
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *list:
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fail(fmt.Errorf("Expected a list for field %q, got %T", fields[0].name, value), fields[0].loc, path)
+			return nil, false
+		}
+		items := make([]interface{}, rv.Len()) // a nil slice is an empty list
+		for i := range items {
+			item, ok := e.complete(t.of, fields, rv.Index(i).Interface(), append(path[:len(path):len(path)], i))
+			if !ok {
+				return nil, false
+			}
+			items[i] = item
+		}
+		return items, true
+	case *Scalar:
+		v, err := t.Serialize(rv.Interface())
+		if err != nil {
+			e.fail(err, fields[0].loc, path)
+			return nil, false
+		}
+		return v, true
+	case *Enum:
+		name := fmt.Sprint(rv.Interface())
+		if !t.has(name) {
+			e.fail(fmt.Errorf("Enum %q cannot represent value %q", t.Name, name), fields[0].loc, path)
+			return nil, false
+		}
+		return name, true
+	case *Object:
+		var selections []selection
+		for _, f := range fields {
+			selections = append(selections, f.selections...)
+		}
+		return e.selectionSet(t, value, selections, path)
+	}
+	return nil, true
+}
+
+// args coerces a field's arguments, filling in defaults
+func (e *executor) args(def *Field, f *field) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(def.Args))
+	for _, argDef := range def.Args {
+		var node *value
+		for _, arg := range f.args {
+			if arg.name == argDef.Name {
+				node = arg.value
+			}
+		}
+		if node != nil && node.kind == variableValue {
+			if _, ok := e.op.vars[node.raw]; !ok {
+				node = nil // an omitted variable leaves the argument out
+			}
+		}
+		if node == nil {
+			if argDef.Default != nil {
+				args[argDef.Name] = argDef.Default
+			} else if isNonNull(argDef.Type) {
+				return nil, fmt.Errorf("Argument %q of required type %q was not provided", argDef.Name, argDef.Type)
+			}
+			continue
+		}
+		v, err := coerceLiteral(node, argDef.Type, e.op.vars)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %q: %v", argDef.Name, err)
+		}
+		args[argDef.Name] = v
+	}
+	return args, nil
+}
+
+// coerceLiteral converts a literal to the value of type t, reading
+// variables, which are already coerced, from vars
+func coerceLiteral(val *value, t Type, vars map[string]interface{}) (interface{}, error) {
+	if val.kind == variableValue {
+		v := vars[val.raw]
+		if v == nil && isNonNull(t) {
+			return nil, fmt.Errorf("Expected value of type %q, found null", t)
+		}
+		return v, nil
+	}
+	if nn, ok := t.(*nonNull); ok {
+		if val.kind == nullValue {
+			return nil, fmt.Errorf("Expected value of type %q, found null", t)
+		}
+		return coerceLiteral(val, nn.of, vars)
+	}
+	if val.kind == nullValue {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *list:
+		if val.kind != listValue {
+			v, err := coerceLiteral(val, t.of, vars)
+			return []interface{}{v}, err
+		}
+		items := make([]interface{}, len(val.list))
+		for i, item := range val.list {
+			v, err := coerceLiteral(item, t.of, vars)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = v
+		}
+		return items, nil
+	case *Enum:
+		if val.kind != enumValue || !t.has(val.raw) {
+			return nil, fmt.Errorf("Value %s does not exist in %q enum", val, t.Name)
+		}
+		return val.raw, nil
+	case *Scalar:
+		var input interface{}
+		switch val.kind {
+		case intValue, floatValue:
+			input = json.Number(val.raw)
+		case stringValue:
+			input = val.raw
+		case booleanValue:
+			input = val.raw == "true"
+		default:
+			return nil, fmt.Errorf("Expected value of type %q, found %s", t.Name, val)
+		}
+		v, err := t.Parse(input)
+		if err != nil {
+			return nil, fmt.Errorf("Expected value of type %q, found %s; %v", t.Name, val, err)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("Expected value of type %q, found %s", t, val)
+}
+
+// coerceInput converts a variable's JSON value to the value of type t
+func coerceInput(v interface{}, t Type) (interface{}, error) {
+	if nn, ok := t.(*nonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("of non-null type %q must not be null", t)
+		}
+		return coerceInput(v, nn.of)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *list:
+		values, ok := v.([]interface{})
+		if !ok {
+			item, err := coerceInput(v, t.of)
+			return []interface{}{item}, err
+		}
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			item, err := coerceInput(value, t.of)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case *Enum:
+		if name, ok := v.(string); ok && t.has(name) {
+			return name, nil
+		}
+		return nil, fmt.Errorf("got invalid value %v; Value does not exist in %q enum", v, t.Name)
+	case *Scalar:
+		value, err := t.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("got invalid value %v; %v", v, err)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("has a type that is not an input type")
+}
+
+func isNonNull(t Type) bool {
+	_, ok := t.(*nonNull)
+	return ok
+}
+
+// defaultResolve reads a field from a map or struct parent
+func defaultResolve(source interface{}, name string) (interface{}, error) {
+	if m, ok := source.(map[string]interface{}); ok {
+		return m[name], nil
+	}
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot read field %q of %T", name, source)
+	}
+	index, ok := structFields(rv.Type())[foldName(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s has no field matching %q", rv.Type(), name)
+	}
+	field, err := rv.FieldByIndexErr(index)
+	if err != nil {
+		return nil, nil // through a nil embedded pointer
+	}
+	return field.Interface(), nil
+}
+
+var fieldIndexes sync.Map // reflect.Type to map[string][]int
+
+// structFields indexes a struct type's exported fields by their folded
+// json name and folded Go name, the json name winning
+func structFields(t reflect.Type) map[string][]int {
+	if cached, ok := fieldIndexes.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	byName := make(map[string][]int)
+	fields := reflect.VisibleFields(t)
+	for _, f := range fields {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" && tag != "-" {
+			byName[foldName(tag)] = f.Index
+		}
+	}
+	for _, f := range fields {
+		if _, taken := byName[foldName(f.Name)]; f.IsExported() && !f.Anonymous && !taken && f.Tag.Get("json") != "-" {
+			byName[foldName(f.Name)] = f.Index
+		}
+	}
+	fieldIndexes.Store(t, byName)
+	return byName
+}
+
+func foldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// object is a result object, which keeps its fields in selection order
+type object []entry
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, e := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(e.key)
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,648 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed request: its operations and fragments
+type document struct {
+	operations []*operationDef
+	fragments  map[string]*fragmentDef
+}
+
+type operationDef struct {
+	kind       string // query, mutation or subscription
+	name       string
+	vars       []*varDef
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type varDef struct {
+	name     string
+	typ      *typeRef
+	def      *value // nil when there is no default
+	loc      Location
+	resolved Type // the schema type named by typ, once validated
+}
+
+// typeRef is a type as written in a variable definition, e.g. [String!]!
+type typeRef struct {
+	name    string
+	list    *typeRef // element type of a list; name is empty then
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.list != nil {
+		s = "[" + t.list.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+// key is the name of the field in the response
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	on         string // empty without a type condition
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type fragmentDef struct {
+	name       string
+	on         string
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type argument struct {
+	name  string
+	value *value
+	loc   Location
+}
+
+type directive struct {
+	name string
+	args []*argument
+	loc  Location
+}
+
+type valueKind int
+
+const (
+	variableValue valueKind = iota
+	intValue
+	floatValue
+	stringValue
+	booleanValue
+	nullValue
+	enumValue
+	listValue
+	objectValue
+)
+
+// value is a literal or variable in a document
+type value struct {
+	kind   valueKind
+	raw    string // the number, string, boolean, enum value or variable name
+	list   []*value
+	fields []*argument // of an object value
+	loc    Location
+}
+
+func (v *value) String() string {
+	switch v.kind {
+	case variableValue:
+		return "$" + v.raw
+	case stringValue:
+		return strconv.Quote(v.raw)
+	case nullValue:
+		return "null"
+	case listValue:
+		items := make([]string, len(v.list))
+		for i, item := range v.list {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case objectValue:
+		fields := make([]string, len(v.fields))
+		for i, f := range v.fields {
+			fields[i] = f.name + ": " + f.value.String()
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return v.raw
+}
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	punctToken
+	nameToken
+	intToken
+	floatToken
+	stringToken
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	loc   Location
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case eofToken:
+		return "end of document"
+	case stringToken:
+		return strconv.Quote(t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// syntaxError aborts parsing; parse recovers it
+type syntaxError struct{ err *Error }
+
+// parser is a recursive descent parser of executable documents, reading
+// one token ahead
+type parser struct {
+	src  string
+	pos  int
+	line int
+	col  int // of pos, 1-based, in runes
+	tok  token
+}
+
+// parse parses an executable document: operations and fragments, without
+// type system definitions
+func parse(src string) (doc *document, err *Error) {
+	p := &parser{src: src, line: 1, col: 1}
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, se.err
+		}
+	}()
+	p.next()
+	doc = &document{fragments: make(map[string]*fragmentDef)}
+	if p.tok.kind == eofToken {
+		p.fail(p.tok.loc, "Syntax Error: the document contains no operations")
+	}
+	for p.tok.kind != eofToken {
+		switch {
+		case p.peek(punctToken, "{"):
+			doc.operations = append(doc.operations, &operationDef{kind: "query", loc: p.tok.loc, selections: p.selectionSet()})
+		case p.peek(nameToken, "query"), p.peek(nameToken, "mutation"), p.peek(nameToken, "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.peek(nameToken, "fragment"):
+			f := p.fragment()
+			if _, dup := doc.fragments[f.name]; dup {
+				p.fail(f.loc, "There can be only one fragment named %q", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.unexpected()
+		}
+	}
+	return doc, nil
+}
+
+func (p *parser) fail(loc Location, format string, args ...interface{}) {
+	panic(syntaxError{&Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}}})
+}
+
+func (p *parser) unexpected() {
+	p.fail(p.tok.loc, "Syntax Error: unexpected %s", p.tok)
+}
+
+// peek reports whether the current token is kind with value
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// skip consumes the current token if it is kind with value
+func (p *parser) skip(kind tokenKind, value string) bool {
+	if p.peek(kind, value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(value string) {
+	if !p.skip(punctToken, value) {
+		p.fail(p.tok.loc, "Syntax Error: expected %q, found %s", value, p.tok)
+	}
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != nameToken {
+		p.fail(p.tok.loc, "Syntax Error: expected a name, found %s", p.tok)
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+func (p *parser) operation() *operationDef {
+	op := &operationDef{kind: p.tok.value, loc: p.tok.loc}
+	p.next()
+	if p.tok.kind == nameToken {
+		op.name = p.name()
+	}
+	if p.skip(punctToken, "(") {
+		for !p.skip(punctToken, ")") {
+			v := &varDef{loc: p.tok.loc}
+			p.expect("$")
+			v.name = p.name()
+			p.expect(":")
+			v.typ = p.typeRef()
+			if p.skip(punctToken, "=") {
+				v.def = p.value(true)
+			}
+			op.vars = append(op.vars, v)
+		}
+	}
+	op.directives = p.directives(false)
+	op.selections = p.selectionSet()
+	return op
+}
+
+func (p *parser) fragment() *fragmentDef {
+	f := &fragmentDef{loc: p.tok.loc}
+	p.next()
+	if p.peek(nameToken, "on") {
+		p.fail(p.tok.loc, "Syntax Error: a fragment cannot be named \"on\"")
+	}
+	f.name = p.name()
+	if !p.skip(nameToken, "on") {
+		p.fail(p.tok.loc, "Syntax Error: expected \"on\", found %s", p.tok)
+	}
+	f.on = p.name()
+	f.directives = p.directives(false)
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) typeRef() *typeRef {
+	t := &typeRef{}
+	if p.skip(punctToken, "[") {
+		t.list = p.typeRef()
+		p.expect("]")
+	} else {
+		t.name = p.name()
+	}
+	t.nonNull = p.skip(punctToken, "!")
+	return t
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect("{")
+	var selections []selection
+	for !p.skip(punctToken, "}") {
+		selections = append(selections, p.selection())
+	}
+	if len(selections) == 0 {
+		p.fail(p.tok.loc, "Syntax Error: a selection set cannot be empty")
+	}
+	return selections
+}
+
+func (p *parser) selection() selection {
+	loc := p.tok.loc
+	if p.skip(punctToken, "...") {
+		if p.tok.kind == nameToken && p.tok.value != "on" {
+			return &fragmentSpread{name: p.name(), directives: p.directives(false), loc: loc}
+		}
+		f := &inlineFragment{loc: loc}
+		if p.skip(nameToken, "on") {
+			f.on = p.name()
+		}
+		f.directives = p.directives(false)
+		f.selections = p.selectionSet()
+		return f
+	}
+
+	f := &field{name: p.name(), loc: loc}
+	if p.skip(punctToken, ":") {
+		f.alias, f.name = f.name, p.name()
+	}
+	f.args = p.arguments(false)
+	f.directives = p.directives(false)
+	if p.peek(punctToken, "{") {
+		f.selections = p.selectionSet()
+	}
+	return f
+}
+
+func (p *parser) arguments(constant bool) []*argument {
+	if !p.skip(punctToken, "(") {
+		return nil
+	}
+	var args []*argument
+	for !p.skip(punctToken, ")") {
+		arg := &argument{loc: p.tok.loc, name: p.name()}
+		p.expect(":")
+		arg.value = p.value(constant)
+		args = append(args, arg)
+	}
+	return args
+}
+
+func (p *parser) directives(constant bool) []*directive {
+	var directives []*directive
+	for p.peek(punctToken, "@") {
+		d := &directive{loc: p.tok.loc}
+		p.next()
+		d.name = p.name()
+		d.args = p.arguments(constant)
+		directives = append(directives, d)
+	}
+	return directives
+}
+
+// value parses a value; constant ones, such as variable defaults, cannot
+// contain variables
+func (p *parser) value(constant bool) *value {
+	v := &value{loc: p.tok.loc, raw: p.tok.value}
+	switch p.tok.kind {
+	case punctToken:
+		switch p.tok.value {
+		case "$":
+			if constant {
+				p.fail(v.loc, "Syntax Error: unexpected variable in a constant value")
+			}
+			p.next()
+			v.kind, v.raw = variableValue, p.name()
+			return v
+		case "[":
+			p.next()
+			v.kind = listValue
+			for !p.skip(punctToken, "]") {
+				v.list = append(v.list, p.value(constant))
+			}
+			return v
+		case "{":
+			p.next()
+			v.kind = objectValue
+			for !p.skip(punctToken, "}") {
+				f := &argument{loc: p.tok.loc, name: p.name()}
+				p.expect(":")
+				f.value = p.value(constant)
+				v.fields = append(v.fields, f)
+			}
+			return v
+		}
+		p.unexpected()
+	case intToken:
+		v.kind = intValue
+	case floatToken:
+		v.kind = floatValue
+	case stringToken:
+		v.kind = stringValue
+	case nameToken:
+		switch p.tok.value {
+		case "true", "false":
+			v.kind = booleanValue
+		case "null":
+			v.kind = nullValue
+		default:
+			v.kind = enumValue
+		}
+	default:
+		p.unexpected()
+	}
+	p.next()
+	return v
+}
+
+// next reads the next token into p.tok, skipping whitespace, commas and
+// comments
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			p.pos++
+			p.line, p.col = p.line+1, 1
+			continue
+		case c == '\r':
+			p.pos++
+			if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+				p.pos++
+			}
+			p.line, p.col = p.line+1, 1
+			continue
+		case c == ' ' || c == '\t' || c == ',':
+			p.advance(1)
+			continue
+		case strings.HasPrefix(p.src[p.pos:], "\ufeff"):
+			p.pos += len("\ufeff") // byte order mark
+			continue
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.advance(1)
+			}
+			continue
+		}
+		break
+	}
+
+	loc := Location{Line: p.line, Column: p.col}
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: eofToken, loc: loc}
+		return
+	}
+	rest := p.src[p.pos:]
+	c := rest[0]
+	switch {
+	case strings.HasPrefix(rest, "..."):
+		p.advance(3)
+		p.tok = token{kind: punctToken, value: "...", loc: loc}
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		p.advance(1)
+		p.tok = token{kind: punctToken, value: string(c), loc: loc}
+	case c == '_' || isLetter(c):
+		n := 1
+		for n < len(rest) && (rest[n] == '_' || isLetter(rest[n]) || isDigit(rest[n])) {
+			n++
+		}
+		p.advance(n)
+		p.tok = token{kind: nameToken, value: rest[:n], loc: loc}
+	case c == '-' || isDigit(c):
+		p.number(loc)
+	case strings.HasPrefix(rest, `"""`):
+		p.blockString(loc)
+	case c == '"':
+		p.string(loc)
+	default:
+		r, _ := utf8.DecodeRuneInString(rest)
+		p.fail(loc, "Syntax Error: unexpected character %q", r)
+	}
+}
+
+// advance moves past n bytes of the current line
+func (p *parser) advance(n int) {
+	p.col += utf8.RuneCountInString(p.src[p.pos : p.pos+n])
+	p.pos += n
+}
+
+func (p *parser) number(loc Location) {
+	rest := p.src[p.pos:]
+	n := 0
+	digits := func() int {
+		start := n
+		for n < len(rest) && isDigit(rest[n]) {
+			n++
+		}
+		return n - start
+	}
+	if rest[n] == '-' {
+		n++
+	}
+	if count := digits(); count == 0 || (count > 1 && rest[n-count] == '0') {
+		p.fail(loc, "Syntax Error: invalid number %q", rest[:n])
+	}
+	kind := intToken
+	if n < len(rest) && rest[n] == '.' {
+		kind = floatToken
+		n++
+		if digits() == 0 {
+			p.fail(loc, "Syntax Error: invalid number %q", rest[:n])
+		}
+	}
+	if n < len(rest) && (rest[n] == 'e' || rest[n] == 'E') {
+		kind = floatToken
+		n++
+		if n < len(rest) && (rest[n] == '+' || rest[n] == '-') {
+			n++
+		}
+		if digits() == 0 {
+			p.fail(loc, "Syntax Error: invalid number %q", rest[:n])
+		}
+	}
+	if n < len(rest) && (rest[n] == '.' || rest[n] == '_' || isLetter(rest[n])) {
+		p.fail(loc, "Syntax Error: invalid number %q", rest[:n+1])
+	}
+	p.advance(n)
+	p.tok = token{kind: kind, value: rest[:n], loc: loc}
+}
+
+func (p *parser) string(loc Location) {
+	var b strings.Builder
+	p.advance(1)
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.fail(loc, "Syntax Error: unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.advance(1)
+			p.tok = token{kind: stringToken, value: b.String(), loc: loc}
+			return
+		case c == '\\' && p.pos+1 < len(p.src):
+			escape := p.src[p.pos+1]
+			if replacement, ok := escapes[escape]; ok {
+				b.WriteByte(replacement)
+				p.advance(2)
+				continue
+			}
+			if escape == 'u' && p.pos+6 <= len(p.src) {
+				if code, err := strconv.ParseUint(p.src[p.pos+2:p.pos+6], 16, 32); err == nil {
+					b.WriteRune(rune(code))
+					p.advance(6)
+					continue
+				}
+			}
+			p.fail(Location{Line: p.line, Column: p.col}, "Syntax Error: invalid escape sequence in string")
+		default:
+			_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteString(p.src[p.pos : p.pos+size])
+			p.advance(size)
+		}
+	}
+}
+
+var escapes = map[byte]byte{'"': '"', '\\': '\\', '/': '/', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t'}
+
+// blockString reads a """block string""", removing its common indentation
+// and leading and trailing blank lines as the spec's BlockStringValue does
+func (p *parser) blockString(loc Location) {
+	p.advance(3)
+	var raw strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			p.fail(loc, "Syntax Error: unterminated block string")
+		}
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, `"""`):
+			p.advance(3)
+			p.tok = token{kind: stringToken, value: blockStringValue(raw.String()), loc: loc}
+			return
+		case strings.HasPrefix(rest, `\"""`):
+			raw.WriteString(`"""`)
+			p.advance(4)
+		case rest[0] == '\n' || rest[0] == '\r':
+			raw.WriteByte('\n')
+			p.pos++
+			if rest[0] == '\r' && len(rest) > 1 && rest[1] == '\n' {
+				p.pos++
+			}
+			p.line, p.col = p.line+1, 1
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			raw.WriteString(rest[:size])
+			p.advance(size)
+		}
+	}
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(raw, "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if n := len(line) - len(trimmed); trimmed != "" && (indent < 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
@@ -0,0 +1,367 @@
+// Package graphql executes GraphQL queries and subscriptions against a
+// schema declared in Go. It implements the parts of the October 2021 spec
+// an API gateway needs: the full query language (variables, aliases,
+// fragments, @skip and @include), validation, null propagation and ordered
+// results. Schemas are made of object, scalar, enum and list types; there
+// are no interfaces, unions or input objects, and no introspection: the
+// schema is published as SDL instead.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// Type is a GraphQL type: *Object, *Scalar, *Enum, or a list or non-null
+// wrapper of one
+type Type interface {
+	String() string
+}
+
+// Object is an output type with fields
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+// Field looks up a field by name
+func (o *Object) Field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Field is a field of an object
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Arg
+	// Resolve returns the field's value. Without it the value is read from
+	// the parent: a map entry of the same name, or the struct field whose
+	// json tag or name matches ignoring case and underscores, so that
+	// searchedAt reads `json:"searched_at"`.
+	Resolve func(p ResolveParams) (interface{}, error)
+	// Subscribe starts the event stream of a Subscription root field. Each
+	// event is the field's value in one response; the stream ends when the
+	// channel is closed or the context is done.
+	Subscribe func(p ResolveParams) (<-chan interface{}, error)
+}
+
+// Arg is an argument of a field: a scalar, an enum or a list of them
+type Arg struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     interface{} // used when the argument is not given; nil for none
+}
+
+// ResolveParams is what a resolver is called with
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}            // the parent object's value
+	Args    map[string]interface{} // coerced to int, float64, string, bool or []interface{}
+	Path    []interface{}          // response keys and list indexes down to the field
+}
+
+// Scalar is a leaf type
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize converts a resolved value to its JSON form
+	Serialize func(value interface{}) (interface{}, error)
+	// Parse converts an argument or variable, given as a JSON value (numbers
+	// as json.Number or float64), to the value resolvers receive
+	Parse func(value interface{}) (interface{}, error)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// Enum is a leaf type whose values are names
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+func (e *Enum) String() string { return e.Name }
+
+func (e *Enum) has(v string) bool {
+	for _, value := range e.Values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+type list struct{ of Type }
+
+func (l *list) String() string { return "[" + l.of.String() + "]" }
+
+type nonNull struct{ of Type }
+
+func (n *nonNull) String() string { return n.of.String() + "!" }
+
+// ListOf is the list type of t
+func ListOf(t Type) Type { return &list{of: t} }
+
+// NonNull is the non-null type of t
+func NonNull(t Type) Type { return &nonNull{of: t} }
+
+// named strips the list and non-null wrappers off a type
+func named(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *list:
+			t = w.of
+		case *nonNull:
+			t = w.of
+		default:
+			return t
+		}
+	}
+}
+
+// The built-in scalars
+var (
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize:   serializeInt,
+		Parse:       parseInt,
+	}
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating point number.",
+		Serialize:   serializeFloat,
+		Parse:       serializeFloat,
+	}
+	String = &Scalar{
+		Name:        "String",
+		Description: "UTF-8 text.",
+		Serialize:   serializeString,
+		Parse:       parseString,
+	}
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize:   serializeBoolean,
+		Parse:       serializeBoolean,
+	}
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "A unique identifier, serialized as a string.",
+		Serialize:   serializeID,
+		Parse:       serializeID,
+	}
+)
+
+var builtinScalars = []*Scalar{Int, Float, String, Boolean, ID}
+
+func serializeInt(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	var n float64
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = rv.Float()
+	default:
+		return nil, fmt.Errorf("Int cannot represent %v", v)
+	}
+	if n != math.Trunc(n) || n < math.MinInt32 || n > math.MaxInt32 {
+		return nil, fmt.Errorf("Int cannot represent %v", v)
+	}
+	return int(n), nil
+}
+
+func parseInt(v interface{}) (interface{}, error) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("Int cannot represent %s", n)
+		}
+		return serializeInt(f)
+	}
+	return serializeInt(v)
+}
+
+func serializeFloat(v interface{}) (interface{}, error) {
+	if n, ok := v.(json.Number); ok {
+		return n.Float64()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("Float cannot represent %v", v)
+}
+
+func serializeString(v interface{}) (interface{}, error) {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	return nil, fmt.Errorf("String cannot represent %v", v)
+}
+
+func parseString(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("String cannot represent %v", v)
+}
+
+func serializeBoolean(v interface{}) (interface{}, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Bool {
+		return rv.Bool(), nil
+	}
+	return nil, fmt.Errorf("Boolean cannot represent %v", v)
+}
+
+func serializeID(v interface{}) (interface{}, error) {
+	if n, ok := v.(json.Number); ok {
+		if _, err := n.Int64(); err != nil {
+			return nil, fmt.Errorf("ID cannot represent %s", n)
+		}
+		return n.String(), nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	if n, err := serializeInt(v); err == nil {
+		return strconv.Itoa(n.(int)), nil
+	}
+	return nil, fmt.Errorf("ID cannot represent %v", v)
+}
+
+// Schema is the types a request can query, from its root types
+type Schema struct {
+	Query        *Object
+	Subscription *Object // nil without subscriptions
+	// MaxDepth rejects requests whose selections nest deeper; 0 for no limit
+	MaxDepth int
+
+	types map[string]Type
+}
+
+var namePattern = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// NewSchema checks the types reachable from the root types: valid and
+// unique names, and arguments of input types
+func NewSchema(query, subscription *Object, maxDepth int) (*Schema, error) {
+	s := &Schema{Query: query, Subscription: subscription, MaxDepth: maxDepth, types: make(map[string]Type)}
+	for _, scalar := range builtinScalars {
+		s.types[scalar.Name] = scalar
+	}
+	if query == nil {
+		return nil, fmt.Errorf("a schema needs a query type")
+	}
+	for _, root := range []*Object{query, subscription} {
+		if root == nil {
+			continue
+		}
+		if err := s.add(root); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// add registers a named type and the types its fields refer to
+func (s *Schema) add(t Type) error {
+	t = named(t)
+	name := t.String()
+	if existing, ok := s.types[name]; ok {
+		if existing != t {
+			return fmt.Errorf("two types are named %s", name)
+		}
+		return nil
+	}
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid type name %q", name)
+	}
+	s.types[name] = t
+	switch t := t.(type) {
+	case *Enum:
+		for _, v := range t.Values {
+			if !namePattern.MatchString(v) || v == "true" || v == "false" || v == "null" {
+				return fmt.Errorf("invalid value %q of enum %s", v, name)
+			}
+		}
+	case *Object:
+		seen := make(map[string]bool)
+		for _, f := range t.Fields {
+			if !namePattern.MatchString(f.Name) || seen[f.Name] {
+				return fmt.Errorf("invalid or repeated field %q of %s", f.Name, name)
+			}
+			seen[f.Name] = true
+			if err := s.add(f.Type); err != nil {
+				return err
+			}
+			for _, arg := range f.Args {
+				if _, ok := named(arg.Type).(*Object); ok {
+					return fmt.Errorf("argument %s of %s.%s is an object type", arg.Name, name, f.Name)
+				}
+				if err := s.add(arg.Type); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of an operation. Data is absent when the request
+// failed before execution, and null when an error nulled the whole result.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []*Error        `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error. Resolvers may return one to set extensions,
+// e.g. a code clients can act on.
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Location is a line and column of the request document, both 1-based
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
@@ -0,0 +1,154 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SDL renders the schema in the schema definition language, for clients'
+// code generators: the root types first, then the types they use, breadth
+// first
+func (s *Schema) SDL() string {
+	order := []Type{s.Query}
+	if s.Subscription != nil {
+		order = append(order, s.Subscription)
+	}
+	seen := make(map[Type]bool)
+	for _, t := range order {
+		seen[t] = true
+	}
+	for i := 0; i < len(order); i++ {
+		obj, ok := order[i].(*Object)
+		if !ok {
+			continue
+		}
+		for _, f := range obj.Fields {
+			types := []Type{f.Type}
+			for _, arg := range f.Args {
+				types = append(types, arg.Type)
+			}
+			for _, t := range types {
+				if t = named(t); !seen[t] {
+					seen[t] = true
+					order = append(order, t)
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	if s.Query.Name != "Query" || (s.Subscription != nil && s.Subscription.Name != "Subscription") {
+		b.WriteString("schema {\n  query: " + s.Query.Name + "\n")
+		if s.Subscription != nil {
+			b.WriteString("  subscription: " + s.Subscription.Name + "\n")
+		}
+		b.WriteString("}\n\n")
+	}
+	for _, t := range order {
+		switch t := t.(type) {
+		case *Scalar:
+			if isBuiltin(t) {
+				continue
+			}
+			writeDescription(&b, t.Description, "")
+			b.WriteString("scalar " + t.Name + "\n\n")
+		case *Enum:
+			writeDescription(&b, t.Description, "")
+			b.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.Values {
+				b.WriteString("  " + v + "\n")
+			}
+			b.WriteString("}\n\n")
+		case *Object:
+			writeDescription(&b, t.Description, "")
+			b.WriteString("type " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				writeDescription(&b, f.Description, "  ")
+				b.WriteString("  " + f.Name + writeArgs(f.Args) + ": " + f.Type.String() + "\n")
+			}
+			b.WriteString("}\n\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func isBuiltin(s *Scalar) bool {
+	for _, builtin := range builtinScalars {
+		if s == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// writeArgs renders a field's arguments, one per line when any has a
+// description
+func writeArgs(args []*Arg) string {
+	if len(args) == 0 {
+		return ""
+	}
+	described := false
+	for _, arg := range args {
+		described = described || arg.Description != ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		part := arg.Name + ": " + arg.Type.String()
+		if arg.Default != nil {
+			part += " = " + literal(arg.Default, arg.Type)
+		}
+		parts[i] = part
+	}
+	if !described {
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+	var b strings.Builder
+	b.WriteString("(\n")
+	for i, arg := range args {
+		writeDescription(&b, arg.Description, "    ")
+		b.WriteString("    " + parts[i] + "\n")
+	}
+	b.WriteString("  )")
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, description, indent string) {
+	if description == "" {
+		return
+	}
+	if !strings.ContainsAny(description, "\n\"\\") {
+		b.WriteString(indent + `"` + description + `"` + "\n")
+		return
+	}
+	b.WriteString(indent + `"""` + "\n")
+	for _, line := range strings.Split(description, "\n") {
+		b.WriteString(indent + strings.ReplaceAll(line, `"""`, `\"""`) + "\n")
+	}
+	b.WriteString(indent + `"""` + "\n")
+}
+
+// literal renders a default value as a GraphQL literal of type t
+func literal(v interface{}, t Type) string {
+	t = nullable(t)
+	if l, ok := t.(*list); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return literal(v, l.of)
+		}
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = literal(rv.Index(i).Interface(), l.of)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	if _, ok := t.(*Enum); ok {
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,494 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// validator applies the spec's validation rules to a document, collecting
+// every violation so that a client sees them all at once
+type validator struct {
+	schema *Schema
+	doc    *document
+	errs   []*Error
+
+	usedFragments map[string]bool
+
+	// of the operation being validated
+	vars     map[string]*varDef
+	usedVars map[string]bool
+	tooDeep  bool
+	selected int // fields, counting those of a fragment once per spread
+}
+
+// maxSelectedFields bounds the work of a request whose fragments spread
+// each other many times over
+const maxSelectedFields = 10000
+
+func (v *validator) report(loc Location, format string, args ...interface{}) {
+	v.errs = append(v.errs, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+// validate checks the whole document and returns the operation to run:
+// the one named operationName, or the only one
+func (v *validator) validate(operationName string) (*operationDef, []*Error) {
+	v.usedFragments = make(map[string]bool)
+	names := make(map[string]bool)
+	for _, op := range v.doc.operations {
+		if op.name == "" && len(v.doc.operations) > 1 {
+			v.report(op.loc, "This anonymous operation must be the only defined operation.")
+		} else if names[op.name] {
+			v.report(op.loc, "There can be only one operation named %q.", op.name)
+		}
+		names[op.name] = true
+	}
+	v.fragmentCycles()
+	if len(v.errs) > 0 {
+		return nil, v.errs
+	}
+
+	for _, op := range v.doc.operations {
+		v.operation(op)
+	}
+	for name, f := range v.doc.fragments {
+		if !v.usedFragments[name] {
+			v.report(f.loc, "Fragment %q is never used.", name)
+		}
+	}
+	if len(v.errs) > 0 {
+		return nil, v.unique()
+	}
+
+	if operationName == "" {
+		if len(v.doc.operations) > 1 {
+			return nil, []*Error{{Message: "Must provide operation name if query contains multiple operations."}}
+		}
+		return v.doc.operations[0], nil
+	}
+	for _, op := range v.doc.operations {
+		if op.name == operationName {
+			return op, nil
+		}
+	}
+	return nil, []*Error{{Message: fmt.Sprintf("Unknown operation named %q.", operationName)}}
+}
+
+func (v *validator) operation(op *operationDef) {
+	var root *Object
+	switch op.kind {
+	case "query":
+		root = v.schema.Query
+	case "subscription":
+		root = v.schema.Subscription
+	}
+	if root == nil {
+		v.report(op.loc, "Schema is not configured for %ss.", op.kind)
+		return
+	}
+
+	v.vars = make(map[string]*varDef)
+	v.usedVars = make(map[string]bool)
+	v.tooDeep, v.selected = false, 0
+	for _, vd := range op.vars {
+		if v.vars[vd.name] != nil {
+			v.report(vd.loc, "There can be only one variable named \"$%s\".", vd.name)
+			continue
+		}
+		v.vars[vd.name] = vd
+		vd.resolved = v.schema.typeOf(vd.typ)
+		if vd.resolved == nil {
+			v.report(vd.loc, "Unknown type %q.", vd.typ)
+			continue
+		}
+		if _, ok := named(vd.resolved).(*Object); ok {
+			v.report(vd.loc, "Variable \"$%s\" cannot be non-input type %q.", vd.name, vd.typ)
+			continue
+		}
+		if vd.def != nil {
+			if _, err := coerceLiteral(vd.def, vd.resolved, nil); err != nil {
+				v.report(vd.def.loc, "Variable \"$%s\" has an invalid default value: %v", vd.name, err)
+			}
+		}
+	}
+	v.directives(op.directives, false)
+	v.selections(root, op.selections, 1)
+	if v.selected < maxSelectedFields {
+		v.mergeable(root, op.selections)
+	}
+
+	if op.kind == "subscription" {
+		fields := v.fields(root, op.selections, nil)
+		if len(fields) != 1 {
+			v.report(op.loc, "Subscription must select only one top level field.")
+		} else if fields[0].fields[0].name == "__typename" {
+			v.report(fields[0].fields[0].loc, "Subscription must not select an introspection top level field.")
+		}
+	}
+	for _, vd := range op.vars {
+		if !v.usedVars[vd.name] {
+			v.report(vd.loc, "Variable \"$%s\" is never used.", vd.name)
+		}
+	}
+}
+
+// selections checks the fields, arguments and fragments selected on parent
+func (v *validator) selections(parent *Object, selections []selection, depth int) {
+	if max := v.schema.MaxDepth; max > 0 && depth > max && !v.tooDeep {
+		v.tooDeep = true
+		loc := Location{}
+		if f, ok := selections[0].(*field); ok {
+			loc = f.loc
+		}
+		v.report(loc, "Query is nested too deeply; the maximum depth is %d.", max)
+	}
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if v.selected++; v.selected == maxSelectedFields {
+				v.report(sel.loc, "Query selects more than %d fields.", maxSelectedFields)
+			}
+			if v.selected >= maxSelectedFields {
+				return
+			}
+			v.directives(sel.directives, true)
+			if sel.name == "__typename" {
+				if len(sel.args) > 0 || len(sel.selections) > 0 {
+					v.report(sel.loc, "Field \"__typename\" takes no arguments or subfields.")
+				}
+				continue
+			}
+			def := parent.Field(sel.name)
+			if def == nil {
+				hint := ""
+				if strings.HasPrefix(sel.name, "__") {
+					hint = " Introspection is not supported; use the published schema."
+				}
+				v.report(sel.loc, "Cannot query field %q on type %q.%s", sel.name, parent.Name, hint)
+				continue
+			}
+			v.arguments(parent, def, sel)
+			switch t := named(def.Type).(type) {
+			case *Object:
+				if len(sel.selections) == 0 {
+					v.report(sel.loc, "Field %q of type %q must have a selection of subfields.", sel.name, def.Type)
+					continue
+				}
+				v.selections(t, sel.selections, depth+1)
+			default:
+				if len(sel.selections) > 0 {
+					v.report(sel.loc, "Field %q must not have a selection since type %q has no subfields.", sel.name, def.Type)
+				}
+			}
+		case *fragmentSpread:
+			v.directives(sel.directives, true)
+			f := v.doc.fragments[sel.name]
+			if f == nil {
+				v.report(sel.loc, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			v.usedFragments[sel.name] = true
+			if v.typeCondition(parent, f.on, sel.loc, fmt.Sprintf("Fragment %q", sel.name)) {
+				v.directives(f.directives, true)
+				v.selections(parent, f.selections, depth)
+			}
+		case *inlineFragment:
+			v.directives(sel.directives, true)
+			if sel.on == "" || v.typeCondition(parent, sel.on, sel.loc, "Fragment") {
+				v.selections(parent, sel.selections, depth)
+			}
+		}
+	}
+}
+
+// unique drops the repeats of errors found in a fragment spread more than
+// once
+func (v *validator) unique() []*Error {
+	seen := make(map[string]bool)
+	var errs []*Error
+	for _, err := range v.errs {
+		key := fmt.Sprint(err.Message, err.Locations)
+		if !seen[key] {
+			seen[key] = true
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// typeCondition checks that a fragment on the named type can apply to
+// parent. Without interfaces or unions that is only parent itself.
+func (v *validator) typeCondition(parent *Object, on string, loc Location, what string) bool {
+	t, ok := v.schema.types[on]
+	switch {
+	case !ok:
+		v.report(loc, "Unknown type %q.", on)
+	case t != Type(parent):
+		if _, isObject := t.(*Object); !isObject {
+			v.report(loc, "Fragment cannot condition on non composite type %q.", on)
+		} else {
+			v.report(loc, "%s cannot be spread here as objects of type %q can never be of type %q.", what, parent.Name, on)
+		}
+	default:
+		return true
+	}
+	return false
+}
+
+func (v *validator) arguments(parent *Object, def *Field, f *field) {
+	given := make(map[string]bool)
+	for _, arg := range f.args {
+		if given[arg.name] {
+			v.report(arg.loc, "There can be only one argument named %q.", arg.name)
+			continue
+		}
+		given[arg.name] = true
+		argDef := def.arg(arg.name)
+		if argDef == nil {
+			v.report(arg.loc, "Unknown argument %q on field \"%s.%s\".", arg.name, parent.Name, def.Name)
+			continue
+		}
+		v.value(arg.value, argDef.Type, argDef.Default != nil)
+	}
+	for _, argDef := range def.Args {
+		if _, required := argDef.Type.(*nonNull); required && argDef.Default == nil && !given[argDef.Name] {
+			v.report(f.loc, "Field %q argument %q of type %q is required, but it was not provided.", def.Name, argDef.Name, argDef.Type)
+		}
+	}
+}
+
+// value checks an argument value against its type; hasDefault allows a
+// nullable variable in a non-null position that has a default
+func (v *validator) value(val *value, t Type, hasDefault bool) {
+	switch {
+	case val.kind == variableValue:
+		vd := v.vars[val.raw]
+		if vd == nil {
+			v.report(val.loc, "Variable \"$%s\" is not defined.", val.raw)
+			return
+		}
+		v.usedVars[val.raw] = true
+		if vd.resolved == nil {
+			return
+		}
+		expected := t
+		if nn, ok := t.(*nonNull); ok && (hasDefault || (vd.def != nil && vd.def.kind != nullValue)) {
+			expected = nn.of
+		}
+		if !compatible(vd.resolved, expected) {
+			v.report(val.loc, "Variable \"$%s\" of type %q used in position expecting type %q.", val.raw, vd.typ, t)
+		}
+	case val.kind == listValue:
+		if l, ok := nullable(t).(*list); ok {
+			for _, item := range val.list {
+				v.value(item, l.of, false)
+			}
+			return
+		}
+		fallthrough
+	default:
+		if _, err := coerceLiteral(val, t, nil); err != nil {
+			v.report(val.loc, "%v", err)
+		}
+	}
+}
+
+// directives checks @skip and @include, the only directives there are;
+// allowed is false where neither may be used
+func (v *validator) directives(directives []*directive, allowed bool) {
+	seen := make(map[string]bool)
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			v.report(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		if !allowed {
+			v.report(d.loc, "Directive \"@%s\" may not be used on operations.", d.name)
+			continue
+		}
+		if seen[d.name] {
+			v.report(d.loc, "The directive \"@%s\" can only be used once at this location.", d.name)
+		}
+		seen[d.name] = true
+		given := false
+		for _, arg := range d.args {
+			if arg.name != "if" {
+				v.report(arg.loc, "Unknown argument %q on directive \"@%s\".", arg.name, d.name)
+				continue
+			}
+			given = true
+			v.value(arg.value, NonNull(Boolean), false)
+		}
+		if !given {
+			v.report(d.loc, "Directive \"@%s\" argument \"if\" of type \"Boolean!\" is required, but it was not provided.", d.name)
+		}
+	}
+}
+
+// mergeable checks that fields sharing a response key, directly or through
+// fragments, are the same field with the same arguments, so that they can
+// be merged into one
+func (v *validator) mergeable(parent *Object, selections []selection) {
+	for _, group := range v.fields(parent, selections, nil) {
+		first := group.fields[0]
+		var merged []selection
+		for _, f := range group.fields {
+			if f.name != first.name {
+				v.report(f.loc, "Fields %q conflict because %s and %s are different fields. Use different aliases on the fields to fetch both if this was intentional.", group.key, first.name, f.name)
+			} else if argString(f.args) != argString(first.args) {
+				v.report(f.loc, "Fields %q conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.", group.key)
+			}
+			merged = append(merged, f.selections...)
+		}
+		if def := parent.Field(first.name); def != nil && len(merged) > 0 {
+			if child, ok := named(def.Type).(*Object); ok {
+				v.mergeable(child, merged)
+			}
+		}
+	}
+}
+
+// fields groups the fields selected on parent by response key, through
+// fragments that apply to it and regardless of directives
+func (v *validator) fields(parent *Object, selections []selection, groups []*fieldGroup) []*fieldGroup {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			groups = addField(groups, sel)
+		case *fragmentSpread:
+			if f := v.doc.fragments[sel.name]; f != nil && f.on == parent.Name {
+				groups = v.fields(parent, f.selections, groups)
+			}
+		case *inlineFragment:
+			if sel.on == "" || sel.on == parent.Name {
+				groups = v.fields(parent, sel.selections, groups)
+			}
+		}
+	}
+	return groups
+}
+
+func argString(args []*argument) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.name + ":" + arg.value.String()
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// fragmentCycles reports fragments that spread themselves, directly or
+// through other fragments
+func (v *validator) fragmentCycles() {
+	const visiting, done = 1, 2
+	state := make(map[string]int)
+	var visit func(f *fragmentDef, path []string)
+	visit = func(f *fragmentDef, path []string) {
+		state[f.name] = visiting
+		for _, spread := range spreads(f.selections, nil) {
+			next := v.doc.fragments[spread.name]
+			switch {
+			case next == nil:
+			case state[next.name] == visiting:
+				via := ""
+				if i := indexOf(path, next.name); i >= 0 && i < len(path)-1 {
+					via = " via " + strings.Join(path[i+1:], ", ")
+				}
+				v.report(spread.loc, "Cannot spread fragment %q within itself%s.", next.name, via)
+			case state[next.name] == 0:
+				visit(next, append(path, next.name))
+			}
+		}
+		state[f.name] = done
+	}
+	names := make([]string, 0, len(v.doc.fragments))
+	for name := range v.doc.fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if state[name] == 0 {
+			visit(v.doc.fragments[name], []string{name})
+		}
+	}
+}
+
+// spreads lists the fragment spreads in selections, at any depth
+func spreads(selections []selection, out []*fragmentSpread) []*fragmentSpread {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			out = spreads(sel.selections, out)
+		case *fragmentSpread:
+			out = append(out, sel)
+		case *inlineFragment:
+			out = spreads(sel.selections, out)
+		}
+	}
+	return out
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// typeOf is the schema type a variable definition names, nil when it
+// names none
+func (s *Schema) typeOf(ref *typeRef) Type {
+	var t Type
+	if ref.list != nil {
+		of := s.typeOf(ref.list)
+		if of == nil {
+			return nil
+		}
+		t = ListOf(of)
+	} else if t = s.types[ref.name]; t == nil {
+		return nil
+	}
+	if ref.nonNull {
+		t = NonNull(t)
+	}
+	return t
+}
+
+// compatible reports whether a variable of type varType can be used where
+// locType is expected
+func compatible(varType, locType Type) bool {
+	if ln, ok := locType.(*nonNull); ok {
+		vn, ok := varType.(*nonNull)
+		return ok && compatible(vn.of, ln.of)
+	}
+	if vn, ok := varType.(*nonNull); ok {
+		return compatible(vn.of, locType)
+	}
+	if ll, ok := locType.(*list); ok {
+		vl, ok := varType.(*list)
+		return ok && compatible(vl.of, ll.of)
+	}
+	if _, ok := varType.(*list); ok {
+		return false
+	}
+	return varType == locType
+}
+
+// nullable strips a non-null wrapper
+func nullable(t Type) Type {
+	if nn, ok := t.(*nonNull); ok {
+		return nn.of
+	}
+	return t
+}
+
+func (f *Field) arg(name string) *Arg {
+	for _, a := range f.Args {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package instant
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// calculationPrefixes are stripped from a query before it is parsed
+var calculationPrefixes = []string{"what is ", "what's "}
+
+// commandPrefixes, like a leading "=", ask for a calculation outright
+var commandPrefixes = []string{"calculate ", "calc ", "compute ", "evaluate ", "solve "}
+
+// calculate evaluates a query that is an arithmetic expression: numbers,
+// + - * / % ^ and !, parentheses, the constants pi and e and the functions
+// in mathFunctions. A lone number is not a calculation, and neither is an
+// expression like "9/11", "24/7", "2001-2005" or "1-800-273-8255" that is
+// only numbers joined by unspaced "-" and "/": those are more often dates,
+// ranges, names and phone numbers. They are calculated after a leading "="
+// or one of commandPrefixes.
+func calculate(q string) *Answer {
+	explicit := strings.HasPrefix(q, "=")
+	q = strings.TrimLeft(q, "= ")
+	for _, prefix := range commandPrefixes {
+		if strings.HasPrefix(q, prefix) {
+			q, explicit = strings.TrimPrefix(q, prefix), true
+		}
+	}
+	for _, prefix := range calculationPrefixes {
+		q = strings.TrimPrefix(q, prefix)
+	}
+	q = strings.TrimSpace(strings.TrimRight(q, "=? "))
+	q = strings.NewReplacer("×", "*", "÷", "/", "**", "^").Replace(q)
+
+	tokens, err := tokenize(q)
+	if err != nil || len(tokens) < 2 || !explicit && !hasOperator(q, tokens) {
+		return nil
+	}
+	p := &parser{tokens: tokens}
+	value, err := p.expr()
+	if err != nil || p.pos != len(p.tokens) || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return &Answer{Kind: KindCalculation, Input: q, Result: formatNumber(value), Value: &value}
+}
+
+// hasOperator reports whether an expression has an operator other than an
+// unspaced "-" or "/" or a leading "+", a constant or a function
+func hasOperator(q string, tokens []token) bool {
+	if strings.Contains(q, " - ") || strings.Contains(q, " / ") {
+		return true
+	}
+	for i, t := range tokens {
+		if t.kind == tokenName || t.kind == tokenOperator && t.text != "-" && t.text != "/" && (t.text != "+" || i > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// mathFunctions may be called with one argument in parentheses
+var mathFunctions = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"cbrt":  math.Cbrt,
+	"abs":   math.Abs,
+	"exp":   math.Exp,
+	"ln":    math.Log,
+	"log":   math.Log10,
+	"log2":  math.Log2,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"round": math.Round,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+}
+
+var mathConstants = map[string]float64{"pi": math.Pi, "e": math.E}
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenOperator
+	tokenName
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+var errSyntax = errors.New("not an arithmetic expression")
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || s[j] == ',') {
+				j++
+			}
+			value, err := strconv.ParseFloat(strings.ReplaceAll(s[i:j], ",", ""), 64)
+			if err != nil {
+				return nil, errSyntax
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: value})
+			i = j
+		case strings.IndexByte("+-*/%^!()", c) >= 0:
+			tokens = append(tokens, token{kind: tokenOperator, text: string(c)})
+			i++
+		case c >= 'a' && c <= 'z':
+			j := i
+			for j < len(s) && (s[j] >= 'a' && s[j] <= 'z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			name := s[i:j]
+			if _, ok := mathFunctions[name]; !ok {
+				if _, ok := mathConstants[name]; !ok {
+					return nil, errSyntax
+				}
+			}
+			tokens = append(tokens, token{kind: tokenName, text: name})
+			i = j
+		default:
+			return nil, errSyntax
+		}
+	}
+	return tokens, nil
+}
+
+// parser evaluates tokens by recursive descent:
+//
+//	expr    = term {("+" | "-") term}
+//	term    = unary {("*" | "/" | "%") unary}
+//	unary   = ("-" | "+") unary | power
+//	power   = postfix ["^" unary]
+//	postfix = primary {"!"}
+//	primary = number | constant | function "(" expr ")" | "(" expr ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == op
+}
+
+func (p *parser) expr() (float64, error) {
+	left, err := p.term()
+	for err == nil && (p.peek("+") || p.peek("-")) {
+		op := p.tokens[p.pos].text
+		p.pos++
+		var right float64
+		if right, err = p.term(); op == "+" {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+	return left, err
+}
+
+func (p *parser) term() (float64, error) {
+	left, err := p.unary()
+	for err == nil && (p.peek("*") || p.peek("/") || p.peek("%")) {
+		op := p.tokens[p.pos].text
+		p.pos++
+		var right float64
+		right, err = p.unary()
+		switch op {
+		case "*":
+			left *= right
+		case "/":
+			left /= right
+		case "%":
+			left = math.Mod(left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *parser) unary() (float64, error) {
+	if p.peek("-") || p.peek("+") {
+		negate := p.tokens[p.pos].text == "-"
+		p.pos++
+		value, err := p.unary()
+		if negate {
+			value = -value
+		}
+		return value, err
+	}
+	return p.power()
+}
+
+func (p *parser) power() (float64, error) {
+	base, err := p.postfix()
+	if err != nil || !p.peek("^") {
+		return base, err
+	}
+	p.pos++
+	exponent, err := p.unary()
+	return math.Pow(base, exponent), err
+}
+
+func (p *parser) postfix() (float64, error) {
+	value, err := p.primary()
+	for err == nil && p.peek("!") {
+		p.pos++
+		if value < 0 || value > 170 || value != math.Trunc(value) {
+			return 0, errSyntax
+		}
+		result := 1.0
+		for n := 2.0; n <= value; n++ {
+			result *= n
+		}
+		value = result
+	}
+	return value, err
+}
+
+func (p *parser) primary() (float64, error) {
+	if p.pos >= len(p.tokens) {
+		return 0, errSyntax
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case t.kind == tokenNumber:
+		return t.value, nil
+	case t.kind == tokenName:
+		if value, ok := mathConstants[t.text]; ok {
+			return value, nil
+		}
+		if !p.peek("(") {
+			return 0, errSyntax
+		}
+		p.pos++
+		arg, err := p.closeParen()
+		return mathFunctions[t.text](arg), err
+	case t.text == "(":
+		return p.closeParen()
+	}
+	return 0, errSyntax
+}
+
+func (p *parser) closeParen() (float64, error) {
+	value, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if !p.peek(")") {
+		return 0, errSyntax
+	}
+	p.pos++
+	return value, nil
+}
+
+// formatNumber prints a result with up to 12 significant digits, without
+// an exponent for ordinary magnitudes
+func formatNumber(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(value, 'g', 12, 64)
+}
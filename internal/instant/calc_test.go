@@ -0,0 +1,57 @@
+package instant
+
+import "testing"
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // "" when the query is not a calculation
+	}{
+		{"2^10", "1024"},
+		{"2+2", "4"},
+		{"2 + 2 =", "4"},
+		{"what is 3*4?", "12"},
+		{"sqrt(16)*(3+2)", "20"},
+		{"5!", "120"},
+		{"10 % 3", "1"},
+		{"9 / 11", "0.818181818182"},
+		{"7 - 11", "-4"},
+		{"2 × 3", "6"},
+		{"1,000 * 3", "3000"},
+		{"pi", ""},
+		{"2pi", ""},
+		{"=9/11", "0.818181818182"},
+		{"= 7-11", "-4"},
+		{"calc 2001-2005", "-4"},
+		{"calculate 1-800-273-8255", "-9327"},
+
+		// Not calculations
+		{"42", ""},
+		{"-5", ""},
+		{"9/11", ""},
+		{"24/7", ""},
+		{"7-11", ""},
+		{"2001-2005", ""},
+		{"what is 9/11", ""},
+		{"1-800-273-8255", ""},
+		{"+1-800-273-8255", ""},
+		{"(555) 123-4567", ""},
+		{"10/16/2026", ""},
+		{"978-0-13-468599-1", ""},
+		{"2 + ", ""},
+		{"1/0 * 2", ""},
+		{"what is love", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			answer := calculate(tt.query)
+			got := ""
+			if answer != nil {
+				got = answer.Result
+			}
+			if got != tt.want {
+				t.Errorf("calculate(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package instant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// maxDefinitions is how many senses of a word are returned
+const maxDefinitions = 3
+
+// definitionPatterns capture the word a query asks to define
+var definitionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^define:? ([a-z][a-z'-]*)$`),
+	regexp.MustCompile(`^(?:definition|meaning) of ([a-z][a-z'-]*)$`),
+	regexp.MustCompile(`^what does ([a-z][a-z'-]*) mean\??$`),
+	regexp.MustCompile(`^([a-z][a-z'-]*) (?:definition|meaning)$`),
+}
+
+func definitionWord(q string) (string, bool) {
+	for _, pattern := range definitionPatterns {
+		if m := pattern.FindStringSubmatch(q); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// dictionaryEntry is an entry of the dictionaryapi.dev response, a list of
+// entries for the word
+type dictionaryEntry struct {
+	Word     string `json:"word"`
+	Meanings []struct {
+		PartOfSpeech string `json:"partOfSpeech"`
+		Definitions  []struct {
+			Definition string `json:"definition"`
+			Example    string `json:"example"`
+		} `json:"definitions"`
+	} `json:"meanings"`
+}
+
+// define looks word up in the dictionary; an unknown word has no answer
+func (a *Answerer) define(ctx context.Context, word string) (*Answer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.dictionaryURL+"/"+url.PathEscape(word), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dictionary request: %w", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dictionary request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dictionary returned status %d", resp.StatusCode)
+	}
+	var entries []dictionaryEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode dictionary response: %w", err)
+	}
+
+	answer := &Answer{Kind: KindDefinition, Input: word, Source: req.URL.Host}
+	for _, entry := range entries {
+		for _, meaning := range entry.Meanings {
+			for _, d := range meaning.Definitions {
+				if len(answer.Definitions) < maxDefinitions && d.Definition != "" {
+					answer.Definitions = append(answer.Definitions, Definition{PartOfSpeech: meaning.PartOfSpeech, Text: d.Definition, Example: d.Example})
+				}
+			}
+		}
+	}
+	if len(answer.Definitions) == 0 {
+		return nil, nil
+	}
+	answer.Result = answer.Definitions[0].Text
+	return answer, nil
+}
@@ -0,0 +1,107 @@
+// Package instant answers queries that need neither a web search nor a
+// summary: arithmetic ("2^10", "sqrt(2)*3"), unit and currency conversions
+// ("5 km to miles", "100 usd to eur") and definitions ("define ephemeral").
+// Calculations and conversions are computed locally; definitions are looked
+// up in a dictionary API.
+package instant
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+)
+
+// Kinds of instant answers
+const (
+	KindCalculation = "calculation"
+	KindConversion  = "conversion"
+	KindDefinition  = "definition"
+)
+
+// Answer is an instant answer to a query
+type Answer struct {
+	Kind   string   `json:"kind"`
+	Input  string   `json:"input"`           // the expression, quantity or word as understood
+	Result string   `json:"result"`          // the answer, formatted for display
+	Value  *float64 `json:"value,omitempty"` // numeric result of calculations and conversions
+	Unit   string   `json:"unit,omitempty"`  // of Value, for conversions
+
+	Definitions []Definition `json:"definitions,omitempty"`
+	Source      string       `json:"source,omitempty"` // where a looked-up answer came from
+}
+
+// Definition is one sense of a defined word
+type Definition struct {
+	PartOfSpeech string `json:"part_of_speech,omitempty"`
+	Text         string `json:"definition"`
+	Example      string `json:"example,omitempty"`
+}
+
+// Lookup is a query recognized as having an instant answer
+type Lookup struct {
+	Kind    string
+	resolve func(ctx context.Context) (*Answer, error)
+}
+
+// Answer resolves the lookup. It returns nil without an error when there
+// turns out to be no answer, e.g. a word the dictionary does not know.
+func (l *Lookup) Answer(ctx context.Context) (*Answer, error) {
+	return l.resolve(ctx)
+}
+
+// Answerer recognizes and answers instant-answer queries
+type Answerer struct {
+	dictionaryURL string
+	client        *http.Client
+	rates         map[string]float64 // per US dollar, by lowercase currency code
+}
+
+// New returns nil when instant answers are disabled
+func New(cfg *config.Config) *Answerer {
+	instantCfg := cfg.Gateway.Instant
+	if !instantCfg.Enabled {
+		return nil
+	}
+	rates := make(map[string]float64, len(instantCfg.CurrencyRates))
+	for code, rate := range instantCfg.CurrencyRates {
+		if rate > 0 {
+			rates[strings.ToLower(code)] = rate
+		}
+	}
+	return &Answerer{
+		dictionaryURL: strings.TrimSuffix(instantCfg.DictionaryURL, "/"),
+		client: &http.Client{
+			Timeout:   instantCfg.DictionaryTimeout,
+			Transport: upstream.WrapTransport(cfg, "dictionary", nil),
+		},
+		rates: rates,
+	}
+}
+
+// Match recognizes a query with an instant answer without doing any I/O,
+// returning nil for queries that need a search
+func (a *Answerer) Match(query string) *Lookup {
+	q := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if q == "" {
+		return nil
+	}
+	if answer := a.convert(q); answer != nil {
+		return resolved(answer)
+	}
+	if answer := calculate(q); answer != nil {
+		return resolved(answer)
+	}
+	if word, ok := definitionWord(q); ok && a.dictionaryURL != "" {
+		return &Lookup{Kind: KindDefinition, resolve: func(ctx context.Context) (*Answer, error) {
+			return a.define(ctx, word)
+		}}
+	}
+	return nil
+}
+
+func resolved(answer *Answer) *Lookup {
+	return &Lookup{Kind: answer.Kind, resolve: func(context.Context) (*Answer, error) { return answer, nil }}
+}
@@ -0,0 +1,140 @@
+package instant
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unit converts to its dimension's base unit as value*factor + offset
+type unit struct {
+	symbol    string
+	dimension string
+	factor    float64
+	offset    float64 // temperatures only
+}
+
+// units maps each accepted name to its unit; base units are the meter,
+// kilogram, liter, second, byte, meter per second and degree Celsius
+var units = map[string]unit{}
+
+func init() {
+	define := func(u unit, names ...string) {
+		units[u.symbol] = u
+		for _, name := range names {
+			units[name] = u
+		}
+	}
+	define(unit{symbol: "m", dimension: "length", factor: 1}, "meter", "meters", "metre", "metres")
+	define(unit{symbol: "km", dimension: "length", factor: 1000}, "kilometer", "kilometers", "kilometre", "kilometres")
+	define(unit{symbol: "cm", dimension: "length", factor: 0.01}, "centimeter", "centimeters", "centimetre", "centimetres")
+	define(unit{symbol: "mm", dimension: "length", factor: 0.001}, "millimeter", "millimeters", "millimetre", "millimetres")
+	define(unit{symbol: "mi", dimension: "length", factor: 1609.344}, "mile", "miles")
+	define(unit{symbol: "yd", dimension: "length", factor: 0.9144}, "yard", "yards")
+	define(unit{symbol: "ft", dimension: "length", factor: 0.3048}, "foot", "feet")
+	define(unit{symbol: "in", dimension: "length", factor: 0.0254}, "inch", "inches")
+	define(unit{symbol: "nmi", dimension: "length", factor: 1852}, "nautical mile", "nautical miles")
+
+	define(unit{symbol: "kg", dimension: "mass", factor: 1}, "kilogram", "kilograms", "kilo", "kilos")
+	define(unit{symbol: "g", dimension: "mass", factor: 0.001}, "gram", "grams")
+	define(unit{symbol: "mg", dimension: "mass", factor: 1e-6}, "milligram", "milligrams")
+	define(unit{symbol: "t", dimension: "mass", factor: 1000}, "tonne", "tonnes", "metric ton", "metric tons")
+	define(unit{symbol: "lb", dimension: "mass", factor: 0.45359237}, "lbs", "pound", "pounds")
+	define(unit{symbol: "oz", dimension: "mass", factor: 0.028349523125}, "ounce", "ounces")
+	define(unit{symbol: "st", dimension: "mass", factor: 6.35029318}, "stone", "stones")
+
+	define(unit{symbol: "l", dimension: "volume", factor: 1}, "liter", "liters", "litre", "litres")
+	define(unit{symbol: "ml", dimension: "volume", factor: 0.001}, "milliliter", "milliliters", "millilitre", "millilitres")
+	define(unit{symbol: "gal", dimension: "volume", factor: 3.785411784}, "gallon", "gallons")
+	define(unit{symbol: "qt", dimension: "volume", factor: 0.946352946}, "quart", "quarts")
+	define(unit{symbol: "pt", dimension: "volume", factor: 0.473176473}, "pint", "pints")
+	define(unit{symbol: "cup", dimension: "volume", factor: 0.2365882365}, "cups")
+	define(unit{symbol: "fl oz", dimension: "volume", factor: 0.0295735295625}, "fluid ounce", "fluid ounces")
+
+	define(unit{symbol: "s", dimension: "time", factor: 1}, "sec", "secs", "second", "seconds")
+	define(unit{symbol: "min", dimension: "time", factor: 60}, "mins", "minute", "minutes")
+	define(unit{symbol: "h", dimension: "time", factor: 3600}, "hr", "hrs", "hour", "hours")
+	define(unit{symbol: "d", dimension: "time", factor: 86400}, "day", "days")
+	define(unit{symbol: "wk", dimension: "time", factor: 604800}, "week", "weeks")
+	define(unit{symbol: "yr", dimension: "time", factor: 31557600}, "year", "years")
+
+	define(unit{symbol: "B", dimension: "data", factor: 1}, "b", "byte", "bytes")
+	define(unit{symbol: "bit", dimension: "data", factor: 0.125}, "bits")
+	define(unit{symbol: "KB", dimension: "data", factor: 1e3}, "kb", "kilobyte", "kilobytes")
+	define(unit{symbol: "MB", dimension: "data", factor: 1e6}, "mb", "megabyte", "megabytes")
+	define(unit{symbol: "GB", dimension: "data", factor: 1e9}, "gb", "gigabyte", "gigabytes")
+	define(unit{symbol: "TB", dimension: "data", factor: 1e12}, "tb", "terabyte", "terabytes")
+	define(unit{symbol: "KiB", dimension: "data", factor: 1 << 10}, "kib", "kibibyte", "kibibytes")
+	define(unit{symbol: "MiB", dimension: "data", factor: 1 << 20}, "mib", "mebibyte", "mebibytes")
+	define(unit{symbol: "GiB", dimension: "data", factor: 1 << 30}, "gib", "gibibyte", "gibibytes")
+	define(unit{symbol: "TiB", dimension: "data", factor: 1 << 40}, "tib", "tebibyte", "tebibytes")
+
+	define(unit{symbol: "m/s", dimension: "speed", factor: 1}, "meters per second", "metres per second")
+	define(unit{symbol: "km/h", dimension: "speed", factor: 1 / 3.6}, "kph", "kmh", "kilometers per hour", "kilometres per hour")
+	define(unit{symbol: "mph", dimension: "speed", factor: 0.44704}, "miles per hour")
+	define(unit{symbol: "kn", dimension: "speed", factor: 1852.0 / 3600}, "knot", "knots")
+
+	define(unit{symbol: "°C", dimension: "temperature", factor: 1}, "c", "celsius", "degrees celsius", "degree celsius")
+	define(unit{symbol: "°F", dimension: "temperature", factor: 5.0 / 9, offset: -32 * 5.0 / 9}, "f", "fahrenheit", "degrees fahrenheit", "degree fahrenheit")
+	define(unit{symbol: "K", dimension: "temperature", factor: 1, offset: -273.15}, "k", "kelvin", "kelvins")
+}
+
+// conversionPattern matches "<amount> <unit> to|in|into|as <unit>"
+var conversionPattern = regexp.MustCompile(`^(?:convert )?([-+]?[0-9][0-9,]*(?:\.[0-9]+)?|[-+]?\.[0-9]+) ?([a-z°/ ]+?) (?:to|in|into|as) ([a-z°/ ]+?)\??$`)
+
+// convert answers a unit or currency conversion; currencies need a rate for
+// both sides
+func (a *Answerer) convert(q string) *Answer {
+	m := conversionPattern.FindStringSubmatch(q)
+	if m == nil {
+		return nil
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", ""), 64)
+	if err != nil {
+		return nil
+	}
+	from, to := strings.TrimPrefix(m[2], "°"), strings.TrimPrefix(m[3], "°")
+
+	if fromRate, ok := a.rates[from]; ok {
+		toRate, ok := a.rates[to]
+		if !ok {
+			return nil
+		}
+		value := amount / fromRate * toRate
+		code := strings.ToUpper(to)
+		return &Answer{
+			Kind:   KindConversion,
+			Input:  formatNumber(amount) + " " + strings.ToUpper(from),
+			Result: strconv.FormatFloat(value, 'f', 2, 64) + " " + code,
+			Value:  &value,
+			Unit:   code,
+			Source: "configured exchange rates",
+		}
+	}
+
+	fromUnit, ok := lookupUnit(from)
+	if !ok {
+		return nil
+	}
+	toUnit, ok := lookupUnit(to)
+	if !ok || toUnit.dimension != fromUnit.dimension {
+		return nil
+	}
+	value := (amount*fromUnit.factor + fromUnit.offset - toUnit.offset) / toUnit.factor
+	return &Answer{
+		Kind:   KindConversion,
+		Input:  formatNumber(amount) + " " + fromUnit.symbol,
+		Result: strconv.FormatFloat(value, 'g', 6, 64) + " " + toUnit.symbol,
+		Value:  &value,
+		Unit:   toUnit.symbol,
+	}
+}
+
+// lookupUnit finds a unit by name, also as "degrees <name>"
+func lookupUnit(name string) (unit, bool) {
+	if u, ok := units[name]; ok {
+		return u, true
+	}
+	u, ok := units[strings.TrimPrefix(name, "degrees ")]
+	return u, ok
+}
@@ -0,0 +1,214 @@
+// Package lru is the bounded in-memory cache used wherever a map would
+// otherwise grow with traffic: the gateway's single-replica stores, the
+// LLM orchestrator's bookkeeping and the inference prefix tracker. Each
+// cache holds at most a fixed number of entries, evicting the least recently
+// used, and entries may expire after a TTL. Size, evictions and hit rates are
+// exported per cache name.
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"ai-search-service/internal/monitoring"
+)
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time // zero when the entry never expires
+}
+
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// Cache is a size- and TTL-bounded LRU cache, safe for concurrent use
+type Cache[K comparable, V any] struct {
+	maxEntries int
+	ttl        time.Duration
+	onEvict    func(K, V)
+
+	mu      sync.Mutex
+	order   *list.List // front = most recently used
+	entries map[K]*list.Element
+
+	size              prometheus.Gauge
+	capacityEvictions prometheus.Counter
+	expiredEvictions  prometheus.Counter
+	hits, misses      prometheus.Counter
+}
+
+// New returns a cache named name in metrics that holds at most maxEntries
+// entries (no limit when <= 0) for at most ttl each (no expiry when <= 0)
+func New[K comparable, V any](name string, maxEntries int, ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		maxEntries:        maxEntries,
+		ttl:               ttl,
+		order:             list.New(),
+		entries:           make(map[K]*list.Element),
+		size:              monitoring.LRUEntries.WithLabelValues(name),
+		capacityEvictions: monitoring.LRUEvictions.WithLabelValues(name, "capacity"),
+		expiredEvictions:  monitoring.LRUEvictions.WithLabelValues(name, "expired"),
+		hits:              monitoring.LRULookups.WithLabelValues(name, "hit"),
+		misses:            monitoring.LRULookups.WithLabelValues(name, "miss"),
+	}
+}
+
+// OnEvict calls fn with every entry dropped for capacity or expiry, but not
+// with entries removed or replaced explicitly. Set it before use; fn runs
+// with the cache locked and must not call back into it.
+func (c *Cache[K, V]) OnEvict(fn func(K, V)) {
+	c.onEvict = fn
+}
+
+// Get returns the value of key and marks it recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok && elem.Value.(*entry[K, V]).expired(time.Now()) {
+		c.evict(elem, c.expiredEvictions)
+		c.size.Set(float64(len(c.entries)))
+		ok = false
+	}
+	if !ok {
+		c.misses.Inc()
+		var zero V
+		return zero, false
+	}
+	c.hits.Inc()
+	c.order.MoveToFront(elem)
+	return elem.Value.(*entry[K, V]).value, true
+}
+
+// Add stores value under key with the cache's TTL, replacing any previous
+// value
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.AddWithTTL(key, value, c.ttl)
+}
+
+// AddWithTTL stores value under key for ttl (no expiry when <= 0),
+// evicting the least recently used entries beyond the size bound
+func (c *Cache[K, V]) AddWithTTL(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(key, value, ttl, time.Now())
+}
+
+// ContainsOrAdd reports whether key is cached, marking it recently used, and
+// adds value under it when it is not
+func (c *Cache[K, V]) ContainsOrAdd(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if elem, ok := c.entries[key]; ok && !elem.Value.(*entry[K, V]).expired(now) {
+		c.hits.Inc()
+		c.order.MoveToFront(elem)
+		return true
+	}
+	c.misses.Inc()
+	c.add(key, value, c.ttl, now)
+	return false
+}
+
+func (c *Cache[K, V]) add(key K, value V, ttl time.Duration, now time.Time) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = now.Add(ttl)
+	}
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	}
+
+	// Expired entries that were not used since are cheap to find at the back
+	for back := c.order.Back(); back != nil && back.Value.(*entry[K, V]).expired(now); back = c.order.Back() {
+		c.evict(back, c.expiredEvictions)
+	}
+	for c.maxEntries > 0 && len(c.entries) > c.maxEntries {
+		c.evict(c.order.Back(), c.capacityEvictions)
+	}
+	c.size.Set(float64(len(c.entries)))
+}
+
+func (c *Cache[K, V]) evict(elem *list.Element, counter prometheus.Counter) {
+	e := elem.Value.(*entry[K, V])
+	c.order.Remove(elem)
+	delete(c.entries, e.key)
+	counter.Inc()
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+}
+
+// Remove drops key, reporting whether it was cached
+func (c *Cache[K, V]) Remove(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	c.order.Remove(elem)
+	delete(c.entries, key)
+	c.size.Set(float64(len(c.entries)))
+	return true
+}
+
+// RemoveFunc drops every entry for which match returns true and returns how
+// many it dropped. match runs with the cache locked and must not call back
+// into it.
+func (c *Cache[K, V]) RemoveFunc(match func(K, V) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if e := elem.Value.(*entry[K, V]); match(e.key, e.value) {
+			c.order.Remove(elem)
+			delete(c.entries, e.key)
+			removed++
+		}
+		elem = next
+	}
+	c.size.Set(float64(len(c.entries)))
+	return removed
+}
+
+// RemoveExpired drops every expired entry and returns how many there were.
+// Expired entries are never returned, so this only frees their memory sooner.
+func (c *Cache[K, V]) RemoveExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*entry[K, V]).expired(now) {
+			c.evict(elem, c.expiredEvictions)
+			removed++
+		}
+		elem = next
+	}
+	c.size.Set(float64(len(c.entries)))
+	return removed
+}
+
+// Len returns the number of entries, including expired ones not yet dropped
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
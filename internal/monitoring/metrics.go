@@ -94,6 +94,516 @@ var (
 		[]string{"service", "model", "streaming"},
 	)
 
+	ModelWarmupDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_model_warmup_duration_seconds",
+			Help:    "Model warm-up duration in seconds",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300},
+		},
+		[]string{"service", "backend", "model", "status"},
+	)
+
+	PrefixCacheLookups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_prefix_cache_lookups_total",
+			Help: "Prompt prefix cache lookups by result (hit or miss)",
+		},
+		[]string{"service", "backend", "result"},
+	)
+
+	PromptProcessingLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_prompt_processing_seconds",
+			Help:    "Prompt processing latency in seconds, split by prefix cache result",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+		},
+		[]string{"service", "backend", "prefix_cache"},
+	)
+
+	// Fault injection metrics
+	FaultsInjected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_faults_injected_total",
+			Help: "Faults injected for resilience testing by RPC and kind (latency, error, drop)",
+		},
+		[]string{"service", "method", "kind"},
+	)
+
+	// SSE resumption metrics
+	SSEResumes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_sse_resumes_total",
+			Help: "SSE reconnects with Last-Event-ID by result (resumed, expired, invalid)",
+		},
+		[]string{"service", "result"},
+	)
+
+	// Idempotency metrics
+	IdempotentRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_idempotent_requests_total",
+			Help: "Requests carrying an Idempotency-Key by outcome (executed, joined, replayed, conflict)",
+		},
+		[]string{"service", "result"},
+	)
+
+	// Request coalescing metrics
+	CoalescedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_coalesced_requests_total",
+			Help: "Searches by coalescing role: leader runs the pipeline, follower shares its result",
+		},
+		[]string{"service", "mode", "role"},
+	)
+
+	BudgetExceeded = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_budget_exceeded_total",
+			Help: "Requests that hit a per-request cost ceiling, by limit",
+		},
+		[]string{"service", "limit"},
+	)
+
+	TrackedEntries = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ai_search_tracked_entries",
+			Help: "Entries held in request bookkeeping maps",
+		},
+		[]string{"service", "kind"},
+	)
+
+	LeakedEntriesReaped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_leaked_entries_reaped_total",
+			Help: "Bookkeeping entries reaped after outliving their TTL without being cleaned up",
+		},
+		[]string{"service", "kind"},
+	)
+
+	LRUEntries = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ai_search_lru_entries",
+			Help: "Entries held in a bounded in-memory cache",
+		},
+		[]string{"cache"},
+	)
+
+	LRUEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_lru_evictions_total",
+			Help: "Entries evicted from a bounded in-memory cache, by reason (capacity, expired)",
+		},
+		[]string{"cache", "reason"},
+	)
+
+	LRULookups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_lru_lookups_total",
+			Help: "Lookups in a bounded in-memory cache, by result (hit, miss)",
+		},
+		[]string{"cache", "result"},
+	)
+
+	DocumentsIngested = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_documents_ingested_total",
+			Help: "Documents submitted for ingestion into the internal corpus, by outcome",
+		},
+		[]string{"service", "outcome"},
+	)
+
+	NotificationsSent = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_notifications_total",
+			Help: "Notification deliveries per channel and event, by outcome (sent, error, suppressed)",
+		},
+		[]string{"channel", "event", "outcome"},
+	)
+
+	TenantQuotaRejections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_tenant_quota_rejections_total",
+			Help: "Searches rejected because a tenant exhausted its per-minute or per-day quota",
+		},
+		[]string{"tenant", "window"},
+	)
+
+	RequestsRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_gateway_rejected_requests_total",
+			Help: "Requests rejected by the gateway's size and time limits",
+		},
+		[]string{"reason"},
+	)
+
+	QueryTypes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_query_types_total",
+			Help: "Searches by detected query type (question, comparison, navigational, exploratory)",
+		},
+		[]string{"type"},
+	)
+
+	InstantAnswers = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_instant_answers_total",
+			Help: "Queries recognized as instant answers, by kind and outcome (answered, no_answer, failed)",
+		},
+		[]string{"kind", "outcome"},
+	)
+
+	StructuredAnswers = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_structured_answers_total",
+			Help: "Queries matched by a structured answer provider, by provider and outcome (answered, no_answer, failed)",
+		},
+		[]string{"provider", "outcome"},
+	)
+
+	KnowledgePanels = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_knowledge_panels_total",
+			Help: "Knowledge panel extractions by outcome (extracted, none, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	RelatedSearches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_related_searches_total",
+			Help: "Related search suggestions by outcome (derived, polished, polish_failed, none)",
+		},
+		[]string{"outcome"},
+	)
+
+	ImageProxyRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_image_proxy_requests_total",
+			Help: "Image proxy requests by outcome (fetched, cached, not_modified, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	FeatureFlagEvaluations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_feature_flag_evaluations_total",
+			Help: "Feature flag evaluations by flag and result (on, off)",
+		},
+		[]string{"flag", "result"},
+	)
+
+	SnippetCleaning = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_snippet_cleaning_total",
+			Help: "Changes made to search snippets before the summarization prompt (url, markup, boilerplate, injection)",
+		},
+		[]string{"change"},
+	)
+
+	ModelRoutes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_model_routes_total",
+			Help: "Summaries routed to the small or large model, by the reason (long_context, many_sources, query_type, simple)",
+		},
+		[]string{"tier", "reason"},
+	)
+
+	ModelRoutePromptTokens = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_model_route_prompt_tokens",
+			Help:    "Estimated prompt tokens of the summaries routed to each model tier",
+			Buckets: []float64{100, 200, 400, 600, 800, 1000, 1500, 2000, 4000},
+		},
+		[]string{"tier"},
+	)
+
+	PromptAuditRecords = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_prompt_audit_records_total",
+			Help: "Summarizations kept in the prompt audit (recorded, redacted, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	RetentionRemoved = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_retention_removed_total",
+			Help: "Entries the retention janitor removed (history, suggestions, audit_log, prompts, caches)",
+		},
+		[]string{"data"},
+	)
+
+	UserDataErased = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_user_data_erased_total",
+			Help: "Entries erased on user data deletion requests (history, exports, shares, saved_queries, prompts)",
+		},
+		[]string{"data"},
+	)
+
+	OutboxEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_outbox_events_total",
+			Help: "Analytics events written to the outbox, published to the event bus or failed to publish",
+		},
+		[]string{"outcome"},
+	)
+
+	StoredObjects = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_stored_objects_total",
+			Help: "Exports streamed to object storage (stored, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	StoredObjectBytes = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ai_search_stored_object_bytes_total",
+			Help: "Bytes streamed to object storage",
+		},
+	)
+
+	BatchQueries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_batch_queries_total",
+			Help: "Queries of batch jobs run (completed, failed)",
+		},
+		[]string{"status"},
+	)
+
+	MaintenanceRuns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_maintenance_runs_total",
+			Help: "Runs of maintenance jobs (succeeded, failed, skipped when another replica claimed them)",
+		},
+		[]string{"job", "outcome"},
+	)
+
+	MaintenanceRunDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_maintenance_run_duration_seconds",
+			Help:    "How long maintenance jobs ran",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		},
+		[]string{"job"},
+	)
+
+	Reranks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_reranks_total",
+			Help: "Result reranks by outcome (reranked, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	RerankDroppedResults = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ai_search_rerank_dropped_results_total",
+			Help: "Search results dropped by reranking for falling under the minimum relevance",
+		},
+	)
+
+	SnippetCompressions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_snippet_compressions_total",
+			Help: "Compressions of a search's snippets for the prompt by outcome (compressed, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	SnippetCompressionDroppedWords = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ai_search_snippet_compression_dropped_words_total",
+			Help: "Words of snippets left out of summarization prompts by compression",
+		},
+	)
+
+	SummaryCache = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_summary_cache_total",
+			Help: "Orchestrator summary cache lookups by outcome (hit, miss)",
+		},
+		[]string{"outcome"},
+	)
+
+	StreamTruncations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_stream_truncations_total",
+			Help: "Summary streams ended early by reason (repetition, max_length, or max_tokens lowered by a session)",
+		},
+		[]string{"reason"},
+	)
+
+	StreamRecoveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_stream_recoveries_total",
+			Help: "Summary streams that broke off by recovery outcome (recovered, failed, skipped)",
+		},
+		[]string{"outcome"},
+	)
+
+	StreamPoolIdle = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ai_search_inference_stream_pool_idle",
+			Help: "Inference sessions open and ready in the orchestrator's pool",
+		},
+	)
+
+	StreamPoolClaims = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_inference_stream_pool_claims_total",
+			Help: "Summary streams by whether they claimed a ready session (hit), opened their own (miss), or opened their own after the claimed session broke before the summary started (retried)",
+		},
+		[]string{"outcome"},
+	)
+
+	StreamPoolEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_inference_stream_pool_evictions_total",
+			Help: "Idle inference sessions closed by the pool, by reason (broken, expired)",
+		},
+		[]string{"reason"},
+	)
+
+	SessionControls = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_llm_session_controls_total",
+			Help: "Control messages of LLM stream sessions by action (cancel, set_max_tokens, regenerate)",
+		},
+		[]string{"action"},
+	)
+
+	StreamCancellations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_stream_cancellations_total",
+			Help: "Cancel requests for streaming searches by outcome (cancelled, forwarded, not_found)",
+		},
+		[]string{"outcome"},
+	)
+
+	LLMTimeouts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_llm_timeouts_total",
+			Help: "Orchestrator requests and stages that ran out of time, by stage (request, tokenize, inference, detokenize)",
+		},
+		[]string{"stage"},
+	)
+
+	PromptTokens = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_prompt_tokens",
+			Help:    "Tokens of summarization prompts before truncation, by model",
+			Buckets: prometheus.ExponentialBuckets(64, 2, 8),
+		},
+		[]string{"model"},
+	)
+
+	PromptContextUtilization = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_prompt_context_utilization_percent",
+			Help:    "Prompt tokens before truncation as a percentage of the model's context window; above 100 overflowed",
+			Buckets: []float64{10, 25, 50, 75, 90, 100, 150, 200, 400},
+		},
+		[]string{"model"},
+	)
+
+	PromptTruncations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_prompt_truncations_total",
+			Help: "Summarization prompts cut to fit, by model and limit (context_window, budget)",
+		},
+		[]string{"model", "limit"},
+	)
+
+	TokenizerMismatches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_tokenizer_mismatches_total",
+			Help: "Token-prompted summaries refused for a tokenizer version other than the model's pinned one, by model",
+		},
+		[]string{"model"},
+	)
+
+	PromptAssemblies = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_prompt_assemblies_total",
+			Help: "Prompts assembled for tokenization, by assembler (tokenizer with BuildPrompt, orchestrator for tokenizers without it)",
+		},
+		[]string{"assembler"},
+	)
+
+	SafetyOutputActions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_safety_output_actions_total",
+			Help: "Summaries by the action their toxicity scores led to (pass, annotate, rewrite, block)",
+		},
+		[]string{"action"},
+	)
+
+	PoliteFetches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_polite_fetches_total",
+			Help: "Page fetches through the politeness layer by outcome (fetched, disallowed, failed, robots_unreachable)",
+		},
+		[]string{"outcome"},
+	)
+
+	PoliteFetchWait = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_polite_fetch_wait_seconds",
+			Help:    "Time page fetches waited for their host's crawl delay",
+			Buckets: []float64{0, 0.1, 0.5, 1, 2, 5, 10, 30},
+		},
+	)
+
+	PaywalledResults = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_paywalled_results_total",
+			Help: "Web results flagged as paywalled by the signal that flagged them (domain, meta, snippet)",
+		},
+		[]string{"signal"},
+	)
+
+	ResponseCache = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_response_cache_total",
+			Help: "JSON searches by response cache outcome (hit, stale, miss) and background refreshes (refreshed, refresh_failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	Prefetches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_prefetches_total",
+			Help: "Related search result prefetches by outcome (fetched, cached, failed, skipped_busy) and searches answered from them (hit)",
+		},
+		[]string{"outcome"},
+	)
+
+	ShadowRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_shadow_requests_total",
+			Help: "Summaries mirrored to the shadow backend by outcome (mirrored, failed, skipped_busy)",
+		},
+		[]string{"outcome"},
+	)
+
+	ShadowLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_search_shadow_latency_seconds",
+			Help:    "Summary latency of mirrored searches on the primary and the shadow backend",
+			Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30},
+		},
+		[]string{"backend"},
+	)
+
+	ModelSwitches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_search_model_switches_total",
+			Help: "Model switches by backend and outcome (switched, refused_warmup, refused_canary, committed, rolled_back)",
+		},
+		[]string{"backend", "outcome"},
+	)
 )
 
 // MetricsCollector handles system metrics collection
@@ -227,3 +737,301 @@ func RecordInferenceLatency(service, model string, streaming bool, duration time
 	InferenceLatency.WithLabelValues(service, model, streamingStr).Observe(duration.Seconds())
 }
 
+// RecordModelWarmup records the outcome and duration of a model warm-up
+func RecordModelWarmup(service, backend, model string, success bool, duration time.Duration) {
+	status := "success"
+	if !success {
+		status = "error"
+	}
+	ModelWarmupDuration.WithLabelValues(service, backend, model, status).Observe(duration.Seconds())
+}
+
+// RecordPrefixCache records a prompt prefix cache lookup and the prompt
+// processing latency observed for it
+func RecordPrefixCache(service, backend string, hit bool, promptLatency time.Duration) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	PrefixCacheLookups.WithLabelValues(service, backend, result).Inc()
+	if promptLatency > 0 {
+		PromptProcessingLatency.WithLabelValues(service, backend, result).Observe(promptLatency.Seconds())
+	}
+}
+
+// RecordFaultInjected records a fault injected into an RPC
+func RecordFaultInjected(service, method, kind string) {
+	FaultsInjected.WithLabelValues(service, method, kind).Inc()
+}
+
+// RecordSSEResume records the outcome of an SSE reconnect with Last-Event-ID
+func RecordSSEResume(service, result string) {
+	SSEResumes.WithLabelValues(service, result).Inc()
+}
+
+// RecordIdempotentRequest records how a request with an Idempotency-Key was served
+func RecordIdempotentRequest(service, result string) {
+	IdempotentRequests.WithLabelValues(service, result).Inc()
+}
+
+// RecordCoalescedRequest records whether a search ran the pipeline or shared one
+func RecordCoalescedRequest(service, mode, role string) {
+	CoalescedRequests.WithLabelValues(service, mode, role).Inc()
+}
+
+// RecordBudgetExceeded records a request hitting one of its budget limits
+func RecordBudgetExceeded(service, limit string) {
+	BudgetExceeded.WithLabelValues(service, limit).Inc()
+}
+
+// RecordTrackedEntries records how many entries a bookkeeping map holds
+func RecordTrackedEntries(service, kind string, count int) {
+	TrackedEntries.WithLabelValues(service, kind).Set(float64(count))
+}
+
+// RecordLeakedEntries records entries reaped because nothing cleaned them up
+func RecordLeakedEntries(service, kind string, count int) {
+	LeakedEntriesReaped.WithLabelValues(service, kind).Add(float64(count))
+}
+
+// RecordDocumentIngested records the outcome of ingesting one document
+func RecordDocumentIngested(service, outcome string) {
+	DocumentsIngested.WithLabelValues(service, outcome).Inc()
+}
+
+// RecordNotification records the outcome of delivering a notification to a channel
+func RecordNotification(channel, event, outcome string) {
+	NotificationsSent.WithLabelValues(channel, event, outcome).Inc()
+}
+
+// RecordQuotaRejection records a search rejected by a tenant quota window
+func RecordQuotaRejection(tenant, window string) {
+	TenantQuotaRejections.WithLabelValues(tenant, window).Inc()
+}
+
+// RecordRequestRejected records a request rejected by a gateway limit
+func RecordRequestRejected(reason string) {
+	RequestsRejected.WithLabelValues(reason).Inc()
+}
+
+// RecordQueryType records the detected type of a search query
+func RecordQueryType(queryType string) {
+	QueryTypes.WithLabelValues(queryType).Inc()
+}
+
+// RecordInstantAnswer records a query recognized as an instant answer
+func RecordInstantAnswer(kind, outcome string) {
+	InstantAnswers.WithLabelValues(kind, outcome).Inc()
+}
+
+// RecordStructuredAnswer records a query matched by an answer provider
+func RecordStructuredAnswer(provider, outcome string) {
+	StructuredAnswers.WithLabelValues(provider, outcome).Inc()
+}
+
+// RecordKnowledgePanel records the outcome of a knowledge panel extraction
+func RecordKnowledgePanel(outcome string) {
+	KnowledgePanels.WithLabelValues(outcome).Inc()
+}
+
+// RecordRelatedSearches records how a search's related searches were made
+func RecordRelatedSearches(outcome string) {
+	RelatedSearches.WithLabelValues(outcome).Inc()
+}
+
+// RecordImageProxy records how the image proxy served a request
+func RecordImageProxy(outcome string) {
+	ImageProxyRequests.WithLabelValues(outcome).Inc()
+}
+
+// RecordFeatureFlag records a feature flag decided for a request
+func RecordFeatureFlag(flag string, on bool) {
+	result := "off"
+	if on {
+		result = "on"
+	}
+	FeatureFlagEvaluations.WithLabelValues(flag, result).Inc()
+}
+
+// RecordSnippetCleaning records a change made to a snippet for the prompt
+func RecordSnippetCleaning(change string) {
+	SnippetCleaning.WithLabelValues(change).Inc()
+}
+
+// RecordModelRoute records the tier a summary was routed to, why, and its
+// estimated prompt tokens
+func RecordModelRoute(tier, reason string, tokens int) {
+	ModelRoutes.WithLabelValues(tier, reason).Inc()
+	ModelRoutePromptTokens.WithLabelValues(tier).Observe(float64(tokens))
+}
+
+// RecordPromptAudit records the outcome of keeping a summarization in the
+// prompt audit
+func RecordPromptAudit(outcome string) {
+	PromptAuditRecords.WithLabelValues(outcome).Inc()
+}
+
+// RecordRetentionRemoved records entries the retention janitor removed
+func RecordRetentionRemoved(data string, n int) {
+	RetentionRemoved.WithLabelValues(data).Add(float64(n))
+}
+
+// RecordUserDataErased records entries erased at a user's request
+func RecordUserDataErased(data string, n int) {
+	UserDataErased.WithLabelValues(data).Add(float64(n))
+}
+
+// RecordOutboxEvents records events written to the outbox or relayed from it
+func RecordOutboxEvents(outcome string, n int) {
+	OutboxEvents.WithLabelValues(outcome).Add(float64(n))
+}
+
+// RecordStoredObject records a file streamed to object storage and its size
+func RecordStoredObject(outcome string, size int64) {
+	StoredObjects.WithLabelValues(outcome).Inc()
+	StoredObjectBytes.Add(float64(size))
+}
+
+// RecordBatchQuery records a query of a batch job that was run
+func RecordBatchQuery(status string) {
+	BatchQueries.WithLabelValues(status).Inc()
+}
+
+// RecordMaintenanceRun records a run of a maintenance job and, unless it was
+// skipped, how long it ran
+func RecordMaintenanceRun(job, outcome string, seconds float64) {
+	MaintenanceRuns.WithLabelValues(job, outcome).Inc()
+	if outcome != "skipped" {
+		MaintenanceRunDuration.WithLabelValues(job).Observe(seconds)
+	}
+}
+
+// RecordRerank records the outcome of reranking a search's results and how
+// many of them it dropped
+func RecordRerank(outcome string, dropped int) {
+	Reranks.WithLabelValues(outcome).Inc()
+	RerankDroppedResults.Add(float64(dropped))
+}
+
+// RecordSnippetCompression records the outcome of compressing a search's
+// snippets and how many words it left out
+func RecordSnippetCompression(outcome string, dropped int) {
+	SnippetCompressions.WithLabelValues(outcome).Inc()
+	SnippetCompressionDroppedWords.Add(float64(dropped))
+}
+
+// RecordSummaryCache records a lookup in the orchestrator's summary cache
+func RecordSummaryCache(outcome string) {
+	SummaryCache.WithLabelValues(outcome).Inc()
+}
+
+// RecordStreamTruncation records a summary stream ended by the stream guard
+func RecordStreamTruncation(reason string) {
+	StreamTruncations.WithLabelValues(reason).Inc()
+}
+
+// RecordStreamRecovery records an attempt to continue a broken summary
+// stream on the fallback backend
+func RecordStreamRecovery(outcome string) {
+	StreamRecoveries.WithLabelValues(outcome).Inc()
+}
+
+// RecordStreamPool records the idle sessions of the inference stream pool
+func RecordStreamPool(idle int) {
+	StreamPoolIdle.Set(float64(idle))
+}
+
+// RecordStreamPoolClaim records whether a summary stream claimed a ready
+// session
+func RecordStreamPoolClaim(outcome string) {
+	StreamPoolClaims.WithLabelValues(outcome).Inc()
+}
+
+// RecordStreamPoolEviction records an idle session the pool closed
+func RecordStreamPoolEviction(reason string) {
+	StreamPoolEvictions.WithLabelValues(reason).Inc()
+}
+
+// RecordSessionControl records a control message of an LLM stream session
+func RecordSessionControl(action string) {
+	SessionControls.WithLabelValues(action).Inc()
+}
+
+// RecordStreamCancellation records the outcome of a cancel request for a
+// streaming search
+func RecordStreamCancellation(outcome string) {
+	StreamCancellations.WithLabelValues(outcome).Inc()
+}
+
+// RecordLLMTimeout records an orchestrator request or stage that ran out
+// of time
+func RecordLLMTimeout(stage string) {
+	LLMTimeouts.WithLabelValues(stage).Inc()
+}
+
+// RecordPromptSize records the tokens of a summarization prompt before
+// truncation and, when the model's context window is known, the share of it
+// they take
+func RecordPromptSize(model string, tokens, contextWindow int32) {
+	PromptTokens.WithLabelValues(model).Observe(float64(tokens))
+	if contextWindow > 0 {
+		PromptContextUtilization.WithLabelValues(model).Observe(100 * float64(tokens) / float64(contextWindow))
+	}
+}
+
+// RecordPromptTruncation records a summarization prompt cut to fit a limit
+func RecordPromptTruncation(model, limit string) {
+	PromptTruncations.WithLabelValues(model, limit).Inc()
+}
+
+// RecordTokenizerMismatch records a summary refused for its tokenizer version
+func RecordTokenizerMismatch(model string) {
+	TokenizerMismatches.WithLabelValues(model).Inc()
+}
+
+// RecordPromptAssembly records which side assembled a prompt
+func RecordPromptAssembly(assembler string) {
+	PromptAssemblies.WithLabelValues(assembler).Inc()
+}
+
+// RecordSafetyOutputAction records what the safety filter did with a summary
+func RecordSafetyOutputAction(action string) {
+	SafetyOutputActions.WithLabelValues(action).Inc()
+}
+
+// RecordPoliteFetch records the outcome of a page fetch through the
+// politeness layer
+func RecordPoliteFetch(outcome string) {
+	PoliteFetches.WithLabelValues(outcome).Inc()
+}
+
+// RecordPaywalledResult records a web result flagged as paywalled
+func RecordPaywalledResult(signal string) {
+	PaywalledResults.WithLabelValues(signal).Inc()
+}
+
+// RecordResponseCache records a response cache lookup or refresh
+func RecordResponseCache(outcome string) {
+	ResponseCache.WithLabelValues(outcome).Inc()
+}
+
+// RecordPrefetch records a result prefetch or a search answered from one
+func RecordPrefetch(outcome string) {
+	Prefetches.WithLabelValues(outcome).Inc()
+}
+
+// RecordShadow records a summary mirrored to the shadow backend or skipped
+func RecordShadow(outcome string) {
+	ShadowRequests.WithLabelValues(outcome).Inc()
+}
+
+// RecordShadowLatency records how long a mirrored summary took on both backends
+func RecordShadowLatency(primary, shadow time.Duration) {
+	ShadowLatency.WithLabelValues("primary").Observe(primary.Seconds())
+	ShadowLatency.WithLabelValues("shadow").Observe(shadow.Seconds())
+}
+
+// RecordModelSwitch records a model switch, its refusal or how its watch ended
+func RecordModelSwitch(backend, outcome string) {
+	ModelSwitches.WithLabelValues(backend, outcome).Inc()
+}
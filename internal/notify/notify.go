@@ -0,0 +1,219 @@
+// Package notify delivers alerts and scheduled digests to Slack incoming
+// webhooks, email and generic JSON webhooks. A Dispatcher routes each
+// message to the configured channels that subscribe to its event.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// Event names what a message is about; channels subscribe to events
+type Event string
+
+const (
+	EventDigest      Event = "scheduled_digest" // a saved query's summary changed
+	EventSafetyBlock Event = "safety_block"     // input blocked as an attack pattern
+	EventOverload    Event = "overload"         // sustained rejections at a capacity limit
+)
+
+// Severity orders messages; channels drop those below their minimum
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Critical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	}
+	return "info"
+}
+
+// ParseSeverity reads info, warning or critical; empty means info
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(name) {
+	case "", "info":
+		return Info, nil
+	case "warning":
+		return Warning, nil
+	case "critical":
+		return Critical, nil
+	}
+	return Info, fmt.Errorf("unknown severity %q", name)
+}
+
+// Field is a labelled fact shown with a message
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Message is one alert or digest
+type Message struct {
+	Event    Event    `json:"event"`
+	Severity Severity `json:"-"`
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	Fields   []Field  `json:"fields,omitempty"`
+	// Key identifies repeats of the same alert for channel cooldowns;
+	// empty uses the event
+	Key string `json:"-"`
+	// Payload, when set, is the body webhook channels post instead of the
+	// message itself
+	Payload interface{} `json:"-"`
+}
+
+func (m Message) key() string {
+	if m.Key != "" {
+		return m.Key
+	}
+	return string(m.Event)
+}
+
+// Sender delivers a message to one destination
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type channel struct {
+	name        string
+	sender      Sender
+	events      map[Event]bool
+	minSeverity Severity
+	cooldown    time.Duration
+}
+
+// Dispatcher routes messages to the configured channels
+type Dispatcher struct {
+	smtp     config.SMTPConfig
+	client   *http.Client
+	channels []*channel
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // channel and message key -> last delivery
+}
+
+// New builds the channels of cfg. A dispatcher without channels delivers
+// nothing but can still send to explicit destinations.
+func New(cfg config.NotificationsConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		smtp:     cfg.SMTP,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		lastSent: make(map[string]time.Time),
+	}
+	seen := make(map[string]bool)
+	for _, ch := range cfg.Channels {
+		if ch.Name == "" || seen[ch.Name] {
+			return nil, fmt.Errorf("notification channels need unique names, got %q", ch.Name)
+		}
+		seen[ch.Name] = true
+
+		var sender Sender
+		switch ch.Type {
+		case "slack":
+			if ch.URL == "" {
+				return nil, fmt.Errorf("notification channel %s: slack needs url", ch.Name)
+			}
+			sender = d.Slack(ch.URL)
+		case "webhook":
+			if ch.URL == "" {
+				return nil, fmt.Errorf("notification channel %s: webhook needs url", ch.Name)
+			}
+			sender = d.Webhook(ch.URL)
+		case "email":
+			if len(ch.To) == 0 || cfg.SMTP.Host == "" {
+				return nil, fmt.Errorf("notification channel %s: email needs recipients and notifications.smtp.host", ch.Name)
+			}
+			sender = d.Email(ch.To)
+		default:
+			return nil, fmt.Errorf("notification channel %s: unknown type %q", ch.Name, ch.Type)
+		}
+
+		minSeverity, err := ParseSeverity(ch.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("notification channel %s: %w", ch.Name, err)
+		}
+		events := make(map[Event]bool, len(ch.Events))
+		for _, event := range ch.Events {
+			switch Event(event) {
+			case EventDigest, EventSafetyBlock, EventOverload:
+				events[Event(event)] = true
+			default:
+				return nil, fmt.Errorf("notification channel %s: unknown event %q", ch.Name, event)
+			}
+		}
+		d.channels = append(d.channels, &channel{name: ch.Name, sender: sender, events: events, minSeverity: minSeverity, cooldown: ch.Cooldown})
+	}
+	return d, nil
+}
+
+// Notify delivers msg to every subscribed channel, skipping channels that
+// sent the same alert within their cooldown, and returns the delivery errors
+func (d *Dispatcher) Notify(ctx context.Context, msg Message) error {
+	log := logger.GetLogger()
+	var errs []error
+	for _, ch := range d.channels {
+		if !ch.events[msg.Event] || msg.Severity < ch.minSeverity || !d.due(ch, msg) {
+			continue
+		}
+		if err := ch.sender.Send(ctx, msg); err != nil {
+			log.Warnf("Failed to deliver %s notification to %s: %v", msg.Event, ch.name, err)
+			monitoring.RecordNotification(ch.name, string(msg.Event), "error")
+			errs = append(errs, fmt.Errorf("%s: %w", ch.name, err))
+			continue
+		}
+		monitoring.RecordNotification(ch.name, string(msg.Event), "sent")
+	}
+	return errors.Join(errs...)
+}
+
+// Publish delivers msg in the background, for callers on a request path
+func (d *Dispatcher) Publish(msg Message) {
+	if d == nil || len(d.channels) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		d.Notify(ctx, msg)
+	}()
+}
+
+// due reserves the delivery of msg on ch unless it is still cooling down
+func (d *Dispatcher) due(ch *channel, msg Message) bool {
+	if ch.cooldown <= 0 {
+		return true
+	}
+	key := ch.name + "\x00" + msg.key()
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.lastSent[key]; ok && now.Sub(last) < ch.cooldown {
+		monitoring.RecordNotification(ch.name, string(msg.Event), "suppressed")
+		return false
+	}
+	for k, last := range d.lastSent {
+		if now.Sub(last) >= ch.cooldown {
+			delete(d.lastSent, k)
+		}
+	}
+	d.lastSent[key] = now
+	return true
+}
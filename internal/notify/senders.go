@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+
+	"ai-search-service/internal/config"
+)
+
+// Slack returns a sender posting to a Slack incoming webhook
+func (d *Dispatcher) Slack(webhookURL string) Sender {
+	return &slackSender{url: webhookURL, client: d.client}
+}
+
+// Webhook returns a sender posting the message, or its Payload, as JSON
+func (d *Dispatcher) Webhook(endpoint string) Sender {
+	return &webhookSender{url: endpoint, client: d.client}
+}
+
+// Email returns a sender mailing the recipients through the configured SMTP
+// server; sending fails when none is configured
+func (d *Dispatcher) Email(to []string) Sender {
+	return &emailSender{smtp: d.smtp, to: to}
+}
+
+type slackSender struct {
+	url    string
+	client *http.Client
+}
+
+var severityEmoji = map[Severity]string{Info: ":information_source:", Warning: ":warning:", Critical: ":rotating_light:"}
+
+func (s *slackSender) Send(ctx context.Context, msg Message) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s *%s*\n%s", severityEmoji[msg.Severity], msg.Title, msg.Text)
+	for _, field := range msg.Fields {
+		fmt.Fprintf(&text, "\n• *%s:* %s", field.Name, field.Value)
+	}
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": text.String()})
+}
+
+type webhookSender struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSender) Send(ctx context.Context, msg Message) error {
+	if msg.Payload != nil {
+		return postJSON(ctx, s.client, s.url, msg.Payload)
+	}
+	return postJSON(ctx, s.client, s.url, struct {
+		Message
+		Severity string `json:"severity"`
+	}{msg, msg.Severity.String()})
+}
+
+func postJSON(ctx context.Context, client *http.Client, endpoint string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+type emailSender struct {
+	smtp config.SMTPConfig
+	to   []string
+}
+
+func (s *emailSender) Send(ctx context.Context, msg Message) error {
+	if s.smtp.Host == "" {
+		return fmt.Errorf("no SMTP server is configured")
+	}
+	for _, address := range s.to {
+		if strings.ContainsAny(address, "\r\n") {
+			return fmt.Errorf("invalid email address %q", address)
+		}
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\n", s.smtp.From, strings.Join(s.to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Title))
+	fmt.Fprintf(&body, "%s\r\n", msg.Text)
+	for _, field := range msg.Fields {
+		fmt.Fprintf(&body, "\r\n%s: %s", field.Name, field.Value)
+	}
+
+	var auth smtp.Auth
+	if s.smtp.Username != "" {
+		auth = smtp.PlainAuth("", s.smtp.Username, s.smtp.Password, s.smtp.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", s.smtp.Host, s.smtp.Port)
+	return smtp.SendMail(addr, auth, s.smtp.From, s.to, []byte(body.String()))
+}
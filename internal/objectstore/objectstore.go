@@ -0,0 +1,258 @@
+// Package objectstore keeps generated artifacts such as report exports in
+// object storage and hands out signed, expiring download URLs for them, so
+// that large files are streamed through rather than held in memory.
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+)
+
+// Backends of gateway.exports.storage.backend
+const (
+	BackendS3         = "s3"
+	BackendFilesystem = "filesystem"
+)
+
+// Store keeps objects by key
+type Store interface {
+	// Put streams body to key, returning how many bytes it stored
+	Put(ctx context.Context, key, contentType string, body io.Reader) (int64, error)
+	// URL signs a download of key. The filesystem store returns a path on
+	// the gateway.
+	URL(ctx context.Context, key string, download Download) (string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Download describes the response to a signed URL
+type Download struct {
+	Filename    string // saved as
+	ContentType string
+	TTL         time.Duration // how long the URL stays valid
+}
+
+// New returns the configured store, or nil without a backend
+func New(cfg config.ObjectStorageConfig) (Store, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case BackendS3:
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("objectstore: the s3 backend needs an endpoint and a bucket")
+		}
+		client, err := minio.New(cfg.Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+			Secure: !cfg.Insecure,
+			Region: cfg.Region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("objectstore: %w", err)
+		}
+		return &s3Store{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+	case BackendFilesystem:
+		if cfg.Directory == "" {
+			return nil, fmt.Errorf("objectstore: the filesystem backend needs a directory")
+		}
+		if err := os.MkdirAll(cfg.Directory, 0o750); err != nil {
+			return nil, fmt.Errorf("objectstore: %w", err)
+		}
+		secret := []byte(cfg.SigningKey)
+		if len(secret) == 0 {
+			logger.GetLogger().Warn("No exports.storage.signing_key set: export URLs stop working on restart and are not valid across replicas")
+			secret = make([]byte, 32)
+			rand.Read(secret)
+		}
+		return &Filesystem{dir: cfg.Directory, prefix: cfg.Prefix, secret: secret}, nil
+	default:
+		return nil, fmt.Errorf("objectstore: unknown backend %q (s3 or filesystem)", cfg.Backend)
+	}
+}
+
+// s3Store keeps objects in a bucket through the S3 API. Bodies of unknown
+// size are uploaded in parts, so one part at a time is buffered.
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func (s *s3Store) Put(ctx context.Context, key, contentType string, body io.Reader) (int64, error) {
+	info, err := s.client.PutObject(ctx, s.bucket, s.prefix+key, body, -1, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+func (s *s3Store) URL(ctx context.Context, key string, download Download) (string, error) {
+	params := url.Values{
+		"response-content-disposition": {attachment(download.Filename)},
+		"response-content-type":        {download.ContentType},
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, s.prefix+key, download.TTL, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, s.prefix+key, minio.RemoveObjectOptions{})
+}
+
+// Filesystem keeps objects as files under a directory, downloaded from the
+// gateway at /exports/<key> with an HMAC-signed expiry
+type Filesystem struct {
+	dir    string
+	prefix string
+	secret []byte
+}
+
+// path maps a key to its file, refusing keys that leave the directory
+func (f *Filesystem) path(key string) (string, error) {
+	clean := path.Clean("/" + f.prefix + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("objectstore: invalid key %q", key)
+	}
+	return filepath.Join(f.dir, filepath.FromSlash(clean)), nil
+}
+
+func (f *Filesystem) Put(ctx context.Context, key, contentType string, body io.Reader) (int64, error) {
+	name, err := f.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return 0, err
+	}
+	// Written aside and renamed, so a download never sees part of a file
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), name)
+}
+
+func (f *Filesystem) URL(ctx context.Context, key string, download Download) (string, error) {
+	if _, err := f.path(key); err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"expires":  {strconv.FormatInt(time.Now().Add(download.TTL).Unix(), 10)},
+		"filename": {download.Filename},
+		"type":     {download.ContentType},
+	}
+	query.Set("signature", f.sign(key, query))
+	return "/exports/" + key + "?" + query.Encode(), nil
+}
+
+func (f *Filesystem) Delete(ctx context.Context, key string) error {
+	name, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sign covers the key and the parameters of its download
+func (f *Filesystem) sign(key string, query url.Values) string {
+	mac := hmac.New(sha256.New, f.secret)
+	for _, value := range []string{key, query.Get("expires"), query.Get("filename"), query.Get("type")} {
+		mac.Write([]byte(value + "\n"))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Serve answers a download of key signed by URL: 403 for a bad or expired
+// signature, 404 once the file is gone
+func (f *Filesystem) Serve(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	unix, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(query.Get("signature")), []byte(f.sign(key, query))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > unix {
+		http.Error(w, "link expired", http.StatusForbidden)
+		return
+	}
+	name, err := f.path(key)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Disposition", attachment(query.Get("filename")))
+	if contentType := query.Get("type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, query.Get("filename"), info.ModTime(), file)
+}
+
+// Expire deletes the files written before the given time, returning how
+// many it deleted; buckets expire objects with a lifecycle rule instead
+func (f *Filesystem) Expire(ctx context.Context, before time.Time) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(f.dir, func(name string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(before) {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed++
+		}
+		return ctx.Err()
+	})
+	return removed, err
+}
+
+// attachment is the Content-Disposition saving a download as filename
+func attachment(filename string) string {
+	return fmt.Sprintf("attachment; filename=%q", filename)
+}
@@ -0,0 +1,331 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxStreamLineBytes  = 1024 * 1024
+)
+
+// StatusError is returned when Ollama answers with a non-2xx status code
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ollama returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// retryable reports whether a failed call is worth retrying
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	// Context cancellation is final; anything else is a transport error
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Client is a typed client for the Ollama HTTP API
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	cfg          config.OllamaConfig
+	modelMutex   sync.RWMutex
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// NewClient creates an Ollama client from configuration
+func NewClient(cfg config.OllamaConfig) *Client {
+	return &Client{
+		baseURL: fmt.Sprintf("http://%s:%d", cfg.Host, cfg.Port),
+		// No client-level timeout: streams are bounded by the request context instead
+		httpClient:   &http.Client{},
+		cfg:          cfg,
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+}
+
+// SetTransport replaces the HTTP transport, e.g. to record or replay upstream calls
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = rt
+}
+
+// NewRequest builds a generate request populated with the configured model defaults
+func (c *Client) NewRequest(prompt string, maxTokens int) *GenerateRequest {
+	if maxTokens <= 0 {
+		maxTokens = c.cfg.MaxTokens
+	}
+	req := &GenerateRequest{
+		Model:  c.Model(),
+		Prompt: prompt,
+		Options: &Options{
+			Temperature: c.cfg.Temperature,
+			NumPredict:  maxTokens,
+		},
+	}
+	if c.cfg.KeepAlive > 0 {
+		req.KeepAlive = c.cfg.KeepAlive.String()
+	}
+	return req
+}
+
+// Model returns the default model used by NewRequest
+func (c *Client) Model() string {
+	c.modelMutex.RLock()
+	defer c.modelMutex.RUnlock()
+	return c.cfg.Model
+}
+
+// SetModel changes the default model used by NewRequest
+func (c *Client) SetModel(model string) {
+	c.modelMutex.Lock()
+	defer c.modelMutex.Unlock()
+	c.cfg.Model = model
+}
+
+// Generate runs a non-streaming completion, retrying transient failures
+func (c *Client) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	body := *req
+	body.Stream = false
+
+	var result *GenerateResponse
+	err := c.withRetries(ctx, "generate", func(ctx context.Context) error {
+		resp, err := c.post(ctx, "/api/generate", &body)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var out GenerateResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return fmt.Errorf("failed to decode generate response: %w", err)
+		}
+		if out.Error != "" {
+			return fmt.Errorf("ollama generate error: %s", out.Error)
+		}
+		result = &out
+		return nil
+	})
+	return result, err
+}
+
+// Embed returns an embedding for each input text, retrying transient failures
+func (c *Client) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var result *EmbedResponse
+	err := c.withRetries(ctx, "embed", func(ctx context.Context) error {
+		resp, err := c.post(ctx, "/api/embed", req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var out EmbedResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return fmt.Errorf("failed to decode embed response: %w", err)
+		}
+		if out.Error != "" {
+			return fmt.Errorf("ollama embed error: %s", out.Error)
+		}
+		if len(out.Embeddings) != len(req.Input) {
+			return &permanentError{fmt.Errorf("ollama returned %d embeddings for %d inputs", len(out.Embeddings), len(req.Input))}
+		}
+		result = &out
+		return nil
+	})
+	return result, err
+}
+
+// GenerateStream runs a streaming completion and invokes fn for every chunk.
+// The call is only retried if the stream fails before the first chunk arrives,
+// so callers never observe duplicated output.
+func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest, fn func(*GenerateResponse) error) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	body := *req
+	body.Stream = true
+
+	return c.withRetries(ctx, "generate_stream", func(ctx context.Context) error {
+		resp, err := c.post(ctx, "/api/generate", &body)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		delivered := 0
+		err = readStream(resp.Body, func(chunk *GenerateResponse) error {
+			delivered++
+			return fn(chunk)
+		})
+		if err != nil && delivered > 0 {
+			// Partial output was already delivered - do not retry
+			return &permanentError{err}
+		}
+		return err
+	})
+}
+
+// withTimeout applies the configured timeout when the caller set no deadline
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, hasDeadline := ctx.Deadline(); hasDeadline || c.cfg.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.cfg.Timeout)
+}
+
+// Version returns the Ollama server version and doubles as a liveness probe
+func (c *Client) Version(ctx context.Context) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", decodeStatusError(resp)
+	}
+	var out VersionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode version response: %w", err)
+	}
+	return out.Version, nil
+}
+
+// Heartbeat reports whether the Ollama server is reachable
+func (c *Client) Heartbeat(ctx context.Context) error {
+	_, err := c.Version(ctx)
+	return err
+}
+
+// readStream decodes newline-delimited JSON chunks until the final chunk
+func readStream(r io.Reader, fn func(*GenerateResponse) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineBytes)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk GenerateResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+		if err := fn(&chunk); err != nil {
+			return err
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// post sends a JSON body and returns the response when the status is 2xx
+func (c *Client) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach ollama: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, decodeStatusError(resp)
+	}
+	return resp, nil
+}
+
+// permanentError marks a failure that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// withRetries runs fn with exponential backoff on transient failures
+func (c *Client) withRetries(ctx context.Context, op string, fn func(context.Context) error) error {
+	log := logger.GetLogger()
+	backoff := c.retryBackoff
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			log.Warnf("Retrying ollama %s (attempt %d/%d) after error: %v", op, attempt, c.maxRetries, lastErr)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		lastErr = fn(ctx)
+		if lastErr == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(lastErr, &permanent) {
+			return permanent.err
+		}
+		if !retryable(lastErr) {
+			return lastErr
+		}
+	}
+	return fmt.Errorf("ollama %s failed after %d retries: %w", op, c.maxRetries, lastErr)
+}
+
+func decodeStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var errResp errorResponse
+	message := string(body)
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		message = errResp.Error
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Message: message}
+}
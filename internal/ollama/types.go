@@ -0,0 +1,64 @@
+package ollama
+
+// Options are the model parameters Ollama accepts under "options"
+type Options struct {
+	Temperature      float64  `json:"temperature"`
+	NumPredict       int      `json:"num_predict,omitempty"` // max tokens to generate
+	TopP             float64  `json:"top_p,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"` // nil for a random seed
+}
+
+// GenerateRequest is the body of POST /api/generate
+type GenerateRequest struct {
+	Model     string   `json:"model"`
+	Prompt    string   `json:"prompt"`
+	System    string   `json:"system,omitempty"`
+	Stream    bool     `json:"stream"`
+	KeepAlive string   `json:"keep_alive,omitempty"`
+	Format    string   `json:"format,omitempty"` // "json" constrains the output to JSON
+	Options   *Options `json:"options,omitempty"`
+}
+
+// GenerateResponse is a single (streamed or final) response from /api/generate
+type GenerateResponse struct {
+	Model              string `json:"model"`
+	CreatedAt          string `json:"created_at"`
+	Response           string `json:"response"`
+	Done               bool   `json:"done"`
+	DoneReason         string `json:"done_reason,omitempty"`
+	Context            []int  `json:"context,omitempty"`
+	TotalDuration      int64  `json:"total_duration,omitempty"` // nanoseconds
+	LoadDuration       int64  `json:"load_duration,omitempty"`
+	PromptEvalCount    int    `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int    `json:"eval_count,omitempty"`
+	EvalDuration       int64  `json:"eval_duration,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// EmbedRequest is the body of POST /api/embed
+type EmbedRequest struct {
+	Model     string   `json:"model"`
+	Input     []string `json:"input"`
+	KeepAlive string   `json:"keep_alive,omitempty"`
+}
+
+// EmbedResponse is the body returned by /api/embed, one vector per input
+type EmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// VersionResponse is the body returned by GET /api/version
+type VersionResponse struct {
+	Version string `json:"version"`
+}
+
+// errorResponse is the body Ollama returns alongside non-2xx status codes
+type errorResponse struct {
+	Error string `json:"error"`
+}
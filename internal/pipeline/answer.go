@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Query types, detected from the query alone before searching
+const (
+	QueryQuestion     = "question"     // a factual question with a short answer
+	QueryComparison   = "comparison"   // "best X", "X vs Y": options to weigh
+	QueryNavigational = "navigational" // looking for a particular site
+	QueryExploratory  = "exploratory"  // a topic to read about
+)
+
+// Answer formats the summary is written in
+const (
+	AnswerDirect  = "direct"  // a sentence or two naming its source
+	AnswerList    = "list"    // one bullet per option
+	AnswerSummary = "summary" // a summary of the results
+)
+
+// Answer is the detected query type and the format the summary was asked for
+type Answer struct {
+	QueryType string `json:"query_type"`
+	Format    string `json:"format"`
+}
+
+// answerStrategy is how the summary is requested for a query type: the
+// instruction leading the summarization text (%q is the query) and a cap
+// on its length (0 for none)
+type answerStrategy struct {
+	format      string
+	instruction string
+	maxTokens   int32
+}
+
+var answerStrategies = map[string]answerStrategy{
+	QueryQuestion: {
+		format:      AnswerDirect,
+		instruction: "Answer the question %q directly in one or two sentences, then name the source the answer comes from.\n\n",
+		maxTokens:   64,
+	},
+	QueryComparison: {
+		format:      AnswerList,
+		instruction: "The query %q compares options. Answer with a bulleted list, one bullet per option the sources mention and what sets it apart.\n\n",
+	},
+	QueryNavigational: {
+		format:      AnswerDirect,
+		instruction: "The query %q looks for a particular site. Name the site and its address from the sources in one sentence.\n\n",
+		maxTokens:   48,
+	},
+	QueryExploratory: {format: AnswerSummary},
+}
+
+var (
+	// questionWords start questions with a short factual answer. "How" and
+	// "why" ask for explanations, which are better summarized, unless they
+	// ask for a quantity.
+	questionWords = map[string]bool{
+		"who": true, "whom": true, "whose": true, "what": true, "when": true,
+		"where": true, "which": true, "is": true, "are": true, "was": true,
+		"were": true, "does": true, "do": true, "did": true, "can": true,
+		"will": true,
+	}
+	howQuantities = map[string]bool{"many": true, "much": true, "old": true, "long": true, "far": true, "tall": true, "big": true}
+
+	comparisonPattern   = regexp.MustCompile(`^(best|top)\b|\b(best|top \d+|vs\.?|versus|compare|comparison|difference between|alternatives?( to)?)\b`)
+	navigationalPattern = regexp.MustCompile(`^(https?://|www\.)|^[a-z0-9-]+(\.[a-z0-9-]+)*\.[a-z]{2,}(/\S*)?$|\b(login|log in|sign in|homepage|official (site|website))\b`)
+)
+
+// classifyQuery tells what kind of answer a query wants from its wording.
+// It is deliberately cheap: a misclassified query still gets a summary of
+// the same results, only in a less fitting format.
+func classifyQuery(query string) *Answer {
+	q := strings.ToLower(strings.TrimSpace(query))
+	words := strings.Fields(q)
+
+	queryType := QueryExploratory
+	switch {
+	case len(words) == 0:
+	case comparisonPattern.MatchString(q):
+		queryType = QueryComparison
+	case navigationalPattern.MatchString(q):
+		queryType = QueryNavigational
+	case questionWords[words[0]], strings.HasSuffix(q, "?") && words[0] != "how" && words[0] != "why":
+		queryType = QueryQuestion
+	case words[0] == "how" && len(words) > 1 && howQuantities[words[1]]:
+		queryType = QueryQuestion
+	}
+	return &Answer{QueryType: queryType, Format: answerStrategies[queryType].format}
+}
+
+// instruction returns the text leading the summarization text for the
+// answer's format, or "" for a plain summary
+func (a *Answer) instruction(query string) string {
+	if text := answerStrategies[a.QueryType].instruction; text != "" {
+		return fmt.Sprintf(text, query)
+	}
+	return ""
+}
+
+// maxTokens caps the summary length for short answer formats
+func (a *Answer) maxTokens(requested int32) int32 {
+	limit := answerStrategies[a.QueryType].maxTokens
+	if limit > 0 && (requested <= 0 || limit < requested) {
+		return limit
+	}
+	return requested
+}
@@ -0,0 +1,26 @@
+package pipeline
+
+import (
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// audit hands the summarization's LLM request and outcome to the request's
+// Audit hook. Nothing is recorded when a custom stage failed the run before
+// the request was built.
+func (e *Engine) audit(req *Request, llmReq *pb.LLMRequest, generated, served string, failed *Error) {
+	if req.Audit == nil || llmReq == nil {
+		return
+	}
+	defer func() {
+		// A broken audit hook must not fail the search it records
+		if r := recover(); r != nil {
+			logger.GetLogger().Errorf("Audit hook panicked: %v", r)
+		}
+	}()
+	var err error
+	if failed != nil {
+		err = failed
+	}
+	req.Audit(llmReq, generated, served, err)
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// compressSnippets shortens each snippet over req.CompressWords words to
+// its sentences most similar to the query under the embedding model, in
+// their original order, so more sources fit in the summarization prompt. A
+// snippet keeps at least its best sentence. If embedding fails the snippets
+// are left as they are.
+func (e *Engine) compressSnippets(req *Request, query string, results []Result) []Result {
+	if req.CompressWords <= 0 {
+		return results
+	}
+	texts := []string{query}
+	sentences := make([][]string, len(results))
+	for i, result := range results {
+		if len(strings.Fields(result.Snippet)) <= req.CompressWords {
+			continue
+		}
+		if sentences[i] = splitSentences(result.Snippet); len(sentences[i]) < 2 {
+			sentences[i] = nil
+			continue
+		}
+		texts = append(texts, sentences[i]...)
+	}
+	if len(texts) == 1 {
+		return results
+	}
+
+	ctx, cancel := req.StageContext(StageCompress)
+	defer cancel()
+	resp, err := e.inference.Embed(ctx, &pb.EmbedRequest{Texts: texts})
+	if err == nil && len(resp.Embeddings) != len(texts) {
+		err = fmt.Errorf("%d embeddings for %d texts", len(resp.Embeddings), len(texts))
+	}
+	if err != nil {
+		logger.GetLogger().Warnf("Compressing snippets failed: %v", err)
+		monitoring.RecordSnippetCompression("failed", 0)
+		return results
+	}
+
+	queryVector := resp.Embeddings[0].Values
+	next := 1
+	compressed := make([]Result, len(results))
+	dropped := 0
+	for i, result := range results {
+		compressed[i] = result
+		if sentences[i] == nil {
+			continue
+		}
+		similarity := make([]float64, len(sentences[i]))
+		for n := range sentences[i] {
+			similarity[n] = cosine(queryVector, resp.Embeddings[next].Values)
+			next++
+		}
+		keep := selectSentences(sentences[i], similarity, req.CompressWords)
+		var kept []string
+		for n, sentence := range sentences[i] {
+			if keep[n] {
+				kept = append(kept, sentence)
+			}
+		}
+		compressed[i].Snippet = strings.Join(kept, " ")
+		dropped += len(strings.Fields(result.Snippet)) - len(strings.Fields(compressed[i].Snippet))
+	}
+	monitoring.RecordSnippetCompression("compressed", dropped)
+	return compressed
+}
+
+// selectSentences picks sentences by descending similarity, skipping those
+// that would take the total over maxWords; the most similar is always kept.
+// Ties keep the earlier sentence.
+func selectSentences(sentences []string, similarity []float64, maxWords int) []bool {
+	order := make([]int, len(sentences))
+	for n := range order {
+		order[n] = n
+	}
+	sort.SliceStable(order, func(a, b int) bool { return similarity[order[a]] > similarity[order[b]] })
+
+	keep := make([]bool, len(sentences))
+	words := 0
+	for rank, n := range order {
+		length := len(strings.Fields(sentences[n]))
+		if rank > 0 && words+length > maxWords {
+			continue
+		}
+		keep[n] = true
+		words += length
+	}
+	return keep
+}
+
+// cosine is the cosine similarity of two vectors, 0 when either is zero
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for n := 0; n < len(a) && n < len(b); n++ {
+		dot += float64(a[n]) * float64(b[n])
+		normA += float64(a[n]) * float64(a[n])
+		normB += float64(b[n]) * float64(b[n])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
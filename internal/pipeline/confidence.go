@@ -0,0 +1,203 @@
+package pipeline
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Confidence levels of a summary, for clients to badge answers
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+const (
+	// minSnippetWords is how many words a result's snippet needs to count as
+	// content; shorter ones are usually just a title echo or a date
+	minSnippetWords = 8
+
+	// Thin results (fewer than two usable sources or thinWords words) get a
+	// short summary and an instruction to admit missing information; rich
+	// results (richSources sources and richWords words) the full length
+	thinWords   = 40
+	richSources = 4
+	richWords   = 100
+
+	// minSummaryTokens is the least a summary is scaled down to
+	minSummaryTokens = 32
+
+	// Weights of the signals in the confidence score. Without a model
+	// probability the other two share its weight.
+	modelWeight        = 0.4
+	groundednessWeight = 0.4
+	agreementWeight    = 0.2
+
+	// Scores at or above these are high and medium confidence
+	highScore   = 0.7
+	mediumScore = 0.4
+
+	// minSharedWords is how many content words a source must share with the
+	// summary to count as agreeing with it
+	minSharedWords = 2
+)
+
+// InsufficientInformationNote leads the summarization text when the results
+// are thin, so the model says what is missing instead of writing filler. It
+// follows the shared instruction prefix, which stays cacheable.
+const InsufficientInformationNote = "The sources below contain little information. If they do not answer the query, say there is not enough information to answer it instead of guessing.\n\n"
+
+// Confidence describes how well the search results cover the query, and so
+// how far the summary built from them can be trusted. Coverage is known
+// before the summary is generated; the score and its signals once it is.
+type Confidence struct {
+	Level   string  `json:"level"`   // high, medium or low, the lower of coverage and score
+	Score   float64 `json:"score"`   // 0-1, the weighted signals below
+	Sources int     `json:"sources"` // results with usable content
+	Words   int     `json:"words"`   // words of content across them
+
+	// ModelProbability is the mean token probability reported by the model,
+	// omitted when the inference backend does not report one
+	ModelProbability float64 `json:"model_probability,omitempty"`
+	// Groundedness is the share of the summary's content words found in the
+	// results; a low value suggests the model made things up
+	Groundedness float64 `json:"groundedness"`
+	// Agreement is the share of usable results the summary draws on; a low
+	// value means it rests on a single source
+	Agreement float64 `json:"agreement"`
+}
+
+// assessConfidence rates the results a summary will be built from by how
+// many carry real content and how much
+func assessConfidence(results []Result) *Confidence {
+	c := &Confidence{}
+	for _, result := range results {
+		if words := len(strings.Fields(result.Snippet)); words >= minSnippetWords {
+			c.Sources++
+			c.Words += words
+		}
+	}
+	switch {
+	case c.Sources < 2 || c.Words < thinWords:
+		c.Level = ConfidenceLow
+	case c.Sources >= richSources && c.Words >= richWords:
+		c.Level = ConfidenceHigh
+	default:
+		c.Level = ConfidenceMedium
+	}
+	return c
+}
+
+// maxTokens scales the requested summary length to the content available:
+// a summary runs to at most about two thirds of the source words, so two
+// short snippets do not get padded out to a full-length answer
+func (c *Confidence) maxTokens(requested int32) int32 {
+	if requested <= 0 || c.Level == ConfidenceHigh {
+		return requested
+	}
+	limit := int32(c.Words * 2 / 3)
+	if limit < minSummaryTokens {
+		limit = minSummaryTokens
+	}
+	if limit < requested {
+		return limit
+	}
+	return requested
+}
+
+// score rates the generated summary against the results it was built from,
+// combining the model's own probability with groundedness and agreement,
+// and lowers the level when the score is below what coverage suggested
+func (c *Confidence) score(summary string, results []Result) {
+	words := contentWords(summary)
+	if len(words) > 0 {
+		var sourceWords []map[string]bool
+		all := make(map[string]bool)
+		for _, result := range results {
+			resultWords := contentWords(result.Title + " " + result.Snippet)
+			for word := range resultWords {
+				all[word] = true
+			}
+			if len(strings.Fields(result.Snippet)) >= minSnippetWords {
+				sourceWords = append(sourceWords, resultWords)
+			}
+		}
+
+		grounded := 0
+		for word := range words {
+			if all[word] {
+				grounded++
+			}
+		}
+		c.Groundedness = round2(float64(grounded) / float64(len(words)))
+
+		agreeing := 0
+		for _, source := range sourceWords {
+			shared := 0
+			for word := range words {
+				if source[word] {
+					shared++
+				}
+			}
+			if shared >= minSharedWords {
+				agreeing++
+			}
+		}
+		if len(sourceWords) > 0 {
+			c.Agreement = round2(float64(agreeing) / float64(len(sourceWords)))
+		}
+	}
+
+	score := (groundednessWeight*c.Groundedness + agreementWeight*c.Agreement) / (groundednessWeight + agreementWeight)
+	if c.ModelProbability > 0 {
+		score = modelWeight*c.ModelProbability + groundednessWeight*c.Groundedness + agreementWeight*c.Agreement
+	}
+	c.Score = round2(score)
+
+	level := ConfidenceLow
+	switch {
+	case c.Score >= highScore:
+		level = ConfidenceHigh
+	case c.Score >= mediumScore:
+		level = ConfidenceMedium
+	}
+	if levelRank[level] < levelRank[c.Level] {
+		c.Level = level
+	}
+}
+
+var levelRank = map[string]int{ConfidenceLow: 0, ConfidenceMedium: 1, ConfidenceHigh: 2}
+
+// stopWords are common words long enough to pass the content word filter
+var stopWords = map[string]bool{
+	"about": true, "also": true, "been": true, "between": true, "could": true,
+	"from": true, "have": true, "into": true, "more": true, "other": true,
+	"some": true, "such": true, "than": true, "that": true, "their": true,
+	"them": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "those": true, "what": true, "when": true, "where": true,
+	"which": true, "while": true, "will": true, "with": true, "would": true,
+}
+
+// contentWords returns the distinct words of text that carry meaning:
+// lowercased, four letters or more, not stop words, with a plural s removed
+// so "goroutine" and "goroutines" match
+func contentWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 4 || stopWords[word] {
+			continue
+		}
+		if len(word) > 4 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = word[:len(word)-1]
+		}
+		words[word] = true
+	}
+	return words
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
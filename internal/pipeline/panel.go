@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// KnowledgePanel is the entity a query is about, for a card next to the
+// summary
+type KnowledgePanel struct {
+	Name        string           `json:"name"`
+	Type        string           `json:"type"` // person, organization, place, product, work, event, concept or other
+	Description string           `json:"description,omitempty"`
+	Attributes  []PanelAttribute `json:"attributes,omitempty"` // key facts, e.g. "Born"
+	ImageURL    string           `json:"image_url,omitempty"`
+	SourceURL   string           `json:"source_url,omitempty"` // the result it was found in
+}
+
+// PanelAttribute is a key fact about the entity
+type PanelAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// panelExtraction is a knowledge panel being extracted while the summary is
+// generated
+type panelExtraction struct {
+	done      chan *KnowledgePanel
+	delivered bool
+}
+
+// extractPanel starts extracting a knowledge panel from the top results.
+// Comparisons and navigational queries are about several entities or a site
+// rather than one, so they get none; nor do requests with panels disabled.
+func (e *Engine) extractPanel(req *Request, query string, results []Result, answer *Answer) *panelExtraction {
+	if req.PanelResults <= 0 || len(results) == 0 || answer.QueryType == QueryComparison || answer.QueryType == QueryNavigational {
+		return nil
+	}
+	if len(results) > req.PanelResults {
+		results = results[:req.PanelResults]
+	}
+	extractReq := &pb.ExtractEntitiesRequest{Query: query, Results: make([]*pb.SearchResult, len(results))}
+	for i, result := range results {
+		extractReq.Results[i] = &pb.SearchResult{Title: result.Title, Url: result.URL, Snippet: result.Snippet, ImageUrl: result.ImageURL}
+	}
+
+	ctx, cancel := req.StageContext(StageExtract)
+	p := &panelExtraction{done: make(chan *KnowledgePanel, 1)}
+	go func() {
+		defer cancel()
+		resp, err := e.inference.ExtractEntities(ctx, extractReq)
+		switch {
+		case err != nil:
+			logger.GetLogger().Warnf("Knowledge panel extraction failed: %v", err)
+			monitoring.RecordKnowledgePanel("failed")
+			p.done <- nil
+		case resp.Entity == nil:
+			monitoring.RecordKnowledgePanel("none")
+			p.done <- nil
+		default:
+			monitoring.RecordKnowledgePanel("extracted")
+			p.done <- knowledgePanel(resp.Entity)
+		}
+	}()
+	return p
+}
+
+// deliver emits the panel once it is extracted: right away if it is ready,
+// otherwise only when wait is set
+func (p *panelExtraction) deliver(emit Emitter, wait bool) {
+	if p == nil || p.delivered {
+		return
+	}
+	var panel *KnowledgePanel
+	if wait {
+		panel = <-p.done
+	} else {
+		select {
+		case panel = <-p.done:
+		default:
+			return
+		}
+	}
+	p.delivered = true
+	if panel != nil {
+		emit.KnowledgePanel(panel)
+	}
+}
+
+func knowledgePanel(entity *pb.Entity) *KnowledgePanel {
+	panel := &KnowledgePanel{
+		Name:        entity.Name,
+		Type:        entity.Type,
+		Description: entity.Description,
+		ImageURL:    entity.ImageUrl,
+		SourceURL:   entity.SourceUrl,
+	}
+	for _, attr := range entity.Attributes {
+		panel.Attributes = append(panel.Attributes, PanelAttribute{Name: attr.Name, Value: attr.Value})
+	}
+	return panel
+}
@@ -0,0 +1,605 @@
+// Package pipeline runs a search request through its stages (validate,
+// search, summarize, sanitize) and reports progress to an Emitter, which
+// renders it for the client protocol: streamed SSE tokens, a batched SSE
+// summary or a single JSON response.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// StageName identifies a built-in step of the pipeline, each with its own deadline
+type StageName string
+
+const (
+	StageValidate  StageName = "validate"
+	StageSearch    StageName = "search"
+	StageSummarize StageName = "summarize"
+	StageSanitize  StageName = "sanitize"
+	// StageExtract extracts the knowledge panel while summarize runs;
+	// custom stages cannot attach to it
+	StageExtract StageName = "extract"
+	// StageRelated has the model polish the related searches while
+	// summarize runs; custom stages cannot attach to it
+	StageRelated StageName = "related"
+	// StageRerank reorders the results by their relevance to the query
+	// before they are sent and packed into the prompt; custom stages cannot
+	// attach to it
+	StageRerank StageName = "rerank"
+	// StageCompress shortens long snippets to their sentences closest to
+	// the query before the prompt is assembled; custom stages cannot attach
+	// to it
+	StageCompress StageName = "compress"
+)
+
+// Result is a search hit as returned to clients
+type Result struct {
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	Snippet    string `json:"snippet"`
+	DisplayURL string `json:"display_url"`
+	// Source is the provider that returned the result; Internal marks
+	// results from the internal corpus rather than the web
+	Source   string  `json:"source,omitempty"`
+	Internal bool    `json:"internal,omitempty"`
+	Score    float64 `json:"score,omitempty"` // normalized within its source, 0..1
+	ImageURL string  `json:"image_url,omitempty"`
+	// Paywalled marks results behind a paywall or login wall, of which only
+	// the snippet is known
+	Paywalled bool `json:"paywalled,omitempty"`
+	// RelevanceScore is the reranking model's grade of the result for the
+	// query, 0..1; 0 when the results were not reranked
+	RelevanceScore float64 `json:"relevance_score,omitempty"`
+}
+
+// Request is one search to run through the pipeline
+type Request struct {
+	Query      string
+	SafeSearch bool
+	NumResults int
+	ClientIP   string
+	Locale     string // BCP 47 language and country to search in; empty for the provider's default
+	Mode       string // prefixes the LLM request ID, e.g. "stream" or "json"
+	MaxTokens  int32
+	// PanelResults is how many top results a knowledge panel is extracted
+	// from; 0 disables it
+	PanelResults int
+	// RelatedSearches is how many related searches are suggested, 0 for
+	// none; PolishRelated has the model reword them
+	RelatedSearches int
+	PolishRelated   bool
+	// Rerank reorders the results by the relevance the model grades them
+	// with, then drops those under MinRelevance while keeping at least
+	// MinResults
+	Rerank       bool
+	MinRelevance float64
+	MinResults   int
+
+	// Tenant policy: the search sources to query (empty for all) and the
+	// model to summarize with (empty for the default)
+	Sources []string
+	Model   string
+	// Router picks the model for summaries the tenant sets none for; nil
+	// leaves them to the default
+	Router *ModelRouter
+	// Safety is what the tenant and API key add to the safety filters; nil
+	// for nothing
+	Safety *pb.SafetyPolicy
+
+	// Sampling holds the caller's sampling parameters for the summary; nil
+	// keeps the model's defaults
+	Sampling *pb.SamplingParams
+
+	// Snippets cleans the results' snippets before they go into the
+	// summarization prompt; nil uses them as they are
+	Snippets *SnippetCleaner
+	// CompressWords, when positive, shortens each snippet in the prompt to
+	// about this many words of its sentences most similar to the query
+	CompressWords int
+
+	// Prefetch, when set, is called with the related searches once the
+	// summary is delivered, to fetch their results ahead of time
+	Prefetch func(related []string)
+
+	// Shadow, when set, is called as the summarize stage ends with its LLM
+	// request, the summary or error it produced and how long it took, to
+	// mirror the request to another backend. It must not block.
+	Shadow func(llmReq *pb.LLMRequest, summary string, latency time.Duration, err error)
+
+	// Audit, when set, is called once the summary has been generated and
+	// sanitized, or has failed, with its LLM request, the text the model
+	// generated, the text served after the safety filter and the error
+	Audit func(llmReq *pb.LLMRequest, generated, served string, err error)
+
+	// StageEnabled, when set, decides whether a custom stage runs for this
+	// request, e.g. by its feature flag; nil runs every stage
+	StageEnabled func(name string) bool
+
+	// StageContext returns the context for a stage's backend calls
+	StageContext func(stage StageName) (context.Context, context.CancelFunc)
+}
+
+// Summary is the finished, sanitized summary. Streaming emitters have already
+// sent its tokens.
+type Summary struct {
+	Text           string
+	Sanitized      bool // the safety filter changed the generated text
+	Warnings       []string
+	OriginalLength int
+	Budget         *pb.BudgetOutcome // per-request ceilings hit while summarizing
+	Confidence     *Confidence       // how well the results support the summary
+	Answer         *Answer           // the detected query type and answer format
+	// RelatedSearches are queries to suggest next, built from the results
+	RelatedSearches []string
+	Safety          *SafetyReport // how the safety filter scored the text, when it matched
+	// Truncated is why the orchestrator's stream guard cut a streamed
+	// summary short (repetition, max_length); empty when it was not
+	Truncated string
+	// Recovered marks a streamed summary whose backend broke off and a
+	// fallback backend continued
+	Recovered bool
+	// InputTruncated marks a summary of a prompt the LLM service cut to
+	// fit the model's context window or the request's input budget
+	InputTruncated bool
+}
+
+// streamOutcome is how a summary's generation ended, from the LLM
+// service's response or final stream message
+type streamOutcome struct {
+	truncated      string
+	recovered      bool
+	inputTruncated bool
+}
+
+// SafetyReport is the toxicity the safety filter scored a summary with, by
+// category, and what it did about it: pass, annotate, rewrite or block
+type SafetyReport struct {
+	Action   string             `json:"action"`
+	Toxicity map[string]float32 `json:"toxicity,omitempty"`
+}
+
+// Error ends the pipeline early. Status 200 marks a failed summary: the
+// search results are still served, with Message in place of the summary.
+type Error struct {
+	Stage   StageName
+	Status  int    // HTTP status for synchronous responses
+	Message string // safe to show to clients
+	Err     error  // underlying cause, for logs
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Stage, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Stage, e.Message)
+}
+
+// Emitter receives the progress of a pipeline run. Exactly one of Summary
+// or Fail ends every run.
+type Emitter interface {
+	// Started is called before the first stage
+	Started(query string)
+	// Stage is called as a stage begins
+	Stage(stage StageName)
+	// Results delivers the search results
+	Results(results []Result)
+	// Structured delivers an answer provider's data, after the results; the
+	// summary that follows is empty when the data replaces it
+	Structured(data *answers.Data)
+	// KnowledgePanel delivers the entity the query is about, once extracted
+	// and before the summary
+	KnowledgePanel(panel *KnowledgePanel)
+	// Summary delivers the finished summary
+	Summary(summary Summary)
+	// Fail reports the error that ended the run
+	Fail(err *Error)
+}
+
+// TokenEmitter is implemented by emitters that stream the summary token by
+// token; the engine then uses a streaming LLM request
+type TokenEmitter interface {
+	Emitter
+	Token(token string, position int32)
+}
+
+// Engine runs requests against the backend services
+type Engine struct {
+	safety    pb.SafetyServiceClient
+	search    pb.SearchServiceClient
+	llm       pb.LLMOrchestratorServiceClient
+	inference pb.InferenceServiceClient    // knowledge panels, related searches
+	registry  *Registry                    // custom stages
+	results   *lru.Cache[string, []Result] // prefetched results; nil when disabled
+}
+
+// NewEngine returns an engine using the given backend clients and the
+// custom stages in DefaultRegistry
+func NewEngine(safety pb.SafetyServiceClient, search pb.SearchServiceClient, llm pb.LLMOrchestratorServiceClient, inference pb.InferenceServiceClient) *Engine {
+	return &Engine{safety: safety, search: search, llm: llm, inference: inference, registry: DefaultRegistry}
+}
+
+// Run executes the pipeline, reporting to emit until it succeeds or fails
+func (e *Engine) Run(req *Request, emit Emitter) {
+	emit.Started(req.Query)
+	state := &State{Query: req.Query, SafeSearch: req.SafeSearch}
+
+	emit.Stage(StageValidate)
+	err := e.stage(req, StageValidate, state, func() (err *Error) {
+		state.Query, err = e.validate(req, state.Query)
+		return err
+	})
+	if err != nil {
+		fail(emit, err)
+		return
+	}
+
+	answer := classifyQuery(state.Query)
+	monitoring.RecordQueryType(answer.QueryType)
+
+	emit.Stage(StageSearch)
+	structured := e.structuredAnswer(req, state.Query)
+	err = e.stage(req, StageSearch, state, func() (err *Error) {
+		state.Results, err = e.searchResults(req, state.Query)
+		return err
+	})
+	if err != nil {
+		fail(emit, err)
+		return
+	}
+	state.Results = e.rerank(req, state.Query, state.Results)
+	emit.Results(state.Results)
+	related := e.relatedSearches(req, state.Query, state.Results)
+
+	data := structured()
+	if data != nil {
+		emit.Structured(data)
+		if !answers.WantsNarrative(state.Query) {
+			// The data answers the query; a summary would only restate it
+			summary := Summary{Answer: answer, RelatedSearches: related()}
+			emit.Summary(summary)
+			e.prefetch(req, summary.RelatedSearches)
+			return
+		}
+	}
+
+	panel := e.extractPanel(req, state.Query, state.Results, answer)
+	sources := e.compressSnippets(req, state.Query, req.Snippets.Clean(state.Results))
+
+	emit.Stage(StageSummarize)
+	var budget *pb.BudgetOutcome
+	var streamed streamOutcome
+	confidence := assessConfidence(state.Results)
+	var llmReq *pb.LLMRequest
+	summarizing := time.Now()
+	err = e.stage(req, StageSummarize, state, func() (err *Error) {
+		llmReq = e.llmRequest(req, state.Query, sources, confidence, answer, data)
+		if tokens, ok := emit.(TokenEmitter); ok {
+			state.Summary, budget, err = e.streamSummary(req, llmReq, confidence, &streamed, tokens, panel)
+		} else {
+			state.Summary, budget, err = e.completeSummary(req, llmReq, confidence, &streamed)
+		}
+		return err
+	})
+	e.shadow(req, llmReq, state.Summary, time.Since(summarizing), err)
+	if err != nil {
+		e.audit(req, llmReq, state.Summary, "", err)
+		if err.Status == http.StatusOK {
+			// The results are still served, so is their panel
+			panel.deliver(emit, true)
+		}
+		fail(emit, err)
+		return
+	}
+
+	generated := state.Summary
+	var summary *Summary
+	err = e.stage(req, StageSanitize, state, func() (err *Error) {
+		summary, err = e.sanitize(req, state.Summary)
+		if err == nil {
+			state.Summary = summary.Text
+		}
+		return err
+	})
+	if err != nil {
+		e.audit(req, llmReq, generated, "", err)
+		fail(emit, err)
+		return
+	}
+	e.audit(req, llmReq, generated, state.Summary, nil)
+	summary.Text = state.Summary
+	summary.Budget = budget
+	confidence.score(summary.Text, groundingResults(state.Results, data))
+	summary.Confidence = confidence
+	summary.Answer = answer
+	summary.RelatedSearches = related()
+	summary.Truncated, summary.Recovered = streamed.truncated, streamed.recovered
+	summary.InputTruncated = streamed.inputTruncated
+	panel.deliver(emit, true)
+	emit.Summary(*summary)
+	e.prefetch(req, summary.RelatedSearches)
+}
+
+// stage runs a built-in stage between the Before and After hooks of the
+// custom stages attached to it
+func (e *Engine) stage(req *Request, at StageName, state *State, run func() *Error) *Error {
+	if err := e.runHooks(req, at, state, false); err != nil {
+		return err
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	return e.runHooks(req, at, state, true)
+}
+
+func fail(emit Emitter, err *Error) {
+	if err.Err != nil {
+		logger.GetLogger().Errorf("Search pipeline failed: %v", err)
+	}
+	emit.Fail(err)
+}
+
+func (e *Engine) validate(req *Request, query string) (string, *Error) {
+	ctx, cancel := req.StageContext(StageValidate)
+	defer cancel()
+
+	resp, err := e.safety.ValidateInput(ctx, &pb.ValidateInputRequest{
+		Text:       query,
+		ClientIp:   req.ClientIP,
+		SafeSearch: req.SafeSearch,
+		Policy:     req.Safety,
+	})
+	if err != nil {
+		return "", &Error{Stage: StageValidate, Status: http.StatusInternalServerError, Message: "Safety validation failed", Err: err}
+	}
+	if !resp.IsSafe {
+		return "", &Error{Stage: StageValidate, Status: http.StatusBadRequest, Message: "Query contains unsafe content"}
+	}
+	return resp.SanitizedText, nil
+}
+
+// structuredAnswer starts the lookup of the first answer provider
+// recognizing query, alongside the search and under its deadline, and
+// returns a function waiting for the data. A failed lookup, or one without
+// data, leaves the search to answer the query.
+func (e *Engine) structuredAnswer(req *Request, query string) func() *answers.Data {
+	provider, lookup := e.registry.matchProvider(query)
+	if lookup == nil {
+		return func() *answers.Data { return nil }
+	}
+	ctx, cancel := req.StageContext(StageSearch)
+	done := make(chan *answers.Data, 1)
+	go func() {
+		defer cancel()
+		data, err := lookup(ctx)
+		switch {
+		case err != nil:
+			logger.GetLogger().Warnf("Answer provider %s failed: %v", provider, err)
+			monitoring.RecordStructuredAnswer(provider, "failed")
+		case data == nil:
+			monitoring.RecordStructuredAnswer(provider, "no_answer")
+		default:
+			monitoring.RecordStructuredAnswer(provider, "answered")
+		}
+		done <- data
+	}()
+	return func() *answers.Data { return <-done }
+}
+
+// searchResults returns the query's prefetched results, or else asks the
+// search service
+func (e *Engine) searchResults(req *Request, query string) ([]Result, *Error) {
+	if results, ok := e.cachedResults(req, query); ok {
+		return results, nil
+	}
+	return e.fetchResults(req, query)
+}
+
+func (e *Engine) fetchResults(req *Request, query string) ([]Result, *Error) {
+	ctx, cancel := req.StageContext(StageSearch)
+	defer cancel()
+
+	resp, err := e.search.Search(ctx, &pb.SearchRequest{
+		Query:      query,
+		SafeSearch: req.SafeSearch,
+		NumResults: int32(req.NumResults),
+		Sources:    req.Sources,
+		Locale:     req.Locale,
+	})
+	if err != nil {
+		return nil, &Error{Stage: StageSearch, Status: http.StatusInternalServerError, Message: "Search failed", Err: err}
+	}
+	if !resp.Success {
+		return nil, &Error{Stage: StageSearch, Status: http.StatusInternalServerError, Message: resp.Error}
+	}
+
+	results := make([]Result, len(resp.Results))
+	for i, result := range resp.Results {
+		results[i] = Result{
+			Title:      result.Title,
+			URL:        result.Url,
+			Snippet:    result.Snippet,
+			DisplayURL: result.DisplayUrl,
+			Source:     result.Source,
+			Internal:   result.Internal,
+			Score:      result.Score,
+			ImageURL:   result.ImageUrl,
+			Paywalled:  result.Paywalled,
+		}
+	}
+	return results, nil
+}
+
+// SummarizationText is the LLM input built from search results. Internal
+// documents are labelled so the summary attributes them to the internal
+// corpus rather than the web, and paywalled articles so it presents what
+// their preview says as such.
+func SummarizationText(results []Result) string {
+	var text string
+	for _, result := range results {
+		if result.Internal {
+			text += "Internal document \"" + result.Title + "\": " + result.Snippet + " "
+			continue
+		}
+		if result.Paywalled {
+			text += "Article preview of \"" + result.Title + "\" (paywalled; cite it as \"per the article preview\"): " + result.Snippet + " "
+			continue
+		}
+		text += result.Title + " " + result.Snippet + " "
+	}
+	return text
+}
+
+// StructuredDataNote leads the summarization text with a provider's data,
+// which is more current than the search results
+const StructuredDataNote = "Current data from %s, which takes precedence over the sources below: %s\n\n"
+
+// llmRequest asks for a summary of the sources, the results with their
+// snippets prepared for the prompt, sized to what the results contain and
+// in the format the query calls for, grounded in the provider's data when
+// there is any. Thin results are summarized briefly, with an instruction
+// to admit what they do not answer.
+func (e *Engine) llmRequest(req *Request, query string, sources []Result, confidence *Confidence, answer *Answer, data *answers.Data) *pb.LLMRequest {
+	text := SummarizationText(sources)
+	if data != nil {
+		text = fmt.Sprintf(StructuredDataNote, data.Provider, data.Text()) + text
+	}
+	text = answer.instruction(query) + text
+	if confidence.Level == ConfidenceLow {
+		text = InsufficientInformationNote + text
+	}
+	model := req.Model
+	if model == "" && req.Router != nil {
+		model = req.Router.route(answer, confidence, text)
+	}
+	return &pb.LLMRequest{
+		Id:        fmt.Sprintf("%s_%d", req.Mode, time.Now().UnixNano()),
+		Text:      text,
+		MaxTokens: answer.maxTokens(confidence.maxTokens(req.MaxTokens)),
+		Model:     model,
+		CreatedAt: time.Now().Unix(),
+		Sampling:  req.Sampling,
+	}
+}
+
+// groundingResults are the results a summary is scored against, with the
+// provider's data as one more source
+func groundingResults(results []Result, data *answers.Data) []Result {
+	if data == nil {
+		return results
+	}
+	return append(results[:len(results):len(results)], Result{Title: data.Title, Snippet: data.Text(), URL: data.Source})
+}
+
+// completeSummary generates the whole summary with one request, recording
+// the model's confidence in it. An LLM-side error still yields a placeholder
+// summary so the search results are served.
+func (e *Engine) completeSummary(req *Request, llmReq *pb.LLMRequest, confidence *Confidence, outcome *streamOutcome) (string, *pb.BudgetOutcome, *Error) {
+	ctx, cancel := req.StageContext(StageSummarize)
+	defer cancel()
+
+	response, err := e.llm.ProcessRequest(ctx, llmReq)
+	if err != nil {
+		return "", nil, &Error{Stage: StageSummarize, Status: http.StatusOK, Message: "AI summarization failed", Err: err}
+	}
+	if response.Error != "" {
+		logger.GetLogger().Infof("LLM response has error: %s", response.Error)
+		return "", nil, &Error{Stage: StageSummarize, Status: http.StatusOK, Message: "Summary unavailable"}
+	}
+
+	text := response.Summary
+	if text == "" {
+		// Reconstruct from tokens
+		text = strings.Join(response.Tokens, "")
+	}
+	confidence.ModelProbability = round2(float64(response.ModelConfidence))
+	outcome.inputTruncated = response.InputTruncated
+	return text, response.Budget, nil
+}
+
+// streamSummary forwards tokens to emit as they are generated and returns
+// the complete text, recording the model's confidence and how the stream
+// ended from the final message. The knowledge panel is sent between tokens as
+// soon as it is ready.
+func (e *Engine) streamSummary(req *Request, llmReq *pb.LLMRequest, confidence *Confidence, outcome *streamOutcome, emit TokenEmitter, panel *panelExtraction) (string, *pb.BudgetOutcome, *Error) {
+	ctx, cancel := req.StageContext(StageSummarize)
+	defer cancel()
+
+	llmReq.Stream = true
+	stream, err := e.llm.StreamRequest(ctx, llmReq)
+	if err != nil {
+		return "", nil, &Error{Stage: StageSummarize, Status: http.StatusBadGateway, Message: "Failed to start AI summarization", Err: err}
+	}
+
+	var text strings.Builder
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			return text.String(), nil, nil
+		}
+		if err != nil {
+			return text.String(), nil, &Error{Stage: StageSummarize, Status: http.StatusBadGateway, Message: "Streaming error", Err: err}
+		}
+		if response.Error != "" {
+			return text.String(), nil, &Error{Stage: StageSummarize, Status: http.StatusBadGateway, Message: response.Error}
+		}
+
+		if response.Token != "" {
+			text.WriteString(response.Token)
+			emit.Token(response.Token, response.Position)
+		}
+		panel.deliver(emit, false)
+		if response.IsFinal {
+			confidence.ModelProbability = round2(float64(response.ModelConfidence))
+			*outcome = streamOutcome{truncated: response.TruncatedReason, recovered: response.Recovered, inputTruncated: response.InputTruncated}
+			return text.String(), response.Budget, nil
+		}
+	}
+}
+
+// sanitize runs the generated summary through the safety filter before it
+// is finalized
+func (e *Engine) sanitize(req *Request, text string) (*Summary, *Error) {
+	if text == "" {
+		return &Summary{}, nil
+	}
+	log := logger.GetLogger()
+	ctx, cancel := req.StageContext(StageSanitize)
+	defer cancel()
+
+	resp, err := e.safety.SanitizeOutput(ctx, &pb.SanitizeOutputRequest{Text: text, Policy: req.Safety})
+	if err != nil {
+		return nil, &Error{Stage: StageSanitize, Status: http.StatusOK, Message: "Summary sanitization failed", Err: err}
+	}
+	if len(resp.Warnings) > 0 {
+		log.Warnf("AI output sanitized with warnings: %v", resp.Warnings)
+	}
+
+	summary := &Summary{
+		Text:           resp.SanitizedText,
+		Sanitized:      resp.SanitizedText != text,
+		Warnings:       resp.Warnings,
+		OriginalLength: len(text),
+	}
+	if summary.Sanitized {
+		log.Warnf("AI output was modified by safety filter")
+	}
+	if resp.Action != "" {
+		monitoring.RecordSafetyOutputAction(resp.Action)
+	}
+	if len(resp.Toxicity) > 0 {
+		summary.Safety = &SafetyReport{Action: resp.Action, Toxicity: resp.Toxicity}
+		log.Infof("AI output toxicity %v: %s", resp.Toxicity, resp.Action)
+	}
+	return summary, nil
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+)
+
+// EnableResultCache keeps up to entries prefetched result sets for ttl. The
+// search stage answers from them before calling the search service.
+func (e *Engine) EnableResultCache(entries int, ttl time.Duration) {
+	e.results = lru.New[string, []Result]("pipeline_prefetched_results", entries, ttl)
+}
+
+// Prefetch fetches the search results of req's query into the result cache,
+// without validating or summarizing it: the search that later uses them is
+// validated as usual. It does nothing without the result cache.
+func (e *Engine) Prefetch(req *Request) error {
+	if e.results == nil {
+		return nil
+	}
+	key := resultKey(req, req.Query)
+	if _, ok := e.results.Get(key); ok {
+		monitoring.RecordPrefetch("cached")
+		return nil
+	}
+	results, err := e.fetchResults(req, req.Query)
+	if err != nil {
+		monitoring.RecordPrefetch("failed")
+		return err
+	}
+	e.results.Add(key, results)
+	monitoring.RecordPrefetch("fetched")
+	return nil
+}
+
+// prefetch hands the related searches of a delivered summary to the
+// request's Prefetch hook
+func (e *Engine) prefetch(req *Request, related []string) {
+	if req.Prefetch == nil || len(related) == 0 {
+		return
+	}
+	defer func() {
+		// A failing hook must not fail the search it follows
+		if r := recover(); r != nil {
+			logger.GetLogger().Errorf("Prefetch hook panicked: %v", r)
+		}
+	}()
+	req.Prefetch(related)
+}
+
+// cachedResults returns prefetched results for the query, copied so the
+// stages after the search cannot change the cached ones
+func (e *Engine) cachedResults(req *Request, query string) ([]Result, bool) {
+	if e.results == nil {
+		return nil, false
+	}
+	results, ok := e.results.Get(resultKey(req, query))
+	if !ok {
+		return nil, false
+	}
+	monitoring.RecordPrefetch("hit")
+	return append([]Result(nil), results...), true
+}
+
+// resultKey identifies the search parameters that decide the results:
+// the query, compared case- and space-insensitively, safe search, the
+// result count, the sources and the locale
+func resultKey(req *Request, query string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	return fmt.Sprintf("%t|%d|%s|%s|%s", req.SafeSearch, req.NumResults, strings.Join(req.Sources, ","), req.Locale, normalized)
+}
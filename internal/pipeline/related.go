@@ -0,0 +1,234 @@
+package pipeline
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// phraseStopWords cannot start or end a related search phrase. With the
+// longer stopWords they are the function words of typical queries and
+// snippets.
+var phraseStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "do": true, "does": true, "for": true,
+	"how": true, "if": true, "in": true, "is": true, "it": true, "its": true,
+	"makes": true, "make": true, "of": true, "on": true, "or": true, "our": true,
+	"the": true, "to": true, "using": true, "was": true, "we": true, "who": true,
+	"why": true, "you": true, "your": true, "best": true, "all": true, "new": true,
+	"use": true, "get": true, "has": true, "not": true, "but": true, "one": true,
+}
+
+// maxPhraseWords is the longest phrase considered for a related search
+const maxPhraseWords = 3
+
+// relatedSearches derives up to req.RelatedSearches related searches from the
+// results and, when req.PolishRelated is set, starts their rewording by the
+// model alongside the summary. It returns a function waiting for them; a
+// failed polish keeps the derived searches.
+func (e *Engine) relatedSearches(req *Request, query string, results []Result) func() []string {
+	if req.RelatedSearches <= 0 {
+		return func() []string { return nil }
+	}
+	searches := deriveRelatedSearches(query, results, req.RelatedSearches)
+	if len(searches) == 0 {
+		monitoring.RecordRelatedSearches("none")
+		return func() []string { return nil }
+	}
+	if !req.PolishRelated {
+		monitoring.RecordRelatedSearches("derived")
+		return func() []string { return searches }
+	}
+
+	ctx, cancel := req.StageContext(StageRelated)
+	done := make(chan []string, 1)
+	go func() {
+		defer cancel()
+		resp, err := e.inference.PolishRelatedSearches(ctx, &pb.PolishRelatedSearchesRequest{Query: query, Searches: searches})
+		if err != nil {
+			logger.GetLogger().Warnf("Polishing related searches failed: %v", err)
+			monitoring.RecordRelatedSearches("polish_failed")
+			done <- searches
+			return
+		}
+		monitoring.RecordRelatedSearches("polished")
+		done <- dedupeSearches(query, resp.Searches)
+	}()
+	return func() []string { return <-done }
+}
+
+// deriveRelatedSearches builds up to max related searches for query from the
+// phrases of one to three words that stand out in the titles and snippets of
+// the results. Each result is a document: a phrase scores its TF-IDF summed
+// over the results, weighted by its length so "helm charts" outranks "helm".
+// Only phrases in at least two results count (one when there is a single
+// result), and ones overlapping a better phrase are skipped. A phrase without
+// the query's subject is prefixed with it, e.g. "kubernetes helm charts".
+func deriveRelatedSearches(query string, results []Result, max int) []string {
+	queryWords := make(map[string]bool)
+	var subject []string
+	for _, word := range phraseWords(query) {
+		queryWords[word] = true
+		if !phraseStopWords[word] && !stopWords[word] {
+			subject = append(subject, word)
+		}
+	}
+
+	counts := make([]map[string]int, len(results))
+	df := make(map[string]int)
+	for i, result := range results {
+		counts[i] = make(map[string]int)
+		for _, segment := range phraseSegments(result.Title + ". " + result.Snippet) {
+			for _, phrase := range candidatePhrases(segment, queryWords) {
+				counts[i][phrase]++
+			}
+		}
+		for phrase := range counts[i] {
+			df[phrase]++
+		}
+	}
+
+	minDF := 2
+	if len(results) == 1 {
+		minDF = 1
+	}
+	scores := make(map[string]float64)
+	for _, docCounts := range counts {
+		for phrase, tf := range docCounts {
+			if df[phrase] < minDF {
+				continue
+			}
+			idf := math.Log(1 + float64(len(results))/float64(df[phrase]))
+			scores[phrase] += float64(tf) * idf * float64(len(strings.Fields(phrase)))
+		}
+	}
+	phrases := make([]string, 0, len(scores))
+	for phrase := range scores {
+		phrases = append(phrases, phrase)
+	}
+	sort.Slice(phrases, func(i, j int) bool {
+		if scores[phrases[i]] != scores[phrases[j]] {
+			return scores[phrases[i]] > scores[phrases[j]]
+		}
+		return phrases[i] < phrases[j]
+	})
+
+	var chosen, searches []string
+	for _, phrase := range phrases {
+		if len(searches) == max {
+			break
+		}
+		if overlapsAny(phrase, chosen, queryWords) {
+			continue
+		}
+		chosen = append(chosen, phrase)
+		search := phrase
+		if !containsAll(phrase, subject) {
+			search = strings.Join(subject, " ") + " " + phrase
+		}
+		searches = append(searches, strings.TrimSpace(search))
+	}
+	return dedupeSearches(query, searches)
+}
+
+// phraseWords lowercases text and splits it into words, keeping the symbols
+// of names like "c++" and "c#"
+func phraseWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#' && r != '\''
+	})
+}
+
+// phraseSegments splits text at punctuation, which phrases do not span
+func phraseSegments(text string) [][]string {
+	var segments [][]string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool {
+		return strings.ContainsRune(".,;:!?()[]{}|\"–—…", r)
+	}) {
+		if words := phraseWords(part); len(words) > 0 {
+			segments = append(segments, words)
+		}
+	}
+	return segments
+}
+
+// candidatePhrases returns the phrases of a segment that may become related
+// searches: not starting or ending with a stop word, not made only of query
+// words and, for single words, at least four letters long
+func candidatePhrases(words []string, queryWords map[string]bool) []string {
+	var phrases []string
+	for start := range words {
+		if isPhraseStopWord(words[start]) {
+			continue
+		}
+		onlyQuery := true
+		for end := start; end < len(words) && end < start+maxPhraseWords; end++ {
+			word := words[end]
+			onlyQuery = onlyQuery && queryWords[word]
+			if isPhraseStopWord(word) || onlyQuery {
+				continue
+			}
+			if end == start && len(word) < 4 {
+				continue
+			}
+			phrases = append(phrases, strings.Join(words[start:end+1], " "))
+		}
+	}
+	return phrases
+}
+
+func isPhraseStopWord(word string) bool {
+	return phraseStopWords[word] || stopWords[word] || strings.Trim(word, "0123456789") == ""
+}
+
+// overlapsAny reports whether phrase shares a word other than a query word
+// with any of the phrases
+func overlapsAny(phrase string, phrases []string, queryWords map[string]bool) bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(phrase) {
+		words[word] = !queryWords[word]
+	}
+	for _, other := range phrases {
+		for _, word := range strings.Fields(other) {
+			if words[word] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsAll reports whether phrase has every one of words
+func containsAll(phrase string, words []string) bool {
+	have := make(map[string]bool)
+	for _, word := range strings.Fields(phrase) {
+		have[word] = true
+	}
+	for _, word := range words {
+		if !have[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// dedupeSearches drops empty searches, repeats and ones that are the query
+// itself, ignoring case and spacing
+func dedupeSearches(query string, searches []string) []string {
+	seen := map[string]bool{strings.Join(phraseWords(query), " "): true}
+	var kept []string
+	for _, search := range searches {
+		key := strings.Join(phraseWords(search), " ")
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, search)
+	}
+	return kept
+}
@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// rerank has the model grade the results' relevance to the query when
+// req.Rerank is set, sorting them by it and dropping the ones under
+// req.MinRelevance beyond the first req.MinResults. A failed rerank keeps
+// the results in the order the search returned them.
+func (e *Engine) rerank(req *Request, query string, results []Result) []Result {
+	if !req.Rerank || len(results) < 2 {
+		return results
+	}
+	documents := make([]string, len(results))
+	for i, result := range results {
+		documents[i] = result.Title + ". " + result.Snippet
+	}
+
+	ctx, cancel := req.StageContext(StageRerank)
+	defer cancel()
+	resp, err := e.inference.Rerank(ctx, &pb.RerankRequest{Query: query, Documents: documents})
+	if err == nil && len(resp.Scores) != len(results) {
+		err = fmt.Errorf("%d scores for %d results", len(resp.Scores), len(results))
+	}
+	if err != nil {
+		logger.GetLogger().Warnf("Reranking %d results failed: %v", len(results), err)
+		monitoring.RecordRerank("failed", 0)
+		return results
+	}
+
+	reranked := make([]Result, len(results))
+	for i, result := range results {
+		result.RelevanceScore = round2(float64(resp.Scores[i]))
+		reranked[i] = result
+	}
+	sort.SliceStable(reranked, func(i, j int) bool { return reranked[i].RelevanceScore > reranked[j].RelevanceScore })
+
+	kept := len(reranked)
+	for kept > req.MinResults && kept > 0 && reranked[kept-1].RelevanceScore < req.MinRelevance {
+		kept--
+	}
+	monitoring.RecordRerank("reranked", len(reranked)-kept)
+	return reranked[:kept]
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"strings"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// Model tiers a summary is routed to
+const (
+	TierSmall = "small"
+	TierLarge = "large"
+)
+
+// Why a summary went to its tier
+const (
+	RouteLongContext = "long_context" // the prompt is longer than the small model handles well
+	RouteManySources = "many_sources" // too many sources to weigh for the small model
+	RouteQueryType   = "query_type"   // the query asks for more than a direct answer
+	RouteSimple      = "simple"       // none of the above
+)
+
+// ModelRouter sends summaries that need little reasoning to a small, cheap
+// model and the rest to the large one. A summary is complex when its prompt
+// is long, when it draws on many sources, or when its query is of a type
+// that asks for weighing them, such as a comparison; it is judged before
+// tokenization, so the prompt's tokens are estimated from its words.
+type ModelRouter struct {
+	small, large      string
+	maxSmallTokens    int
+	maxSmallSources   int
+	complexQueryTypes map[string]bool
+}
+
+// NewModelRouter routes between the small and large models; a limit of 0
+// does not route on that signal
+func NewModelRouter(small, large string, maxSmallTokens, maxSmallSources int, complexQueryTypes []string) *ModelRouter {
+	r := &ModelRouter{
+		small:             small,
+		large:             large,
+		maxSmallTokens:    maxSmallTokens,
+		maxSmallSources:   maxSmallSources,
+		complexQueryTypes: make(map[string]bool, len(complexQueryTypes)),
+	}
+	for _, queryType := range complexQueryTypes {
+		r.complexQueryTypes[queryType] = true
+	}
+	return r
+}
+
+// route picks the model for a summary of text, built from the sources
+// confidence counted, for a query of the answer's type
+func (r *ModelRouter) route(answer *Answer, confidence *Confidence, text string) string {
+	tokens := estimateTokens(text)
+	model, tier, reason := r.large, TierLarge, ""
+	switch {
+	case r.maxSmallTokens > 0 && tokens > r.maxSmallTokens:
+		reason = RouteLongContext
+	case r.maxSmallSources > 0 && confidence.Sources > r.maxSmallSources:
+		reason = RouteManySources
+	case r.complexQueryTypes[answer.QueryType]:
+		reason = RouteQueryType
+	default:
+		model, tier, reason = r.small, TierSmall, RouteSimple
+	}
+	monitoring.RecordModelRoute(tier, reason, tokens)
+	logger.GetLogger().Infof("Routed summary to %s model %s (%s: ~%d prompt tokens, %d sources, %s query)",
+		tier, model, reason, tokens, confidence.Sources, answer.QueryType)
+	return model
+}
+
+// estimateTokens approximates the tokens of text: subword tokenizers
+// average about four tokens to every three English words
+func estimateTokens(text string) int {
+	return len(strings.Fields(text)) * 4 / 3
+}
@@ -0,0 +1,28 @@
+package pipeline
+
+import (
+	"time"
+
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// shadow hands the summarize stage's LLM request and outcome to the
+// request's Shadow hook. Nothing is mirrored when a custom stage failed the
+// run before the request was built.
+func (e *Engine) shadow(req *Request, llmReq *pb.LLMRequest, summary string, latency time.Duration, failed *Error) {
+	if req.Shadow == nil || llmReq == nil {
+		return
+	}
+	defer func() {
+		// Shadow traffic must never fail the search it mirrors
+		if r := recover(); r != nil {
+			logger.GetLogger().Errorf("Shadow hook panicked: %v", r)
+		}
+	}()
+	var err error
+	if failed != nil {
+		err = failed
+	}
+	req.Shadow(llmReq, summary, latency, err)
+}
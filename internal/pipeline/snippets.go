@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// SnippetCleaner prepares search result snippets for the summarization
+// prompt. Snippets come straight from web pages, so it strips the URLs and
+// markup left in them, drops boilerplate sentences such as cookie banners,
+// and drops sentences that try to instruct the model. The results served
+// to the caller keep their snippets.
+type SnippetCleaner struct {
+	boilerplate *regexp.Regexp
+	injection   *regexp.Regexp
+}
+
+// The built-in patterns, matched case-insensitively within a sentence
+var (
+	boilerplatePatterns = []string{
+		`(accept|use|uses|using) (all )?cookies`,
+		`cookie (policy|settings|preferences)`,
+		`privacy policy`,
+		`terms (of (service|use)|and conditions)`,
+		`all rights reserved`,
+		`(sign up|subscribe) (for|to) (our|the) newsletter`,
+		`(enable|turn on) javascript`,
+		`javascript is (disabled|required)`,
+		`skip to (main )?content`,
+		`^(read|learn) more\W*$`,
+		`^click here\b`,
+	}
+	injectionPatterns = []string{
+		`(ignore|disregard|forget|override) (all |any )?(the )?(previous|prior|above|earlier|your|preceding) (instructions|prompts?|rules|directions)`,
+		`(new|updated|real) (system )?instructions\s*:`,
+		`\byou are (now|no longer) (an? )?(ai|assistant|language model|chatbot|bound by)\b`,
+		`\bact as (an? )?(ai|assistant|language model|chatbot)\b`,
+		`\bsystem prompt\b`,
+		`\b(do not|don't) summarize\b`,
+		`\b(respond|reply|answer) only with\b`,
+		`^(assistant|system|user)\s*:`,
+	}
+
+	urlPattern    = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+`)
+	markupPattern = regexp.MustCompile(`<[^>]*>|\[(\.\.\.|…)\]|[•»«▶►]|&[a-z]+;`)
+	// sentenceEnd splits a snippet after ., ! or ? followed by whitespace
+	sentenceEnd = regexp.MustCompile(`[.!?…]+\s+`)
+)
+
+// NewSnippetCleaner compiles the built-in patterns with extra boilerplate
+// and injection phrases, matched case-insensitively
+func NewSnippetCleaner(boilerplate, injection []string) *SnippetCleaner {
+	return &SnippetCleaner{
+		boilerplate: compileAlternatives(boilerplatePatterns, boilerplate),
+		injection:   compileAlternatives(injectionPatterns, injection),
+	}
+}
+
+func compileAlternatives(patterns, phrases []string) *regexp.Regexp {
+	alternatives := append([]string(nil), patterns...)
+	for _, phrase := range phrases {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			alternatives = append(alternatives, regexp.QuoteMeta(phrase))
+		}
+	}
+	for i, alternative := range alternatives {
+		alternatives[i] = "(?:" + alternative + ")"
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+}
+
+// Clean returns the results with cleaned snippets, leaving the given
+// results unchanged. A nil cleaner returns them as they are.
+func (c *SnippetCleaner) Clean(results []Result) []Result {
+	if c == nil {
+		return results
+	}
+	cleaned := make([]Result, len(results))
+	for i, result := range results {
+		cleaned[i] = result
+		cleaned[i].Snippet = c.clean(result.Snippet, result.URL)
+	}
+	return cleaned
+}
+
+// clean strips a snippet for the prompt, counting what it changed
+func (c *SnippetCleaner) clean(snippet, url string) string {
+	text := html.UnescapeString(snippet)
+	if stripped := urlPattern.ReplaceAllString(text, ""); stripped != text {
+		monitoring.RecordSnippetCleaning("url")
+		text = stripped
+	}
+	if stripped := markupPattern.ReplaceAllString(text, " "); stripped != text {
+		monitoring.RecordSnippetCleaning("markup")
+		text = stripped
+	}
+
+	var kept []string
+	for _, sentence := range splitSentences(text) {
+		switch {
+		case c.injection.MatchString(sentence):
+			logger.GetLogger().Warnf("Dropped a sentence instructing the model from the snippet of %s: %q", url, sentence)
+			monitoring.RecordSnippetCleaning("injection")
+		case c.boilerplate.MatchString(sentence):
+			monitoring.RecordSnippetCleaning("boilerplate")
+		default:
+			kept = append(kept, sentence)
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(kept, " ")), " ")
+}
+
+// splitSentences splits text after sentence-ending punctuation, keeping
+// the punctuation with its sentence
+func splitSentences(text string) []string {
+	var sentences []string
+	last := 0
+	for _, end := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if sentence := strings.TrimSpace(text[last:end[1]]); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		last = end[1]
+	}
+	if sentence := strings.TrimSpace(text[last:]); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"plugin"
+	"sync"
+
+	"ai-search-service/internal/answers"
+	"ai-search-service/internal/logger"
+)
+
+// Stage is a custom step attached to one of the built-in stages, e.g. an
+// internal knowledge-base lookup after search or a compliance filter after
+// sanitize. Before runs ahead of the built-in stage and After once it has
+// finished; either may change the state. Returning an *Error fails the
+// request with that status and message, any other error with a 500.
+type Stage interface {
+	Name() string
+	Before(ctx context.Context, state *State) error
+	After(ctx context.Context, state *State) error
+}
+
+// State is the request data custom stages can read and change
+type State struct {
+	Query      string // raw query before validate, sanitized after
+	SafeSearch bool
+	Results    []Result // set after search; changes are what the client receives
+	// Summary is set after summarize (the generated text) and after sanitize
+	// (the final text). Streamed tokens have already been sent by then, so
+	// for streaming clients changes only affect the final summary.
+	Summary string
+}
+
+// Registry holds the custom stages attached to each built-in stage and the
+// structured answer providers
+type Registry struct {
+	mu        sync.RWMutex
+	stages    map[StageName][]Stage
+	providers []answers.Provider
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{stages: make(map[StageName][]Stage)}
+}
+
+// DefaultRegistry is used by engines created with NewEngine. Compile-time
+// extensions register into it from an init function.
+var DefaultRegistry = NewRegistry()
+
+// Register attaches stage to the built-in stage at; stages attached to the
+// same point run in registration order
+func (r *Registry) Register(at StageName, stage Stage) error {
+	switch at {
+	case StageValidate, StageSearch, StageSummarize, StageSanitize:
+	default:
+		return fmt.Errorf("unknown pipeline stage %q", at)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stages[at] = append(r.stages[at], stage)
+	logger.GetLogger().Infof("Registered pipeline stage %s at %s", stage.Name(), at)
+	return nil
+}
+
+// Register attaches stage to the default registry
+func Register(at StageName, stage Stage) error {
+	return DefaultRegistry.Register(at, stage)
+}
+
+func (r *Registry) attached(at StageName) []Stage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stages[at]
+}
+
+// RegisterProvider adds a structured answer provider, replacing one of the
+// same name. Providers are asked in registration order and the first to
+// recognize a query answers it.
+func (r *Registry) RegisterProvider(provider answers.Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, registered := range r.providers {
+		if registered.Name() == provider.Name() {
+			r.providers[i] = provider
+			return
+		}
+	}
+	r.providers = append(r.providers, provider)
+	logger.GetLogger().Infof("Registered answer provider %s", provider.Name())
+}
+
+// RegisterProvider adds a provider to the default registry
+func RegisterProvider(provider answers.Provider) {
+	DefaultRegistry.RegisterProvider(provider)
+}
+
+// matchProvider returns the first provider recognizing query and its lookup
+func (r *Registry) matchProvider(query string) (string, answers.Lookup) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, provider := range r.providers {
+		if lookup := provider.Match(query); lookup != nil {
+			return provider.Name(), lookup
+		}
+	}
+	return "", nil
+}
+
+// PluginSymbol is the function a Go plugin exports to register its stages
+// and answer providers:
+//
+//	func RegisterStages(r *pipeline.Registry) error
+const PluginSymbol = "RegisterStages"
+
+// LoadPlugins opens each Go plugin and lets it register into r
+func (r *Registry) LoadPlugins(paths []string) error {
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open pipeline plugin %s: %w", path, err)
+		}
+		sym, err := p.Lookup(PluginSymbol)
+		if err != nil {
+			return fmt.Errorf("pipeline plugin %s: %w", path, err)
+		}
+		register, ok := sym.(func(*Registry) error)
+		if !ok {
+			return fmt.Errorf("pipeline plugin %s: %s has type %T, want func(*pipeline.Registry) error", path, PluginSymbol, sym)
+		}
+		if err := register(r); err != nil {
+			return fmt.Errorf("pipeline plugin %s: %w", path, err)
+		}
+		logger.GetLogger().Infof("Loaded pipeline plugin %s", path)
+	}
+	return nil
+}
+
+// runHooks runs the Before or After hooks attached to a built-in stage
+func (e *Engine) runHooks(req *Request, at StageName, state *State, after bool) *Error {
+	stages := e.registry.attached(at)
+	if len(stages) == 0 {
+		return nil
+	}
+	ctx, cancel := req.StageContext(at)
+	defer cancel()
+
+	for _, stage := range stages {
+		if req.StageEnabled != nil && !req.StageEnabled(stage.Name()) {
+			continue
+		}
+		var err error
+		if after {
+			err = stage.After(ctx, state)
+		} else {
+			err = stage.Before(ctx, state)
+		}
+		if err == nil {
+			continue
+		}
+		var pipelineErr *Error
+		if errors.As(err, &pipelineErr) {
+			return pipelineErr
+		}
+		return &Error{Stage: at, Status: http.StatusInternalServerError, Message: fmt.Sprintf("Pipeline stage %s failed", stage.Name()), Err: err}
+	}
+	return nil
+}
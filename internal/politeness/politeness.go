@@ -0,0 +1,260 @@
+// Package politeness makes fetching pages from arbitrary sites behave like a
+// well-mannered crawler, so the deployment's IP does not get banned: every
+// request carries the configured user agent, robots.txt is obeyed (and
+// cached per host), requests to one host are spaced by its Crawl-delay or a
+// configured minimum, a host answering 429 or 503 is left alone for its
+// Retry-After, and a global cap bounds the requests in flight. Wrap any
+// fetcher's transport with New, or use NewClient.
+package politeness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/upstream"
+)
+
+// ErrDisallowed is returned for URLs the site's robots.txt disallows
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsRetry is how long an unreachable robots.txt disallows its site
+// before it is fetched again
+const robotsRetry = time.Minute
+
+// Transport is an http.RoundTripper fetching politely through a base
+// transport
+type Transport struct {
+	config config.PolitenessConfig
+	base   http.RoundTripper
+	slots  chan struct{} // nil without a concurrency cap
+
+	mu    sync.Mutex // serializes host creation
+	hosts *lru.Cache[string, *host]
+}
+
+// host is what is known about one scheme and host
+type host struct {
+	robotsMu      sync.Mutex // held while robots.txt is fetched
+	robots        *Rules
+	robotsExpires time.Time
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next request
+}
+
+// New wraps base (http.DefaultTransport when nil) with the politeness rules
+// of cfg
+func New(cfg config.PolitenessConfig, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{config: cfg, base: base, hosts: lru.New[string, *host]("politeness_hosts", cfg.CacheEntries, 0)}
+	if cfg.MaxConcurrency > 0 {
+		t.slots = make(chan struct{}, cfg.MaxConcurrency)
+	}
+	return t
+}
+
+// NewClient returns an HTTP client for the named upstream that fetches
+// politely, following redirects (each hop checked and paced) up to ten times
+func NewClient(cfg *config.Config, name string, timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: New(cfg.Politeness, upstream.WrapTransport(cfg, name, nil))}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	req = req.Clone(ctx)
+	if req.Header.Get("User-Agent") == "" && t.config.UserAgent != "" {
+		req.Header.Set("User-Agent", t.config.UserAgent)
+	}
+	h := t.host(req.URL.Scheme + "://" + req.URL.Host)
+
+	rules := AllowAll
+	if t.config.RespectRobots {
+		rules = t.robots(ctx, h, req)
+		path := req.URL.EscapedPath()
+		if req.URL.RawQuery != "" {
+			path += "?" + req.URL.RawQuery
+		}
+		if !rules.Allowed(path) {
+			monitoring.RecordPoliteFetch("disallowed")
+			return nil, fmt.Errorf("%w: %s", ErrDisallowed, req.URL.Redacted())
+		}
+	}
+
+	if err := t.wait(ctx, h, rules.CrawlDelay); err != nil {
+		return nil, err
+	}
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.release()
+		monitoring.RecordPoliteFetch("failed")
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		t.backOff(h, resp.Header.Get("Retry-After"))
+	}
+	monitoring.RecordPoliteFetch("fetched")
+	// The slot is held until the body is read
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: t.release}
+	return resp, nil
+}
+
+func (t *Transport) release() {
+	if t.slots != nil {
+		<-t.slots
+	}
+}
+
+// releasingBody frees a concurrency slot once closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func (t *Transport) host(key string) *host {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts.Get(key)
+	if !ok {
+		h = &host{}
+		t.hosts.Add(key, h)
+	}
+	return h
+}
+
+// wait blocks until the host's turn, reserving the slot after it: requests
+// to one host start at least the configured interval, or its crawl delay,
+// apart (neither beyond max_host_delay)
+func (t *Transport) wait(ctx context.Context, h *host, crawlDelay time.Duration) error {
+	interval := t.config.PerHostInterval
+	if crawlDelay > interval {
+		interval = crawlDelay
+	}
+	if max := t.config.MaxHostDelay; max > 0 && interval > max {
+		interval = max
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(interval)
+	h.mu.Unlock()
+
+	delay := start.Sub(now)
+	monitoring.PoliteFetchWait.Observe(delay.Seconds())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backOff keeps requests away from a host that asked for it, for its
+// Retry-After in seconds or as a date (at most max_host_delay), or else for
+// the configured interval
+func (t *Transport) backOff(h *host, retryAfter string) {
+	delay := t.config.PerHostInterval
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(retryAfter); err == nil {
+		delay = time.Until(at)
+	}
+	if max := t.config.MaxHostDelay; max > 0 && delay > max {
+		delay = max
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if until := time.Now().Add(delay); until.After(h.next) {
+		h.next = until
+	}
+}
+
+// robots returns the host's rules for the user agent, fetching robots.txt
+// when it is not cached. Per RFC 9309 a missing robots.txt (4xx) allows
+// everything, and one that cannot be fetched (5xx, 429 or a network error)
+// disallows everything until it is retried.
+func (t *Transport) robots(ctx context.Context, h *host, req *http.Request) *Rules {
+	h.robotsMu.Lock()
+	defer h.robotsMu.Unlock()
+	if h.robots != nil && time.Now().Before(h.robotsExpires) {
+		return h.robots
+	}
+
+	rules, ttl := t.fetchRobots(ctx, req)
+	h.robots, h.robotsExpires = rules, time.Now().Add(ttl)
+	return rules
+}
+
+func (t *Transport) fetchRobots(ctx context.Context, req *http.Request) (*Rules, time.Duration) {
+	robotsURL := req.URL.Scheme + "://" + req.URL.Host + "/robots.txt"
+	if t.config.RobotsTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.config.RobotsTimeout)
+		defer cancel()
+	}
+	robotsReq, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return DisallowAll, robotsRetry
+	}
+	robotsReq.Header.Set("User-Agent", req.Header.Get("User-Agent"))
+	// Redirects are followed, as RFC 9309 asks, but not paced
+	client := &http.Client{Transport: t.base}
+	resp, err := client.Do(robotsReq)
+	if err != nil {
+		logger.GetLogger().Warnf("Failed to fetch %s, disallowing the site: %v", robotsURL, err)
+		monitoring.RecordPoliteFetch("robots_unreachable")
+		return DisallowAll, robotsRetry
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+		if err != nil {
+			monitoring.RecordPoliteFetch("robots_unreachable")
+			return DisallowAll, robotsRetry
+		}
+		return ParseRobots(data, req.Header.Get("User-Agent")), t.config.RobotsTTL
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		logger.GetLogger().Warnf("%s returned status %d, disallowing the site", robotsURL, resp.StatusCode)
+		monitoring.RecordPoliteFetch("robots_unreachable")
+		return DisallowAll, robotsRetry
+	default:
+		return AllowAll, t.config.RobotsTTL
+	}
+}
@@ -0,0 +1,148 @@
+package politeness
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRobotsBytes is how much of a robots.txt is read; RFC 9309 asks
+// crawlers to parse at least 500 KiB
+const maxRobotsBytes = 512 << 10
+
+// Rules are the robots.txt rules that apply to one user agent
+type Rules struct {
+	rules      []rule
+	CrawlDelay time.Duration // 0 when the group sets none
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll are the rules of a site without a robots.txt
+var AllowAll = &Rules{}
+
+// DisallowAll are the rules of a site whose robots.txt cannot be fetched
+var DisallowAll = &Rules{rules: []rule{{allow: false, pattern: "/"}}}
+
+// ParseRobots returns the rules of a robots.txt for the user agent: those of
+// the groups naming its product token ("ExampleBot" of "ExampleBot/1.0"),
+// or else those of the * groups
+func ParseRobots(data []byte, userAgent string) *Rules {
+	if len(data) > maxRobotsBytes {
+		data = data[:maxRobotsBytes]
+	}
+	token := strings.ToLower(productToken(userAgent))
+
+	var specific, wildcard Rules
+	named := false // some group names the token
+	var agents []string
+	inRules := false // a group's user-agent lines end at its first rule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), maxRobotsBytes)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+			continue
+		}
+		inRules = true
+		var target []*Rules
+		if matchesAgent(agents, "*") {
+			target = append(target, &wildcard)
+		}
+		if token != "" && matchesAgent(agents, token) {
+			target = append(target, &specific)
+			named = true
+		}
+		for _, rules := range target {
+			switch key {
+			case "allow", "disallow":
+				if value != "" { // an empty Disallow allows everything
+					rules.rules = append(rules.rules, rule{allow: key == "allow", pattern: value})
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					rules.CrawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if named {
+		return &specific
+	}
+	return &wildcard
+}
+
+// matchesAgent reports whether a group's user-agent lines name token
+func matchesAgent(agents []string, token string) bool {
+	for _, agent := range agents {
+		if agent == token {
+			return true
+		}
+	}
+	return false
+}
+
+// productToken is the name a user agent goes by in robots.txt
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	token, _, _ = strings.Cut(token, " ")
+	return token
+}
+
+// Allowed reports whether path (with its query) may be fetched: the longest
+// matching rule decides, Allow winning ties, and no match allows it.
+// /robots.txt itself is always allowed.
+func (r *Rules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if len(rule.pattern) < longest || !matchPattern(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || rule.allow {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// matchPattern matches a robots.txt path pattern, where * is any sequence
+// of characters and a final $ anchors the end of the path
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,81 @@
+// Package privacy anonymizes client IPs before they are logged, stored or
+// passed on to other services, as the deployment's privacy.ip_mode says:
+// "truncate" keeps the network and zeroes the host bits (an IPv4 /24 by
+// default), "hash" replaces the IP with a keyed hash that still tells
+// clients apart, and "off" keeps IPs verbatim. Anonymizing twice gives the
+// same result, so each service anonymizes what it receives.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"ai-search-service/internal/config"
+)
+
+// Modes of privacy.ip_mode
+const (
+	ModeOff      = "off"
+	ModeTruncate = "truncate"
+	ModeHash     = "hash"
+)
+
+// Anonymizer anonymizes client IPs; the zero value and nil keep them
+type Anonymizer struct {
+	mode string
+	ipv4 net.IPMask
+	ipv6 net.IPMask
+	key  []byte // of the hash mode
+}
+
+// New returns the anonymizer of cfg. Without a hash_key, hashes are keyed
+// per process and differ across restarts.
+func New(cfg config.PrivacyConfig) (*Anonymizer, error) {
+	a := &Anonymizer{mode: cfg.IPMode}
+	switch cfg.IPMode {
+	case "", ModeOff:
+		a.mode = ModeOff
+	case ModeTruncate:
+		if cfg.IPv4Prefix < 0 || cfg.IPv4Prefix > 32 || cfg.IPv6Prefix < 0 || cfg.IPv6Prefix > 128 {
+			return nil, fmt.Errorf("privacy: prefixes must be within /0-/32 for IPv4 and /0-/128 for IPv6, got /%d and /%d", cfg.IPv4Prefix, cfg.IPv6Prefix)
+		}
+		a.ipv4 = net.CIDRMask(cfg.IPv4Prefix, 32)
+		a.ipv6 = net.CIDRMask(cfg.IPv6Prefix, 128)
+	case ModeHash:
+		a.key = []byte(cfg.HashKey)
+		if len(a.key) == 0 {
+			a.key = make([]byte, 32)
+			if _, err := rand.Read(a.key); err != nil {
+				return nil, fmt.Errorf("privacy: failed to generate a hash key: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("privacy: unknown ip_mode %q (off, truncate or hash)", cfg.IPMode)
+	}
+	return a, nil
+}
+
+// IP anonymizes a client IP. Anything that is not an IP, such as an already
+// hashed one or "scheduler", is returned as is.
+func (a *Anonymizer) IP(ip string) string {
+	if a == nil || a.mode == "" || a.mode == ModeOff {
+		return ip
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if a.mode == ModeHash {
+		mac := hmac.New(sha256.New, a.key)
+		mac.Write([]byte(parsed.String()))
+		return "ip-" + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(a.ipv4).String()
+	}
+	return parsed.Mask(a.ipv6).String()
+}
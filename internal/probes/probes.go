@@ -0,0 +1,206 @@
+// Package probes serves the Kubernetes probe endpoints. They differ from the
+// services' HealthCheck and the gateway's /health, which describe health for
+// humans and dashboards:
+//
+//   - /healthz (liveness) only shows the process is responsive. It never
+//     looks at dependencies, so an outage downstream does not get every pod
+//     restarted.
+//   - /readyz (readiness) runs the registered checks, such as downstream
+//     connectivity, model warm-up and Redis, and fails while any of them
+//     does, so traffic is routed elsewhere.
+//   - /startupz (startup) fails until the service has finished starting,
+//     holding off the other probes during slow starts such as loading models.
+package probes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// Check reports why a service cannot take traffic, or nil when it can
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Probes holds a service's startup state and readiness checks
+type Probes struct {
+	service string
+	timeout time.Duration // per check
+
+	started     atomic.Bool
+	startup     Check // nil when MarkStarted alone ends startup
+	startupDone atomic.Bool
+
+	mu     sync.RWMutex
+	checks []namedCheck
+}
+
+// New returns the probes of service, bounding each check by timeout
+// (default 2s)
+func New(service string, timeout time.Duration) *Probes {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Probes{service: service, timeout: timeout}
+}
+
+// AddCheck adds a readiness check
+func (p *Probes) AddCheck(name string, check Check) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checks = append(p.checks, namedCheck{name: name, check: check})
+}
+
+// StartupCheck must also pass before startup is complete, e.g. model
+// warm-up. Once it has passed it is not run again. Set it before serving.
+func (p *Probes) StartupCheck(check Check) {
+	p.startup = check
+}
+
+// MarkStarted is called once the service is initialized and serving
+func (p *Probes) MarkStarted() {
+	p.started.Store(true)
+}
+
+// Started reports whether startup is complete
+func (p *Probes) Started(ctx context.Context) error {
+	if !p.started.Load() {
+		return errors.New("starting")
+	}
+	if p.startup != nil && !p.startupDone.Load() {
+		ctx, cancel := context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+		if err := p.startup(ctx); err != nil {
+			return err
+		}
+		p.startupDone.Store(true)
+	}
+	return nil
+}
+
+// Handler serves /healthz, /readyz and /startupz
+func (p *Probes) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", p.Liveness)
+	mux.HandleFunc("/readyz", p.Readiness)
+	mux.HandleFunc("/startupz", p.Startup)
+	return mux
+}
+
+// Liveness answers 200 while the process can serve HTTP at all
+func (p *Probes) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, map[string]interface{}{"status": "ok", "service": p.service})
+}
+
+// Startup answers 200 once startup is complete and 503 until then
+func (p *Probes) Startup(w http.ResponseWriter, r *http.Request) {
+	if err := p.Started(r.Context()); err != nil {
+		writeStatus(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "starting", "service": p.service, "error": err.Error()})
+		return
+	}
+	writeStatus(w, http.StatusOK, map[string]interface{}{"status": "started", "service": p.service})
+}
+
+// Readiness runs every check concurrently, answering 200 when all pass and
+// 503 listing the failures otherwise. A service still starting is not ready.
+func (p *Probes) Readiness(w http.ResponseWriter, r *http.Request) {
+	if err := p.Started(r.Context()); err != nil {
+		writeStatus(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "starting", "service": p.service, "error": err.Error()})
+		return
+	}
+
+	p.mu.RLock()
+	checks := append([]namedCheck(nil), p.checks...)
+	p.mu.RUnlock()
+
+	results := make(map[string]string, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+			defer cancel()
+			result := "ok"
+			if err := c.check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			results[c.name] = result
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	status, code := "ready", http.StatusOK
+	for _, result := range results {
+		if result != "ok" {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	writeStatus(w, code, map[string]interface{}{"status": status, "service": p.service, "checks": results})
+}
+
+func writeStatus(w http.ResponseWriter, code int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Serve starts the probe endpoints on port in the background, for gRPC
+// services that have no HTTP server of their own. It returns nil when port
+// is 0.
+func (p *Probes) Serve(port int) *http.Server {
+	if port == 0 {
+		return nil
+	}
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           p.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.GetLogger().Infof("Probe endpoints for %s listening on port %d", p.service, port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.GetLogger().Errorf("Probe server for %s failed: %v", p.service, err)
+		}
+	}()
+	return server
+}
+
+// notReadyStatuses are HealthCheck statuses of a service that cannot take
+// traffic. Degraded and overloaded services still can.
+var notReadyStatuses = map[string]bool{
+	"warming_up": true,
+	"draining":   true,
+	"unhealthy":  true,
+}
+
+// Health turns a HealthCheck call into a check that fails when the call does
+// or the reported status means the service cannot take traffic
+func Health(call func(ctx context.Context) (*pb.HealthCheckResponse, error)) Check {
+	return func(ctx context.Context) error {
+		resp, err := call(ctx)
+		if err != nil {
+			return err
+		}
+		if notReadyStatuses[resp.Status] {
+			return fmt.Errorf("%s is %s", resp.Service, resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Message is a chat message of a prompt
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Messages returns t as chat messages: the system part, unless it renders
+// empty, then the user part
+func (t Template) Messages(vars map[string]string) []Message {
+	system, user := t.Render(vars)
+	var messages []Message
+	if system != "" {
+		messages = append(messages, Message{Role: "system", Content: system})
+	}
+	return append(messages, Message{Role: "user", Content: user})
+}
+
+// FoldSystem returns messages with a leading system message folded into
+// the user message after it, joined as Text joins the parts. The tokenizer
+// renders these instead when a chat template rejects system messages.
+func FoldSystem(messages []Message) []Message {
+	if len(messages) < 2 || messages[0].Role != "system" {
+		return messages
+	}
+	folded := append([]Message(nil), messages[1:]...)
+	if system := strings.TrimRightFunc(messages[0].Content, unicode.IsSpace); system != "" {
+		folded[0].Content = system + Separator + folded[0].Content
+	}
+	return folded
+}
+
+// ChatTemplate formats the messages of an instruction-tuned model's prompt.
+// It is written in Jinja, as the chat_template of a Hugging Face
+// tokenizer_config.json is. The tokenizer renders it in BuildPrompt, with
+// messages, bos_token, eos_token and add_generation_prompt set; there is
+// no renderer on this side.
+type ChatTemplate struct {
+	Source   string
+	BOSToken string
+	EOSToken string
+}
+
+// builtinChatTemplates are the chat templates of common instruction-tuned
+// model families, as their tokenizer_config.json has them
+var builtinChatTemplates = map[string]ChatTemplate{
+	"chatml": {
+		Source:   "{% for message in messages %}{{'<|im_start|>' + message['role'] + '\\n' + message['content'] + '<|im_end|>' + '\\n'}}{% endfor %}{% if add_generation_prompt %}{{ '<|im_start|>assistant\\n' }}{% endif %}",
+		EOSToken: "<|im_end|>",
+	},
+	"llama3": {
+		Source:   "{% set loop_messages = messages %}{% for message in loop_messages %}{% set content = '<|start_header_id|>' + message['role'] + '<|end_header_id|>\\n\\n'+ message['content'] | trim + '<|eot_id|>' %}{% if loop.index0 == 0 %}{% set content = bos_token + content %}{% endif %}{{ content }}{% endfor %}{% if add_generation_prompt %}{{ '<|start_header_id|>assistant<|end_header_id|>\\n\\n' }}{% endif %}",
+		BOSToken: "<|begin_of_text|>",
+		EOSToken: "<|eot_id|>",
+	},
+	"phi3": {
+		Source:   "{% for message in messages %}{% if message['role'] == 'system' %}{{'<|system|>\\n' + message['content'] + '<|end|>\\n'}}{% elif message['role'] == 'user' %}{{'<|user|>\\n' + message['content'] + '<|end|>\\n'}}{% elif message['role'] == 'assistant' %}{{'<|assistant|>\\n' + message['content'] + '<|end|>\\n'}}{% endif %}{% endfor %}{% if add_generation_prompt %}{{ '<|assistant|>\\n' }}{% else %}{{ eos_token }}{% endif %}",
+		BOSToken: "<s>",
+		EOSToken: "<|endoftext|>",
+	},
+}
+
+// LookupChatTemplate returns a chat template. template is the name of a
+// built-in one (chatml, llama3, phi3) or Jinja source; bosToken and
+// eosToken override a built-in template's when set. The source is only
+// checked once the tokenizer renders it.
+func LookupChatTemplate(template, bosToken, eosToken string) (*ChatTemplate, error) {
+	c, ok := builtinChatTemplates[template]
+	if !ok {
+		if !strings.Contains(template, "{") {
+			return nil, fmt.Errorf("unknown chat template %q", template)
+		}
+		c = ChatTemplate{Source: template}
+	}
+	if bosToken != "" {
+		c.BOSToken = bosToken
+	}
+	if eosToken != "" {
+		c.EOSToken = eosToken
+	}
+	return &c, nil
+}
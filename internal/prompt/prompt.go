@@ -0,0 +1,98 @@
+// Package prompt holds the prompt templates the LLM orchestrator asks the
+// tokenizer to assemble with BuildPrompt. A template is sent by ID with its
+// variables; the tokenizer renders it and adds the special tokens of the
+// model family, so prompt text never carries them. The Python tokenizer
+// keeps the same templates (TEMPLATES in cmd/tokenizer-python/main.py), and
+// the orchestrator renders them here for tokenizers without BuildPrompt.
+package prompt
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Template IDs
+const (
+	Summarize         = "summarize"          // summary of search results
+	SummarizeContinue = "summarize_continue" // continuation of an interrupted summary
+)
+
+// Variables the templates use
+const (
+	VarInstruction     = "instruction"      // the static instruction as a cacheable prefix; may be empty
+	VarUserInstruction = "user_instruction" // the instruction at the start of the user part, when it is not a prefix
+	VarResults         = "results"          // the search results to summarize
+	VarSummary         = "summary"          // the summary so far of a continuation
+)
+
+// Template is a prompt in two parts. Models with chat roles get System as
+// the system message and User as the user message; others get the two
+// joined by Separator. Placeholders are {{name}}; variables that are not set
+// render empty.
+type Template struct {
+	ID     string
+	System string
+	User   string
+
+	// Truncate is the variable cut when the prompt exceeds the model's
+	// context, so the instruction and what follows it are kept
+	Truncate string
+}
+
+var templates = map[string]Template{
+	Summarize: {
+		ID:       Summarize,
+		System:   "{{instruction}}",
+		User:     "{{user_instruction}}{{results}}",
+		Truncate: VarResults,
+	},
+	SummarizeContinue: {
+		ID:       SummarizeContinue,
+		System:   "{{instruction}}",
+		User:     "{{user_instruction}}{{results}}\n\nSummary so far: {{summary}}",
+		Truncate: VarResults,
+	},
+}
+
+// Lookup returns the template with id
+func Lookup(id string) (Template, bool) {
+	t, ok := templates[id]
+	return t, ok
+}
+
+// Render returns the system and user parts of t with vars filled in
+func (t Template) Render(vars map[string]string) (system, user string) {
+	return fill(t.System, vars), fill(t.User, vars)
+}
+
+// Separator sets the user part of a prompt rendered as one text apart from
+// the system part, whose trailing whitespace it replaces
+const Separator = "\n\n"
+
+// Text returns t rendered as one text, for models without chat roles
+func (t Template) Text(vars map[string]string) string {
+	system, user := t.Render(vars)
+	if system = strings.TrimRightFunc(system, unicode.IsSpace); system == "" {
+		return user
+	}
+	return system + Separator + user
+}
+
+func fill(s string, vars map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			break
+		}
+		b.WriteString(s[:start])
+		b.WriteString(vars[strings.TrimSpace(s[start+2:start+end])])
+		s = s[start+end+2:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package prompt
+
+import "testing"
+
+func TestText(t *testing.T) {
+	summarize, _ := Lookup(Summarize)
+	continuation, _ := Lookup(SummarizeContinue)
+	tests := []struct {
+		name     string
+		template Template
+		vars     map[string]string
+		want     string
+	}{
+		{"instruction as prefix", summarize,
+			map[string]string{VarInstruction: "Summarize.", VarResults: "Tides rise."},
+			"Summarize.\n\nTides rise."},
+		{"trailing whitespace of the prefix replaced", summarize,
+			map[string]string{VarInstruction: "Summarize.\n\n", VarResults: "Tides rise."},
+			"Summarize.\n\nTides rise."},
+		{"instruction in the user part", summarize,
+			map[string]string{VarUserInstruction: "Summarize.\n\n", VarResults: "Tides rise."},
+			"Summarize.\n\nTides rise."},
+		{"no instruction", summarize,
+			map[string]string{VarResults: "Tides rise."},
+			"Tides rise."},
+		{"continuation", continuation,
+			map[string]string{VarInstruction: "Summarize.", VarResults: "Tides rise.", VarSummary: "The moon"},
+			"Summarize.\n\nTides rise.\n\nSummary so far: The moon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.template.Text(tt.vars); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessages(t *testing.T) {
+	summarize, _ := Lookup(Summarize)
+	messages := summarize.Messages(map[string]string{VarInstruction: "Summarize.", VarResults: "Tides rise."})
+	if len(messages) != 2 || messages[0] != (Message{Role: "system", Content: "Summarize."}) || messages[1] != (Message{Role: "user", Content: "Tides rise."}) {
+		t.Errorf("got %+v, want a system and a user message", messages)
+	}
+	messages = summarize.Messages(map[string]string{VarUserInstruction: "Summarize.\n\n", VarResults: "Tides rise."})
+	if len(messages) != 1 || messages[0] != (Message{Role: "user", Content: "Summarize.\n\nTides rise."}) {
+		t.Errorf("got %+v, want the instruction in the user message", messages)
+	}
+}
+
+func TestFoldSystem(t *testing.T) {
+	folded := FoldSystem([]Message{{Role: "system", Content: "Summarize.\n\n"}, {Role: "user", Content: "Tides rise."}})
+	if len(folded) != 1 || folded[0] != (Message{Role: "user", Content: "Summarize.\n\nTides rise."}) {
+		t.Errorf("got %+v, want the system message folded into the user message", folded)
+	}
+	userOnly := []Message{{Role: "user", Content: "Tides rise."}}
+	if folded := FoldSystem(userOnly); len(folded) != 1 || folded[0] != userOnly[0] {
+		t.Errorf("got %+v, want a user message left as it is", folded)
+	}
+}
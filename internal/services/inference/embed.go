@@ -0,0 +1,57 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/ollama"
+	pb "ai-search-service/proto"
+)
+
+// Embed returns a dense vector for each text from the configured embedding
+// backend. Unlike Summarize there is no mock fallback: a made-up vector would
+// silently return unrelated documents.
+func (i *InferenceService) Embed(ctx context.Context, req *pb.EmbedRequest) (*pb.EmbedResponse, error) {
+	if len(req.Texts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no texts to embed")
+	}
+	cfg := i.config.Inference.Embedding
+	model := req.ModelName
+	if model == "" {
+		model = cfg.Model
+	}
+
+	vectors, err := i.embed(ctx, cfg.Backend, model, req.Texts)
+	if err != nil {
+		logger.GetLogger().Errorf("Embedding %d texts with %s (%s) failed: %v", len(req.Texts), model, cfg.Backend, err)
+		monitoring.RecordRequest("inference", "embed", "error")
+		return nil, status.Errorf(codes.Unavailable, "embedding failed: %v", err)
+	}
+	monitoring.RecordRequest("inference", "embed", "success")
+
+	resp := &pb.EmbedResponse{ModelName: model, Embeddings: make([]*pb.Embedding, len(vectors))}
+	for n, vector := range vectors {
+		resp.Embeddings[n] = &pb.Embedding{Values: vector}
+	}
+	return resp, nil
+}
+
+func (i *InferenceService) embed(ctx context.Context, backend, model string, texts []string) ([][]float32, error) {
+	switch backend {
+	case "vllm":
+		return i.vllmEngine.Embed(ctx, texts, model)
+	case "ollama", "":
+		resp, err := i.ollamaClient.Embed(ctx, &ollama.EmbedRequest{Model: model, Input: texts})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Embeddings, nil
+	default:
+		return nil, fmt.Errorf("unknown embedding backend %q", backend)
+	}
+}
@@ -0,0 +1,252 @@
+package inference
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/upstream"
+	pb "ai-search-service/proto"
+)
+
+// entityInstruction asks a generation model for the main entity of a query
+// as JSON; %q is the query and the numbered results follow
+const entityInstruction = `Identify the one specific person, organization, place, product, work, event or concept the query %q is about, using only the search results below. ` +
+	`Reply with JSON only, in the form {"name": "...", "type": "person|organization|place|product|work|event|concept", "description": "one sentence", "attributes": [{"name": "...", "value": "..."}], "source": <number of the result used>}, ` +
+	`with at most 5 key facts as attributes. Reply {} if the query is not about one specific entity.` + "\n\n"
+
+// entityTypes are the types an entity may have; anything else is "other"
+var entityTypes = map[string]bool{
+	"person": true, "organization": true, "place": true, "product": true,
+	"work": true, "event": true, "concept": true,
+}
+
+// ExtractEntities finds the main entity of a query in its search results
+// for a knowledge panel, with the configured generation model or NER model.
+// A response without an entity means the results are not about one.
+func (i *InferenceService) ExtractEntities(ctx context.Context, req *pb.ExtractEntitiesRequest) (*pb.ExtractEntitiesResponse, error) {
+	if len(req.Results) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no results to extract entities from")
+	}
+	cfg := i.config.Inference.Entities
+	model := req.ModelName
+	if model == "" {
+		model = cfg.Model
+	}
+
+	var entity *pb.Entity
+	var err error
+	switch cfg.Backend {
+	case "ner":
+		entity, err = i.extractWithNER(ctx, req.Query, req.Results)
+	case "vllm", "ollama", "":
+		entity, model, err = i.extractWithLLM(ctx, cfg.Backend, model, req.Query, req.Results)
+	default:
+		err = fmt.Errorf("unknown entity backend %q", cfg.Backend)
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Entity extraction for %q with %s failed: %v", req.Query, cfg.Backend, err)
+		monitoring.RecordRequest("inference", "extract_entities", "error")
+		return nil, status.Errorf(codes.Unavailable, "entity extraction failed: %v", err)
+	}
+	monitoring.RecordRequest("inference", "extract_entities", "success")
+	return &pb.ExtractEntitiesResponse{Entity: entity, ModelName: model}, nil
+}
+
+// llmEntity is the JSON the generation model is asked to reply with
+type llmEntity struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Attributes  []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"attributes"`
+	Source int `json:"source"` // 1-based result number
+}
+
+// extractWithLLM prompts the generation model of backend, returning the
+// entity and the model that found it
+func (i *InferenceService) extractWithLLM(ctx context.Context, backend, model, query string, results []*pb.SearchResult) (*pb.Entity, string, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, entityInstruction, query)
+	for n, result := range results {
+		fmt.Fprintf(&prompt, "[%d] %s: %s\n", n+1, result.Title, result.Snippet)
+	}
+	reply, model, err := i.generateText(ctx, backend, model, prompt.String(), i.config.Inference.Entities.MaxTokens, "json")
+	if err != nil {
+		return nil, model, err
+	}
+
+	// Models tend to wrap the object in prose or code fences
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, model, fmt.Errorf("model reply is not JSON: %q", reply)
+	}
+	var found llmEntity
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &found); err != nil {
+		return nil, model, fmt.Errorf("failed to parse model reply: %w", err)
+	}
+	if strings.TrimSpace(found.Name) == "" {
+		return nil, model, nil
+	}
+
+	entity := &pb.Entity{
+		Name:        strings.TrimSpace(found.Name),
+		Type:        entityType(found.Type),
+		Description: strings.TrimSpace(found.Description),
+	}
+	for _, attr := range found.Attributes {
+		if attr.Name != "" && attr.Value != "" {
+			entity.Attributes = append(entity.Attributes, &pb.EntityAttribute{Name: attr.Name, Value: attr.Value})
+		}
+	}
+	var source *pb.SearchResult
+	if found.Source >= 1 && found.Source <= len(results) {
+		source = results[found.Source-1]
+	}
+	attribute(entity, source, results)
+	return entity, model, nil
+}
+
+// generateText completes a text prompt with the generation model of backend
+// (vllm, or ollama in the given output format), returning the reply and the
+// model that wrote it; an empty model uses the backend's current one
+func (i *InferenceService) generateText(ctx context.Context, backend, model, prompt string, maxTokens int, format string) (string, string, error) {
+	if backend == "ollama" {
+		genReq := i.ollamaClient.NewRequest(prompt, maxTokens)
+		if model != "" {
+			genReq.Model = model
+		}
+		genReq.Format = format
+		resp, err := i.ollamaClient.Generate(ctx, genReq)
+		if err != nil {
+			return "", genReq.Model, err
+		}
+		return resp.Response, genReq.Model, nil
+	}
+	if model == "" {
+		model = i.vllmEngine.Model()
+	}
+	reply, err := i.vllmEngine.GenerateFromPrompt(ctx, prompt, model, maxTokens)
+	return reply, model, err
+}
+
+// nerEntity is an entity group of a Hugging Face token classification
+// response
+type nerEntity struct {
+	Group string  `json:"entity_group"`
+	Word  string  `json:"word"`
+	Score float64 `json:"score"`
+}
+
+// nerTypes maps CoNLL entity groups to entity types
+var nerTypes = map[string]string{"PER": "person", "ORG": "organization", "LOC": "place", "MISC": "other"}
+
+// extractWithNER runs the results through a token classification model and
+// picks the entity named in the query, or else the one the results mention
+// most. NER finds names only, so the panel has no attributes.
+func (i *InferenceService) extractWithNER(ctx context.Context, query string, results []*pb.SearchResult) (*pb.Entity, error) {
+	texts := make([]string, len(results))
+	for n, result := range results {
+		texts[n] = result.Title + ". " + result.Snippet
+	}
+	body, err := json.Marshal(map[string]interface{}{"inputs": texts})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, i.config.Inference.Entities.NERURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NER request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Transport: upstream.WrapTransport(i.config, "ner", nil)}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("NER request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NER model returned status %d", resp.StatusCode)
+	}
+	var perText [][]nerEntity
+	if err := json.NewDecoder(resp.Body).Decode(&perText); err != nil {
+		return nil, fmt.Errorf("failed to decode NER response: %w", err)
+	}
+
+	q := strings.ToLower(query)
+	var best *nerEntity
+	bestScore := 0.0
+	mentions := make(map[string]float64)
+	for _, entities := range perText {
+		for n := range entities {
+			e := &entities[n]
+			if e.Score < 0.5 || len(e.Word) < 2 {
+				continue
+			}
+			key := strings.ToLower(e.Word)
+			mentions[key] += e.Score
+			score := mentions[key]
+			if strings.Contains(q, key) {
+				score += 100 // named in the query
+			}
+			if score > bestScore {
+				best, bestScore = e, score
+			}
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	entity := &pb.Entity{Name: best.Word, Type: entityType(nerTypes[best.Group])}
+	name := strings.ToLower(best.Word)
+	var source *pb.SearchResult
+	for _, result := range results {
+		if strings.Contains(strings.ToLower(result.Title+" "+result.Snippet), name) {
+			source = result
+			entity.Description = result.Snippet
+			break
+		}
+	}
+	attribute(entity, source, results)
+	return entity, nil
+}
+
+// attribute links the entity to the result it was found in and takes an
+// image from it, or else from the first result mentioning the entity
+func attribute(entity *pb.Entity, source *pb.SearchResult, results []*pb.SearchResult) {
+	if source != nil {
+		entity.SourceUrl = source.Url
+		entity.ImageUrl = source.ImageUrl
+	}
+	if entity.ImageUrl != "" {
+		return
+	}
+	name := strings.ToLower(entity.Name)
+	for _, result := range results {
+		if result.ImageUrl != "" && strings.Contains(strings.ToLower(result.Title+" "+result.Snippet), name) {
+			entity.ImageUrl = result.ImageUrl
+			if entity.SourceUrl == "" {
+				entity.SourceUrl = result.Url
+			}
+			return
+		}
+	}
+}
+
+func entityType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if entityTypes[t] {
+		return t
+	}
+	return "other"
+}
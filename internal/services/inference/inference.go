@@ -3,7 +3,6 @@ package inference
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -11,7 +10,12 @@ import (
 	"ai-search-service/internal/config"
 	"ai-search-service/internal/logger"
 	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/ollama"
+	"ai-search-service/internal/upstream"
 	pb "ai-search-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // RequestContext tracks individual inference requests for concurrency control
@@ -25,10 +29,17 @@ type RequestContext struct {
 
 type InferenceService struct {
 	pb.UnimplementedInferenceServiceServer
-	config     *config.Config
-	httpClient *http.Client
-	metrics    *monitoring.MetricsCollector
-	vllmEngine *VLLMEngine  // Enterprise token-native engine
+	config       *config.Config
+	metrics      *monitoring.MetricsCollector
+	vllmEngine   *VLLMEngine    // Enterprise token-native engine
+	tokenEngine  tokenEngine    // nil unless inference.backend.engine is triton or onnx-local
+	llamaServer  *llamaServer   // the llama-server process run for engine llamacpp, if any
+	ollamaClient *ollama.Client // Text-native engine for requests without tokens
+	warmup       *warmupState
+	switches     *modelSwitches
+	prefixCache  *prefixCache // mirrors backend prefix caches for hit-rate metrics
+
+	tokenizerPins map[string]string // tokenizer version by model, from inference.tokenizer_pins
 	
 	// Concurrency control
 	activeRequests    map[string]*RequestContext
@@ -45,30 +56,63 @@ func NewInferenceService(cfg *config.Config) (*InferenceService, error) {
 		logger.GetLogger().Warnf("Failed to initialize metrics collector: %v", err)
 	}
 
-	// Initialize enterprise vLLM engine
-	vllmEngine := NewVLLMEngine(cfg)
+	// Initialize enterprise vLLM engine, or drive a llama.cpp server through it
+	var vllmEngine *VLLMEngine
+	var llamaServer *llamaServer
+	if cfg.Inference.Backend.Engine == "llamacpp" {
+		vllmEngine, llamaServer, err = newLlamaCppEngine(cfg)
+	} else {
+		vllmEngine, err = NewVLLMEngine(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Token-prompted summaries go to Triton or ONNX Runtime instead of vLLM when selected
+	tokenEngine, err := newTokenEngine(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ollamaClient := ollama.NewClient(cfg.Ollama)
+	ollamaClient.SetTransport(upstream.WrapTransport(cfg, "ollama", nil))
 
 	// Set concurrent request limits
 	maxConcurrentReqs := 8 // Default: reasonable limit for inference operations
 	requestTimeout := time.Minute * 2 // Default: 2 minutes per request
 
 	return &InferenceService{
-		config: cfg,
-		httpClient: &http.Client{
-			Timeout: cfg.VLLM.Timeout,
-		},
+		config:            cfg,
 		metrics:           metricsCollector,
 		vllmEngine:        vllmEngine,
+		tokenEngine:       tokenEngine,
+		llamaServer:       llamaServer,
+		ollamaClient:      ollamaClient,
+		warmup:            &warmupState{status: WarmupPending},
+		switches:          newModelSwitches(),
+		prefixCache:       newPrefixCache(cfg.Inference.PrefixCache.MaxEntries),
+		tokenizerPins:     newTokenizerPins(cfg.Inference.TokenizerPins),
 		activeRequests:    make(map[string]*RequestContext),
 		maxConcurrentReqs: maxConcurrentReqs,
 		requestTimeout:    requestTimeout,
 	}, nil
 }
 
+// Close stops the llama-server process the service started, if any
+func (i *InferenceService) Close() {
+	if i.llamaServer != nil {
+		i.llamaServer.stop()
+	}
+}
+
 func (i *InferenceService) Summarize(ctx context.Context, req *pb.SummarizeRequest) (*pb.SummarizeResponse, error) {
 	start := time.Now()
 	log := logger.GetLogger()
 
+	if err := i.checkTokenizerVersion(req); err != nil {
+		return nil, err
+	}
+
 	// Check concurrent request limit
 	i.requestsMutex.RLock()
 	activeCount := len(i.activeRequests)
@@ -109,15 +153,39 @@ func (i *InferenceService) Summarize(ctx context.Context, req *pb.SummarizeReque
 
 	var modelName string
 	var summary string
+	var confidence float32 // model token probability, unknown for Ollama and mock summaries
+	var generated []int32  // the summary's token IDs, from Triton or ONNX Runtime
 
 	// INDUSTRY STANDARD: Token-native processing vs fallback
-	if len(req.TokenIds) > 0 {
+	if len(req.TokenIds) > 0 && i.tokenEngine != nil {
+		engine := i.tokenEngine
+		log.Infof("Processing %d tokens via %s (model: %s)", len(req.TokenIds), engine.Name(), engine.Model())
+
+		modelName = engine.Model()
+		ids, err := engine.Generate(requestCtx, req.TokenIds, int(req.MaxLength))
+		if err != nil {
+			log.Errorf("%s generation failed: %v", engine.Name(), err)
+			monitoring.RecordRequest("inference", engine.Name()+"_generate", "error")
+			// Fallback to mock
+			summary = i.generateMockSummary("Enterprise tokenized content", int(req.MaxLength))
+		} else {
+			generated = ids
+		}
+	} else if len(req.TokenIds) > 0 {
 		log.Infof("🚀 ENTERPRISE: Processing %d tokens directly via vLLM (model: %s)", 
 			len(req.TokenIds), req.ModelName)
 		
+		prefixHit, prefixTracked := i.observeTokenPrefix(req)
+		generateStart := time.Now()
+
 		// INDUSTRY STANDARD: Send tokens directly to vLLM (NO text conversion!)
-		result, err := i.vllmEngine.GenerateFromTokens(requestCtx, req.TokenIds, req.ModelName, int(req.MaxLength))
+		result, probability, err := i.vllmEngine.GenerateFromTokens(requestCtx, req.TokenIds, req.ModelName, int(req.MaxLength), req.Sampling)
+		i.observeGeneration("vllm", err)
 		modelName = req.ModelName
+		if err == nil && prefixTracked {
+			// vLLM does not report prompt time separately; use the full generation
+			monitoring.RecordPrefixCache("inference", "vllm", prefixHit, time.Since(generateStart))
+		}
 		
 		if err != nil {
 			log.Errorf("vLLM token generation failed: %v", err)
@@ -126,13 +194,25 @@ func (i *InferenceService) Summarize(ctx context.Context, req *pb.SummarizeReque
 			summary = i.generateMockSummary("Enterprise tokenized content", int(req.MaxLength))
 		} else {
 			summary = result
+			confidence = probability
 		}
 	} else {
-		log.Infof("No tokens provided - using mock summary for text request: %d characters", len(req.OriginalText))
-		
-		// Generate mock summary when no tokenization is available
-		modelName = "mock"
-		summary = i.generateMockSummary(req.OriginalText, int(req.MaxLength))
+		log.Infof("No tokens provided - generating via Ollama for text request: %d characters", len(req.OriginalText))
+
+		modelName = i.ollamaClient.Model()
+		result, err := i.ollamaClient.Generate(requestCtx, i.newOllamaSummaryRequest(req))
+		i.observeGeneration("ollama", err)
+		if err != nil {
+			log.Errorf("Ollama generation failed: %v", err)
+			monitoring.RecordRequest("inference", "ollama_generate", "error")
+			// Fallback to mock
+			modelName = "mock"
+			summary = i.generateMockSummary(req.OriginalText, int(req.MaxLength))
+		} else {
+			monitoring.RecordTokensProcessed("inference", modelName, result.PromptEvalCount+result.EvalCount)
+			i.recordOllamaPrefix(modelName, result)
+			summary = strings.TrimSpace(result.Response)
+		}
 	}
 
 	// Record inference latency
@@ -144,7 +224,9 @@ func (i *InferenceService) Summarize(ctx context.Context, req *pb.SummarizeReque
 		Summary:    summary,
 		Success:    true,
 		TokensUsed: int32(len(req.OriginalText)),
-		Confidence: 0.85,
+		Confidence: confidence,
+
+		GeneratedTokenIds: generated,
 	}, nil
 }
 
@@ -152,6 +234,10 @@ func (i *InferenceService) SummarizeStream(req *pb.SummarizeRequest, stream pb.I
 	start := time.Now()
 	log := logger.GetLogger()
 
+	if err := i.checkTokenizerVersion(req); err != nil {
+		return err
+	}
+
 	// Check concurrent request limit
 	i.requestsMutex.RLock()
 	activeCount := len(i.activeRequests)
@@ -192,6 +278,20 @@ func (i *InferenceService) SummarizeStream(req *pb.SummarizeRequest, stream pb.I
 
 	var modelName string
 
+	// Triton and ONNX Runtime generate the whole summary, then its token IDs are streamed
+	if len(req.TokenIds) > 0 && i.tokenEngine != nil {
+		engine := i.tokenEngine
+		modelName = engine.Model()
+		err := i.streamGeneratedTokens(requestCtx, req, stream)
+		if err != nil {
+			log.Errorf("%s generation failed: %v", engine.Name(), err)
+			monitoring.RecordRequest("inference", engine.Name()+"_stream", "error")
+			err = i.mockStreamingSummary(req, stream)
+		}
+		monitoring.RecordInferenceLatency("inference", modelName, true, time.Since(start))
+		return err
+	}
+
 	// INDUSTRY STANDARD: Token-native streaming vs fallback
 	if len(req.TokenIds) > 0 {
 		log.Infof("🚀 ENTERPRISE STREAMING: %d tokens directly via vLLM (model: %s)", 
@@ -200,10 +300,16 @@ func (i *InferenceService) SummarizeStream(req *pb.SummarizeRequest, stream pb.I
 		modelName = req.ModelName
 		
 		// INDUSTRY STANDARD: Stream tokens directly from vLLM
-		err := i.streamVLLMTokens(requestCtx, req.TokenIds, req.ModelName, int(req.MaxLength), stream)
+		sent, err := i.streamVLLMTokens(requestCtx, req, stream)
+		i.observeGeneration("vllm", err)
 		if err != nil {
 			log.Errorf("vLLM token streaming failed: %v", err)
 			monitoring.RecordRequest("inference", "vllm_stream", "error")
+			if sent {
+				// A mock summary would not follow on from what was streamed;
+				// the orchestrator recovers the stream instead
+				return status.Errorf(codes.Unavailable, "vLLM stream interrupted: %v", err)
+			}
 			// Fallback to mock streaming
 			err = i.mockStreamingSummary(req, stream)
 		}
@@ -213,36 +319,59 @@ func (i *InferenceService) SummarizeStream(req *pb.SummarizeRequest, stream pb.I
 		log.Infof("vLLM token streaming complete")
 		return err
 	} else {
-		log.Infof("No tokens provided - using mock streaming for text request: %d characters", len(req.OriginalText))
-		
-		modelName = "mock"
-		
-		// Use mock streaming when no tokenization is available
-		err := i.mockStreamingSummary(req, stream)
-		
+		log.Infof("No tokens provided - streaming via Ollama for text request: %d characters", len(req.OriginalText))
+
+		modelName = i.ollamaClient.Model()
+
+		sent, err := i.streamOllama(requestCtx, req, stream)
+		i.observeGeneration("ollama", err)
+		if err != nil {
+			log.Errorf("Ollama streaming failed: %v", err)
+			monitoring.RecordRequest("inference", "ollama_stream", "error")
+			if sent {
+				// A mock summary would not follow on from what was streamed
+				return status.Errorf(codes.Unavailable, "Ollama stream interrupted: %v", err)
+			}
+			// Fallback to mock streaming
+			modelName = "mock"
+			err = i.mockStreamingSummary(req, stream)
+		}
+
 		// Record inference latency
 		monitoring.RecordInferenceLatency("inference", modelName, true, time.Since(start))
-		
-		log.Infof("Mock streaming complete")
+
+		log.Infof("Text streaming complete")
 		return err
 	}
 }
 
 func (i *InferenceService) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
-	// Check if vLLM is available
-	vllmURL := fmt.Sprintf("http://%s:%d/health", i.config.VLLM.Host, i.config.VLLM.Port)
-	resp, err := i.httpClient.Get(vllmURL)
-	if err != nil {
+	warmup := i.GetWarmupStatus()
+
+	// Not ready until the models are loaded, so first requests don't pay the cold start
+	if !warmup.Ready() {
 		return &pb.HealthCheckResponse{
-			Status:    "degraded", // Still functional with mock summaries
+			Status:    "warming_up",
 			Service:   "inference",
 			Timestamp: time.Now().Unix(),
+			Details:   warmup.Details(),
 		}, nil
 	}
-	defer resp.Body.Close()
 
+	// Healthy when either backend is reachable; otherwise still functional with mock summaries
 	status := "healthy"
-	if resp.StatusCode != http.StatusOK {
+	engine, engineErr := "vLLM", error(nil)
+	if i.tokenEngine != nil {
+		engine, engineErr = i.tokenEngine.Name(), i.tokenEngine.Health(ctx)
+	} else {
+		engineErr = i.vllmEngine.Health(ctx)
+	}
+	ollamaErr := i.ollamaClient.Heartbeat(ctx)
+	if engineErr != nil && ollamaErr != nil {
+		logger.GetLogger().Warnf("No inference backend reachable (%s: %v, Ollama: %v)", engine, engineErr, ollamaErr)
+		status = "degraded"
+	}
+	if warmup.Status == WarmupFailed {
 		status = "degraded"
 	}
 
@@ -250,26 +379,116 @@ func (i *InferenceService) HealthCheck(ctx context.Context, req *pb.HealthCheckR
 		Status:    status,
 		Service:   "inference",
 		Timestamp: time.Now().Unix(),
+		Details:   i.switches.addDetails(warmup.Details()),
 	}, nil
 }
 
-func (i *InferenceService) createSummarizationPrompt(originalText string, maxLength int) string {
-	return fmt.Sprintf(`Please provide a concise summary of the following text. The summary should be informative and capture the key points. Keep it under %d characters.
-
-Text to summarize:
+// createSummarizationPrompt splits the prompt into the static instruction and the
+// per-request part. The instruction is sent as the system prompt so every request
+// starts with an identical, cacheable prefix; anything variable (including the
+// length limit) goes after it.
+func (i *InferenceService) createSummarizationPrompt(originalText string, maxLength int) (string, string) {
+	prompt := fmt.Sprintf(`Text to summarize:
 %s
 
-Summary:`, maxLength, originalText)
+Keep the summary under %d characters.
+
+Summary:`, originalText, maxLength)
+	return i.summarizationInstruction(), prompt
+}
+
+// summarizationInstruction returns the shared instruction prefix
+func (i *InferenceService) summarizationInstruction() string {
+	instruction := i.config.Inference.PrefixCache.Instruction
+	if instruction == "" {
+		instruction = config.DefaultSummarizationInstruction
+	}
+	return strings.TrimSpace(instruction)
 }
 
+// newOllamaSummaryRequest builds an Ollama request with the instruction as
+// system prompt and the request's sampling parameters
+func (i *InferenceService) newOllamaSummaryRequest(req *pb.SummarizeRequest) *ollama.GenerateRequest {
+	system, prompt := i.createSummarizationPrompt(req.OriginalText, int(req.MaxLength))
+	genReq := i.ollamaClient.NewRequest(prompt, 0)
+	genReq.System = system
+	if req.ModelName != "" {
+		// Only direct text requests get here; they may name the Ollama model
+		genReq.Model = req.ModelName
+	}
+	if sampling := req.Sampling; sampling != nil {
+		if sampling.Temperature != nil {
+			genReq.Options.Temperature = float64(*sampling.Temperature)
+		}
+		if sampling.TopP != nil {
+			genReq.Options.TopP = float64(*sampling.TopP)
+		}
+		if sampling.FrequencyPenalty != nil {
+			genReq.Options.FrequencyPenalty = float64(*sampling.FrequencyPenalty)
+		}
+		if sampling.PresencePenalty != nil {
+			genReq.Options.PresencePenalty = float64(*sampling.PresencePenalty)
+		}
+		genReq.Options.Stop, genReq.Options.Seed = sampling.Stop, sampling.Seed
+	}
+	return genReq
+}
+
+// observeTokenPrefix looks up the instruction prefix marked by the orchestrator.
+// tracked is false when prefix caching is disabled or the request has no prefix.
+func (i *InferenceService) observeTokenPrefix(req *pb.SummarizeRequest) (hit bool, tracked bool) {
+	n := int(req.PrefixTokenCount)
+	if !i.config.Inference.PrefixCache.Enabled || n <= 0 || n > len(req.TokenIds) {
+		return false, false
+	}
+	model := req.ModelName
+	if model == "" {
+		model = i.vllmEngine.Model()
+	}
+	return i.prefixCache.observeTokens(model, req.TokenIds[:n]), true
+}
+
+// recordOllamaPrefix records the prefix cache result using Ollama's reported
+// prompt evaluation time, which drops when the system prompt's KV cache is reused
+func (i *InferenceService) recordOllamaPrefix(model string, result *ollama.GenerateResponse) {
+	if !i.config.Inference.PrefixCache.Enabled {
+		return
+	}
+	hit := i.prefixCache.observeText(model, i.summarizationInstruction())
+	monitoring.RecordPrefixCache("inference", "ollama", hit, time.Duration(result.PromptEvalDuration))
+}
+
+
+// streamGeneratedTokens generates the summary with the token engine and
+// streams its token IDs, which the orchestrator detokenizes as they arrive
+func (i *InferenceService) streamGeneratedTokens(ctx context.Context, req *pb.SummarizeRequest, stream pb.InferenceService_SummarizeStreamServer) error {
+	ids, err := i.tokenEngine.Generate(ctx, req.TokenIds, int(req.MaxLength))
+	if err != nil {
+		return err
+	}
+	for n, id := range ids {
+		if err := stream.Send(&pb.SummarizeStreamResponse{GeneratedTokenId: id, Position: int32(n)}); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&pb.SummarizeStreamResponse{IsFinal: true, Position: int32(len(ids))})
+}
 
-// streamVLLMTokens handles token-native streaming with vLLM
-func (i *InferenceService) streamVLLMTokens(ctx context.Context, tokenIds []int32, modelName string, maxLength int, stream pb.InferenceService_SummarizeStreamServer) error {
+// streamVLLMTokens handles token-native streaming with vLLM. sent reports
+// whether any text reached the client before an error.
+func (i *InferenceService) streamVLLMTokens(ctx context.Context, req *pb.SummarizeRequest, stream pb.InferenceService_SummarizeStreamServer) (sent bool, err error) {
 	position := int32(0)
+	prefixHit, prefixTracked := i.observeTokenPrefix(req)
+	start := time.Now()
+	defer func() { sent = position > 0 }()
 	
 	// Stream tokens directly from vLLM
-	return i.vllmEngine.StreamFromTokens(ctx, tokenIds, modelName, maxLength, func(content string, isFinished bool) {
+	return false, i.vllmEngine.StreamFromTokens(ctx, req.TokenIds, req.ModelName, int(req.MaxLength), req.Sampling, func(content string, isFinished bool, probability float32) {
 		if content != "" {
+			if position == 0 && prefixTracked {
+				// Time to first token is dominated by prompt processing
+				monitoring.RecordPrefixCache("inference", "vllm", prefixHit, time.Since(start))
+			}
 			// Send each token chunk to client
 			resp := &pb.SummarizeStreamResponse{
 				Token:    content,
@@ -283,9 +502,10 @@ func (i *InferenceService) streamVLLMTokens(ctx context.Context, tokenIds []int3
 		if isFinished {
 			// Send final completion signal
 			resp := &pb.SummarizeStreamResponse{
-				Token:    "",
-				IsFinal:  true,
-				Position: position,
+				Token:      "",
+				IsFinal:    true,
+				Position:   position,
+				Confidence: probability,
 			}
 			stream.Send(resp)
 		}
@@ -294,6 +514,37 @@ func (i *InferenceService) streamVLLMTokens(ctx context.Context, tokenIds []int3
 
 
 
+// streamOllama handles text-native streaming with Ollama. sent reports
+// whether any text reached the client before an error.
+func (i *InferenceService) streamOllama(ctx context.Context, req *pb.SummarizeRequest, stream pb.InferenceService_SummarizeStreamServer) (sent bool, err error) {
+	position := int32(0)
+	defer func() { sent = position > 0 }()
+
+	return false, i.ollamaClient.GenerateStream(ctx, i.newOllamaSummaryRequest(req), func(chunk *ollama.GenerateResponse) error {
+		if chunk.Response != "" {
+			if err := stream.Send(&pb.SummarizeStreamResponse{
+				Token:    chunk.Response,
+				Position: position,
+			}); err != nil {
+				return fmt.Errorf("failed to send stream response: %w", err)
+			}
+			position++
+		}
+
+		if chunk.Done {
+			i.recordOllamaPrefix(chunk.Model, chunk)
+
+			// Send final completion signal
+			return stream.Send(&pb.SummarizeStreamResponse{
+				Token:    "",
+				IsFinal:  true,
+				Position: position,
+			})
+		}
+		return nil
+	})
+}
+
 func (i *InferenceService) mockStreamingSummary(req *pb.SummarizeRequest, stream pb.InferenceService_SummarizeStreamServer) error {
 	log := logger.GetLogger()
 	log.Warn("Using mock streaming summary as fallback")
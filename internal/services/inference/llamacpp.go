@@ -0,0 +1,143 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/upstream"
+)
+
+// newLlamaCppEngine returns the engine for a llama.cpp server, starting
+// one for the configured GGUF model when server_path is set. llama-server
+// serves the same OpenAI-compatible completions API as vLLM, token ID
+// prompts and streaming included, so the vLLM engine drives it; the server
+// is nil when it is run elsewhere.
+func newLlamaCppEngine(cfg *config.Config) (*VLLMEngine, *llamaServer, error) {
+	lcfg := cfg.Inference.LlamaCpp
+	if transport := cfg.Inference.Backend.Transport; transport != "http" && transport != "" {
+		return nil, nil, fmt.Errorf("llama.cpp is only served over http, not %q", transport)
+	}
+	engine := &VLLMEngine{
+		baseURL:      lcfg.URL,
+		defaultModel: lcfg.Model,
+		httpClient: &http.Client{
+			Timeout:   lcfg.Timeout,
+			Transport: upstream.WrapTransport(cfg, "llamacpp", nil),
+		},
+	}
+	if lcfg.ServerPath == "" {
+		return engine, nil, nil
+	}
+	server, err := startLlamaServer(lcfg, engine.httpClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	return engine, server, nil
+}
+
+// llamaServer is a llama-server process run by the inference service
+type llamaServer struct {
+	url        string
+	httpClient *http.Client
+	cmd        *exec.Cmd
+	exited     chan struct{} // closed once the process exits
+	err        error         // why it exited, once exited is closed
+}
+
+// startLlamaServer starts llama-server on the host and port of the
+// configured URL, loading the model with its context length, threads and
+// GPU layers
+func startLlamaServer(lcfg config.LlamaCppConfig, httpClient *http.Client) (*llamaServer, error) {
+	u, err := url.Parse(lcfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid llama.cpp url %q: %w", lcfg.URL, err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("llama.cpp url %q needs a host and port to start the server on: %w", lcfg.URL, err)
+	}
+	if lcfg.ModelPath == "" {
+		return nil, errors.New("llama.cpp server_path is set without a model_path to load")
+	}
+
+	args := []string{"--model", lcfg.ModelPath, "--host", host, "--port", port}
+	if lcfg.ContextLength > 0 {
+		args = append(args, "--ctx-size", strconv.Itoa(lcfg.ContextLength))
+	}
+	if lcfg.Threads > 0 {
+		args = append(args, "--threads", strconv.Itoa(lcfg.Threads))
+	}
+	if lcfg.GPULayers != 0 {
+		args = append(args, "--n-gpu-layers", strconv.Itoa(lcfg.GPULayers))
+	}
+	if lcfg.Model != "" {
+		args = append(args, "--alias", lcfg.Model)
+	}
+
+	cmd := exec.Command(lcfg.ServerPath, args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start llama-server %s: %w", lcfg.ServerPath, err)
+	}
+	logger.GetLogger().Infof("Started llama-server (pid %d) for %s on %s", cmd.Process.Pid, lcfg.ModelPath, u.Host)
+
+	s := &llamaServer{url: lcfg.URL, httpClient: httpClient, cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		s.err = cmd.Wait()
+		close(s.exited)
+	}()
+	return s, nil
+}
+
+// waitReady waits until the server has loaded its model; llama-server
+// answers /health with 503 while loading
+func (s *llamaServer) waitReady(ctx context.Context) error {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"/health", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		if resp, err := s.httpClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-s.exited:
+			return fmt.Errorf("llama-server exited: %v", s.err)
+		case <-ctx.Done():
+			return fmt.Errorf("llama-server not ready: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop asks the server to shut down, killing it if it has not within five
+// seconds
+func (s *llamaServer) stop() {
+	select {
+	case <-s.exited:
+		return
+	default:
+	}
+	s.cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-s.exited:
+	case <-time.After(5 * time.Second):
+		s.cmd.Process.Kill()
+		<-s.exited
+	}
+}
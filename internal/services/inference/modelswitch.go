@@ -0,0 +1,299 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// modelTarget is where a backend's generations go
+type modelTarget struct {
+	url   string // vLLM only
+	model string
+}
+
+// outcomes counts a backend's generations since its target became active
+type outcomes struct {
+	requests int
+	errors   int
+}
+
+func (o outcomes) errorRate() float64 {
+	if o.requests == 0 {
+		return 0
+	}
+	return float64(o.errors) / float64(o.requests)
+}
+
+// switchWatch is a cutover whose error rate is compared with the previous
+// target's until the rollback window ends
+type switchWatch struct {
+	backend  string
+	previous modelTarget
+	target   modelTarget
+	baseline float64 // the previous target's error rate
+	until    time.Time
+}
+
+// modelSwitches tracks each backend's generation outcomes and the one
+// switch that may be in progress or watched at a time
+type modelSwitches struct {
+	mu        sync.Mutex
+	switching bool // a target is being warmed and tested
+	outcomes  map[string]*outcomes
+	watch     *switchWatch
+	last      string // how the latest switch ended, for HealthCheck details
+}
+
+func newModelSwitches() *modelSwitches {
+	return &modelSwitches{outcomes: map[string]*outcomes{"vllm": {}, "ollama": {}}}
+}
+
+// SwitchModel cuts a backend over to another model or vLLM server (blue/
+// green). The target is first sent the warm-up prompt, so it is loaded
+// before traffic reaches it, and then the canary prompt, whose answer must
+// contain inference.model_switch.canary_expect; either failing refuses the
+// switch and leaves the current target active. After the cutover the
+// previous target is restored automatically if the error rate regresses
+// within inference.model_switch.window.
+func (i *InferenceService) SwitchModel(ctx context.Context, req *pb.SwitchModelRequest) (*pb.SwitchModelResponse, error) {
+	log := logger.GetLogger()
+	cfg := i.config.Inference
+
+	previous, err := i.modelTarget(req.Backend)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.Url != "" && req.Backend != "vllm" {
+		return nil, status.Error(codes.InvalidArgument, "only the vLLM server can be switched")
+	}
+	target := previous
+	if req.Model != "" {
+		target.model = req.Model
+	}
+	if req.Url != "" {
+		target.url = strings.TrimRight(req.Url, "/")
+	}
+	if target == previous {
+		return nil, status.Error(codes.InvalidArgument, "the target is already active")
+	}
+	if err := i.switches.begin(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer i.switches.end()
+
+	resp := &pb.SwitchModelResponse{
+		Model:         previous.model,
+		Url:           previous.url,
+		PreviousModel: previous.model,
+		PreviousUrl:   previous.url,
+	}
+	refuse := func(outcome, reason string) (*pb.SwitchModelResponse, error) {
+		log.Warnf("Refused to switch %s to %s %s: %s", req.Backend, target.model, target.url, reason)
+		monitoring.RecordModelSwitch(req.Backend, outcome)
+		resp.Error = reason
+		return resp, nil
+	}
+
+	if cfg.Warmup.Prompt != "" {
+		start := time.Now()
+		_, err := i.generateWith(ctx, req.Backend, target, cfg.Warmup.Prompt, cfg.Warmup.MaxTokens)
+		monitoring.RecordModelWarmup("inference", req.Backend, target.model, err == nil, time.Since(start))
+		if err != nil {
+			return refuse("refused_warmup", fmt.Sprintf("warm-up failed: %v", err))
+		}
+	}
+	canary, err := i.generateWith(ctx, req.Backend, target, cfg.ModelSwitch.CanaryPrompt, cfg.ModelSwitch.CanaryMaxTokens)
+	if err != nil {
+		return refuse("refused_canary", fmt.Sprintf("canary prompt failed: %v", err))
+	}
+	resp.CanaryOutput = canary
+	if expect := cfg.ModelSwitch.CanaryExpect; !strings.Contains(strings.ToLower(canary), strings.ToLower(expect)) {
+		return refuse("refused_canary", fmt.Sprintf("canary answer %q does not contain %q", canary, expect))
+	}
+
+	watch := i.cutOver(req.Backend, previous, target)
+	log.Infof("Switched %s from %s %s to %s %s, watching its error rate until %s",
+		req.Backend, previous.model, previous.url, target.model, target.url, watch.until.Format(time.RFC3339))
+	monitoring.RecordModelSwitch(req.Backend, "switched")
+	resp.Switched = true
+	resp.Model, resp.Url = target.model, target.url
+	resp.WatchUntil = watch.until.Unix()
+	return resp, nil
+}
+
+// ListModels reports each generation backend's active target, whether it
+// answered the warm-up prompt at startup and, while a switch to it is
+// watched, until when it may still be rolled back
+func (i *InferenceService) ListModels(ctx context.Context, req *pb.ListModelsRequest) (*pb.ListModelsResponse, error) {
+	warmed := make(map[string]bool)
+	for _, pair := range i.GetWarmupStatus().Warmed {
+		warmed[pair] = true
+	}
+	s := i.switches
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.ListModelsResponse{}
+	for _, backend := range []string{"vllm", "ollama"} {
+		target, _ := i.modelTarget(backend)
+		info := &pb.ModelInfo{
+			Backend: backend,
+			Model:   target.model,
+			Url:     target.url,
+			Warmed:  warmed[backend+"/"+target.model],
+		}
+		if s.watch != nil && s.watch.backend == backend {
+			info.WatchUntil = s.watch.until.Unix()
+		}
+		resp.Models = append(resp.Models, info)
+	}
+	return resp, nil
+}
+
+// modelTarget returns the backend's active target
+func (i *InferenceService) modelTarget(backend string) (modelTarget, error) {
+	switch backend {
+	case "vllm":
+		return modelTarget{url: i.vllmEngine.BaseURL(), model: i.vllmEngine.Model()}, nil
+	case "ollama":
+		return modelTarget{model: i.ollamaClient.Model()}, nil
+	default:
+		return modelTarget{}, fmt.Errorf("unknown inference backend %q", backend)
+	}
+}
+
+// setModelTarget sends the backend's generations to target
+func (i *InferenceService) setModelTarget(backend string, target modelTarget) {
+	switch backend {
+	case "vllm":
+		i.vllmEngine.SetTarget(target.url, target.model)
+	case "ollama":
+		i.ollamaClient.SetModel(target.model)
+	}
+}
+
+// generateWith answers a text prompt with a target that is not active yet
+func (i *InferenceService) generateWith(ctx context.Context, backend string, target modelTarget, prompt string, maxTokens int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, i.config.Inference.ModelSwitch.Timeout)
+	defer cancel()
+	if backend == "vllm" {
+		text, err := i.vllmEngine.withTarget(target.url, target.model).GenerateFromPrompt(ctx, prompt, target.model, maxTokens)
+		return strings.TrimSpace(text), err
+	}
+	req := i.ollamaClient.NewRequest(prompt, maxTokens)
+	req.Model = target.model
+	result, err := i.ollamaClient.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Response), nil
+}
+
+// cutOver activates target and starts watching its error rate against the
+// previous target's
+func (i *InferenceService) cutOver(backend string, previous, target modelTarget) *switchWatch {
+	s := i.switches
+	window := i.config.Inference.ModelSwitch.Window
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i.setModelTarget(backend, target)
+	watch := &switchWatch{
+		backend:  backend,
+		previous: previous,
+		target:   target,
+		baseline: s.outcomes[backend].errorRate(),
+		until:    time.Now().Add(window),
+	}
+	s.outcomes[backend] = &outcomes{}
+	s.watch = watch
+	s.last = fmt.Sprintf("watching %s %s %s", backend, target.model, target.url)
+	time.AfterFunc(window, func() { i.commitSwitch(watch) })
+	return watch
+}
+
+// commitSwitch ends the watch of a switch that did not regress
+func (i *InferenceService) commitSwitch(watch *switchWatch) {
+	s := i.switches
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watch != watch {
+		return // rolled back
+	}
+	s.watch = nil
+	s.last = fmt.Sprintf("committed %s %s %s", watch.backend, watch.target.model, watch.target.url)
+	monitoring.RecordModelSwitch(watch.backend, "committed")
+	logger.GetLogger().Infof("Committed the switch of %s to %s %s", watch.backend, watch.target.model, watch.target.url)
+}
+
+// observeGeneration counts a generation of the backend's active target,
+// rolling a watched switch back once its error rate exceeds the previous
+// target's by more than inference.model_switch.max_error_rate_increase.
+// Generations cancelled by the caller are not counted.
+func (i *InferenceService) observeGeneration(backend string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	cfg := i.config.Inference.ModelSwitch
+	s := i.switches
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.outcomes[backend]
+	current.requests++
+	if err != nil {
+		current.errors++
+	}
+
+	watch := s.watch
+	if watch == nil || watch.backend != backend || current.requests < cfg.MinRequests {
+		return
+	}
+	if rate := current.errorRate(); rate > watch.baseline+cfg.MaxErrorRateIncrease {
+		i.setModelTarget(backend, watch.previous)
+		s.outcomes[backend] = &outcomes{}
+		s.watch = nil
+		s.last = fmt.Sprintf("rolled back %s to %s %s", backend, watch.previous.model, watch.previous.url)
+		monitoring.RecordModelSwitch(backend, "rolled_back")
+		logger.GetLogger().Warnf("Rolled %s back to %s %s: error rate %.2f after the switch to %s %s, %.2f before",
+			backend, watch.previous.model, watch.previous.url, rate, watch.target.model, watch.target.url, watch.baseline)
+	}
+}
+
+// begin claims the single switch slot
+func (s *modelSwitches) begin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.switching:
+		return errors.New("another model switch is in progress")
+	case s.watch != nil:
+		return fmt.Errorf("the switch of %s is watched for regressions until %s", s.watch.backend, s.watch.until.Format(time.RFC3339))
+	}
+	s.switching = true
+	return nil
+}
+
+func (s *modelSwitches) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.switching = false
+}
+
+// addDetails adds the latest switch to HealthCheck details
+func (s *modelSwitches) addDetails(details map[string]string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last != "" {
+		details["model_switch"] = strings.TrimSpace(s.last)
+	}
+	return details
+}
@@ -0,0 +1,193 @@
+//go:build onnx && cgo
+
+package inference
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+
+	"ai-search-service/internal/config"
+)
+
+// Inputs and output of an ONNX export with the BeamSearch operator
+var (
+	onnxInputNames = []string{
+		"input_ids", "max_length", "min_length", "num_beams",
+		"num_return_sequences", "length_penalty", "repetition_penalty",
+	}
+	onnxOutputNames = []string{"sequences"}
+)
+
+// ortEnvironment initializes ONNX Runtime once per process
+var ortEnvironment struct {
+	once sync.Once
+	err  error
+}
+
+// ONNXEngine generates summaries in process with ONNX Runtime, from a small
+// model exported with its generation loop, so a deployment needs no
+// inference server. Prompts go in as token IDs and summaries come back as
+// token IDs, as with Triton.
+type ONNXEngine struct {
+	cfg     config.ONNXConfig
+	session *ort.DynamicAdvancedSession
+	slots   chan struct{} // bounds concurrent generations
+}
+
+// NewONNXEngine loads the ONNX Runtime library and the model
+func NewONNXEngine(cfg *config.Config) (*ONNXEngine, error) {
+	ocfg := cfg.Inference.ONNX
+	ortEnvironment.once.Do(func() {
+		if ocfg.LibraryPath != "" {
+			ort.SetSharedLibraryPath(ocfg.LibraryPath)
+		}
+		ortEnvironment.err = ort.InitializeEnvironment()
+	})
+	if ortEnvironment.err != nil {
+		return nil, fmt.Errorf("failed to initialize ONNX Runtime: %w", ortEnvironment.err)
+	}
+
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ONNX session options: %w", err)
+	}
+	defer options.Destroy()
+	if ocfg.IntraOpThreads > 0 {
+		if err := options.SetIntraOpNumThreads(ocfg.IntraOpThreads); err != nil {
+			return nil, fmt.Errorf("failed to set ONNX intra-op threads: %w", err)
+		}
+	}
+	session, err := ort.NewDynamicAdvancedSession(ocfg.ModelPath, onnxInputNames, onnxOutputNames, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ONNX model %s: %w", ocfg.ModelPath, err)
+	}
+
+	concurrency := ocfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &ONNXEngine{cfg: ocfg, session: session, slots: make(chan struct{}, concurrency)}, nil
+}
+
+// Name identifies the engine in logs and metrics
+func (e *ONNXEngine) Name() string {
+	return "onnx"
+}
+
+// Model returns the name summaries are reported as generated with
+func (e *ONNXEngine) Model() string {
+	return e.cfg.Model
+}
+
+// Generate runs the model on the prompt's token IDs and returns the
+// summary's token IDs. A generation waits for a free slot, and is
+// terminated when ctx ends.
+func (e *ONNXEngine) Generate(ctx context.Context, tokenIds []int32, maxLength int) ([]int32, error) {
+	select {
+	case e.slots <- struct{}{}:
+		defer func() { <-e.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if maxLength <= 0 {
+		maxLength = 150
+	}
+
+	inputs, err := e.inputs(tokenIds, maxLength)
+	defer destroyValues(inputs)
+	if err != nil {
+		return nil, err
+	}
+	runOptions, err := ort.NewRunOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ONNX run options: %w", err)
+	}
+	defer runOptions.Destroy()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			runOptions.Terminate()
+		case <-done:
+		}
+	}()
+
+	outputs := make([]ort.Value, len(onnxOutputNames)) // allocated by the run
+	err = e.session.RunWithOptions(inputs, outputs, runOptions)
+	defer destroyValues(outputs)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ONNX generation failed: %w", err)
+	}
+
+	sequences, ok := outputs[0].(*ort.Tensor[int32])
+	if !ok {
+		return nil, fmt.Errorf("ONNX model returned sequences of type %T, want int32", outputs[0])
+	}
+	data := sequences.GetData()
+	output := make([]int64, len(data))
+	for n, id := range data {
+		output[n] = int64(id)
+	}
+	tokens := specialTokens{e.cfg.BOSTokenID, e.cfg.PadTokenID, e.cfg.EOSTokenID}
+	return tokens.generated(output, sequences.GetShape()), nil
+}
+
+// Health reports whether the model is loaded; it is for the engine's
+// lifetime once created
+func (e *ONNXEngine) Health(ctx context.Context) error {
+	if e.session == nil {
+		return fmt.Errorf("ONNX model %s is not loaded", e.cfg.ModelPath)
+	}
+	return nil
+}
+
+// inputs returns the BeamSearch inputs for the prompt: its token IDs and
+// the search parameters, a single sequence returned
+func (e *ONNXEngine) inputs(tokenIds []int32, maxLength int) ([]ort.Value, error) {
+	numBeams := e.cfg.NumBeams
+	if numBeams <= 0 {
+		numBeams = 1
+	}
+	lengthPenalty := e.cfg.LengthPenalty
+	if lengthPenalty == 0 {
+		lengthPenalty = 1
+	}
+
+	var values []ort.Value
+	ids, err := ort.NewTensor(ort.NewShape(1, int64(len(tokenIds))), append([]int32(nil), tokenIds...))
+	if err != nil {
+		return values, fmt.Errorf("failed to create ONNX input_ids: %w", err)
+	}
+	values = append(values, ids)
+	for _, v := range []int32{int32(maxLength), e.cfg.MinLength, numBeams, 1} {
+		t, err := ort.NewTensor(ort.NewShape(1), []int32{v})
+		if err != nil {
+			return values, fmt.Errorf("failed to create ONNX input: %w", err)
+		}
+		values = append(values, t)
+	}
+	for _, v := range []float32{lengthPenalty, 1} {
+		t, err := ort.NewTensor(ort.NewShape(1), []float32{v})
+		if err != nil {
+			return values, fmt.Errorf("failed to create ONNX input: %w", err)
+		}
+		values = append(values, t)
+	}
+	return values, nil
+}
+
+func destroyValues(values []ort.Value) {
+	for _, v := range values {
+		if v != nil {
+			v.Destroy()
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !onnx || !cgo
+
+package inference
+
+import (
+	"context"
+	"errors"
+
+	"ai-search-service/internal/config"
+)
+
+// errONNXUnavailable is returned when the onnx-local engine is selected in
+// a binary built without ONNX Runtime
+var errONNXUnavailable = errors.New("inference engine onnx-local requires a build with ONNX Runtime: rebuild with CGO_ENABLED=1 and -tags onnx")
+
+// ONNXEngine generates summaries in process with ONNX Runtime; this build
+// does not include the runtime
+type ONNXEngine struct{}
+
+// NewONNXEngine reports that this build does not include ONNX Runtime
+func NewONNXEngine(cfg *config.Config) (*ONNXEngine, error) {
+	return nil, errONNXUnavailable
+}
+
+func (e *ONNXEngine) Name() string  { return "onnx" }
+func (e *ONNXEngine) Model() string { return "" }
+
+func (e *ONNXEngine) Generate(ctx context.Context, tokenIds []int32, maxLength int) ([]int32, error) {
+	return nil, errONNXUnavailable
+}
+
+func (e *ONNXEngine) Health(ctx context.Context) error {
+	return errONNXUnavailable
+}
@@ -0,0 +1,50 @@
+package inference
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+
+	"ai-search-service/internal/lru"
+)
+
+// prefixCache remembers which prompt prefixes were recently sent to a backend.
+// The backends own the actual KV cache (vLLM --enable-prefix-caching, Ollama's
+// per-model context reuse); this LRU only mirrors it so hit rates and the
+// resulting prompt-processing latency can be measured.
+type prefixCache struct {
+	entries *lru.Cache[uint64, struct{}]
+}
+
+func newPrefixCache(maxEntries int) *prefixCache {
+	if maxEntries <= 0 {
+		maxEntries = 1024
+	}
+	return &prefixCache{entries: lru.New[uint64, struct{}]("inference_prefix", maxEntries, 0)}
+}
+
+// observeTokens records a token prefix for a model and reports whether it was
+// already cached
+func (c *prefixCache) observeTokens(model string, prefix []int32) bool {
+	h := fnv.New64a()
+	h.Write([]byte(model))
+	buf := make([]byte, 4)
+	for _, id := range prefix {
+		binary.LittleEndian.PutUint32(buf, uint32(id))
+		h.Write(buf)
+	}
+	return c.observe(h.Sum64())
+}
+
+// observeText records a text prefix for a model and reports whether it was
+// already cached
+func (c *prefixCache) observeText(model, prefix string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(prefix))
+	return c.observe(h.Sum64())
+}
+
+func (c *prefixCache) observe(key uint64) bool {
+	return c.entries.ContainsOrAdd(key, struct{}{})
+}
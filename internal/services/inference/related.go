@@ -0,0 +1,83 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// relatedInstruction asks a generation model to reword related searches;
+// %q is the query and the numbered searches follow
+const relatedInstruction = `These related searches for the query %q were built from phrases in its search results. ` +
+	`Rewrite each as the short, natural query a person would type, keeping its meaning. ` +
+	`Reply with one query per line in the same order, without numbers or anything else.` + "\n\n"
+
+// listMarker is the number or bullet a reply line may start with
+var listMarker = regexp.MustCompile(`^(?:\d+[.)]|[-*•])\s*`)
+
+// PolishRelatedSearches rewords related searches derived from search results
+// as natural queries with the configured generation model. Searches the model
+// drops or garbles are returned unchanged, so the response always has one per
+// request search.
+func (i *InferenceService) PolishRelatedSearches(ctx context.Context, req *pb.PolishRelatedSearchesRequest) (*pb.PolishRelatedSearchesResponse, error) {
+	if len(req.Searches) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no related searches to polish")
+	}
+	cfg := i.config.Inference.Related
+	model := req.ModelName
+	if model == "" {
+		model = cfg.Model
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, relatedInstruction, req.Query)
+	for n, search := range req.Searches {
+		fmt.Fprintf(&prompt, "%d. %s\n", n+1, search)
+	}
+
+	var reply string
+	var err error
+	switch cfg.Backend {
+	case "vllm", "ollama", "":
+		reply, model, err = i.generateText(ctx, cfg.Backend, model, prompt.String(), cfg.MaxTokens, "")
+	default:
+		err = fmt.Errorf("unknown related searches backend %q", cfg.Backend)
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Polishing related searches for %q with %s failed: %v", req.Query, cfg.Backend, err)
+		monitoring.RecordRequest("inference", "polish_related_searches", "error")
+		return nil, status.Errorf(codes.Unavailable, "related searches polish failed: %v", err)
+	}
+	monitoring.RecordRequest("inference", "polish_related_searches", "success")
+	return &pb.PolishRelatedSearchesResponse{Searches: polishedSearches(reply, req.Searches), ModelName: model}, nil
+}
+
+// polishedSearches pairs the lines of a model reply with the searches they
+// rewrite, keeping a search when its line is missing, empty or overlong
+func polishedSearches(reply string, searches []string) []string {
+	var lines []string
+	for _, line := range strings.Split(reply, "\n") {
+		// Models number or bullet the lines despite being asked not to
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.Trim(line, "\"'` ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	polished := make([]string, len(searches))
+	for n, search := range searches {
+		polished[n] = search
+		if n < len(lines) && len(lines[n]) <= 2*len(search)+20 {
+			polished[n] = lines[n]
+		}
+	}
+	return polished
+}
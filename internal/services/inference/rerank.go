@@ -0,0 +1,159 @@
+package inference
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/upstream"
+	pb "ai-search-service/proto"
+)
+
+// rerankInstruction asks a generation model to grade search results; %q is
+// the query and the numbered results follow
+const rerankInstruction = `Grade how relevant each search result below is to the query %q, from 0 (unrelated) to 10 (answers it directly). ` +
+	`Reply with one grade per line in the same order, as "<number of the result>: <grade>", and nothing else.` + "\n\n"
+
+// gradeLine is a line of a model's grades, e.g. "3: 7" or "[3] 7/10"
+var gradeLine = regexp.MustCompile(`^\[?(\d+)(?:[\].:)]\s*|\s+)[:=-]?\s*(\d+(?:\.\d+)?)`)
+
+// Rerank scores how relevant each document is to the query, from 0 to 1,
+// with the configured cross-encoder or generation model. There is no
+// fallback: callers keep the documents in their order when it fails.
+func (i *InferenceService) Rerank(ctx context.Context, req *pb.RerankRequest) (*pb.RerankResponse, error) {
+	if len(req.Documents) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no documents to rerank")
+	}
+	cfg := i.config.Inference.Rerank
+	model := req.ModelName
+	if model == "" {
+		model = cfg.Model
+	}
+
+	var scores []float32
+	var err error
+	switch cfg.Backend {
+	case "cross_encoder", "":
+		scores, err = i.rerankWithCrossEncoder(ctx, model, req.Query, req.Documents)
+	case "vllm", "ollama":
+		scores, model, err = i.rerankWithLLM(ctx, cfg.Backend, model, req.Query, req.Documents)
+	default:
+		err = fmt.Errorf("unknown rerank backend %q", cfg.Backend)
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Reranking %d documents for %q with %s failed: %v", len(req.Documents), req.Query, cfg.Backend, err)
+		monitoring.RecordRequest("inference", "rerank", "error")
+		return nil, status.Errorf(codes.Unavailable, "rerank failed: %v", err)
+	}
+	monitoring.RecordRequest("inference", "rerank", "success")
+	return &pb.RerankResponse{Scores: scores, ModelName: model}, nil
+}
+
+// crossEncoderResponse is the reply of the rerank API shared by vLLM,
+// Infinity and Jina
+type crossEncoderResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// rerankWithCrossEncoder scores the query against each document with a
+// cross-encoder behind the rerank API at inference.rerank.url; an empty
+// model uses the one the server serves
+func (i *InferenceService) rerankWithCrossEncoder(ctx context.Context, model, query string, documents []string) ([]float32, error) {
+	payload := map[string]interface{}{"query": query, "documents": documents}
+	if model != "" {
+		payload["model"] = model
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, i.config.Inference.Rerank.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rerank request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Transport: upstream.WrapTransport(i.config, "rerank", nil)}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("rerank request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rerank model returned status %d", resp.StatusCode)
+	}
+	var ranked crossEncoderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ranked); err != nil {
+		return nil, fmt.Errorf("failed to decode rerank response: %w", err)
+	}
+
+	scores := make([]float32, len(documents))
+	seen := 0
+	for _, result := range ranked.Results {
+		if result.Index < 0 || result.Index >= len(documents) {
+			return nil, fmt.Errorf("rerank response has no document %d", result.Index)
+		}
+		scores[result.Index] = float32(clamp01(result.RelevanceScore))
+		seen++
+	}
+	if seen != len(documents) {
+		return nil, fmt.Errorf("rerank response scored %d of %d documents", seen, len(documents))
+	}
+	return scores, nil
+}
+
+// rerankWithLLM has the generation model of backend grade each document,
+// returning the scores and the model that graded them. Documents the model
+// skips score 0.
+func (i *InferenceService) rerankWithLLM(ctx context.Context, backend, model, query string, documents []string) ([]float32, string, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, rerankInstruction, query)
+	for n, document := range documents {
+		fmt.Fprintf(&prompt, "[%d] %s\n", n+1, document)
+	}
+	reply, model, err := i.generateText(ctx, backend, model, prompt.String(), i.config.Inference.Rerank.MaxTokens, "")
+	if err != nil {
+		return nil, model, err
+	}
+
+	scores := make([]float32, len(documents))
+	graded := 0
+	for _, line := range strings.Split(reply, "\n") {
+		match := gradeLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		grade, _ := strconv.ParseFloat(match[2], 64)
+		if n >= 1 && n <= len(documents) {
+			scores[n-1] = float32(clamp01(grade / 10))
+			graded++
+		}
+	}
+	if graded == 0 {
+		return nil, model, fmt.Errorf("model reply has no grades: %q", reply)
+	}
+	return scores, model, nil
+}
+
+func clamp01(v float64) float64 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 1:
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,27 @@
+package inference
+
+import (
+	"errors"
+	"io"
+
+	pb "ai-search-service/proto"
+)
+
+// SummarizeSession serves a summary stream opened ahead of its request. It
+// reports the session ready, then streams the summary of the one request
+// it is sent as SummarizeStream would, so a request claiming a ready
+// session does not wait for its stream to be set up. A session closed
+// before its request ends quietly.
+func (i *InferenceService) SummarizeSession(stream pb.InferenceService_SummarizeSessionServer) error {
+	if err := stream.Send(&pb.SummarizeStreamResponse{SessionReady: true}); err != nil {
+		return err
+	}
+	req, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return i.SummarizeStream(req, stream)
+}
@@ -0,0 +1,62 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+
+	"ai-search-service/internal/config"
+)
+
+// tokenEngine generates a summary's token IDs from the prompt's, for the
+// engines that replace vLLM for token-prompted summaries; the orchestrator
+// detokenizes what they generate
+type tokenEngine interface {
+	Name() string  // for logs and metrics: triton, onnx
+	Model() string // the model summaries are generated with
+	Generate(ctx context.Context, tokenIds []int32, maxLength int) ([]int32, error)
+	Health(ctx context.Context) error // whether the model is loaded and ready
+}
+
+// newTokenEngine creates the engine inference.backend.engine selects; nil
+// for vllm and llamacpp, which generate text
+func newTokenEngine(cfg *config.Config) (tokenEngine, error) {
+	switch engine := cfg.Inference.Backend.Engine; engine {
+	case "vllm", "llamacpp", "":
+		return nil, nil
+	case "triton":
+		return NewTritonEngine(cfg)
+	case "onnx-local":
+		return NewONNXEngine(cfg)
+	default:
+		return nil, fmt.Errorf("unknown inference engine %q", engine)
+	}
+}
+
+// specialTokens are the IDs framing a generated sequence, as BART frames
+// it: the decoder start (EOS) and BOS tokens first, then EOS and padding
+type specialTokens struct {
+	bos, pad, eos int32
+}
+
+// generated maps an output tensor of generated sequences to the summary's
+// token IDs: its first sequence (of the batch and of the beams), without
+// the decoder start, BOS and pad tokens, up to EOS
+func (s specialTokens) generated(output, shape []int64) []int32 {
+	if len(shape) > 1 && shape[len(shape)-1] > 0 && int64(len(output)) > shape[len(shape)-1] {
+		output = output[:shape[len(shape)-1]]
+	}
+	ids := make([]int32, 0, len(output))
+	for n, v := range output {
+		id := int32(v)
+		switch {
+		case id == s.eos && n == 0: // BART's decoder start token
+			continue
+		case id == s.eos:
+			return ids
+		case id == s.bos || id == s.pad:
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,284 @@
+package inference
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+	pb "ai-search-service/proto"
+)
+
+// Methods of Triton's GRPCInferenceService
+const (
+	tritonModelReadyMethod = "/inference.GRPCInferenceService/ModelReady"
+	tritonModelInferMethod = "/inference.GRPCInferenceService/ModelInfer"
+)
+
+// TritonEngine generates summaries with a BART deployment on Triton
+// Inference Server over the KServe v2 protocol, HTTP or gRPC. Prompts go in
+// as token IDs and summaries come back as token IDs, which the orchestrator
+// detokenizes, so the model runs on Triton's GPU scheduling without vLLM.
+type TritonEngine struct {
+	cfg        config.TritonConfig
+	httpClient *http.Client
+	conn       *grpc.ClientConn // nil with the http transport
+}
+
+// tritonTensor is an input or output tensor of the HTTP protocol
+type tritonTensor struct {
+	Name     string  `json:"name"`
+	Shape    []int64 `json:"shape"`
+	Datatype string  `json:"datatype"`
+	Data     []int64 `json:"data"`
+}
+
+// tritonInferRequest is the body of POST /v2/models/{model}/infer
+type tritonInferRequest struct {
+	ID      string         `json:"id,omitempty"`
+	Inputs  []tritonTensor `json:"inputs"`
+	Outputs []struct {
+		Name string `json:"name"`
+	} `json:"outputs"`
+}
+
+// tritonInferResponse is the body returned by /v2/models/{model}/infer
+type tritonInferResponse struct {
+	ModelName string         `json:"model_name"`
+	Outputs   []tritonTensor `json:"outputs"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// NewTritonEngine creates a Triton engine from configuration, dialing its
+// gRPC endpoint with the grpc transport
+func NewTritonEngine(cfg *config.Config) (*TritonEngine, error) {
+	tcfg := cfg.Inference.Triton
+	if tcfg.Datatype != "INT64" && tcfg.Datatype != "INT32" {
+		return nil, fmt.Errorf("unsupported Triton datatype %q", tcfg.Datatype)
+	}
+	e := &TritonEngine{
+		cfg: tcfg,
+		httpClient: &http.Client{
+			Timeout:   tcfg.Timeout,
+			Transport: upstream.WrapTransport(cfg, "triton", nil),
+		},
+	}
+	switch transport := cfg.Inference.Backend.Transport; transport {
+	case "http", "":
+	case "grpc":
+		creds := insecure.NewCredentials()
+		if cfg.Inference.Backend.TLS {
+			creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		}
+		conn, err := grpc.Dial(tcfg.GRPCAddress, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial Triton at %s: %w", tcfg.GRPCAddress, err)
+		}
+		e.conn = conn
+	default:
+		return nil, fmt.Errorf("unknown Triton transport %q", transport)
+	}
+	return e, nil
+}
+
+// Name identifies the engine in logs and metrics
+func (e *TritonEngine) Name() string {
+	return "triton"
+}
+
+// Model returns the Triton model summaries are generated with
+func (e *TritonEngine) Model() string {
+	return e.cfg.Model
+}
+
+// Generate runs the model on the prompt's token IDs and returns the
+// summary's token IDs
+func (e *TritonEngine) Generate(ctx context.Context, tokenIds []int32, maxLength int) ([]int32, error) {
+	if e.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.cfg.Timeout)
+		defer cancel()
+	}
+	var output []int64
+	var shape []int64
+	var err error
+	if e.conn != nil {
+		output, shape, err = e.inferGRPC(ctx, tokenIds, maxLength)
+	} else {
+		output, shape, err = e.inferHTTP(ctx, tokenIds, maxLength)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return specialTokens{e.cfg.BOSTokenID, e.cfg.PadTokenID, e.cfg.EOSTokenID}.generated(output, shape), nil
+}
+
+// Health reports whether the model is loaded and ready
+func (e *TritonEngine) Health(ctx context.Context) error {
+	if e.conn != nil {
+		resp := new(pb.TritonModelReadyResponse)
+		err := e.conn.Invoke(ctx, tritonModelReadyMethod, &pb.TritonModelReadyRequest{Name: e.cfg.Model, Version: e.cfg.ModelVersion}, resp)
+		if err != nil {
+			return fmt.Errorf("Triton unreachable: %w", err)
+		}
+		if !resp.Ready {
+			return fmt.Errorf("Triton model %s is not ready", e.cfg.Model)
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.modelURL()+"/ready", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Triton unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Triton model %s is not ready: status %d", e.cfg.Model, resp.StatusCode)
+	}
+	return nil
+}
+
+// inputs returns the request's input tensors: the prompt's token IDs, and
+// its attention mask and the summary length when the model takes them
+func (e *TritonEngine) inputs(tokenIds []int32, maxLength int) []tritonTensor {
+	ids := make([]int64, len(tokenIds))
+	mask := make([]int64, len(tokenIds))
+	for n, id := range tokenIds {
+		ids[n], mask[n] = int64(id), 1
+	}
+	shape := []int64{1, int64(len(tokenIds))}
+	inputs := []tritonTensor{{Name: e.cfg.InputName, Shape: shape, Datatype: e.cfg.Datatype, Data: ids}}
+	if e.cfg.MaskName != "" {
+		inputs = append(inputs, tritonTensor{Name: e.cfg.MaskName, Shape: shape, Datatype: e.cfg.Datatype, Data: mask})
+	}
+	if e.cfg.MaxLengthName != "" {
+		if maxLength <= 0 {
+			maxLength = 150
+		}
+		inputs = append(inputs, tritonTensor{Name: e.cfg.MaxLengthName, Shape: []int64{1, 1}, Datatype: e.cfg.Datatype, Data: []int64{int64(maxLength)}})
+	}
+	return inputs
+}
+
+func (e *TritonEngine) inferHTTP(ctx context.Context, tokenIds []int32, maxLength int) ([]int64, []int64, error) {
+	body := tritonInferRequest{ID: fmt.Sprintf("inf_%d", time.Now().UnixNano()), Inputs: e.inputs(tokenIds, maxLength)}
+	body.Outputs = append(body.Outputs, struct {
+		Name string `json:"name"`
+	}{Name: e.cfg.OutputName})
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode Triton request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.modelURL()+"/infer", bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Triton request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, nil, fmt.Errorf("Triton returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var out tritonInferResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode Triton response: %w", err)
+	}
+	for _, output := range out.Outputs {
+		if output.Name == e.cfg.OutputName {
+			return output.Data, output.Shape, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("Triton returned no %s output", e.cfg.OutputName)
+}
+
+func (e *TritonEngine) inferGRPC(ctx context.Context, tokenIds []int32, maxLength int) ([]int64, []int64, error) {
+	req := &pb.TritonInferRequest{
+		ModelName:    e.cfg.Model,
+		ModelVersion: e.cfg.ModelVersion,
+		Id:           fmt.Sprintf("inf_%d", time.Now().UnixNano()),
+		Outputs:      []*pb.TritonRequestedOutput{{Name: e.cfg.OutputName}},
+	}
+	for _, input := range e.inputs(tokenIds, maxLength) {
+		tensor := &pb.TritonInferTensor{Name: input.Name, Datatype: input.Datatype, Shape: input.Shape, Contents: &pb.TritonTensorContents{}}
+		if input.Datatype == "INT32" {
+			for _, v := range input.Data {
+				tensor.Contents.IntContents = append(tensor.Contents.IntContents, int32(v))
+			}
+		} else {
+			tensor.Contents.Int64Contents = input.Data
+		}
+		req.Inputs = append(req.Inputs, tensor)
+	}
+
+	resp := new(pb.TritonInferResponse)
+	if err := e.conn.Invoke(ctx, tritonModelInferMethod, req, resp); err != nil {
+		return nil, nil, fmt.Errorf("Triton request failed: %w", err)
+	}
+	for n, output := range resp.Outputs {
+		if output.Name != e.cfg.OutputName {
+			continue
+		}
+		if n < len(resp.RawOutputContents) {
+			data, err := decodeRawTensor(resp.RawOutputContents[n], output.Datatype)
+			return data, output.Shape, err
+		}
+		if contents := output.Contents; contents != nil {
+			data := contents.Int64Contents
+			for _, v := range contents.IntContents {
+				data = append(data, int64(v))
+			}
+			return data, output.Shape, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("Triton returned no %s output", e.cfg.OutputName)
+}
+
+// decodeRawTensor decodes the little-endian raw contents of an integer tensor
+func decodeRawTensor(raw []byte, datatype string) ([]int64, error) {
+	switch datatype {
+	case "INT64":
+		data := make([]int64, len(raw)/8)
+		for n := range data {
+			data[n] = int64(binary.LittleEndian.Uint64(raw[n*8:]))
+		}
+		return data, nil
+	case "INT32":
+		data := make([]int64, len(raw)/4)
+		for n := range data {
+			data[n] = int64(int32(binary.LittleEndian.Uint32(raw[n*4:])))
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported Triton output datatype %q", datatype)
+	}
+}
+
+func (e *TritonEngine) modelURL() string {
+	url := strings.TrimSuffix(e.cfg.URL, "/") + "/v2/models/" + e.cfg.Model
+	if e.cfg.ModelVersion != "" {
+		url += "/versions/" + e.cfg.ModelVersion
+	}
+	return url
+}
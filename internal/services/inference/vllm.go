@@ -0,0 +1,377 @@
+package inference
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+	pb "ai-search-service/proto"
+)
+
+// VLLMEngine talks to vLLM's OpenAI-compatible completions API, or to its
+// gRPC frontend with inference.backend.transport set to grpc, using token
+// IDs as the prompt, so no text round-trip happens between tokenizer and model
+type VLLMEngine struct {
+	baseURL      string // the HTTP API, or the gRPC frontend's address
+	defaultModel string
+	modelMutex   sync.RWMutex // guards baseURL and defaultModel
+	httpClient   *http.Client
+	grpc         *vllmGRPC // nil with the http transport
+}
+
+// vllmCompletionRequest is the body of POST /v1/completions
+type vllmCompletionRequest struct {
+	Model     string      `json:"model"`
+	Prompt    interface{} `json:"prompt"` // token IDs (vLLM skips tokenization) or plain text
+	MaxTokens int         `json:"max_tokens"`
+	Stream    bool        `json:"stream"`
+	Logprobs  int         `json:"logprobs,omitempty"` // report the log probability of each generated token
+
+	// Sampling parameters; unset ones keep vLLM's defaults
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+}
+
+// vllmCompletionResponse is a full or streamed chunk from /v1/completions
+type vllmCompletionResponse struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Text         string        `json:"text"`
+		FinishReason *string       `json:"finish_reason"`
+		Logprobs     *vllmLogprobs `json:"logprobs"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
+}
+
+// vllmLogprobs holds the log probability of each token of a choice; the
+// first may be null
+type vllmLogprobs struct {
+	TokenLogprobs []*float64 `json:"token_logprobs"`
+}
+
+// tokenProbability accumulates token log probabilities across a completion
+type tokenProbability struct {
+	sum    float64
+	tokens int
+}
+
+func (p *tokenProbability) add(logprobs *vllmLogprobs) {
+	if logprobs == nil {
+		return
+	}
+	for _, lp := range logprobs.TokenLogprobs {
+		if lp != nil {
+			p.addOne(*lp)
+		}
+	}
+}
+
+// addAll accumulates the log probabilities of a gRPC response
+func (p *tokenProbability) addAll(logprobs []float32) {
+	for _, lp := range logprobs {
+		p.addOne(float64(lp))
+	}
+}
+
+func (p *tokenProbability) addOne(lp float64) {
+	if !math.IsNaN(lp) && !math.IsInf(lp, 0) {
+		p.sum += lp
+		p.tokens++
+	}
+}
+
+// mean returns the geometric mean probability of the generated tokens, how
+// sure the model was of its output on average, or 0 when vLLM reported none
+func (p *tokenProbability) mean() float32 {
+	if p.tokens == 0 {
+		return 0
+	}
+	return float32(math.Exp(p.sum / float64(p.tokens)))
+}
+
+// vllmEmbeddingRequest is the body of POST /v1/embeddings
+type vllmEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// vllmEmbeddingResponse is the body returned by /v1/embeddings
+type vllmEmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// NewVLLMEngine creates a vLLM engine from configuration
+func NewVLLMEngine(cfg *config.Config) (*VLLMEngine, error) {
+	e := &VLLMEngine{
+		baseURL:      cfg.GetVLLMURL(),
+		defaultModel: cfg.VLLM.Model,
+		httpClient: &http.Client{
+			Timeout:   cfg.VLLM.Timeout,
+			Transport: upstream.WrapTransport(cfg, "vllm", nil),
+		},
+	}
+	switch transport := cfg.Inference.Backend.Transport; transport {
+	case "http", "":
+	case "grpc":
+		e.baseURL = cfg.GetVLLMGRPCAddress()
+		e.grpc = newVLLMGRPC(cfg)
+	default:
+		return nil, fmt.Errorf("unknown vLLM transport %q", transport)
+	}
+	return e, nil
+}
+
+// GenerateFromTokens generates a completion for the given prompt token IDs,
+// with the request's sampling parameters (nil for vLLM's defaults).
+// probability is the mean probability of the generated tokens, 0 when vLLM
+// did not report log probabilities.
+func (e *VLLMEngine) GenerateFromTokens(ctx context.Context, tokenIds []int32, modelName string, maxLength int, sampling *pb.SamplingParams) (text string, probability float32, err error) {
+	if e.grpc != nil {
+		return e.grpc.generate(ctx, e.BaseURL(), e.newRequest(tokenIds, modelName, maxLength, false, sampling))
+	}
+	resp, err := e.post(ctx, e.newRequest(tokenIds, modelName, maxLength, false, sampling))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	var out vllmCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", 0, fmt.Errorf("failed to decode vLLM response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", 0, fmt.Errorf("vLLM returned no choices")
+	}
+	var p tokenProbability
+	p.add(out.Choices[0].Logprobs)
+	return strings.TrimSpace(out.Choices[0].Text), p.mean(), nil
+}
+
+// GenerateFromPrompt generates a completion for a plain-text prompt; used for
+// warm-up, smoke tests and entity extraction, where no tokenizer is involved
+func (e *VLLMEngine) GenerateFromPrompt(ctx context.Context, prompt string, modelName string, maxLength int) (string, error) {
+	body := e.newRequest(nil, modelName, maxLength, false, nil)
+	body.Prompt = prompt
+	if e.grpc != nil {
+		text, _, err := e.grpc.generate(ctx, e.BaseURL(), body)
+		return text, err
+	}
+
+	resp, err := e.post(ctx, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var out vllmCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode vLLM response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("vLLM returned no choices")
+	}
+	return strings.TrimSpace(out.Choices[0].Text), nil
+}
+
+// Embed returns an embedding for each text from a vLLM embedding model, over
+// the HTTP API whatever the transport
+func (e *VLLMEngine) Embed(ctx context.Context, texts []string, modelName string) ([][]float32, error) {
+	resp, err := e.postJSON(ctx, e.apiURL()+"/v1/embeddings", &vllmEmbeddingRequest{Model: modelName, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out vllmEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode vLLM embedding response: %w", err)
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("vLLM returned %d embeddings for %d inputs", len(out.Data), len(texts))
+	}
+	embeddings := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("vLLM returned embedding index %d for %d inputs", d.Index, len(texts))
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return embeddings, nil
+}
+
+// Model returns the model used when a request does not name one
+func (e *VLLMEngine) Model() string {
+	e.modelMutex.RLock()
+	defer e.modelMutex.RUnlock()
+	return e.defaultModel
+}
+
+// SetModel changes the model used when a request does not name one
+func (e *VLLMEngine) SetModel(model string) {
+	e.modelMutex.Lock()
+	defer e.modelMutex.Unlock()
+	e.defaultModel = model
+}
+
+// BaseURL returns the vLLM server generations are sent to: the URL of its
+// HTTP API, or the address of its gRPC frontend
+func (e *VLLMEngine) BaseURL() string {
+	e.modelMutex.RLock()
+	defer e.modelMutex.RUnlock()
+	return e.baseURL
+}
+
+// SetTarget points requests at another vLLM server and default model
+func (e *VLLMEngine) SetTarget(baseURL, model string) {
+	e.modelMutex.Lock()
+	defer e.modelMutex.Unlock()
+	e.baseURL, e.defaultModel = baseURL, model
+}
+
+// withTarget returns an engine for another server and default model,
+// sharing this one's clients
+func (e *VLLMEngine) withTarget(baseURL, model string) *VLLMEngine {
+	return &VLLMEngine{baseURL: baseURL, defaultModel: model, httpClient: e.httpClient, grpc: e.grpc}
+}
+
+// apiURL returns the HTTP API for the calls with no gRPC equivalent
+func (e *VLLMEngine) apiURL() string {
+	if e.grpc != nil {
+		return e.grpc.httpURL
+	}
+	return e.BaseURL()
+}
+
+// StreamFromTokens streams a completion, invoking callback for each text chunk.
+// The callback receives isFinished=true exactly once, when generation stops,
+// along with the mean probability of the generated tokens (0 when unknown).
+func (e *VLLMEngine) StreamFromTokens(ctx context.Context, tokenIds []int32, modelName string, maxLength int, sampling *pb.SamplingParams, callback func(content string, isFinished bool, probability float32)) error {
+	if e.grpc != nil {
+		return e.grpc.stream(ctx, e.BaseURL(), e.newRequest(tokenIds, modelName, maxLength, true, sampling), callback)
+	}
+	resp, err := e.post(ctx, e.newRequest(tokenIds, modelName, maxLength, true, sampling))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var p tokenProbability
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			callback("", true, p.mean())
+			return nil
+		}
+
+		var chunk vllmCompletionResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode vLLM stream chunk: %w", err)
+		}
+		for _, choice := range chunk.Choices {
+			p.add(choice.Logprobs)
+			if choice.Text != "" {
+				callback(choice.Text, false, 0)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("vLLM stream read failed: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// Health checks the vLLM server health endpoint
+func (e *VLLMEngine) Health(ctx context.Context) error {
+	if e.grpc != nil {
+		return e.grpc.health(ctx, e.BaseURL())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.BaseURL()+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("vLLM unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vLLM health returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *VLLMEngine) newRequest(tokenIds []int32, modelName string, maxLength int, stream bool, sampling *pb.SamplingParams) *vllmCompletionRequest {
+	if modelName == "" {
+		modelName = e.Model()
+	}
+	if maxLength <= 0 {
+		maxLength = 150
+	}
+	req := &vllmCompletionRequest{
+		Model:     modelName,
+		Prompt:    tokenIds,
+		MaxTokens: maxLength,
+		Stream:    stream,
+		Logprobs:  1,
+	}
+	if sampling != nil {
+		req.Temperature, req.TopP = sampling.Temperature, sampling.TopP
+		req.FrequencyPenalty, req.PresencePenalty = sampling.FrequencyPenalty, sampling.PresencePenalty
+		req.Stop, req.Seed = sampling.Stop, sampling.Seed
+	}
+	return req
+}
+
+func (e *VLLMEngine) post(ctx context.Context, body *vllmCompletionRequest) (*http.Response, error) {
+	return e.postJSON(ctx, e.BaseURL()+"/v1/completions", body)
+}
+
+func (e *VLLMEngine) postJSON(ctx context.Context, url string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vLLM request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vLLM request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("vLLM returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,172 @@
+package inference
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"ai-search-service/internal/config"
+	pb "ai-search-service/proto"
+)
+
+// vllmGRPC sends generations to the VLLMGenerationService of a vLLM or
+// Triton gRPC frontend. A connection is dialed for each address the engine
+// targets, since a model switch may move it to another server.
+type vllmGRPC struct {
+	dialOpts []grpc.DialOption
+	timeout  time.Duration // of each generation, as vllm.timeout bounds HTTP ones
+	httpURL  string        // vLLM's HTTP API, for the calls with no gRPC equivalent
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func newVLLMGRPC(cfg *config.Config) *vllmGRPC {
+	creds := insecure.NewCredentials()
+	if cfg.Inference.Backend.TLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	return &vllmGRPC{
+		dialOpts: []grpc.DialOption{grpc.WithTransportCredentials(creds)},
+		timeout:  cfg.VLLM.Timeout,
+		httpURL:  cfg.GetVLLMURL(),
+		conns:    make(map[string]*grpc.ClientConn),
+	}
+}
+
+// conn returns the connection to address, dialing it on first use
+func (g *vllmGRPC) conn(address string) (*grpc.ClientConn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if conn, ok := g.conns[address]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(address, g.dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial vLLM at %s: %w", address, err)
+	}
+	g.conns[address] = conn
+	return conn, nil
+}
+
+func (g *vllmGRPC) client(address string) (pb.VLLMGenerationServiceClient, error) {
+	conn, err := g.conn(address)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewVLLMGenerationServiceClient(conn), nil
+}
+
+func (g *vllmGRPC) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, g.timeout)
+}
+
+// generate runs a completion, returning its text and the mean probability
+// of its tokens
+func (g *vllmGRPC) generate(ctx context.Context, address string, body *vllmCompletionRequest) (string, float32, error) {
+	client, err := g.client(address)
+	if err != nil {
+		return "", 0, err
+	}
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := client.Generate(ctx, newVLLMGenerateRequest(body))
+	if err != nil {
+		return "", 0, fmt.Errorf("vLLM request failed: %w", err)
+	}
+	var p tokenProbability
+	p.addAll(resp.TokenLogprobs)
+	return strings.TrimSpace(resp.Text), p.mean(), nil
+}
+
+// stream runs a streamed completion with the callback contract of
+// VLLMEngine.StreamFromTokens. A stream that ends before a message with a
+// finish reason broke off, and fails with io.ErrUnexpectedEOF.
+func (g *vllmGRPC) stream(ctx context.Context, address string, body *vllmCompletionRequest, callback func(content string, isFinished bool, probability float32)) error {
+	client, err := g.client(address)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	stream, err := client.GenerateStream(ctx, newVLLMGenerateRequest(body))
+	if err != nil {
+		return fmt.Errorf("vLLM request failed: %w", err)
+	}
+	var p tokenProbability
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("vLLM stream read failed: %w", err)
+		}
+		p.addAll(chunk.TokenLogprobs)
+		if chunk.Text != "" {
+			callback(chunk.Text, false, 0)
+		}
+		if chunk.FinishReason != "" {
+			callback("", true, p.mean())
+			return nil
+		}
+	}
+}
+
+// health checks the frontend with the standard gRPC health service
+func (g *vllmGRPC) health(ctx context.Context, address string) error {
+	conn, err := g.conn(address)
+	if err != nil {
+		return err
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return fmt.Errorf("vLLM unreachable: %w", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("vLLM health returned %s", resp.Status)
+	}
+	return nil
+}
+
+// newVLLMGenerateRequest converts a completion request to its gRPC form
+func newVLLMGenerateRequest(body *vllmCompletionRequest) *pb.VLLMGenerateRequest {
+	req := &pb.VLLMGenerateRequest{
+		RequestId: fmt.Sprintf("inf_%d", time.Now().UnixNano()),
+		Model:     body.Model,
+		MaxTokens: int32(body.MaxTokens),
+		Logprobs:  int32(body.Logprobs),
+	}
+	switch prompt := body.Prompt.(type) {
+	case []int32:
+		req.TokenIds = prompt
+	case string:
+		req.Prompt = prompt
+	}
+	if body.Temperature != nil || body.TopP != nil || body.FrequencyPenalty != nil || body.PresencePenalty != nil || len(body.Stop) > 0 || body.Seed != nil {
+		req.Sampling = &pb.SamplingParams{
+			Temperature:      body.Temperature,
+			TopP:             body.TopP,
+			FrequencyPenalty: body.FrequencyPenalty,
+			PresencePenalty:  body.PresencePenalty,
+			Stop:             body.Stop,
+			Seed:             body.Seed,
+		}
+	}
+	return req
+}
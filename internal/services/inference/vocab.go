@@ -0,0 +1,50 @@
+package inference
+
+import (
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTokenizerPins maps each pinned model to its tokenizer version
+func newTokenizerPins(pins []config.TokenizerPinConfig) map[string]string {
+	versions := make(map[string]string, len(pins))
+	for _, pin := range pins {
+		versions[pin.Model] = pin.Version
+	}
+	return versions
+}
+
+// checkTokenizerVersion refuses the token IDs of req when they come from a
+// tokenizer other than the one their model is pinned to: with another
+// vocabulary they stand for other words and the summary would be garbage.
+// Token IDs without a version are refused for a pinned model too, since
+// nothing shows they match it. Text requests and models without a pin pass.
+func (i *InferenceService) checkTokenizerVersion(req *pb.SummarizeRequest) error {
+	if len(req.TokenIds) == 0 {
+		return nil
+	}
+	model := req.ModelName
+	if i.tokenEngine != nil {
+		model = i.tokenEngine.Model()
+	} else if model == "" {
+		model = i.vllmEngine.Model()
+	}
+	pinned, ok := i.tokenizerPins[model]
+	if !ok || pinned == req.TokenizerVersion {
+		return nil
+	}
+	monitoring.RecordTokenizerMismatch(model)
+	if req.TokenizerVersion == "" {
+		logger.GetLogger().Errorf("Refusing tokens of request %s: no tokenizer version, model %s is pinned to %s",
+			req.RequestId, model, pinned)
+		return status.Errorf(codes.FailedPrecondition, "token IDs have no tokenizer version, model %s is pinned to %s", model, pinned)
+	}
+	logger.GetLogger().Errorf("Refusing tokens of request %s: tokenizer version %s, model %s is pinned to %s",
+		req.RequestId, req.TokenizerVersion, model, pinned)
+	return status.Errorf(codes.FailedPrecondition, "token IDs are from tokenizer version %s, model %s is pinned to %s", req.TokenizerVersion, model, pinned)
+}
@@ -0,0 +1,179 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+)
+
+// Warm-up states reported through HealthCheck details
+const (
+	WarmupPending  = "pending"
+	WarmupRunning  = "running"
+	WarmupReady    = "ready"
+	WarmupFailed   = "failed"
+	WarmupDisabled = "disabled"
+)
+
+// warmupState tracks the most recent warm-up run
+type warmupState struct {
+	mu          sync.RWMutex
+	status      string
+	generation  int // incremented per run so stale runs cannot overwrite newer results
+	warmed      []string
+	errors      []string
+	startedAt   time.Time
+	completedAt time.Time
+}
+
+// WarmupStatus is a snapshot of the warm-up state
+type WarmupStatus struct {
+	Status      string
+	Warmed      []string // backend/model pairs that answered the warm-up prompt
+	Errors      []string
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+// Ready reports whether warm-up no longer gates readiness
+func (w WarmupStatus) Ready() bool {
+	return w.Status == WarmupReady || w.Status == WarmupFailed || w.Status == WarmupDisabled
+}
+
+// Details renders the status as HealthCheck details
+func (w WarmupStatus) Details() map[string]string {
+	details := map[string]string{"warmup_status": w.Status}
+	if len(w.Warmed) > 0 {
+		details["warmup_models"] = strings.Join(w.Warmed, ",")
+	}
+	if len(w.Errors) > 0 {
+		details["warmup_errors"] = strings.Join(w.Errors, "; ")
+	}
+	if !w.CompletedAt.IsZero() {
+		details["warmup_duration_ms"] = fmt.Sprintf("%d", w.CompletedAt.Sub(w.StartedAt).Milliseconds())
+	}
+	return details
+}
+
+// StartWarmup runs the warm-up prompt against every backend in the background.
+// Readiness is gated until the run completes; it is called at startup. Model
+// switches warm their target before cutting over instead.
+func (i *InferenceService) StartWarmup() {
+	if !i.config.Inference.Warmup.Enabled {
+		i.warmup.mu.Lock()
+		i.warmup.status = WarmupDisabled
+		i.warmup.mu.Unlock()
+		return
+	}
+
+	i.warmup.mu.Lock()
+	i.warmup.generation++
+	generation := i.warmup.generation
+	i.warmup.status = WarmupRunning
+	i.warmup.warmed = nil
+	i.warmup.errors = nil
+	i.warmup.startedAt = time.Now()
+	i.warmup.completedAt = time.Time{}
+	i.warmup.mu.Unlock()
+
+	go i.runWarmup(generation)
+}
+
+// GetWarmupStatus returns a snapshot of the current warm-up state
+func (i *InferenceService) GetWarmupStatus() WarmupStatus {
+	i.warmup.mu.RLock()
+	defer i.warmup.mu.RUnlock()
+	return WarmupStatus{
+		Status:      i.warmup.status,
+		Warmed:      append([]string(nil), i.warmup.warmed...),
+		Errors:      append([]string(nil), i.warmup.errors...),
+		StartedAt:   i.warmup.startedAt,
+		CompletedAt: i.warmup.completedAt,
+	}
+}
+
+// runWarmup sends the warm-up prompt to both backends concurrently; a
+// Triton or ONNX Runtime engine is waited on until its model is ready instead
+func (i *InferenceService) runWarmup(generation int) {
+	log := logger.GetLogger()
+	cfg := i.config.Inference.Warmup
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	type result struct {
+		backend string
+		model   string
+		err     error
+		elapsed time.Duration
+	}
+	results := make(chan result, 2)
+
+	go func() {
+		start := time.Now()
+		if engine := i.tokenEngine; engine != nil {
+			// Token engines load their models up front; they are warm once the model is ready
+			model := engine.Model()
+			err := engine.Health(ctx)
+			results <- result{backend: engine.Name(), model: model, err: err, elapsed: time.Since(start)}
+			return
+		}
+		backend, model := "vllm", i.vllmEngine.Model()
+		if i.config.Inference.Backend.Engine == "llamacpp" {
+			backend = "llamacpp"
+		}
+		var err error
+		if i.llamaServer != nil {
+			// A llama-server started here has the model to load first
+			err = i.llamaServer.waitReady(ctx)
+		}
+		if err == nil {
+			_, err = i.vllmEngine.GenerateFromPrompt(ctx, cfg.Prompt, model, cfg.MaxTokens)
+		}
+		results <- result{backend: backend, model: model, err: err, elapsed: time.Since(start)}
+	}()
+	go func() {
+		start := time.Now()
+		model := i.ollamaClient.Model()
+		req := i.ollamaClient.NewRequest(cfg.Prompt, cfg.MaxTokens)
+		if i.config.Inference.PrefixCache.Enabled {
+			// Also primes the KV cache for the shared instruction prefix
+			req.System = i.summarizationInstruction()
+		}
+		_, err := i.ollamaClient.Generate(ctx, req)
+		results <- result{backend: "ollama", model: model, err: err, elapsed: time.Since(start)}
+	}()
+
+	var warmed, errs []string
+	for n := 0; n < 2; n++ {
+		r := <-results
+		monitoring.RecordModelWarmup("inference", r.backend, r.model, r.err == nil, r.elapsed)
+		if r.err != nil {
+			log.Warnf("Warm-up of %s model %s failed after %v: %v", r.backend, r.model, r.elapsed, r.err)
+			errs = append(errs, fmt.Sprintf("%s: %v", r.backend, r.err))
+			continue
+		}
+		log.Infof("Warm-up of %s model %s completed in %v", r.backend, r.model, r.elapsed)
+		warmed = append(warmed, r.backend+"/"+r.model)
+	}
+
+	i.warmup.mu.Lock()
+	defer i.warmup.mu.Unlock()
+	if i.warmup.generation != generation {
+		return // a newer warm-up superseded this one
+	}
+	i.warmup.warmed = warmed
+	i.warmup.errors = errs
+	i.warmup.completedAt = time.Now()
+	if len(warmed) > 0 {
+		i.warmup.status = WarmupReady
+	} else {
+		// Nothing to warm; serve with mock fallbacks rather than staying unready forever
+		i.warmup.status = WarmupFailed
+	}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// Budget limit names reported in BudgetOutcome.Limits
+const (
+	limitInputTokens  = "max_input_tokens"
+	limitOutputTokens = "max_output_tokens"
+	limitLLMCalls     = "max_llm_calls"
+)
+
+// requestBudget enforces the per-request cost ceilings and records which ones
+// were hit. A nil budget is unlimited.
+type requestBudget struct {
+	cfg      config.BudgetConfig
+	llmCalls int32 // inference calls spent on this request, earlier continuations included
+
+	mu      sync.Mutex
+	limits  []string
+	skipped []string
+}
+
+func newRequestBudget(cfg config.BudgetConfig, priorLLMCalls int32) *requestBudget {
+	return &requestBudget{cfg: cfg, llmCalls: priorLLMCalls}
+}
+
+// capOutputTokens lowers the requested generation length to the budget
+func (b *requestBudget) capOutputTokens(maxTokens int32) int32 {
+	if b == nil || b.cfg.MaxOutputTokens <= 0 || maxTokens <= b.cfg.MaxOutputTokens {
+		return maxTokens
+	}
+	b.exceeded(limitOutputTokens, fmt.Sprintf("generation capped at %d of %d requested tokens", b.cfg.MaxOutputTokens, maxTokens))
+	return b.cfg.MaxOutputTokens
+}
+
+// capInputTokens truncates the prompt to the budget, dropping the tail so the
+// shared instruction prefix stays intact
+func (b *requestBudget) capInputTokens(tokenIds []int32) []int32 {
+	if b == nil || b.cfg.MaxInputTokens <= 0 || int32(len(tokenIds)) <= b.cfg.MaxInputTokens {
+		return tokenIds
+	}
+	b.exceeded(limitInputTokens, fmt.Sprintf("%d of %d prompt tokens dropped", int32(len(tokenIds))-b.cfg.MaxInputTokens, len(tokenIds)))
+	return tokenIds[:b.cfg.MaxInputTokens]
+}
+
+// allowLLMCall spends one inference call, reporting false once the budget is used up
+func (b *requestBudget) allowLLMCall() bool {
+	if b == nil || b.cfg.MaxLLMCalls <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	allowed := b.llmCalls < b.cfg.MaxLLMCalls
+	if allowed {
+		b.llmCalls++
+	}
+	calls := b.llmCalls
+	b.mu.Unlock()
+
+	if !allowed {
+		b.exceeded(limitLLMCalls, fmt.Sprintf("summarization skipped after %d LLM calls", calls))
+	}
+	return allowed
+}
+
+func (b *requestBudget) exceeded(limit, skipped string) {
+	b.mu.Lock()
+	b.limits = append(b.limits, limit)
+	b.skipped = append(b.skipped, skipped)
+	b.mu.Unlock()
+
+	log.Printf("Budget exceeded (%s): %s", limit, skipped)
+	monitoring.RecordBudgetExceeded("llm", limit)
+}
+
+// outcome returns the limits hit so far, or nil when the request stayed within budget
+func (b *requestBudget) outcome() *pb.BudgetOutcome {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.limits) == 0 {
+		return nil
+	}
+	return &pb.BudgetOutcome{
+		Limits:  append([]string(nil), b.limits...),
+		Skipped: append([]string(nil), b.skipped...),
+	}
+}
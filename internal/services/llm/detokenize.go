@@ -0,0 +1,216 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultDetokenizeBatchSize     = 8
+	defaultDetokenizeFlushInterval = 100 * time.Millisecond
+)
+
+// streamDetokenizer buffers generated token IDs during streaming and detokenizes
+// them in batches, replacing one tokenizer RPC per token with one per batch.
+// Batches go over the tokenizer's stateful DecodeStream so words spanning
+// several BPE tokens and multi-byte characters split across batches come out
+// merged; tokenizers without DecodeStream get unary Detokenize calls instead.
+// The text the inference service sent alongside each token is kept as the
+// fallback for when the tokenizer is unreachable.
+type streamDetokenizer struct {
+	orchestrator  *LLMOrchestrator
+	ctx           context.Context
+	modelName     string
+	batchSize     int
+	flushInterval time.Duration
+
+	ids         []int32
+	fallback    strings.Builder
+	startPos    int32
+	nextPos     int32
+	firstQueued time.Time
+
+	decodeStream    pb.TokenizerService_DecodeStreamClient
+	cancelDecode    context.CancelFunc // ends decodeStream
+	streamAvailable bool               // false once DecodeStream turned out to be unimplemented
+	tokenizerDown   bool               // stop calling the tokenizer once it has failed for this stream
+}
+
+func (o *LLMOrchestrator) newStreamDetokenizer(ctx context.Context, modelName string) *streamDetokenizer {
+	batchSize := o.detokenizeBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultDetokenizeBatchSize
+	}
+	flushInterval := o.detokenizeFlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultDetokenizeFlushInterval
+	}
+	return &streamDetokenizer{
+		orchestrator:    o,
+		ctx:             ctx,
+		modelName:       modelName,
+		batchSize:       batchSize,
+		flushInterval:   flushInterval,
+		streamAvailable: true,
+	}
+}
+
+// add queues a generated token and returns text ready to emit once the batch is
+// full or the oldest queued token has waited longer than the flush interval
+func (d *streamDetokenizer) add(tokenID int32, text string, position int32) (string, int32, bool) {
+	if len(d.ids) == 0 {
+		d.startPos = position
+		d.firstQueued = time.Now()
+	}
+	d.ids = append(d.ids, tokenID)
+	d.fallback.WriteString(text)
+	d.nextPos = position + 1
+
+	if len(d.ids) >= d.batchSize || time.Since(d.firstQueued) >= d.flushInterval {
+		return d.flush()
+	}
+	return "", 0, false
+}
+
+// flush detokenizes everything queued and returns the text with the position of
+// its first token; ok is false when there is nothing to emit yet
+func (d *streamDetokenizer) flush() (string, int32, bool) {
+	if len(d.ids) == 0 {
+		return "", 0, false
+	}
+	return d.emit(d.decode(false))
+}
+
+// finish flushes the queue and any text the tokenizer is still holding back,
+// then closes the decode stream; call it once when generation ends
+func (d *streamDetokenizer) finish() (string, int32, bool) {
+	if len(d.ids) == 0 && d.decodeStream == nil {
+		return "", 0, false
+	}
+	if len(d.ids) == 0 {
+		d.startPos = d.nextPos
+	}
+	text, position, ok := d.emit(d.decode(true))
+	if d.decodeStream != nil {
+		d.decodeStream.CloseSend()
+		d.decodeStream = nil
+	}
+	return text, position, ok
+}
+
+func (d *streamDetokenizer) emit(text string) (string, int32, bool) {
+	position := d.startPos
+	d.ids = d.ids[:0]
+	d.fallback.Reset()
+	return text, position, text != ""
+}
+
+// decode returns the text for the queued tokens, falling back to the inference
+// text if the tokenizer fails
+func (d *streamDetokenizer) decode(final bool) string {
+	if d.tokenizerDown {
+		return d.fallback.String()
+	}
+
+	start := time.Now()
+	var text string
+	var err error
+	method := "decode_stream"
+	if d.streamAvailable {
+		text, err = d.decodeStreaming(final)
+		if status.Code(err) == codes.Unimplemented {
+			log.Printf("Tokenizer has no DecodeStream, falling back to batched Detokenize")
+			d.streamAvailable = false
+			if d.decodeStream != nil {
+				d.decodeStream = nil
+				d.cancelDecode()
+			}
+		}
+	}
+	if !d.streamAvailable {
+		method = "detokenize_batch"
+		text, err = d.decodeBatch()
+	}
+	monitoring.RecordRequestDuration("llm", method, time.Since(start))
+
+	if err != nil {
+		log.Printf("Streaming detokenization of %d tokens failed: %v, using inference text for the rest of the stream", len(d.ids), err)
+		monitoring.RecordRequest("llm", method, "error")
+		d.tokenizerDown = true
+		return d.fallback.String()
+	}
+	monitoring.RecordRequest("llm", method, "success")
+	return text
+}
+
+// decodeStreaming sends the queued tokens over the stateful DecodeStream. A
+// decode that takes longer than the detokenize timeout ends the stream.
+func (d *streamDetokenizer) decodeStreaming(final bool) (string, error) {
+	if d.decodeStream == nil {
+		ctx, cancel := context.WithCancel(d.ctx)
+		stream, err := d.orchestrator.tokenizerClient.DecodeStream(ctx)
+		if err != nil {
+			cancel()
+			return "", err
+		}
+		d.decodeStream, d.cancelDecode = stream, cancel
+	}
+	timeout := d.orchestrator.timeouts.Detokenize
+	if timeout <= 0 {
+		return d.exchange(final)
+	}
+	timer := time.AfterFunc(timeout, d.cancelDecode)
+	text, err := d.exchange(final)
+	if !timer.Stop() && err != nil {
+		return "", timedOut(d.ctx, stageDetokenize, err)
+	}
+	return text, err
+}
+
+// exchange decodes the queued tokens over the open DecodeStream
+func (d *streamDetokenizer) exchange(final bool) (string, error) {
+	err := d.decodeStream.Send(&pb.DecodeStreamRequest{
+		TokenIds:          d.ids,
+		ModelName:         d.modelName,
+		SkipSpecialTokens: true,
+		RequestId:         fmt.Sprintf("detok_stream_%d", time.Now().UnixNano()),
+		IsFinal:           final,
+	})
+	if err != nil {
+		// The real cause surfaces on Recv
+		if _, recvErr := d.decodeStream.Recv(); recvErr != nil {
+			return "", recvErr
+		}
+		return "", err
+	}
+
+	resp, err := d.decodeStream.Recv()
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success {
+		return "", fmt.Errorf("tokenizer error: %s", resp.Error)
+	}
+	return resp.Text, nil
+}
+
+// decodeBatch detokenizes the queued tokens with a unary Detokenize call
+func (d *streamDetokenizer) decodeBatch() (string, error) {
+	if len(d.ids) == 0 {
+		return "", nil
+	}
+	resp, err := d.orchestrator.performDetokenization(d.ctx, d.ids, d.modelName)
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
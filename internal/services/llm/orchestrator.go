@@ -2,11 +2,19 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/prompt"
 	pb "ai-search-service/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -17,8 +25,92 @@ type LLMRequest struct {
 	ID        string    `json:"id"`
 	Text      string    `json:"text"`
 	MaxTokens int32     `json:"max_tokens"`
+	Model     string    `json:"model,omitempty"` // empty uses defaultModel
 	Stream    bool      `json:"stream"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// Continuation is a partial summary from an interrupted stream to continue
+	Continuation string `json:"continuation,omitempty"`
+
+	// Budget holds the per-request cost ceilings; nil means unlimited
+	Budget *requestBudget `json:"-"`
+
+	// Sampling holds the caller's sampling parameters; nil keeps the
+	// backend's defaults
+	Sampling *pb.SamplingParams `json:"-"`
+
+	// Subject is who the request runs as, forwarded by the gateway, for
+	// feature flags
+	Subject flags.Subject `json:"-"`
+
+	// Shadow marks mirrored traffic, which bypasses the summary cache so
+	// the backends are really compared
+	Shadow bool `json:"-"`
+
+	// ModelConfidence is the mean token probability the inference service
+	// reported on its final stream message; 0 when unknown
+	ModelConfidence float32 `json:"-"`
+
+	// Truncated is why the stream guard ended the stream early; empty when
+	// it ran to completion
+	Truncated string `json:"-"`
+
+	// Recovered marks a stream that broke off and was continued on the
+	// fallback backend
+	Recovered bool `json:"-"`
+
+	// Regenerated marks a session's request to generate its summary again,
+	// which bypasses the summary cache that would replay the same one
+	Regenerated bool `json:"-"`
+
+	// Deadline is when the caller stops waiting for the request; zero when
+	// it set none
+	Deadline time.Time `json:"-"`
+
+	// InputTruncated marks a prompt cut to fit the model's context window
+	// or the budget's input limit, so the summary saw only part of it
+	InputTruncated bool `json:"-"`
+
+	// tokenLimit caps the tokens streamed once a session sets it above 0
+	// while the request streams
+	tokenLimit *atomic.Int32
+
+	// timeout is the failure of a stream that ran out of time, reported
+	// on its final message
+	timeout *timeoutError
+}
+
+// defaultModel tokenizes and summarizes requests that name no model
+const defaultModel = "facebook/bart-large-cnn"
+
+func (r *LLMRequest) model() string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return defaultModel
+}
+
+// promptText is the text to summarize; a continuation is appended so the
+// model picks up where the interrupted summary left off
+func (r *LLMRequest) promptText() string {
+	if r.Continuation == "" {
+		return r.Text
+	}
+	return r.Text + "\n\nSummary so far: " + r.Continuation
+}
+
+// finalize adds how the request went to the final message of its stream
+func (r *LLMRequest) finalize(response *pb.LLMStreamResponse) {
+	response.Budget = r.Budget.outcome()
+	response.ModelConfidence = r.ModelConfidence
+	response.Truncated = r.Truncated != ""
+	response.TruncatedReason = r.Truncated
+	response.Recovered = r.Recovered
+	response.InputTruncated = r.InputTruncated
+	if r.timeout != nil {
+		response.Error = r.timeout.Error()
+		response.ErrorCode = r.timeout.code
+	}
 }
 
 // LLMResponse represents the response from LLM processing
@@ -28,23 +120,64 @@ type LLMResponse struct {
 	Summary  string   `json:"summary,omitempty"`
 	Error    string   `json:"error,omitempty"`
 	Complete bool     `json:"complete"`
+
+	// ModelConfidence is the mean token probability of the summary; 0 when
+	// the inference backend does not report one
+	ModelConfidence float32 `json:"model_confidence,omitempty"`
+
+	// InputTruncated marks a summary of a prompt that was cut to fit
+	InputTruncated bool `json:"input_truncated,omitempty"`
 }
 
 // LLMOrchestrator manages enterprise tokenization and inference services
 type LLMOrchestrator struct {
 	tokenizerClient pb.TokenizerServiceClient  // Enterprise tokenizer
 	inferenceClient pb.InferenceServiceClient
+	conns           map[string]*grpc.ClientConn // by backend, for diagnostics
 
 	// Request tracking for streaming
 	activeRequests map[string]*RequestProcessor
 	requestsMutex  sync.RWMutex
+	draining       bool // new requests are refused; guarded by requestsMutex
 
 	// Backpressure configuration
 	maxConcurrentRequests int
-	requestTimeout        time.Duration
+	timeouts              config.LLMTimeoutsConfig
+	overload              *overloadAlarm // nil when overload alerts are off
 
 	// Service integration
 	service *LLMService
+
+	// Prompt prefix caching: token IDs of the instruction prefix per model
+	prefixCfg    config.PrefixCacheConfig
+	prefixTokens *lru.Cache[string, []int32]
+
+	// Set once the tokenizer turned out to have no BuildPrompt
+	buildPromptUnsupported atomic.Bool
+
+	// Chat templates of instruction-tuned models, by model
+	chatTemplates map[string]*prompt.ChatTemplate
+
+	// Summaries by a hash of their model, prompt and parameters; nil when
+	// the summary cache is disabled
+	summaries *lru.Cache[string, *cachedSummary]
+
+	// Limits that end degenerate summary streams early
+	streamGuard config.StreamGuardConfig
+
+	// Continues streams that break off on the fallback backend
+	streamRecovery config.StreamRecoveryConfig
+
+	// Inference sessions opened ahead of the streams that claim them; nil
+	// when the pool is disabled
+	streamPool *streamPool
+
+	// Feature flags, evaluated for each request's subject
+	flags *flags.Flags
+
+	// Streaming detokenization batching
+	detokenizeBatchSize     int
+	detokenizeFlushInterval time.Duration
 	
 	// Shutdown
 	ctx    context.Context
@@ -60,6 +193,9 @@ type RequestProcessor struct {
 	Result    *LLMResponse
 	Error     error
 	CreatedAt time.Time
+	Done      chan struct{} // closed once the request has finished
+
+	finishOnce sync.Once
 }
 
 // NewLLMOrchestrator creates a new enterprise LLM orchestrator with tokenization
@@ -67,16 +203,20 @@ func NewLLMOrchestrator(
 	tokenizerAddr string,
 	inferenceAddr string,
 	maxConcurrentRequests int,
+	prefixCfg config.PrefixCacheConfig,
 	service *LLMService,
+	dialOpts ...grpc.DialOption,
 ) (*LLMOrchestrator, error) {
+	dialOpts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, dialOpts...)
+
 	// Connect to enterprise tokenizer service
-	tokenizerConn, err := grpc.Dial(tokenizerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	tokenizerConn, err := grpc.Dial(tokenizerAddr, dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to tokenizer: %w", err)
 	}
 
 	// Connect to inference service
-	inferenceConn, err := grpc.Dial(inferenceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	inferenceConn, err := grpc.Dial(inferenceAddr, dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to inference: %w", err)
 	}
@@ -86,10 +226,13 @@ func NewLLMOrchestrator(
 	orchestrator := &LLMOrchestrator{
 		tokenizerClient:       pb.NewTokenizerServiceClient(tokenizerConn),
 		inferenceClient:       pb.NewInferenceServiceClient(inferenceConn),
+		conns:                 map[string]*grpc.ClientConn{"tokenizer": tokenizerConn, "inference": inferenceConn},
 		activeRequests:        make(map[string]*RequestProcessor),
 		maxConcurrentRequests: maxConcurrentRequests,
-		requestTimeout:        time.Minute * 5,
+		timeouts:              config.LLMTimeoutsConfig{Request: 5 * time.Minute},
 		service:               service,
+		prefixCfg:             prefixCfg,
+		prefixTokens:          lru.New[string, []int32]("llm_prefix_tokens", maxPrefixModels, 0),
 		ctx:                   ctx,
 		cancel:                cancel,
 	}
@@ -98,9 +241,13 @@ func NewLLMOrchestrator(
 }
 
 // Start initializes the orchestrator (no workers needed for direct streaming)
+// and starts filling the stream pool, which runs until Stop
 func (o *LLMOrchestrator) Start() {
 	log.Printf("Starting LLM orchestrator with direct gRPC streaming (max concurrent: %d)", o.maxConcurrentRequests)
 	// No background workers needed - processing is done on-demand via direct gRPC calls
+	if o.streamPool != nil {
+		go o.streamPool.run()
+	}
 }
 
 // Stop gracefully shuts down the orchestrator
@@ -118,77 +265,131 @@ func (o *LLMOrchestrator) Stop() {
 	log.Println("LLM orchestrator stopped")
 }
 
+// Drain refuses new requests while letting those in flight finish
+func (o *LLMOrchestrator) Drain() {
+	o.requestsMutex.Lock()
+	o.draining = true
+	o.requestsMutex.Unlock()
+}
+
 // ProcessRequest processes a NON-STREAMING request directly via gRPC
 func (o *LLMOrchestrator) ProcessRequest(req *LLMRequest) (*LLMResponse, error) {
 	if req.Stream {
 		return nil, fmt.Errorf("use ProcessStreamingRequest for streaming requests")
 	}
 
-	// Check concurrent request limit
-	o.requestsMutex.RLock()
-	activeCount := len(o.activeRequests)
-	o.requestsMutex.RUnlock()
-
-	if activeCount >= o.maxConcurrentRequests {
-		return nil, fmt.Errorf("too many concurrent requests (%d/%d)", activeCount, o.maxConcurrentRequests)
-	}
-
-	// Create request processor
-	ctx, cancel := context.WithTimeout(o.ctx, o.requestTimeout)
-	processor := &RequestProcessor{
-		ID:        req.ID,
-		Ctx:       ctx,
-		Cancel:    cancel,
-		Status:    "processing",
-		CreatedAt: time.Now(),
+	processor, activeCount, err := o.track(req)
+	if err != nil {
+		return nil, err
 	}
 
-	// Track the request
-	o.requestsMutex.Lock()
-	o.activeRequests[req.ID] = processor
-	o.requestsMutex.Unlock()
-
 	// Process immediately
 	go o.processLLMRequest(processor, req)
 
-	log.Printf("Processing non-streaming LLM request %s (active: %d/%d)", req.ID, activeCount+1, o.maxConcurrentRequests)
+	log.Printf("Processing non-streaming LLM request %s (active: %d/%d)", req.ID, activeCount, o.maxConcurrentRequests)
 
 	// Wait for completion
-	return o.waitForCompletion(req.ID)
+	return o.waitForCompletion(processor)
 }
 
 // ProcessStreamingRequest processes a STREAMING request directly
 func (o *LLMOrchestrator) ProcessStreamingRequest(req *LLMRequest, streamCallback func(string, string, bool, int32)) error {
-	// Check concurrent request limit
-	o.requestsMutex.RLock()
-	activeCount := len(o.activeRequests)
-	o.requestsMutex.RUnlock()
+	processor, activeCount, err := o.track(req)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Processing streaming LLM request %s (active: %d/%d)", req.ID, activeCount, o.maxConcurrentRequests)
 
+	// Process streaming directly
+	go o.processStreamingLLMRequest(processor, req, streamCallback)
+
+	return nil
+}
+
+// track registers an in-flight request, enforcing the concurrency limit and
+// unique IDs under a single lock. It returns the number of active requests.
+func (o *LLMOrchestrator) track(req *LLMRequest) (*RequestProcessor, int, error) {
+	requestID := req.ID
+	o.requestsMutex.Lock()
+	defer o.requestsMutex.Unlock()
+
+	activeCount := len(o.activeRequests)
+	if o.draining {
+		return nil, activeCount, fmt.Errorf("orchestrator is shutting down")
+	}
 	if activeCount >= o.maxConcurrentRequests {
-		return fmt.Errorf("too many concurrent requests (%d/%d)", activeCount, o.maxConcurrentRequests)
+		o.overload.reject(activeCount, o.maxConcurrentRequests)
+		return nil, activeCount, fmt.Errorf("too many concurrent requests (%d/%d)", activeCount, o.maxConcurrentRequests)
+	}
+	if _, exists := o.activeRequests[requestID]; exists {
+		return nil, activeCount, fmt.Errorf("request %s is already in progress", requestID)
 	}
 
-	// Create request processor
-	ctx, cancel := context.WithTimeout(o.ctx, o.requestTimeout)
+	ctx, cancel := o.requestContext(req)
 	processor := &RequestProcessor{
-		ID:        req.ID,
+		ID:        requestID,
 		Ctx:       ctx,
 		Cancel:    cancel,
 		Status:    "processing",
 		CreatedAt: time.Now(),
+		Done:      make(chan struct{}),
 	}
+	o.activeRequests[requestID] = processor
+	return processor, activeCount + 1, nil
+}
+
+// finish ends a request's in-flight lifecycle: it leaves activeRequests and
+// its outcome moves to the service, which keeps it queryable for a while
+func (o *LLMOrchestrator) finish(processor *RequestProcessor) {
+	processor.finishOnce.Do(func() { o.complete(processor) })
+}
 
-	// Track the request
+func (o *LLMOrchestrator) complete(processor *RequestProcessor) {
 	o.requestsMutex.Lock()
-	o.activeRequests[req.ID] = processor
+	if o.activeRequests[processor.ID] == processor {
+		delete(o.activeRequests, processor.ID)
+	}
 	o.requestsMutex.Unlock()
+	processor.Cancel()
 
-	log.Printf("Processing streaming LLM request %s (active: %d/%d)", req.ID, activeCount+1, o.maxConcurrentRequests)
+	if o.service != nil {
+		o.service.UpdateRequestStatus(processor.ID, processor.Status, processor.Result, processor.Error)
+	}
+	close(processor.Done)
+}
 
-	// Process streaming directly
-	go o.processStreamingLLMRequest(processor, req, streamCallback)
+// CancelRequest aborts an in-flight request whose client has gone away
+func (o *LLMOrchestrator) CancelRequest(requestID string) {
+	if processor, exists := o.GetRequestStatus(requestID); exists {
+		processor.Cancel()
+	}
+}
 
-	return nil
+// reapStale force-finishes in-flight requests older than maxAge. Requests end
+// on their own within llm.timeouts.request, so anything older has leaked. It
+// returns how many were reaped.
+func (o *LLMOrchestrator) reapStale(maxAge time.Duration) int {
+	cutoff := time.Now().Add(-maxAge)
+	var stale []*RequestProcessor
+	o.requestsMutex.RLock()
+	for _, processor := range o.activeRequests {
+		if processor.CreatedAt.Before(cutoff) {
+			stale = append(stale, processor)
+		}
+	}
+	o.requestsMutex.RUnlock()
+
+	reaped := 0
+	for _, processor := range stale {
+		processor.finishOnce.Do(func() {
+			processor.Status = "failed"
+			processor.Error = fmt.Errorf("request reaped after %s", maxAge)
+			o.complete(processor)
+			reaped++
+		})
+	}
+	return reaped
 }
 
 // GetRequestStatus retrieves the current status of a request
@@ -201,51 +402,45 @@ func (o *LLMOrchestrator) GetRequestStatus(requestID string) (*RequestProcessor,
 }
 
 // waitForCompletion waits for a non-streaming request to complete
-func (o *LLMOrchestrator) waitForCompletion(requestID string) (*LLMResponse, error) {
-	for {
-		select {
-		case <-o.ctx.Done():
-			return nil, fmt.Errorf("context cancelled")
-		default:
-			processor, exists := o.GetRequestStatus(requestID)
-			if !exists {
-				return nil, fmt.Errorf("request not found")
-			}
+func (o *LLMOrchestrator) waitForCompletion(processor *RequestProcessor) (*LLMResponse, error) {
+	select {
+	case <-o.ctx.Done():
+		return nil, fmt.Errorf("context cancelled")
+	case <-processor.Done:
+	}
 
-			switch processor.Status {
-			case "completed":
-				// Clean up the request
-				o.requestsMutex.Lock()
-				delete(o.activeRequests, requestID)
-				o.requestsMutex.Unlock()
-				return processor.Result, nil
-				
-			case "failed":
-				// Clean up the request
-				o.requestsMutex.Lock()
-				delete(o.activeRequests, requestID)
-				o.requestsMutex.Unlock()
-				return nil, processor.Error
-				
-			default:
-				// Still processing, wait a bit
-				time.Sleep(100 * time.Millisecond)
-			}
-		}
+	if processor.Status == "completed" {
+		return processor.Result, nil
 	}
+	return nil, processor.Error
 }
 
 // processLLMRequest handles NON-STREAMING LLM processing via direct gRPC
 func (o *LLMOrchestrator) processLLMRequest(processor *RequestProcessor, req *LLMRequest) {
-	defer func() {
-		// Clean up on completion - for non-streaming, we wait for result so don't delete here
-		processor.Cancel()
-	}()
+	defer o.finish(processor)
+
+	// A cached summary of the same prompt costs no LLM call
+	cacheKey, cacheable := o.summaryCacheKey(req)
+	if cacheable {
+		if cached, ok := o.lookupSummary(cacheKey); ok {
+			processor.Result = &LLMResponse{ID: req.ID, Summary: cached.Summary, Complete: true, ModelConfidence: cached.ModelConfidence, InputTruncated: cached.InputTruncated}
+			processor.Status = "completed"
+			return
+		}
+	}
+
+	// Out of LLM calls: complete without a summary, the budget outcome says why
+	if !req.Budget.allowLLMCall() {
+		processor.Result = &LLMResponse{ID: req.ID, Complete: true}
+		processor.Status = "completed"
+		return
+	}
+	req.MaxTokens = req.Budget.capOutputTokens(req.MaxTokens)
 
 	// CLEAN TOKEN-NATIVE FLOW: tokenize → inference → detokenize
 	
 	// Step 1: Call tokenizer service to tokenize input text
-	tokenizeResp, err := o.performTokenization(processor.Ctx, req.Text, "facebook/bart-large-cnn", req.MaxTokens)
+	tokenizeResp, err := o.performTokenization(processor.Ctx, req)
 	if err != nil {
 		log.Printf("Tokenization failed for request %s: %v", req.ID, err)
 		processor.Status = "failed"
@@ -255,9 +450,11 @@ func (o *LLMOrchestrator) processLLMRequest(processor *RequestProcessor, req *LL
 
 	log.Printf("Step 1 complete - Tokenization: %d tokens (%.2fms, %s)", 
 		tokenizeResp.TokenCount, tokenizeResp.ProcessingTimeMs, tokenizeResp.CacheStatus)
+	tokenIds := o.fitPrompt(req, tokenizeResp)
 
 	// Step 2: Call inference service with token IDs
-	inferenceResp, err := o.performInference(processor.Ctx, req, tokenizeResp.TokenIds, tokenizeResp.ModelUsed)
+	prefixCount := o.prefixTokenCount(processor.Ctx, req, tokenizeResp.ModelUsed, tokenIds)
+	inferenceResp, err := o.performInference(processor.Ctx, req, tokenIds, tokenizeResp.ModelUsed, tokenizeResp.TokenizerVersion, prefixCount)
 	if err != nil {
 		log.Printf("Inference failed for request %s: %v", req.ID, err)
 		processor.Status = "failed"
@@ -283,28 +480,57 @@ func (o *LLMOrchestrator) processLLMRequest(processor *RequestProcessor, req *LL
 	// Complete response
 	processor.Status = "completed"
 	processor.Result = &LLMResponse{
-		ID:       req.ID,
-		Summary:  finalSummary,
-		Complete: true,
+		ID:              req.ID,
+		Summary:         finalSummary,
+		Complete:        true,
+		ModelConfidence: inferenceResp.Confidence,
+		InputTruncated:  req.InputTruncated,
+	}
+	if cacheable {
+		o.storeSummary(cacheKey, finalSummary, inferenceResp.Confidence, req.InputTruncated)
 	}
 }
 
 // processStreamingLLMRequest handles STREAMING LLM processing via direct gRPC
 func (o *LLMOrchestrator) processStreamingLLMRequest(processor *RequestProcessor, req *LLMRequest, streamCallback func(string, string, bool, int32)) {
-	defer func() {
-		// Clean up on completion - for streaming, delete immediately
-		o.requestsMutex.Lock()
-		delete(o.activeRequests, req.ID)
-		o.requestsMutex.Unlock()
-		processor.Cancel()
-	}()
+	defer o.finish(processor)
+
+	cacheKey, cacheable := o.summaryCacheKey(req)
+	if cacheable {
+		if cached, ok := o.lookupSummary(cacheKey); ok {
+			processor.Status = "completed"
+			replaySummary(req, cached, streamCallback)
+			return
+		}
+		// Keep the streamed text to cache once the stream completes
+		var generated strings.Builder
+		forward := streamCallback
+		streamCallback = func(requestID, token string, isFinal bool, position int32) {
+			generated.WriteString(token)
+			forward(requestID, token, isFinal, position)
+		}
+		defer func() {
+			if processor.Status == "completed" && req.Truncated == "" && !req.Recovered {
+				o.storeSummary(cacheKey, generated.String(), req.ModelConfidence, req.InputTruncated)
+			}
+		}()
+	}
+
+	// Out of LLM calls: end the stream without tokens, the budget outcome says why
+	if !req.Budget.allowLLMCall() {
+		processor.Status = "completed"
+		streamCallback(req.ID, "", true, 0)
+		return
+	}
+	req.MaxTokens = req.Budget.capOutputTokens(req.MaxTokens)
 
 	// CLEAN TOKEN-NATIVE STREAMING FLOW: tokenize → inference → detokenize (streaming)
 	
 	// Step 1: Call tokenizer service to tokenize input text
-	tokenizeResp, err := o.performTokenization(processor.Ctx, req.Text, "facebook/bart-large-cnn", req.MaxTokens)
+	tokenizeResp, err := o.performTokenization(processor.Ctx, req)
 	if err != nil {
 		log.Printf("Tokenization failed for streaming request %s: %v", req.ID, err)
+		errors.As(err, &req.timeout)
 		processor.Status = "failed"
 		processor.Error = fmt.Errorf("tokenization failed: %w", err)
 		streamCallback(req.ID, "", true, 0) // Send error signal
@@ -313,116 +539,184 @@ func (o *LLMOrchestrator) processStreamingLLMRequest(processor *RequestProcessor
 
 	log.Printf("Step 1 complete - Streaming tokenization: %d tokens (%.2fms, %s)", 
 		tokenizeResp.TokenCount, tokenizeResp.ProcessingTimeMs, tokenizeResp.CacheStatus)
+	tokenIds := o.fitPrompt(req, tokenizeResp)
 
 	// Step 2: Call inference service for streaming with token IDs
-	o.performStreamingInference(processor, req, streamCallback, tokenizeResp.TokenIds, tokenizeResp.ModelUsed)
+	prefixCount := o.prefixTokenCount(processor.Ctx, req, tokenizeResp.ModelUsed, tokenIds)
+	o.performStreamingInference(processor, req, streamCallback, tokenIds, tokenizeResp.ModelUsed, tokenizeResp.TokenizerVersion, prefixCount)
 }
 
-// performTokenization calls the tokenizer service to tokenize text
-func (o *LLMOrchestrator) performTokenization(ctx context.Context, text, modelName string, maxTokens int32) (*pb.TokenizeResponse, error) {
-	// Build complete prompt for summarization
-	completePrompt := o.buildSummarizationPrompt(text)
-	log.Printf("Complete prompt: '%s' (max tokens: %d)", completePrompt, maxTokens)
-	return o.tokenizerClient.Tokenize(ctx, &pb.TokenizeRequest{
-		Text:                  completePrompt,
-		ModelName:            modelName,
-		MaxTokens:            maxTokens,
-		IncludeSpecialTokens: true,
-		RequestId:            fmt.Sprintf("llm_%d", time.Now().UnixNano()),
-	})
+// performTokenization has the tokenizer assemble and tokenize the prompt
+// of req. The prompt is only truncated to the model's context and the
+// budget's input limit, never to the summary length, which shrinks for thin
+// results.
+func (o *LLMOrchestrator) performTokenization(ctx context.Context, req *LLMRequest) (*pb.TokenizeResponse, error) {
+	t, vars := o.promptTemplate(req)
+	stageCtx, cancel := o.stageContext(ctx, stageTokenize)
+	defer cancel()
+	resp, completePrompt, err := o.assemblePrompt(stageCtx, req.model(), t, vars, fmt.Sprintf("llm_%d", time.Now().UnixNano()))
+	if err == nil {
+		log.Printf("Complete prompt: '%s'", completePrompt)
+	}
+	return resp, stageError(ctx, stageCtx, stageTokenize, err)
 }
 
-// performInference calls the inference service with token IDs
-func (o *LLMOrchestrator) performInference(ctx context.Context, req *LLMRequest, tokenIds []int32, modelName string) (*pb.SummarizeResponse, error) {
+// performInference calls the inference service with token IDs, which
+// tokenizerVersion's vocabulary produced
+func (o *LLMOrchestrator) performInference(ctx context.Context, req *LLMRequest, tokenIds []int32, modelName, tokenizerVersion string, prefixTokenCount int32) (*pb.SummarizeResponse, error) {
 	// Create inference request with tokens as primary input
 	inferenceReq := &pb.SummarizeRequest{
-		TokenIds:  tokenIds,
-		ModelName: modelName,
-		MaxLength: req.MaxTokens,
-		Streaming: false,
-		RequestId: req.ID,
+		TokenIds:         tokenIds,
+		ModelName:        modelName,
+		MaxLength:        req.MaxTokens,
+		Streaming:        false,
+		RequestId:        req.ID,
+		PrefixTokenCount: prefixTokenCount,
+		Sampling:         req.Sampling,
+		TokenizerVersion: tokenizerVersion,
 	}
 	
-	log.Printf("Calling inference service with %d tokens", len(tokenIds))
+	log.Printf("Calling inference service with %d tokens (%d prefix)", len(tokenIds), prefixTokenCount)
 	
-	return o.inferenceClient.Summarize(ctx, inferenceReq)
+	stageCtx, cancel := o.stageContext(ctx, stageInference)
+	defer cancel()
+	resp, err := o.inferenceClient.Summarize(stageCtx, inferenceReq)
+	return resp, stageError(ctx, stageCtx, stageInference, err)
 }
 
 // performDetokenization calls the tokenizer service to detokenize token IDs
 func (o *LLMOrchestrator) performDetokenization(ctx context.Context, tokenIds []int32, modelName string) (*pb.DetokenizeResponse, error) {
-	return o.tokenizerClient.Detokenize(ctx, &pb.DetokenizeRequest{
+	stageCtx, cancel := o.stageContext(ctx, stageDetokenize)
+	defer cancel()
+	resp, err := o.tokenizerClient.Detokenize(stageCtx, &pb.DetokenizeRequest{
 		TokenIds:          tokenIds,
 		ModelName:         modelName,
 		SkipSpecialTokens: true, // Skip special tokens for clean output
 		RequestId:         fmt.Sprintf("detok_%d", time.Now().UnixNano()),
 	})
-}
-
-// buildSummarizationPrompt constructs the complete prompt for tokenization
-func (o *LLMOrchestrator) buildSummarizationPrompt(searchResults string) string {
-	// BART-optimized prompt - minimal instructions, focus on content
-	// BART works best with direct content for summarization
-	return searchResults
+	return resp, stageError(ctx, stageCtx, stageDetokenize, err)
 }
 
 // performStreamingInference handles streaming inference via direct gRPC with tokens
-func (o *LLMOrchestrator) performStreamingInference(processor *RequestProcessor, req *LLMRequest, streamCallback func(string, string, bool, int32), tokenIds []int32, modelName string) {
+func (o *LLMOrchestrator) performStreamingInference(processor *RequestProcessor, req *LLMRequest, streamCallback func(string, string, bool, int32), tokenIds []int32, modelName, tokenizerVersion string, prefixTokenCount int32) {
 	// Create streaming inference request with tokens as input
 	inferenceReq := &pb.SummarizeRequest{
-		TokenIds:  tokenIds,
-		ModelName: modelName,
-		MaxLength: req.MaxTokens,
-		Streaming: true,
-		RequestId: req.ID,
+		TokenIds:         tokenIds,
+		ModelName:        modelName,
+		MaxLength:        req.MaxTokens,
+		Streaming:        true,
+		RequestId:        req.ID,
+		PrefixTokenCount: prefixTokenCount,
+		Sampling:         req.Sampling,
+		TokenizerVersion: tokenizerVersion,
 	}
 	
-	log.Printf("Starting streaming inference with %d tokens", len(tokenIds))
+	log.Printf("Starting streaming inference with %d tokens (%d prefix)", len(tokenIds), prefixTokenCount)
 
-	stream, err := o.inferenceClient.SummarizeStream(processor.Ctx, inferenceReq)
+	// Bounded by the inference timeout, and cancelled when the stream guard
+	// ends the stream, to stop generation
+	streamCtx, cancelStream := o.stageContext(processor.Ctx, stageInference)
+	defer cancelStream()
+
+	stream, err := o.openInferenceStream(streamCtx, inferenceReq)
 	if err != nil {
+		err = stageError(processor.Ctx, streamCtx, stageInference, err)
+		errors.As(err, &req.timeout)
 		processor.Status = "failed"
 		processor.Error = fmt.Errorf("streaming inference failed: %w", err)
 		streamCallback(req.ID, "", true, 0) // Send error
 		return
 	}
 
+	// emit forwards generated text, reporting false once the guard ends the
+	// stream, which it has then finished
+	guard := newStreamGuard(o.streamGuard)
+	var generated strings.Builder // kept to continue from if the stream breaks off
+	var offset, next int32        // positions of a recovery stream follow the broken one's
+	seam := false                 // the next text is the first of a recovery stream
+	emit := func(text string, position int32) bool {
+		if seam {
+			text, seam = stitch(generated.String(), text), false
+		}
+		generated.WriteString(text)
+		next = position + 1
+		streamCallback(req.ID, text, false, position)
+		reason := guard.observe(text)
+		if limit := req.tokenLimit; reason == "" && limit != nil && limit.Load() > 0 && next >= limit.Load() {
+			reason = truncatedMaxTokens
+		}
+		if reason == "" {
+			return true
+		}
+		cancelStream()
+		log.Printf("Ended the stream of request %s early: %s", req.ID, reason)
+		monitoring.RecordStreamTruncation(reason)
+		req.Truncated = reason
+		processor.Status = "completed"
+		streamCallback(req.ID, "", true, 0)
+		return false
+	}
+
+	// Generated token IDs are detokenized in batches rather than one RPC per token
+	detok := o.newStreamDetokenizer(processor.Ctx, modelName)
+	flushPending := func() bool {
+		if text, position, ok := detok.flush(); ok {
+			return emit(text, position)
+		}
+		return true
+	}
+	finishPending := func() bool {
+		if text, position, ok := detok.finish(); ok {
+			return emit(text, position)
+		}
+		return true
+	}
+
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
+			if !finishPending() {
+				return
+			}
 			if err.Error() == "EOF" {
 				// Stream complete - send final callback to signal completion
 				processor.Status = "completed"
 				streamCallback(req.ID, "", true, 0) // Signal final completion
 				return
 			}
+			if recovery := o.recoverStream(streamCtx, req, generated.String(), err); recovery != nil {
+				stream, offset, seam = recovery, next, true
+				continue
+			}
+			err = stageError(processor.Ctx, streamCtx, stageInference, err)
+			errors.As(err, &req.timeout)
 			processor.Status = "failed"
 			processor.Error = fmt.Errorf("streaming error: %w", err)
 			streamCallback(req.ID, "", true, 0) // Send error
 			return
 		}
 
-		// TOKEN-NATIVE STREAMING: Detokenize token ID if available
-		finalToken := resp.Token
+		// TOKEN-NATIVE STREAMING: Queue token ID for batched detokenization
 		if resp.GeneratedTokenId != 0 && !resp.IsFinal {
-			// Call detokenizer for this single token ID
-			detokenizeResp, err := o.performDetokenization(processor.Ctx, []int32{resp.GeneratedTokenId}, modelName)
-			if err != nil {
-				log.Printf("Streaming detokenization failed for token %d: %v, using fallback", resp.GeneratedTokenId, err)
-				// Keep using the fallback token text from inference service
-			} else {
-				// Use the properly detokenized text
-				finalToken = detokenizeResp.Text
-				log.Printf("Detokenized streaming token %d: '%s'", resp.GeneratedTokenId, finalToken)
+			if text, position, ok := detok.add(resp.GeneratedTokenId, resp.Token, resp.Position); ok && !emit(text, position) {
+				return
 			}
+			continue
 		}
 
-		// Send token via callback (either detokenized or fallback)
-		streamCallback(req.ID, finalToken, resp.IsFinal, resp.Position)
-
+		// Text-only chunk or final signal - emit anything still buffered first to keep order
 		if resp.IsFinal {
+			if !finishPending() {
+				return
+			}
+			req.ModelConfidence = resp.Confidence
+			streamCallback(req.ID, resp.Token, true, resp.Position+offset)
 			processor.Status = "completed"
 			break
 		}
+		if !flushPending() || !emit(resp.Token, resp.Position+offset) {
+			return
+		}
 	}
 }
 
@@ -431,6 +725,7 @@ func (o *LLMOrchestrator) performStreamingInference(processor *RequestProcessor,
 func (o *LLMOrchestrator) GetStats() map[string]interface{} {
 	o.requestsMutex.RLock()
 	activeRequests := len(o.activeRequests)
+	draining := o.draining
 	
 	// Count by status
 	processing := 0
@@ -451,6 +746,7 @@ func (o *LLMOrchestrator) GetStats() map[string]interface{} {
 
 	return map[string]interface{}{
 		"active_requests":        activeRequests,
+		"draining":               draining,
 		"max_concurrent":         o.maxConcurrentRequests,
 		"processing_requests":    processing,
 		"completed_requests":     completed,
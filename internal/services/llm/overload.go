@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/notify"
+)
+
+// overloadAlarm raises an overload alert once the orchestrator has rejected
+// config.Rejections requests at its concurrency limit within config.Window.
+// The count restarts after each alert, so sustained overload repeats it
+// (subject to the channels' cooldowns) while a brief spike does not.
+type overloadAlarm struct {
+	config   config.OverloadAlertConfig
+	notifier *notify.Dispatcher
+
+	mu         sync.Mutex
+	rejections []time.Time
+}
+
+func newOverloadAlarm(cfg config.OverloadAlertConfig, notifier *notify.Dispatcher) *overloadAlarm {
+	if cfg.Rejections <= 0 || cfg.Window <= 0 {
+		return nil
+	}
+	return &overloadAlarm{config: cfg, notifier: notifier}
+}
+
+// reject records a request turned away with active of max slots in use
+func (a *overloadAlarm) reject(active, max int) {
+	if a == nil {
+		return
+	}
+	now := time.Now()
+
+	a.mu.Lock()
+	kept := a.rejections[:0]
+	for _, t := range a.rejections {
+		if now.Sub(t) < a.config.Window {
+			kept = append(kept, t)
+		}
+	}
+	a.rejections = append(kept, now)
+	count := len(a.rejections)
+	if count < a.config.Rejections {
+		a.mu.Unlock()
+		return
+	}
+	a.rejections = nil
+	a.mu.Unlock()
+
+	a.notifier.Publish(notify.Message{
+		Event:    notify.EventOverload,
+		Severity: notify.Warning,
+		Title:    "LLM orchestrator overloaded",
+		Text:     fmt.Sprintf("%d requests were rejected at the concurrency limit within %s.", count, a.config.Window),
+		Fields: []notify.Field{
+			{Name: "Active requests", Value: fmt.Sprintf("%d/%d", active, max)},
+		},
+		Key: "overload:llm",
+	})
+}
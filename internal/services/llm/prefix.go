@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/prompt"
+)
+
+// maxPrefixModels bounds the models whose instruction prefix tokens are kept.
+// Model names come from requests, so they are not trusted to be few.
+const maxPrefixModels = 64
+
+// instruction returns the summarization instruction every prompt carries
+func (o *LLMOrchestrator) instruction() string {
+	if o.prefixCfg.Instruction == "" {
+		return config.DefaultSummarizationInstruction
+	}
+	return o.prefixCfg.Instruction
+}
+
+// prefixCached reports whether the prompt of req starts with the instruction
+// as a prefix for backends to cache. The prefix_cache feature flag can turn
+// it off for some requests.
+func (o *LLMOrchestrator) prefixCached(req *LLMRequest) bool {
+	return o.prefixCfg.Enabled && o.flags.Enabled(flags.PrefixCache, req.Subject)
+}
+
+// prefixTokenCount returns how many leading prompt tokens belong to the shared
+// instruction prefix. The prefix is tokenized once per model and compared with
+// the prompt token by token, so a BPE merge across the prefix boundary can only
+// shorten the marked prefix, never mark prompt content as cacheable.
+func (o *LLMOrchestrator) prefixTokenCount(ctx context.Context, req *LLMRequest, modelName string, tokenIds []int32) int32 {
+	if !o.prefixCached(req) {
+		return 0
+	}
+
+	prefix, err := o.getPrefixTokens(ctx, modelName)
+	if err != nil {
+		log.Printf("Prefix tokenization failed for model %s: %v, sending request without prefix hint", modelName, err)
+		return 0
+	}
+
+	n := 0
+	for n < len(prefix) && n < len(tokenIds) && prefix[n] == tokenIds[n] {
+		n++
+	}
+	return int32(n)
+}
+
+// getPrefixTokens returns the cached token IDs of the instruction prefix for a model
+func (o *LLMOrchestrator) getPrefixTokens(ctx context.Context, modelName string) ([]int32, error) {
+	if prefix, ok := o.prefixTokens.Get(modelName); ok {
+		return prefix, nil
+	}
+
+	// Assembled as prompts are, so the special tokens the model family puts
+	// before the instruction match
+	t, _ := prompt.Lookup(prompt.Summarize)
+	vars := map[string]string{prompt.VarInstruction: o.instruction()}
+	resp, _, err := o.assemblePrompt(ctx, modelName, t, vars, fmt.Sprintf("prefix_%d", time.Now().UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("tokenizer error: %s", resp.Error)
+	}
+
+	o.prefixTokens.Add(modelName, resp.TokenIds)
+
+	log.Printf("Cached %d instruction prefix tokens for model %s", len(resp.TokenIds), modelName)
+	return resp.TokenIds, nil
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/prompt"
+	pb "ai-search-service/proto"
+)
+
+// promptTemplate returns the template and variables the prompt of req is
+// assembled from. Every prompt carries the instruction; with prefix caching
+// it comes first, as the system part, so prompts share a cacheable token
+// prefix, and otherwise it starts the user part with the results.
+func (o *LLMOrchestrator) promptTemplate(req *LLMRequest) (prompt.Template, map[string]string) {
+	vars := map[string]string{prompt.VarResults: req.Text}
+	if o.prefixCached(req) {
+		vars[prompt.VarInstruction] = o.instruction()
+	} else {
+		vars[prompt.VarUserInstruction] = strings.TrimRightFunc(o.instruction(), unicode.IsSpace) + prompt.Separator
+	}
+	id := prompt.Summarize
+	if req.Continuation != "" {
+		id = prompt.SummarizeContinue
+		vars[prompt.VarSummary] = req.Continuation
+	}
+	t, _ := prompt.Lookup(id)
+	return t, vars
+}
+
+// renderPrompt returns the prompt text of req before special tokens and
+// chat formatting, which are fixed per model
+func (o *LLMOrchestrator) renderPrompt(req *LLMRequest) string {
+	t, vars := o.promptTemplate(req)
+	return t.Text(vars)
+}
+
+// lookupChatTemplates returns the configured chat templates by model
+func lookupChatTemplates(configs []config.ChatTemplateConfig) (map[string]*prompt.ChatTemplate, error) {
+	templates := make(map[string]*prompt.ChatTemplate, len(configs))
+	for _, cfg := range configs {
+		chat, err := prompt.LookupChatTemplate(cfg.Template, cfg.BOSToken, cfg.EOSToken)
+		if err != nil {
+			return nil, fmt.Errorf("chat template of model %s: %w", cfg.Model, err)
+		}
+		templates[cfg.Model] = chat
+	}
+	return templates, nil
+}
+
+// checkChatTemplates has the tokenizer render each chat template on a
+// sample prompt of a user message, the least a prompt has, so a template
+// that does not parse or render, or a tokenizer that cannot render them,
+// stops the service at startup rather than failing requests. A tokenizer
+// that cannot be reached yet leaves the templates to be checked on use.
+func (o *LLMOrchestrator) checkChatTemplates(ctx context.Context) error {
+	t, _ := prompt.Lookup(prompt.Summarize)
+	for model, chat := range o.chatTemplates {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := o.tokenizerClient.BuildPrompt(checkCtx, &pb.BuildPromptRequest{
+			TemplateId:   t.ID,
+			Variables:    map[string]string{prompt.VarResults: "Sample search results."},
+			ModelName:    model,
+			RequestId:    "chat_template_check",
+			ChatTemplate: chat.Source,
+			BosToken:     chat.BOSToken,
+			EosToken:     chat.EOSToken,
+		})
+		cancel()
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.Unimplemented:
+			return fmt.Errorf("chat template of model %s: the tokenizer has no BuildPrompt to render it", model)
+		case codes.InvalidArgument:
+			return fmt.Errorf("chat template of model %s: %s", model, status.Convert(err).Message())
+		default:
+			log.Printf("Could not check the chat template of model %s with the tokenizer: %v", model, err)
+		}
+	}
+	return nil
+}
+
+// assemblePrompt has the tokenizer render t from vars and tokenize it with
+// the special tokens of the model's family, or in the model's chat template
+// when it has one. A tokenizer without BuildPrompt is remembered and from
+// then on sent the prompt rendered here to Tokenize, which only models
+// without a chat template can use. It returns the tokens and the assembled
+// text.
+func (o *LLMOrchestrator) assemblePrompt(ctx context.Context, modelName string, t prompt.Template, vars map[string]string, requestID string) (*pb.TokenizeResponse, string, error) {
+	chat := o.chatTemplates[modelName]
+	if !o.buildPromptUnsupported.Load() {
+		req := &pb.BuildPromptRequest{
+			TemplateId: t.ID,
+			Variables:  vars,
+			ModelName:  modelName,
+			RequestId:  requestID,
+		}
+		if chat != nil {
+			req.ChatTemplate, req.BosToken, req.EosToken = chat.Source, chat.BOSToken, chat.EOSToken
+		}
+		resp, err := o.tokenizerClient.BuildPrompt(ctx, req)
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+			log.Printf("Tokenizer has no BuildPrompt, assembling prompts for Tokenize instead")
+			o.buildPromptUnsupported.Store(true)
+		case err != nil:
+			return nil, "", err
+		case resp.Tokens == nil:
+			return nil, "", fmt.Errorf("tokenizer built prompt %s without tokens", t.ID)
+		default:
+			monitoring.RecordPromptAssembly("tokenizer")
+			return resp.Tokens, resp.Prompt, nil
+		}
+	}
+
+	if chat != nil {
+		return nil, "", status.Errorf(codes.FailedPrecondition, "the chat template of model %s needs a tokenizer with BuildPrompt", modelName)
+	}
+	text := t.Text(vars)
+	resp, err := o.tokenizerClient.Tokenize(ctx, &pb.TokenizeRequest{
+		Text:                 text,
+		ModelName:            modelName,
+		IncludeSpecialTokens: true,
+		RequestId:            requestID,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	monitoring.RecordPromptAssembly("orchestrator")
+	return resp, text, nil
+}
@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"log"
+
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// Limits a summarization prompt is cut to fit
+const (
+	truncatedToContext = "context_window"
+	truncatedToBudget  = "budget"
+)
+
+// fitPrompt records the size of the tokenized prompt against the model's
+// context window and returns the token IDs to send for inference. The
+// request is flagged when its prompt lost tokens, whether the tokenizer cut
+// it to the context window or the budget's input limit did.
+func (o *LLMOrchestrator) fitPrompt(req *LLMRequest, resp *pb.TokenizeResponse) []int32 {
+	model := resp.ModelUsed
+	if model == "" {
+		model = req.model()
+	}
+	tokens := resp.OriginalTokenCount
+	if tokens == 0 {
+		tokens = resp.TokenCount // tokenizers that do not report the original size
+	}
+	monitoring.RecordPromptSize(model, tokens, resp.ContextWindow)
+
+	if resp.WasTruncated {
+		log.Printf("WARNING: prompt of request %s truncated from %d to %d tokens to fit the %d-token context of %s",
+			req.ID, tokens, resp.TokenCount, resp.ContextWindow, model)
+		monitoring.RecordPromptTruncation(model, truncatedToContext)
+		req.InputTruncated = true
+	}
+
+	tokenIds := req.Budget.capInputTokens(resp.TokenIds)
+	if len(tokenIds) < len(resp.TokenIds) {
+		log.Printf("WARNING: prompt of request %s truncated from %d to %d tokens by its budget",
+			req.ID, len(resp.TokenIds), len(tokenIds))
+		monitoring.RecordPromptTruncation(model, truncatedToBudget)
+		req.InputTruncated = true
+	}
+	return tokenIds
+}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"log"
+	"unicode"
+	"unicode/utf8"
+
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// recoverStream continues a summary whose inference stream failed with
+// cause. The continuation is requested as text, without token IDs, which the
+// inference service generates on its Ollama backend, prompted with the
+// partial summary to continue from; the returned stream then replaces the
+// broken one. It returns nil when the stream is not recovered: recovery is
+// off or already happened once, the request was cancelled or is out of LLM
+// calls, or the fallback failed to start.
+func (o *LLMOrchestrator) recoverStream(ctx context.Context, req *LLMRequest, partial string, cause error) pb.InferenceService_SummarizeStreamClient {
+	if !o.streamRecovery.Enabled || req.Recovered || ctx.Err() != nil {
+		return nil
+	}
+	if !req.Budget.allowLLMCall() {
+		monitoring.RecordStreamRecovery("skipped")
+		return nil
+	}
+
+	text := req.promptText()
+	if partial != "" {
+		text += "\n\nContinue from: " + partial
+	}
+	stream, err := o.inferenceClient.SummarizeStream(ctx, &pb.SummarizeRequest{
+		OriginalText: text,
+		MaxLength:    req.MaxTokens,
+		Streaming:    true,
+		RequestId:    req.ID,
+		Sampling:     req.Sampling,
+	})
+	if err != nil {
+		log.Printf("Stream recovery for request %s failed to start: %v", req.ID, err)
+		monitoring.RecordStreamRecovery("failed")
+		return nil
+	}
+	log.Printf("Stream for request %s broke off after %d chars (%v), continuing on the fallback backend", req.ID, len(partial), cause)
+	monitoring.RecordStreamRecovery("recovered")
+	req.Recovered = true
+	return stream
+}
+
+// stitch joins the first text of a recovery stream to the partial summary,
+// adding the space between two words the backends may each have left out
+func stitch(partial, text string) string {
+	last, _ := utf8.DecodeLastRuneInString(partial)
+	first, _ := utf8.DecodeRuneInString(text)
+	if partial == "" || text == "" || unicode.IsSpace(last) || unicode.IsSpace(first) || unicode.IsPunct(first) {
+		return text
+	}
+	return " " + text
+}
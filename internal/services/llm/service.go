@@ -8,23 +8,68 @@ import (
 	"time"
 
 	"ai-search-service/internal/config"
+	"ai-search-service/internal/flags"
 	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
 	"ai-search-service/internal/monitoring"
+	"ai-search-service/internal/notify"
 	pb "ai-search-service/proto"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // LLMService implements the gRPC LLMOrchestratorService
 type LLMService struct {
 	pb.UnimplementedLLMOrchestratorServiceServer
-	orchestrator   *LLMOrchestrator
-	config         *config.Config
-	activeRequests map[string]*RequestTracker
-	requestsMutex  sync.RWMutex
-	streamingChans map[string]chan *pb.LLMStreamResponse
-	streamMutex    sync.RWMutex
+	orchestrator *LLMOrchestrator
+	config       *config.Config
+
+	// finishedRequests keeps outcomes for GetStatus once a request leaves the
+	// orchestrator's activeRequests; in-flight state lives only there
+	finishedRequests *lru.Cache[string, *RequestTracker]
+	streamingChans   map[string]*streamEntry
+	streamMutex      sync.RWMutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// streamEntry feeds one StreamRequest handler. done is closed when the
+// handler exits or the entry is reaped, so producers never block on or send
+// to a stream nobody reads.
+type streamEntry struct {
+	ch        chan *pb.LLMStreamResponse
+	done      chan struct{}
+	closeOnce sync.Once
+	createdAt time.Time
+}
+
+func newStreamEntry() *streamEntry {
+	return &streamEntry{
+		ch:        make(chan *pb.LLMStreamResponse, 100),
+		done:      make(chan struct{}),
+		createdAt: time.Now(),
+	}
+}
+
+// send delivers a response unless the stream has been closed
+func (e *streamEntry) send(response *pb.LLMStreamResponse) bool {
+	select {
+	case e.ch <- response:
+		return true
+	case <-e.done:
+		return false
+	}
 }
 
-// RequestTracker tracks the status of individual requests
+func (e *streamEntry) close() {
+	e.closeOnce.Do(func() { close(e.done) })
+}
+
+// RequestTracker records the outcome of a finished request
 type RequestTracker struct {
 	RequestID     string
 	Status        string // pending, processing, completed, failed
@@ -35,34 +80,71 @@ type RequestTracker struct {
 	Response      *LLMResponse
 }
 
-// NewLLMService creates a new enterprise LLM service
-func NewLLMService(cfg *config.Config) (*LLMService, error) {
+// NewLLMService creates a new enterprise LLM service. dialOpts are added to the
+// tokenizer and inference connections (e.g. an in-memory dialer in the test harness).
+func NewLLMService(cfg *config.Config, dialOpts ...grpc.DialOption) (*LLMService, error) {
+	notifier, err := notify.New(cfg.Notifications)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create enterprise LLM orchestrator with tokenization
 	orchestrator, err := NewLLMOrchestrator(
 		cfg.GetTokenizerAddress(), // Enterprise tokenizer
 		cfg.GetInferenceAddress(),
 		cfg.LLM.MaxWorkers, // Now used as max concurrent requests
+		cfg.Inference.PrefixCache,
 		nil, // Will be set after service creation
+		dialOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create LLM orchestrator: %w", err)
 	}
 
 	service := &LLMService{
-		orchestrator:   orchestrator,
-		config:         cfg,
-		activeRequests: make(map[string]*RequestTracker),
-		streamingChans: make(map[string]chan *pb.LLMStreamResponse),
+		orchestrator:     orchestrator,
+		config:           cfg,
+		finishedRequests: lru.New[string, *RequestTracker]("llm_finished_requests", cfg.LLM.MaxFinishedRequests, cfg.LLM.RequestTTL),
+		streamingChans:   make(map[string]*streamEntry),
+		stop:             make(chan struct{}),
+	}
+
+	// Feature flags come from config, with the overrides operators make on
+	// the gateway read from the shared Redis
+	var redisClient *redis.Client
+	if addr := cfg.GetRedisAddress(); addr != "" {
+		redisClient = redis.NewClient(&redis.Options{Addr: addr, Password: cfg.Redis.Password, DB: cfg.Redis.DB})
+	}
+	if orchestrator.flags, err = flags.New(cfg, redisClient); err != nil {
+		return nil, err
 	}
 
 	// Set the service reference in orchestrator
 	orchestrator.service = service
+	orchestrator.overload = newOverloadAlarm(cfg.Notifications.Overload, notifier)
+	orchestrator.detokenizeBatchSize = cfg.LLM.DetokenizeBatchSize
+	orchestrator.detokenizeFlushInterval = cfg.LLM.DetokenizeFlushInterval
+	if cache := cfg.LLM.SummaryCache; cache.Enabled {
+		orchestrator.summaries = lru.New[string, *cachedSummary]("llm_summaries", cache.MaxEntries, cache.TTL)
+	}
+	orchestrator.streamGuard = cfg.LLM.StreamGuard
+	orchestrator.streamRecovery = cfg.LLM.StreamRecovery
+	if pool := cfg.LLM.StreamPool; pool.Enabled {
+		orchestrator.streamPool = newStreamPool(orchestrator.ctx, orchestrator.inferenceClient, pool)
+	}
+	orchestrator.timeouts = cfg.LLM.Timeouts
+	if orchestrator.chatTemplates, err = lookupChatTemplates(cfg.LLM.ChatTemplates); err != nil {
+		return nil, err
+	}
+	if err := orchestrator.checkChatTemplates(orchestrator.ctx); err != nil {
+		return nil, err
+	}
 
 	// Start the orchestrator
 	orchestrator.Start()
 
-	// Start request cleanup
-	go service.cleanupOldRequests()
+	// Start reaping expired and leaked bookkeeping entries
+	go service.reapRequests()
 
 	return service, nil
 }
@@ -71,13 +153,16 @@ func NewLLMService(cfg *config.Config) (*LLMService, error) {
 func (s *LLMService) ProcessRequest(ctx context.Context, req *pb.LLMRequest) (*pb.LLMResponse, error) {
 	log := logger.GetLogger()
 	start := time.Now()
+	// Shadow traffic is recorded apart so it does not skew user-facing metrics
+	method := "process_request"
+	if req.Shadow {
+		method = "shadow_request"
+	}
 
 	log.Infof("Processing LLM request %s", req.Id)
 
 	// Check if request already exists
-	s.requestsMutex.Lock()
-	if _, exists := s.activeRequests[req.Id]; exists {
-		s.requestsMutex.Unlock()
+	if s.requestExists(req.Id) {
 		return &pb.LLMResponse{
 			Id:       req.Id,
 			Error:    "Request ID already exists",
@@ -85,49 +170,25 @@ func (s *LLMService) ProcessRequest(ctx context.Context, req *pb.LLMRequest) (*p
 		}, nil
 	}
 
-	// Create request tracker
-	tracker := &RequestTracker{
-		RequestID: req.Id,
-		Status:    "pending",
-		CreatedAt: time.Now(),
-	}
-	s.activeRequests[req.Id] = tracker
-	s.requestsMutex.Unlock()
-
 	// Convert proto request to internal request
-	llmReq := &LLMRequest{
-		ID:        req.Id,
-		Text:      req.Text,
-		MaxTokens: req.MaxTokens,
-		Stream:    req.Stream,
-		CreatedAt: time.Unix(req.CreatedAt, 0),
-	}
+	llmReq := s.newLLMRequest(ctx, req)
 
 	// Process the request directly via orchestrator
 	result, err := s.orchestrator.ProcessRequest(llmReq)
 	if err != nil {
-		s.requestsMutex.Lock()
-		tracker.Status = "failed"
-		tracker.Error = err.Error()
-		s.requestsMutex.Unlock()
-
 		log.Errorf("Failed to process request %s: %v", req.Id, err)
 		return &pb.LLMResponse{
-			Id:       req.Id,
-			Error:    fmt.Sprintf("Failed to process request: %v", err),
-			Complete: true,
+			Id:        req.Id,
+			Error:     fmt.Sprintf("Failed to process request: %v", err),
+			ErrorCode: errorCode(err),
+			Complete:  true,
 		}, nil
 	}
 
-	// Update tracker status
-	s.requestsMutex.Lock()
-	tracker.Status = "processing"
-	s.requestsMutex.Unlock()
-
 	// For non-streaming requests, return the result directly
 	if !req.Stream {
-		monitoring.RecordRequest("llm", "process_request", "success")
-		monitoring.RecordRequestDuration("llm", "process_request", time.Since(start))
+		monitoring.RecordRequest("llm", method, "success")
+		monitoring.RecordRequestDuration("llm", method, time.Since(start))
 		
 		return &pb.LLMResponse{
 			Id:       result.ID,
@@ -135,12 +196,16 @@ func (s *LLMService) ProcessRequest(ctx context.Context, req *pb.LLMRequest) (*p
 			Summary:  result.Summary,
 			Error:    result.Error,
 			Complete: result.Complete,
+			Budget:   llmReq.Budget.outcome(),
+
+			ModelConfidence: result.ModelConfidence,
+			InputTruncated:  result.InputTruncated,
 		}, nil
 	}
 
 	// For streaming requests, return immediately with pending status
-	monitoring.RecordRequest("llm", "process_request", "success")
-	monitoring.RecordRequestDuration("llm", "process_request", time.Since(start))
+	monitoring.RecordRequest("llm", method, "success")
+	monitoring.RecordRequestDuration("llm", method, time.Since(start))
 
 	return &pb.LLMResponse{
 		Id:       req.Id,
@@ -148,55 +213,55 @@ func (s *LLMService) ProcessRequest(ctx context.Context, req *pb.LLMRequest) (*p
 	}, nil
 }
 
+// newLLMRequest converts a request to the orchestrator's form; ctx carries
+// the subject the gateway forwarded and the caller's deadline
+func (s *LLMService) newLLMRequest(ctx context.Context, req *pb.LLMRequest) *LLMRequest {
+	deadline, _ := ctx.Deadline()
+	return &LLMRequest{
+		ID:        req.Id,
+		Text:      req.Text,
+		MaxTokens: req.MaxTokens,
+		Model:     req.Model,
+		Stream:    req.Stream,
+		CreatedAt: time.Unix(req.CreatedAt, 0),
+
+		Continuation: req.Continuation,
+		Budget:       newRequestBudget(s.config.Budget, req.PriorLlmCalls),
+		Sampling:     req.Sampling,
+		Subject:      flags.IncomingSubject(ctx),
+		Shadow:       req.Shadow,
+		Deadline:     deadline,
+	}
+}
+
 // GetStatus returns the status of a request
 func (s *LLMService) GetStatus(ctx context.Context, req *pb.LLMStatusRequest) (*pb.LLMStatusResponse, error) {
-	// First check local tracker
-	s.requestsMutex.RLock()
-	tracker, exists := s.activeRequests[req.RequestId]
-	s.requestsMutex.RUnlock()
+	// In-flight requests are tracked by the orchestrator
+	if processor, exists := s.orchestrator.GetRequestStatus(req.RequestId); exists {
+		stats := s.orchestrator.GetStats()
+		activeRequests, _ := stats["active_requests"].(int)
 
-	if !exists {
-		// Check orchestrator for active processing
-		processor, orchestratorExists := s.orchestrator.GetRequestStatus(req.RequestId)
-		if !orchestratorExists {
-			return &pb.LLMStatusResponse{
-				RequestId: req.RequestId,
-				Status:    "not_found",
-			}, nil
-		}
-		
-		// Use orchestrator status
 		return &pb.LLMStatusResponse{
 			RequestId:         req.RequestId,
 			Status:            processor.Status,
-			QueuePosition:     0, // No queue in direct processing
-			EstimatedWaitTime: 0,
-			Error:             "",
+			QueuePosition:     int32(activeRequests),
+			EstimatedWaitTime: int32(activeRequests * 10), // Rough estimate: 10 seconds per active request
 		}, nil
 	}
 
-	// Calculate position based on active requests
-	stats := s.orchestrator.GetStats()
-	activeRequests, _ := stats["active_requests"].(int)
-	maxConcurrent, _ := stats["max_concurrent"].(int)
-
-	var queuePosition int32
-	var estimatedWaitTime int32
-
-	if tracker.Status == "pending" || tracker.Status == "processing" {
-		queuePosition = int32(activeRequests)
-		// Rough estimate: 10 seconds per active request
-		if maxConcurrent > 0 {
-			estimatedWaitTime = int32(activeRequests * 10)
-		}
+	// Finished requests are kept until they expire
+	tracker, exists := s.finishedRequests.Get(req.RequestId)
+	if !exists {
+		return &pb.LLMStatusResponse{
+			RequestId: req.RequestId,
+			Status:    "not_found",
+		}, nil
 	}
 
 	return &pb.LLMStatusResponse{
-		RequestId:         req.RequestId,
-		Status:            tracker.Status,
-		QueuePosition:     queuePosition,
-		EstimatedWaitTime: estimatedWaitTime,
-		Error:             tracker.Error,
+		RequestId: req.RequestId,
+		Status:    tracker.Status,
+		Error:     tracker.Error,
 	}, nil
 }
 
@@ -214,6 +279,9 @@ func (s *LLMService) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest
 	if activeRequests >= maxConcurrent {
 		status = "overloaded"
 	}
+	if draining, _ := stats["draining"].(bool); draining {
+		status = "draining"
+	}
 
 	return &pb.HealthCheckResponse{
 		Status:    status,
@@ -222,56 +290,86 @@ func (s *LLMService) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest
 	}, nil
 }
 
-// cleanupOldRequests removes completed requests older than 1 hour
-func (s *LLMService) cleanupOldRequests() {
-	ticker := time.NewTicker(10 * time.Minute)
+// reapRequests periodically expires finished requests and reaps leaked entries
+func (s *LLMService) reapRequests() {
+	interval := s.config.LLM.ReapInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			s.requestsMutex.Lock()
-			cutoff := time.Now().Add(-1 * time.Hour)
-			var toDelete []string
-
-			for id, tracker := range s.activeRequests {
-				if tracker.Status == "completed" || tracker.Status == "failed" {
-					if tracker.CompletedAt != nil && tracker.CompletedAt.Before(cutoff) {
-						toDelete = append(toDelete, id)
-					}
-				}
-			}
+			s.reap()
+		case <-s.stop:
+			return
+		}
+	}
+}
 
-			for _, id := range toDelete {
-				delete(s.activeRequests, id)
-			}
+// reap frees finished requests older than RequestTTL, and closes streams and
+// force-finishes in-flight requests older than StreamTTL. The latter only
+// exist when a client vanished without the handler noticing, so they are
+// counted as leaked.
+func (s *LLMService) reap() {
+	now := time.Now()
 
-			if len(toDelete) > 0 {
-				logger.GetLogger().Infof("Cleaned up %d old LLM requests", len(toDelete))
-			}
-			s.requestsMutex.Unlock()
+	expired := s.finishedRequests.RemoveExpired()
+	finished := s.finishedRequests.Len()
+
+	s.streamMutex.Lock()
+	leakedStreams := 0
+	for id, entry := range s.streamingChans {
+		if now.Sub(entry.createdAt) > s.config.LLM.StreamTTL {
+			delete(s.streamingChans, id)
+			entry.close()
+			leakedStreams++
 		}
 	}
+	streams := len(s.streamingChans)
+	s.streamMutex.Unlock()
+
+	leakedRequests := s.orchestrator.reapStale(s.config.LLM.StreamTTL)
+
+	monitoring.RecordTrackedEntries("llm", "finished_request", finished)
+	monitoring.RecordTrackedEntries("llm", "stream_channel", streams)
+	monitoring.RecordLeakedEntries("llm", "stream_channel", leakedStreams)
+	monitoring.RecordLeakedEntries("llm", "active_request", leakedRequests)
+
+	if expired > 0 {
+		logger.GetLogger().Infof("Cleaned up %d old LLM requests", expired)
+	}
+	if leakedStreams > 0 || leakedRequests > 0 {
+		logger.GetLogger().Warnf("Reaped %d leaked streams and %d leaked requests older than %s",
+			leakedStreams, leakedRequests, s.config.LLM.StreamTTL)
+	}
 }
 
-// UpdateRequestStatus updates the status of a request (for non-streaming requests only)
-func (s *LLMService) UpdateRequestStatus(requestID string, status string, response *LLMResponse, err error) {
-	s.requestsMutex.Lock()
-	defer s.requestsMutex.Unlock()
+// requestExists reports whether the ID is in flight or recently finished
+func (s *LLMService) requestExists(requestID string) bool {
+	if _, exists := s.orchestrator.GetRequestStatus(requestID); exists {
+		return true
+	}
+	_, exists := s.finishedRequests.Get(requestID)
+	return exists
+}
 
-	if tracker, exists := s.activeRequests[requestID]; exists {
-		tracker.Status = status
-		if response != nil {
-			tracker.Response = response
-		}
-		if err != nil {
-			tracker.Error = err.Error()
-		}
-		if status == "completed" || status == "failed" {
-			now := time.Now()
-			tracker.CompletedAt = &now
-		}
+// UpdateRequestStatus records the outcome of a request leaving the orchestrator
+func (s *LLMService) UpdateRequestStatus(requestID string, status string, response *LLMResponse, err error) {
+	now := time.Now()
+	tracker := &RequestTracker{
+		RequestID:   requestID,
+		Status:      status,
+		CompletedAt: &now,
+		Response:    response,
 	}
+	if err != nil {
+		tracker.Error = err.Error()
+	}
+
+	s.finishedRequests.Add(requestID, tracker)
 }
 
 // StreamRequest handles streaming LLM requests
@@ -280,69 +378,67 @@ func (s *LLMService) StreamRequest(req *pb.LLMRequest, stream pb.LLMOrchestrator
 	log.Infof("Starting streaming request %s", req.Id)
 
 	// Create streaming channel
-	streamChan := make(chan *pb.LLMStreamResponse, 100)
+	entry := newStreamEntry()
 	s.streamMutex.Lock()
-	s.streamingChans[req.Id] = streamChan
+	s.streamingChans[req.Id] = entry
 	s.streamMutex.Unlock()
 
-	// Cleanup on exit
+	// Cleanup on exit; a client that went away also stops the processing
 	defer func() {
 		s.streamMutex.Lock()
-		delete(s.streamingChans, req.Id)
+		if s.streamingChans[req.Id] == entry {
+			delete(s.streamingChans, req.Id)
+		}
 		s.streamMutex.Unlock()
-		close(streamChan)
+		entry.close()
+		if stream.Context().Err() != nil {
+			s.orchestrator.CancelRequest(req.Id)
+		}
 	}()
 
 	// Start processing in background
 	go func() {
-		// Create request tracker
-		s.requestsMutex.Lock()
-		tracker := &RequestTracker{
-			RequestID: req.Id,
-			Status:    "processing",
-			CreatedAt: time.Now(),
+		// The client may have vanished before processing starts
+		select {
+		case <-entry.done:
+			return
+		default:
 		}
-		s.activeRequests[req.Id] = tracker
-		s.requestsMutex.Unlock()
 
 		// Convert proto request to internal request
-		llmReq := &LLMRequest{
-			ID:        req.Id,
-			Text:      req.Text,
-			MaxTokens: req.MaxTokens,
-			Stream:    true,
-			CreatedAt: time.Unix(req.CreatedAt, 0),
-		}
+		llmReq := s.newLLMRequest(stream.Context(), req)
+		llmReq.Stream = true
 
 		// Create callback function for streaming
 		streamCallback := func(requestID, token string, isFinal bool, position int32) {
-			streamChan <- &pb.LLMStreamResponse{
+			response := &pb.LLMStreamResponse{
 				Id:       requestID,
 				Token:    token,
 				IsFinal:  isFinal,
 				Position: position,
 			}
+			if isFinal {
+				llmReq.finalize(response)
+			}
+			entry.send(response)
 		}
 
 		// Process via orchestrator streaming method (direct, no ProcessRequest)
 		err := s.orchestrator.ProcessStreamingRequest(llmReq, streamCallback)
 		if err != nil {
-			streamChan <- &pb.LLMStreamResponse{
+			entry.send(&pb.LLMStreamResponse{
 				Id:      req.Id,
 				Token:   "",
 				IsFinal: true,
 				Error:   err.Error(),
-			}
+			})
 		}
 	}()
 
 	// Stream responses to client
 	for {
 		select {
-		case response, ok := <-streamChan:
-			if !ok {
-				return nil
-			}
+		case response := <-entry.ch:
 			
 			if err := stream.Send(response); err != nil {
 				log.Errorf("Failed to send stream response: %v", err)
@@ -356,6 +452,10 @@ func (s *LLMService) StreamRequest(req *pb.LLMRequest, stream pb.LLMOrchestrator
 		case <-stream.Context().Done():
 			log.Infof("Stream context cancelled for request %s", req.Id)
 			return stream.Context().Err()
+
+		case <-entry.done:
+			log.Warnf("Stream for request %s reaped after %s", req.Id, s.config.LLM.StreamTTL)
+			return status.Errorf(codes.DeadlineExceeded, "stream exceeded its %s lifetime", s.config.LLM.StreamTTL)
 		}
 	}
 }
@@ -367,9 +467,43 @@ func (s *LLMService) SendStreamChunk(requestID, token string, isFinal bool, posi
 	logger.GetLogger().Warnf("SendStreamChunk is deprecated - use streaming callbacks instead")
 }
 
+// TokenizerHealth checks the tokenizer service the orchestrator depends on
+func (s *LLMService) TokenizerHealth(ctx context.Context) (*pb.HealthCheckResponse, error) {
+	return s.orchestrator.tokenizerClient.HealthCheck(ctx, &pb.HealthCheckRequest{})
+}
+
+// InferenceHealth checks the inference service the orchestrator depends on
+func (s *LLMService) InferenceHealth(ctx context.Context) (*pb.HealthCheckResponse, error) {
+	return s.orchestrator.inferenceClient.HealthCheck(ctx, &pb.HealthCheckRequest{})
+}
+
+// Connections returns the orchestrator's gRPC client connections
+func (s *LLMService) Connections() map[string]*grpc.ClientConn {
+	return s.orchestrator.conns
+}
+
+// Stats counts what the service tracks, to spot requests or streams that
+// are never released
+func (s *LLMService) Stats() map[string]interface{} {
+	stats := s.orchestrator.GetStats()
+	stats["finished_requests"] = s.finishedRequests.Len()
+	s.streamMutex.RLock()
+	stats["open_streams"] = len(s.streamingChans)
+	s.streamMutex.RUnlock()
+	return stats
+}
+
+// Drain stops accepting requests ahead of the gRPC server's GracefulStop,
+// which waits for the streams still running; Stop cancels those
+func (s *LLMService) Drain() {
+	log.Println("Draining LLM service: refusing new requests")
+	s.orchestrator.Drain()
+}
+
 // Stop gracefully shuts down the service
 func (s *LLMService) Stop() {
 	log.Println("Stopping LLM service...")
+	s.stopOnce.Do(func() { close(s.stop) })
 	s.orchestrator.Stop()
 	log.Println("LLM service stopped")
 }
@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Actions of the control messages of a StreamSession
+const (
+	sessionCancel       = "cancel"
+	sessionSetMaxTokens = "set_max_tokens"
+	sessionRegenerate   = "regenerate"
+)
+
+// StreamSession streams a generation the client controls while it runs. The
+// first message starts it; later ones cancel it, cap its tokens or start it
+// over. A regeneration cancels the generation before it and numbers its
+// messages with the next generation, so the client knows to discard the
+// tokens it has shown.
+func (s *LLMService) StreamSession(stream pb.LLMOrchestratorService_StreamSessionServer) error {
+	log := logger.GetLogger()
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.Start == nil {
+		return status.Error(codes.InvalidArgument, "a session starts with a start message")
+	}
+	log.Infof("Starting stream session %s", first.Start.Id)
+
+	session := &streamSession{
+		service:   s,
+		start:     first.Start,
+		maxTokens: first.Start.MaxTokens,
+		responses: make(chan *pb.LLMStreamResponse, 100),
+		done:      make(chan struct{}),
+	}
+	defer close(session.done)
+	if err := session.generate(ctx); err != nil {
+		return session.fail(stream, err)
+	}
+
+	controls := make(chan *pb.LLMSessionMessage)
+	go func() {
+		defer close(controls)
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case controls <- msg:
+			case <-session.done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case response := <-session.responses:
+			if response.Generation != session.generation {
+				continue // the tail of a generation since replaced
+			}
+			if err := stream.Send(response); err != nil {
+				session.cancel()
+				return err
+			}
+			if response.IsFinal {
+				return nil
+			}
+
+		case msg, ok := <-controls:
+			if !ok {
+				// The client is done sending; the generation streams on
+				controls = nil
+				continue
+			}
+			switch msg.Action {
+			case sessionCancel:
+				monitoring.RecordSessionControl(msg.Action)
+				session.cancel()
+				log.Infof("Stream session %s cancelled by the client", first.Start.Id)
+				return stream.Send(&pb.LLMStreamResponse{Id: first.Start.Id, IsFinal: true, Generation: session.generation, Cancelled: true})
+			case sessionSetMaxTokens:
+				if msg.MaxTokens <= 0 {
+					session.cancel()
+					return status.Error(codes.InvalidArgument, "set_max_tokens needs max_tokens above 0")
+				}
+				monitoring.RecordSessionControl(msg.Action)
+				session.setMaxTokens(msg.MaxTokens)
+			case sessionRegenerate:
+				monitoring.RecordSessionControl(msg.Action)
+				session.cancel()
+				if err := session.generate(ctx); err != nil {
+					return session.fail(stream, err)
+				}
+			default:
+				session.cancel()
+				return status.Errorf(codes.InvalidArgument, "unknown session action %q", msg.Action)
+			}
+
+		case <-ctx.Done():
+			session.cancel()
+			return ctx.Err()
+		}
+	}
+}
+
+// streamSession is the state of one StreamSession; only its handler
+// goroutine uses it, the orchestrator's callbacks only send to responses
+type streamSession struct {
+	service    *LLMService
+	start      *pb.LLMRequest
+	maxTokens  int32
+	generation int32
+	current    *LLMRequest
+	responses  chan *pb.LLMStreamResponse
+	done       chan struct{} // closed when the handler returns
+}
+
+// generate starts the next generation. Regenerations run under their own
+// request ID, bypass the summary cache and spend another LLM call of the
+// request's budget.
+func (ss *streamSession) generate(ctx context.Context) error {
+	ss.generation++
+	generation := ss.generation
+
+	req := ss.service.newLLMRequest(ctx, ss.start)
+	req.Stream = true
+	req.MaxTokens = ss.maxTokens
+	req.tokenLimit = new(atomic.Int32)
+	if generation > 1 {
+		req.ID = fmt.Sprintf("%s-%d", ss.start.Id, generation)
+		req.Regenerated = true
+		req.Budget = newRequestBudget(ss.service.config.Budget, ss.start.PriorLlmCalls+generation-1)
+	}
+	ss.current = req
+
+	return ss.service.orchestrator.ProcessStreamingRequest(req, func(requestID, token string, isFinal bool, position int32) {
+		response := &pb.LLMStreamResponse{
+			Id:         ss.start.Id,
+			Token:      token,
+			IsFinal:    isFinal,
+			Position:   position,
+			Generation: generation,
+		}
+		if isFinal {
+			req.finalize(response)
+		}
+		select {
+		case ss.responses <- response:
+		case <-ss.done:
+		}
+	})
+}
+
+// setMaxTokens caps the tokens of the running generation, which ends once
+// it has streamed them, and of the regenerations after it
+func (ss *streamSession) setMaxTokens(maxTokens int32) {
+	ss.maxTokens = maxTokens
+	ss.current.tokenLimit.Store(maxTokens)
+}
+
+// cancel stops the running generation, freeing its slot in the orchestrator
+func (ss *streamSession) cancel() {
+	ss.service.orchestrator.CancelRequest(ss.current.ID)
+}
+
+// fail ends the session with a generation that could not start
+func (ss *streamSession) fail(stream pb.LLMOrchestratorService_StreamSessionServer, err error) error {
+	return stream.Send(&pb.LLMStreamResponse{Id: ss.start.Id, IsFinal: true, Generation: ss.generation, Error: err.Error()})
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"ai-search-service/internal/config"
+)
+
+// Reasons a stream is ended early, reported as its truncated_reason
+const (
+	truncatedRepetition = "repetition"
+	truncatedMaxLength  = "max_length"
+	truncatedMaxTokens  = "max_tokens" // a session lowered its max tokens
+)
+
+// streamGuard watches the text of one summary stream for output that has
+// degenerated: the same word or phrase repeated back to back, or a summary
+// longer than any the prompt asks for
+type streamGuard struct {
+	cfg     config.StreamGuardConfig
+	words   int      // complete words seen so far
+	tail    []string // the last MaxNGram*MaxRepeats of them, normalized
+	partial string   // text after the last space, a word still being generated
+}
+
+// newStreamGuard returns nil when the guard is disabled; a nil guard
+// observes nothing
+func newStreamGuard(cfg config.StreamGuardConfig) *streamGuard {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &streamGuard{cfg: cfg}
+}
+
+// observe adds streamed text, returning why the stream has to end or ""
+func (g *streamGuard) observe(text string) string {
+	if g == nil {
+		return ""
+	}
+	g.partial += text
+	end := strings.LastIndexFunc(g.partial, unicode.IsSpace)
+	if end < 0 {
+		return ""
+	}
+	_, size := utf8.DecodeRuneInString(g.partial[end:])
+	complete := strings.Fields(g.partial[:end])
+	g.partial = g.partial[end+size:]
+
+	for _, word := range complete {
+		g.words++
+		if g.cfg.MaxWords > 0 && g.words > g.cfg.MaxWords {
+			return truncatedMaxLength
+		}
+		g.push(strings.ToLower(strings.TrimFunc(word, unicode.IsPunct)))
+		if g.repeating() {
+			return truncatedRepetition
+		}
+	}
+	return ""
+}
+
+func (g *streamGuard) push(word string) {
+	window := g.cfg.MaxNGram * g.cfg.MaxRepeats
+	g.tail = append(g.tail, word)
+	if len(g.tail) > window {
+		g.tail = append(g.tail[:0], g.tail[len(g.tail)-window:]...)
+	}
+}
+
+// repeating reports whether the last words are one n-gram of up to MaxNGram
+// words repeated MaxRepeats times in a row
+func (g *streamGuard) repeating() bool {
+	if g.cfg.MaxRepeats < 2 {
+		return false
+	}
+	for n := 1; n <= g.cfg.MaxNGram; n++ {
+		span := n * g.cfg.MaxRepeats
+		if len(g.tail) < span {
+			break
+		}
+		run := g.tail[len(g.tail)-span:]
+		repeated := true
+		for i := n; i < span && repeated; i++ {
+			repeated = run[i] == run[i-n]
+		}
+		if repeated {
+			return true
+		}
+	}
+	return false
+}
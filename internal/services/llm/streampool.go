@@ -0,0 +1,315 @@
+package llm
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// streamPool keeps inference sessions open and ready for summary streams
+// to claim, saving them the stream setup. A session serves one summary.
+// Claimed sessions are replaced in the background; idle ones that broke
+// or expired are evicted on each check. After sessions fail to open, the
+// pool waits before opening more, twice as long each time up to max_backoff.
+type streamPool struct {
+	client pb.InferenceServiceClient
+	cfg    config.StreamPoolConfig
+	ctx    context.Context // the orchestrator's, cancelled when the service stops; sessions end with it
+
+	mu          sync.Mutex
+	idle        []*pooledStream // most recently opened last
+	opening     int
+	unsupported bool      // the inference service has no sessions
+	failures    int       // consecutive rounds of opens that failed
+	retryAt     time.Time // no sessions are opened before it
+}
+
+// pooledStream is a ready session. A reader receives its messages from
+// when it opens, so a session that breaks while idle is noticed.
+type pooledStream struct {
+	pb.InferenceService_SummarizeSessionClient
+	ctx    context.Context
+	cancel context.CancelFunc
+	opened time.Time
+
+	messages chan *pb.SummarizeStreamResponse
+	ended    chan struct{} // closed once the stream ends, with err
+	err      error
+}
+
+func newStreamPool(ctx context.Context, client pb.InferenceServiceClient, cfg config.StreamPoolConfig) *streamPool {
+	return &streamPool{client: client, cfg: cfg, ctx: ctx}
+}
+
+// openInferenceStream starts the summary stream of req, on a ready
+// session from the pool when there is one
+func (o *LLMOrchestrator) openInferenceStream(ctx context.Context, req *pb.SummarizeRequest) (pb.InferenceService_SummarizeStreamClient, error) {
+	if o.streamPool != nil {
+		if stream := o.streamPool.claim(ctx, req); stream != nil {
+			return stream, nil
+		}
+	}
+	return o.inferenceClient.SummarizeStream(ctx, req)
+}
+
+// run fills the pool, then evicts and refills it every check interval
+// until the orchestrator stops
+func (p *streamPool) run() {
+	p.fill()
+	ticker := time.NewTicker(p.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			p.mu.Lock()
+			p.idle = nil
+			p.mu.Unlock()
+			monitoring.RecordStreamPool(0)
+			return
+		case <-ticker.C:
+			p.evict()
+			p.fill()
+		}
+	}
+}
+
+// claim starts the summary of req on a ready session, tied to ctx as a
+// stream opened with it would be; nil when no session is ready. A session
+// that breaks before its first message is replaced by a stream of its own.
+func (p *streamPool) claim(ctx context.Context, req *pb.SummarizeRequest) pb.InferenceService_SummarizeStreamClient {
+	p.mu.Lock()
+	unsupported := p.unsupported
+	p.mu.Unlock()
+	if unsupported {
+		return nil
+	}
+	defer p.fill()
+	for {
+		s := p.take()
+		if s == nil {
+			monitoring.RecordStreamPoolClaim("miss")
+			return nil
+		}
+		if err := s.Send(req); err != nil {
+			s.cancel()
+			monitoring.RecordStreamPoolEviction("broken")
+			continue
+		}
+		s.CloseSend()
+		stop := context.AfterFunc(ctx, s.cancel)
+		go func() {
+			<-s.ended
+			stop()
+			s.cancel()
+		}()
+		monitoring.RecordStreamPoolClaim("hit")
+		return &claimedStream{InferenceService_SummarizeStreamClient: s, ctx: ctx, req: req, client: p.client}
+	}
+}
+
+// claimedStream is the summary stream of a claimed session. A session can
+// break between being taken and the summary starting, as when the
+// inference service restarts; the summary is then retried once on a stream
+// of its own, which is only safe while no message has been received.
+type claimedStream struct {
+	pb.InferenceService_SummarizeStreamClient // the session, or the stream that replaced it
+	ctx                                       context.Context
+	req                                       *pb.SummarizeRequest
+	client                                    pb.InferenceServiceClient
+	started                                   bool // a message was received, or the summary was retried
+}
+
+func (s *claimedStream) Recv() (*pb.SummarizeStreamResponse, error) {
+	msg, err := s.InferenceService_SummarizeStreamClient.Recv()
+	if err == nil || s.started || s.ctx.Err() != nil {
+		s.started = true
+		return msg, err
+	}
+	s.started = true
+	if code := status.Code(err); code != codes.Unavailable && code != codes.Canceled {
+		return nil, err
+	}
+	log.Printf("Claimed inference session broke before the summary started, opening a stream instead: %v", err)
+	monitoring.RecordStreamPoolClaim("retried")
+	stream, retryErr := s.client.SummarizeStream(s.ctx, s.req)
+	if retryErr != nil {
+		return nil, retryErr
+	}
+	s.InferenceService_SummarizeStreamClient = stream
+	return stream.Recv()
+}
+
+// take removes the most recently opened session that has not broken
+func (p *streamPool) take() *pooledStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer func() { monitoring.RecordStreamPool(len(p.idle)) }()
+	for len(p.idle) > 0 {
+		s := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		select {
+		case <-s.ended:
+			s.cancel()
+			monitoring.RecordStreamPoolEviction("broken")
+			continue
+		default:
+			return s
+		}
+	}
+	return nil
+}
+
+// evict closes idle sessions that broke or have been idle over max_idle
+func (p *streamPool) evict() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := p.idle[:0]
+	for _, s := range p.idle {
+		select {
+		case <-s.ended:
+			s.cancel()
+			monitoring.RecordStreamPoolEviction("broken")
+			continue
+		default:
+		}
+		if p.cfg.MaxIdle > 0 && time.Since(s.opened) > p.cfg.MaxIdle {
+			s.cancel()
+			monitoring.RecordStreamPoolEviction("expired")
+			continue
+		}
+		kept = append(kept, s)
+	}
+	p.idle = kept
+	monitoring.RecordStreamPool(len(p.idle))
+}
+
+func (p *streamPool) interval() time.Duration {
+	if p.cfg.CheckInterval <= 0 {
+		return 5 * time.Second
+	}
+	return p.cfg.CheckInterval
+}
+
+// backoff is how long to wait after failures consecutive rounds of opens
+// failed: the check interval, doubled for each round after the first
+func (p *streamPool) backoff() time.Duration {
+	limit := p.cfg.MaxBackoff
+	if limit <= 0 {
+		limit = time.Minute
+	}
+	wait := p.interval()
+	for n := 1; n < p.failures && wait < limit; n++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
+
+// fill opens sessions in the background until the pool has its size,
+// unless it is backing off after failed opens
+func (p *streamPool) fill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().Before(p.retryAt) {
+		return
+	}
+	for !p.unsupported && p.ctx.Err() == nil && len(p.idle)+p.opening < p.cfg.Size {
+		p.opening++
+		go p.open()
+	}
+}
+
+// open opens a session and adds it to the pool once the inference service
+// reports it ready
+func (p *streamPool) open() {
+	s, err := p.openSession()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.opening--
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		if !p.unsupported {
+			log.Printf("Inference service has no summary sessions; opening a stream per request")
+		}
+		p.unsupported = true
+	case p.ctx.Err() != nil:
+		if s != nil {
+			s.cancel()
+		}
+	case err != nil:
+		// The opens of a round fail together; the first sets the backoff
+		if time.Now().Before(p.retryAt) {
+			return
+		}
+		p.failures++
+		wait := p.backoff()
+		p.retryAt = time.Now().Add(wait)
+		log.Printf("Failed to open an inference session for the pool, retrying in %v: %v", wait, err)
+	default:
+		p.failures, p.retryAt = 0, time.Time{}
+		p.idle = append(p.idle, s)
+		monitoring.RecordStreamPool(len(p.idle))
+	}
+}
+
+func (p *streamPool) openSession() (*pooledStream, error) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	stream, err := p.client.SummarizeSession(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if !first.SessionReady {
+		cancel()
+		return nil, status.Error(codes.Internal, "inference session opened without reporting ready")
+	}
+	s := &pooledStream{
+		InferenceService_SummarizeSessionClient: stream,
+		ctx:                                     ctx,
+		cancel:                                  cancel,
+		opened:                                  time.Now(),
+		messages:                                make(chan *pb.SummarizeStreamResponse),
+		ended:                                   make(chan struct{}),
+	}
+	go s.read()
+	return s, nil
+}
+
+// read forwards the session's messages until it ends
+func (s *pooledStream) read() {
+	for {
+		msg, err := s.InferenceService_SummarizeSessionClient.Recv()
+		if err != nil {
+			s.err = err
+			close(s.ended)
+			return
+		}
+		select {
+		case s.messages <- msg:
+		case <-s.ctx.Done():
+			// the summary was abandoned; Recv now fails with the cancellation
+		}
+	}
+}
+
+// Recv returns the next message of the summary, as a stream's Recv does
+func (s *pooledStream) Recv() (*pb.SummarizeStreamResponse, error) {
+	select {
+	case msg := <-s.messages:
+		return msg, nil
+	case <-s.ended:
+		return nil, s.err
+	}
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"ai-search-service/internal/monitoring"
+)
+
+// summaryPromptVersion is part of every summary cache key; bump it when
+// the prompt templates or how the tokenizer assembles them change, so that
+// summaries of the old prompts are not served
+const summaryPromptVersion = 2
+
+// cachedSummary is a summary kept for the requests whose prompt and
+// parameters hash to the same key
+type cachedSummary struct {
+	Summary         string
+	ModelConfidence float32
+	InputTruncated  bool
+}
+
+// summaryCacheKey hashes what determines a request's summary. ok is false
+// when the request is not cached: the cache is off, the request continues
+// an interrupted summary or regenerates one, or it is shadow traffic
+// comparing backends.
+func (o *LLMOrchestrator) summaryCacheKey(req *LLMRequest) (key string, ok bool) {
+	if o.summaries == nil || req.Continuation != "" || req.Regenerated || req.Shadow {
+		return "", false
+	}
+	sampling, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.Sampling)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	for _, part := range []string{req.model(), string(sampling), o.renderPrompt(req)} {
+		binary.Write(h, binary.BigEndian, uint32(len(part)))
+		h.Write([]byte(part))
+	}
+	binary.Write(h, binary.BigEndian, int32(summaryPromptVersion))
+	binary.Write(h, binary.BigEndian, req.MaxTokens)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// lookupSummary returns the summary cached under key, recording the lookup
+func (o *LLMOrchestrator) lookupSummary(key string) (*cachedSummary, bool) {
+	summary, ok := o.summaries.Get(key)
+	if ok {
+		monitoring.RecordSummaryCache("hit")
+	} else {
+		monitoring.RecordSummaryCache("miss")
+	}
+	return summary, ok
+}
+
+// storeSummary caches a completed summary; empty summaries are not kept
+func (o *LLMOrchestrator) storeSummary(key, summary string, confidence float32, inputTruncated bool) {
+	if strings.TrimSpace(summary) == "" {
+		return
+	}
+	o.summaries.Add(key, &cachedSummary{Summary: summary, ModelConfidence: confidence, InputTruncated: inputTruncated})
+}
+
+// replaySummary streams a cached summary word by word, as the backend
+// would have generated it, then ends the stream
+func replaySummary(req *LLMRequest, summary *cachedSummary, streamCallback func(string, string, bool, int32)) {
+	for position, token := range strings.SplitAfter(summary.Summary, " ") {
+		if token != "" {
+			streamCallback(req.ID, token, false, int32(position))
+		}
+	}
+	req.ModelConfidence = summary.ModelConfidence
+	req.InputTruncated = summary.InputTruncated
+	streamCallback(req.ID, "", true, 0)
+}
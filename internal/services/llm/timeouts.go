@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ai-search-service/internal/monitoring"
+)
+
+// Stages of an orchestrator request with a timeout of their own
+const (
+	stageTokenize   = "tokenize"
+	stageInference  = "inference"
+	stageDetokenize = "detokenize"
+)
+
+// timeoutError is the failure of a request that ran out of time; its code
+// tells which stage stalled
+type timeoutError struct {
+	code string // <stage>_timeout, or request_timeout
+	err  error
+}
+
+func (e *timeoutError) Error() string { return e.code + ": " + e.err.Error() }
+
+func (e *timeoutError) Unwrap() error { return e.err }
+
+// errorCode is the code reported with a request's failure, "" unless it ran
+// out of time
+func errorCode(err error) string {
+	var timeout *timeoutError
+	if errors.As(err, &timeout) {
+		return timeout.code
+	}
+	return ""
+}
+
+// requestContext bounds a request by llm.timeouts.request, or by its
+// caller's deadline when that is sooner
+func (o *LLMOrchestrator) requestContext(req *LLMRequest) (context.Context, context.CancelFunc) {
+	deadline := req.Deadline
+	if timeout := o.timeouts.Request; timeout > 0 {
+		if own := time.Now().Add(timeout); deadline.IsZero() || own.Before(deadline) {
+			deadline = own
+		}
+	}
+	if deadline.IsZero() {
+		return context.WithCancel(o.ctx)
+	}
+	return context.WithDeadline(o.ctx, deadline)
+}
+
+// stageContext bounds a stage of the request running under ctx by the
+// stage's timeout; what is left of the request's time bounds it anyway
+func (o *LLMOrchestrator) stageContext(ctx context.Context, stage string) (context.Context, context.CancelFunc) {
+	var timeout time.Duration
+	switch stage {
+	case stageTokenize:
+		timeout = o.timeouts.Tokenize
+	case stageInference:
+		timeout = o.timeouts.Inference
+	case stageDetokenize:
+		timeout = o.timeouts.Detokenize
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// stageError returns the error of a stage that ran under ctx, as a timeout
+// if ctx ran out of time
+func stageError(requestCtx, ctx context.Context, stage string, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return timedOut(requestCtx, stage, err)
+}
+
+// timedOut records a stage that ran out of time and codes its error by the
+// stage, or by the request when it was the request's deadline that passed
+func timedOut(requestCtx context.Context, stage string, err error) error {
+	if errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+		stage = "request"
+	}
+	monitoring.RecordLLMTimeout(stage)
+	return &timeoutError{code: stage + "_timeout", err: err}
+}
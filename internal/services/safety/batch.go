@@ -0,0 +1,95 @@
+package safety
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// BatchValidateInput validates each text as ValidateInput does, under the
+// batch's client, safe search setting and policy
+func (s *SafetyService) BatchValidateInput(ctx context.Context, req *pb.BatchValidateInputRequest) (*pb.BatchValidateInputResponse, error) {
+	if err := s.checkBatch(len(req.Texts)); err != nil {
+		return nil, err
+	}
+	req.ClientIp = s.ips.IP(req.ClientIp)
+	results := make([]*pb.ValidateInputResponse, len(req.Texts))
+	err := s.each(ctx, len(req.Texts), func(i int) {
+		results[i] = s.validateInput(&pb.ValidateInputRequest{
+			Text:       req.Texts[i],
+			ClientIp:   req.ClientIp,
+			SafeSearch: req.SafeSearch,
+			Policy:     req.Policy,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unsafe := 0
+	for _, result := range results {
+		if !result.IsSafe {
+			unsafe++
+		}
+	}
+	logger.GetLogger().Infof("Validated %d texts from %s, %d unsafe", len(results), req.ClientIp, unsafe)
+	return &pb.BatchValidateInputResponse{Results: results}, nil
+}
+
+// BatchSanitizeOutput sanitizes each text as SanitizeOutput does, under the
+// batch's policy
+func (s *SafetyService) BatchSanitizeOutput(ctx context.Context, req *pb.BatchSanitizeOutputRequest) (*pb.BatchSanitizeOutputResponse, error) {
+	if err := s.checkBatch(len(req.Texts)); err != nil {
+		return nil, err
+	}
+	results := make([]*pb.SanitizeOutputResponse, len(req.Texts))
+	err := s.each(ctx, len(req.Texts), func(i int) {
+		results[i] = s.sanitizeOutput(&pb.SanitizeOutputRequest{Text: req.Texts[i], Policy: req.Policy})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changed := 0
+	for i, result := range results {
+		if result.SanitizedText != req.Texts[i] {
+			changed++
+		}
+	}
+	logger.GetLogger().Infof("Sanitized %d texts, %d changed", len(results), changed)
+	return &pb.BatchSanitizeOutputResponse{Results: results}, nil
+}
+
+// checkBatch rejects batches over safety.max_batch_size
+func (s *SafetyService) checkBatch(n int) error {
+	if limit := s.config.Safety.MaxBatchSize; limit > 0 && n > limit {
+		return status.Errorf(codes.InvalidArgument, "batch of %d texts exceeds the limit of %d", n, limit)
+	}
+	return nil
+}
+
+// each calls check for 0 to n-1, safety.batch_workers at a time, and stops
+// starting calls once the context ends
+func (s *SafetyService) each(ctx context.Context, n int, check func(i int)) error {
+	slots := make(chan struct{}, max(s.config.Safety.BatchWorkers, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for i := 0; i < n; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-slots; wg.Done() }()
+			check(i)
+		}(i)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package safety
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// patternSet is a category of patterns compiled for one pass over a text:
+// its plain keywords into an Aho-Corasick automaton and its other patterns
+// into a single regexp. A category of dozens of patterns then costs one
+// scan instead of one per pattern.
+type patternSet struct {
+	keywords *keywordMatcher // nil without keywords
+	regex    *regexp.Regexp  // nil without other patterns
+	// phrase is set when the regexp's first group is the match, leaving out
+	// the boundary characters around a phrase
+	phrase bool
+	// leet is set for content patterns, which also match the text with leet
+	// spellings undone
+	leet bool
+}
+
+// newPatternSet compiles case-insensitive keywords, matched on word
+// boundaries like \b, and regular expressions
+func newPatternSet(keywords []string, patterns []string) *patternSet {
+	set := &patternSet{}
+	if len(keywords) > 0 {
+		set.keywords = newKeywordMatcher(keywords)
+	}
+	if len(patterns) > 0 {
+		alternatives := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			alternatives[i] = "(?:" + pattern + ")"
+		}
+		set.regex = regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+	}
+	return set
+}
+
+// empty reports whether the set has no patterns
+func (p *patternSet) empty() bool {
+	return p == nil || (p.keywords == nil && p.regex == nil)
+}
+
+// contentSet compiles content keywords, which also match with leet
+// spellings and masked vowels
+func contentSet(keywords []string) *patternSet {
+	set := newPatternSet(maskedVariants(keywords), nil)
+	set.leet = true
+	return set
+}
+
+// match reports whether any of the patterns occurs in the normalized text
+func (p *patternSet) match(v *textView) bool {
+	if p.empty() {
+		return false
+	}
+	for _, form := range v.forms(p.leet) {
+		if (p.keywords != nil && p.keywords.match(form.text)) || (p.regex != nil && p.regex.MatchString(form.text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// spans returns where the patterns occur in the normalized text, as spans of
+// the original, ordered and not overlapping; of overlapping matches the
+// earliest and then longest is kept
+func (p *patternSet) spans(v *textView) [][]int {
+	if p.empty() {
+		return nil
+	}
+	var spans [][]int
+	for _, form := range v.forms(p.leet) {
+		var found [][]int
+		if p.keywords != nil {
+			found = p.keywords.find(form.text)
+		}
+		if p.regex != nil {
+			for _, match := range p.regex.FindAllStringSubmatchIndex(form.text, -1) {
+				if p.phrase && len(match) >= 4 && match[2] >= 0 {
+					found = append(found, match[2:4])
+				} else {
+					found = append(found, match[:2])
+				}
+			}
+		}
+		for _, span := range found {
+			spans = append(spans, form.span(span[0], span[1]))
+		}
+	}
+	return disjoint(spans)
+}
+
+// disjoint sorts spans and drops those overlapping an earlier one
+func disjoint(spans [][]int) [][]int {
+	if len(spans) < 2 {
+		return spans
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i][0] != spans[j][0] {
+			return spans[i][0] < spans[j][0]
+		}
+		return spans[i][1] > spans[j][1]
+	})
+	out := spans[:1]
+	for _, span := range spans[1:] {
+		if span[0] >= out[len(out)-1][1] {
+			out = append(out, span)
+		}
+	}
+	return out
+}
+
+// keywordMatcher finds keywords with an Aho-Corasick automaton, folding
+// ASCII case so offsets in the text are kept. Keywords match on word
+// boundaries as regexp's \b defines them.
+type keywordMatcher struct {
+	classes  [256]uint16 // byte to input class; 0 for bytes in no keyword
+	nclasses int
+	next     []int32 // next[state*nclasses+class], complete transitions
+	out      [][]int // lengths of the keywords ending in each state
+}
+
+func newKeywordMatcher(keywords []string) *keywordMatcher {
+	m := &keywordMatcher{nclasses: 1}
+	for _, keyword := range keywords {
+		for i := 0; i < len(keyword); i++ {
+			c := lowerASCII(keyword[i])
+			if m.classes[c] == 0 {
+				m.classes[c] = uint16(m.nclasses)
+				m.nclasses++
+			}
+		}
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		m.classes[c] = m.classes[c+'a'-'A']
+	}
+
+	// The trie, with -1 for missing edges
+	m.next = make([]int32, m.nclasses)
+	m.out = [][]int{nil}
+	for i := range m.next {
+		m.next[i] = -1
+	}
+	for _, keyword := range keywords {
+		if keyword == "" {
+			continue
+		}
+		state := int32(0)
+		for i := 0; i < len(keyword); i++ {
+			edge := int(state)*m.nclasses + int(m.classes[keyword[i]])
+			if m.next[edge] < 0 {
+				m.next[edge] = int32(len(m.out))
+				m.out = append(m.out, nil)
+				for j := 0; j < m.nclasses; j++ {
+					m.next = append(m.next, -1)
+				}
+			}
+			state = m.next[edge]
+		}
+		m.out[state] = append(m.out[state], len(keyword))
+	}
+
+	// Failure links, breadth first, turning the trie into a complete
+	// automaton and merging each state's outputs with its suffix's
+	fail := make([]int32, len(m.out))
+	var queue []int32
+	for c := 0; c < m.nclasses; c++ {
+		if child := m.next[c]; child > 0 {
+			queue = append(queue, child)
+		} else {
+			m.next[c] = 0
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.out[state] = append(m.out[state], m.out[fail[state]]...)
+		for c := 0; c < m.nclasses; c++ {
+			edge := int(state)*m.nclasses + c
+			fallback := m.next[int(fail[state])*m.nclasses+c]
+			if child := m.next[edge]; child >= 0 {
+				fail[child] = fallback
+				queue = append(queue, child)
+			} else {
+				m.next[edge] = fallback
+			}
+		}
+	}
+	return m
+}
+
+// scan calls found with the span of each keyword occurrence on word
+// boundaries, in order of their ends, until it returns false
+func (m *keywordMatcher) scan(text string, found func(start, end int) bool) {
+	state := 0
+	for i := 0; i < len(text); i++ {
+		state = int(m.next[state*m.nclasses+int(m.classes[text[i]])])
+		for _, n := range m.out[state] {
+			start, end := i+1-n, i+1
+			if wordBoundary(text, start) && wordBoundary(text, end) && !found(start, end) {
+				return
+			}
+		}
+	}
+}
+
+func (m *keywordMatcher) match(text string) bool {
+	matched := false
+	m.scan(text, func(start, end int) bool {
+		matched = true
+		return false
+	})
+	return matched
+}
+
+func (m *keywordMatcher) find(text string) [][]int {
+	var spans [][]int
+	m.scan(text, func(start, end int) bool {
+		spans = append(spans, []int{start, end})
+		return true
+	})
+	return spans
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// isWordByte is regexp's \w: ASCII letters, digits and underscore
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// wordBoundary reports whether \b matches at offset i of the text
+func wordBoundary(text string, i int) bool {
+	before := i > 0 && isWordByte(text[i-1])
+	after := i < len(text) && isWordByte(text[i])
+	return before != after
+}
@@ -0,0 +1,169 @@
+package safety
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// benchQueries are typical search queries, some of which a filter matches
+var benchQueries = []string{
+	"how do vaccines train the immune system",
+	"best hiking trails near lake tahoe in october",
+	"golang generics type inference rules",
+	"symptoms of heroin withdrawal and treatment options",
+	"history of the roman empire's decline",
+	"why does my sourdough starter smell like acetone",
+	"is it illegal to fly a drone over a national park",
+	"compare index funds and etfs for retirement",
+}
+
+// benchSummary is a summary of typical length with a few filtered words
+var benchSummary = strings.Repeat("The results describe how the immune system learns to recognize "+
+	"pathogens, why booster doses matter and what researchers found about long-term protection. "+
+	"One source calls the old advice stupid, another covers the illegal trade in counterfeit doses. ", 4)
+
+// inappropriateGroups are the inappropriate-content keywords as they were
+// grouped into regexps
+var inappropriateGroups = [][]string{
+	{"hack", "crack", "exploit", "malware", "virus", "trojan"},
+	{"illegal", "piracy", "torrent", "torrents"},
+	{"drug", "drugs", "cocaine", "heroin", "marijuana"},
+	{"adult", "porn", "sex", "xxx"},
+	{"violence", "kill", "murder", "bomb"},
+	{"fuck", "shit", "damn", "bitch", "ass", "crap"},
+	{"wtf", "what the fuck", "fucking", "fucked"},
+	{"hell", "goddamn", "jesus christ"},
+	{"stupid", "idiot", "moron", "retard"},
+	{"hate", "racist", "nazi", "terrorist"},
+}
+
+// regexScan is how the filters used to run: every pattern compiled on its
+// own and tried in turn
+type regexScan struct {
+	dangerous, inappropriate, sql, cmd []*regexp.Regexp
+}
+
+func newRegexScan() *regexScan {
+	compile := func(patterns []string, groups [][]string) []*regexp.Regexp {
+		var compiled []*regexp.Regexp
+		for _, pattern := range patterns {
+			compiled = append(compiled, regexp.MustCompile(`(?i)`+pattern))
+		}
+		for _, group := range groups {
+			compiled = append(compiled, regexp.MustCompile(`(?i)\b(`+strings.Join(group, "|")+`)\b`))
+		}
+		return compiled
+	}
+	return &regexScan{
+		dangerous:     compile(dangerousPatterns, nil),
+		inappropriate: compile(nil, inappropriateGroups),
+		sql:           compile(sqlPatterns, sqlKeywords),
+		cmd:           compile(cmdPatterns, cmdKeywords),
+	}
+}
+
+func anyMatch(patterns []*regexp.Regexp, text string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate is the pattern checks of ValidateInput
+func (r *regexScan) validate(text string) bool {
+	return anyMatch(r.dangerous, text) || anyMatch(r.sql, text) || anyMatch(r.cmd, text) || anyMatch(r.inappropriate, text)
+}
+
+// sanitize is the pattern filtering of SanitizeOutput
+func (r *regexScan) sanitize(text string) string {
+	for _, pattern := range r.dangerous {
+		text = pattern.ReplaceAllString(text, "[FILTERED]")
+	}
+	for _, pattern := range r.inappropriate {
+		text = pattern.ReplaceAllString(text, "[CONTENT FILTERED]")
+	}
+	return text
+}
+
+// setScan runs the same checks with the compiled pattern sets
+type setScan struct {
+	dangerous, inappropriate, sql, cmd *patternSet
+}
+
+func newSetScan() *setScan {
+	return &setScan{
+		dangerous:     newPatternSet(nil, dangerousPatterns),
+		inappropriate: contentSet(categoryKeywords(inappropriateKeywords)),
+		sql:           newPatternSet(flatten(sqlKeywords), sqlPatterns),
+		cmd:           newPatternSet(flatten(cmdKeywords), cmdPatterns),
+	}
+}
+
+func (s *setScan) validate(text string) bool {
+	v := newView(text)
+	return s.dangerous.match(v) || s.sql.match(v) || s.cmd.match(v) || s.inappropriate.match(v)
+}
+
+func (s *setScan) sanitize(text string) string {
+	text, _ = noPolicy.replace(s.dangerous, text, "[FILTERED]")
+	text, _ = noPolicy.replace(s.inappropriate, text, "[CONTENT FILTERED]")
+	return text
+}
+
+// scanner is the pattern checks of ValidateInput and the pattern filtering
+// of SanitizeOutput
+type scanner interface {
+	validate(string) bool
+	sanitize(string) string
+}
+
+// TestMatcherMatchesRegexScan checks that the pattern sets decide and filter
+// like the per-pattern regexes they replace
+func TestMatcherMatchesRegexScan(t *testing.T) {
+	before, after := newRegexScan(), newSetScan()
+	typical := append(append([]string(nil), benchQueries...), benchSummary)
+	for _, sample := range append(typical, "<script>alert(1)</script>", "x onload = y", "DROP table users",
+		"rm -rf /", "Hell, WHAT THE FUCK", "a--b", "sexy", "classic") {
+		if before.validate(sample) != after.validate(sample) {
+			t.Errorf("verdicts differ for %q", sample)
+		}
+		// Where matches overlap the sets filter the longest, so the texts
+		// may differ, but neither leaves anything a pattern matches
+		if got := after.sanitize(sample); anyMatch(before.dangerous, got) || anyMatch(before.inappropriate, got) {
+			t.Errorf("filtered text %q of %q still matches", got, sample)
+		}
+	}
+	for _, sample := range typical {
+		if want, got := before.sanitize(sample), after.sanitize(sample); want != got {
+			t.Errorf("filtered text differs for %q:\n%q\n%q", sample, want, got)
+		}
+	}
+}
+
+// benchmarkScan reports the time scan takes to validate a query and to
+// filter a summary
+func benchmarkScan(b *testing.B, scan scanner) {
+	b.Run("validate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			scan.validate(benchQueries[i%len(benchQueries)])
+		}
+	})
+	b.Run("sanitize", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			scan.sanitize(benchSummary)
+		}
+	})
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	benchmarkScan(b, newSetScan())
+}
+
+func BenchmarkRegexScan(b *testing.B) {
+	benchmarkScan(b, newRegexScan())
+}
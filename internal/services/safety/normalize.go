@@ -0,0 +1,204 @@
+package safety
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Filters match a normalized form of the text so that look-alike spellings
+// cannot slip past them:
+//
+//   - invisible format characters such as zero-width spaces and joiners are
+//     dropped
+//   - characters are decomposed for compatibility, as NFKC does, and
+//     combining marks dropped: fullwidth and styled letters become plain
+//     ones and accents go
+//   - Cyrillic and Greek letters that look Latin become the Latin letter
+//   - content filters also match the text with leet spellings within words
+//     undone ("fr33" is "free"); attack patterns only see $, | and digits as
+//     written
+//
+// Matches are mapped back to the original text, so output filtering
+// replaces exactly the characters that spelled the match.
+
+// confusables maps Cyrillic and Greek letters to the Latin letters they are
+// drawn like
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c',
+	'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ї': 'i', 'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T',
+	'У': 'Y', 'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't',
+	'υ': 'u', 'χ': 'x', 'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M',
+	'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	// Latin look-alikes compatibility decomposition keeps
+	'ı': 'i', 'ɡ': 'g', 'ɑ': 'a',
+}
+
+// leet maps the digits and symbols written for letters. '*' stands for a
+// masked letter and is kept; content keywords are also matched with their
+// inner vowels masked.
+var leet = map[byte]byte{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't',
+}
+
+// normalized is a normalized text with, for each of its bytes, the span of
+// the original text it came from. Without a mapping it equals the original.
+type normalized struct {
+	text       string
+	start, end []int
+}
+
+// span maps a span of the normalized text to the original
+func (n *normalized) span(s, e int) []int {
+	if n.start == nil || e <= s {
+		return []int{s, e}
+	}
+	return []int{n.start[s], n.end[e-1]}
+}
+
+// textView is a text with its normalized forms, computed when first needed
+type textView struct {
+	text   string
+	plain  *normalized
+	folded *normalized // with leet undone; nil when the same as plain
+}
+
+func newView(text string) *textView {
+	return &textView{text: text}
+}
+
+// forms returns the normalized text, and for content patterns the text with
+// leet undone too when that differs
+func (v *textView) forms(withLeet bool) []*normalized {
+	if v.plain == nil {
+		v.plain = normalize(v.text)
+		if folded := undoLeet(v.plain.text); folded != v.plain.text {
+			v.folded = &normalized{text: folded, start: v.plain.start, end: v.plain.end}
+		}
+	}
+	if withLeet && v.folded != nil {
+		return []*normalized{v.plain, v.folded}
+	}
+	return []*normalized{v.plain}
+}
+
+func normalize(text string) *normalized {
+	if !needsNormalizing(text) {
+		return &normalized{text: text}
+	}
+	var b strings.Builder
+	start := make([]int, 0, len(text))
+	end := make([]int, 0, len(text))
+	emit := func(s string, from, to int) {
+		b.WriteString(s)
+		for i := 0; i < len(s); i++ {
+			start = append(start, from)
+			end = append(end, to)
+		}
+	}
+	var buf [utf8.UTFMax]byte
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		from, to := i, i+size
+		i = to
+		if r < utf8.RuneSelf {
+			emit(text[from:to], from, to)
+			continue
+		}
+		if unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		if c, ok := confusables[r]; ok {
+			emit(string(c), from, to)
+			continue
+		}
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			if c, ok := confusables[d]; ok {
+				d = c
+			}
+			n := utf8.EncodeRune(buf[:], d)
+			emit(string(buf[:n]), from, to)
+		}
+	}
+	return &normalized{text: b.String(), start: start, end: end}
+}
+
+// needsNormalizing reports whether the text has characters other than ASCII
+func needsNormalizing(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// undoLeet replaces leet characters by letters within words that contain a
+// letter, keeping numbers such as "2024" as written. The replacements are
+// single bytes, so offsets are kept.
+func undoLeet(text string) string {
+	if !strings.ContainsAny(text, "01345789@$!|+") {
+		return text
+	}
+	b := []byte(text)
+	for i := 0; i < len(b); {
+		if !leetWordByte(b[i]) {
+			i++
+			continue
+		}
+		j, letters := i, false
+		for ; j < len(b) && leetWordByte(b[j]); j++ {
+			if 'a' <= b[j] && b[j] <= 'z' || 'A' <= b[j] && b[j] <= 'Z' {
+				letters = true
+			}
+		}
+		if letters {
+			for k := i; k < j; k++ {
+				if c, ok := leet[b[k]]; ok {
+					b[k] = c
+				}
+			}
+		}
+		i = j
+	}
+	return string(b)
+}
+
+func leetWordByte(c byte) bool {
+	_, ok := leet[c]
+	return ok || c == '*' || isWordByte(c)
+}
+
+// maskedVariants adds each keyword with an inner vowel masked by '*', and
+// with all its inner vowels masked: "dr*gs", "f*ck", "c*c**n*"
+func maskedVariants(keywords []string) []string {
+	out := append([]string(nil), keywords...)
+	for _, keyword := range keywords {
+		all := []byte(keyword)
+		masked := 0
+		for i := 1; i < len(keyword)-1; i++ {
+			if !strings.ContainsRune("aeiou", rune(keyword[i])) {
+				continue
+			}
+			variant := []byte(keyword)
+			variant[i] = '*'
+			out = append(out, string(variant))
+			all[i] = '*'
+			masked++
+		}
+		if masked > 1 {
+			out = append(out, string(all))
+		}
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package safety
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// policyMatchers is a tenant's or key's safety policy, compiled
+type policyMatchers struct {
+	fingerprint string        // of the policy it was compiled from
+	blocked     []*patternSet // blocked terms, and blocked patterns
+	allowed     *patternSet   // exceptions to the inappropriate-content filters
+}
+
+// noPolicy matches nothing, for requests without a policy
+var noPolicy = &policyMatchers{}
+
+// matchers returns the compiled policy of a request, merging the blocked
+// terms sent outside a policy. Each policy ID keeps its last compilation,
+// which is reused until the policy's terms change.
+func (s *SafetyService) matchers(policy *pb.SafetyPolicy, blockedTerms []string) *policyMatchers {
+	if policy == nil {
+		policy = &pb.SafetyPolicy{}
+	}
+	terms := append(append([]string(nil), policy.BlockedTerms...), blockedTerms...)
+	if len(terms) == 0 && len(policy.BlockedPatterns) == 0 && len(policy.AllowedTerms) == 0 {
+		return noPolicy
+	}
+	fingerprint := policyFingerprint(terms, policy.BlockedPatterns, policy.AllowedTerms)
+	id := policy.Id
+	if id == "" {
+		id = fingerprint
+	}
+	if cached, ok := s.policies.Get(id); ok && cached.fingerprint == fingerprint {
+		return cached
+	}
+
+	m := &policyMatchers{fingerprint: fingerprint, blocked: []*patternSet{phraseSet(terms)}, allowed: phraseSet(policy.AllowedTerms)}
+	m.blocked[0].leet, m.allowed.leet = true, true
+	var patterns []string
+	for _, pattern := range policy.BlockedPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			logger.GetLogger().Warnf("Ignoring invalid blocked pattern %q of policy %s: %v", pattern, policy.Id, err)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	if len(patterns) > 0 {
+		m.blocked = append(m.blocked, newPatternSet(nil, patterns))
+	}
+	s.policies.Add(id, m)
+	return m
+}
+
+// policyFingerprint identifies a policy's contents
+func policyFingerprint(lists ...[]string) string {
+	h := sha256.New()
+	for _, list := range lists {
+		for _, item := range list {
+			h.Write([]byte(item))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// blocks reports whether the text contains a blocked term or pattern
+func (m *policyMatchers) blocks(v *textView) bool {
+	for _, set := range m.blocked {
+		if set.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// covered reports whether a match lies within an allowed term
+func covered(match []int, spans [][]int) bool {
+	for _, span := range spans {
+		if match[0] >= span[0] && match[1] <= span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the set matches the text anywhere but within the
+// allowed terms
+func (m *policyMatchers) matches(set *patternSet, v *textView) bool {
+	if m.allowed.empty() {
+		return set.match(v)
+	}
+	spans := m.allowed.spans(v)
+	for _, match := range set.spans(v) {
+		if !covered(match, spans) {
+			return true
+		}
+	}
+	return false
+}
+
+// count returns how often the set matches the text outside the allowed terms
+func (m *policyMatchers) count(set *patternSet, v *textView) int {
+	spans := m.allowed.spans(v)
+	n := 0
+	for _, match := range set.spans(v) {
+		if !covered(match, spans) {
+			n++
+		}
+	}
+	return n
+}
+
+// replace replaces the set's matches outside the allowed terms, reporting
+// whether it replaced any. Phrase patterns consume the boundary after a
+// phrase, so a phrase repeated right after itself takes another pass.
+func (m *policyMatchers) replace(set *patternSet, text, replacement string) (string, bool) {
+	replacedAny := false
+	for pass := 0; pass < 4; pass++ {
+		v := newView(text)
+		spans := m.allowed.spans(v)
+		var b strings.Builder
+		last, replaced := 0, false
+		for _, match := range set.spans(v) {
+			if covered(match, spans) {
+				continue
+			}
+			b.WriteString(text[last:match[0]])
+			b.WriteString(replacement)
+			last, replaced = match[1], true
+		}
+		if !replaced {
+			break
+		}
+		b.WriteString(text[last:])
+		text, replacedAny = b.String(), true
+	}
+	return text, replacedAny
+}
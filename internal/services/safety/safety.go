@@ -3,43 +3,82 @@ package safety
 import (
 	"context"
 	"html"
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
 	"ai-search-service/internal/config"
 	"ai-search-service/internal/logger"
+	"ai-search-service/internal/lru"
+	"ai-search-service/internal/notify"
+	"ai-search-service/internal/privacy"
 	pb "ai-search-service/proto"
 )
 
 type SafetyService struct {
 	pb.UnimplementedSafetyServiceServer
 	config                *config.Config
-	dangerousPatterns     []*regexp.Regexp
-	inappropriatePatterns []*regexp.Regexp
-	sqlPatterns           []*regexp.Regexp
-	cmdPatterns           []*regexp.Regexp
+	notifier              *notify.Dispatcher
+	ips                   *privacy.Anonymizer // client IPs as they may be logged
+	dangerousPatterns     *patternSet
+	inappropriatePatterns *patternSet
+	toxicity              []scoredCategory // inappropriatePatterns by category
+	sqlPatterns           *patternSet
+	cmdPatterns           *patternSet
+	denylistPatterns      *patternSet // phrases never suggested
+	// policies holds each tenant's and key's compiled policy by policy ID
+	policies *lru.Cache[string, *policyMatchers]
 }
 
 func NewSafetyService(cfg *config.Config) (*SafetyService, error) {
+	notifier, err := notify.New(cfg.Notifications)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := privacy.New(cfg.Privacy)
+	if err != nil {
+		return nil, err
+	}
 	service := &SafetyService{
-		config: cfg,
+		config:   cfg,
+		notifier: notifier,
+		ips:      ips,
+		policies: lru.New[string, *policyMatchers]("safety_policies", cfg.Safety.PolicyCacheEntries, 0),
 	}
 
-	// Compile regex patterns
-	service.compileDangerousPatterns()
-	service.compileInappropriatePatterns()
-	service.compileSQLPatterns()
-	service.compileCmdPatterns()
+	// Compile each category for a single pass
+	service.dangerousPatterns = newPatternSet(nil, dangerousPatterns)
+	service.inappropriatePatterns = contentSet(categoryKeywords(inappropriateKeywords))
+	for _, category := range inappropriateKeywords {
+		weight := category.weight
+		if w, ok := cfg.Safety.Toxicity.Weights[category.name]; ok {
+			weight = w
+		}
+		service.toxicity = append(service.toxicity, scoredCategory{name: category.name, weight: weight, set: contentSet(category.keywords)})
+	}
+	service.sqlPatterns = newPatternSet(flatten(sqlKeywords), sqlPatterns)
+	service.cmdPatterns = newPatternSet(flatten(cmdKeywords), cmdPatterns)
+	service.denylistPatterns = phraseSet(cfg.Safety.SuggestionDenylist)
+	service.denylistPatterns.leet = true
 
 	return service, nil
 }
 
 func (s *SafetyService) ValidateInput(ctx context.Context, req *pb.ValidateInputRequest) (*pb.ValidateInputResponse, error) {
 	log := logger.GetLogger()
-
+	req.ClientIp = s.ips.IP(req.ClientIp)
 	log.Infof("Validating input from IP: %s", req.ClientIp)
+	resp := s.validateInput(req)
+	log.Infof("Input validation complete. Safe: %t, Warnings: %d", resp.IsSafe, len(resp.Warnings))
+	return resp, nil
+}
+
+func (s *SafetyService) validateInput(req *pb.ValidateInputRequest) *pb.ValidateInputResponse {
+	log := logger.GetLogger()
 
 	text := req.Text
 	warnings := []string{}
@@ -50,7 +89,7 @@ func (s *SafetyService) ValidateInput(ctx context.Context, req *pb.ValidateInput
 			IsSafe:        false,
 			SanitizedText: "",
 			Warnings:      []string{"Empty input"},
-		}, nil
+		}
 	}
 
 	// Length check
@@ -59,71 +98,92 @@ func (s *SafetyService) ValidateInput(ctx context.Context, req *pb.ValidateInput
 		text = text[:500]
 	}
 
+	// Match the patterns against the text normalized, so homoglyphs,
+	// zero-width characters and leet spellings don't evade them
+	view := newView(text)
+
 	// Check for dangerous patterns
-	for _, pattern := range s.dangerousPatterns {
-		if pattern.MatchString(text) {
-			return &pb.ValidateInputResponse{
-				IsSafe:        false,
-				SanitizedText: "",
-				Warnings:      []string{"Dangerous pattern detected"},
-			}, nil
-		}
+	if s.dangerousPatterns.match(view) {
+		return s.blockAttack(req, text, "Dangerous pattern detected")
 	}
 
 	// Check for SQL injection
-	for _, pattern := range s.sqlPatterns {
-		if pattern.MatchString(text) {
-			return &pb.ValidateInputResponse{
-				IsSafe:        false,
-				SanitizedText: "",
-				Warnings:      []string{"SQL injection pattern detected"},
-			}, nil
-		}
+	if s.sqlPatterns.match(view) {
+		return s.blockAttack(req, text, "SQL injection pattern detected")
 	}
 
 	// Check for command injection
-	for _, pattern := range s.cmdPatterns {
-		if pattern.MatchString(text) {
-			return &pb.ValidateInputResponse{
-				IsSafe:        false,
-				SanitizedText: "",
-				Warnings:      []string{"Command injection pattern detected"},
-			}, nil
+	if s.cmdPatterns.match(view) {
+		return s.blockAttack(req, text, "Command injection pattern detected")
+	}
+
+	// Check the tenant's and key's blocked terms and patterns
+	policy := s.matchers(req.Policy, req.BlockedTerms)
+	if policy.blocks(view) {
+		log.Infof("Blocked input from %s by tenant policy", req.ClientIp)
+		return &pb.ValidateInputResponse{
+			IsSafe:        false,
+			SanitizedText: "",
+			Warnings:      []string{"Query blocked by policy"},
 		}
 	}
 
-	// Check for inappropriate content
-	for _, pattern := range s.inappropriatePatterns {
-		if pattern.MatchString(text) {
-			if req.SafeSearch {
-				return &pb.ValidateInputResponse{
-					IsSafe:        false,
-					SanitizedText: "",
-					Warnings:      []string{"Inappropriate content detected and blocked by safe search"},
-				}, nil
+	// Check for inappropriate content, except the policy's allowed terms
+	if policy.matches(s.inappropriatePatterns, view) {
+		if req.SafeSearch {
+			return &pb.ValidateInputResponse{
+				IsSafe:        false,
+				SanitizedText: "",
+				Warnings:      []string{"Inappropriate content detected and blocked by safe search"},
 			}
-			warnings = append(warnings, "Potentially inappropriate content detected")
-			break
 		}
+		warnings = append(warnings, "Potentially inappropriate content detected")
 	}
 
 	// Sanitize the text
 	sanitizedText := s.sanitizeText(text)
 
-	log.Infof("Input validation complete. Safe: %t, Warnings: %d", true, len(warnings))
-
 	return &pb.ValidateInputResponse{
 		IsSafe:        true,
 		SanitizedText: sanitizedText,
 		Warnings:      warnings,
-	}, nil
+	}
+}
+
+// blockAttack rejects input matching an attack pattern and raises a
+// safety_block alert, repeated at most once per channel cooldown per client
+func (s *SafetyService) blockAttack(req *pb.ValidateInputRequest, text, reason string) *pb.ValidateInputResponse {
+	logger.GetLogger().Warnf("Blocked input from %s: %s", req.ClientIp, reason)
+	if len(text) > 200 {
+		text = text[:200] + "..."
+	}
+	s.notifier.Publish(notify.Message{
+		Event:    notify.EventSafetyBlock,
+		Severity: notify.Critical,
+		Title:    "Blocked input: " + reason,
+		Text:     "The safety service rejected a query matching an attack pattern.",
+		Fields: []notify.Field{
+			{Name: "Client", Value: req.ClientIp},
+			{Name: "Input", Value: strconv.Quote(text)},
+		},
+		Key: "safety_block:" + req.ClientIp,
+	})
+	return &pb.ValidateInputResponse{
+		IsSafe:        false,
+		SanitizedText: "",
+		Warnings:      []string{reason},
+	}
 }
 
 func (s *SafetyService) SanitizeOutput(ctx context.Context, req *pb.SanitizeOutputRequest) (*pb.SanitizeOutputResponse, error) {
 	log := logger.GetLogger()
-
 	log.Infof("Sanitizing output text of length: %d", len(req.Text))
+	resp := s.sanitizeOutput(req)
+	log.Infof("Output sanitization complete. Action: %s, Warnings: %d", resp.Action, len(resp.Warnings))
+	return resp, nil
+}
 
+func (s *SafetyService) sanitizeOutput(req *pb.SanitizeOutputRequest) *pb.SanitizeOutputResponse {
 	text := req.Text
 	warnings := []string{}
 
@@ -137,27 +197,143 @@ func (s *SafetyService) SanitizeOutput(ctx context.Context, req *pb.SanitizeOutp
 	sanitizedText := s.sanitizeText(text)
 
 	// Remove any remaining dangerous patterns
-	for _, pattern := range s.dangerousPatterns {
-		if pattern.MatchString(sanitizedText) {
-			sanitizedText = pattern.ReplaceAllString(sanitizedText, "[FILTERED]")
-			warnings = append(warnings, "Dangerous content filtered")
-		}
+	if filtered, ok := noPolicy.replace(s.dangerousPatterns, sanitizedText, "[FILTERED]"); ok {
+		sanitizedText = filtered
+		warnings = append(warnings, "Dangerous content filtered")
 	}
 
-	// Filter inappropriate content from AI output
-	for _, pattern := range s.inappropriatePatterns {
-		if pattern.MatchString(sanitizedText) {
-			sanitizedText = pattern.ReplaceAllString(sanitizedText, "[CONTENT FILTERED]")
-			warnings = append(warnings, "Inappropriate content filtered from AI output")
+	// Filter the policy's blocked terms and patterns, and inappropriate
+	// content other than its allowed terms, from AI output
+	policy := s.matchers(req.Policy, nil)
+	for _, set := range policy.blocked {
+		if filtered, ok := policy.replace(set, sanitizedText, "[CONTENT FILTERED]"); ok {
+			sanitizedText = filtered
+			warnings = append(warnings, "Content blocked by policy filtered from AI output")
 		}
 	}
 
-	log.Infof("Output sanitization complete. Warnings: %d", len(warnings))
+	// Score inappropriate content other than the policy's allowed terms and
+	// act on the highest score
+	thresholds := s.config.Safety.Toxicity
+	scores, action := s.score(policy, sanitizedText)
+	switch action {
+	case actionBlock:
+		sanitizedText = withheldText
+		warnings = append(warnings, "AI output withheld by safety filter")
+	case actionRewrite:
+		for _, category := range s.toxicity {
+			if float64(scores[category.name]) < thresholds.RewriteThreshold {
+				continue
+			}
+			if filtered, ok := policy.replace(category.set, sanitizedText, "[CONTENT FILTERED]"); ok {
+				sanitizedText = filtered
+			}
+		}
+		warnings = append(warnings, "Inappropriate content filtered from AI output")
+	case actionAnnotate:
+		var categories []string
+		for name, score := range scores {
+			if float64(score) >= thresholds.AnnotateThreshold {
+				categories = append(categories, name)
+			}
+		}
+		sort.Strings(categories)
+		warnings = append(warnings, "AI output may contain sensitive content: "+strings.Join(categories, ", "))
+	}
 
 	return &pb.SanitizeOutputResponse{
 		SanitizedText: sanitizedText,
 		Warnings:      warnings,
-	}, nil
+		Toxicity:      scores,
+		Action:        action,
+	}
+}
+
+// The actions SanitizeOutput takes on toxicity scores, from the mildest
+const (
+	actionPass     = "pass"
+	actionAnnotate = "annotate"
+	actionRewrite  = "rewrite"
+	actionBlock    = "block"
+)
+
+// withheldText replaces output scored at the block threshold
+const withheldText = "[SUMMARY WITHHELD BY SAFETY FILTER]"
+
+// scoredCategory is an inappropriate-content category compiled for scoring
+type scoredCategory struct {
+	name   string
+	weight float64
+	set    *patternSet
+}
+
+// score rates the text in each inappropriate-content category it matches
+// outside the policy's allowed terms. Each match adds the category's
+// weight to what is left of its score, so n matches score 1-(1-weight)^n.
+// The action is the one the highest score reaches.
+func (s *SafetyService) score(policy *policyMatchers, text string) (map[string]float32, string) {
+	view := newView(text)
+	if !policy.matches(s.inappropriatePatterns, view) {
+		return nil, actionPass
+	}
+	scores := make(map[string]float32)
+	highest := 0.0
+	for _, category := range s.toxicity {
+		n := policy.count(category.set, view)
+		if n == 0 {
+			continue
+		}
+		score := math.Round((1-math.Pow(1-category.weight, float64(n)))*100) / 100
+		scores[category.name] = float32(score)
+		highest = math.Max(highest, score)
+	}
+
+	thresholds := s.config.Safety.Toxicity
+	reaches := func(threshold float64) bool { return threshold > 0 && highest >= threshold }
+	switch {
+	case reaches(thresholds.BlockThreshold):
+		return scores, actionBlock
+	case reaches(thresholds.RewriteThreshold):
+		return scores, actionRewrite
+	case reaches(thresholds.AnnotateThreshold):
+		return scores, actionAnnotate
+	}
+	return scores, actionPass
+}
+
+// FilterSuggestions drops query suggestions that input validation would
+// block or warn about, or that match the configured denylist. The policy's
+// allowed terms are exempt from the inappropriate-content patterns and the
+// denylist.
+func (s *SafetyService) FilterSuggestions(ctx context.Context, req *pb.FilterSuggestionsRequest) (*pb.FilterSuggestionsResponse, error) {
+	attacks := []*patternSet{s.dangerousPatterns, s.sqlPatterns, s.cmdPatterns}
+	content := []*patternSet{s.inappropriatePatterns, s.denylistPatterns}
+	policy := s.matchers(req.Policy, req.BlockedTerms)
+
+	allowed := make([]string, 0, len(req.Suggestions))
+	for _, suggestion := range req.Suggestions {
+		view := newView(suggestion)
+		blocked := policy.blocks(view)
+		for _, set := range attacks {
+			if blocked || set.match(view) {
+				blocked = true
+				break
+			}
+		}
+		for _, set := range content {
+			if blocked || policy.matches(set, view) {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			allowed = append(allowed, suggestion)
+		}
+	}
+	if dropped := len(req.Suggestions) - len(allowed); dropped > 0 {
+		logger.GetLogger().Infof("Filtered %d of %d suggestions", dropped, len(req.Suggestions))
+	}
+	return &pb.FilterSuggestionsResponse{Allowed: allowed}, nil
 }
 
 func (s *SafetyService) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
@@ -187,14 +363,19 @@ func (s *SafetyService) sanitizeText(text string) string {
 	}
 
 	// Normalize whitespace
-	text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
+	text = whitespace.ReplaceAllString(text, " ")
 	text = strings.TrimSpace(text)
 
 	return text
 }
 
-func (s *SafetyService) compileDangerousPatterns() {
-	patterns := []string{
+// whitespace is a run of whitespace, collapsed to a single space
+var whitespace = regexp.MustCompile(`\s+`)
+
+// The built-in patterns, matched case-insensitively. Each keyword group is
+// matched as \b(keyword|...)\b; the groups only keep the lists readable.
+var (
+	dangerousPatterns = []string{
 		`<script[^>]*>.*?</script>`,
 		`javascript:`,
 		`on\w+\s*=`,
@@ -209,54 +390,81 @@ func (s *SafetyService) compileDangerousPatterns() {
 		`<button[^>]*>.*?</button>`,
 	}
 
-	s.dangerousPatterns = make([]*regexp.Regexp, len(patterns))
-	for i, pattern := range patterns {
-		s.dangerousPatterns[i] = regexp.MustCompile(`(?i)` + pattern)
+	// inappropriateKeywords are grouped by the toxicity category they score,
+	// weighted by how much a single match weighs
+	inappropriateKeywords = []toxicityCategory{
+		{"hacking", 0.2, []string{"hack", "crack", "exploit", "malware", "virus", "trojan"}},
+		{"piracy", 0.3, []string{"illegal", "piracy", "torrent", "torrents"}},
+		{"drugs", 0.35, []string{"drug", "drugs", "cocaine", "heroin", "marijuana"}},
+		{"adult", 0.5, []string{"adult", "porn", "sex", "xxx"}},
+		{"violence", 0.35, []string{"violence", "kill", "murder", "bomb"}},
+		{"profanity", 0.35, []string{"fuck", "shit", "damn", "bitch", "ass", "crap",
+			"wtf", "what the fuck", "fucking", "fucked", "hell", "goddamn", "jesus christ"}},
+		{"insult", 0.3, []string{"stupid", "idiot", "moron", "retard"}},
+		{"hate", 0.6, []string{"hate", "racist", "nazi", "terrorist"}},
 	}
-}
 
-func (s *SafetyService) compileInappropriatePatterns() {
-	patterns := []string{
-		`\b(hack|crack|exploit|malware|virus|trojan)\b`,
-		`\b(illegal|piracy|torrents?)\b`,
-		`\b(drugs?|cocaine|heroin|marijuana)\b`,
-		`\b(adult|porn|sex|xxx)\b`,
-		`\b(violence|kill|murder|bomb)\b`,
-		`\b(fuck|shit|damn|bitch|ass|crap)\b`,
-		`\b(wtf|what the fuck|fucking|fucked)\b`,
-		`\b(hell|goddamn|jesus christ)\b`,
-		`\b(stupid|idiot|moron|retard)\b`,
-		`\b(hate|racist|nazi|terrorist)\b`,
+	sqlKeywords = [][]string{
+		{"union", "select", "insert", "delete", "update", "drop", "create", "alter", "exec", "execute"},
 	}
-
-	s.inappropriatePatterns = make([]*regexp.Regexp, len(patterns))
-	for i, pattern := range patterns {
-		s.inappropriatePatterns[i] = regexp.MustCompile(`(?i)` + pattern)
-	}
-}
-
-func (s *SafetyService) compileSQLPatterns() {
-	patterns := []string{
-		`\b(union|select|insert|delete|update|drop|create|alter|exec|execute)\b`,
+	sqlPatterns = []string{
 		`[\'";]`,
 		`--`,
 		`/\*.*?\*/`,
 	}
 
-	s.sqlPatterns = make([]*regexp.Regexp, len(patterns))
-	for i, pattern := range patterns {
-		s.sqlPatterns[i] = regexp.MustCompile(`(?i)` + pattern)
+	cmdKeywords = [][]string{
+		{"cat", "ls", "rm", "mv", "cp", "chmod", "chown", "sudo", "su", "wget", "curl", "nc", "netcat"},
+	}
+	cmdPatterns = []string{
+		`[;&|` + "`" + `$]`,
 	}
+)
+
+// toxicityCategory is a group of inappropriate keywords scored together
+type toxicityCategory struct {
+	name     string
+	weight   float64
+	keywords []string
 }
 
-func (s *SafetyService) compileCmdPatterns() {
-	patterns := []string{
-		`[;&|` + "`" + `$]`,
-		`\b(cat|ls|rm|mv|cp|chmod|chown|sudo|su|wget|curl|nc|netcat)\b`,
+func categoryKeywords(categories []toxicityCategory) []string {
+	var all []string
+	for _, category := range categories {
+		all = append(all, category.keywords...)
+	}
+	return all
+}
+
+func flatten(groups [][]string) []string {
+	var all []string
+	for _, group := range groups {
+		all = append(all, group...)
 	}
+	return all
+}
 
-	s.cmdPatterns = make([]*regexp.Regexp, len(patterns))
-	for i, pattern := range patterns {
-		s.cmdPatterns[i] = regexp.MustCompile(`(?i)` + pattern)
+// phraseSet matches the phrases case-insensitively, with any run of
+// whitespace between their words. The phrase matched is the first group.
+func phraseSet(phrases []string) *patternSet {
+	var alternatives []string
+	for _, phrase := range phrases {
+		words := strings.Fields(phrase)
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		alternatives = append(alternatives, strings.Join(words, `\s+`))
+	}
+	if len(alternatives) == 0 {
+		return &patternSet{}
 	}
+	// Longest first, so a phrase such as "c++" wins over "c"
+	sort.SliceStable(alternatives, func(i, j int) bool { return len(alternatives[i]) > len(alternatives[j]) })
+	// Bounded by non-word characters rather than \b so phrases such as
+	// "c++" match too
+	pattern := `(?i)(?:^|\W)(` + strings.Join(alternatives, "|") + `)(?:\W|$)`
+	return &patternSet{regex: regexp.MustCompile(pattern), phrase: true}
 }
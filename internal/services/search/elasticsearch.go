@@ -0,0 +1,186 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+	pb "ai-search-service/proto"
+)
+
+// ElasticsearchProvider searches an Elasticsearch or OpenSearch index. Both
+// accept the same _search query DSL; hits are mapped onto search results with
+// the highlighted content fragment as the snippet.
+type ElasticsearchProvider struct {
+	config     config.ElasticsearchConfig
+	httpClient *http.Client
+}
+
+type esSearchResponse struct {
+	Hits struct {
+		Hits []esHit `json:"hits"`
+	} `json:"hits"`
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+type esHit struct {
+	ID        string                 `json:"_id"`
+	Score     float64                `json:"_score"`
+	Source    map[string]interface{} `json:"_source"`
+	Highlight map[string][]string    `json:"highlight"`
+}
+
+// NewElasticsearchProvider returns a provider for the configured index
+func NewElasticsearchProvider(cfg *config.Config) (*ElasticsearchProvider, error) {
+	es := cfg.Elasticsearch
+	if es.URL == "" || es.Index == "" {
+		return nil, fmt.Errorf("elasticsearch: url and index are required")
+	}
+	if es.Weight <= 0 || es.Weight > 1 {
+		return nil, fmt.Errorf("elasticsearch: weight must be in (0, 1], got %v", es.Weight)
+	}
+	return &ElasticsearchProvider{
+		config: es,
+		httpClient: &http.Client{
+			Timeout:   es.Timeout,
+			Transport: upstream.WrapTransport(cfg, "elasticsearch", nil),
+		},
+	}, nil
+}
+
+func (p *ElasticsearchProvider) Name() string { return "elasticsearch" }
+
+func (p *ElasticsearchProvider) Search(ctx context.Context, req *pb.SearchRequest) ([]*pb.SearchResult, error) {
+	body, err := json.Marshal(p.query(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+
+	searchURL := fmt.Sprintf("%s/%s/_search", strings.TrimSuffix(p.config.URL, "/"), url.PathEscape(p.config.Index))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, searchURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "ApiKey "+p.config.APIKey)
+	} else if p.config.Username != "" {
+		httpReq.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("index %s returned status %d: %s", p.config.Index, resp.StatusCode, truncate(string(respBody), 200))
+	}
+
+	var esResp esSearchResponse
+	if err := json.Unmarshal(respBody, &esResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(esResp.Error) > 0 {
+		return nil, fmt.Errorf("index %s error: %s", p.config.Index, truncate(string(esResp.Error), 200))
+	}
+
+	results := make([]*pb.SearchResult, 0, len(esResp.Hits.Hits))
+	for _, hit := range esResp.Hits.Hits {
+		results = append(results, p.result(hit))
+	}
+	return results, nil
+}
+
+// query builds the _search body: a multi_match over title and content with
+// one plain-text highlight fragment of the content
+func (p *ElasticsearchProvider) query(req *pb.SearchRequest) map[string]interface{} {
+	size := int(req.NumResults)
+	if size <= 0 {
+		size = 10
+	}
+	return map[string]interface{}{
+		"size":    size,
+		"_source": []string{p.config.TitleField, p.config.URLField, p.config.ContentField},
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  req.Query,
+				"fields": []string{p.config.TitleField + "^2", p.config.ContentField},
+			},
+		},
+		"highlight": map[string]interface{}{
+			"pre_tags":  []string{""},
+			"post_tags": []string{""},
+			"fields": map[string]interface{}{
+				p.config.ContentField: map[string]interface{}{
+					"fragment_size":       p.config.FragmentSize,
+					"number_of_fragments": 1,
+				},
+			},
+		},
+	}
+}
+
+// result maps a hit onto a search result. Without a highlight the snippet is
+// the start of the content; documents without a URL show the index name.
+func (p *ElasticsearchProvider) result(hit esHit) *pb.SearchResult {
+	title := sourceField(hit.Source, p.config.TitleField)
+	if title == "" {
+		title = hit.ID
+	}
+	var snippet string
+	if fragments := hit.Highlight[p.config.ContentField]; len(fragments) > 0 {
+		snippet = fragments[0]
+	} else {
+		snippet = truncate(sourceField(hit.Source, p.config.ContentField), p.config.FragmentSize)
+	}
+
+	link := sourceField(hit.Source, p.config.URLField)
+	displayURL := p.config.Index
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		displayURL = u.Host
+	}
+
+	return &pb.SearchResult{
+		Title:      sanitizeText(title),
+		Url:        link,
+		Snippet:    sanitizeText(snippet),
+		DisplayUrl: displayURL,
+		Score:      hit.Score,
+	}
+}
+
+func sourceField(source map[string]interface{}, field string) string {
+	switch v := source[field].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// truncate cuts text to at most n bytes at a rune boundary; n <= 0 keeps it whole
+func truncate(text string, n int) string {
+	if n <= 0 || len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
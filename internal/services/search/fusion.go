@@ -0,0 +1,134 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	pb "ai-search-service/proto"
+)
+
+// Ranking methods (ranking.method)
+const (
+	RankInterleave = "interleave"
+	RankScore      = "score"
+)
+
+// fuse merges per-source results into one list of at most n results (n <= 0
+// keeps every result). Each result is tagged with its source and its score is
+// normalized within that source, so an Elasticsearch BM25 score and a cosine
+// similarity compare on the same 0..1 scale. Duplicate URLs keep the first,
+// best-ranked copy.
+func fuse(method string, sources []source, lists []sourceResults, n int) []*pb.SearchResult {
+	for i, src := range sources {
+		normalizeScores(lists[i].results)
+		for _, result := range lists[i].results {
+			result.Source = src.provider.Name()
+			result.Internal = src.internal
+		}
+	}
+	if method == RankScore {
+		return rankByScore(sources, lists, n)
+	}
+	return interleave(sources, lists, n)
+}
+
+// normalizeScores scales scores by the best one in the list. Providers that
+// report no scores (the web search API) are scored by rank instead.
+func normalizeScores(results []*pb.SearchResult) {
+	best := 0.0
+	for _, result := range results {
+		if result.Score > best {
+			best = result.Score
+		}
+	}
+	for i, result := range results {
+		switch {
+		case best <= 0:
+			result.Score = 1 - float64(i)/float64(len(results))
+		case result.Score < 0:
+			result.Score = 0
+		default:
+			result.Score /= best
+		}
+	}
+}
+
+// interleave takes results in proportion to the source weights (smooth
+// weighted round-robin) so every source is represented near the top.
+// Sources that run out yield their slots to the others.
+func interleave(sources []source, lists []sourceResults, n int) []*pb.SearchResult {
+	var fused []*pb.SearchResult
+	seen := make(map[string]bool)
+	next := make([]int, len(sources))
+	current := make([]float64, len(sources))
+
+	for n <= 0 || len(fused) < n {
+		pick, total := -1, 0.0
+		for i, src := range sources {
+			if src.weight <= 0 || next[i] >= len(lists[i].results) {
+				continue
+			}
+			current[i] += src.weight
+			total += src.weight
+			if pick < 0 || current[i] > current[pick] {
+				pick = i
+			}
+		}
+		if pick < 0 {
+			break
+		}
+		current[pick] -= total
+
+		result := lists[pick].results[next[pick]]
+		next[pick]++
+		if !seenURL(seen, result) {
+			fused = append(fused, result)
+		}
+	}
+	return fused
+}
+
+// rankByScore orders every result by its source weight times its normalized
+// score; ties keep source order, so web results lead
+func rankByScore(sources []source, lists []sourceResults, n int) []*pb.SearchResult {
+	type candidate struct {
+		result *pb.SearchResult
+		rank   float64
+	}
+	var candidates []candidate
+	for i, src := range sources {
+		if src.weight <= 0 {
+			continue
+		}
+		for _, result := range lists[i].results {
+			candidates = append(candidates, candidate{result: result, rank: src.weight * result.Score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].rank > candidates[j].rank })
+
+	var fused []*pb.SearchResult
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if n > 0 && len(fused) == n {
+			break
+		}
+		if !seenURL(seen, c.result) {
+			fused = append(fused, c.result)
+		}
+	}
+	return fused
+}
+
+// seenURL records the result's URL, reporting whether it was already taken.
+// Results without a URL are never duplicates.
+func seenURL(seen map[string]bool, result *pb.SearchResult) bool {
+	if result.Url == "" {
+		return false
+	}
+	key := strings.TrimSuffix(result.Url, "/")
+	if seen[key] {
+		return true
+	}
+	seen[key] = true
+	return false
+}
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// IngestDocument chunks, embeds and stores a document in the vector store.
+// Documents whose normalized text is already stored are skipped.
+func (s *SearchService) IngestDocument(ctx context.Context, req *pb.IngestDocumentRequest) (*pb.IngestDocumentResponse, error) {
+	if s.vectors == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no vector store is configured")
+	}
+	text := sanitizeText(req.Text)
+	if text == "" {
+		return nil, status.Error(codes.InvalidArgument, "document has no text")
+	}
+	return s.vectors.ingest(ctx, req.Title, req.Url, text)
+}
+
+func (p *VectorStoreProvider) ingest(ctx context.Context, title, link, text string) (*pb.IngestDocumentResponse, error) {
+	log := logger.GetLogger()
+	sum := sha256.Sum256([]byte(text))
+	contentHash := hex.EncodeToString(sum[:])
+
+	exists, err := p.store.contains(ctx, contentHash)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "dedup lookup failed: %v", err)
+	}
+	if exists {
+		log.Infof("Skipping duplicate document %q (%s)", title, contentHash[:12])
+		return &pb.IngestDocumentResponse{ContentHash: contentHash, Duplicate: true}, nil
+	}
+
+	pieces := chunkText(text, p.config.ChunkSize, p.config.ChunkOverlap)
+	embedded, err := p.embedder.Embed(ctx, &pb.EmbedRequest{Texts: pieces, ModelName: p.config.EmbeddingModel})
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to embed document: %v", err)
+	}
+	if len(embedded.Embeddings) != len(pieces) {
+		return nil, status.Errorf(codes.Internal, "embedding service returned %d vectors for %d chunks", len(embedded.Embeddings), len(pieces))
+	}
+
+	chunks := make([]vectorChunk, len(pieces))
+	for i, piece := range pieces {
+		chunks[i] = vectorChunk{
+			id:     chunkID(contentHash, i),
+			vector: embedded.Embeddings[i].Values,
+			fields: map[string]interface{}{
+				p.config.TitleField:   title,
+				p.config.URLField:     link,
+				p.config.ContentField: piece,
+				p.config.HashField:    contentHash,
+			},
+		}
+	}
+	if err := p.store.upsert(ctx, chunks); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to store document: %v", err)
+	}
+
+	log.Infof("Ingested document %q as %d chunks (%s)", title, len(chunks), contentHash[:12])
+	return &pb.IngestDocumentResponse{ContentHash: contentHash, Chunks: int32(len(chunks))}, nil
+}
+
+// chunkText splits text into pieces of at most size bytes on word boundaries,
+// each starting about overlap bytes before the previous one ended so context
+// spanning a boundary is kept in one chunk
+func chunkText(text string, size, overlap int) []string {
+	if size <= 0 || len(text) <= size {
+		return []string{text}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	for start := 0; start < len(text); {
+		end := start + size
+		if end >= len(text) {
+			chunks = append(chunks, text[start:])
+			break
+		}
+		// Break at the last space so words stay whole
+		if cut := strings.LastIndexByte(text[start:end], ' '); cut > 0 {
+			end = start + cut
+		} else {
+			end = start + len(truncate(text[start:], size))
+		}
+		chunks = append(chunks, strings.TrimSpace(text[start:end]))
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		// Resume at a word start
+		if space := strings.IndexByte(text[next:end], ' '); space >= 0 && next != end {
+			next += space + 1
+		}
+		for next < end && !utf8.RuneStart(text[next]) {
+			next++
+		}
+		start = next
+	}
+	return chunks
+}
+
+// chunkID derives a stable UUID for a chunk, so re-ingesting after a partial
+// failure overwrites rather than duplicates
+func chunkID(contentHash string, index int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", contentHash, index)))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // version 5 layout
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,47 @@
+package search
+
+import (
+	"net/url"
+	"strings"
+)
+
+// googleLanguages maps languages whose Custom Search codes differ from
+// their BCP 47 ones
+var googleLanguages = map[string]string{"he": "iw", "nb": "no", "nn": "no", "fil": "tl"}
+
+// googleCountries maps countries whose Custom Search codes differ from
+// their ISO 3166 ones
+var googleCountries = map[string]string{"gb": "uk"}
+
+// addLocaleParams sets the Custom Search locale parameters for a BCP 47
+// locale such as "de-CH": gl (the country results are boosted for), hl (the
+// interface language) and, with restrictLanguage, lr (the only language
+// results may be in). Chinese distinguishes simplified and traditional.
+func addLocaleParams(params url.Values, locale string, restrictLanguage bool) {
+	language, country, _ := strings.Cut(locale, "-")
+	language, country = strings.ToLower(language), strings.ToLower(country)
+	if language == "" {
+		return
+	}
+	if mapped, ok := googleLanguages[language]; ok {
+		language = mapped
+	}
+	if language == "zh" {
+		if country == "tw" || country == "hk" || country == "mo" {
+			language = "zh-TW"
+		} else {
+			language = "zh-CN"
+		}
+	}
+
+	params.Set("hl", language)
+	if restrictLanguage {
+		params.Set("lr", "lang_"+language)
+	}
+	if country != "" {
+		if mapped, ok := googleCountries[country]; ok {
+			country = mapped
+		}
+		params.Set("gl", country)
+	}
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"ai-search-service/internal/monitoring"
+	pb "ai-search-service/proto"
+)
+
+// paywallCues are phrases a snippet shows when the crawler only saw a
+// subscription or sign-in prompt
+var paywallCues = regexp.MustCompile(`(?i)\b(subscribe (now )?to (read|continue|unlock)|subscribers? only|for subscribers|(sign|log) ?in to (read|continue|view)|create a free account to|already a subscriber|this (article|content) is (available|exclusive) to (subscribers|members)|to continue reading)\b`)
+
+// lockedTiers are article:content_tier values of pages readers cannot
+// read in full without paying
+var lockedTiers = map[string]bool{"locked": true, "metered": true}
+
+// paywallDetector flags web results whose page is behind a paywall or login
+// wall, so the summary leans on their snippet as a preview only
+type paywallDetector struct {
+	domains map[string]bool
+}
+
+func newPaywallDetector(domains []string) *paywallDetector {
+	d := &paywallDetector{domains: make(map[string]bool, len(domains))}
+	for _, domain := range domains {
+		d.domains[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "www."))] = true
+	}
+	return d
+}
+
+// signal returns why a result looks paywalled, or "" when it does not:
+// "domain" for a configured paywalled site or one of its subdomains, "meta"
+// for meta tags declaring the content locked, "snippet" for a subscription
+// or sign-in prompt in place of the text
+func (d *paywallDetector) signal(link, snippet string, metatags []map[string]string) string {
+	if u, err := url.Parse(link); err == nil {
+		for host := strings.ToLower(u.Hostname()); host != ""; {
+			if d.domains[host] {
+				return "domain"
+			}
+			_, parent, ok := strings.Cut(host, ".")
+			if !ok {
+				break
+			}
+			host = parent
+		}
+	}
+	for _, tags := range metatags {
+		if lockedTiers[strings.ToLower(tags["article:content_tier"])] || strings.EqualFold(tags["isaccessibleforfree"], "false") {
+			return "meta"
+		}
+	}
+	if paywallCues.MatchString(snippet) {
+		return "snippet"
+	}
+	return ""
+}
+
+// mark flags a result as paywalled when it looks so
+func (d *paywallDetector) mark(result *pb.SearchResult, metatags []map[string]string) {
+	if signal := d.signal(result.Url, result.Snippet, metatags); signal != "" {
+		result.Paywalled = true
+		monitoring.RecordPaywalledResult(signal)
+	}
+}
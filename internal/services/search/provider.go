@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"ai-search-service/internal/logger"
+	pb "ai-search-service/proto"
+)
+
+// Provider is a source of search results: the web search API or an internal
+// knowledge source
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, req *pb.SearchRequest) ([]*pb.SearchResult, error)
+}
+
+// source is a provider with its share of the blended results
+type source struct {
+	provider Provider
+	weight   float64
+	internal bool // an internal knowledge source rather than the web
+}
+
+// permittedSources keeps the sources named in allowed: "web" names the web
+// provider, anything else a provider's name
+func permittedSources(sources []source, allowed []string) []source {
+	var permitted []source
+	for _, src := range sources {
+		for _, name := range allowed {
+			if name == src.provider.Name() || (name == "web" && !src.internal) {
+				permitted = append(permitted, src)
+				break
+			}
+		}
+	}
+	return permitted
+}
+
+// sourceResults is what one source returned
+type sourceResults struct {
+	results []*pb.SearchResult
+	err     error
+}
+
+// searchSources queries every source concurrently, each for the full number
+// of results so that a source coming up short can be filled from the others
+func searchSources(ctx context.Context, sources []source, req *pb.SearchRequest) []sourceResults {
+	out := make([]sourceResults, len(sources))
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		wg.Add(1)
+		go func(i int, provider Provider) {
+			defer wg.Done()
+			results, err := provider.Search(ctx, req)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", provider.Name(), err)
+			}
+			out[i] = sourceResults{results: results, err: err}
+		}(i, src.provider)
+	}
+	wg.Wait()
+	return out
+}
+
+// searchBlended runs the search against every source and fuses the results.
+// A failing source is logged and skipped; the search only fails when no
+// source returned anything and at least one of them errored.
+func searchBlended(ctx context.Context, method string, sources []source, req *pb.SearchRequest) ([]*pb.SearchResult, error) {
+	log := logger.GetLogger()
+
+	lists := searchSources(ctx, sources, req)
+	var firstErr error
+	found := false
+	for i, list := range lists {
+		if list.err != nil {
+			log.Errorf("Search source %s failed: %v", sources[i].provider.Name(), list.err)
+			if firstErr == nil {
+				firstErr = list.err
+			}
+			continue
+		}
+		found = found || len(list.results) > 0
+	}
+	if !found && firstErr != nil {
+		return nil, firstErr
+	}
+	return fuse(method, sources, lists, int(req.NumResults)), nil
+}
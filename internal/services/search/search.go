@@ -5,20 +5,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
 	"ai-search-service/internal/config"
 	"ai-search-service/internal/logger"
+	"ai-search-service/internal/upstream"
 	pb "ai-search-service/proto"
 )
 
 type SearchService struct {
 	pb.UnimplementedSearchServiceServer
-	config     *config.Config
-	httpClient *http.Client
+	config  *config.Config
+	sources []source             // web search plus any internal knowledge sources
+	vectors *VectorStoreProvider // nil unless a vector store is configured; documents are ingested into it
+
+	suggester *googleSuggester // nil unless provider suggestions are configured
+
+	inferenceConn *grpc.ClientConn // nil unless a vector store is configured
 }
 
 type GoogleSearchResponse struct {
@@ -32,6 +42,12 @@ type GoogleSearchItem struct {
 	Snippet      string `json:"snippet"`
 	DisplayLink  string `json:"displayLink"`
 	FormattedUrl string `json:"formattedUrl"`
+	Pagemap      struct {
+		Thumbnail []struct {
+			Src string `json:"src"`
+		} `json:"cse_thumbnail"`
+		Metatags []map[string]string `json:"metatags"`
+	} `json:"pagemap"`
 }
 
 type GoogleError struct {
@@ -39,37 +55,106 @@ type GoogleError struct {
 	Message string `json:"message"`
 }
 
-func NewSearchService(cfg *config.Config) (*SearchService, error) {
+// NewSearchService creates the search service. dialOpts are used to reach the
+// inference service, which embeds queries for the vector store.
+func NewSearchService(cfg *config.Config, dialOpts ...grpc.DialOption) (*SearchService, error) {
+	var web Provider
+	// Check if Google API credentials are configured; replay needs none
+	if (cfg.Google.APIKey == "" || cfg.Google.CX == "") && cfg.UpstreamMode != upstream.ModeReplay {
+		web = mockProvider{}
+	} else {
+		web = newGoogleProvider(cfg)
+	}
+
+	switch cfg.Ranking.Method {
+	case RankInterleave, RankScore, "":
+	default:
+		return nil, fmt.Errorf("unknown ranking method %q", cfg.Ranking.Method)
+	}
+
+	// Internal knowledge sources take their weight from the web share
+	var sources []source
+	var vectors *VectorStoreProvider
+	var inferenceConn *grpc.ClientConn
+	webWeight := 1.0
+	if cfg.Elasticsearch.Enabled {
+		es, err := NewElasticsearchProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source{provider: es, weight: cfg.Elasticsearch.Weight, internal: true})
+		webWeight -= cfg.Elasticsearch.Weight
+	}
+	if cfg.VectorStore.Enabled {
+		dialOpts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, dialOpts...)
+		conn, err := grpc.Dial(cfg.GetInferenceAddress(), dialOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to inference: %w", err)
+		}
+		inferenceConn = conn
+		vs, err := NewVectorStoreProvider(cfg, pb.NewInferenceServiceClient(conn))
+		if err != nil {
+			return nil, err
+		}
+		vectors = vs
+		sources = append(sources, source{provider: vs, weight: cfg.VectorStore.Weight, internal: true})
+		webWeight -= cfg.VectorStore.Weight
+	}
+	// Round away float error so equal shares tie and web results lead
+	webWeight = math.Round(webWeight*1e9) / 1e9
+	if webWeight > 0 {
+		sources = append([]source{{provider: web, weight: webWeight}}, sources...)
+	}
+
 	return &SearchService{
-		config: cfg,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		config:    cfg,
+		sources:   sources,
+		vectors:   vectors,
+		suggester: newGoogleSuggester(cfg),
+
+		inferenceConn: inferenceConn,
 	}, nil
 }
 
+// Connections returns the service's gRPC client connections, for diagnostics
+func (s *SearchService) Connections() map[string]*grpc.ClientConn {
+	conns := make(map[string]*grpc.ClientConn)
+	if s.inferenceConn != nil {
+		conns["inference"] = s.inferenceConn
+	}
+	return conns
+}
+
 func (s *SearchService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
 	log := logger.GetLogger()
 
 	log.Infof("Performing search for query: %s", req.Query)
 
-	// Check if Google API credentials are configured
-	if s.config.Google.APIKey == "" || s.config.Google.CX == "" {
-		log.Warn("Google API credentials not configured, using mock data")
-		return s.getMockSearchResults(req), nil
+	sources := s.sources
+	if len(req.Sources) > 0 {
+		sources = permittedSources(s.sources, req.Sources)
+		if len(sources) == 0 {
+			return &pb.SearchResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Search failed: none of the sources %v is configured", req.Sources),
+			}, nil
+		}
 	}
 
-	// Perform actual Google search
-	results, err := s.performGoogleSearch(ctx, req)
+	results, err := searchBlended(ctx, s.config.Ranking.Method, sources, req)
 	if err != nil {
-		log.Errorf("Google search failed: %v", err)
+		log.Errorf("Search failed: %v", err)
 		return &pb.SearchResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Search failed: %v", err),
 		}, nil
 	}
 
-	return results, nil
+	return &pb.SearchResponse{
+		Results: results,
+		Query:   req.Query,
+		Success: true,
+	}, nil
 }
 
 func (s *SearchService) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
@@ -80,18 +165,42 @@ func (s *SearchService) HealthCheck(ctx context.Context, req *pb.HealthCheckRequ
 	}, nil
 }
 
-func (s *SearchService) performGoogleSearch(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+// googleProvider searches the web through the Google Custom Search JSON API
+type googleProvider struct {
+	config     config.GoogleConfig
+	httpClient *http.Client
+	paywalls   *paywallDetector
+}
+
+func newGoogleProvider(cfg *config.Config) *googleProvider {
+	return &googleProvider{
+		config:   cfg.Google,
+		paywalls: newPaywallDetector(cfg.Google.PaywallDomains),
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: upstream.WrapTransport(cfg, "google", nil),
+		},
+	}
+}
+
+func (p *googleProvider) Name() string { return "google" }
+
+func (p *googleProvider) Search(ctx context.Context, req *pb.SearchRequest) ([]*pb.SearchResult, error) {
 	// Build Google Custom Search API URL
-	baseURL := "https://www.googleapis.com/customsearch/v1"
+	baseURL := p.config.BaseURL
+	if baseURL == "" {
+		baseURL = "https://www.googleapis.com/customsearch/v1"
+	}
 	params := url.Values{}
-	params.Add("key", s.config.Google.APIKey)
-	params.Add("cx", s.config.Google.CX)
+	params.Add("key", p.config.APIKey)
+	params.Add("cx", p.config.CX)
 	params.Add("q", req.Query)
 	params.Add("num", fmt.Sprintf("%d", req.NumResults))
 
 	if req.SafeSearch {
 		params.Add("safe", "active")
 	}
+	addLocaleParams(params, req.Locale, p.config.RestrictLanguage)
 
 	searchURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 
@@ -102,7 +211,7 @@ func (s *SearchService) performGoogleSearch(ctx context.Context, req *pb.SearchR
 	}
 
 	// Perform request
-	resp, err := s.httpClient.Do(httpReq)
+	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform request: %w", err)
 	}
@@ -129,21 +238,28 @@ func (s *SearchService) performGoogleSearch(ctx context.Context, req *pb.SearchR
 	results := make([]*pb.SearchResult, len(googleResp.Items))
 	for i, item := range googleResp.Items {
 		results[i] = &pb.SearchResult{
-			Title:      s.sanitizeText(item.Title),
+			Title:      sanitizeText(item.Title),
 			Url:        item.Link,
-			Snippet:    s.sanitizeText(item.Snippet),
+			Snippet:    sanitizeText(item.Snippet),
 			DisplayUrl: item.DisplayLink,
 		}
+		if len(item.Pagemap.Thumbnail) > 0 {
+			results[i].ImageUrl = item.Pagemap.Thumbnail[0].Src
+		}
+		p.paywalls.mark(results[i], item.Pagemap.Metatags)
 	}
 
-	return &pb.SearchResponse{
-		Results: results,
-		Query:   req.Query,
-		Success: true,
-	}, nil
+	return results, nil
 }
 
-func (s *SearchService) getMockSearchResults(req *pb.SearchRequest) *pb.SearchResponse {
+// mockProvider stands in for web search when Google credentials are missing
+type mockProvider struct{}
+
+func (mockProvider) Name() string { return "mock" }
+
+func (mockProvider) Search(ctx context.Context, req *pb.SearchRequest) ([]*pb.SearchResult, error) {
+	logger.GetLogger().Warn("Google API credentials not configured, using mock data")
+
 	// Generate mock results for testing
 	mockResults := []*pb.SearchResult{
 		{
@@ -175,14 +291,10 @@ func (s *SearchService) getMockSearchResults(req *pb.SearchRequest) *pb.SearchRe
 		numResults = len(mockResults)
 	}
 
-	return &pb.SearchResponse{
-		Results: mockResults[:numResults],
-		Query:   req.Query,
-		Success: true,
-	}
+	return mockResults[:numResults], nil
 }
 
-func (s *SearchService) sanitizeText(text string) string {
+func sanitizeText(text string) string {
 	// Basic text sanitization
 	text = strings.TrimSpace(text)
 	text = strings.ReplaceAll(text, "\n", " ")
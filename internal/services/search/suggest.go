@@ -0,0 +1,86 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+	"ai-search-service/internal/upstream"
+	pb "ai-search-service/proto"
+)
+
+// googleSuggester completes queries through Google's autocomplete endpoint,
+// which answers in the browser suggestion format: ["query", ["completion", ...]]
+type googleSuggester struct {
+	url        string
+	httpClient *http.Client
+}
+
+// newGoogleSuggester returns nil when no suggest URL is configured
+func newGoogleSuggester(cfg *config.Config) *googleSuggester {
+	if cfg.Google.SuggestURL == "" {
+		return nil
+	}
+	return &googleSuggester{
+		url: cfg.Google.SuggestURL,
+		httpClient: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: upstream.WrapTransport(cfg, "google_suggest", nil),
+		},
+	}
+}
+
+func (p *googleSuggester) suggest(ctx context.Context, query string) ([]string, error) {
+	params := url.Values{}
+	params.Add("client", "firefox")
+	params.Add("q", query)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("suggest endpoint returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var parsed []json.RawMessage
+	var suggestions []string
+	if err := json.Unmarshal(body, &parsed); err != nil || len(parsed) < 2 {
+		return nil, fmt.Errorf("unexpected suggest response")
+	}
+	if err := json.Unmarshal(parsed[1], &suggestions); err != nil {
+		return nil, fmt.Errorf("unexpected suggest response: %w", err)
+	}
+	return suggestions, nil
+}
+
+// Suggest returns the web provider's completions of a query prefix, or none
+// when provider suggestions are not configured
+func (s *SearchService) Suggest(ctx context.Context, req *pb.SuggestRequest) (*pb.SuggestResponse, error) {
+	if s.suggester == nil || req.Query == "" {
+		return &pb.SuggestResponse{}, nil
+	}
+	suggestions, err := s.suggester.suggest(ctx, req.Query)
+	if err != nil {
+		logger.GetLogger().Warnf("Provider suggestions failed: %v", err)
+		return nil, err
+	}
+	if req.MaxResults > 0 && len(suggestions) > int(req.MaxResults) {
+		suggestions = suggestions[:req.MaxResults]
+	}
+	return &pb.SuggestResponse{Suggestions: suggestions}, nil
+}
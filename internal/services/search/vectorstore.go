@@ -0,0 +1,499 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/upstream"
+	pb "ai-search-service/proto"
+)
+
+// VectorStoreProvider retrieves the documents nearest to the query from a
+// vector database. The query is embedded by the inference service, so the
+// store must hold vectors from the same embedding model.
+type VectorStoreProvider struct {
+	config   config.VectorStoreConfig
+	embedder pb.InferenceServiceClient
+	store    vectorStore
+}
+
+// vectorStore finds the documents nearest to a query vector and stores
+// ingested chunks
+type vectorStore interface {
+	nearest(ctx context.Context, vector []float32, topK int, threshold float64) ([]vectorHit, error)
+	// contains reports whether chunks of a document with this content hash are stored
+	contains(ctx context.Context, contentHash string) (bool, error)
+	upsert(ctx context.Context, chunks []vectorChunk) error
+}
+
+// vectorChunk is one embedded piece of an ingested document
+type vectorChunk struct {
+	id     string // UUID derived from the content hash and chunk index
+	vector []float32
+	fields map[string]interface{}
+}
+
+// vectorHit is a stored document with its cosine similarity to the query
+type vectorHit struct {
+	id     string
+	fields map[string]interface{}
+	score  float64
+}
+
+// NewVectorStoreProvider returns a provider for the configured backend,
+// embedding queries through embedder
+func NewVectorStoreProvider(cfg *config.Config, embedder pb.InferenceServiceClient) (*VectorStoreProvider, error) {
+	vs := cfg.VectorStore
+	if vs.Collection == "" {
+		return nil, fmt.Errorf("vector store: collection is required")
+	}
+	if vs.Weight <= 0 || vs.Weight > 1 {
+		return nil, fmt.Errorf("vector store: weight must be in (0, 1], got %v", vs.Weight)
+	}
+	httpClient := &http.Client{
+		Timeout:   vs.Timeout,
+		Transport: upstream.WrapTransport(cfg, vs.Backend, nil),
+	}
+
+	var store vectorStore
+	var err error
+	switch vs.Backend {
+	case "qdrant":
+		store = &qdrantStore{config: vs, httpClient: httpClient}
+	case "weaviate":
+		store, err = newWeaviateStore(vs, httpClient)
+	case "pgvector":
+		store, err = newPGVectorStore(vs)
+	default:
+		err = fmt.Errorf("unknown backend %q", vs.Backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vector store: %w", err)
+	}
+
+	return &VectorStoreProvider{config: vs, embedder: embedder, store: store}, nil
+}
+
+func (p *VectorStoreProvider) Name() string { return p.config.Backend }
+
+func (p *VectorStoreProvider) Search(ctx context.Context, req *pb.SearchRequest) ([]*pb.SearchResult, error) {
+	embedded, err := p.embedder.Embed(ctx, &pb.EmbedRequest{Texts: []string{req.Query}, ModelName: p.config.EmbeddingModel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(embedded.Embeddings) != 1 || len(embedded.Embeddings[0].Values) == 0 {
+		return nil, fmt.Errorf("embedding service returned no vector")
+	}
+
+	topK := p.config.TopK
+	if n := int(req.NumResults); n > 0 && (topK <= 0 || n < topK) {
+		topK = n
+	}
+	hits, err := p.store.nearest(ctx, embedded.Embeddings[0].Values, topK, p.config.ScoreThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*pb.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		// Stores filter on the threshold too; this covers any that cannot
+		if hit.score < p.config.ScoreThreshold {
+			continue
+		}
+		results = append(results, p.result(hit))
+	}
+	return results, nil
+}
+
+// result maps a hit onto a search result; documents without a URL show the
+// collection name
+func (p *VectorStoreProvider) result(hit vectorHit) *pb.SearchResult {
+	title := sourceField(hit.fields, p.config.TitleField)
+	if title == "" {
+		title = hit.id
+	}
+	link := sourceField(hit.fields, p.config.URLField)
+	displayURL := p.config.Collection
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		displayURL = u.Host
+	}
+	return &pb.SearchResult{
+		Title:      sanitizeText(title),
+		Url:        link,
+		Snippet:    sanitizeText(truncate(sourceField(hit.fields, p.config.ContentField), p.config.SnippetLength)),
+		DisplayUrl: displayURL,
+		Score:      hit.score,
+	}
+}
+
+// requestJSON sends body to a vector store HTTP API and decodes the response into out
+func requestJSON(ctx context.Context, client *http.Client, method, endpoint string, header http.Header, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header = header.Clone()
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("returned status %d: %s", resp.StatusCode, truncate(string(respBody), 200))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// qdrantStore searches a Qdrant collection through its REST API; payload
+// fields map onto results and scores are cosine similarities
+type qdrantStore struct {
+	config     config.VectorStoreConfig
+	httpClient *http.Client
+}
+
+func (s *qdrantStore) nearest(ctx context.Context, vector []float32, topK int, threshold float64) ([]vectorHit, error) {
+	body := map[string]interface{}{
+		"vector":       vector,
+		"limit":        topK,
+		"with_payload": true,
+	}
+	if threshold > 0 {
+		body["score_threshold"] = threshold
+	}
+	var out struct {
+		Result []struct {
+			ID      interface{}            `json:"id"` // integer or UUID
+			Score   float64                `json:"score"`
+			Payload map[string]interface{} `json:"payload"`
+		} `json:"result"`
+	}
+	if err := s.request(ctx, http.MethodPost, "/points/search", body, &out); err != nil {
+		return nil, err
+	}
+
+	hits := make([]vectorHit, len(out.Result))
+	for i, point := range out.Result {
+		hits[i] = vectorHit{id: fmt.Sprint(point.ID), fields: point.Payload, score: point.Score}
+	}
+	return hits, nil
+}
+
+func (s *qdrantStore) contains(ctx context.Context, contentHash string) (bool, error) {
+	body := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"must": []interface{}{
+				map[string]interface{}{"key": s.config.HashField, "match": map[string]string{"value": contentHash}},
+			},
+		},
+		"limit":        1,
+		"with_payload": false,
+	}
+	var out struct {
+		Result struct {
+			Points []json.RawMessage `json:"points"`
+		} `json:"result"`
+	}
+	if err := s.request(ctx, http.MethodPost, "/points/scroll", body, &out); err != nil {
+		return false, err
+	}
+	return len(out.Result.Points) > 0, nil
+}
+
+func (s *qdrantStore) upsert(ctx context.Context, chunks []vectorChunk) error {
+	points := make([]map[string]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		points[i] = map[string]interface{}{"id": chunk.id, "vector": chunk.vector, "payload": chunk.fields}
+	}
+	var out json.RawMessage
+	// wait=true so a duplicate submitted right after is already visible
+	return s.request(ctx, http.MethodPut, "/points?wait=true", map[string]interface{}{"points": points}, &out)
+}
+
+func (s *qdrantStore) request(ctx context.Context, method, path string, body, out interface{}) error {
+	header := http.Header{}
+	if s.config.APIKey != "" {
+		header.Set("api-key", s.config.APIKey)
+	}
+	endpoint := fmt.Sprintf("%s/collections/%s%s", strings.TrimSuffix(s.config.URL, "/"), url.PathEscape(s.config.Collection), path)
+	if err := requestJSON(ctx, s.httpClient, method, endpoint, header, body, out); err != nil {
+		return fmt.Errorf("qdrant collection %s: %w", s.config.Collection, err)
+	}
+	return nil
+}
+
+// identifier matches the class, table and column names that are spliced into
+// Weaviate GraphQL and pgvector SQL, optionally schema-qualified
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+func checkIdentifiers(names ...string) error {
+	for _, name := range names {
+		if !identifier.MatchString(name) {
+			return fmt.Errorf("invalid identifier %q", name)
+		}
+	}
+	return nil
+}
+
+// weaviateStore runs a nearVector GraphQL query against a Weaviate class.
+// Weaviate reports cosine distance, converted to similarity as 1 - distance.
+type weaviateStore struct {
+	config     config.VectorStoreConfig
+	httpClient *http.Client
+}
+
+func newWeaviateStore(cfg config.VectorStoreConfig, httpClient *http.Client) (*weaviateStore, error) {
+	if err := checkIdentifiers(cfg.Collection, cfg.TitleField, cfg.URLField, cfg.ContentField, cfg.HashField); err != nil {
+		return nil, err
+	}
+	if strings.Contains(cfg.Collection, ".") {
+		return nil, fmt.Errorf("invalid Weaviate class %q", cfg.Collection)
+	}
+	return &weaviateStore{config: cfg, httpClient: httpClient}, nil
+}
+
+func (s *weaviateStore) nearest(ctx context.Context, vector []float32, topK int, threshold float64) ([]vectorHit, error) {
+	nearVector := "vector: " + vectorLiteral(vector)
+	if threshold > 0 {
+		nearVector += ", distance: " + strconv.FormatFloat(1-threshold, 'f', -1, 64)
+	}
+	query := fmt.Sprintf("{ Get { %s(nearVector: {%s}, limit: %d) { %s %s %s _additional { id distance } } } }",
+		s.config.Collection, nearVector, topK, s.config.TitleField, s.config.URLField, s.config.ContentField)
+
+	var out weaviateGetResponse
+	if err := s.graphql(ctx, query, &out); err != nil {
+		return nil, err
+	}
+
+	objects := out.Data.Get[s.config.Collection]
+	hits := make([]vectorHit, 0, len(objects))
+	for _, object := range objects {
+		hit := vectorHit{fields: object}
+		if additional, ok := object["_additional"].(map[string]interface{}); ok {
+			hit.id = sourceField(additional, "id")
+			if distance, ok := additional["distance"].(float64); ok {
+				hit.score = 1 - distance
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+type weaviateGetResponse struct {
+	Data struct {
+		Get map[string][]map[string]interface{} `json:"Get"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (s *weaviateStore) contains(ctx context.Context, contentHash string) (bool, error) {
+	// The hash is hex, so it is safe to splice into the query
+	query := fmt.Sprintf(`{ Get { %s(where: {path: ["%s"], operator: Equal, valueText: "%s"}, limit: 1) { _additional { id } } } }`,
+		s.config.Collection, s.config.HashField, contentHash)
+	var out weaviateGetResponse
+	if err := s.graphql(ctx, query, &out); err != nil {
+		return false, err
+	}
+	return len(out.Data.Get[s.config.Collection]) > 0, nil
+}
+
+func (s *weaviateStore) upsert(ctx context.Context, chunks []vectorChunk) error {
+	objects := make([]map[string]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		objects[i] = map[string]interface{}{
+			"class":      s.config.Collection,
+			"id":         chunk.id,
+			"properties": chunk.fields,
+			"vector":     chunk.vector,
+		}
+	}
+	var out []struct {
+		Result struct {
+			Errors *struct {
+				Error []struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"errors"`
+		} `json:"result"`
+	}
+	endpoint := strings.TrimSuffix(s.config.URL, "/") + "/v1/batch/objects"
+	if err := requestJSON(ctx, s.httpClient, http.MethodPost, endpoint, s.header(), map[string]interface{}{"objects": objects}, &out); err != nil {
+		return fmt.Errorf("weaviate class %s: %w", s.config.Collection, err)
+	}
+	for _, object := range out {
+		if object.Result.Errors != nil && len(object.Result.Errors.Error) > 0 {
+			return fmt.Errorf("weaviate class %s: %s", s.config.Collection, object.Result.Errors.Error[0].Message)
+		}
+	}
+	return nil
+}
+
+func (s *weaviateStore) graphql(ctx context.Context, query string, out *weaviateGetResponse) error {
+	endpoint := strings.TrimSuffix(s.config.URL, "/") + "/v1/graphql"
+	if err := requestJSON(ctx, s.httpClient, http.MethodPost, endpoint, s.header(), map[string]string{"query": query}, out); err != nil {
+		return fmt.Errorf("weaviate class %s: %w", s.config.Collection, err)
+	}
+	if len(out.Errors) > 0 {
+		return fmt.Errorf("weaviate class %s: %s", s.config.Collection, out.Errors[0].Message)
+	}
+	return nil
+}
+
+func (s *weaviateStore) header() http.Header {
+	header := http.Header{}
+	if s.config.APIKey != "" {
+		header.Set("Authorization", "Bearer "+s.config.APIKey)
+	}
+	return header
+}
+
+// pgvectorStore queries a PostgreSQL table with a pgvector column by cosine
+// distance. database/sql needs a Postgres driver registered under the
+// configured name, linked into the search binary with a blank import.
+type pgvectorStore struct {
+	config        config.VectorStoreConfig
+	db            *sql.DB
+	nearestQuery  string
+	containsQuery string
+	insertQuery   string
+}
+
+func newPGVectorStore(cfg config.VectorStoreConfig) (*pgvectorStore, error) {
+	if err := checkIdentifiers(cfg.Collection, cfg.TitleField, cfg.URLField, cfg.ContentField, cfg.EmbeddingField, cfg.HashField); err != nil {
+		return nil, err
+	}
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("pgvector needs a dsn")
+	}
+	registered := false
+	for _, driver := range sql.Drivers() {
+		registered = registered || driver == cfg.Driver
+	}
+	if !registered {
+		return nil, fmt.Errorf("no database/sql driver %q linked into this binary for pgvector", cfg.Driver)
+	}
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pgvector database: %w", err)
+	}
+
+	// <=> is cosine distance; $1 is the query vector, $2 the minimum similarity
+	nearest := fmt.Sprintf(`SELECT %[2]s, %[3]s, %[4]s, 1 - (%[5]s <=> $1::vector) AS score
+FROM %[1]s
+WHERE 1 - (%[5]s <=> $1::vector) >= $2
+ORDER BY %[5]s <=> $1::vector
+LIMIT $3`, cfg.Collection, cfg.TitleField, cfg.URLField, cfg.ContentField, cfg.EmbeddingField)
+	return &pgvectorStore{
+		config:        cfg,
+		db:            db,
+		nearestQuery:  nearest,
+		containsQuery: fmt.Sprintf(`SELECT 1 FROM %s WHERE %s = $1 LIMIT 1`, cfg.Collection, cfg.HashField),
+		insertQuery: fmt.Sprintf(`INSERT INTO %s (%s, %s, %s, %s, %s) VALUES ($1, $2, $3, $4::vector, $5)`,
+			cfg.Collection, cfg.TitleField, cfg.URLField, cfg.ContentField, cfg.EmbeddingField, cfg.HashField),
+	}, nil
+}
+
+func (s *pgvectorStore) nearest(ctx context.Context, vector []float32, topK int, threshold float64) ([]vectorHit, error) {
+	rows, err := s.db.QueryContext(ctx, s.nearestQuery, vectorLiteral(vector), threshold, topK)
+	if err != nil {
+		return nil, fmt.Errorf("pgvector query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []vectorHit
+	for rows.Next() {
+		var title, link, content sql.NullString
+		var score float64
+		if err := rows.Scan(&title, &link, &content, &score); err != nil {
+			return nil, fmt.Errorf("pgvector scan failed: %w", err)
+		}
+		hits = append(hits, vectorHit{
+			fields: map[string]interface{}{
+				s.config.TitleField:   title.String,
+				s.config.URLField:     link.String,
+				s.config.ContentField: content.String,
+			},
+			score: score,
+		})
+	}
+	return hits, rows.Err()
+}
+
+func (s *pgvectorStore) contains(ctx context.Context, contentHash string) (bool, error) {
+	var one int
+	err := s.db.QueryRowContext(ctx, s.containsQuery, contentHash).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("pgvector lookup failed: %w", err)
+	}
+	return true, nil
+}
+
+// upsert inserts every chunk in one transaction; dedup by content hash
+// happens before, so rows are never updated
+func (s *pgvectorStore) upsert(ctx context.Context, chunks []vectorChunk) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("pgvector transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, chunk := range chunks {
+		_, err := tx.ExecContext(ctx, s.insertQuery,
+			sourceField(chunk.fields, s.config.TitleField),
+			sourceField(chunk.fields, s.config.URLField),
+			sourceField(chunk.fields, s.config.ContentField),
+			vectorLiteral(chunk.vector),
+			sourceField(chunk.fields, s.config.HashField),
+		)
+		if err != nil {
+			return fmt.Errorf("pgvector insert failed: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("pgvector commit failed: %w", err)
+	}
+	return nil
+}
+
+// vectorLiteral formats a vector as [x,y,...], accepted by both GraphQL and pgvector
+func vectorLiteral(vector []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
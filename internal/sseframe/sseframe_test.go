@@ -0,0 +1,72 @@
+package sseframe_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"ai-search-service/internal/sseframe"
+)
+
+// benchTokens are typical generated tokens plus ones that need escaping
+var benchTokens = []string{" The", " model", " summarizes", " results", ".", "\n", " \"quoted\"", " <b>", " café", " 東京"}
+
+const benchStreamID = "5f2c9a7e1b3d4c60"
+
+// encodeMapFrame encodes a token event as the gateway used to: a gin.H
+// marshaled by encoding/json, then rendered by gin-contrib/sse
+func encodeMapFrame(w *bytes.Buffer, token string, position int32, seq int64) {
+	payload, _ := json.Marshal(gin.H{"type": "token", "token": token, "position": position})
+	sse.Encode(w, sse.Event{
+		Id:    benchStreamID + ":" + strconv.FormatInt(seq, 10),
+		Event: "token",
+		Data:  json.RawMessage(payload),
+	})
+}
+
+// encodePooledFrame encodes a token event as the gateway does
+func encodePooledFrame(w *bytes.Buffer, token string, position int32, seq int64) {
+	buf := sseframe.GetBuffer()
+	buf.B = sseframe.AppendTokenData(buf.B, token, position)
+	payload := buf.B
+	buf.B = sseframe.AppendEvent(buf.B, benchStreamID, seq, "token", payload)
+	w.Write(buf.B[len(payload):])
+	sseframe.PutBuffer(buf)
+}
+
+func TestTokenEventMatchesGinRender(t *testing.T) {
+	var want, got bytes.Buffer
+	for i, token := range append(benchTokens, "\u2028", "a\x01b", "bad \xff utf-8", "&") {
+		want.Reset()
+		got.Reset()
+		encodeMapFrame(&want, token, int32(i), int64(i+1))
+		encodePooledFrame(&got, token, int32(i), int64(i+1))
+		if !bytes.Equal(want.Bytes(), got.Bytes()) {
+			t.Errorf("frames differ for token %q:\n%q\n%q", token, want.String(), got.String())
+		}
+	}
+}
+
+// benchmarkEncode reports the time and allocations encode takes per token
+// event. With stream buffering on, the gateway also copies each event's
+// data once to keep it for resumption.
+func benchmarkEncode(b *testing.B, encode func(*bytes.Buffer, string, int32, int64)) {
+	b.ReportAllocs()
+	var out bytes.Buffer
+	for i := 0; i < b.N; i++ {
+		out.Reset()
+		encode(&out, benchTokens[i%len(benchTokens)], int32(i), int64(i+1))
+	}
+}
+
+func BenchmarkTokenEvent(b *testing.B) {
+	benchmarkEncode(b, encodePooledFrame)
+}
+
+func BenchmarkTokenEventGinRender(b *testing.B) {
+	benchmarkEncode(b, encodeMapFrame)
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryRecords keeps records in maps, nothing surviving a restart
+type memoryRecords struct {
+	mu      sync.Mutex
+	records map[string]map[string]*memoryRecord // by collection and ID
+	lookups map[string]map[string]string        // record IDs by collection and lookup key
+}
+
+type memoryRecord struct {
+	data    []byte
+	lookups []string
+}
+
+func newMemoryRecords() *memoryRecords {
+	return &memoryRecords{records: make(map[string]map[string]*memoryRecord), lookups: make(map[string]map[string]string)}
+}
+
+func (r *memoryRecords) Put(ctx context.Context, collection, id string, data []byte, lookups ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.records[collection] == nil {
+		r.records[collection] = make(map[string]*memoryRecord)
+		r.lookups[collection] = make(map[string]string)
+	}
+	r.unindex(collection, id)
+	r.records[collection][id] = &memoryRecord{data: append([]byte(nil), data...), lookups: append([]string(nil), lookups...)}
+	for _, lookup := range lookups {
+		r.lookups[collection][lookup] = id
+	}
+	return nil
+}
+
+// unindex drops the lookup keys that still point to a record
+func (r *memoryRecords) unindex(collection, id string) {
+	record, ok := r.records[collection][id]
+	if !ok {
+		return
+	}
+	for _, lookup := range record.lookups {
+		if r.lookups[collection][lookup] == id {
+			delete(r.lookups[collection], lookup)
+		}
+	}
+}
+
+func (r *memoryRecords) Get(ctx context.Context, collection, id string) ([]byte, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[collection][id]
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte(nil), record.data...), true, nil
+}
+
+func (r *memoryRecords) Find(ctx context.Context, collection, lookup string) ([]byte, bool, error) {
+	r.mu.Lock()
+	id, ok := r.lookups[collection][lookup]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	return r.Get(ctx, collection, id)
+}
+
+func (r *memoryRecords) List(ctx context.Context, collection string) ([][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.records[collection]))
+	for id := range r.records[collection] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	all := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		all = append(all, append([]byte(nil), r.records[collection][id].data...))
+	}
+	return all, nil
+}
+
+func (r *memoryRecords) Delete(ctx context.Context, collection, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[collection][id]; !ok {
+		return false, nil
+	}
+	r.unindex(collection, id)
+	delete(r.records[collection], id)
+	return true, nil
+}
+
+// memoryEvents keeps each stream oldest first
+type memoryEvents struct {
+	mu      sync.Mutex
+	streams map[string][]memoryEvent
+}
+
+type memoryEvent struct {
+	at   time.Time
+	data []byte
+}
+
+func (e *memoryEvents) Append(ctx context.Context, stream string, at time.Time, data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.streams[stream] = append(e.streams[stream], memoryEvent{at: at, data: append([]byte(nil), data...)})
+	return nil
+}
+
+func (e *memoryEvents) Recent(ctx context.Context, stream string, limit int) ([][]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := e.streams[stream]
+	if limit <= 0 || limit > len(events) {
+		limit = len(events)
+	}
+	recent := make([][]byte, 0, limit)
+	for i := len(events) - 1; i >= len(events)-limit; i-- {
+		recent = append(recent, append([]byte(nil), events[i].data...))
+	}
+	return recent, nil
+}
+
+func (e *memoryEvents) Trim(ctx context.Context, stream string, keep int) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := e.streams[stream]
+	if len(events) <= keep {
+		return 0, nil
+	}
+	dropped := len(events) - keep
+	e.streams[stream] = append([]memoryEvent(nil), events[dropped:]...)
+	return dropped, nil
+}
+
+func (e *memoryEvents) Expire(ctx context.Context, stream string, before time.Time) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := e.streams[stream]
+	kept := events[:0]
+	for _, event := range events {
+		if !event.at.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	e.streams[stream] = kept
+	return len(events) - len(kept), nil
+}
+
+func (e *memoryEvents) Drop(ctx context.Context, stream string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.streams, stream)
+	return nil
+}
+
+type memoryCounters struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+func (c *memoryCounters) Add(ctx context.Context, name string, delta int64, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.counters[name]
+	if !ok || !time.Now().Before(counter.expires) {
+		counter = &memoryCounter{expires: time.Now().Add(ttl)}
+		c.counters[name] = counter
+	}
+	counter.value += delta
+	return counter.value, nil
+}
+
+func (c *memoryCounters) Get(ctx context.Context, name string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.counters[name]
+	if !ok || !time.Now().Before(counter.expires) {
+		return 0, nil
+	}
+	return counter.value, nil
+}
+
+func (c *memoryCounters) Prune(ctx context.Context) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pruned := 0
+	for name, counter := range c.counters {
+		if !time.Now().Before(counter.expires) {
+			delete(c.counters, name)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// memoryOutbox keeps events in the order they were added
+type memoryOutbox struct {
+	mu      sync.Mutex
+	entries []*memoryOutboxEntry
+}
+
+type memoryOutboxEntry struct {
+	event     *OutboxEvent
+	relaying  bool
+	published time.Time // zero until published
+}
+
+func (o *memoryOutbox) Add(ctx context.Context, event *OutboxEvent) error {
+	if err := event.prepare(); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	copied := *event
+	copied.Data = append([]byte(nil), event.Data...)
+	o.entries = append(o.entries, &memoryOutboxEntry{event: &copied})
+	return nil
+}
+
+func (o *memoryOutbox) Relay(ctx context.Context, limit int, publish func(ctx context.Context, events []*OutboxEvent) error) (int, error) {
+	o.mu.Lock()
+	var claimed []*memoryOutboxEntry
+	var events []*OutboxEvent
+	for _, entry := range o.entries {
+		if len(claimed) == limit {
+			break
+		}
+		if entry.published.IsZero() && !entry.relaying {
+			entry.relaying = true
+			claimed = append(claimed, entry)
+			copied := *entry.event
+			events = append(events, &copied)
+		}
+	}
+	o.mu.Unlock()
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	err := publish(ctx, events)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, entry := range claimed {
+		entry.relaying = false
+		if err == nil {
+			entry.published = time.Now()
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+func (o *memoryOutbox) Pending(ctx context.Context) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending := 0
+	for _, entry := range o.entries {
+		if entry.published.IsZero() {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (o *memoryOutbox) Purge(ctx context.Context, before time.Time) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	kept := o.entries[:0]
+	for _, entry := range o.entries {
+		if entry.published.IsZero() || !entry.published.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	purged := len(o.entries) - len(kept)
+	o.entries = kept
+	return purged, nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrations are applied in the order of the version their name starts
+// with, e.g. 0002_events.sql. Applied migrations must never change; later
+// schema changes are new files.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the advisory lock held while a migration is applied, so
+// that replicas starting together apply each one once
+const migrationLock = 0x61692d73746f7265 // "ai-store"
+
+// migration is one embedded schema change
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// Migrate applies the embedded migrations that schema_migrations does not
+// list yet, each in a transaction of its own, and returns how many it
+// applied
+func Migrate(ctx context.Context, db *sql.DB) (int, error) {
+	pending, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+		return 0, fmt.Errorf("store: failed to create schema_migrations: %w", err)
+	}
+	applied := 0
+	for _, m := range pending {
+		done, err := apply(ctx, db, m)
+		if err != nil {
+			return applied, fmt.Errorf("store: migration %s failed: %w", m.name, err)
+		}
+		if done {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// apply runs a migration unless it has been applied, reporting whether it ran
+func apply(ctx context.Context, db *sql.DB, m migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(migrationLock)); err != nil {
+		return false, err
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.version).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// loadMigrations reads the embedded migrations, ordered by version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var list []migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("store: migration %s must be named <version>_<name>.sql", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("store: migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+		data, err := migrations.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, migration{version: version, name: name, sql: string(data)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	return list, nil
+}
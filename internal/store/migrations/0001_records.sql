@@ -0,0 +1,18 @@
+-- JSON documents by collection and ID, with unique lookup keys per collection
+CREATE TABLE records (
+    collection TEXT NOT NULL,
+    id TEXT NOT NULL,
+    data JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (collection, id)
+);
+
+CREATE TABLE record_lookups (
+    collection TEXT NOT NULL,
+    lookup TEXT NOT NULL,
+    id TEXT NOT NULL,
+    PRIMARY KEY (collection, lookup),
+    FOREIGN KEY (collection, id) REFERENCES records (collection, id) ON DELETE CASCADE
+);
+
+CREATE INDEX record_lookups_record ON record_lookups (collection, id);
@@ -0,0 +1,10 @@
+-- Append-only event streams, read newest first
+CREATE TABLE events (
+    seq BIGSERIAL PRIMARY KEY,
+    stream TEXT NOT NULL,
+    at TIMESTAMPTZ NOT NULL,
+    data JSONB NOT NULL
+);
+
+CREATE INDEX events_stream_seq ON events (stream, seq DESC);
+CREATE INDEX events_stream_at ON events (stream, at);
@@ -0,0 +1,8 @@
+-- Counters that start again from zero once expired
+CREATE TABLE counters (
+    name TEXT PRIMARY KEY,
+    value BIGINT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX counters_expires_at ON counters (expires_at);
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// querier runs statements on the database, or within a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction of its own, or in the one q already is
+func inTx(ctx context.Context, q querier, fn func(q querier) error) error {
+	db, ok := q.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type pgRecords struct {
+	db querier
+}
+
+func (r *pgRecords) Put(ctx context.Context, collection, id string, data []byte, lookups ...string) error {
+	return inTx(ctx, r.db, func(q querier) error {
+		if _, err := q.ExecContext(ctx, `INSERT INTO records (collection, id, data) VALUES ($1, $2, $3)
+ON CONFLICT (collection, id) DO UPDATE SET data = EXCLUDED.data, updated_at = now()`, collection, id, string(data)); err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, `DELETE FROM record_lookups WHERE collection = $1 AND id = $2`, collection, id); err != nil {
+			return err
+		}
+		// A lookup key moves to the record saved with it last
+		for _, lookup := range lookups {
+			if _, err := q.ExecContext(ctx, `INSERT INTO record_lookups (collection, lookup, id) VALUES ($1, $2, $3)
+ON CONFLICT (collection, lookup) DO UPDATE SET id = EXCLUDED.id`, collection, lookup, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *pgRecords) Get(ctx context.Context, collection, id string) ([]byte, bool, error) {
+	return scanData(r.db.QueryRowContext(ctx, `SELECT data FROM records WHERE collection = $1 AND id = $2`, collection, id))
+}
+
+func (r *pgRecords) Find(ctx context.Context, collection, lookup string) ([]byte, bool, error) {
+	return scanData(r.db.QueryRowContext(ctx, `SELECT r.data FROM record_lookups l
+JOIN records r ON r.collection = l.collection AND r.id = l.id
+WHERE l.collection = $1 AND l.lookup = $2`, collection, lookup))
+}
+
+func (r *pgRecords) List(ctx context.Context, collection string) ([][]byte, error) {
+	return queryData(ctx, r.db, `SELECT data FROM records WHERE collection = $1 ORDER BY id`, collection)
+}
+
+func (r *pgRecords) Delete(ctx context.Context, collection, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM records WHERE collection = $1 AND id = $2`, collection, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+type pgEvents struct {
+	db querier
+}
+
+func (e *pgEvents) Append(ctx context.Context, stream string, at time.Time, data []byte) error {
+	_, err := e.db.ExecContext(ctx, `INSERT INTO events (stream, at, data) VALUES ($1, $2, $3)`, stream, at, string(data))
+	return err
+}
+
+func (e *pgEvents) Recent(ctx context.Context, stream string, limit int) ([][]byte, error) {
+	// LIMIT NULL returns every event
+	var max sql.NullInt64
+	if limit > 0 {
+		max = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+	return queryData(ctx, e.db, `SELECT data FROM events WHERE stream = $1 ORDER BY seq DESC LIMIT $2`, stream, max)
+}
+
+func (e *pgEvents) Trim(ctx context.Context, stream string, keep int) (int, error) {
+	return exec(ctx, e.db, `DELETE FROM events WHERE stream = $1 AND seq <= (
+    SELECT seq FROM events WHERE stream = $1 ORDER BY seq DESC OFFSET $2 LIMIT 1
+)`, stream, keep)
+}
+
+func (e *pgEvents) Expire(ctx context.Context, stream string, before time.Time) (int, error) {
+	return exec(ctx, e.db, `DELETE FROM events WHERE stream = $1 AND at < $2`, stream, before)
+}
+
+func (e *pgEvents) Drop(ctx context.Context, stream string) error {
+	_, err := e.db.ExecContext(ctx, `DELETE FROM events WHERE stream = $1`, stream)
+	return err
+}
+
+// pgCounters times counters by the database clock, shared by every replica
+type pgCounters struct {
+	db querier
+}
+
+func (c *pgCounters) Add(ctx context.Context, name string, delta int64, ttl time.Duration) (int64, error) {
+	var value int64
+	err := c.db.QueryRowContext(ctx, `INSERT INTO counters (name, value, expires_at)
+VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+ON CONFLICT (name) DO UPDATE SET
+    value = CASE WHEN counters.expires_at <= now() THEN EXCLUDED.value ELSE counters.value + EXCLUDED.value END,
+    expires_at = CASE WHEN counters.expires_at <= now() THEN EXCLUDED.expires_at ELSE counters.expires_at END
+RETURNING value`, name, delta, ttl.Milliseconds()).Scan(&value)
+	return value, err
+}
+
+func (c *pgCounters) Get(ctx context.Context, name string) (int64, error) {
+	var value int64
+	err := c.db.QueryRowContext(ctx, `SELECT value FROM counters WHERE name = $1 AND expires_at > now()`, name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return value, err
+}
+
+func (c *pgCounters) Prune(ctx context.Context) (int, error) {
+	return exec(ctx, c.db, `DELETE FROM counters WHERE expires_at <= now()`)
+}
+
+// scanData returns the data column of a single row
+func scanData(row *sql.Row) ([]byte, bool, error) {
+	var data []byte
+	err := row.Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// queryData returns the data column of every row
+func queryData(ctx context.Context, db querier, query string, args ...interface{}) ([][]byte, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var all [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		all = append(all, data)
+	}
+	return all, rows.Err()
+}
+
+// exec runs a statement and returns how many rows it affected
+func exec(ctx context.Context, db querier, query string, args ...interface{}) (int, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+type pgOutbox struct {
+	db querier
+}
+
+func (o *pgOutbox) Add(ctx context.Context, event *OutboxEvent) error {
+	if err := event.prepare(); err != nil {
+		return err
+	}
+	_, err := o.db.ExecContext(ctx, `INSERT INTO outbox (event_id, topic, key, data, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		event.ID, event.Topic, event.Key, string(event.Data), event.CreatedAt)
+	return err
+}
+
+// Relay locks the events it passes on, skipping those another relay holds,
+// until it has marked them published
+func (o *pgOutbox) Relay(ctx context.Context, limit int, publish func(ctx context.Context, events []*OutboxEvent) error) (int, error) {
+	published := 0
+	err := inTx(ctx, o.db, func(q querier) error {
+		rows, err := q.QueryContext(ctx, `SELECT seq, event_id, topic, key, data, created_at FROM outbox
+WHERE published_at IS NULL ORDER BY seq LIMIT $1 FOR UPDATE SKIP LOCKED`, limit)
+		if err != nil {
+			return err
+		}
+		var events []*OutboxEvent
+		var seqs []int64
+		for rows.Next() {
+			var event OutboxEvent
+			var data []byte
+			if err := rows.Scan(&event.seq, &event.ID, &event.Topic, &event.Key, &data, &event.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			event.Data = data
+			events = append(events, &event)
+			seqs = append(seqs, event.seq)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(events) == 0 {
+			return err
+		}
+		if err := publish(ctx, events); err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, `UPDATE outbox SET published_at = now() WHERE seq = ANY($1)`, seqs); err != nil {
+			return err
+		}
+		published = len(events)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, nil
+}
+
+func (o *pgOutbox) Pending(ctx context.Context) (int, error) {
+	var pending int
+	err := o.db.QueryRowContext(ctx, `SELECT count(*) FROM outbox WHERE published_at IS NULL`).Scan(&pending)
+	return pending, err
+}
+
+func (o *pgOutbox) Purge(ctx context.Context, before time.Time) (int, error) {
+	return exec(ctx, o.db, `DELETE FROM outbox WHERE published_at < $1`, before)
+}
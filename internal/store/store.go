@@ -0,0 +1,239 @@
+// Package store keeps the data of subsystems that need durable storage, the
+// admin audit log, usage counters, saved queries and tenants, in Postgres
+// instead of Redis or memory. It offers the repositories the subsystems
+// build on: Records (JSON documents by collection and ID, findable by
+// lookup keys), Events (append-only streams, newest first), Counters
+// (counters that restart once expired) and the Outbox of events to publish,
+// written in the same transaction (Atomic) as the changes they report. The
+// memory backend implements them for tests and single-process deployments;
+// the schema is created and upgraded by the migrations embedded in this
+// package.
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+)
+
+// Subsystems that can opt into the store through store.subsystems
+const (
+	AuditLog     = "audit_log"
+	Usage        = "usage"
+	SavedQueries = "saved_queries"
+	Tenants      = "tenants"
+)
+
+// Backends of store.backend
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Records keeps JSON documents by collection and ID. A record may be saved
+// with lookup keys, unique within its collection, to find it by.
+type Records interface {
+	// Put creates or replaces a record and replaces its lookup keys
+	Put(ctx context.Context, collection, id string, data []byte, lookups ...string) error
+	// Get returns found=false for unknown IDs
+	Get(ctx context.Context, collection, id string) (data []byte, found bool, err error)
+	// Find returns the record saved with a lookup key, found=false for none
+	Find(ctx context.Context, collection, lookup string) (data []byte, found bool, err error)
+	// List returns a collection's records ordered by ID
+	List(ctx context.Context, collection string) ([][]byte, error)
+	// Delete drops a record and its lookup keys, reporting whether it existed
+	Delete(ctx context.Context, collection, id string) (bool, error)
+}
+
+// Events keeps append-only streams of JSON events
+type Events interface {
+	Append(ctx context.Context, stream string, at time.Time, data []byte) error
+	// Recent returns up to limit events, newest first; all when limit <= 0
+	Recent(ctx context.Context, stream string, limit int) ([][]byte, error)
+	// Trim keeps a stream's newest keep events, returning how many it dropped
+	Trim(ctx context.Context, stream string, keep int) (int, error)
+	// Expire drops a stream's events from before the given time, returning
+	// how many it dropped
+	Expire(ctx context.Context, stream string, before time.Time) (int, error)
+	// Drop deletes a stream
+	Drop(ctx context.Context, stream string) error
+}
+
+// Counters are named counters that expire a ttl after they start and then
+// start again from zero
+type Counters interface {
+	// Add adds delta to a counter, starting it when it is missing or
+	// expired, and returns its value
+	Add(ctx context.Context, name string, delta int64, ttl time.Duration) (int64, error)
+	// Get returns a counter's value, 0 when it is missing or expired
+	Get(ctx context.Context, name string) (int64, error)
+	// Prune deletes the expired counters, returning how many it deleted
+	Prune(ctx context.Context) (int, error)
+}
+
+// OutboxEvent is an event to publish to the event bus, stored in the outbox
+// with the state change it reports
+type OutboxEvent struct {
+	ID        string          `json:"id"`    // unique, for consumers to drop redeliveries
+	Topic     string          `json:"topic"` // e.g. search.completed
+	Key       string          `json:"key"`   // what the event is about, e.g. a tenant or saved query ID
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	seq int64 // position in the outbox
+}
+
+// Outbox keeps events until a relay has published them, so that an event is
+// stored if and only if the state change it reports is
+type Outbox interface {
+	// Add stores an event, assigning its ID and creation time when unset
+	Add(ctx context.Context, event *OutboxEvent) error
+	// Relay passes up to limit unpublished events, oldest first, to publish
+	// and marks them published when it succeeds; concurrent relays are
+	// passed different events. It returns how many it published.
+	Relay(ctx context.Context, limit int, publish func(ctx context.Context, events []*OutboxEvent) error) (int, error)
+	// Pending counts the unpublished events
+	Pending(ctx context.Context) (int, error)
+	// Purge deletes the events published before the given time, returning
+	// how many it deleted
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+// Tx holds the repositories within one transaction
+type Tx struct {
+	Records  Records
+	Events   Events
+	Counters Counters
+	Outbox   Outbox
+}
+
+// Store holds the repositories of the configured backend and the subsystems
+// kept there
+type Store struct {
+	Records  Records
+	Events   Events
+	Counters Counters
+	Outbox   Outbox
+
+	subsystems map[string]bool
+	db         *sql.DB    // nil for the memory backend
+	mu         sync.Mutex // serializes Atomic on the memory backend
+}
+
+// Open connects the configured backend, applying pending migrations to
+// Postgres when store.migrate is set. It returns nil when no backend is
+// configured.
+func Open(ctx context.Context, cfg config.StoreConfig) (*Store, error) {
+	s := &Store{subsystems: make(map[string]bool)}
+	for _, subsystem := range cfg.Subsystems {
+		switch subsystem {
+		case AuditLog, Usage, SavedQueries, Tenants:
+			s.subsystems[subsystem] = true
+		default:
+			return nil, fmt.Errorf("store: unknown subsystem %q (audit_log, usage, saved_queries or tenants)", subsystem)
+		}
+	}
+
+	switch cfg.Backend {
+	case "":
+		if len(s.subsystems) > 0 {
+			return nil, fmt.Errorf("store: subsystems %v need a backend", cfg.Subsystems)
+		}
+		return nil, nil
+	case BackendMemory:
+		s.Records = newMemoryRecords()
+		s.Events = &memoryEvents{streams: make(map[string][]memoryEvent)}
+		s.Counters = &memoryCounters{counters: make(map[string]*memoryCounter)}
+		s.Outbox = &memoryOutbox{}
+		return s, nil
+	case BackendPostgres:
+	default:
+		return nil, fmt.Errorf("store: unknown backend %q (postgres or memory)", cfg.Backend)
+	}
+
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("store: postgres needs a dsn")
+	}
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to open the database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: failed to connect to the database: %w", err)
+	}
+	if cfg.Migrate {
+		applied, err := Migrate(ctx, db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		if applied > 0 {
+			logger.GetLogger().Infof("Applied %d store migrations", applied)
+		}
+	}
+	s.db = db
+	s.Records, s.Events, s.Counters, s.Outbox = &pgRecords{db: db}, &pgEvents{db: db}, &pgCounters{db: db}, &pgOutbox{db: db}
+	return s, nil
+}
+
+// Atomic runs fn in a transaction, committed when fn returns nil. The
+// memory backend runs one fn at a time and cannot roll back.
+func (s *Store) Atomic(ctx context.Context, fn func(tx *Tx) error) error {
+	if s.db == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return fn(&Tx{Records: s.Records, Events: s.Events, Counters: s.Counters, Outbox: s.Outbox})
+	}
+	return inTx(ctx, s.db, func(q querier) error {
+		return fn(&Tx{Records: &pgRecords{db: q}, Events: &pgEvents{db: q}, Counters: &pgCounters{db: q}, Outbox: &pgOutbox{db: q}})
+	})
+}
+
+// Keeps reports whether a subsystem opted into the store; always false
+// without one
+func (s *Store) Keeps(subsystem string) bool {
+	return s != nil && s.subsystems[subsystem]
+}
+
+// Ping checks the database connection
+func (s *Store) Ping(ctx context.Context) error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database connections
+func (s *Store) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// prepare assigns an event's ID and creation time when unset
+func (e *OutboxEvent) prepare() error {
+	if e.ID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		e.ID = hex.EncodeToString(id)
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	return nil
+}
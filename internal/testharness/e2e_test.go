@@ -0,0 +1,38 @@
+package testharness
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"ai-search-service/internal/logger"
+)
+
+var (
+	update          = flag.Bool("update", false, "rewrite the golden SSE traces instead of comparing against them")
+	scenarioTimeout = flag.Duration("scenario-timeout", 30*time.Second, "per-scenario timeout")
+)
+
+// TestE2E runs the pipeline suite against one harness of in-memory services
+// and fakes, each scenario as a subtest: go test -run 'TestE2E/<name>'
+func TestE2E(t *testing.T) {
+	UpdateGolden = *update
+	logger.InitLogger("warn")
+
+	h, err := Start()
+	if err != nil {
+		t.Fatalf("Failed to start test harness: %v", err)
+	}
+	defer h.Close()
+
+	for _, scenario := range Scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), *scenarioTimeout)
+			defer cancel()
+			if err := scenario.Run(ctx, h); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
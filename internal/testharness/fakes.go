@@ -0,0 +1,1659 @@
+package testharness
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"ai-search-service/internal/ollama"
+	"ai-search-service/internal/prompt"
+	pb "ai-search-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// FakeGoogle serves the Google Custom Search JSON API. An item's "image" is
+// served as its pagemap thumbnail and its "content_tier" as a meta tag.
+type FakeGoogle struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	items     []map[string]string
+	failWith  int // HTTP status to fail with; 0 serves items
+	requests  int
+	lastQuery url.Values
+}
+
+// NewFakeGoogle starts a fake Custom Search endpoint returning three results
+func NewFakeGoogle() *FakeGoogle {
+	f := &FakeGoogle{
+		items: []map[string]string{
+			{"title": "Go Programming Language", "link": "https://go.dev", "snippet": "Go is an open source programming language that makes it simple to build secure, scalable systems.", "displayLink": "go.dev", "image": "https://go.dev/images/go-logo-blue.svg"},
+			{"title": "Effective Go", "link": "https://go.dev/doc/effective_go", "snippet": "Tips for writing clear, idiomatic Go code.", "displayLink": "go.dev"},
+			{"title": "Go by Example", "link": "https://gobyexample.com", "snippet": "A hands-on introduction to Go using annotated example programs.", "displayLink": "gobyexample.com"},
+		},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// FailWith makes subsequent searches return a Google API error with the given status
+func (f *FakeGoogle) FailWith(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failWith = status
+}
+
+// SetItems replaces the results served, returning the previous ones
+func (f *FakeGoogle) SetItems(items []map[string]string) []map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	previous := f.items
+	f.items = items
+	return previous
+}
+
+// Requests returns how many searches were served
+func (f *FakeGoogle) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// LastQuery returns the query parameters of the latest search
+func (f *FakeGoogle) LastQuery() url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastQuery
+}
+
+// fakeCompletions are the autocomplete entries served for matching prefixes
+var fakeCompletions = []string{"golang tutorial", "golang torrents", "golang forbidden topic", "kubernetes operators"}
+
+func (f *FakeGoogle) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/complete/search" {
+		f.complete(w, r)
+		return
+	}
+	f.mu.Lock()
+	f.requests++
+	f.lastQuery = r.URL.Query()
+	status, items := f.failWith, f.items
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status != 0 {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": status, "message": "injected failure"},
+		})
+		return
+	}
+	served := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		served[i] = make(map[string]interface{}, len(item))
+		for k, v := range item {
+			served[i][k] = v
+		}
+		pagemap := make(map[string]interface{})
+		if image, ok := item["image"]; ok {
+			delete(served[i], "image")
+			pagemap["cse_thumbnail"] = []map[string]string{{"src": image}}
+		}
+		if tier, ok := item["content_tier"]; ok {
+			delete(served[i], "content_tier")
+			pagemap["metatags"] = []map[string]string{{"article:content_tier": tier}}
+		}
+		if len(pagemap) > 0 {
+			served[i]["pagemap"] = pagemap
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"items": served})
+}
+
+// FakeWebhook records the JSON bodies posted to it
+type FakeWebhook struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	bodies []json.RawMessage
+}
+
+// NewFakeWebhook starts a webhook receiver that accepts every post
+func NewFakeWebhook() *FakeWebhook {
+	f := &FakeWebhook{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || !json.Valid(body) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.bodies = append(f.bodies, body)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return f
+}
+
+// Received returns the bodies posted so far
+func (f *FakeWebhook) Received() []json.RawMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]json.RawMessage(nil), f.bodies...)
+}
+
+// waitFor polls until a received body contains text
+func (f *FakeWebhook) waitFor(ctx context.Context, text string) error {
+	for {
+		for _, body := range f.Received() {
+			if strings.Contains(string(body), text) {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no webhook body containing %q: %w", text, ctx.Err())
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// complete answers autocomplete requests in the browser suggestion format
+func (f *FakeGoogle) complete(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.ToLower(r.URL.Query().Get("q"))
+	completions := []string{}
+	for _, completion := range fakeCompletions {
+		if strings.HasPrefix(completion, prefix) {
+			completions = append(completions, completion)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode([]interface{}{prefix, completions})
+}
+
+// FakePDFRenderer serves Gotenberg's HTML conversion route, answering with a
+// stub PDF that embeds the uploaded page
+type FakePDFRenderer struct {
+	*httptest.Server
+}
+
+// NewFakePDFRenderer starts a renderer that requires an index.html upload
+func NewFakePDFRenderer() *FakePDFRenderer {
+	return &FakePDFRenderer{Server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/forms/chromium/convert/html" {
+			http.NotFound(w, r)
+			return
+		}
+		file, header, err := r.FormFile("files")
+		if err != nil || header.Filename != "index.html" {
+			http.Error(w, "index.html is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		page, _ := io.ReadAll(file)
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprintf(w, "%%PDF-1.7\n%s\n%%%%EOF\n", page)
+	}))}
+}
+
+// FakeDictionary serves the dictionaryapi.dev entries API for a few words,
+// answering 404 for the rest
+type FakeDictionary struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests int
+}
+
+// fakeDefinitions are the entries the fake dictionary knows
+var fakeDefinitions = map[string]string{
+	"ephemeral": `[{"word":"ephemeral","meanings":[{"partOfSpeech":"adjective","definitions":[{"definition":"Lasting for a very short time.","example":"fashions are ephemeral"}]},{"partOfSpeech":"noun","definitions":[{"definition":"A plant that lives for a very short time."}]}]}]`,
+}
+
+// NewFakeDictionary starts a dictionary under /api/v2/entries/en/
+func NewFakeDictionary() *FakeDictionary {
+	f := &FakeDictionary{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests++
+		f.mu.Unlock()
+		entry, ok := fakeDefinitions[strings.TrimPrefix(r.URL.Path, "/api/v2/entries/en/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"title":"No Definitions Found"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, entry)
+	}))
+	return f
+}
+
+// Requests returns how many lookups the dictionary received
+func (f *FakeDictionary) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// FakeImages serves result thumbnails: /photo.jpg, a JPEG carrying EXIF
+// with ExifMarker and a comment, /logo.svg and /huge.png, a PNG of 100 KiB
+type FakeImages struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests int
+}
+
+// ExifMarker is the text in /photo.jpg's EXIF segment
+const ExifMarker = "GPS 48.8584N 2.2945E"
+
+// NewFakeImages starts the image host
+func NewFakeImages() *FakeImages {
+	f := &FakeImages{}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 0, G: 173, B: 216, A: 255}}, image.Point{}, draw.Src)
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, img, nil)
+	segment := func(marker byte, payload string) []byte {
+		return append([]byte{0xFF, marker, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+	}
+	photo := append([]byte{0xFF, 0xD8}, segment(0xE1, "Exif\x00\x00"+ExifMarker)...)
+	photo = append(photo, segment(0xFE, "shot on a fake camera")...)
+	photo = append(photo, encoded.Bytes()[2:]...)
+	huge := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100<<10)...)
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests++
+		f.mu.Unlock()
+		switch r.URL.Path {
+		case "/photo.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write(photo)
+		case "/logo.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`))
+		case "/huge.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(huge)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return f
+}
+
+// Requests returns how many images were requested
+func (f *FakeImages) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// FakeAnswerAPIs serves the OpenWeather current weather API under
+// /weather, the Yahoo Finance chart API under /finance and TheSportsDB
+// under /sports, knowing Paris, AAPL and Arsenal and answering 404 (or
+// empty lists, as TheSportsDB does) for the rest
+type FakeAnswerAPIs struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+// NewFakeAnswerAPIs starts the answer provider APIs
+func NewFakeAnswerAPIs() *FakeAnswerAPIs {
+	f := &FakeAnswerAPIs{requests: make(map[string]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// Requests returns how many requests the API under prefix ("weather",
+// "finance" or "sports") received
+func (f *FakeAnswerAPIs) Requests(prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[prefix]
+}
+
+func (f *FakeAnswerAPIs) handle(w http.ResponseWriter, r *http.Request) {
+	prefix, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	f.mu.Lock()
+	f.requests[prefix]++
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	switch {
+	case prefix == "weather" && path == "weather" && strings.EqualFold(query.Get("q"), "paris") && query.Get("appid") != "":
+		fmt.Fprint(w, `{"id":2988507,"name":"Paris","dt":1760000000,"sys":{"country":"FR"},"weather":[{"description":"light rain"}],"main":{"temp":18.2,"feels_like":17.6,"temp_min":16.1,"temp_max":19.4,"humidity":72},"wind":{"speed":4.1}}`)
+	case prefix == "weather":
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"cod":"404","message":"city not found"}`)
+	case prefix == "finance" && path == "v8/finance/chart/AAPL":
+		fmt.Fprint(w, `{"chart":{"result":[{"meta":{"symbol":"AAPL","currency":"USD","exchangeName":"NMS","longName":"Apple Inc.","regularMarketPrice":189.5,"chartPreviousClose":187.0,"regularMarketDayHigh":190.1,"regularMarketDayLow":186.8,"regularMarketTime":1760000000}}],"error":null}}`)
+	case prefix == "finance":
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"chart":{"result":null,"error":{"code":"Not Found","description":"No data found, symbol may be delisted"}}}`)
+	case prefix == "sports" && path == "searchteams.php" && strings.EqualFold(query.Get("t"), "arsenal"):
+		fmt.Fprint(w, `{"teams":[{"idTeam":"133604","strTeam":"Arsenal"}]}`)
+	case prefix == "sports" && path == "eventslast.php" && query.Get("id") == "133604":
+		fmt.Fprint(w, `{"results":[{"idEvent":"2070001","strLeague":"English Premier League","strHomeTeam":"Arsenal","strAwayTeam":"Chelsea","intHomeScore":"2","intAwayScore":"1","dateEvent":"2026-10-11","strVenue":"Emirates Stadium"}]}`)
+	case prefix == "sports" && path == "eventsnext.php" && query.Get("id") == "133604":
+		fmt.Fprint(w, `{"events":[{"idEvent":"2070002","strLeague":"English Premier League","strHomeTeam":"Liverpool","strAwayTeam":"Arsenal","dateEvent":"2026-10-18","strTime":"15:00:00","strVenue":"Anfield"}]}`)
+	case prefix == "sports":
+		fmt.Fprint(w, `{"teams":null,"results":null,"events":null}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// FakeElasticsearch serves the _search API of an Elasticsearch or OpenSearch
+// index. Documents match when the query mentions their topic word.
+type FakeElasticsearch struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	docs     []map[string]string
+	requests int
+}
+
+// NewFakeElasticsearch starts a fake index holding two onboarding documents
+func NewFakeElasticsearch() *FakeElasticsearch {
+	f := &FakeElasticsearch{
+		docs: []map[string]string{
+			{"title": "Engineering Onboarding Guide", "url": "https://wiki.internal/onboarding", "content": "Your first week: set up the Go toolchain, request repository access and pair with your onboarding buddy.", "topic": "onboarding"},
+			{"title": "Onboarding Checklist", "url": "https://wiki.internal/onboarding/checklist", "content": "Complete security training, join the on-call rotation shadow and read the service handbook.", "topic": "onboarding"},
+		},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// Requests returns how many searches were served
+func (f *FakeElasticsearch) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *FakeElasticsearch) handle(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Query struct {
+			MultiMatch struct {
+				Query string `json:"query"`
+			} `json:"multi_match"`
+		} `json:"query"`
+	}
+	if !strings.HasSuffix(r.URL.Path, "/_search") || json.NewDecoder(r.Body).Decode(&body) != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests++
+	docs := f.docs
+	f.mu.Unlock()
+
+	query := strings.ToLower(body.Query.MultiMatch.Query)
+	hits := []map[string]interface{}{}
+	for i, doc := range docs {
+		if !strings.Contains(query, doc["topic"]) {
+			continue
+		}
+		hits = append(hits, map[string]interface{}{
+			"_id":       fmt.Sprintf("doc-%d", i),
+			"_score":    8.0 / float64(len(hits)+1),
+			"_source":   map[string]string{"title": doc["title"], "url": doc["url"], "content": doc["content"]},
+			"highlight": map[string][]string{"content": {doc["content"][:40]}},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+}
+
+// FakeOllama serves /api/generate and /api/version with a canned response
+type FakeOllama struct {
+	*httptest.Server
+	Response string
+
+	mu         sync.Mutex
+	lastPrompt string
+	breakAfter int // words streamed before a stream breaks off; 0 completes them
+}
+
+// NewFakeOllama starts a fake Ollama server
+func NewFakeOllama() *FakeOllama {
+	f := &FakeOllama{Response: "Go is a simple, fast language for building reliable software."}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ollama.VersionResponse{Version: "0.0.0-fake"})
+	})
+	mux.HandleFunc("/api/generate", f.generate)
+	mux.HandleFunc("/api/embed", f.embed)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+func (f *FakeOllama) embed(w http.ResponseWriter, r *http.Request) {
+	var req ollama.EmbedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+	resp := ollama.EmbedResponse{Model: req.Model}
+	for _, text := range req.Input {
+		resp.Embeddings = append(resp.Embeddings, fakeEmbedding(text))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// embeddingVocabulary are the dimensions of fake embeddings. Text without any
+// of these words embeds to the zero vector and is similar to nothing.
+var embeddingVocabulary = []string{"incident", "runbook", "rollback", "pager", "latency"}
+
+// fakeEmbedding counts vocabulary words in text, so texts sharing words are
+// close under cosine similarity
+func fakeEmbedding(text string) []float32 {
+	vector := make([]float32, len(embeddingVocabulary))
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		for i, term := range embeddingVocabulary {
+			if strings.Trim(word, ".,:;!?") == term {
+				vector[i]++
+			}
+		}
+	}
+	return vector
+}
+
+// FakeQdrant serves Qdrant's points search, scroll and upsert APIs. Seed
+// documents are embedded with fakeEmbedding; upserted points keep the vector
+// they were sent with.
+type FakeQdrant struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	points   []qdrantPoint
+	requests int
+}
+
+type qdrantPoint struct {
+	ID      interface{}            `json:"id"`
+	Vector  []float32              `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// NewFakeQdrant starts a fake collection of two incident runbooks
+func NewFakeQdrant() *FakeQdrant {
+	f := &FakeQdrant{}
+	for i, doc := range []map[string]interface{}{
+		{"title": "Incident Runbook: API Latency", "url": "https://runbooks.internal/api-latency", "content": "When the pager fires for latency, check recent deploys and roll back if needed."},
+		{"title": "Rollback Procedure", "url": "https://runbooks.internal/rollback", "content": "Use the deploy tool to roll back; a rollback needs no incident review."},
+	} {
+		vector := fakeEmbedding(doc["title"].(string) + " " + doc["content"].(string))
+		f.points = append(f.points, qdrantPoint{ID: i + 1, Vector: vector, Payload: doc})
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// Requests returns how many searches were served
+func (f *FakeQdrant) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// Points returns how many points the collection holds
+func (f *FakeQdrant) Points() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.points)
+}
+
+// HasText reports whether a point's payload contains text
+func (f *FakeQdrant) HasText(text string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, point := range f.points {
+		for _, value := range point.Payload {
+			if s, ok := value.(string); ok && strings.Contains(s, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *FakeQdrant) handle(w http.ResponseWriter, r *http.Request) {
+	var result interface{}
+	var ok bool
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/points/search"):
+		result, ok = f.search(r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/points/scroll"):
+		result, ok = f.scroll(r)
+	case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/points"):
+		result, ok = f.upsert(r)
+	}
+	if !ok {
+		http.Error(w, `{"status":{"error":"bad request"}}`, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": result, "status": "ok"})
+}
+
+func (f *FakeQdrant) search(r *http.Request) (interface{}, bool) {
+	var body struct {
+		Vector         []float32 `json:"vector"`
+		Limit          int       `json:"limit"`
+		ScoreThreshold float64   `json:"score_threshold"`
+	}
+	if json.NewDecoder(r.Body).Decode(&body) != nil {
+		return nil, false
+	}
+
+	f.mu.Lock()
+	f.requests++
+	stored := f.points
+	f.mu.Unlock()
+
+	type hit struct {
+		ID      interface{}            `json:"id"`
+		Score   float64                `json:"score"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	hits := []hit{}
+	for _, point := range stored {
+		score := cosine(body.Vector, point.Vector)
+		if score >= body.ScoreThreshold && score > 0 {
+			hits = append(hits, hit{ID: point.ID, Score: score, Payload: point.Payload})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if body.Limit > 0 && len(hits) > body.Limit {
+		hits = hits[:body.Limit]
+	}
+	return hits, true
+}
+
+// scroll supports the single "must match" filter used for dedup lookups
+func (f *FakeQdrant) scroll(r *http.Request) (interface{}, bool) {
+	var body struct {
+		Filter struct {
+			Must []struct {
+				Key   string `json:"key"`
+				Match struct {
+					Value interface{} `json:"value"`
+				} `json:"match"`
+			} `json:"must"`
+		} `json:"filter"`
+		Limit int `json:"limit"`
+	}
+	if json.NewDecoder(r.Body).Decode(&body) != nil {
+		return nil, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	matched := []map[string]interface{}{}
+	for _, point := range f.points {
+		match := true
+		for _, cond := range body.Filter.Must {
+			if point.Payload[cond.Key] != cond.Match.Value {
+				match = false
+			}
+		}
+		if match {
+			matched = append(matched, map[string]interface{}{"id": point.ID})
+		}
+		if body.Limit > 0 && len(matched) == body.Limit {
+			break
+		}
+	}
+	return map[string]interface{}{"points": matched}, true
+}
+
+func (f *FakeQdrant) upsert(r *http.Request) (interface{}, bool) {
+	var body struct {
+		Points []qdrantPoint `json:"points"`
+	}
+	if json.NewDecoder(r.Body).Decode(&body) != nil || len(body.Points) == 0 {
+		return nil, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, point := range body.Points {
+		replaced := false
+		for i := range f.points {
+			if f.points[i].ID == point.ID {
+				f.points[i], replaced = point, true
+			}
+		}
+		if !replaced {
+			f.points = append(f.points, point)
+		}
+	}
+	return map[string]interface{}{"status": "completed"}, true
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// BreakStreams makes subsequent streams end after words words without
+// completing, as when Ollama dies mid-generation; 0 completes them
+func (f *FakeOllama) BreakStreams(words int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.breakAfter = words
+}
+
+// LastPrompt returns the prompt of the latest generation
+func (f *FakeOllama) LastPrompt() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastPrompt
+}
+
+func (f *FakeOllama) generate(w http.ResponseWriter, r *http.Request) {
+	var req ollama.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.lastPrompt = req.Prompt
+	breakAfter := f.breakAfter
+	f.mu.Unlock()
+
+	if !req.Stream {
+		json.NewEncoder(w).Encode(ollama.GenerateResponse{Model: req.Model, Response: f.Response, Done: true, EvalCount: len(strings.Fields(f.Response))})
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for n, word := range strings.Fields(f.Response) {
+		if breakAfter > 0 && n == breakAfter {
+			return
+		}
+		enc.Encode(ollama.GenerateResponse{Model: req.Model, Response: word + " "})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	enc.Encode(ollama.GenerateResponse{Model: req.Model, Done: true, DoneReason: "stop"})
+}
+
+// FakeVLLM serves the vLLM OpenAI-compatible /v1/completions and /health
+// endpoints. Token prompts (summaries) generate Response; text prompts
+// (warm-up and entity extraction) generate TextResponse, except for related
+// search polishing, which generates RelatedResponse. It also serves the
+// /v1/rerank API, scoring each document by the share of the query's words
+// it contains. ServeGRPC adds the VLLMGenerationService of a gRPC frontend,
+// generating the same way.
+type FakeVLLM struct {
+	*httptest.Server
+	pb.UnimplementedVLLMGenerationServiceServer
+	Response        string
+	TextResponse    string
+	RelatedResponse string
+
+	grpcServer *grpc.Server // nil until ServeGRPC
+
+	mu           sync.Mutex
+	failWith     int
+	rerankStatus int           // HTTP status to fail reranks with; 0 serves them
+	delay        time.Duration // per generated word
+	breakAfter   int           // words streamed before a stream breaks off; 0 completes them
+	override     string        // replaces Response when set
+	lastPrompt   []int32
+	lastMax      int
+	lastModel    string
+	sampling     VLLMSampling
+}
+
+// VLLMSampling are the sampling parameters of a completion request, nil
+// when unset
+type VLLMSampling struct {
+	Temperature      *float64 `json:"temperature"`
+	TopP             *float64 `json:"top_p"`
+	FrequencyPenalty *float64 `json:"frequency_penalty"`
+	PresencePenalty  *float64 `json:"presence_penalty"`
+	Stop             []string `json:"stop"`
+	Seed             *int64   `json:"seed"`
+}
+
+// fakeTokenLogprob is the log probability reported for every generated
+// word, a mean token probability of about 0.95
+const fakeTokenLogprob = -0.05
+
+// NewFakeVLLM starts a fake vLLM server
+func NewFakeVLLM() *FakeVLLM {
+	f := &FakeVLLM{
+		Response:        "Go is an open source language designed for simple, reliable and efficient software.",
+		RelatedResponse: "1. How does Kubernetes pod autoscaling work\n2. Kubernetes Helm charts tutorial",
+		TextResponse:    `{"name": "Go", "type": "product", "description": "An open source programming language.", "attributes": [{"name": "Designed by", "value": "Google"}, {"name": "First appeared", "value": "2009"}], "source": 1}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/v1/completions", f.complete)
+	mux.HandleFunc("/v1/rerank", f.rerank)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// LastRequest returns the prompt token IDs and max_tokens of the latest
+// token prompt completion
+func (f *FakeVLLM) LastRequest() ([]int32, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastPrompt, f.lastMax
+}
+
+// LastModel returns the model of the latest token prompt completion
+func (f *FakeVLLM) LastModel() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastModel
+}
+
+// LastSampling returns the sampling parameters of the latest token prompt
+// completion
+func (f *FakeVLLM) LastSampling() VLLMSampling {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sampling
+}
+
+// SetResponse makes subsequent completions generate text; "" restores Response
+func (f *FakeVLLM) SetResponse(text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.override = text
+}
+
+// FailWith makes subsequent completions fail with the given HTTP status; 0 restores them
+func (f *FakeVLLM) FailWith(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failWith = status
+}
+
+// SlowDown makes subsequent completions take delay per word; 0 restores them
+func (f *FakeVLLM) SlowDown(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = delay
+}
+
+// BreakStreams makes subsequent streamed completions end without [DONE]
+// after words words, as if the server died; 0 restores them
+func (f *FakeVLLM) BreakStreams(words int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.breakAfter = words
+}
+
+// FailReranks makes subsequent reranks fail with the given HTTP status; 0
+// restores them
+func (f *FakeVLLM) FailReranks(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rerankStatus = status
+}
+
+func (f *FakeVLLM) rerank(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	status := f.rerankStatus
+	f.mu.Unlock()
+	if status != 0 {
+		http.Error(w, "injected failure", status)
+		return
+	}
+	var req struct {
+		Query     string   `json:"query"`
+		Documents []string `json:"documents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	words := func(text string) map[string]bool {
+		set := make(map[string]bool)
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			set[word] = true
+		}
+		return set
+	}
+	query := words(req.Query)
+	type result struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	}
+	results := make([]result, len(req.Documents))
+	for i, document := range req.Documents {
+		matched, have := 0, words(document)
+		for word := range query {
+			if have[word] {
+				matched++
+			}
+		}
+		results[i] = result{Index: i, RelevanceScore: float64(matched) / float64(len(query))}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
+
+// ServeGRPC starts serving the VLLMGenerationService and the gRPC health
+// service, returning their address; Close stops them
+func (f *FakeVLLM) ServeGRPC() (string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	f.grpcServer = grpc.NewServer()
+	pb.RegisterVLLMGenerationServiceServer(f.grpcServer, f)
+	healthpb.RegisterHealthServer(f.grpcServer, health.NewServer())
+	go f.grpcServer.Serve(lis)
+	return lis.Addr().String(), nil
+}
+
+// Close stops the HTTP server and the gRPC one, if started
+func (f *FakeVLLM) Close() {
+	if f.grpcServer != nil {
+		f.grpcServer.Stop()
+	}
+	f.Server.Close()
+}
+
+// generation picks the response to a token prompt, or to a text prompt
+// when tokens is nil, recording the request of a token prompt
+func (f *FakeVLLM) generation(tokens []int32, text, model string, maxTokens int, sampling VLLMSampling) (response string, delay time.Duration, breakAfter, failWith int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failWith, delay, response, breakAfter = f.failWith, f.delay, f.override, f.breakAfter
+	switch {
+	case tokens != nil:
+		f.lastPrompt, f.lastMax, f.lastModel, f.sampling = tokens, maxTokens, model, sampling
+		if response == "" {
+			response = f.Response
+		}
+	case strings.Contains(text, "related searches"):
+		response = f.RelatedResponse
+	default:
+		response = f.TextResponse
+	}
+	return response, delay, breakAfter, failWith
+}
+
+func (f *FakeVLLM) Generate(ctx context.Context, req *pb.VLLMGenerateRequest) (*pb.VLLMGenerateResponse, error) {
+	response, delay, _, failWith := f.generation(req.TokenIds, req.Prompt, req.Model, int(req.MaxTokens), grpcSampling(req.Sampling))
+	if failWith != 0 {
+		return nil, status.Error(codes.Unavailable, "injected failure")
+	}
+	words := strings.Fields(response)
+	time.Sleep(delay * time.Duration(len(words)))
+	return &pb.VLLMGenerateResponse{
+		Text:             response,
+		TokenLogprobs:    fakeLogprobs(req.Logprobs, len(words)),
+		FinishReason:     "stop",
+		PromptTokens:     int32(len(req.TokenIds)),
+		CompletionTokens: int32(len(words)),
+	}, nil
+}
+
+func (f *FakeVLLM) GenerateStream(req *pb.VLLMGenerateRequest, stream pb.VLLMGenerationService_GenerateStreamServer) error {
+	response, delay, breakAfter, failWith := f.generation(req.TokenIds, req.Prompt, req.Model, int(req.MaxTokens), grpcSampling(req.Sampling))
+	if failWith != 0 {
+		return status.Error(codes.Unavailable, "injected failure")
+	}
+	words := strings.Fields(response)
+	for i, word := range words {
+		if breakAfter > 0 && i == breakAfter {
+			return nil
+		}
+		time.Sleep(delay)
+		if err := stream.Send(&pb.VLLMGenerateResponse{Text: word + " ", TokenLogprobs: fakeLogprobs(req.Logprobs, 1)}); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&pb.VLLMGenerateResponse{FinishReason: "stop", PromptTokens: int32(len(req.TokenIds)), CompletionTokens: int32(len(words))})
+}
+
+// grpcSampling is the gRPC form of sampling parameters as recorded
+func grpcSampling(params *pb.SamplingParams) VLLMSampling {
+	if params == nil {
+		return VLLMSampling{}
+	}
+	widen := func(v *float32) *float64 {
+		if v == nil {
+			return nil
+		}
+		f := float64(*v)
+		return &f
+	}
+	return VLLMSampling{
+		Temperature:      widen(params.Temperature),
+		TopP:             widen(params.TopP),
+		FrequencyPenalty: widen(params.FrequencyPenalty),
+		PresencePenalty:  widen(params.PresencePenalty),
+		Stop:             params.Stop,
+		Seed:             params.Seed,
+	}
+}
+
+// fakeLogprobs reports fakeTokenLogprob for each of n words when asked to
+func fakeLogprobs(asked int32, n int) []float32 {
+	if asked == 0 {
+		return nil
+	}
+	lps := make([]float32, n)
+	for i := range lps {
+		lps[i] = fakeTokenLogprob
+	}
+	return lps
+}
+
+func (f *FakeVLLM) complete(w http.ResponseWriter, r *http.Request) {
+
+	var req struct {
+		Model     string          `json:"model"`
+		Prompt    json.RawMessage `json:"prompt"`
+		MaxTokens int             `json:"max_tokens"`
+		Stream    bool            `json:"stream"`
+		Logprobs  int             `json:"logprobs"`
+		VLLMSampling
+	}
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var prompt []int32
+	if json.Unmarshal(req.Prompt, &prompt) != nil {
+		prompt = nil
+	} else if prompt == nil {
+		prompt = []int32{}
+	}
+	response, delay, breakAfter, failWith := f.generation(prompt, string(req.Prompt), req.Model, req.MaxTokens, req.VLLMSampling)
+	if failWith != 0 {
+		http.Error(w, "injected failure", failWith)
+		return
+	}
+
+	// Every word is one token, each reported with fakeTokenLogprob
+	logprobs := func(n int) string {
+		if req.Logprobs == 0 {
+			return ""
+		}
+		lps := strings.TrimSuffix(strings.Repeat(fmt.Sprintf("%g,", fakeTokenLogprob), n), ",")
+		return `,"logprobs":{"token_logprobs":[` + lps + `]}`
+	}
+
+	words := strings.Fields(response)
+	if !req.Stream {
+		time.Sleep(delay * time.Duration(len(words)))
+		fmt.Fprintf(w, `{"id":"cmpl-fake","choices":[{"text":%q,"finish_reason":"stop"%s}]}`, response, logprobs(len(words)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	for i, word := range words {
+		if breakAfter > 0 && i == breakAfter {
+			return
+		}
+		time.Sleep(delay)
+		fmt.Fprintf(w, "data: {\"id\":\"cmpl-fake\",\"choices\":[{\"text\":%q,\"finish_reason\":null%s}]}\n\n", word+" ", logprobs(1))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// FakeTriton serves a summarization model on Triton's KServe v2 protocol,
+// over HTTP and, once ServeGRPC is called, gRPC. Its output is Output
+// framed as BART frames generated IDs: after the decoder start (EOS) and BOS
+// tokens, followed by EOS and padding.
+type FakeTriton struct {
+	*httptest.Server
+	Model  string
+	Output []int32
+
+	grpcServer *grpc.Server // nil until ServeGRPC
+
+	mu        sync.Mutex
+	lastInput []int64
+	lastMask  bool
+}
+
+// Special token IDs the fake frames its output with; unlike BART's (0, 1
+// and 2) they are clear of the IDs FakeTokenizer assigns to words
+const (
+	fakeBOSTokenID = 1_000_000
+	fakePadTokenID = 1_000_001
+	fakeEOSTokenID = 1_000_002
+)
+
+// NewFakeTriton starts a fake Triton server serving model
+func NewFakeTriton(model string, output []int32) *FakeTriton {
+	f := &FakeTriton{Model: model, Output: output}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/models/"+model+"/ready", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/v2/models/"+model+"/infer", f.infer)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// LastInput returns the prompt token IDs of the latest inference and
+// whether an attention mask came with them
+func (f *FakeTriton) LastInput() ([]int64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastInput, f.lastMask
+}
+
+// ServeGRPC starts serving Triton's GRPCInferenceService, returning its
+// address; Close stops it
+func (f *FakeTriton) ServeGRPC() (string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	f.grpcServer = grpc.NewServer()
+	f.grpcServer.RegisterService(&fakeTritonServiceDesc, f)
+	go f.grpcServer.Serve(lis)
+	return lis.Addr().String(), nil
+}
+
+// Close stops the HTTP server and the gRPC one, if started
+func (f *FakeTriton) Close() {
+	if f.grpcServer != nil {
+		f.grpcServer.Stop()
+	}
+	f.Server.Close()
+}
+
+// output is the generated sequence as the model returns it
+func (f *FakeTriton) output() []int64 {
+	out := []int64{fakeEOSTokenID, fakeBOSTokenID}
+	for _, id := range f.Output {
+		out = append(out, int64(id))
+	}
+	return append(out, fakeEOSTokenID, fakePadTokenID, fakePadTokenID)
+}
+
+func (f *FakeTriton) record(input []int64, mask bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastInput, f.lastMask = input, mask
+}
+
+func (f *FakeTriton) infer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Inputs []struct {
+			Name string  `json:"name"`
+			Data []int64 `json:"data"`
+		} `json:"inputs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+	var input []int64
+	mask := false
+	for _, tensor := range req.Inputs {
+		switch tensor.Name {
+		case "input_ids":
+			input = tensor.Data
+		case "attention_mask":
+			mask = true
+		}
+	}
+	f.record(input, mask)
+	out := f.output()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"model_name": f.Model,
+		"outputs":    []map[string]interface{}{{"name": "output_ids", "datatype": "INT64", "shape": []int{1, len(out)}, "data": out}},
+	})
+}
+
+func (f *FakeTriton) modelInfer(ctx context.Context, req *pb.TritonInferRequest) (*pb.TritonInferResponse, error) {
+	var input []int64
+	mask := false
+	for _, tensor := range req.Inputs {
+		switch tensor.Name {
+		case "input_ids":
+			input = tensor.Contents.GetInt64Contents()
+		case "attention_mask":
+			mask = true
+		}
+	}
+	f.record(input, mask)
+	out := f.output()
+	raw := make([]byte, 8*len(out))
+	for n, id := range out {
+		binary.LittleEndian.PutUint64(raw[n*8:], uint64(id))
+	}
+	return &pb.TritonInferResponse{
+		ModelName:         f.Model,
+		Id:                req.Id,
+		Outputs:           []*pb.TritonInferTensor{{Name: "output_ids", Datatype: "INT64", Shape: []int64{1, int64(len(out))}}},
+		RawOutputContents: [][]byte{raw},
+	}, nil
+}
+
+// fakeTritonServiceDesc serves the ModelReady and ModelInfer methods of
+// Triton's GRPCInferenceService with the wire-compatible messages
+var fakeTritonServiceDesc = grpc.ServiceDesc{
+	ServiceName: "inference.GRPCInferenceService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ModelReady",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(pb.TritonModelReadyRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return &pb.TritonModelReadyResponse{Ready: req.Name == srv.(*FakeTriton).Model}, nil
+			},
+		},
+		{
+			MethodName: "ModelInfer",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(pb.TritonInferRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return srv.(*FakeTriton).modelInfer(ctx, req)
+			},
+		},
+	},
+}
+
+// FakeTokenizer is an in-process word-level tokenizer standing in for the
+// Python tokenizer service. Token IDs are assigned on first sight; 0 is reserved.
+type FakeTokenizer struct {
+	pb.UnimplementedTokenizerServiceServer
+
+	mu    sync.Mutex
+	ids   map[string]int32
+	words []string
+	delay time.Duration // before each Tokenize and BuildPrompt
+
+	contextWindow int32  // prompts are truncated to it
+	version       string // reported as the tokenizer_version of every model
+	promptsBuilt  int
+}
+
+// defaultFakeContextWindow is the fake model's context, in words
+const defaultFakeContextWindow = 4096
+
+// FakeTokenizerVersion is the tokenizer version the fake reports by default
+const FakeTokenizerVersion = "fake-vocab-1"
+
+// NewFakeTokenizer creates an empty fake tokenizer
+func NewFakeTokenizer() *FakeTokenizer {
+	return &FakeTokenizer{ids: make(map[string]int32), words: []string{""}, contextWindow: defaultFakeContextWindow, version: FakeTokenizerVersion}
+}
+
+// SetVersion sets the tokenizer version reported, as after an upgrade of
+// the tokenizer's models; "" restores FakeTokenizerVersion
+func (t *FakeTokenizer) SetVersion(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if version == "" {
+		version = FakeTokenizerVersion
+	}
+	t.version = version
+}
+
+// SetContextWindow sets the context window prompts are truncated to; 0
+// restores the default
+func (t *FakeTokenizer) SetContextWindow(tokens int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tokens <= 0 {
+		tokens = defaultFakeContextWindow
+	}
+	t.contextWindow = tokens
+}
+
+// SlowDown delays subsequent Tokenize and BuildPrompt calls by delay, or
+// until the caller gives up; 0 restores them
+func (t *FakeTokenizer) SlowDown(delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delay = delay
+}
+
+func (t *FakeTokenizer) encode(text string) []int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fields := strings.Fields(text)
+	ids := make([]int32, len(fields))
+	for i, word := range fields {
+		id, ok := t.ids[word]
+		if !ok {
+			id = int32(len(t.words))
+			t.ids[word] = id
+			t.words = append(t.words, word)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func (t *FakeTokenizer) decode(ids []int32) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	words := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 && int(id) < len(t.words) {
+			words = append(words, t.words[id])
+		}
+	}
+	return words
+}
+
+// wait holds a call back by the delay SlowDown set and returns the
+// context window and tokenizer version
+func (t *FakeTokenizer) wait(ctx context.Context) (int32, string, error) {
+	t.mu.Lock()
+	delay, window, version := t.delay, t.contextWindow, t.version
+	t.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return 0, "", status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return window, version, nil
+}
+
+func (t *FakeTokenizer) Tokenize(ctx context.Context, req *pb.TokenizeRequest) (*pb.TokenizeResponse, error) {
+	start := time.Now()
+	window, version, err := t.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := t.encode(req.Text)
+	original := int32(len(ids))
+	limit := window
+	if req.MaxTokens > 0 && req.MaxTokens < limit {
+		limit = req.MaxTokens
+	}
+	truncated := false
+	if original > limit {
+		ids = ids[:limit]
+		truncated = true
+	}
+	return &pb.TokenizeResponse{
+		TokenIds:           ids,
+		TokenCount:         int32(len(ids)),
+		OriginalTokenCount: original,
+		ContextWindow:      window,
+		TokenizerVersion:   version,
+		WasTruncated:       truncated,
+		ModelUsed:          req.ModelName,
+		ProcessingTimeMs:   float32(time.Since(start).Seconds() * 1000),
+		CacheStatus:        "disabled",
+		Success:            true,
+	}, nil
+}
+
+// UserOnlyChatTemplate is a chat template without a system role, like
+// Mistral-7B-Instruct-v0.1's: it raises an exception for system messages
+const UserOnlyChatTemplate = "{{ bos_token }}{% for message in messages %}{% if message['role'] == 'user' %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% else %}{{ raise_exception('Only user and assistant roles are supported!') }}{% endif %}{% endfor %}"
+
+// fakeChatTemplate returns how the chat template with source renders, in
+// place of the Jinja the tokenizer runs, for the built-in chatml and
+// UserOnlyChatTemplate; ok is false for other templates
+func fakeChatTemplate(source, bosToken string) (render func([]prompt.Message) (string, error), ok bool) {
+	if chatml, _ := prompt.LookupChatTemplate("chatml", "", ""); source == chatml.Source {
+		return func(messages []prompt.Message) (string, error) {
+			var b strings.Builder
+			for _, m := range messages {
+				b.WriteString("<|im_start|>" + m.Role + "\n" + m.Content + "<|im_end|>\n")
+			}
+			b.WriteString("<|im_start|>assistant\n")
+			return b.String(), nil
+		}, true
+	}
+	if source == UserOnlyChatTemplate {
+		return func(messages []prompt.Message) (string, error) {
+			var b strings.Builder
+			b.WriteString(bosToken)
+			for _, m := range messages {
+				if m.Role != "user" {
+					return "", fmt.Errorf("chat template: Only user and assistant roles are supported!")
+				}
+				b.WriteString("[INST] " + m.Content + " [/INST]")
+			}
+			return b.String(), nil
+		}, true
+	}
+	return nil, false
+}
+
+// BuildPrompt renders the template as the orchestrator would and tokenizes
+// it like Tokenize. Its words have no special tokens, so every model is a
+// seq2seq model that gets none, or a chat model when the request has a
+// chat template the fake knows. A prompt over the context window loses words from the end
+// of the template's truncatable variable, keeping what follows it.
+func (t *FakeTokenizer) BuildPrompt(ctx context.Context, req *pb.BuildPromptRequest) (*pb.BuildPromptResponse, error) {
+	start := time.Now()
+	tmpl, ok := prompt.Lookup(req.TemplateId)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown prompt template %q", req.TemplateId)
+	}
+	family, renderText := "seq2seq", func(vars map[string]string) (string, error) { return tmpl.Text(vars), nil }
+	if req.ChatTemplate != "" {
+		chat, ok := fakeChatTemplate(req.ChatTemplate, req.BosToken)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid chat template: the fake tokenizer renders only chatml and UserOnlyChatTemplate")
+		}
+		// A system message the template rejects is folded into the user
+		// message, as the Python tokenizer does
+		family, renderText = "chat", func(vars map[string]string) (string, error) {
+			messages := tmpl.Messages(vars)
+			text, err := chat(messages)
+			if err != nil && len(messages) > 1 {
+				return chat(prompt.FoldSystem(messages))
+			}
+			return text, err
+		}
+	}
+	window, version, err := t.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit := window
+	if req.MaxTokens > 0 && req.MaxTokens < limit {
+		limit = req.MaxTokens
+	}
+
+	vars := make(map[string]string, len(req.Variables))
+	for name, value := range req.Variables {
+		vars[name] = value
+	}
+	text, err := renderText(vars)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "rendering prompt: %v", err)
+	}
+	ids := t.encode(text)
+	original := int32(len(ids))
+	if over := int(original - limit); over > 0 {
+		words := strings.Fields(vars[tmpl.Truncate])
+		vars[tmpl.Truncate] = strings.Join(words[:max(len(words)-over, 0)], " ")
+		if text, err = renderText(vars); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "rendering prompt: %v", err)
+		}
+		ids = t.encode(text)
+		if int32(len(ids)) > limit {
+			ids = ids[:limit]
+		}
+	}
+
+	t.mu.Lock()
+	t.promptsBuilt++
+	t.mu.Unlock()
+	return &pb.BuildPromptResponse{
+		Tokens: &pb.TokenizeResponse{
+			TokenIds:           ids,
+			TokenCount:         int32(len(ids)),
+			OriginalTokenCount: original,
+			ContextWindow:      window,
+			TokenizerVersion:   version,
+			WasTruncated:       int32(len(ids)) < original,
+			ModelUsed:          req.ModelName,
+			ProcessingTimeMs:   float32(time.Since(start).Seconds() * 1000),
+			CacheStatus:        "disabled",
+			Success:            true,
+		},
+		Prompt:      text,
+		ModelFamily: family,
+	}, nil
+}
+
+// PromptsBuilt returns how many prompts BuildPrompt has assembled
+func (t *FakeTokenizer) PromptsBuilt() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.promptsBuilt
+}
+
+func (t *FakeTokenizer) Detokenize(ctx context.Context, req *pb.DetokenizeRequest) (*pb.DetokenizeResponse, error) {
+	return &pb.DetokenizeResponse{
+		Text:       strings.Join(t.decode(req.TokenIds), " "),
+		TokenCount: int32(len(req.TokenIds)),
+		ModelUsed:  req.ModelName,
+		Success:    true,
+	}, nil
+}
+
+func (t *FakeTokenizer) DecodeStream(stream pb.TokenizerService_DecodeStreamServer) error {
+	count := int32(0)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var text strings.Builder
+		for _, word := range t.decode(req.TokenIds) {
+			if count > 0 || text.Len() > 0 {
+				text.WriteString(" ")
+			}
+			text.WriteString(word)
+		}
+		count += int32(len(req.TokenIds))
+
+		if err := stream.Send(&pb.DecodeStreamResponse{Text: text.String(), TokenCount: count, Success: true}); err != nil {
+			return err
+		}
+		if req.IsFinal {
+			return nil
+		}
+	}
+}
+
+func (t *FakeTokenizer) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	return &pb.HealthCheckResponse{Status: "healthy", Service: "tokenizer-fake", Timestamp: time.Now().Unix()}, nil
+}
+
+// FakeOIDC is an OpenID Connect provider serving discovery and its signing
+// key, with Token minting RS256 tokens for the e2e client. Its authorization
+// endpoint logs in whoever LoginAs names without asking.
+type FakeOIDC struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	user  *oidcUser
+	codes map[string]*oidcGrant
+}
+
+type oidcUser struct {
+	subject string
+	claims  map[string]interface{}
+}
+
+// oidcGrant is an issued authorization code awaiting redemption
+type oidcGrant struct {
+	user        *oidcUser
+	redirectURI string
+	nonce       string
+	challenge   string
+}
+
+// NewFakeOIDC starts a provider with a fresh signing key
+func NewFakeOIDC() *FakeOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	f := &FakeOIDC{ClientID: "e2e-client", ClientSecret: "e2e-secret", key: key, codes: make(map[string]*oidcGrant)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// LoginAs makes the authorization endpoint log in subject with the given
+// claims, as if they had signed in at the provider; with no subject it
+// denies access
+func (f *FakeOIDC) LoginAs(subject string, claims map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.user = nil
+	if subject != "" {
+		f.user = &oidcUser{subject: subject, claims: claims}
+	}
+}
+
+func (f *FakeOIDC) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/authorize",
+			"token_endpoint":         f.URL + "/token",
+			"jwks_uri":               f.URL + "/jwks",
+		})
+	case "/jwks":
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "e2e", "kty": "RSA", "use": "sig", "alg": "RS256",
+			"n": encode(f.key.N.Bytes()), "e": encode(big.NewInt(int64(f.key.E)).Bytes()),
+		}}})
+	case "/authorize":
+		f.authorize(w, r)
+	case "/token":
+		f.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *FakeOIDC) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != f.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request"})
+		return
+	}
+	f.mu.Lock()
+	user := f.user
+	code := fmt.Sprintf("code-%d", len(f.codes)+1)
+	if user != nil {
+		f.codes[code] = &oidcGrant{user: user, redirectURI: q.Get("redirect_uri"), nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	}
+	f.mu.Unlock()
+
+	params := url.Values{"state": {q.Get("state")}}
+	if user == nil {
+		params.Set("error", "access_denied")
+	} else {
+		params.Set("code", code)
+	}
+	http.Redirect(w, r, q.Get("redirect_uri")+"?"+params.Encode(), http.StatusFound)
+}
+
+func (f *FakeOIDC) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	f.mu.Lock()
+	grant := f.codes[r.PostForm.Get("code")]
+	delete(f.codes, r.PostForm.Get("code"))
+	f.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if grant == nil || r.PostForm.Get("client_id") != f.ClientID || r.PostForm.Get("client_secret") != f.ClientSecret ||
+		r.PostForm.Get("redirect_uri") != grant.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	claims := map[string]interface{}{"nonce": grant.nonce}
+	for k, v := range grant.user.claims {
+		claims[k] = v
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "e2e-access-token",
+		"token_type":   "Bearer",
+		"id_token":     f.Token(grant.user.subject, claims),
+	})
+}
+
+// Token signs a token for subject with the given claims added to the
+// issuer, audience and an expiry an hour ahead
+func (f *FakeOIDC) Token(subject string, claims map[string]interface{}) string {
+	payload := map[string]interface{}{"iss": f.URL, "aud": f.ClientID, "sub": subject, "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "e2e", "typ": "JWT"})
+	body, _ := json.Marshal(payload)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,228 @@
+package testharness
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"ai-search-service/internal/faults"
+	"ai-search-service/internal/gateway"
+)
+
+// UpdateGolden rewrites the golden SSE traces from the current gateway
+// instead of comparing against them (e2e -update)
+var UpdateGolden bool
+
+// goldenDir holds the recorded traces, next to this file so the suite finds
+// them whatever directory it runs from
+func goldenDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "testdata", "sse")
+}
+
+// Trace is the protocol shape of an SSE response: its status, content type
+// and, for each event, the name and the top-level fields of its data.
+// Payload values, IDs and the number of tokens vary between runs and are
+// left out, so a trace only changes when the contract frontends rely on
+// does.
+type Trace struct {
+	Status      int
+	ContentType string
+	Events      []Event
+}
+
+// String renders the trace one line per event, adding the data's "type"
+// when it differs from the event name. Consecutive token events collapse into one "token+" line.
+func (t Trace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "status %d %s\n", t.Status, t.ContentType)
+	for i, e := range t.Events {
+		if e.Name == "" {
+			e.Name = "message"
+		}
+		if e.Name == "token" {
+			if i > 0 && t.Events[i-1].Name == "token" {
+				continue
+			}
+			e.Name = "token+"
+		}
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal([]byte(e.Data), &typed) == nil && typed.Type != "" && typed.Type != e.Name && e.Name != "token+" {
+			e.Name += "/" + typed.Type
+		}
+		fmt.Fprintf(&b, "%s %s\n", e.Name, dataShape(e.Data))
+	}
+	return b.String()
+}
+
+// dataShape is the sorted keys of a JSON object, or the JSON type of any
+// other payload
+func dataShape(data string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return "text"
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return "{" + strings.Join(keys, ",") + "}"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case nil:
+		return "null"
+	default:
+		return "scalar"
+	}
+}
+
+// traceSSE runs a search over SSE like searchSSE, keeping the response
+// status and content type. stop, when set, is called with each event and
+// ends the read early (a client disconnect) once it returns true.
+func (h *Harness) traceSSE(ctx context.Context, query string, streaming bool, header http.Header, stop func(Event) bool) (Trace, error) {
+	var req *http.Request
+	var err error
+	if streaming {
+		params := url.Values{"query": {query}, "num_results": {"3"}}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, h.Gateway.URL+"/api/v1/search?"+params.Encode(), nil)
+	} else {
+		body, _ := json.Marshal(gateway.SearchRequest{Query: query, NumResults: 3})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, h.Gateway.URL+"/api/v1/search", bytes.NewReader(body))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return Trace{}, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Trace{}, err
+	}
+	defer resp.Body.Close()
+
+	trace := Trace{Status: resp.StatusCode}
+	trace.ContentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if trace.ContentType != "text/event-stream" {
+		// Rejected before the stream started: the body is a JSON error
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		trace.Events = append(trace.Events, Event{Name: "body", Data: body.String()})
+		return trace, nil
+	}
+	err = scanEvents(bufio.NewScanner(resp.Body), func(e Event) bool {
+		trace.Events = append(trace.Events, e)
+		return stop == nil || !stop(e)
+	})
+	// Returning before the end of the body closes the connection: the
+	// server sees the client disconnect
+	return trace, err
+}
+
+// checkGolden compares a rendered trace with testdata/sse/<name>.golden, or
+// rewrites the file under -update
+func checkGolden(name, got string) error {
+	path := filepath.Join(goldenDir(), name+".golden")
+	if UpdateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(got), 0o644)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("no golden trace (run e2e -update to record it): %w", err)
+	}
+	if got != string(want) {
+		return fmt.Errorf("SSE trace %s changed; if the protocol change is intended, run e2e -update and commit the diff\n--- want\n%s--- got\n%s", name, want, got)
+	}
+	return nil
+}
+
+// goldenTraces records the SSE protocol of the canonical outcomes (success
+// with and without token streaming, a safety block, a failed LLM call, an
+// inference backend failure with its fallback summary and a client disconnect followed by a resume) and compares each with its
+// golden file, so a change frontends would notice fails the suite
+func goldenTraces(ctx context.Context, h *Harness) error {
+	cases := []struct {
+		name string
+		run  func(ctx context.Context) (string, error)
+	}{
+		{"streaming_success", func(ctx context.Context) (string, error) {
+			trace, err := h.traceSSE(ctx, "golang golden streaming", true, nil, nil)
+			return trace.String(), err
+		}},
+		{"non_streaming_success", func(ctx context.Context) (string, error) {
+			trace, err := h.traceSSE(ctx, "golang golden buffered", false, nil, nil)
+			return trace.String(), err
+		}},
+		{"safety_block", func(ctx context.Context) (string, error) {
+			trace, err := h.traceSSE(ctx, "drop table users", true, nil, nil)
+			return trace.String(), err
+		}},
+		{"llm_failure", func(ctx context.Context) (string, error) {
+			header := http.Header{faults.HeaderName: {"method=StreamRequest;code=unavailable"}}
+			trace, err := h.traceSSE(ctx, "golang golden failure", true, header, nil)
+			return trace.String(), err
+		}},
+		{"inference_fallback", func(ctx context.Context) (string, error) {
+			h.VLLM.FailWith(http.StatusServiceUnavailable)
+			defer h.VLLM.FailWith(0)
+			trace, err := h.traceSSE(ctx, "golang golden fallback", true, nil, nil)
+			return trace.String(), err
+		}},
+		{"client_disconnect", func(ctx context.Context) (string, error) {
+			const query = "golang golden disconnect"
+			var last Event
+			first, err := h.traceSSE(ctx, query, true, nil, func(e Event) bool {
+				last = e
+				return e.Name == "search_results"
+			})
+			if err != nil {
+				return "", err
+			}
+			if last.ID == "" {
+				return "", fmt.Errorf("stream ended before search_results")
+			}
+			resumed, err := h.traceSSE(ctx, query, true, http.Header{"Last-Event-Id": {last.ID}}, nil)
+			return first.String() + "-- reconnect with Last-Event-ID\n" + resumed.String(), err
+		}},
+	}
+
+	var failed []string
+	for _, c := range cases {
+		got, err := c.run(ctx)
+		if err == nil {
+			err = checkGolden(c.name, got)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", c.name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
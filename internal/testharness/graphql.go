@@ -0,0 +1,191 @@
+package testharness
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// graphqlResponse is a GraphQL response with its errors decoded
+type graphqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Path       []interface{}          `json:"path"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// graphqlQuery posts a GraphQL operation and decodes the response
+func (h *Harness) graphqlQuery(ctx context.Context, query string, variables map[string]interface{}) (int, *graphqlResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp graphqlResponse
+	status, err := h.send(ctx, http.MethodPost, "/api/v1/graphql", string(body), nil, &resp)
+	return status, &resp, err
+}
+
+// graphqlEndpoint runs dashboard-style queries against /api/v1/graphql: a
+// search selecting only result URLs next to usage and models in one
+// request, aliases, field errors, validation failures, the per-request
+// search cap, a subscription streamed as graphql-sse events and the SDL
+// schema endpoint
+func graphqlEndpoint(ctx context.Context, h *Harness) error {
+	status, resp, err := h.graphqlQuery(ctx, `query Dashboard($q: String!) {
+		brief: search(query: $q, numResults: 3) { summary taskId searchResults { url } confidence { level } }
+		usage { tenant }
+		models { backend model warmed }
+	}`, map[string]interface{}{"q": "golang graphql"})
+	if err != nil || status != http.StatusOK || len(resp.Errors) > 0 {
+		return fmt.Errorf("expected the query to succeed, got %d %+v (%v)", status, resp, err)
+	}
+	if _, ok := resp.Data["search"]; ok {
+		return fmt.Errorf("expected the search under its alias, got %v", resp.Data)
+	}
+	var brief struct {
+		Summary       string                   `json:"summary"`
+		TaskID        string                   `json:"taskId"`
+		SearchResults []map[string]interface{} `json:"searchResults"`
+		Confidence    map[string]interface{}   `json:"confidence"`
+	}
+	if err := json.Unmarshal(resp.Data["brief"], &brief); err != nil {
+		return err
+	}
+	if brief.Summary != h.VLLM.Response || brief.TaskID == "" || len(brief.SearchResults) != 3 || len(brief.Confidence) != 1 {
+		return fmt.Errorf("unexpected search %s", resp.Data["brief"])
+	}
+	for _, result := range brief.SearchResults {
+		if _, ok := result["url"]; !ok || len(result) != 1 {
+			return fmt.Errorf("expected results with only their url, got %v", result)
+		}
+	}
+	var models []struct {
+		Backend string `json:"backend"`
+		Model   string `json:"model"`
+	}
+	if err := json.Unmarshal(resp.Data["models"], &models); err != nil {
+		return err
+	}
+	if len(models) != 2 || models[0].Backend != "vllm" || models[1].Backend != "ollama" || models[0].Model == "" {
+		return fmt.Errorf("unexpected models %s", resp.Data["models"])
+	}
+	if !strings.Contains(string(resp.Data["usage"]), `"tenant"`) {
+		return fmt.Errorf("unexpected usage %s", resp.Data["usage"])
+	}
+
+	// Field errors come back next to the data, with the REST status
+	status, resp, err = h.graphqlQuery(ctx, `{ history { query } usage { tenant } }`, nil)
+	if err != nil || status != http.StatusOK || len(resp.Errors) != 1 || string(resp.Data["history"]) != "null" {
+		return fmt.Errorf("expected history to fail on its own, got %d %+v (%v)", status, resp, err)
+	}
+	if e := resp.Errors[0]; e.Extensions["status"] != float64(http.StatusUnauthorized) || fmt.Sprint(e.Path) != "[history]" {
+		return fmt.Errorf("expected a 401 error at history, got %+v", e)
+	}
+
+	// A request may search gateway.graphql.max_searches times
+	status, resp, err = h.graphqlQuery(ctx, `{
+		a: search(query: "") { status } b: search(query: "") { status }
+		c: search(query: "") { status } d: search(query: "") { status }
+	}`, nil)
+	if err != nil || status != http.StatusOK || len(resp.Errors) != 4 {
+		return fmt.Errorf("expected an error per search, got %d %+v (%v)", status, resp, err)
+	}
+	if last := resp.Errors[3]; !strings.Contains(last.Message, "at most 3") {
+		return fmt.Errorf("expected the fourth search to be refused, got %+v", last)
+	}
+
+	// Operations that do not validate are refused before anything runs
+	invalid := []string{
+		`{ search(query: "golang") { summary body } }`,
+		`{ search { summary } }`,
+		`{ models }`,
+		`{ search(query: "golang") { summary } `,
+		`subscription { search(query: "golang") { type } }`,
+	}
+	for _, query := range invalid {
+		status, resp, err := h.graphqlQuery(ctx, query, nil)
+		if err != nil || status != http.StatusBadRequest || len(resp.Errors) == 0 || resp.Data != nil {
+			return fmt.Errorf("expected %q to be refused, got %d %+v (%v)", query, status, resp, err)
+		}
+	}
+	var rejected struct {
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	if status, err := h.send(ctx, http.MethodPost, "/api/v1/graphql", `{"query":""}`, nil, &rejected); err != nil || status != http.StatusBadRequest || len(rejected.Errors) != 1 || rejected.Errors[0].Field != "query" {
+		return fmt.Errorf("expected an empty query to be a field error, got %d %+v (%v)", status, rejected, err)
+	}
+
+	// A subscription streams the search as next events
+	params := url.Values{"query": {`subscription { search(query: "golang graphql stream", numResults: 3) { type token summary searchResults { title } } }`}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.Gateway.URL+"/api/v1/graphql?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	httpResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	events, err := ReadEvents(bufio.NewScanner(httpResp.Body))
+	if err != nil {
+		return err
+	}
+	if len(events) < 2 || events[len(events)-1].Name != "complete" {
+		return fmt.Errorf("expected next events and complete, got %+v", events)
+	}
+	var types []string
+	var tokens strings.Builder
+	var summary string
+	for _, e := range events[:len(events)-1] {
+		var next struct {
+			Data struct {
+				Search struct {
+					Type    string `json:"type"`
+					Token   string `json:"token"`
+					Summary string `json:"summary"`
+				} `json:"search"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(e.Data), &next); err != nil || e.Name != "next" {
+			return fmt.Errorf("unexpected event %+v (%v)", e, err)
+		}
+		event := next.Data.Search
+		if len(types) == 0 || types[len(types)-1] != event.Type {
+			types = append(types, event.Type)
+		}
+		tokens.WriteString(event.Token)
+		summary = event.Summary
+	}
+	if got := strings.Join(types, " "); got != "STARTED STATUS RESULTS STATUS TOKEN SUMMARY" {
+		return fmt.Errorf("unexpected event types %s", got)
+	}
+	if summary != h.VLLM.Response || strings.TrimSpace(tokens.String()) != h.VLLM.Response {
+		return fmt.Errorf("expected summary %q, got %q streamed as %q", h.VLLM.Response, summary, tokens.String())
+	}
+
+	code, _, sdl, err := h.get(ctx, "/api/v1/graphql/schema.graphql")
+	if err != nil || code != http.StatusOK {
+		return fmt.Errorf("GET /api/v1/graphql/schema.graphql returned %d (%v)", code, err)
+	}
+	var missing []string
+	for _, want := range []string{"type Query {", "type Subscription {", "enum SearchEventType {", "scalar DateTime", "history(limit: Int = 20): [HistoryEntry!]"} {
+		if !strings.Contains(sdl, want) {
+			missing = append(missing, want)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("the schema lacks %s:\n%s", strings.Join(missing, ", "), sdl)
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+// Package testharness runs the whole pipeline in one process for end-to-end
+// tests: every gRPC service is served over an in-memory bufconn listener, the
+// gateway over httptest, and Google, Elasticsearch, Qdrant, Ollama, vLLM,
+// the Python tokenizer and notification webhooks are replaced by fakes. No
+// Docker or network access is needed.
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/faults"
+	"ai-search-service/internal/flags"
+	"ai-search-service/internal/gateway"
+	"ai-search-service/internal/services/inference"
+	"ai-search-service/internal/services/llm"
+	"ai-search-service/internal/services/safety"
+	"ai-search-service/internal/services/search"
+	pb "ai-search-service/proto"
+)
+
+const bufSize = 1024 * 1024
+
+// Harness is a running in-process deployment of all services
+type Harness struct {
+	Config     *config.Config
+	Gateway    *httptest.Server // gateway HTTP API
+	Routes     gin.RoutesInfo   // registered on the gateway's router
+	Google     *FakeGoogle
+	Index      *FakeElasticsearch
+	Vectors    *FakeQdrant
+	Ollama     *FakeOllama
+	VLLM       *FakeVLLM
+	Tokenizer  *FakeTokenizer
+	Webhook    *FakeWebhook // saved-query webhooks
+	Slack      *FakeWebhook // Slack incoming webhook for notification channels
+	Events     *FakeWebhook // event bus the outbox relay publishes to
+	PDF        *FakePDFRenderer
+	OIDC       *FakeOIDC
+	Dictionary *FakeDictionary
+	AnswerAPIs *FakeAnswerAPIs // weather, stock and sports providers
+	Images     *FakeImages     // result thumbnails
+	Inference  *inference.InferenceService
+	Safety     *safety.SafetyService
+
+	llmService *llm.LLMService
+	exportDir  string // files of the filesystem object store
+	servers    []*grpc.Server
+	listeners  map[string]*bufconn.Listener
+}
+
+// Start brings up fakes, services and the gateway. Call Close when done.
+func Start() (*Harness, error) {
+	gin.SetMode(gin.TestMode)
+
+	h := &Harness{
+		Google:     NewFakeGoogle(),
+		Index:      NewFakeElasticsearch(),
+		Vectors:    NewFakeQdrant(),
+		Ollama:     NewFakeOllama(),
+		VLLM:       NewFakeVLLM(),
+		Tokenizer:  NewFakeTokenizer(),
+		Webhook:    NewFakeWebhook(),
+		Slack:      NewFakeWebhook(),
+		Events:     NewFakeWebhook(),
+		PDF:        NewFakePDFRenderer(),
+		OIDC:       NewFakeOIDC(),
+		Dictionary: NewFakeDictionary(),
+		AnswerAPIs: NewFakeAnswerAPIs(),
+		Images:     NewFakeImages(),
+		listeners:  make(map[string]*bufconn.Listener),
+	}
+
+	cfg, err := h.newConfig()
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	h.Config = cfg
+
+	if err := h.startServices(); err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	gw, err := gateway.NewGateway(cfg, h.DialOption())
+	if err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to create gateway: %w", err)
+	}
+	router := gin.New()
+	router.GET("/health", gw.HealthCheck)
+	gw.RegisterProbeRoutes(router)
+	router.GET("/metrics", gw.Metrics)
+	gw.RegisterAPIRoutes(router.Group("/api/v1"))
+	gw.RegisterAdminRoutes(router.Group("/admin"))
+	gw.RegisterBrowserRoutes(router)
+	gw.RegisterFragmentRoutes(router)
+	gw.RegisterLoginRoutes(router)
+	gw.RegisterShareRoutes(router)
+	gw.RegisterImageRoutes(router)
+	gw.RegisterExportRoutes(router)
+	gw.RegisterDocsRoutes(router)
+	router.SetHTMLTemplate(template.Must(template.New("index.html").Parse(`{{.title}}{{with .user}} signed in as {{.Email}}{{end}}`)))
+	router.GET("/", gw.RequireLogin, gw.Index)
+	h.Routes = router.Routes()
+	server, err := gateway.NewHTTPServer(cfg, "", router)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	h.Gateway = httptest.NewUnstartedServer(server.Handler)
+	h.Gateway.Config = server.Server
+	h.Gateway.Start()
+
+	return h, nil
+}
+
+// DialOption routes service addresses from the harness config to their
+// in-memory listeners
+func (h *Harness) DialOption() grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		lis, ok := h.listeners[addr]
+		if !ok {
+			return nil, fmt.Errorf("testharness: no service listening on %s", addr)
+		}
+		return lis.DialContext(ctx)
+	})
+}
+
+// Close stops the gateway, services and fakes
+func (h *Harness) Close() {
+	if h.Gateway != nil {
+		h.Gateway.Close()
+	}
+	if h.llmService != nil {
+		h.llmService.Stop()
+	}
+	for _, s := range h.servers {
+		s.Stop()
+	}
+	h.Google.Close()
+	h.Index.Close()
+	h.Vectors.Close()
+	h.Ollama.Close()
+	h.VLLM.Close()
+	h.Webhook.Close()
+	h.Slack.Close()
+	h.Events.Close()
+	h.PDF.Close()
+	h.OIDC.Close()
+	h.Dictionary.Close()
+	h.AnswerAPIs.Close()
+	h.Images.Close()
+	if h.exportDir != "" {
+		os.RemoveAll(h.exportDir)
+	}
+}
+
+func (h *Harness) newConfig() (*config.Config, error) {
+	ollamaHost, ollamaPort, err := hostPort(h.Ollama.URL)
+	if err != nil {
+		return nil, err
+	}
+	vllmHost, vllmPort, err := hostPort(h.VLLM.URL)
+	if err != nil {
+		return nil, err
+	}
+	if h.exportDir, err = os.MkdirTemp("", "e2e-exports-"); err != nil {
+		return nil, err
+	}
+
+	service := func(name string) config.ServiceConfig {
+		return config.ServiceConfig{Host: name, Port: 1, Timeout: 10 * time.Second}
+	}
+
+	return &config.Config{
+		Environment: "test",
+		LogLevel:    "warn",
+		Gateway: config.GatewayConfig{
+			Port:         0,
+			Timeout:      30 * time.Second,
+			StreamBuffer: config.StreamBufferConfig{Enabled: true, MaxEvents: 1000, TTL: time.Minute},
+			Partials:     config.PartialsConfig{Enabled: true, TTL: time.Minute},
+			Idempotency:  config.IdempotencyConfig{Enabled: true, TTL: time.Minute},
+			Coalescing:   config.CoalescingConfig{Enabled: true},
+			// Off by default; the scenario's tenant sets its own policy
+			ResponseCache: config.ResponseCacheConfig{Enabled: true, MaxEntries: 100},
+			Ingestion:     config.IngestionConfig{Enabled: true, JobTTL: time.Minute, MaxDocumentBytes: 1 << 20, MaxBulkDocuments: 10, MaxPages: 2, MaxInflatedBytes: 1 << 20},
+			Batch:         config.BatchConfig{Enabled: true, JobTTL: time.Minute, MaxQueries: 10, MaxFileBytes: 16 << 10, MaxRunning: 1, MaxConcurrent: 2},
+			Scheduler: config.SchedulerConfig{
+				Enabled: true, TickInterval: 20 * time.Millisecond, MaxConcurrent: 2, MaxQueries: 10,
+				MinInterval: time.Minute, RunHistory: 5, ChangeThreshold: 0.3, MinNewSources: 1,
+			},
+			OpenSearch: config.OpenSearchConfig{ShortName: "AI Search", Description: "Test search engine"},
+			HTMLSearch: config.HTMLSearchConfig{Enabled: true, FragmentMaxAge: 5 * time.Minute},
+			Docs:       config.DocsConfig{Enabled: true, SwaggerUIURL: "https://unpkg.com/swagger-ui-dist@5"},
+			GraphQL:    config.GraphQLConfig{Enabled: true, MaxDepth: 8, MaxSearches: 3},
+			Suggest:    config.SuggestConfig{Enabled: true, MaxResults: 5, MinCount: 2, MaxEntries: 100, MaxQueryWords: 8, Timeout: 5 * time.Second},
+			Exports: config.ExportsConfig{Enabled: true, TTL: time.Minute, PDFRendererURL: h.PDF.URL, PDFTimeout: 10 * time.Second,
+				Storage: config.ObjectStorageConfig{Backend: "filesystem", Directory: h.exportDir, SigningKey: "e2e-export-signing-key", URLTTL: time.Minute},
+			},
+			Tenancy: config.TenancyConfig{
+				Enabled: true, BaseDomain: "search.test", AdminToken: "e2e-admin-token",
+				Tenants: []config.TenantConfig{
+					{ID: "acme", Name: "Acme", Subdomain: "acme", APIKeys: []string{"acme-key"}, Sources: []string{"web"}, BlockedTerms: []string{"project falcon"}},
+					{ID: "globex", Name: "Globex", APIKeys: []string{"globex-key"}, Sources: []string{"elasticsearch"}},
+					{ID: "initech", Name: "Initech", APIKeys: []string{"initech-key"}, RequestsPerDay: 2},
+				},
+			},
+			RBAC: config.RBACConfig{
+				Enabled: true, AuditEntries: 100,
+				APIKeys: []config.RBACKeyConfig{
+					{Name: "e2e-viewer", Key: "viewer-key", Role: "viewer"},
+					{Name: "e2e-operator", Key: "operator-key", Role: "operator"},
+				},
+			},
+			OIDC: config.OIDCConfig{
+				Issuer: h.OIDC.URL, ClientID: h.OIDC.ClientID, ClientSecret: h.OIDC.ClientSecret,
+				RoleClaim: "groups", Operators: []string{"search-operators"},
+			},
+			Limits: config.LimitsConfig{
+				MaxBodyBytes: 64 << 10, MaxQueryLength: 200, MaxNumResults: 20,
+				ReadHeaderTimeout: 5 * time.Second, BodyTimeout: 500 * time.Millisecond,
+			},
+			MemoryStoreEntries: 1000,
+			Server: config.ServerConfig{
+				IdleTimeout: time.Minute, WriteTimeout: 1500 * time.Millisecond, StreamWriteTimeout: time.Minute,
+				HTTP2: true, H2C: true, MaxConcurrentStreams: 100,
+			},
+			Sharing: config.SharingConfig{Enabled: true, Secret: "e2e-share-secret", TTL: time.Hour, MaxTTL: 24 * time.Hour},
+			Instant: config.InstantConfig{
+				Enabled: true, DictionaryURL: h.Dictionary.URL + "/api/v2/entries/en", DictionaryTimeout: time.Second,
+				CurrencyRates: map[string]float64{"usd": 1, "eur": 0.92},
+			},
+			AnswerProviders: config.AnswerProvidersConfig{
+				Weather: config.AnswerProviderConfig{Enabled: true, URL: h.AnswerAPIs.URL + "/weather", APIKey: "e2e-weather-key"},
+				Stocks:  config.AnswerProviderConfig{Enabled: true, URL: h.AnswerAPIs.URL + "/finance"},
+				Sports:  config.AnswerProviderConfig{Enabled: true, URL: h.AnswerAPIs.URL + "/sports"},
+			},
+			RelatedSearches: config.RelatedSearchesConfig{Enabled: true, Max: 4},
+			// Off by its flag until a scenario turns it on
+			Reranking:   config.RerankingConfig{Enabled: true, MinScore: 0.3, MinResults: 3},
+			Snippets:    config.SnippetsConfig{Enabled: true, Compression: config.SnippetCompressionConfig{Enabled: true, MaxWords: 25}},
+			PromptAudit: config.PromptAuditConfig{Enabled: true, Retention: time.Hour, RedactPatterns: []string{`\bCASE-\d{4}\b`}},
+			// The janitor sweeps throughout the run without expiring anything
+			Retention: config.RetentionConfig{JanitorInterval: 50 * time.Millisecond, History: time.Hour, AuditLog: time.Hour, Caches: time.Hour},
+			ImageProxy: config.ImageProxyConfig{
+				Enabled: true, Secret: "e2e-image-secret", MaxBytes: 64 << 10, Timeout: 2 * time.Second,
+				CacheEntries: 16, CacheTTL: time.Hour, AllowPrivate: true, // the fakes listen on loopback
+			},
+			Locale: config.LocaleConfig{AcceptLanguage: true},
+			Sampling: config.SamplingConfig{
+				Temperature: config.RangeConfig{Max: 2}, TopP: config.RangeConfig{Max: 1},
+				FrequencyPenalty: config.RangeConfig{Min: -2, Max: 2}, PresencePenalty: config.RangeConfig{Min: -2, Max: 2},
+				MaxStop: 4, MaxStopLength: 64, DeterministicSeed: 7,
+			},
+			Login: config.LoginConfig{Enabled: true, SessionSecret: "e2e-session-secret", SessionTTL: time.Hour, CookieName: "ai_search_session", HistoryEntries: 10},
+		},
+		Services: config.ServicesConfig{
+			Search:    service("search"),
+			Tokenizer: service("tokenizer"),
+			Inference: service("inference"),
+			Safety:    service("safety"),
+			LLM:       service("llm"),
+		},
+		Google: config.GoogleConfig{APIKey: "fake-key", CX: "fake-cx", BaseURL: h.Google.URL, SuggestURL: h.Google.URL + "/complete/search", PaywallDomains: []string{"wsj.com"}, RestrictLanguage: true},
+		Safety: config.SafetyConfig{
+			SuggestionDenylist: []string{"forbidden topic"},
+			Toxicity:           config.ToxicityConfig{AnnotateThreshold: 0.2, RewriteThreshold: 0.3, BlockThreshold: 0.9},
+			BatchWorkers:       4,
+			MaxBatchSize:       16,
+		},
+		Elasticsearch: config.ElasticsearchConfig{
+			Enabled: true, URL: h.Index.URL, Index: "wiki", Timeout: 10 * time.Second,
+			TitleField: "title", URLField: "url", ContentField: "content", FragmentSize: 200, Weight: 0.4,
+		},
+		VectorStore: config.VectorStoreConfig{
+			Enabled: true, Backend: "qdrant", URL: h.Vectors.URL, Collection: "runbooks", Timeout: 10 * time.Second,
+			TopK: 5, ScoreThreshold: 0.5, TitleField: "title", URLField: "url", ContentField: "content", SnippetLength: 200, Weight: 0.2,
+			HashField: "content_hash", ChunkSize: 200, ChunkOverlap: 40,
+		},
+		LLM: config.LLMConfig{MaxWorkers: 10, MaxQueueSize: 100, DetokenizeBatchSize: 4, DetokenizeFlushInterval: 50 * time.Millisecond, RequestTTL: time.Minute, StreamTTL: time.Minute, ReapInterval: 10 * time.Second, MaxFinishedRequests: 1000,
+			StreamGuard:    config.StreamGuardConfig{Enabled: true, MaxNGram: 4, MaxRepeats: 4, MaxWords: 200},
+			StreamRecovery: config.StreamRecoveryConfig{Enabled: true},
+			StreamPool:     config.StreamPoolConfig{Enabled: true, Size: 2, MaxIdle: time.Minute, CheckInterval: 20 * time.Millisecond},
+			ChatTemplates:  []config.ChatTemplateConfig{{Model: "fake-instruct", Template: "chatml"}},
+			Timeouts:       config.LLMTimeoutsConfig{Request: time.Minute, Tokenize: 300 * time.Millisecond, Inference: 30 * time.Second, Detokenize: 2 * time.Second},
+		},
+		Ollama: config.OllamaConfig{Host: ollamaHost, Port: ollamaPort, Model: "fake-llama", Temperature: 0.3, MaxTokens: 64, Timeout: 10 * time.Second},
+		VLLM:   config.VLLMConfig{Host: vllmHost, Port: vllmPort, Model: "facebook/bart-large-cnn", Timeout: 10 * time.Second},
+		Inference: config.InferenceConfig{
+			PrefixCache: config.PrefixCacheConfig{Enabled: true, Instruction: config.DefaultSummarizationInstruction, MaxEntries: 64},
+			Embedding:   config.EmbeddingConfig{Backend: "ollama", Model: "fake-embed"},
+			Related:     config.RelatedConfig{Backend: "vllm", MaxTokens: 128},
+			Rerank:      config.RerankConfig{Backend: "cross_encoder", URL: h.VLLM.URL + "/v1/rerank"},
+			// Not run at startup; model switches warm their target with it
+			Warmup: config.WarmupConfig{Prompt: "Summarize: The quick brown fox jumps over the lazy dog.", MaxTokens: 16},
+			ModelSwitch: config.ModelSwitchConfig{
+				CanaryPrompt: "What is the capital of France? Answer in one word.", CanaryExpect: "Paris", CanaryMaxTokens: 16,
+				Timeout: 10 * time.Second, Window: time.Minute, MinRequests: 2, MaxErrorRateIncrease: 0.5,
+			},
+		},
+		FaultInjection: config.FaultInjectionConfig{Enabled: true},
+		Budget:         config.BudgetConfig{MaxInputTokens: 1024, MaxOutputTokens: 256, MaxFetchedPages: 5, MaxLLMCalls: 2},
+		Notifications: config.NotificationsConfig{
+			Channels: []config.NotificationChannel{
+				{Name: "security", Type: "slack", URL: h.Slack.URL, Events: []string{"safety_block"}, MinSeverity: "critical", Cooldown: time.Minute},
+				{Name: "digests", Type: "slack", URL: h.Slack.URL, Events: []string{"scheduled_digest"}},
+			},
+			Overload: config.OverloadAlertConfig{Window: time.Minute, Rejections: 20},
+		},
+		Probes:      config.ProbesConfig{Timeout: 2 * time.Second},
+		Diagnostics: config.DiagnosticsConfig{Enabled: true, Token: "e2e-diagnostics-token"},
+		Privacy:     config.PrivacyConfig{IPMode: "truncate", IPv4Prefix: 24, IPv6Prefix: 48},
+		FeatureFlags: config.FeatureFlagsConfig{Flags: []config.FeatureFlagConfig{
+			{Name: flags.Rerank, Enabled: false},
+		}},
+		// Every durable subsystem runs on the store's repositories
+		Store: config.StoreConfig{Backend: "memory", Subsystems: []string{"audit_log", "usage", "saved_queries", "tenants"}},
+		Events: config.EventsConfig{
+			Enabled:       true,
+			Bus:           "webhook",
+			URL:           h.Events.URL,
+			Timeout:       2 * time.Second,
+			RelayInterval: 50 * time.Millisecond,
+			BatchSize:     100,
+			Retention:     time.Hour,
+		},
+		// Rotated once: e2e-2 wraps new values, e2e-1 still opens old ones
+		Encryption: config.EncryptionConfig{Keys: []config.EncryptionKeyConfig{
+			{ID: "e2e-2", Key: "ZTJlLWVuY3J5cHRpb24ta2V5LW51bWJlci10d28uLiE="},
+			{ID: "e2e-1", Key: "ZTJlLWVuY3J5cHRpb24ta2V5LW51bWJlci1vbmUuLiE="},
+		}},
+	}, nil
+}
+
+func (h *Harness) startServices() error {
+	cfg := h.Config
+
+	var err error
+	h.Safety, err = safety.NewSafetyService(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create safety service: %w", err)
+	}
+	h.Inference, err = inference.NewInferenceService(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create inference service: %w", err)
+	}
+	h.Inference.StartWarmup()
+
+	h.serve(cfg.GetTokenizerAddress(), func(s *grpc.Server) { pb.RegisterTokenizerServiceServer(s, h.Tokenizer) })
+	h.serve(fmt.Sprintf("%s:%d", cfg.Services.Safety.Host, cfg.Services.Safety.Port), func(s *grpc.Server) { pb.RegisterSafetyServiceServer(s, h.Safety) })
+	h.serve(cfg.GetInferenceAddress(), func(s *grpc.Server) { pb.RegisterInferenceServiceServer(s, h.Inference) })
+
+	// Search dials inference to embed queries for the vector store
+	searchService, err := search.NewSearchService(cfg, h.DialOption())
+	if err != nil {
+		return fmt.Errorf("failed to create search service: %w", err)
+	}
+	h.serve(fmt.Sprintf("%s:%d", cfg.Services.Search.Host, cfg.Services.Search.Port), func(s *grpc.Server) { pb.RegisterSearchServiceServer(s, searchService) })
+
+	// The orchestrator dials tokenizer and inference, so it starts last
+	h.llmService, err = llm.NewLLMService(cfg, h.DialOption())
+	if err != nil {
+		return fmt.Errorf("failed to create LLM service: %w", err)
+	}
+	h.serve(cfg.GetLLMAddress(), func(s *grpc.Server) { pb.RegisterLLMOrchestratorServiceServer(s, h.llmService) })
+
+	return nil
+}
+
+// serve starts a gRPC server for addr on a new in-memory listener
+func (h *Harness) serve(addr string, register func(*grpc.Server)) {
+	lis := bufconn.Listen(bufSize)
+	name, _, _ := net.SplitHostPort(addr)
+	s := grpc.NewServer(faults.ServerOptions(h.Config, name)...)
+	register(s)
+	h.listeners[addr] = lis
+	h.servers = append(h.servers, s)
+	go s.Serve(lis)
+}
+
+func hostPort(rawURL string) (string, int, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid fake server URL %q: %w", rawURL, err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid fake server port in %q: %w", rawURL, err)
+	}
+	return u.Hostname(), port, nil
+}
@@ -0,0 +1,292 @@
+package testharness
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ai-search-service/internal/gateway"
+	"ai-search-service/pkg/client"
+)
+
+// openAPIMethods are the operation keys of an OpenAPI path item
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPISpec checks the served OpenAPI spec against the gateway's router:
+// every route is documented and every documented operation is routed, each
+// operation has a unique operationId, responses and its path parameters,
+// and every $ref resolves. It also checks that /docs serves Swagger UI for
+// the spec.
+func openAPISpec(ctx context.Context, h *Harness) error {
+	var spec map[string]interface{}
+	if err := h.getJSON(ctx, "/api/v1/openapi.json", &spec); err != nil {
+		return err
+	}
+	if version, _ := spec["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return fmt.Errorf("expected an OpenAPI 3 document, got version %q", version)
+	}
+	paths, _ := spec["paths"].(map[string]interface{})
+
+	var problems []string
+	routed := make(map[string]bool)
+	for _, route := range h.Routes {
+		path := openAPIPath(route.Path)
+		method := strings.ToLower(route.Method)
+		routed[method+" "+path] = true
+		item, _ := paths[path].(map[string]interface{})
+		if _, ok := item[method]; !ok {
+			problems = append(problems, fmt.Sprintf("route %s %s is not in the spec", route.Method, path))
+		}
+	}
+
+	operationIDs := make(map[string]string)
+	for path, item := range paths {
+		item, _ := item.(map[string]interface{})
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := strings.ToUpper(method) + " " + path
+			if !routed[method+" "+path] {
+				problems = append(problems, name+" is in the spec but not routed")
+			}
+			id, _ := op["operationId"].(string)
+			if id == "" {
+				problems = append(problems, name+" has no operationId")
+			} else if other, dup := operationIDs[id]; dup {
+				problems = append(problems, fmt.Sprintf("%s reuses operationId %s of %s", name, id, other))
+			}
+			operationIDs[id] = name
+			if responses, _ := op["responses"].(map[string]interface{}); len(responses) == 0 {
+				problems = append(problems, name+" has no responses")
+			}
+			declared := pathParams(spec, item["parameters"], op["parameters"])
+			for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+				if !declared[m[1]] {
+					problems = append(problems, fmt.Sprintf("%s does not declare path parameter %s", name, m[1]))
+				}
+			}
+		}
+	}
+
+	walkRefs(spec, func(ref string) {
+		if resolveRef(spec, ref) == nil {
+			problems = append(problems, "unresolved $ref "+ref)
+		}
+	})
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi.yaml is out of date:\n%s", strings.Join(problems, "\n"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.Gateway.URL+"/docs", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return fmt.Errorf("GET /docs returned %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "SwaggerUIBundle") || !strings.Contains(string(body), "/api/v1/openapi.json") {
+		return fmt.Errorf("GET /docs does not load Swagger UI for the spec:\n%s", body)
+	}
+	return nil
+}
+
+// openAPIPath turns a gin route into an OpenAPI path template
+func openAPIPath(route string) string {
+	segments := strings.Split(route, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams is the names of the path parameters an operation declares,
+// itself or on its path item
+func pathParams(spec map[string]interface{}, lists ...interface{}) map[string]bool {
+	names := make(map[string]bool)
+	for _, list := range lists {
+		params, _ := list.([]interface{})
+		for _, p := range params {
+			param, _ := p.(map[string]interface{})
+			if ref, ok := param["$ref"].(string); ok {
+				param, _ = resolveRef(spec, ref).(map[string]interface{})
+			}
+			if in, _ := param["in"].(string); in == "path" {
+				name, _ := param["name"].(string)
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+// walkRefs calls fn with every $ref in the document
+func walkRefs(node interface{}, fn func(ref string)) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for k, v := range node {
+			if ref, ok := v.(string); ok && k == "$ref" {
+				fn(ref)
+				continue
+			}
+			walkRefs(v, fn)
+		}
+	case []interface{}:
+		for _, v := range node {
+			walkRefs(v, fn)
+		}
+	}
+}
+
+// resolveRef follows a local JSON pointer ("#/components/schemas/Tenant"),
+// returning nil when it points nowhere
+func resolveRef(spec map[string]interface{}, ref string) interface{} {
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil
+	}
+	var node interface{} = spec
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if node, ok = m[token]; !ok {
+			return nil
+		}
+	}
+	return node
+}
+
+// invalidRequests sends requests that break the spec's parameter and body
+// schemas and checks that each is rejected with a 400 naming every
+// offending field, over JSON, over an event stream and through the Go
+// client
+func invalidRequests(ctx context.Context, h *Harness) error {
+	type rejection struct {
+		Error  string               `json:"error"`
+		Errors []gateway.FieldError `json:"errors"`
+	}
+	expectFields := func(what string, got []gateway.FieldError, want ...gateway.FieldError) error {
+		for _, w := range want {
+			found := false
+			for _, g := range got {
+				found = found || (g.Field == w.Field && g.In == w.In && strings.Contains(g.Message, w.Message))
+			}
+			if !found {
+				return fmt.Errorf("%s: expected a field error %+v, got %+v", what, w, got)
+			}
+		}
+		return nil
+	}
+
+	var body rejection
+	status, err := h.send(ctx, http.MethodPost, "/api/v1/search", `{"query":"","num_results":-1,"safe_search":"yes","sampling":{"top_p":"high"}}`, nil, &body)
+	if err != nil || status != http.StatusBadRequest {
+		return fmt.Errorf("expected a 400 for an invalid search body, got %d (%v)", status, err)
+	}
+	if err := expectFields("search body", body.Errors,
+		gateway.FieldError{Field: "query", In: "body", Message: "must not be empty"},
+		gateway.FieldError{Field: "num_results", In: "body", Message: "must be at least 0"},
+		gateway.FieldError{Field: "safe_search", In: "body", Message: "must be true or false"},
+		gateway.FieldError{Field: "sampling.top_p", In: "body", Message: "must be a number"},
+	); err != nil {
+		return err
+	}
+	if !strings.Contains(body.Error, "num_results must be at least 0") {
+		return fmt.Errorf("expected the error summary to name the fields, got %q", body.Error)
+	}
+
+	// Ranges that come from the configuration rather than the spec
+	body = rejection{}
+	status, err = h.send(ctx, http.MethodPost, "/api/v1/search", `{"query":"golang","sampling":{"temperature":9}}`, nil, &body)
+	if err != nil || status != http.StatusBadRequest {
+		return fmt.Errorf("expected a 400 for an out of range temperature, got %d (%v)", status, err)
+	}
+	if err := expectFields("sampling", body.Errors, gateway.FieldError{Field: "sampling.temperature", In: "body", Message: "must be between"}); err != nil {
+		return err
+	}
+
+	body = rejection{}
+	status, err = h.send(ctx, http.MethodGet, "/api/v1/search/some-task/export?format=docx", "", nil, &body)
+	if err != nil || status != http.StatusBadRequest {
+		return fmt.Errorf("expected a 400 for an unknown export format, got %d (%v)", status, err)
+	}
+	if err := expectFields("export", body.Errors, gateway.FieldError{Field: "format", In: "query", Message: "must be one of md, html, pdf"}); err != nil {
+		return err
+	}
+
+	body = rejection{}
+	admin := http.Header{"Authorization": {"Bearer e2e-admin-token"}}
+	status, err = h.send(ctx, http.MethodPost, "/admin/tenants", `{"name":"No ID","requests_per_day":-5}`, admin, &body)
+	if err != nil || status != http.StatusBadRequest {
+		return fmt.Errorf("expected a 400 for a tenant without an ID, got %d (%v)", status, err)
+	}
+	if err := expectFields("tenant", body.Errors,
+		gateway.FieldError{Field: "id", In: "body", Message: "is required"},
+		gateway.FieldError{Field: "requests_per_day", In: "body", Message: "must be at least 0"},
+	); err != nil {
+		return err
+	}
+
+	// Streaming searches get the errors as an error event
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.Gateway.URL+"/api/v1/search?num_results=many", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	events, err := ReadEvents(bufio.NewScanner(resp.Body))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusBadRequest || len(events) != 1 || events[0].Name != "error" {
+		return fmt.Errorf("expected a 400 with one error event, got %d %+v", resp.StatusCode, events)
+	}
+	var event struct {
+		Errors []gateway.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(events[0].Data), &event); err != nil {
+		return err
+	}
+	if err := expectFields("stream", event.Errors,
+		gateway.FieldError{Field: "query", In: "query", Message: "is required"},
+		gateway.FieldError{Field: "num_results", In: "query", Message: "must be an integer"},
+	); err != nil {
+		return err
+	}
+
+	// The Go client surfaces the field errors and does not retry them
+	c := client.New(h.Gateway.URL)
+	_, err = c.Search(ctx, &client.SearchRequest{Query: "golang", NumResults: -3})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "num_results" {
+		return fmt.Errorf("expected the client to report the num_results field, got %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package testharness
+
+import (
+	"context"
+	"strings"
+
+	"ai-search-service/internal/pipeline"
+)
+
+// Registered at compile time, the way a downstream deployment would add its
+// own stages
+func init() {
+	pipeline.Register(pipeline.StageSearch, knowledgeBaseStage{})
+}
+
+// KnowledgeBaseTitle is the result the fake knowledge-base stage adds for
+// queries mentioning the handbook
+const KnowledgeBaseTitle = "Engineering handbook (internal)"
+
+// knowledgeBaseStage stands in for an internal knowledge-base lookup: it
+// puts an internal document ahead of the web results
+type knowledgeBaseStage struct{}
+
+func (knowledgeBaseStage) Name() string { return "knowledge_base" }
+
+func (knowledgeBaseStage) Before(ctx context.Context, state *pipeline.State) error { return nil }
+
+func (knowledgeBaseStage) After(ctx context.Context, state *pipeline.State) error {
+	if !strings.Contains(strings.ToLower(state.Query), "handbook") {
+		return nil
+	}
+	doc := pipeline.Result{
+		Title:      KnowledgeBaseTitle,
+		URL:        "https://wiki.internal/handbook",
+		Snippet:    "How we build, review and ship services.",
+		DisplayURL: "wiki.internal",
+	}
+	state.Results = append([]pipeline.Result{doc}, state.Results...)
+	return nil
+}
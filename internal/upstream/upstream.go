@@ -0,0 +1,212 @@
+// Package upstream captures and replays HTTP calls to external APIs (Google,
+// Ollama, vLLM). In record mode responses are written to fixture files; in
+// replay mode those fixtures are served instead of touching the network, which
+// makes benchmarks reproducible and allows offline development without keys.
+package upstream
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-search-service/internal/config"
+	"ai-search-service/internal/logger"
+)
+
+// Upstream modes
+const (
+	ModeLive   = "live"
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// redactedParams never reach fixture files or fixture keys
+var redactedParams = []string{"key", "api_key", "cx"}
+
+// Fixture is one recorded request/response pair
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+// FixtureRequest identifies the recorded request
+type FixtureRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"` // path and redacted query; host is not part of the key
+	Body   string `json:"body,omitempty"`
+}
+
+// FixtureResponse is replayed verbatim
+type FixtureResponse struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body"`
+}
+
+// Transport records or replays requests for one upstream
+type Transport struct {
+	mode string
+	dir  string // fixtures for this upstream
+	base http.RoundTripper
+	mu   sync.Mutex // serializes fixture writes
+}
+
+// NewTransport wraps base (http.DefaultTransport when nil) for the given mode.
+// Fixtures for the upstream are stored under dir/name.
+func NewTransport(mode, dir, name string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{mode: mode, dir: filepath.Join(dir, name), base: base}
+}
+
+// WrapTransport returns the transport to use for an upstream according to the
+// configured mode; in live mode base is returned unchanged
+func WrapTransport(cfg *config.Config, name string, base http.RoundTripper) http.RoundTripper {
+	switch cfg.UpstreamMode {
+	case ModeRecord, ModeReplay:
+		logger.GetLogger().Infof("Upstream %s in %s mode (fixtures: %s)", name, cfg.UpstreamMode, filepath.Join(cfg.FixturesDir, name))
+		return NewTransport(cfg.UpstreamMode, cfg.FixturesDir, name, base)
+	default:
+		if base == nil {
+			return http.DefaultTransport
+		}
+		return base
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, fixtureReq, err := fixtureKey(req)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(t.dir, key+".json")
+
+	if t.mode == ModeReplay {
+		return t.replay(req, path)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || t.mode != ModeRecord {
+		return resp, err
+	}
+	return t.record(resp, fixtureReq, path)
+}
+
+func (t *Transport) replay(req *http.Request, path string) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no recorded fixture for %s %s (%s): %w", req.Method, req.URL.Path, path, err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+
+	header := make(http.Header)
+	for k, v := range fixture.Response.Header {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Response.StatusCode, http.StatusText(fixture.Response.StatusCode)),
+		StatusCode:    fixture.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(fixture.Response.Body)),
+		ContentLength: int64(len(fixture.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// record buffers the whole response (streams included) and writes the fixture
+func (t *Transport) record(resp *http.Response, fixtureReq FixtureRequest, path string) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upstream response for recording: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Request: fixtureReq,
+		Response: FixtureResponse{
+			StatusCode: resp.StatusCode,
+			Header:     map[string]string{"Content-Type": resp.Header.Get("Content-Type")},
+			Body:       string(body),
+		},
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		logger.GetLogger().Warnf("Failed to create fixtures dir %s: %v", t.dir, err)
+		return resp, nil
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		logger.GetLogger().Warnf("Failed to write fixture %s: %v", path, err)
+	}
+	return resp, nil
+}
+
+// fixtureKey derives a stable key from the method, path, redacted query and
+// body, restoring the request body so it can still be sent
+func fixtureKey(req *http.Request) (string, FixtureRequest, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", FixtureRequest{}, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	fixtureReq := FixtureRequest{
+		Method: req.Method,
+		URL:    req.URL.Path + canonicalQuery(req.URL.Query()),
+		Body:   string(body),
+	}
+
+	sum := sha256.Sum256([]byte(fixtureReq.Method + " " + fixtureReq.URL + "\n" + fixtureReq.Body))
+	return hex.EncodeToString(sum[:12]), fixtureReq, nil
+}
+
+func canonicalQuery(query url.Values) string {
+	for _, p := range redactedParams {
+		query.Del(p)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return "?" + strings.Join(parts, "&")
+}
@@ -0,0 +1,323 @@
+// Package client is a typed Go client for the search gateway's HTTP API:
+// JSON and streaming searches, input validation, quota usage and the
+// logged-in user's search history. Calls take a context, and requests that
+// fail with a network error or a 429, 502, 503 or 504 are retried with
+// exponential backoff, honouring Retry-After.
+//
+//	c := client.New("https://search.example.com", client.WithAPIKey(key))
+//	resp, err := c.Search(ctx, &client.SearchRequest{Query: "golang generics"})
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy is how failed requests are retried
+type RetryPolicy struct {
+	MaxAttempts int           // including the first; 1 disables retries
+	MinBackoff  time.Duration // before the first retry, doubling for each one after
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryPolicy tries a request up to three times, waiting about 250ms
+// and then 500ms
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, MinBackoff: 250 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// Client calls one gateway. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	header  http.Header
+	retry   RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient.
+// Its Timeout must allow for streaming searches; use contexts to bound calls.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithAPIKey authenticates as the tenant the key belongs to
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithBearerToken authenticates with an OIDC access token
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithSessionCookie sends a web UI login session, which History needs
+func WithSessionCookie(cookie *http.Cookie) Option {
+	return WithHeader("Cookie", cookie.String())
+}
+
+// WithHeader adds a header to every request
+func WithHeader(key, value string) Option {
+	return func(c *Client) { c.header.Add(key, value) }
+}
+
+// WithRetry replaces DefaultRetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// New returns a client of the gateway at baseURL, e.g.
+// "https://search.example.com"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    http.DefaultClient,
+		header:  make(http.Header),
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c
+}
+
+// APIError is a request the gateway answered with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError  // on 400, every field that failed validation
+	RetryAfter time.Duration // from the Retry-After header, when sent
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gateway returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether retrying the request later may succeed
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Search runs a search and waits for its summary. Retries carry the same
+// Idempotency-Key, so the gateway runs the search only once.
+func (c *Client) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{"Idempotency-Key": {newIdempotencyKey()}}
+	var resp SearchResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/search", body, header, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CancelSearch stops a streaming search, whose stream then ends with a
+// *CancelledEvent. requestID is the RequestID of its "started" StatusEvent.
+func (c *Client) CancelSearch(ctx context.Context, requestID string) error {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	return c.do(ctx, http.MethodPost, "/api/v1/search/"+url.PathEscape(requestID)+"/cancel", nil, nil, &resp)
+}
+
+// Validate checks a text with the safety service
+func (c *Client) Validate(ctx context.Context, text string) (*Validation, error) {
+	body, _ := json.Marshal(map[string]string{"text": text})
+	var resp Validation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/validate", body, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Usage returns the calling tenant's quota usage
+func (c *Client) Usage(ctx context.Context) (*Usage, error) {
+	var resp Usage
+	if err := c.do(ctx, http.MethodGet, "/api/v1/usage", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// History returns up to limit of the logged-in user's searches, newest
+// first. It needs WithSessionCookie.
+func (c *Client) History(ctx context.Context, limit int) ([]HistoryEntry, error) {
+	var resp struct {
+		Searches []HistoryEntry `json:"searches"`
+	}
+	path := "/api/v1/me/history?" + url.Values{"limit": {strconv.Itoa(limit)}}.Encode()
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Searches, nil
+}
+
+// do sends a JSON request, retrying under the policy, and decodes the
+// response into out
+func (c *Client) do(ctx context.Context, method, path string, body []byte, header http.Header, out interface{}) error {
+	return c.withRetry(ctx, func() error {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := c.newRequest(ctx, method, path, reader, header)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return readAPIError(resp)
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	})
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+// readAPIError turns an error response into an *APIError. The gateway
+// answers with {"error": ..., "errors": [...]}, or with an error event on
+// event stream requests; anything else is kept as the message.
+func readAPIError(resp *http.Response) *APIError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	for _, line := range strings.Split(string(data), "\n") {
+		if event, ok := strings.CutPrefix(line, "data:"); ok {
+			data = []byte(event)
+			break
+		}
+	}
+	var body struct {
+		Error   string       `json:"error"`
+		Message string       `json:"message"` // of an error event
+		Errors  []FieldError `json:"errors"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Error == "" {
+			body.Error = body.Message
+		}
+		if body.Error != "" {
+			apiErr.Message = body.Error
+		}
+		apiErr.Fields = body.Errors
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// withRetry calls attempt until it succeeds, fails permanently or the
+// policy's attempts are used up
+func (c *Client) withRetry(ctx context.Context, attempt func() error) error {
+	var err error
+	for n := 0; n < c.retry.MaxAttempts; n++ {
+		if n > 0 {
+			wait, ok := c.backoff(n, err)
+			if !ok || sleep(ctx, wait) != nil {
+				return err
+			}
+		}
+		if err = attempt(); err == nil || !retryable(ctx, err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryable reports whether err may go away on retry: network errors and
+// temporary statuses, but not the caller's own cancellation
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr)
+}
+
+// backoff is the wait before retry n (from 1): exponential with jitter,
+// capped at MaxBackoff, and never shorter than a Retry-After the gateway
+// asked for. A Retry-After beyond MaxBackoff, such as an exhausted daily
+// quota, is not worth waiting for and returns false.
+func (c *Client) backoff(n int, err error) (time.Duration, bool) {
+	wait := c.retry.MinBackoff << (n - 1)
+	if wait <= 0 || (c.retry.MaxBackoff > 0 && wait > c.retry.MaxBackoff) {
+		wait = c.retry.MaxBackoff
+	}
+	if wait > 0 {
+		// Between half and all of it, so clients that failed together do
+		// not retry together
+		wait = wait/2 + rand.N(wait/2+1)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+		if c.retry.MaxBackoff > 0 && apiErr.RetryAfter > c.retry.MaxBackoff {
+			return 0, false
+		}
+		wait = apiErr.RetryAfter
+	}
+	return wait, true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,371 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a server-sent event of a streaming search. Switch on its type:
+// *StatusEvent, *ResultsEvent, *TokenEvent, *SummaryEvent, *CompleteEvent,
+// *ErrorEvent and so on. Events this package does not know arrive as
+// *UnknownEvent.
+type Event interface {
+	Name() string // the SSE event name
+}
+
+// StatusEvent reports the search's progress. The first is "started" and
+// carries the request ID and query; then come validating, searching and
+// summarizing.
+type StatusEvent struct {
+	Status    string `json:"type"`
+	RequestID string `json:"request_id,omitempty"`
+	Query     string `json:"query,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"` // Unix seconds
+}
+
+// ResultsEvent carries the search results, ahead of the summary
+type ResultsEvent struct {
+	Results []Result `json:"results"`
+}
+
+// InstantAnswerEvent answers a calculation, conversion or definition in
+// place of results and summary
+type InstantAnswerEvent struct {
+	Answer *InstantAnswer `json:"answer"`
+}
+
+// StructuredAnswerEvent carries an answer provider's data
+type StructuredAnswerEvent struct {
+	Answer *StructuredAnswer `json:"answer"`
+}
+
+// KnowledgePanelEvent carries the entity the query is about
+type KnowledgePanelEvent struct {
+	Panel *KnowledgePanel `json:"panel"`
+}
+
+// TokenEvent is the next piece of the summary
+type TokenEvent struct {
+	Token    string `json:"token"`
+	Position int32  `json:"position"`
+}
+
+// SanitizedEvent reports that the safety filter changed the streamed
+// summary; the tokens already received are not what was kept
+type SanitizedEvent struct {
+	Message         string   `json:"message"`
+	OriginalLength  int      `json:"original_length"`
+	SanitizedLength int      `json:"sanitized_length"`
+	Warnings        []string `json:"warnings"`
+}
+
+// SummaryEvent ends the summary. Text is only set on streams that send
+// the summary whole instead of token by token.
+type SummaryEvent struct {
+	Text            string        `json:"text,omitempty"`
+	Confidence      *Confidence   `json:"confidence,omitempty"`
+	Answer          *Answer       `json:"answer,omitempty"`
+	RelatedSearches []string      `json:"related_searches,omitempty"`
+	Safety          *SafetyReport `json:"safety,omitempty"`
+	// Truncated marks a summary the server cut short because it repeated
+	// itself or ran too long; TruncatedReason is repetition or max_length
+	Truncated       bool   `json:"truncated,omitempty"`
+	TruncatedReason string `json:"truncated_reason,omitempty"`
+	// Recovered marks a summary the server's backend stopped generating
+	// partway and a fallback backend finished
+	Recovered bool `json:"recovered,omitempty"`
+	// InputTruncated marks a summary of search results that did not all
+	// fit the model's context, so part of them went unread
+	InputTruncated bool `json:"input_truncated,omitempty"`
+}
+
+// BudgetEvent reports the limits the search ran into
+type BudgetEvent struct {
+	Budget
+}
+
+// CompleteEvent ends a successful stream
+type CompleteEvent struct {
+	TaskID string `json:"task_id,omitempty"` // exports the search while it is kept
+}
+
+// CancelledEvent ends a stream stopped with Client.CancelSearch
+type CancelledEvent struct {
+	RequestID string `json:"request_id"`
+}
+
+// ShutdownEvent warns that the gateway is draining. The stream is allowed
+// to finish; if the connection closes first, the client resumes it.
+type ShutdownEvent struct {
+	Message  string
+	Deadline time.Time // zero when the gateway gave none
+}
+
+// ErrorEvent ends a failed stream: the gateway refused or failed the
+// search, or Err is set because the connection could not be resumed
+type ErrorEvent struct {
+	Message    string
+	RequestID  string
+	RetryAfter time.Duration // when a quota or overload may clear
+	Err        error
+}
+
+// UnknownEvent is an event this version of the package does not know
+type UnknownEvent struct {
+	Event string
+	Data  json.RawMessage
+}
+
+func (*StatusEvent) Name() string           { return "status" }
+func (*ResultsEvent) Name() string          { return "search_results" }
+func (*InstantAnswerEvent) Name() string    { return "instant_answer" }
+func (*StructuredAnswerEvent) Name() string { return "structured_answer" }
+func (*KnowledgePanelEvent) Name() string   { return "knowledge_panel" }
+func (*TokenEvent) Name() string            { return "token" }
+func (*SanitizedEvent) Name() string        { return "summary_sanitized" }
+func (*SummaryEvent) Name() string          { return "summary" }
+func (*BudgetEvent) Name() string           { return "budget_exceeded" }
+func (*CompleteEvent) Name() string         { return "complete" }
+func (*CancelledEvent) Name() string        { return "cancelled" }
+func (*ShutdownEvent) Name() string         { return "server_shutting_down" }
+func (*ErrorEvent) Name() string            { return "error" }
+func (e *UnknownEvent) Name() string        { return e.Event }
+
+func (e *ErrorEvent) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *ErrorEvent) Unwrap() error { return e.Err }
+
+// maxEventSize bounds one event's data; result lists are the largest
+const maxEventSize = 4 * 1024 * 1024
+
+// SearchStream runs a search, streaming the summary token by token. The
+// channel delivers events until a *CompleteEvent, *CancelledEvent or
+// *ErrorEvent and is then closed; cancelling ctx closes it early. A dropped connection is resumed
+// with Last-Event-ID under the retry policy, so no event is lost or
+// repeated, provided the gateway buffers streams (if it does not, the
+// search runs again and its events start over with a "started" status).
+// Sampling and Deterministic are not supported; use Search.
+func (c *Client) SearchStream(ctx context.Context, req *SearchRequest) (<-chan Event, error) {
+	if req.Sampling != nil || req.Deterministic {
+		return nil, errors.New("client: sampling parameters and deterministic mode need Search, not SearchStream")
+	}
+	params := url.Values{"query": {req.Query}}
+	if req.SafeSearch {
+		params.Set("safe_search", "true")
+	}
+	if req.NumResults > 0 {
+		params.Set("num_results", strconv.Itoa(req.NumResults))
+	}
+	s := &stream{
+		c:      c,
+		ctx:    ctx,
+		path:   "/api/v1/search?" + params.Encode(),
+		locale: req.Locale,
+		events: make(chan Event),
+	}
+	body, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	go s.run(body)
+	return s.events, nil
+}
+
+// stream follows one streaming search across reconnects
+type stream struct {
+	c      *Client
+	ctx    context.Context
+	path   string
+	locale string
+	lastID string // of the last event delivered
+	events chan Event
+}
+
+// connect opens the event stream, resuming after lastID when set
+func (s *stream) connect() (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := s.c.withRetry(s.ctx, func() error {
+		req, err := s.c.newRequest(s.ctx, http.MethodGet, s.path, nil, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		if s.locale != "" {
+			req.Header.Set("Accept-Language", s.locale)
+		}
+		if s.lastID != "" {
+			req.Header.Set("Last-Event-ID", s.lastID)
+		}
+		resp, err := s.c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			defer resp.Body.Close()
+			return readAPIError(resp)
+		}
+		body = resp.Body
+		return nil
+	})
+	return body, err
+}
+
+// run delivers the stream's events, reconnecting until a terminal event
+func (s *stream) run(body io.ReadCloser) {
+	defer close(s.events)
+	failures := 0
+	for {
+		terminal, received, err := s.read(body)
+		body.Close()
+		if terminal || s.ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF // closed without complete or error
+		}
+		if received {
+			failures = 0
+		}
+		failures++
+		if s.lastID == "" || failures >= s.c.retry.MaxAttempts {
+			// Without an event ID there is nothing to resume from
+			s.send(&ErrorEvent{Message: "stream interrupted: " + err.Error(), Err: err})
+			return
+		}
+		if wait, _ := s.c.backoff(failures, err); sleep(s.ctx, wait) != nil {
+			return
+		}
+		if body, err = s.connect(); err != nil {
+			if s.ctx.Err() == nil {
+				s.send(&ErrorEvent{Message: "stream could not be resumed: " + err.Error(), Err: err})
+			}
+			return
+		}
+	}
+}
+
+// read parses and delivers events until the body ends or a terminal event,
+// reporting whether any event arrived
+func (s *stream) read(body io.Reader) (terminal, received bool, err error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	var id, name string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "id":
+				id = value
+			case "event":
+				name = value
+			case "data":
+				data = append(data, value)
+			}
+			continue
+		}
+		if name == "" && data == nil {
+			continue
+		}
+		if name == "" {
+			name = "message"
+		}
+		event := decodeEvent(name, []byte(strings.Join(data, "\n")))
+		if !s.send(event) {
+			return false, received, s.ctx.Err()
+		}
+		received = true
+		if id != "" {
+			s.lastID = id
+		}
+		if _, ok := event.(*CompleteEvent); ok {
+			return true, true, nil
+		}
+		if _, ok := event.(*CancelledEvent); ok {
+			return true, true, nil
+		}
+		if _, ok := event.(*ErrorEvent); ok {
+			return true, true, nil
+		}
+		id, name, data = "", "", nil
+	}
+	return false, received, scanner.Err()
+}
+
+func (s *stream) send(event Event) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// decodeEvent turns an SSE event into its typed form
+func decodeEvent(name string, data []byte) Event {
+	var event Event
+	switch name {
+	case "status":
+		event = &StatusEvent{}
+	case "search_results":
+		event = &ResultsEvent{}
+	case "instant_answer":
+		event = &InstantAnswerEvent{}
+	case "structured_answer":
+		event = &StructuredAnswerEvent{}
+	case "knowledge_panel":
+		event = &KnowledgePanelEvent{}
+	case "token":
+		event = &TokenEvent{}
+	case "summary_sanitized":
+		event = &SanitizedEvent{}
+	case "summary":
+		event = &SummaryEvent{}
+	case "budget_exceeded":
+		event = &BudgetEvent{}
+	case "complete":
+		event = &CompleteEvent{}
+	case "cancelled":
+		event = &CancelledEvent{}
+	case "server_shutting_down":
+		var raw struct {
+			Message  string `json:"message"`
+			Deadline int64  `json:"deadline"`
+		}
+		json.Unmarshal(data, &raw)
+		shutdown := &ShutdownEvent{Message: raw.Message}
+		if raw.Deadline > 0 {
+			shutdown.Deadline = time.Unix(raw.Deadline, 0)
+		}
+		return shutdown
+	case "error":
+		var raw struct {
+			Message    string `json:"message"`
+			RequestID  string `json:"request_id"`
+			RetryAfter int    `json:"retry_after"`
+		}
+		json.Unmarshal(data, &raw)
+		return &ErrorEvent{Message: raw.Message, RequestID: raw.RequestID, RetryAfter: time.Duration(raw.RetryAfter) * time.Second}
+	default:
+		return &UnknownEvent{Event: name, Data: append(json.RawMessage(nil), data...)}
+	}
+	if err := json.Unmarshal(data, event); err != nil {
+		return &UnknownEvent{Event: name, Data: append(json.RawMessage(nil), data...)}
+	}
+	return event
+}
@@ -0,0 +1,179 @@
+package client
+
+import "time"
+
+// SearchRequest is a search to run. Sampling and Deterministic only apply
+// to Search; the streaming endpoint does not take them.
+type SearchRequest struct {
+	Query      string `json:"query"`
+	SafeSearch bool   `json:"safe_search"`
+	NumResults int    `json:"num_results,omitempty"` // 0 for the tenant's default
+	// Locale is the BCP 47 language and country to search in, e.g. "de-CH"
+	Locale        string          `json:"locale,omitempty"`
+	Sampling      *SamplingParams `json:"sampling,omitempty"`
+	Deterministic bool            `json:"deterministic,omitempty"`
+}
+
+// SamplingParams tune the summary's generation; nil fields keep the
+// gateway's defaults
+type SamplingParams struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+}
+
+// SearchResponse is a completed search
+type SearchResponse struct {
+	Query           string            `json:"query"`
+	Status          string            `json:"status"`
+	Results         []Result          `json:"search_results,omitempty"`
+	Summary         string            `json:"summary,omitempty"`
+	TaskID          string            `json:"task_id,omitempty"` // exports the search while it is kept
+	Error           string            `json:"error,omitempty"`
+	Budget          *Budget           `json:"budget,omitempty"`
+	Confidence      *Confidence       `json:"confidence,omitempty"`
+	Answer          *Answer           `json:"answer,omitempty"`
+	InstantAnswer   *InstantAnswer    `json:"instant_answer,omitempty"`
+	Structured      *StructuredAnswer `json:"structured_answer,omitempty"`
+	KnowledgePanel  *KnowledgePanel   `json:"knowledge_panel,omitempty"`
+	RelatedSearches []string          `json:"related_searches,omitempty"`
+	Safety          *SafetyReport     `json:"safety,omitempty"`
+}
+
+// Result is one search result
+type Result struct {
+	Title          string  `json:"title"`
+	URL            string  `json:"url"`
+	Snippet        string  `json:"snippet"`
+	DisplayURL     string  `json:"display_url"`
+	Source         string  `json:"source,omitempty"`   // the provider that returned it
+	Internal       bool    `json:"internal,omitempty"` // from the internal corpus rather than the web
+	Score          float64 `json:"score,omitempty"`
+	ImageURL       string  `json:"image_url,omitempty"`
+	Paywalled      bool    `json:"paywalled,omitempty"`       // only the snippet is known
+	RelevanceScore float64 `json:"relevance_score,omitempty"` // graded by the reranking model, 0..1
+}
+
+// Budget reports the limits a search ran into and what they cost it
+type Budget struct {
+	Outcome string   `json:"outcome"`
+	Limits  []string `json:"limits"`
+	Skipped []string `json:"skipped"`
+}
+
+// SafetyReport is the toxicity the safety filter scored the summary with,
+// by category from 0 to 1, and what it did: pass, annotate, rewrite or block
+type SafetyReport struct {
+	Action   string             `json:"action"`
+	Toxicity map[string]float64 `json:"toxicity,omitempty"`
+}
+
+// Confidence rates how well the results support the summary
+type Confidence struct {
+	Level            string  `json:"level"` // high, medium or low
+	Score            float64 `json:"score"`
+	Sources          int     `json:"sources"`
+	Words            int     `json:"words"`
+	ModelProbability float64 `json:"model_probability,omitempty"`
+	Groundedness     float64 `json:"groundedness"`
+	Agreement        float64 `json:"agreement"`
+}
+
+// Answer is the detected query type and the format of the summary
+type Answer struct {
+	QueryType string `json:"query_type"`
+	Format    string `json:"format"`
+}
+
+// InstantAnswer answers calculations, conversions and definitions without
+// a search
+type InstantAnswer struct {
+	Kind        string       `json:"kind"`
+	Input       string       `json:"input"`
+	Result      string       `json:"result"`
+	Value       *float64     `json:"value,omitempty"`
+	Unit        string       `json:"unit,omitempty"`
+	Definitions []Definition `json:"definitions,omitempty"`
+	Source      string       `json:"source,omitempty"`
+}
+
+// Definition is one sense of a defined word
+type Definition struct {
+	PartOfSpeech string `json:"part_of_speech,omitempty"`
+	Text         string `json:"definition"`
+	Example      string `json:"example,omitempty"`
+}
+
+// StructuredAnswer is an answer provider's data: weather, stocks or sports
+type StructuredAnswer struct {
+	Kind     string `json:"kind"`
+	Provider string `json:"provider"`
+	Title    string `json:"title"`
+	Headline string `json:"headline"`
+	Facts    []Fact `json:"facts,omitempty"`
+	AsOf     string `json:"as_of,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// Fact is one labelled value of a structured answer
+type Fact struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// KnowledgePanel is the entity a query is about
+type KnowledgePanel struct {
+	Name        string           `json:"name"`
+	Type        string           `json:"type"`
+	Description string           `json:"description,omitempty"`
+	Attributes  []PanelAttribute `json:"attributes,omitempty"`
+	ImageURL    string           `json:"image_url,omitempty"`
+	SourceURL   string           `json:"source_url,omitempty"`
+}
+
+// PanelAttribute is a key fact about a knowledge panel's entity
+type PanelAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Validation is the safety service's verdict on a text
+type Validation struct {
+	IsSafe        bool     `json:"is_safe"`
+	SanitizedText string   `json:"sanitized_text"`
+	Warnings      []string `json:"warnings"`
+}
+
+// FieldError is a parameter or body field the gateway rejected, e.g.
+// Field "sampling.temperature", In "body", Message "must be between 0 and 2"
+type FieldError struct {
+	Field   string `json:"field,omitempty"` // empty for the body as a whole
+	In      string `json:"in"`              // query, path or body
+	Message string `json:"message"`
+}
+
+// Usage is the calling tenant's quota usage
+type Usage struct {
+	Tenant string       `json:"tenant"`
+	Quotas []QuotaUsage `json:"quotas"` // empty without quotas
+}
+
+// QuotaUsage is how much of one quota is used
+type QuotaUsage struct {
+	Window    string    `json:"window"` // minute or day
+	Limit     int       `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// HistoryEntry is one of the logged-in user's searches
+type HistoryEntry struct {
+	TaskID     string    `json:"task_id,omitempty"`
+	Query      string    `json:"query"`
+	Results    int       `json:"results"`
+	SearchedAt time.Time `json:"searched_at"`
+}
@@ -63,6 +63,7 @@ type HealthCheckResponse struct {
 	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
 	Service       string                 `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
 	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Details       map[string]string      `protobuf:"bytes,4,rep,name=details,proto3" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // service-specific status, e.g. model warm-up progress
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -118,12 +119,21 @@ func (x *HealthCheckResponse) GetTimestamp() int64 {
 	return 0
 }
 
+func (x *HealthCheckResponse) GetDetails() map[string]string {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
 // Search messages
 type SearchRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
 	SafeSearch    bool                   `protobuf:"varint,2,opt,name=safe_search,json=safeSearch,proto3" json:"safe_search,omitempty"`
 	NumResults    int32                  `protobuf:"varint,3,opt,name=num_results,json=numResults,proto3" json:"num_results,omitempty"`
+	Sources       []string               `protobuf:"bytes,4,rep,name=sources,proto3" json:"sources,omitempty"` // providers to query ("web" or a provider name); empty queries all
+	Locale        string                 `protobuf:"bytes,5,opt,name=locale,proto3" json:"locale,omitempty"`   // BCP 47 language and country, e.g. "de-CH"; empty for the provider's default
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -179,6 +189,20 @@ func (x *SearchRequest) GetNumResults() int32 {
 	return 0
 }
 
+func (x *SearchRequest) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
 type SearchResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Results       []*SearchResult        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
@@ -253,6 +277,11 @@ type SearchResult struct {
 	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
 	Snippet       string                 `protobuf:"bytes,3,opt,name=snippet,proto3" json:"snippet,omitempty"`
 	DisplayUrl    string                 `protobuf:"bytes,4,opt,name=display_url,json=displayUrl,proto3" json:"display_url,omitempty"`
+	Source        string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`                     // provider that returned it, e.g. "google", "elasticsearch" or "qdrant"
+	Internal      bool                   `protobuf:"varint,6,opt,name=internal,proto3" json:"internal,omitempty"`                // from an internal knowledge source rather than the web
+	Score         float64                `protobuf:"fixed64,7,opt,name=score,proto3" json:"score,omitempty"`                     // relevance: the provider's raw score, normalized to 0..1 once fused
+	ImageUrl      string                 `protobuf:"bytes,8,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"` // thumbnail of the page, when the provider has one
+	Paywalled     bool                   `protobuf:"varint,9,opt,name=paywalled,proto3" json:"paywalled,omitempty"`              // behind a paywall or login wall: only the snippet, a preview, is usable
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -315,6 +344,260 @@ func (x *SearchResult) GetDisplayUrl() string {
 	return ""
 }
 
+func (x *SearchResult) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SearchResult) GetInternal() bool {
+	if x != nil {
+		return x.Internal
+	}
+	return false
+}
+
+func (x *SearchResult) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SearchResult) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+func (x *SearchResult) GetPaywalled() bool {
+	if x != nil {
+		return x.Paywalled
+	}
+	return false
+}
+
+// Query completions for a typed prefix
+type SuggestRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	MaxResults    int32                  `protobuf:"varint,2,opt,name=max_results,json=maxResults,proto3" json:"max_results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SuggestRequest) Reset() {
+	*x = SuggestRequest{}
+	mi := &file_proto_search_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SuggestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SuggestRequest) ProtoMessage() {}
+
+func (x *SuggestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SuggestRequest.ProtoReflect.Descriptor instead.
+func (*SuggestRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SuggestRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SuggestRequest) GetMaxResults() int32 {
+	if x != nil {
+		return x.MaxResults
+	}
+	return 0
+}
+
+type SuggestResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Suggestions   []string               `protobuf:"bytes,1,rep,name=suggestions,proto3" json:"suggestions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SuggestResponse) Reset() {
+	*x = SuggestResponse{}
+	mi := &file_proto_search_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SuggestResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SuggestResponse) ProtoMessage() {}
+
+func (x *SuggestResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SuggestResponse.ProtoReflect.Descriptor instead.
+func (*SuggestResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SuggestResponse) GetSuggestions() []string {
+	if x != nil {
+		return x.Suggestions
+	}
+	return nil
+}
+
+// Document ingestion: the text is chunked, embedded and upserted into the
+// configured vector store, skipping content that is already stored
+type IngestDocumentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Text          string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"` // plain text, already extracted from HTML or PDF
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestDocumentRequest) Reset() {
+	*x = IngestDocumentRequest{}
+	mi := &file_proto_search_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestDocumentRequest) ProtoMessage() {}
+
+func (x *IngestDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestDocumentRequest.ProtoReflect.Descriptor instead.
+func (*IngestDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *IngestDocumentRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *IngestDocumentRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *IngestDocumentRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type IngestDocumentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ContentHash   string                 `protobuf:"bytes,1,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"` // sha256 of the whitespace-normalized text
+	Chunks        int32                  `protobuf:"varint,2,opt,name=chunks,proto3" json:"chunks,omitempty"`                             // chunks stored; 0 for duplicates
+	Duplicate     bool                   `protobuf:"varint,3,opt,name=duplicate,proto3" json:"duplicate,omitempty"`                       // the store already held this content
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestDocumentResponse) Reset() {
+	*x = IngestDocumentResponse{}
+	mi := &file_proto_search_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestDocumentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestDocumentResponse) ProtoMessage() {}
+
+func (x *IngestDocumentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestDocumentResponse.ProtoReflect.Descriptor instead.
+func (*IngestDocumentResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *IngestDocumentResponse) GetContentHash() string {
+	if x != nil {
+		return x.ContentHash
+	}
+	return ""
+}
+
+func (x *IngestDocumentResponse) GetChunks() int32 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
+func (x *IngestDocumentResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
 // Enterprise Tokenizer messages
 type TokenizeRequest struct {
 	state                protoimpl.MessageState `protogen:"open.v1"`
@@ -329,7 +612,7 @@ type TokenizeRequest struct {
 
 func (x *TokenizeRequest) Reset() {
 	*x = TokenizeRequest{}
-	mi := &file_proto_search_proto_msgTypes[5]
+	mi := &file_proto_search_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -341,7 +624,7 @@ func (x *TokenizeRequest) String() string {
 func (*TokenizeRequest) ProtoMessage() {}
 
 func (x *TokenizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[5]
+	mi := &file_proto_search_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -354,7 +637,7 @@ func (x *TokenizeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TokenizeRequest.ProtoReflect.Descriptor instead.
 func (*TokenizeRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{5}
+	return file_proto_search_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *TokenizeRequest) GetText() string {
@@ -393,24 +676,27 @@ func (x *TokenizeRequest) GetRequestId() string {
 }
 
 type TokenizeResponse struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	TokenIds         []int32                `protobuf:"varint,1,rep,packed,name=token_ids,json=tokenIds,proto3" json:"token_ids,omitempty"`
-	TokenStrings     []string               `protobuf:"bytes,2,rep,name=token_strings,json=tokenStrings,proto3" json:"token_strings,omitempty"` // human-readable tokens
-	TokenCount       int32                  `protobuf:"varint,3,opt,name=token_count,json=tokenCount,proto3" json:"token_count,omitempty"`
-	TruncatedText    string                 `protobuf:"bytes,4,opt,name=truncated_text,json=truncatedText,proto3" json:"truncated_text,omitempty"` // if truncation occurred
-	WasTruncated     bool                   `protobuf:"varint,5,opt,name=was_truncated,json=wasTruncated,proto3" json:"was_truncated,omitempty"`
-	ModelUsed        string                 `protobuf:"bytes,6,opt,name=model_used,json=modelUsed,proto3" json:"model_used,omitempty"`
-	ProcessingTimeMs float32                `protobuf:"fixed32,7,opt,name=processing_time_ms,json=processingTimeMs,proto3" json:"processing_time_ms,omitempty"` // performance metrics
-	CacheStatus      string                 `protobuf:"bytes,8,opt,name=cache_status,json=cacheStatus,proto3" json:"cache_status,omitempty"`                    // "hit", "miss", "bypassed"
-	Success          bool                   `protobuf:"varint,9,opt,name=success,proto3" json:"success,omitempty"`
-	Error            string                 `protobuf:"bytes,10,opt,name=error,proto3" json:"error,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	TokenIds           []int32                `protobuf:"varint,1,rep,packed,name=token_ids,json=tokenIds,proto3" json:"token_ids,omitempty"`
+	TokenStrings       []string               `protobuf:"bytes,2,rep,name=token_strings,json=tokenStrings,proto3" json:"token_strings,omitempty"` // human-readable tokens
+	TokenCount         int32                  `protobuf:"varint,3,opt,name=token_count,json=tokenCount,proto3" json:"token_count,omitempty"`
+	TruncatedText      string                 `protobuf:"bytes,4,opt,name=truncated_text,json=truncatedText,proto3" json:"truncated_text,omitempty"` // if truncation occurred
+	WasTruncated       bool                   `protobuf:"varint,5,opt,name=was_truncated,json=wasTruncated,proto3" json:"was_truncated,omitempty"`
+	ModelUsed          string                 `protobuf:"bytes,6,opt,name=model_used,json=modelUsed,proto3" json:"model_used,omitempty"`
+	ProcessingTimeMs   float32                `protobuf:"fixed32,7,opt,name=processing_time_ms,json=processingTimeMs,proto3" json:"processing_time_ms,omitempty"` // performance metrics
+	CacheStatus        string                 `protobuf:"bytes,8,opt,name=cache_status,json=cacheStatus,proto3" json:"cache_status,omitempty"`                    // "hit", "miss", "bypassed"
+	Success            bool                   `protobuf:"varint,9,opt,name=success,proto3" json:"success,omitempty"`
+	Error              string                 `protobuf:"bytes,10,opt,name=error,proto3" json:"error,omitempty"`
+	OriginalTokenCount int32                  `protobuf:"varint,11,opt,name=original_token_count,json=originalTokenCount,proto3" json:"original_token_count,omitempty"` // before truncation
+	ContextWindow      int32                  `protobuf:"varint,12,opt,name=context_window,json=contextWindow,proto3" json:"context_window,omitempty"`                  // the most tokens the model takes, which the text was truncated to; 0 when unknown
+	TokenizerVersion   string                 `protobuf:"bytes,13,opt,name=tokenizer_version,json=tokenizerVersion,proto3" json:"tokenizer_version,omitempty"`          // fingerprint of the model's vocabulary; the token IDs mean the same only to a tokenizer with the same one
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *TokenizeResponse) Reset() {
 	*x = TokenizeResponse{}
-	mi := &file_proto_search_proto_msgTypes[6]
+	mi := &file_proto_search_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -422,7 +708,7 @@ func (x *TokenizeResponse) String() string {
 func (*TokenizeResponse) ProtoMessage() {}
 
 func (x *TokenizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[6]
+	mi := &file_proto_search_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -435,7 +721,7 @@ func (x *TokenizeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TokenizeResponse.ProtoReflect.Descriptor instead.
 func (*TokenizeResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{6}
+	return file_proto_search_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *TokenizeResponse) GetTokenIds() []int32 {
@@ -508,6 +794,27 @@ func (x *TokenizeResponse) GetError() string {
 	return ""
 }
 
+func (x *TokenizeResponse) GetOriginalTokenCount() int32 {
+	if x != nil {
+		return x.OriginalTokenCount
+	}
+	return 0
+}
+
+func (x *TokenizeResponse) GetContextWindow() int32 {
+	if x != nil {
+		return x.ContextWindow
+	}
+	return 0
+}
+
+func (x *TokenizeResponse) GetTokenizerVersion() string {
+	if x != nil {
+		return x.TokenizerVersion
+	}
+	return ""
+}
+
 type BatchTokenizeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Requests      []*TokenizeRequest     `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
@@ -518,7 +825,7 @@ type BatchTokenizeRequest struct {
 
 func (x *BatchTokenizeRequest) Reset() {
 	*x = BatchTokenizeRequest{}
-	mi := &file_proto_search_proto_msgTypes[7]
+	mi := &file_proto_search_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -530,7 +837,7 @@ func (x *BatchTokenizeRequest) String() string {
 func (*BatchTokenizeRequest) ProtoMessage() {}
 
 func (x *BatchTokenizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[7]
+	mi := &file_proto_search_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -543,7 +850,7 @@ func (x *BatchTokenizeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BatchTokenizeRequest.ProtoReflect.Descriptor instead.
 func (*BatchTokenizeRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{7}
+	return file_proto_search_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *BatchTokenizeRequest) GetRequests() []*TokenizeRequest {
@@ -572,7 +879,7 @@ type BatchTokenizeResponse struct {
 
 func (x *BatchTokenizeResponse) Reset() {
 	*x = BatchTokenizeResponse{}
-	mi := &file_proto_search_proto_msgTypes[8]
+	mi := &file_proto_search_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -584,7 +891,7 @@ func (x *BatchTokenizeResponse) String() string {
 func (*BatchTokenizeResponse) ProtoMessage() {}
 
 func (x *BatchTokenizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[8]
+	mi := &file_proto_search_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -597,7 +904,7 @@ func (x *BatchTokenizeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BatchTokenizeResponse.ProtoReflect.Descriptor instead.
 func (*BatchTokenizeResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{8}
+	return file_proto_search_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *BatchTokenizeResponse) GetResponses() []*TokenizeResponse {
@@ -637,7 +944,7 @@ type VocabularyInfoRequest struct {
 
 func (x *VocabularyInfoRequest) Reset() {
 	*x = VocabularyInfoRequest{}
-	mi := &file_proto_search_proto_msgTypes[9]
+	mi := &file_proto_search_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -649,7 +956,7 @@ func (x *VocabularyInfoRequest) String() string {
 func (*VocabularyInfoRequest) ProtoMessage() {}
 
 func (x *VocabularyInfoRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[9]
+	mi := &file_proto_search_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -662,7 +969,7 @@ func (x *VocabularyInfoRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VocabularyInfoRequest.ProtoReflect.Descriptor instead.
 func (*VocabularyInfoRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{9}
+	return file_proto_search_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *VocabularyInfoRequest) GetModelName() string {
@@ -673,18 +980,19 @@ func (x *VocabularyInfoRequest) GetModelName() string {
 }
 
 type VocabularyInfoResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	VocabSize     int32                  `protobuf:"varint,1,opt,name=vocab_size,json=vocabSize,proto3" json:"vocab_size,omitempty"`
-	SpecialTokens []string               `protobuf:"bytes,2,rep,name=special_tokens,json=specialTokens,proto3" json:"special_tokens,omitempty"`
-	EncodingName  string                 `protobuf:"bytes,3,opt,name=encoding_name,json=encodingName,proto3" json:"encoding_name,omitempty"` // e.g., "cl100k_base"
-	ModelName     string                 `protobuf:"bytes,4,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	VocabSize        int32                  `protobuf:"varint,1,opt,name=vocab_size,json=vocabSize,proto3" json:"vocab_size,omitempty"`
+	SpecialTokens    []string               `protobuf:"bytes,2,rep,name=special_tokens,json=specialTokens,proto3" json:"special_tokens,omitempty"`
+	EncodingName     string                 `protobuf:"bytes,3,opt,name=encoding_name,json=encodingName,proto3" json:"encoding_name,omitempty"` // e.g., "cl100k_base"
+	ModelName        string                 `protobuf:"bytes,4,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	TokenizerVersion string                 `protobuf:"bytes,5,opt,name=tokenizer_version,json=tokenizerVersion,proto3" json:"tokenizer_version,omitempty"` // as in TokenizeResponse, to pin inference.tokenizer_pins to
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
 
 func (x *VocabularyInfoResponse) Reset() {
 	*x = VocabularyInfoResponse{}
-	mi := &file_proto_search_proto_msgTypes[10]
+	mi := &file_proto_search_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -696,7 +1004,7 @@ func (x *VocabularyInfoResponse) String() string {
 func (*VocabularyInfoResponse) ProtoMessage() {}
 
 func (x *VocabularyInfoResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[10]
+	mi := &file_proto_search_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -709,7 +1017,7 @@ func (x *VocabularyInfoResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VocabularyInfoResponse.ProtoReflect.Descriptor instead.
 func (*VocabularyInfoResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{10}
+	return file_proto_search_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *VocabularyInfoResponse) GetVocabSize() int32 {
@@ -740,6 +1048,13 @@ func (x *VocabularyInfoResponse) GetModelName() string {
 	return ""
 }
 
+func (x *VocabularyInfoResponse) GetTokenizerVersion() string {
+	if x != nil {
+		return x.TokenizerVersion
+	}
+	return ""
+}
+
 // Detokenization messages (industry standard: token IDs -> text)
 type DetokenizeRequest struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
@@ -753,7 +1068,7 @@ type DetokenizeRequest struct {
 
 func (x *DetokenizeRequest) Reset() {
 	*x = DetokenizeRequest{}
-	mi := &file_proto_search_proto_msgTypes[11]
+	mi := &file_proto_search_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -765,7 +1080,7 @@ func (x *DetokenizeRequest) String() string {
 func (*DetokenizeRequest) ProtoMessage() {}
 
 func (x *DetokenizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[11]
+	mi := &file_proto_search_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -778,7 +1093,7 @@ func (x *DetokenizeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DetokenizeRequest.ProtoReflect.Descriptor instead.
 func (*DetokenizeRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{11}
+	return file_proto_search_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *DetokenizeRequest) GetTokenIds() []int32 {
@@ -824,7 +1139,7 @@ type DetokenizeResponse struct {
 
 func (x *DetokenizeResponse) Reset() {
 	*x = DetokenizeResponse{}
-	mi := &file_proto_search_proto_msgTypes[12]
+	mi := &file_proto_search_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -836,7 +1151,7 @@ func (x *DetokenizeResponse) String() string {
 func (*DetokenizeResponse) ProtoMessage() {}
 
 func (x *DetokenizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[12]
+	mi := &file_proto_search_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -849,7 +1164,7 @@ func (x *DetokenizeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DetokenizeResponse.ProtoReflect.Descriptor instead.
 func (*DetokenizeResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{12}
+	return file_proto_search_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *DetokenizeResponse) GetText() string {
@@ -911,7 +1226,7 @@ type BatchDetokenizeRequest struct {
 
 func (x *BatchDetokenizeRequest) Reset() {
 	*x = BatchDetokenizeRequest{}
-	mi := &file_proto_search_proto_msgTypes[13]
+	mi := &file_proto_search_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -923,7 +1238,7 @@ func (x *BatchDetokenizeRequest) String() string {
 func (*BatchDetokenizeRequest) ProtoMessage() {}
 
 func (x *BatchDetokenizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[13]
+	mi := &file_proto_search_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -936,7 +1251,7 @@ func (x *BatchDetokenizeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BatchDetokenizeRequest.ProtoReflect.Descriptor instead.
 func (*BatchDetokenizeRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{13}
+	return file_proto_search_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *BatchDetokenizeRequest) GetRequests() []*DetokenizeRequest {
@@ -965,7 +1280,7 @@ type BatchDetokenizeResponse struct {
 
 func (x *BatchDetokenizeResponse) Reset() {
 	*x = BatchDetokenizeResponse{}
-	mi := &file_proto_search_proto_msgTypes[14]
+	mi := &file_proto_search_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -977,7 +1292,7 @@ func (x *BatchDetokenizeResponse) String() string {
 func (*BatchDetokenizeResponse) ProtoMessage() {}
 
 func (x *BatchDetokenizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[14]
+	mi := &file_proto_search_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -990,7 +1305,7 @@ func (x *BatchDetokenizeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BatchDetokenizeResponse.ProtoReflect.Descriptor instead.
 func (*BatchDetokenizeResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{14}
+	return file_proto_search_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *BatchDetokenizeResponse) GetResponses() []*DetokenizeResponse {
@@ -1021,34 +1336,35 @@ func (x *BatchDetokenizeResponse) GetCacheMisses() int32 {
 	return 0
 }
 
-// Enhanced Inference messages (Industry Standard)
-type SummarizeRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	TokenIds      []int32                `protobuf:"varint,1,rep,packed,name=token_ids,json=tokenIds,proto3" json:"token_ids,omitempty"` // PRIMARY: from tokenizer service
-	ModelName     string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`      // which model/tokenizer was used
-	Streaming     bool                   `protobuf:"varint,3,opt,name=streaming,proto3" json:"streaming,omitempty"`
-	MaxLength     int32                  `protobuf:"varint,4,opt,name=max_length,json=maxLength,proto3" json:"max_length,omitempty"`
-	RequestId     string                 `protobuf:"bytes,5,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`          // for correlation
-	OriginalText  string                 `protobuf:"bytes,6,opt,name=original_text,json=originalText,proto3" json:"original_text,omitempty"` // FALLBACK ONLY: for non-tokenized requests
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+// Streaming detokenization messages: the tokenizer keeps the decode state for the
+// lifetime of the stream and only emits complete, correctly merged text, holding
+// back BPE pieces and partial UTF-8 sequences until they resolve
+type DecodeStreamRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	TokenIds          []int32                `protobuf:"varint,1,rep,packed,name=token_ids,json=tokenIds,proto3" json:"token_ids,omitempty"`                       // newly generated tokens, in order
+	ModelName         string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`                            // read from the first message
+	SkipSpecialTokens bool                   `protobuf:"varint,3,opt,name=skip_special_tokens,json=skipSpecialTokens,proto3" json:"skip_special_tokens,omitempty"` // read from the first message
+	RequestId         string                 `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`                            // for tracking
+	IsFinal           bool                   `protobuf:"varint,5,opt,name=is_final,json=isFinal,proto3" json:"is_final,omitempty"`                                 // flush any held-back text
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
-func (x *SummarizeRequest) Reset() {
-	*x = SummarizeRequest{}
-	mi := &file_proto_search_proto_msgTypes[15]
+func (x *DecodeStreamRequest) Reset() {
+	*x = DecodeStreamRequest{}
+	mi := &file_proto_search_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SummarizeRequest) String() string {
+func (x *DecodeStreamRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SummarizeRequest) ProtoMessage() {}
+func (*DecodeStreamRequest) ProtoMessage() {}
 
-func (x *SummarizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[15]
+func (x *DecodeStreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1059,80 +1375,71 @@ func (x *SummarizeRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SummarizeRequest.ProtoReflect.Descriptor instead.
-func (*SummarizeRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{15}
+// Deprecated: Use DecodeStreamRequest.ProtoReflect.Descriptor instead.
+func (*DecodeStreamRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *SummarizeRequest) GetTokenIds() []int32 {
+func (x *DecodeStreamRequest) GetTokenIds() []int32 {
 	if x != nil {
 		return x.TokenIds
 	}
 	return nil
 }
 
-func (x *SummarizeRequest) GetModelName() string {
+func (x *DecodeStreamRequest) GetModelName() string {
 	if x != nil {
 		return x.ModelName
 	}
 	return ""
 }
 
-func (x *SummarizeRequest) GetStreaming() bool {
+func (x *DecodeStreamRequest) GetSkipSpecialTokens() bool {
 	if x != nil {
-		return x.Streaming
+		return x.SkipSpecialTokens
 	}
 	return false
 }
 
-func (x *SummarizeRequest) GetMaxLength() int32 {
-	if x != nil {
-		return x.MaxLength
-	}
-	return 0
-}
-
-func (x *SummarizeRequest) GetRequestId() string {
+func (x *DecodeStreamRequest) GetRequestId() string {
 	if x != nil {
 		return x.RequestId
 	}
 	return ""
 }
 
-func (x *SummarizeRequest) GetOriginalText() string {
+func (x *DecodeStreamRequest) GetIsFinal() bool {
 	if x != nil {
-		return x.OriginalText
+		return x.IsFinal
 	}
-	return ""
+	return false
 }
 
-type SummarizeResponse struct {
-	state             protoimpl.MessageState `protogen:"open.v1"`
-	Summary           string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
-	Success           bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
-	Error             string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
-	TokensUsed        int32                  `protobuf:"varint,4,opt,name=tokens_used,json=tokensUsed,proto3" json:"tokens_used,omitempty"`
-	Confidence        float32                `protobuf:"fixed32,5,opt,name=confidence,proto3" json:"confidence,omitempty"`
-	GeneratedTokenIds []int32                `protobuf:"varint,6,rep,packed,name=generated_token_ids,json=generatedTokenIds,proto3" json:"generated_token_ids,omitempty"` // TOKEN-NATIVE: Generated tokens for detokenization
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+type DecodeStreamResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`                                // complete text fragment; empty while waiting for more bytes
+	TokenCount    int32                  `protobuf:"varint,2,opt,name=token_count,json=tokenCount,proto3" json:"token_count,omitempty"` // tokens consumed so far on this stream
+	Success       bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SummarizeResponse) Reset() {
-	*x = SummarizeResponse{}
-	mi := &file_proto_search_proto_msgTypes[16]
+func (x *DecodeStreamResponse) Reset() {
+	*x = DecodeStreamResponse{}
+	mi := &file_proto_search_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SummarizeResponse) String() string {
+func (x *DecodeStreamResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SummarizeResponse) ProtoMessage() {}
+func (*DecodeStreamResponse) ProtoMessage() {}
 
-func (x *SummarizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[16]
+func (x *DecodeStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1143,79 +1450,71 @@ func (x *SummarizeResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SummarizeResponse.ProtoReflect.Descriptor instead.
-func (*SummarizeResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{16}
-}
-
-func (x *SummarizeResponse) GetSummary() string {
-	if x != nil {
-		return x.Summary
-	}
-	return ""
-}
-
-func (x *SummarizeResponse) GetSuccess() bool {
-	if x != nil {
-		return x.Success
-	}
-	return false
+// Deprecated: Use DecodeStreamResponse.ProtoReflect.Descriptor instead.
+func (*DecodeStreamResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *SummarizeResponse) GetError() string {
+func (x *DecodeStreamResponse) GetText() string {
 	if x != nil {
-		return x.Error
+		return x.Text
 	}
 	return ""
 }
 
-func (x *SummarizeResponse) GetTokensUsed() int32 {
+func (x *DecodeStreamResponse) GetTokenCount() int32 {
 	if x != nil {
-		return x.TokensUsed
+		return x.TokenCount
 	}
 	return 0
 }
 
-func (x *SummarizeResponse) GetConfidence() float32 {
+func (x *DecodeStreamResponse) GetSuccess() bool {
 	if x != nil {
-		return x.Confidence
+		return x.Success
 	}
-	return 0
+	return false
 }
 
-func (x *SummarizeResponse) GetGeneratedTokenIds() []int32 {
+func (x *DecodeStreamResponse) GetError() string {
 	if x != nil {
-		return x.GeneratedTokenIds
+		return x.Error
 	}
-	return nil
+	return ""
 }
 
-type SummarizeStreamResponse struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	Token            string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
-	IsFinal          bool                   `protobuf:"varint,2,opt,name=is_final,json=isFinal,proto3" json:"is_final,omitempty"`
-	Error            string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
-	Position         int32                  `protobuf:"varint,4,opt,name=position,proto3" json:"position,omitempty"`
-	GeneratedTokenId int32                  `protobuf:"varint,5,opt,name=generated_token_id,json=generatedTokenId,proto3" json:"generated_token_id,omitempty"` // TOKEN-NATIVE: Token ID for streaming detokenization
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+// Prompt assembly: the tokenizer renders a prompt template from its variables
+// and tokenizes it the way the model family expects, adding BOS/EOS or chat
+// roles itself, so callers never concatenate special tokens into text
+type BuildPromptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TemplateId    string                 `protobuf:"bytes,1,opt,name=template_id,json=templateId,proto3" json:"template_id,omitempty"`                                                       // e.g. "summarize", "summarize_continue"
+	Variables     map[string]string      `protobuf:"bytes,2,rep,name=variables,proto3" json:"variables,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // e.g. instruction, results, summary
+	ModelName     string                 `protobuf:"bytes,3,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	MaxTokens     int32                  `protobuf:"varint,4,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`         // truncation limit; the template's truncatable variable is cut, not the tail
+	RequestId     string                 `protobuf:"bytes,5,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`          // for tracking/caching
+	ChatTemplate  string                 `protobuf:"bytes,6,opt,name=chat_template,json=chatTemplate,proto3" json:"chat_template,omitempty"` // Jinja chat template of an instruction-tuned model; empty for the family's default formatting
+	BosToken      string                 `protobuf:"bytes,7,opt,name=bos_token,json=bosToken,proto3" json:"bos_token,omitempty"`             // bos_token and eos_token of chat_template; empty for the tokenizer's
+	EosToken      string                 `protobuf:"bytes,8,opt,name=eos_token,json=eosToken,proto3" json:"eos_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SummarizeStreamResponse) Reset() {
-	*x = SummarizeStreamResponse{}
-	mi := &file_proto_search_proto_msgTypes[17]
+func (x *BuildPromptRequest) Reset() {
+	*x = BuildPromptRequest{}
+	mi := &file_proto_search_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SummarizeStreamResponse) String() string {
+func (x *BuildPromptRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SummarizeStreamResponse) ProtoMessage() {}
+func (*BuildPromptRequest) ProtoMessage() {}
 
-func (x *SummarizeStreamResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[17]
+func (x *BuildPromptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1226,71 +1525,91 @@ func (x *SummarizeStreamResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SummarizeStreamResponse.ProtoReflect.Descriptor instead.
-func (*SummarizeStreamResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{17}
+// Deprecated: Use BuildPromptRequest.ProtoReflect.Descriptor instead.
+func (*BuildPromptRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{21}
 }
 
-func (x *SummarizeStreamResponse) GetToken() string {
+func (x *BuildPromptRequest) GetTemplateId() string {
 	if x != nil {
-		return x.Token
+		return x.TemplateId
 	}
 	return ""
 }
 
-func (x *SummarizeStreamResponse) GetIsFinal() bool {
+func (x *BuildPromptRequest) GetVariables() map[string]string {
 	if x != nil {
-		return x.IsFinal
+		return x.Variables
 	}
-	return false
+	return nil
 }
 
-func (x *SummarizeStreamResponse) GetError() string {
+func (x *BuildPromptRequest) GetModelName() string {
 	if x != nil {
-		return x.Error
+		return x.ModelName
 	}
 	return ""
 }
 
-func (x *SummarizeStreamResponse) GetPosition() int32 {
+func (x *BuildPromptRequest) GetMaxTokens() int32 {
 	if x != nil {
-		return x.Position
+		return x.MaxTokens
 	}
 	return 0
 }
 
-func (x *SummarizeStreamResponse) GetGeneratedTokenId() int32 {
+func (x *BuildPromptRequest) GetRequestId() string {
 	if x != nil {
-		return x.GeneratedTokenId
+		return x.RequestId
 	}
-	return 0
+	return ""
 }
 
-// Safety messages
-type ValidateInputRequest struct {
+func (x *BuildPromptRequest) GetChatTemplate() string {
+	if x != nil {
+		return x.ChatTemplate
+	}
+	return ""
+}
+
+func (x *BuildPromptRequest) GetBosToken() string {
+	if x != nil {
+		return x.BosToken
+	}
+	return ""
+}
+
+func (x *BuildPromptRequest) GetEosToken() string {
+	if x != nil {
+		return x.EosToken
+	}
+	return ""
+}
+
+type BuildPromptResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
-	ClientIp      string                 `protobuf:"bytes,2,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
-	SafeSearch    bool                   `protobuf:"varint,3,opt,name=safe_search,json=safeSearch,proto3" json:"safe_search,omitempty"`
+	Tokens        *TokenizeResponse      `protobuf:"bytes,1,opt,name=tokens,proto3" json:"tokens,omitempty"`                              // the assembled prompt, tokenized
+	Prompt        string                 `protobuf:"bytes,2,opt,name=prompt,proto3" json:"prompt,omitempty"`                              // the assembled text, special tokens included, for logging
+	ModelFamily   string                 `protobuf:"bytes,3,opt,name=model_family,json=modelFamily,proto3" json:"model_family,omitempty"` // "seq2seq", "causal" or "chat": how special tokens were added
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ValidateInputRequest) Reset() {
-	*x = ValidateInputRequest{}
-	mi := &file_proto_search_proto_msgTypes[18]
+func (x *BuildPromptResponse) Reset() {
+	*x = BuildPromptResponse{}
+	mi := &file_proto_search_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ValidateInputRequest) String() string {
+func (x *BuildPromptResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ValidateInputRequest) ProtoMessage() {}
+func (*BuildPromptResponse) ProtoMessage() {}
 
-func (x *ValidateInputRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[18]
+func (x *BuildPromptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1301,57 +1620,63 @@ func (x *ValidateInputRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ValidateInputRequest.ProtoReflect.Descriptor instead.
-func (*ValidateInputRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{18}
+// Deprecated: Use BuildPromptResponse.ProtoReflect.Descriptor instead.
+func (*BuildPromptResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *ValidateInputRequest) GetText() string {
+func (x *BuildPromptResponse) GetTokens() *TokenizeResponse {
 	if x != nil {
-		return x.Text
+		return x.Tokens
 	}
-	return ""
+	return nil
 }
 
-func (x *ValidateInputRequest) GetClientIp() string {
+func (x *BuildPromptResponse) GetPrompt() string {
 	if x != nil {
-		return x.ClientIp
+		return x.Prompt
 	}
 	return ""
 }
 
-func (x *ValidateInputRequest) GetSafeSearch() bool {
+func (x *BuildPromptResponse) GetModelFamily() string {
 	if x != nil {
-		return x.SafeSearch
+		return x.ModelFamily
 	}
-	return false
+	return ""
 }
 
-type ValidateInputResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	IsSafe        bool                   `protobuf:"varint,1,opt,name=is_safe,json=isSafe,proto3" json:"is_safe,omitempty"`
-	SanitizedText string                 `protobuf:"bytes,2,opt,name=sanitized_text,json=sanitizedText,proto3" json:"sanitized_text,omitempty"`
-	Warnings      []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+// Enhanced Inference messages (Industry Standard)
+type SummarizeRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	TokenIds         []int32                `protobuf:"varint,1,rep,packed,name=token_ids,json=tokenIds,proto3" json:"token_ids,omitempty"` // PRIMARY: from tokenizer service
+	ModelName        string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`      // which model/tokenizer was used; for text requests the Ollama model (empty for its default)
+	Streaming        bool                   `protobuf:"varint,3,opt,name=streaming,proto3" json:"streaming,omitempty"`
+	MaxLength        int32                  `protobuf:"varint,4,opt,name=max_length,json=maxLength,proto3" json:"max_length,omitempty"`
+	RequestId        string                 `protobuf:"bytes,5,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`                         // for correlation
+	OriginalText     string                 `protobuf:"bytes,6,opt,name=original_text,json=originalText,proto3" json:"original_text,omitempty"`                // FALLBACK ONLY: for non-tokenized requests
+	PrefixTokenCount int32                  `protobuf:"varint,7,opt,name=prefix_token_count,json=prefixTokenCount,proto3" json:"prefix_token_count,omitempty"` // leading token_ids forming the shared instruction prefix (prefix-cacheable)
+	Sampling         *SamplingParams        `protobuf:"bytes,8,opt,name=sampling,proto3" json:"sampling,omitempty"`                                            // unset fields keep the backend's defaults
+	TokenizerVersion string                 `protobuf:"bytes,9,opt,name=tokenizer_version,json=tokenizerVersion,proto3" json:"tokenizer_version,omitempty"`    // of the tokenizer token_ids are from; refused by a model pinned to another, empty skips the check
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
-func (x *ValidateInputResponse) Reset() {
-	*x = ValidateInputResponse{}
-	mi := &file_proto_search_proto_msgTypes[19]
+func (x *SummarizeRequest) Reset() {
+	*x = SummarizeRequest{}
+	mi := &file_proto_search_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ValidateInputResponse) String() string {
+func (x *SummarizeRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ValidateInputResponse) ProtoMessage() {}
+func (*SummarizeRequest) ProtoMessage() {}
 
-func (x *ValidateInputResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[19]
+func (x *SummarizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1362,61 +1687,2753 @@ func (x *ValidateInputResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ValidateInputResponse.ProtoReflect.Descriptor instead.
-func (*ValidateInputResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{19}
+// Deprecated: Use SummarizeRequest.ProtoReflect.Descriptor instead.
+func (*SummarizeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{23}
 }
 
-func (x *ValidateInputResponse) GetIsSafe() bool {
+func (x *SummarizeRequest) GetTokenIds() []int32 {
 	if x != nil {
-		return x.IsSafe
+		return x.TokenIds
+	}
+	return nil
+}
+
+func (x *SummarizeRequest) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+func (x *SummarizeRequest) GetStreaming() bool {
+	if x != nil {
+		return x.Streaming
+	}
+	return false
+}
+
+func (x *SummarizeRequest) GetMaxLength() int32 {
+	if x != nil {
+		return x.MaxLength
+	}
+	return 0
+}
+
+func (x *SummarizeRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SummarizeRequest) GetOriginalText() string {
+	if x != nil {
+		return x.OriginalText
+	}
+	return ""
+}
+
+func (x *SummarizeRequest) GetPrefixTokenCount() int32 {
+	if x != nil {
+		return x.PrefixTokenCount
+	}
+	return 0
+}
+
+func (x *SummarizeRequest) GetSampling() *SamplingParams {
+	if x != nil {
+		return x.Sampling
+	}
+	return nil
+}
+
+func (x *SummarizeRequest) GetTokenizerVersion() string {
+	if x != nil {
+		return x.TokenizerVersion
+	}
+	return ""
+}
+
+// Sampling parameters for generation, passed through to the backend. Unset
+// fields keep the backend's defaults.
+type SamplingParams struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Temperature      *float32               `protobuf:"fixed32,1,opt,name=temperature,proto3,oneof" json:"temperature,omitempty"`
+	TopP             *float32               `protobuf:"fixed32,2,opt,name=top_p,json=topP,proto3,oneof" json:"top_p,omitempty"`
+	FrequencyPenalty *float32               `protobuf:"fixed32,3,opt,name=frequency_penalty,json=frequencyPenalty,proto3,oneof" json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float32               `protobuf:"fixed32,4,opt,name=presence_penalty,json=presencePenalty,proto3,oneof" json:"presence_penalty,omitempty"`
+	Stop             []string               `protobuf:"bytes,5,rep,name=stop,proto3" json:"stop,omitempty"`        // generation ends before any of these
+	Seed             *int64                 `protobuf:"varint,6,opt,name=seed,proto3,oneof" json:"seed,omitempty"` // fixes the random sampling for reproducible outputs
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *SamplingParams) Reset() {
+	*x = SamplingParams{}
+	mi := &file_proto_search_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SamplingParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SamplingParams) ProtoMessage() {}
+
+func (x *SamplingParams) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SamplingParams.ProtoReflect.Descriptor instead.
+func (*SamplingParams) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SamplingParams) GetTemperature() float32 {
+	if x != nil && x.Temperature != nil {
+		return *x.Temperature
+	}
+	return 0
+}
+
+func (x *SamplingParams) GetTopP() float32 {
+	if x != nil && x.TopP != nil {
+		return *x.TopP
+	}
+	return 0
+}
+
+func (x *SamplingParams) GetFrequencyPenalty() float32 {
+	if x != nil && x.FrequencyPenalty != nil {
+		return *x.FrequencyPenalty
+	}
+	return 0
+}
+
+func (x *SamplingParams) GetPresencePenalty() float32 {
+	if x != nil && x.PresencePenalty != nil {
+		return *x.PresencePenalty
+	}
+	return 0
+}
+
+func (x *SamplingParams) GetStop() []string {
+	if x != nil {
+		return x.Stop
+	}
+	return nil
+}
+
+func (x *SamplingParams) GetSeed() int64 {
+	if x != nil && x.Seed != nil {
+		return *x.Seed
+	}
+	return 0
+}
+
+type SummarizeResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Summary           string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	Success           bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Error             string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	TokensUsed        int32                  `protobuf:"varint,4,opt,name=tokens_used,json=tokensUsed,proto3" json:"tokens_used,omitempty"`
+	Confidence        float32                `protobuf:"fixed32,5,opt,name=confidence,proto3" json:"confidence,omitempty"`                                                // mean token probability from model logprobs; 0 when the backend reports none
+	GeneratedTokenIds []int32                `protobuf:"varint,6,rep,packed,name=generated_token_ids,json=generatedTokenIds,proto3" json:"generated_token_ids,omitempty"` // TOKEN-NATIVE: Generated tokens for detokenization
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *SummarizeResponse) Reset() {
+	*x = SummarizeResponse{}
+	mi := &file_proto_search_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummarizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummarizeResponse) ProtoMessage() {}
+
+func (x *SummarizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummarizeResponse.ProtoReflect.Descriptor instead.
+func (*SummarizeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SummarizeResponse) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *SummarizeResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *SummarizeResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SummarizeResponse) GetTokensUsed() int32 {
+	if x != nil {
+		return x.TokensUsed
+	}
+	return 0
+}
+
+func (x *SummarizeResponse) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *SummarizeResponse) GetGeneratedTokenIds() []int32 {
+	if x != nil {
+		return x.GeneratedTokenIds
+	}
+	return nil
+}
+
+type SummarizeStreamResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Token            string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	IsFinal          bool                   `protobuf:"varint,2,opt,name=is_final,json=isFinal,proto3" json:"is_final,omitempty"`
+	Error            string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Position         int32                  `protobuf:"varint,4,opt,name=position,proto3" json:"position,omitempty"`
+	GeneratedTokenId int32                  `protobuf:"varint,5,opt,name=generated_token_id,json=generatedTokenId,proto3" json:"generated_token_id,omitempty"` // TOKEN-NATIVE: Token ID for streaming detokenization
+	Confidence       float32                `protobuf:"fixed32,6,opt,name=confidence,proto3" json:"confidence,omitempty"`                                      // on the final message: mean token probability, 0 when unknown
+	SessionReady     bool                   `protobuf:"varint,7,opt,name=session_ready,json=sessionReady,proto3" json:"session_ready,omitempty"`               // SummarizeSession: the first message, sent once the session can take its request
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *SummarizeStreamResponse) Reset() {
+	*x = SummarizeStreamResponse{}
+	mi := &file_proto_search_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummarizeStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummarizeStreamResponse) ProtoMessage() {}
+
+func (x *SummarizeStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummarizeStreamResponse.ProtoReflect.Descriptor instead.
+func (*SummarizeStreamResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *SummarizeStreamResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *SummarizeStreamResponse) GetIsFinal() bool {
+	if x != nil {
+		return x.IsFinal
+	}
+	return false
+}
+
+func (x *SummarizeStreamResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SummarizeStreamResponse) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *SummarizeStreamResponse) GetGeneratedTokenId() int32 {
+	if x != nil {
+		return x.GeneratedTokenId
+	}
+	return 0
+}
+
+func (x *SummarizeStreamResponse) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *SummarizeStreamResponse) GetSessionReady() bool {
+	if x != nil {
+		return x.SessionReady
+	}
+	return false
+}
+
+type EmbedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Texts         []string               `protobuf:"bytes,1,rep,name=texts,proto3" json:"texts,omitempty"`
+	ModelName     string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"` // empty uses the configured embedding model
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedRequest) Reset() {
+	*x = EmbedRequest{}
+	mi := &file_proto_search_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedRequest) ProtoMessage() {}
+
+func (x *EmbedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedRequest.ProtoReflect.Descriptor instead.
+func (*EmbedRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *EmbedRequest) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+func (x *EmbedRequest) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+type Embedding struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []float32              `protobuf:"fixed32,1,rep,packed,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Embedding) Reset() {
+	*x = Embedding{}
+	mi := &file_proto_search_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Embedding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Embedding) ProtoMessage() {}
+
+func (x *Embedding) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Embedding.ProtoReflect.Descriptor instead.
+func (*Embedding) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *Embedding) GetValues() []float32 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type EmbedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Embeddings    []*Embedding           `protobuf:"bytes,1,rep,name=embeddings,proto3" json:"embeddings,omitempty"` // one per input text, in order
+	ModelName     string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedResponse) Reset() {
+	*x = EmbedResponse{}
+	mi := &file_proto_search_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedResponse) ProtoMessage() {}
+
+func (x *EmbedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedResponse.ProtoReflect.Descriptor instead.
+func (*EmbedResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *EmbedResponse) GetEmbeddings() []*Embedding {
+	if x != nil {
+		return x.Embeddings
+	}
+	return nil
+}
+
+func (x *EmbedResponse) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+// The main entity a query is about, extracted from its search results
+type ExtractEntitiesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Results       []*SearchResult        `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"`
+	ModelName     string                 `protobuf:"bytes,3,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"` // empty uses the configured entity model
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractEntitiesRequest) Reset() {
+	*x = ExtractEntitiesRequest{}
+	mi := &file_proto_search_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractEntitiesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractEntitiesRequest) ProtoMessage() {}
+
+func (x *ExtractEntitiesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractEntitiesRequest.ProtoReflect.Descriptor instead.
+func (*ExtractEntitiesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *ExtractEntitiesRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ExtractEntitiesRequest) GetResults() []*SearchResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *ExtractEntitiesRequest) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+type EntityAttribute struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // e.g. "Born"
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EntityAttribute) Reset() {
+	*x = EntityAttribute{}
+	mi := &file_proto_search_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EntityAttribute) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EntityAttribute) ProtoMessage() {}
+
+func (x *EntityAttribute) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EntityAttribute.ProtoReflect.Descriptor instead.
+func (*EntityAttribute) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *EntityAttribute) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *EntityAttribute) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type Entity struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"` // person, organization, place, product, work, event, concept or other
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Attributes    []*EntityAttribute     `protobuf:"bytes,4,rep,name=attributes,proto3" json:"attributes,omitempty"`
+	ImageUrl      string                 `protobuf:"bytes,5,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"` // from the result the entity was found in
+	SourceUrl     string                 `protobuf:"bytes,6,opt,name=source_url,json=sourceUrl,proto3" json:"source_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Entity) Reset() {
+	*x = Entity{}
+	mi := &file_proto_search_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entity) ProtoMessage() {}
+
+func (x *Entity) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entity.ProtoReflect.Descriptor instead.
+func (*Entity) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *Entity) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Entity) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Entity) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Entity) GetAttributes() []*EntityAttribute {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Entity) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+func (x *Entity) GetSourceUrl() string {
+	if x != nil {
+		return x.SourceUrl
+	}
+	return ""
+}
+
+type ExtractEntitiesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entity        *Entity                `protobuf:"bytes,1,opt,name=entity,proto3" json:"entity,omitempty"` // unset when the results are not about one entity
+	ModelName     string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractEntitiesResponse) Reset() {
+	*x = ExtractEntitiesResponse{}
+	mi := &file_proto_search_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractEntitiesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractEntitiesResponse) ProtoMessage() {}
+
+func (x *ExtractEntitiesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractEntitiesResponse.ProtoReflect.Descriptor instead.
+func (*ExtractEntitiesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *ExtractEntitiesResponse) GetEntity() *Entity {
+	if x != nil {
+		return x.Entity
+	}
+	return nil
+}
+
+func (x *ExtractEntitiesResponse) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+type PolishRelatedSearchesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Searches      []string               `protobuf:"bytes,2,rep,name=searches,proto3" json:"searches,omitempty"`                    // derived from the query's search results
+	ModelName     string                 `protobuf:"bytes,3,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"` // empty uses the configured model
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PolishRelatedSearchesRequest) Reset() {
+	*x = PolishRelatedSearchesRequest{}
+	mi := &file_proto_search_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PolishRelatedSearchesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolishRelatedSearchesRequest) ProtoMessage() {}
+
+func (x *PolishRelatedSearchesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolishRelatedSearchesRequest.ProtoReflect.Descriptor instead.
+func (*PolishRelatedSearchesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *PolishRelatedSearchesRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *PolishRelatedSearchesRequest) GetSearches() []string {
+	if x != nil {
+		return x.Searches
+	}
+	return nil
+}
+
+func (x *PolishRelatedSearchesRequest) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+type PolishRelatedSearchesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Searches      []string               `protobuf:"bytes,1,rep,name=searches,proto3" json:"searches,omitempty"` // in the same order, each rewritten as a natural query
+	ModelName     string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PolishRelatedSearchesResponse) Reset() {
+	*x = PolishRelatedSearchesResponse{}
+	mi := &file_proto_search_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PolishRelatedSearchesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolishRelatedSearchesResponse) ProtoMessage() {}
+
+func (x *PolishRelatedSearchesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolishRelatedSearchesResponse.ProtoReflect.Descriptor instead.
+func (*PolishRelatedSearchesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *PolishRelatedSearchesResponse) GetSearches() []string {
+	if x != nil {
+		return x.Searches
+	}
+	return nil
+}
+
+func (x *PolishRelatedSearchesResponse) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+type RerankRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Documents     []string               `protobuf:"bytes,2,rep,name=documents,proto3" json:"documents,omitempty"`                  // each result's title and snippet
+	ModelName     string                 `protobuf:"bytes,3,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"` // empty uses the configured model
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RerankRequest) Reset() {
+	*x = RerankRequest{}
+	mi := &file_proto_search_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RerankRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RerankRequest) ProtoMessage() {}
+
+func (x *RerankRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RerankRequest.ProtoReflect.Descriptor instead.
+func (*RerankRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *RerankRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *RerankRequest) GetDocuments() []string {
+	if x != nil {
+		return x.Documents
+	}
+	return nil
+}
+
+func (x *RerankRequest) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+type RerankResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Scores        []float32              `protobuf:"fixed32,1,rep,packed,name=scores,proto3" json:"scores,omitempty"` // relevance of each document to the query, 0..1, in the same order
+	ModelName     string                 `protobuf:"bytes,2,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RerankResponse) Reset() {
+	*x = RerankResponse{}
+	mi := &file_proto_search_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RerankResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RerankResponse) ProtoMessage() {}
+
+func (x *RerankResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RerankResponse.ProtoReflect.Descriptor instead.
+func (*RerankResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *RerankResponse) GetScores() []float32 {
+	if x != nil {
+		return x.Scores
+	}
+	return nil
+}
+
+func (x *RerankResponse) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+// Blue/green switch of a backend's model or server. The target is warmed
+// and must answer the canary prompt before traffic cuts over; the previous
+// target is restored if the error rate regresses within the rollback window.
+type SwitchModelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Backend       string                 `protobuf:"bytes,1,opt,name=backend,proto3" json:"backend,omitempty"` // "vllm" or "ollama"
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`     // default model; empty keeps the current one
+	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`         // vLLM server to send requests to; empty keeps the current one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SwitchModelRequest) Reset() {
+	*x = SwitchModelRequest{}
+	mi := &file_proto_search_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SwitchModelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SwitchModelRequest) ProtoMessage() {}
+
+func (x *SwitchModelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SwitchModelRequest.ProtoReflect.Descriptor instead.
+func (*SwitchModelRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *SwitchModelRequest) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *SwitchModelRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *SwitchModelRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type SwitchModelResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Switched      bool                   `protobuf:"varint,1,opt,name=switched,proto3" json:"switched,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"` // why the target was refused; the previous one stays active
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"` // active after the call
+	Url           string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	PreviousModel string                 `protobuf:"bytes,5,opt,name=previous_model,json=previousModel,proto3" json:"previous_model,omitempty"` // restored on a regression
+	PreviousUrl   string                 `protobuf:"bytes,6,opt,name=previous_url,json=previousUrl,proto3" json:"previous_url,omitempty"`
+	CanaryOutput  string                 `protobuf:"bytes,7,opt,name=canary_output,json=canaryOutput,proto3" json:"canary_output,omitempty"`
+	WatchUntil    int64                  `protobuf:"varint,8,opt,name=watch_until,json=watchUntil,proto3" json:"watch_until,omitempty"` // unix seconds; the error rate is watched until then
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SwitchModelResponse) Reset() {
+	*x = SwitchModelResponse{}
+	mi := &file_proto_search_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SwitchModelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SwitchModelResponse) ProtoMessage() {}
+
+func (x *SwitchModelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SwitchModelResponse.ProtoReflect.Descriptor instead.
+func (*SwitchModelResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *SwitchModelResponse) GetSwitched() bool {
+	if x != nil {
+		return x.Switched
+	}
+	return false
+}
+
+func (x *SwitchModelResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SwitchModelResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *SwitchModelResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *SwitchModelResponse) GetPreviousModel() string {
+	if x != nil {
+		return x.PreviousModel
+	}
+	return ""
+}
+
+func (x *SwitchModelResponse) GetPreviousUrl() string {
+	if x != nil {
+		return x.PreviousUrl
+	}
+	return ""
+}
+
+func (x *SwitchModelResponse) GetCanaryOutput() string {
+	if x != nil {
+		return x.CanaryOutput
+	}
+	return ""
+}
+
+func (x *SwitchModelResponse) GetWatchUntil() int64 {
+	if x != nil {
+		return x.WatchUntil
+	}
+	return 0
+}
+
+type ListModelsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListModelsRequest) Reset() {
+	*x = ListModelsRequest{}
+	mi := &file_proto_search_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListModelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModelsRequest) ProtoMessage() {}
+
+func (x *ListModelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModelsRequest.ProtoReflect.Descriptor instead.
+func (*ListModelsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{40}
+}
+
+type ModelInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Backend       string                 `protobuf:"bytes,1,opt,name=backend,proto3" json:"backend,omitempty"`                          // "vllm" or "ollama"
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`                              // the backend's default model
+	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`                                  // vLLM server
+	Warmed        bool                   `protobuf:"varint,4,opt,name=warmed,proto3" json:"warmed,omitempty"`                           // answered the warm-up prompt at startup
+	WatchUntil    int64                  `protobuf:"varint,5,opt,name=watch_until,json=watchUntil,proto3" json:"watch_until,omitempty"` // unix seconds; a switch to it is watched for regressions until then, 0 when not
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModelInfo) Reset() {
+	*x = ModelInfo{}
+	mi := &file_proto_search_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelInfo) ProtoMessage() {}
+
+func (x *ModelInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelInfo.ProtoReflect.Descriptor instead.
+func (*ModelInfo) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *ModelInfo) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetWarmed() bool {
+	if x != nil {
+		return x.Warmed
+	}
+	return false
+}
+
+func (x *ModelInfo) GetWatchUntil() int64 {
+	if x != nil {
+		return x.WatchUntil
+	}
+	return 0
+}
+
+type ListModelsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Models        []*ModelInfo           `protobuf:"bytes,1,rep,name=models,proto3" json:"models,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListModelsResponse) Reset() {
+	*x = ListModelsResponse{}
+	mi := &file_proto_search_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListModelsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModelsResponse) ProtoMessage() {}
+
+func (x *ListModelsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModelsResponse.ProtoReflect.Descriptor instead.
+func (*ListModelsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *ListModelsResponse) GetModels() []*ModelInfo {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+// SafetyPolicy is what a tenant, or one of its API keys, adds to the built-in
+// filters. The safety service compiles it once per id and reuses the matchers
+// until the policy changes.
+type SafetyPolicy struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`                                                  // the tenant ID, or "<tenant>/<key ID>" for a key's policy
+	BlockedTerms    []string               `protobuf:"bytes,2,rep,name=blocked_terms,json=blockedTerms,proto3" json:"blocked_terms,omitempty"`          // phrases, matched case-insensitively on word boundaries
+	BlockedPatterns []string               `protobuf:"bytes,3,rep,name=blocked_patterns,json=blockedPatterns,proto3" json:"blocked_patterns,omitempty"` // RE2 regular expressions
+	AllowedTerms    []string               `protobuf:"bytes,4,rep,name=allowed_terms,json=allowedTerms,proto3" json:"allowed_terms,omitempty"`          // phrases the inappropriate-content filters let through; never exempt from attack patterns
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SafetyPolicy) Reset() {
+	*x = SafetyPolicy{}
+	mi := &file_proto_search_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SafetyPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SafetyPolicy) ProtoMessage() {}
+
+func (x *SafetyPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SafetyPolicy.ProtoReflect.Descriptor instead.
+func (*SafetyPolicy) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *SafetyPolicy) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SafetyPolicy) GetBlockedTerms() []string {
+	if x != nil {
+		return x.BlockedTerms
+	}
+	return nil
+}
+
+func (x *SafetyPolicy) GetBlockedPatterns() []string {
+	if x != nil {
+		return x.BlockedPatterns
+	}
+	return nil
+}
+
+func (x *SafetyPolicy) GetAllowedTerms() []string {
+	if x != nil {
+		return x.AllowedTerms
+	}
+	return nil
+}
+
+type ValidateInputRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	ClientIp      string                 `protobuf:"bytes,2,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	SafeSearch    bool                   `protobuf:"varint,3,opt,name=safe_search,json=safeSearch,proto3" json:"safe_search,omitempty"`
+	BlockedTerms  []string               `protobuf:"bytes,4,rep,name=blocked_terms,json=blockedTerms,proto3" json:"blocked_terms,omitempty"` // tenant policy: phrases rejected in addition to the built-in patterns
+	Policy        *SafetyPolicy          `protobuf:"bytes,5,opt,name=policy,proto3" json:"policy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateInputRequest) Reset() {
+	*x = ValidateInputRequest{}
+	mi := &file_proto_search_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateInputRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateInputRequest) ProtoMessage() {}
+
+func (x *ValidateInputRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateInputRequest.ProtoReflect.Descriptor instead.
+func (*ValidateInputRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *ValidateInputRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *ValidateInputRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *ValidateInputRequest) GetSafeSearch() bool {
+	if x != nil {
+		return x.SafeSearch
+	}
+	return false
+}
+
+func (x *ValidateInputRequest) GetBlockedTerms() []string {
+	if x != nil {
+		return x.BlockedTerms
+	}
+	return nil
+}
+
+func (x *ValidateInputRequest) GetPolicy() *SafetyPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type ValidateInputResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IsSafe        bool                   `protobuf:"varint,1,opt,name=is_safe,json=isSafe,proto3" json:"is_safe,omitempty"`
+	SanitizedText string                 `protobuf:"bytes,2,opt,name=sanitized_text,json=sanitizedText,proto3" json:"sanitized_text,omitempty"`
+	Warnings      []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateInputResponse) Reset() {
+	*x = ValidateInputResponse{}
+	mi := &file_proto_search_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateInputResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateInputResponse) ProtoMessage() {}
+
+func (x *ValidateInputResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateInputResponse.ProtoReflect.Descriptor instead.
+func (*ValidateInputResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *ValidateInputResponse) GetIsSafe() bool {
+	if x != nil {
+		return x.IsSafe
+	}
+	return false
+}
+
+func (x *ValidateInputResponse) GetSanitizedText() string {
+	if x != nil {
+		return x.SanitizedText
+	}
+	return ""
+}
+
+func (x *ValidateInputResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *ValidateInputResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type SanitizeOutputRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Policy        *SafetyPolicy          `protobuf:"bytes,2,opt,name=policy,proto3" json:"policy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SanitizeOutputRequest) Reset() {
+	*x = SanitizeOutputRequest{}
+	mi := &file_proto_search_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SanitizeOutputRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SanitizeOutputRequest) ProtoMessage() {}
+
+func (x *SanitizeOutputRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SanitizeOutputRequest.ProtoReflect.Descriptor instead.
+func (*SanitizeOutputRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *SanitizeOutputRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SanitizeOutputRequest) GetPolicy() *SafetyPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type SanitizeOutputResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SanitizedText string                 `protobuf:"bytes,1,opt,name=sanitized_text,json=sanitizedText,proto3" json:"sanitized_text,omitempty"`
+	Warnings      []string               `protobuf:"bytes,2,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	// Toxicity scores the text from 0 to 1 in each category it matched
+	Toxicity map[string]float32 `protobuf:"bytes,4,rep,name=toxicity,proto3" json:"toxicity,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed32,2,opt,name=value"`
+	// Action is what the scores led to: pass, annotate, rewrite or block
+	Action        string `protobuf:"bytes,5,opt,name=action,proto3" json:"action,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SanitizeOutputResponse) Reset() {
+	*x = SanitizeOutputResponse{}
+	mi := &file_proto_search_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SanitizeOutputResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SanitizeOutputResponse) ProtoMessage() {}
+
+func (x *SanitizeOutputResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SanitizeOutputResponse.ProtoReflect.Descriptor instead.
+func (*SanitizeOutputResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *SanitizeOutputResponse) GetSanitizedText() string {
+	if x != nil {
+		return x.SanitizedText
+	}
+	return ""
+}
+
+func (x *SanitizeOutputResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *SanitizeOutputResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SanitizeOutputResponse) GetToxicity() map[string]float32 {
+	if x != nil {
+		return x.Toxicity
+	}
+	return nil
+}
+
+func (x *SanitizeOutputResponse) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+// Batches screen many texts, such as a request's snippets or chunks, in one
+// call under one policy. Results are in the order of the texts.
+type BatchValidateInputRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Texts         []string               `protobuf:"bytes,1,rep,name=texts,proto3" json:"texts,omitempty"`
+	ClientIp      string                 `protobuf:"bytes,2,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	SafeSearch    bool                   `protobuf:"varint,3,opt,name=safe_search,json=safeSearch,proto3" json:"safe_search,omitempty"`
+	Policy        *SafetyPolicy          `protobuf:"bytes,4,opt,name=policy,proto3" json:"policy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchValidateInputRequest) Reset() {
+	*x = BatchValidateInputRequest{}
+	mi := &file_proto_search_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchValidateInputRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchValidateInputRequest) ProtoMessage() {}
+
+func (x *BatchValidateInputRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchValidateInputRequest.ProtoReflect.Descriptor instead.
+func (*BatchValidateInputRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *BatchValidateInputRequest) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+func (x *BatchValidateInputRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *BatchValidateInputRequest) GetSafeSearch() bool {
+	if x != nil {
+		return x.SafeSearch
+	}
+	return false
+}
+
+func (x *BatchValidateInputRequest) GetPolicy() *SafetyPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type BatchValidateInputResponse struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	Results       []*ValidateInputResponse `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchValidateInputResponse) Reset() {
+	*x = BatchValidateInputResponse{}
+	mi := &file_proto_search_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchValidateInputResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchValidateInputResponse) ProtoMessage() {}
+
+func (x *BatchValidateInputResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchValidateInputResponse.ProtoReflect.Descriptor instead.
+func (*BatchValidateInputResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *BatchValidateInputResponse) GetResults() []*ValidateInputResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BatchSanitizeOutputRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Texts         []string               `protobuf:"bytes,1,rep,name=texts,proto3" json:"texts,omitempty"`
+	Policy        *SafetyPolicy          `protobuf:"bytes,2,opt,name=policy,proto3" json:"policy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchSanitizeOutputRequest) Reset() {
+	*x = BatchSanitizeOutputRequest{}
+	mi := &file_proto_search_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchSanitizeOutputRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchSanitizeOutputRequest) ProtoMessage() {}
+
+func (x *BatchSanitizeOutputRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchSanitizeOutputRequest.ProtoReflect.Descriptor instead.
+func (*BatchSanitizeOutputRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *BatchSanitizeOutputRequest) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+func (x *BatchSanitizeOutputRequest) GetPolicy() *SafetyPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type BatchSanitizeOutputResponse struct {
+	state         protoimpl.MessageState    `protogen:"open.v1"`
+	Results       []*SanitizeOutputResponse `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchSanitizeOutputResponse) Reset() {
+	*x = BatchSanitizeOutputResponse{}
+	mi := &file_proto_search_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchSanitizeOutputResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchSanitizeOutputResponse) ProtoMessage() {}
+
+func (x *BatchSanitizeOutputResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchSanitizeOutputResponse.ProtoReflect.Descriptor instead.
+func (*BatchSanitizeOutputResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *BatchSanitizeOutputResponse) GetResults() []*SanitizeOutputResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// Suggestions are shown unrequested, so any that match an attack or
+// inappropriate pattern or the denylist are dropped
+type FilterSuggestionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Suggestions   []string               `protobuf:"bytes,1,rep,name=suggestions,proto3" json:"suggestions,omitempty"`
+	BlockedTerms  []string               `protobuf:"bytes,2,rep,name=blocked_terms,json=blockedTerms,proto3" json:"blocked_terms,omitempty"` // tenant policy, on top of the configured denylist
+	Policy        *SafetyPolicy          `protobuf:"bytes,3,opt,name=policy,proto3" json:"policy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterSuggestionsRequest) Reset() {
+	*x = FilterSuggestionsRequest{}
+	mi := &file_proto_search_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterSuggestionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterSuggestionsRequest) ProtoMessage() {}
+
+func (x *FilterSuggestionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterSuggestionsRequest.ProtoReflect.Descriptor instead.
+func (*FilterSuggestionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *FilterSuggestionsRequest) GetSuggestions() []string {
+	if x != nil {
+		return x.Suggestions
+	}
+	return nil
+}
+
+func (x *FilterSuggestionsRequest) GetBlockedTerms() []string {
+	if x != nil {
+		return x.BlockedTerms
+	}
+	return nil
+}
+
+func (x *FilterSuggestionsRequest) GetPolicy() *SafetyPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+type FilterSuggestionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Allowed       []string               `protobuf:"bytes,1,rep,name=allowed,proto3" json:"allowed,omitempty"` // in request order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterSuggestionsResponse) Reset() {
+	*x = FilterSuggestionsResponse{}
+	mi := &file_proto_search_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterSuggestionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterSuggestionsResponse) ProtoMessage() {}
+
+func (x *FilterSuggestionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterSuggestionsResponse.ProtoReflect.Descriptor instead.
+func (*FilterSuggestionsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *FilterSuggestionsResponse) GetAllowed() []string {
+	if x != nil {
+		return x.Allowed
+	}
+	return nil
+}
+
+// LLM Orchestrator messages
+type LLMRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	MaxTokens     int32                  `protobuf:"varint,3,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	Stream        bool                   `protobuf:"varint,4,opt,name=stream,proto3" json:"stream,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Continuation  string                 `protobuf:"bytes,6,opt,name=continuation,proto3" json:"continuation,omitempty"`                           // partial summary to continue from (resumed streams)
+	PriorLlmCalls int32                  `protobuf:"varint,7,opt,name=prior_llm_calls,json=priorLlmCalls,proto3" json:"prior_llm_calls,omitempty"` // LLM calls already spent on this search (continuations)
+	Model         string                 `protobuf:"bytes,8,opt,name=model,proto3" json:"model,omitempty"`                                         // tokenizer/model to summarize with; empty uses the default
+	Sampling      *SamplingParams        `protobuf:"bytes,9,opt,name=sampling,proto3" json:"sampling,omitempty"`                                   // validated against gateway.sampling; unset keeps the backend's defaults
+	Shadow        bool                   `protobuf:"varint,10,opt,name=shadow,proto3" json:"shadow,omitempty"`                                     // mirrored traffic whose summary no user sees
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LLMRequest) Reset() {
+	*x = LLMRequest{}
+	mi := &file_proto_search_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LLMRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LLMRequest) ProtoMessage() {}
+
+func (x *LLMRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LLMRequest.ProtoReflect.Descriptor instead.
+func (*LLMRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *LLMRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *LLMRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *LLMRequest) GetMaxTokens() int32 {
+	if x != nil {
+		return x.MaxTokens
+	}
+	return 0
+}
+
+func (x *LLMRequest) GetStream() bool {
+	if x != nil {
+		return x.Stream
+	}
+	return false
+}
+
+func (x *LLMRequest) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *LLMRequest) GetContinuation() string {
+	if x != nil {
+		return x.Continuation
+	}
+	return ""
+}
+
+func (x *LLMRequest) GetPriorLlmCalls() int32 {
+	if x != nil {
+		return x.PriorLlmCalls
+	}
+	return 0
+}
+
+func (x *LLMRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *LLMRequest) GetSampling() *SamplingParams {
+	if x != nil {
+		return x.Sampling
+	}
+	return nil
+}
+
+func (x *LLMRequest) GetShadow() bool {
+	if x != nil {
+		return x.Shadow
+	}
+	return false
+}
+
+type LLMResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Tokens          []string               `protobuf:"bytes,2,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	Summary         string                 `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"`
+	Error           string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Complete        bool                   `protobuf:"varint,5,opt,name=complete,proto3" json:"complete,omitempty"`
+	Budget          *BudgetOutcome         `protobuf:"bytes,6,opt,name=budget,proto3" json:"budget,omitempty"`                                            // set when a per-request ceiling was hit
+	ModelConfidence float32                `protobuf:"fixed32,7,opt,name=model_confidence,json=modelConfidence,proto3" json:"model_confidence,omitempty"` // mean token probability from the model; 0 when unknown
+	ErrorCode       string                 `protobuf:"bytes,8,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`                     // with error when time ran out: request_timeout, tokenize_timeout or inference_timeout
+	InputTruncated  bool                   `protobuf:"varint,9,opt,name=input_truncated,json=inputTruncated,proto3" json:"input_truncated,omitempty"`     // the prompt was cut to the model's context window or the budget's max input tokens
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *LLMResponse) Reset() {
+	*x = LLMResponse{}
+	mi := &file_proto_search_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LLMResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LLMResponse) ProtoMessage() {}
+
+func (x *LLMResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LLMResponse.ProtoReflect.Descriptor instead.
+func (*LLMResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *LLMResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *LLMResponse) GetTokens() []string {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+func (x *LLMResponse) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *LLMResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *LLMResponse) GetComplete() bool {
+	if x != nil {
+		return x.Complete
+	}
+	return false
+}
+
+func (x *LLMResponse) GetBudget() *BudgetOutcome {
+	if x != nil {
+		return x.Budget
+	}
+	return nil
+}
+
+func (x *LLMResponse) GetModelConfidence() float32 {
+	if x != nil {
+		return x.ModelConfidence
+	}
+	return 0
+}
+
+func (x *LLMResponse) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *LLMResponse) GetInputTruncated() bool {
+	if x != nil {
+		return x.InputTruncated
+	}
+	return false
+}
+
+// BudgetOutcome reports which per-request cost ceilings were hit and what
+// work was skipped or cut short because of them
+type BudgetOutcome struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Limits        []string               `protobuf:"bytes,1,rep,name=limits,proto3" json:"limits,omitempty"`   // e.g. max_input_tokens, max_output_tokens, max_llm_calls
+	Skipped       []string               `protobuf:"bytes,2,rep,name=skipped,proto3" json:"skipped,omitempty"` // human-readable description of skipped work
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BudgetOutcome) Reset() {
+	*x = BudgetOutcome{}
+	mi := &file_proto_search_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BudgetOutcome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BudgetOutcome) ProtoMessage() {}
+
+func (x *BudgetOutcome) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BudgetOutcome.ProtoReflect.Descriptor instead.
+func (*BudgetOutcome) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{56}
+}
+
+func (x *BudgetOutcome) GetLimits() []string {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+func (x *BudgetOutcome) GetSkipped() []string {
+	if x != nil {
+		return x.Skipped
+	}
+	return nil
+}
+
+type LLMStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LLMStatusRequest) Reset() {
+	*x = LLMStatusRequest{}
+	mi := &file_proto_search_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LLMStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LLMStatusRequest) ProtoMessage() {}
+
+func (x *LLMStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LLMStatusRequest.ProtoReflect.Descriptor instead.
+func (*LLMStatusRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *LLMStatusRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+type LLMStatusResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	RequestId         string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Status            string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // pending, processing, completed, failed
+	QueuePosition     int32                  `protobuf:"varint,3,opt,name=queue_position,json=queuePosition,proto3" json:"queue_position,omitempty"`
+	EstimatedWaitTime int32                  `protobuf:"varint,4,opt,name=estimated_wait_time,json=estimatedWaitTime,proto3" json:"estimated_wait_time,omitempty"` // seconds
+	Error             string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *LLMStatusResponse) Reset() {
+	*x = LLMStatusResponse{}
+	mi := &file_proto_search_proto_msgTypes[58]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LLMStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LLMStatusResponse) ProtoMessage() {}
+
+func (x *LLMStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[58]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LLMStatusResponse.ProtoReflect.Descriptor instead.
+func (*LLMStatusResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{58}
+}
+
+func (x *LLMStatusResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *LLMStatusResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *LLMStatusResponse) GetQueuePosition() int32 {
+	if x != nil {
+		return x.QueuePosition
+	}
+	return 0
+}
+
+func (x *LLMStatusResponse) GetEstimatedWaitTime() int32 {
+	if x != nil {
+		return x.EstimatedWaitTime
+	}
+	return 0
+}
+
+func (x *LLMStatusResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type LLMStreamResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Token           string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	IsFinal         bool                   `protobuf:"varint,3,opt,name=is_final,json=isFinal,proto3" json:"is_final,omitempty"`
+	Error           string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Position        int32                  `protobuf:"varint,5,opt,name=position,proto3" json:"position,omitempty"`
+	Budget          *BudgetOutcome         `protobuf:"bytes,6,opt,name=budget,proto3" json:"budget,omitempty"`                                            // on the final message when a per-request ceiling was hit
+	ModelConfidence float32                `protobuf:"fixed32,7,opt,name=model_confidence,json=modelConfidence,proto3" json:"model_confidence,omitempty"` // on the final message: mean token probability, 0 when unknown
+	Truncated       bool                   `protobuf:"varint,8,opt,name=truncated,proto3" json:"truncated,omitempty"`                                     // on the final message: the orchestrator's stream guard cut the summary short
+	TruncatedReason string                 `protobuf:"bytes,9,opt,name=truncated_reason,json=truncatedReason,proto3" json:"truncated_reason,omitempty"`   // why: repetition or max_length
+	Recovered       bool                   `protobuf:"varint,10,opt,name=recovered,proto3" json:"recovered,omitempty"`                                    // on the final message: the backend stream broke off and a fallback backend continued it
+	Generation      int32                  `protobuf:"varint,11,opt,name=generation,proto3" json:"generation,omitempty"`                                  // StreamSession: 1, then one more for each regeneration
+	Cancelled       bool                   `protobuf:"varint,12,opt,name=cancelled,proto3" json:"cancelled,omitempty"`                                    // StreamSession: on the final message, the client cancelled the generation
+	ErrorCode       string                 `protobuf:"bytes,13,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`                    // on the final message, with error when time ran out, as in LLMResponse
+	InputTruncated  bool                   `protobuf:"varint,14,opt,name=input_truncated,json=inputTruncated,proto3" json:"input_truncated,omitempty"`    // on the final message: the prompt was cut, as in LLMResponse
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *LLMStreamResponse) Reset() {
+	*x = LLMStreamResponse{}
+	mi := &file_proto_search_proto_msgTypes[59]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LLMStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LLMStreamResponse) ProtoMessage() {}
+
+func (x *LLMStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[59]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LLMStreamResponse.ProtoReflect.Descriptor instead.
+func (*LLMStreamResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{59}
+}
+
+func (x *LLMStreamResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *LLMStreamResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *LLMStreamResponse) GetIsFinal() bool {
+	if x != nil {
+		return x.IsFinal
 	}
 	return false
 }
 
-func (x *ValidateInputResponse) GetSanitizedText() string {
+func (x *LLMStreamResponse) GetError() string {
 	if x != nil {
-		return x.SanitizedText
+		return x.Error
 	}
 	return ""
 }
 
-func (x *ValidateInputResponse) GetWarnings() []string {
+func (x *LLMStreamResponse) GetPosition() int32 {
 	if x != nil {
-		return x.Warnings
+		return x.Position
+	}
+	return 0
+}
+
+func (x *LLMStreamResponse) GetBudget() *BudgetOutcome {
+	if x != nil {
+		return x.Budget
 	}
 	return nil
 }
 
-func (x *ValidateInputResponse) GetError() string {
+func (x *LLMStreamResponse) GetModelConfidence() float32 {
 	if x != nil {
-		return x.Error
+		return x.ModelConfidence
+	}
+	return 0
+}
+
+func (x *LLMStreamResponse) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *LLMStreamResponse) GetTruncatedReason() string {
+	if x != nil {
+		return x.TruncatedReason
 	}
 	return ""
 }
 
-type SanitizeOutputRequest struct {
+func (x *LLMStreamResponse) GetRecovered() bool {
+	if x != nil {
+		return x.Recovered
+	}
+	return false
+}
+
+func (x *LLMStreamResponse) GetGeneration() int32 {
+	if x != nil {
+		return x.Generation
+	}
+	return 0
+}
+
+func (x *LLMStreamResponse) GetCancelled() bool {
+	if x != nil {
+		return x.Cancelled
+	}
+	return false
+}
+
+func (x *LLMStreamResponse) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *LLMStreamResponse) GetInputTruncated() bool {
+	if x != nil {
+		return x.InputTruncated
+	}
+	return false
+}
+
+// LLMSessionMessage drives a StreamSession. The first message starts the
+// generation; later ones control it while its tokens stream back.
+type LLMSessionMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Start         *LLMRequest            `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`                           // the first message only
+	Action        string                 `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`                         // later messages: cancel, set_max_tokens or regenerate
+	MaxTokens     int32                  `protobuf:"varint,3,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"` // set_max_tokens: caps the tokens streamed, and regenerations
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SanitizeOutputRequest) Reset() {
-	*x = SanitizeOutputRequest{}
-	mi := &file_proto_search_proto_msgTypes[20]
+func (x *LLMSessionMessage) Reset() {
+	*x = LLMSessionMessage{}
+	mi := &file_proto_search_proto_msgTypes[60]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LLMSessionMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LLMSessionMessage) ProtoMessage() {}
+
+func (x *LLMSessionMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[60]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LLMSessionMessage.ProtoReflect.Descriptor instead.
+func (*LLMSessionMessage) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{60}
+}
+
+func (x *LLMSessionMessage) GetStart() *LLMRequest {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *LLMSessionMessage) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *LLMSessionMessage) GetMaxTokens() int32 {
+	if x != nil {
+		return x.MaxTokens
+	}
+	return 0
+}
+
+// VLLMGenerateRequest prompts with token IDs, so the model skips
+// tokenization, or with text when there are none (warm-up, entity extraction)
+type VLLMGenerateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	TokenIds      []int32                `protobuf:"varint,3,rep,packed,name=token_ids,json=tokenIds,proto3" json:"token_ids,omitempty"`
+	Prompt        string                 `protobuf:"bytes,4,opt,name=prompt,proto3" json:"prompt,omitempty"` // used when token_ids is empty
+	MaxTokens     int32                  `protobuf:"varint,5,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	Sampling      *SamplingParams        `protobuf:"bytes,6,opt,name=sampling,proto3" json:"sampling,omitempty"`  // unset keeps the server's defaults
+	Logprobs      int32                  `protobuf:"varint,7,opt,name=logprobs,proto3" json:"logprobs,omitempty"` // report the log probability of each generated token
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VLLMGenerateRequest) Reset() {
+	*x = VLLMGenerateRequest{}
+	mi := &file_proto_search_proto_msgTypes[61]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VLLMGenerateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VLLMGenerateRequest) ProtoMessage() {}
+
+func (x *VLLMGenerateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[61]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VLLMGenerateRequest.ProtoReflect.Descriptor instead.
+func (*VLLMGenerateRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{61}
+}
+
+func (x *VLLMGenerateRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *VLLMGenerateRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *VLLMGenerateRequest) GetTokenIds() []int32 {
+	if x != nil {
+		return x.TokenIds
+	}
+	return nil
+}
+
+func (x *VLLMGenerateRequest) GetPrompt() string {
+	if x != nil {
+		return x.Prompt
+	}
+	return ""
+}
+
+func (x *VLLMGenerateRequest) GetMaxTokens() int32 {
+	if x != nil {
+		return x.MaxTokens
+	}
+	return 0
+}
+
+func (x *VLLMGenerateRequest) GetSampling() *SamplingParams {
+	if x != nil {
+		return x.Sampling
+	}
+	return nil
+}
+
+func (x *VLLMGenerateRequest) GetLogprobs() int32 {
+	if x != nil {
+		return x.Logprobs
+	}
+	return 0
+}
+
+// VLLMGenerateResponse is a whole completion, or one chunk of a stream;
+// the stream's last message has finish_reason set
+type VLLMGenerateResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Text             string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	TokenIds         []int32                `protobuf:"varint,2,rep,packed,name=token_ids,json=tokenIds,proto3" json:"token_ids,omitempty"`                 // generated tokens of this message
+	TokenLogprobs    []float32              `protobuf:"fixed32,3,rep,packed,name=token_logprobs,json=tokenLogprobs,proto3" json:"token_logprobs,omitempty"` // one per generated token when logprobs was asked for
+	FinishReason     string                 `protobuf:"bytes,4,opt,name=finish_reason,json=finishReason,proto3" json:"finish_reason,omitempty"`             // stop, length or abort; empty until the last message
+	PromptTokens     int32                  `protobuf:"varint,5,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	CompletionTokens int32                  `protobuf:"varint,6,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *VLLMGenerateResponse) Reset() {
+	*x = VLLMGenerateResponse{}
+	mi := &file_proto_search_proto_msgTypes[62]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VLLMGenerateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VLLMGenerateResponse) ProtoMessage() {}
+
+func (x *VLLMGenerateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[62]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VLLMGenerateResponse.ProtoReflect.Descriptor instead.
+func (*VLLMGenerateResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{62}
+}
+
+func (x *VLLMGenerateResponse) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *VLLMGenerateResponse) GetTokenIds() []int32 {
+	if x != nil {
+		return x.TokenIds
+	}
+	return nil
+}
+
+func (x *VLLMGenerateResponse) GetTokenLogprobs() []float32 {
+	if x != nil {
+		return x.TokenLogprobs
+	}
+	return nil
+}
+
+func (x *VLLMGenerateResponse) GetFinishReason() string {
+	if x != nil {
+		return x.FinishReason
+	}
+	return ""
+}
+
+func (x *VLLMGenerateResponse) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *VLLMGenerateResponse) GetCompletionTokens() int32 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+// Messages of Triton Inference Server's GRPCInferenceService (the KServe v2
+// protocol), for its ModelReady and ModelInfer methods. Only the fields the
+// inference service uses are declared; their numbers match Triton's
+// grpc_service.proto, so they are compatible on the wire.
+type TritonModelReadyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TritonModelReadyRequest) Reset() {
+	*x = TritonModelReadyRequest{}
+	mi := &file_proto_search_proto_msgTypes[63]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SanitizeOutputRequest) String() string {
+func (x *TritonModelReadyRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SanitizeOutputRequest) ProtoMessage() {}
+func (*TritonModelReadyRequest) ProtoMessage() {}
 
-func (x *SanitizeOutputRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[20]
+func (x *TritonModelReadyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[63]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1427,42 +4444,47 @@ func (x *SanitizeOutputRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SanitizeOutputRequest.ProtoReflect.Descriptor instead.
-func (*SanitizeOutputRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{20}
+// Deprecated: Use TritonModelReadyRequest.ProtoReflect.Descriptor instead.
+func (*TritonModelReadyRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{63}
 }
 
-func (x *SanitizeOutputRequest) GetText() string {
+func (x *TritonModelReadyRequest) GetName() string {
 	if x != nil {
-		return x.Text
+		return x.Name
 	}
 	return ""
 }
 
-type SanitizeOutputResponse struct {
+func (x *TritonModelReadyRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+type TritonModelReadyResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SanitizedText string                 `protobuf:"bytes,1,opt,name=sanitized_text,json=sanitizedText,proto3" json:"sanitized_text,omitempty"`
-	Warnings      []string               `protobuf:"bytes,2,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Ready         bool                   `protobuf:"varint,1,opt,name=ready,proto3" json:"ready,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SanitizeOutputResponse) Reset() {
-	*x = SanitizeOutputResponse{}
-	mi := &file_proto_search_proto_msgTypes[21]
+func (x *TritonModelReadyResponse) Reset() {
+	*x = TritonModelReadyResponse{}
+	mi := &file_proto_search_proto_msgTypes[64]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SanitizeOutputResponse) String() string {
+func (x *TritonModelReadyResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SanitizeOutputResponse) ProtoMessage() {}
+func (*TritonModelReadyResponse) ProtoMessage() {}
 
-func (x *SanitizeOutputResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[21]
+func (x *TritonModelReadyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[64]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1473,59 +4495,44 @@ func (x *SanitizeOutputResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SanitizeOutputResponse.ProtoReflect.Descriptor instead.
-func (*SanitizeOutputResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{21}
-}
-
-func (x *SanitizeOutputResponse) GetSanitizedText() string {
-	if x != nil {
-		return x.SanitizedText
-	}
-	return ""
-}
-
-func (x *SanitizeOutputResponse) GetWarnings() []string {
-	if x != nil {
-		return x.Warnings
-	}
-	return nil
+// Deprecated: Use TritonModelReadyResponse.ProtoReflect.Descriptor instead.
+func (*TritonModelReadyResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{64}
 }
 
-func (x *SanitizeOutputResponse) GetError() string {
+func (x *TritonModelReadyResponse) GetReady() bool {
 	if x != nil {
-		return x.Error
+		return x.Ready
 	}
-	return ""
+	return false
 }
 
-// LLM Orchestrator messages
-type LLMRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
-	MaxTokens     int32                  `protobuf:"varint,3,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
-	Stream        bool                   `protobuf:"varint,4,opt,name=stream,proto3" json:"stream,omitempty"`
-	CreatedAt     int64                  `protobuf:"varint,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+type TritonInferRequest struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	ModelName     string                   `protobuf:"bytes,1,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	ModelVersion  string                   `protobuf:"bytes,2,opt,name=model_version,json=modelVersion,proto3" json:"model_version,omitempty"`
+	Id            string                   `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	Inputs        []*TritonInferTensor     `protobuf:"bytes,5,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Outputs       []*TritonRequestedOutput `protobuf:"bytes,6,rep,name=outputs,proto3" json:"outputs,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LLMRequest) Reset() {
-	*x = LLMRequest{}
-	mi := &file_proto_search_proto_msgTypes[22]
+func (x *TritonInferRequest) Reset() {
+	*x = TritonInferRequest{}
+	mi := &file_proto_search_proto_msgTypes[65]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LLMRequest) String() string {
+func (x *TritonInferRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LLMRequest) ProtoMessage() {}
+func (*TritonInferRequest) ProtoMessage() {}
 
-func (x *LLMRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[22]
+func (x *TritonInferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[65]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1536,72 +4543,74 @@ func (x *LLMRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LLMRequest.ProtoReflect.Descriptor instead.
-func (*LLMRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{22}
+// Deprecated: Use TritonInferRequest.ProtoReflect.Descriptor instead.
+func (*TritonInferRequest) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{65}
 }
 
-func (x *LLMRequest) GetId() string {
+func (x *TritonInferRequest) GetModelName() string {
 	if x != nil {
-		return x.Id
+		return x.ModelName
 	}
 	return ""
 }
 
-func (x *LLMRequest) GetText() string {
+func (x *TritonInferRequest) GetModelVersion() string {
 	if x != nil {
-		return x.Text
+		return x.ModelVersion
 	}
 	return ""
 }
 
-func (x *LLMRequest) GetMaxTokens() int32 {
+func (x *TritonInferRequest) GetId() string {
 	if x != nil {
-		return x.MaxTokens
+		return x.Id
 	}
-	return 0
+	return ""
 }
 
-func (x *LLMRequest) GetStream() bool {
+func (x *TritonInferRequest) GetInputs() []*TritonInferTensor {
 	if x != nil {
-		return x.Stream
+		return x.Inputs
 	}
-	return false
+	return nil
 }
 
-func (x *LLMRequest) GetCreatedAt() int64 {
+func (x *TritonInferRequest) GetOutputs() []*TritonRequestedOutput {
 	if x != nil {
-		return x.CreatedAt
+		return x.Outputs
 	}
-	return 0
+	return nil
 }
 
-type LLMResponse struct {
+// TritonInferTensor is an input tensor of a request or an output tensor of
+// a response; Triton sends output data in raw_output_contents instead of
+// contents
+type TritonInferTensor struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Tokens        []string               `protobuf:"bytes,2,rep,name=tokens,proto3" json:"tokens,omitempty"`
-	Summary       string                 `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"`
-	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
-	Complete      bool                   `protobuf:"varint,5,opt,name=complete,proto3" json:"complete,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Datatype      string                 `protobuf:"bytes,2,opt,name=datatype,proto3" json:"datatype,omitempty"` // INT64, INT32
+	Shape         []int64                `protobuf:"varint,3,rep,packed,name=shape,proto3" json:"shape,omitempty"`
+	Contents      *TritonTensorContents  `protobuf:"bytes,5,opt,name=contents,proto3" json:"contents,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LLMResponse) Reset() {
-	*x = LLMResponse{}
-	mi := &file_proto_search_proto_msgTypes[23]
+func (x *TritonInferTensor) Reset() {
+	*x = TritonInferTensor{}
+	mi := &file_proto_search_proto_msgTypes[66]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LLMResponse) String() string {
+func (x *TritonInferTensor) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LLMResponse) ProtoMessage() {}
+func (*TritonInferTensor) ProtoMessage() {}
 
-func (x *LLMResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[23]
+func (x *TritonInferTensor) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[66]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1612,68 +4621,61 @@ func (x *LLMResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LLMResponse.ProtoReflect.Descriptor instead.
-func (*LLMResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{23}
+// Deprecated: Use TritonInferTensor.ProtoReflect.Descriptor instead.
+func (*TritonInferTensor) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{66}
 }
 
-func (x *LLMResponse) GetId() string {
+func (x *TritonInferTensor) GetName() string {
 	if x != nil {
-		return x.Id
+		return x.Name
 	}
 	return ""
 }
 
-func (x *LLMResponse) GetTokens() []string {
-	if x != nil {
-		return x.Tokens
-	}
-	return nil
-}
-
-func (x *LLMResponse) GetSummary() string {
+func (x *TritonInferTensor) GetDatatype() string {
 	if x != nil {
-		return x.Summary
+		return x.Datatype
 	}
 	return ""
 }
 
-func (x *LLMResponse) GetError() string {
+func (x *TritonInferTensor) GetShape() []int64 {
 	if x != nil {
-		return x.Error
+		return x.Shape
 	}
-	return ""
+	return nil
 }
 
-func (x *LLMResponse) GetComplete() bool {
+func (x *TritonInferTensor) GetContents() *TritonTensorContents {
 	if x != nil {
-		return x.Complete
+		return x.Contents
 	}
-	return false
+	return nil
 }
 
-type LLMStatusRequest struct {
+type TritonRequestedOutput struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LLMStatusRequest) Reset() {
-	*x = LLMStatusRequest{}
-	mi := &file_proto_search_proto_msgTypes[24]
+func (x *TritonRequestedOutput) Reset() {
+	*x = TritonRequestedOutput{}
+	mi := &file_proto_search_proto_msgTypes[67]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LLMStatusRequest) String() string {
+func (x *TritonRequestedOutput) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LLMStatusRequest) ProtoMessage() {}
+func (*TritonRequestedOutput) ProtoMessage() {}
 
-func (x *LLMStatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[24]
+func (x *TritonRequestedOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[67]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1684,44 +4686,41 @@ func (x *LLMStatusRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LLMStatusRequest.ProtoReflect.Descriptor instead.
-func (*LLMStatusRequest) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{24}
+// Deprecated: Use TritonRequestedOutput.ProtoReflect.Descriptor instead.
+func (*TritonRequestedOutput) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{67}
 }
 
-func (x *LLMStatusRequest) GetRequestId() string {
+func (x *TritonRequestedOutput) GetName() string {
 	if x != nil {
-		return x.RequestId
+		return x.Name
 	}
 	return ""
 }
 
-type LLMStatusResponse struct {
-	state             protoimpl.MessageState `protogen:"open.v1"`
-	RequestId         string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
-	Status            string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // pending, processing, completed, failed
-	QueuePosition     int32                  `protobuf:"varint,3,opt,name=queue_position,json=queuePosition,proto3" json:"queue_position,omitempty"`
-	EstimatedWaitTime int32                  `protobuf:"varint,4,opt,name=estimated_wait_time,json=estimatedWaitTime,proto3" json:"estimated_wait_time,omitempty"` // seconds
-	Error             string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+type TritonTensorContents struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IntContents   []int32                `protobuf:"varint,2,rep,packed,name=int_contents,json=intContents,proto3" json:"int_contents,omitempty"`
+	Int64Contents []int64                `protobuf:"varint,3,rep,packed,name=int64_contents,json=int64Contents,proto3" json:"int64_contents,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LLMStatusResponse) Reset() {
-	*x = LLMStatusResponse{}
-	mi := &file_proto_search_proto_msgTypes[25]
+func (x *TritonTensorContents) Reset() {
+	*x = TritonTensorContents{}
+	mi := &file_proto_search_proto_msgTypes[68]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LLMStatusResponse) String() string {
+func (x *TritonTensorContents) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LLMStatusResponse) ProtoMessage() {}
+func (*TritonTensorContents) ProtoMessage() {}
 
-func (x *LLMStatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[25]
+func (x *TritonTensorContents) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[68]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1732,72 +4731,51 @@ func (x *LLMStatusResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LLMStatusResponse.ProtoReflect.Descriptor instead.
-func (*LLMStatusResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{25}
-}
-
-func (x *LLMStatusResponse) GetRequestId() string {
-	if x != nil {
-		return x.RequestId
-	}
-	return ""
-}
-
-func (x *LLMStatusResponse) GetStatus() string {
-	if x != nil {
-		return x.Status
-	}
-	return ""
-}
-
-func (x *LLMStatusResponse) GetQueuePosition() int32 {
-	if x != nil {
-		return x.QueuePosition
-	}
-	return 0
+// Deprecated: Use TritonTensorContents.ProtoReflect.Descriptor instead.
+func (*TritonTensorContents) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{68}
 }
 
-func (x *LLMStatusResponse) GetEstimatedWaitTime() int32 {
+func (x *TritonTensorContents) GetIntContents() []int32 {
 	if x != nil {
-		return x.EstimatedWaitTime
+		return x.IntContents
 	}
-	return 0
+	return nil
 }
 
-func (x *LLMStatusResponse) GetError() string {
+func (x *TritonTensorContents) GetInt64Contents() []int64 {
 	if x != nil {
-		return x.Error
+		return x.Int64Contents
 	}
-	return ""
+	return nil
 }
 
-type LLMStreamResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
-	IsFinal       bool                   `protobuf:"varint,3,opt,name=is_final,json=isFinal,proto3" json:"is_final,omitempty"`
-	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
-	Position      int32                  `protobuf:"varint,5,opt,name=position,proto3" json:"position,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type TritonInferResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	ModelName         string                 `protobuf:"bytes,1,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	ModelVersion      string                 `protobuf:"bytes,2,opt,name=model_version,json=modelVersion,proto3" json:"model_version,omitempty"`
+	Id                string                 `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	Outputs           []*TritonInferTensor   `protobuf:"bytes,5,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	RawOutputContents [][]byte               `protobuf:"bytes,6,rep,name=raw_output_contents,json=rawOutputContents,proto3" json:"raw_output_contents,omitempty"` // little-endian, one per output
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
-func (x *LLMStreamResponse) Reset() {
-	*x = LLMStreamResponse{}
-	mi := &file_proto_search_proto_msgTypes[26]
+func (x *TritonInferResponse) Reset() {
+	*x = TritonInferResponse{}
+	mi := &file_proto_search_proto_msgTypes[69]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LLMStreamResponse) String() string {
+func (x *TritonInferResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LLMStreamResponse) ProtoMessage() {}
+func (*TritonInferResponse) ProtoMessage() {}
 
-func (x *LLMStreamResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_search_proto_msgTypes[26]
+func (x *TritonInferResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_search_proto_msgTypes[69]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1808,44 +4786,44 @@ func (x *LLMStreamResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LLMStreamResponse.ProtoReflect.Descriptor instead.
-func (*LLMStreamResponse) Descriptor() ([]byte, []int) {
-	return file_proto_search_proto_rawDescGZIP(), []int{26}
+// Deprecated: Use TritonInferResponse.ProtoReflect.Descriptor instead.
+func (*TritonInferResponse) Descriptor() ([]byte, []int) {
+	return file_proto_search_proto_rawDescGZIP(), []int{69}
 }
 
-func (x *LLMStreamResponse) GetId() string {
+func (x *TritonInferResponse) GetModelName() string {
 	if x != nil {
-		return x.Id
+		return x.ModelName
 	}
 	return ""
 }
 
-func (x *LLMStreamResponse) GetToken() string {
+func (x *TritonInferResponse) GetModelVersion() string {
 	if x != nil {
-		return x.Token
+		return x.ModelVersion
 	}
 	return ""
 }
 
-func (x *LLMStreamResponse) GetIsFinal() bool {
+func (x *TritonInferResponse) GetId() string {
 	if x != nil {
-		return x.IsFinal
+		return x.Id
 	}
-	return false
+	return ""
 }
 
-func (x *LLMStreamResponse) GetError() string {
+func (x *TritonInferResponse) GetOutputs() []*TritonInferTensor {
 	if x != nil {
-		return x.Error
+		return x.Outputs
 	}
-	return ""
+	return nil
 }
 
-func (x *LLMStreamResponse) GetPosition() int32 {
+func (x *TritonInferResponse) GetRawOutputContents() [][]byte {
 	if x != nil {
-		return x.Position
+		return x.RawOutputContents
 	}
-	return 0
+	return nil
 }
 
 var File_proto_search_proto protoreflect.FileDescriptor
@@ -1853,28 +4831,53 @@ var File_proto_search_proto protoreflect.FileDescriptor
 const file_proto_search_proto_rawDesc = "" +
 	"\n" +
 	"\x12proto/search.proto\x12\x06search\"\x14\n" +
-	"\x12HealthCheckRequest\"e\n" +
+	"\x12HealthCheckRequest\"\xe5\x01\n" +
 	"\x13HealthCheckResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
 	"\aservice\x18\x02 \x01(\tR\aservice\x12\x1c\n" +
-	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\"g\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12B\n" +
+	"\adetails\x18\x04 \x03(\v2(.search.HealthCheckResponse.DetailsEntryR\adetails\x1a:\n" +
+	"\fDetailsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x99\x01\n" +
 	"\rSearchRequest\x12\x14\n" +
 	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1f\n" +
 	"\vsafe_search\x18\x02 \x01(\bR\n" +
 	"safeSearch\x12\x1f\n" +
 	"\vnum_results\x18\x03 \x01(\x05R\n" +
-	"numResults\"\x86\x01\n" +
+	"numResults\x12\x18\n" +
+	"\asources\x18\x04 \x03(\tR\asources\x12\x16\n" +
+	"\x06locale\x18\x05 \x01(\tR\x06locale\"\x86\x01\n" +
 	"\x0eSearchResponse\x12.\n" +
 	"\aresults\x18\x01 \x03(\v2\x14.search.SearchResultR\aresults\x12\x14\n" +
 	"\x05query\x18\x02 \x01(\tR\x05query\x12\x18\n" +
 	"\asuccess\x18\x03 \x01(\bR\asuccess\x12\x14\n" +
-	"\x05error\x18\x04 \x01(\tR\x05error\"q\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xf6\x01\n" +
 	"\fSearchResult\x12\x14\n" +
 	"\x05title\x18\x01 \x01(\tR\x05title\x12\x10\n" +
 	"\x03url\x18\x02 \x01(\tR\x03url\x12\x18\n" +
 	"\asnippet\x18\x03 \x01(\tR\asnippet\x12\x1f\n" +
 	"\vdisplay_url\x18\x04 \x01(\tR\n" +
-	"displayUrl\"\xb8\x01\n" +
+	"displayUrl\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\x12\x1a\n" +
+	"\binternal\x18\x06 \x01(\bR\binternal\x12\x14\n" +
+	"\x05score\x18\a \x01(\x01R\x05score\x12\x1b\n" +
+	"\timage_url\x18\b \x01(\tR\bimageUrl\x12\x1c\n" +
+	"\tpaywalled\x18\t \x01(\bR\tpaywalled\"G\n" +
+	"\x0eSuggestRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1f\n" +
+	"\vmax_results\x18\x02 \x01(\x05R\n" +
+	"maxResults\"3\n" +
+	"\x0fSuggestResponse\x12 \n" +
+	"\vsuggestions\x18\x01 \x03(\tR\vsuggestions\"S\n" +
+	"\x15IngestDocumentRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\"q\n" +
+	"\x16IngestDocumentResponse\x12!\n" +
+	"\fcontent_hash\x18\x01 \x01(\tR\vcontentHash\x12\x16\n" +
+	"\x06chunks\x18\x02 \x01(\x05R\x06chunks\x12\x1c\n" +
+	"\tduplicate\x18\x03 \x01(\bR\tduplicate\"\xb8\x01\n" +
 	"\x0fTokenizeRequest\x12\x12\n" +
 	"\x04text\x18\x01 \x01(\tR\x04text\x12\x1d\n" +
 	"\n" +
@@ -1883,7 +4886,7 @@ const file_proto_search_proto_rawDesc = "" +
 	"max_tokens\x18\x03 \x01(\x05R\tmaxTokens\x124\n" +
 	"\x16include_special_tokens\x18\x04 \x01(\bR\x14includeSpecialTokens\x12\x1d\n" +
 	"\n" +
-	"request_id\x18\x05 \x01(\tR\trequestId\"\xe1\x02\n" +
+	"request_id\x18\x05 \x01(\tR\trequestId\"\xe7\x03\n" +
 	"\x10TokenizeResponse\x12\x1b\n" +
 	"\ttoken_ids\x18\x01 \x03(\x05R\btokenIds\x12#\n" +
 	"\rtoken_strings\x18\x02 \x03(\tR\ftokenStrings\x12\x1f\n" +
@@ -1897,7 +4900,10 @@ const file_proto_search_proto_rawDesc = "" +
 	"\fcache_status\x18\b \x01(\tR\vcacheStatus\x12\x18\n" +
 	"\asuccess\x18\t \x01(\bR\asuccess\x12\x14\n" +
 	"\x05error\x18\n" +
-	" \x01(\tR\x05error\"j\n" +
+	" \x01(\tR\x05error\x120\n" +
+	"\x14original_token_count\x18\v \x01(\x05R\x12originalTokenCount\x12%\n" +
+	"\x0econtext_window\x18\f \x01(\x05R\rcontextWindow\x12+\n" +
+	"\x11tokenizer_version\x18\r \x01(\tR\x10tokenizerVersion\"j\n" +
 	"\x14BatchTokenizeRequest\x123\n" +
 	"\brequests\x18\x01 \x03(\v2\x17.search.TokenizeRequestR\brequests\x12\x1d\n" +
 	"\n" +
@@ -1910,14 +4916,15 @@ const file_proto_search_proto_rawDesc = "" +
 	"\fcache_misses\x18\x04 \x01(\x05R\vcacheMisses\"6\n" +
 	"\x15VocabularyInfoRequest\x12\x1d\n" +
 	"\n" +
-	"model_name\x18\x01 \x01(\tR\tmodelName\"\xa2\x01\n" +
+	"model_name\x18\x01 \x01(\tR\tmodelName\"\xcf\x01\n" +
 	"\x16VocabularyInfoResponse\x12\x1d\n" +
 	"\n" +
 	"vocab_size\x18\x01 \x01(\x05R\tvocabSize\x12%\n" +
 	"\x0especial_tokens\x18\x02 \x03(\tR\rspecialTokens\x12#\n" +
 	"\rencoding_name\x18\x03 \x01(\tR\fencodingName\x12\x1d\n" +
 	"\n" +
-	"model_name\x18\x04 \x01(\tR\tmodelName\"\x9e\x01\n" +
+	"model_name\x18\x04 \x01(\tR\tmodelName\x12+\n" +
+	"\x11tokenizer_version\x18\x05 \x01(\tR\x10tokenizerVersion\"\x9e\x01\n" +
 	"\x11DetokenizeRequest\x12\x1b\n" +
 	"\ttoken_ids\x18\x01 \x03(\x05R\btokenIds\x12\x1d\n" +
 	"\n" +
@@ -1944,7 +4951,41 @@ const file_proto_search_proto_rawDesc = "" +
 	"\x18total_processing_time_ms\x18\x02 \x01(\x02R\x15totalProcessingTimeMs\x12\x1d\n" +
 	"\n" +
 	"cache_hits\x18\x03 \x01(\x05R\tcacheHits\x12!\n" +
-	"\fcache_misses\x18\x04 \x01(\x05R\vcacheMisses\"\xcf\x01\n" +
+	"\fcache_misses\x18\x04 \x01(\x05R\vcacheMisses\"\xbb\x01\n" +
+	"\x13DecodeStreamRequest\x12\x1b\n" +
+	"\ttoken_ids\x18\x01 \x03(\x05R\btokenIds\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x02 \x01(\tR\tmodelName\x12.\n" +
+	"\x13skip_special_tokens\x18\x03 \x01(\bR\x11skipSpecialTokens\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x04 \x01(\tR\trequestId\x12\x19\n" +
+	"\bis_final\x18\x05 \x01(\bR\aisFinal\"{\n" +
+	"\x14DecodeStreamResponse\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x1f\n" +
+	"\vtoken_count\x18\x02 \x01(\x05R\n" +
+	"tokenCount\x12\x18\n" +
+	"\asuccess\x18\x03 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xf8\x02\n" +
+	"\x12BuildPromptRequest\x12\x1f\n" +
+	"\vtemplate_id\x18\x01 \x01(\tR\n" +
+	"templateId\x12G\n" +
+	"\tvariables\x18\x02 \x03(\v2).search.BuildPromptRequest.VariablesEntryR\tvariables\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x03 \x01(\tR\tmodelName\x12\x1d\n" +
+	"\n" +
+	"max_tokens\x18\x04 \x01(\x05R\tmaxTokens\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x05 \x01(\tR\trequestId\x12#\n" +
+	"\rchat_template\x18\x06 \x01(\tR\fchatTemplate\x12\x1b\n" +
+	"\tbos_token\x18\a \x01(\tR\bbosToken\x12\x1b\n" +
+	"\teos_token\x18\b \x01(\tR\beosToken\x1a<\n" +
+	"\x0eVariablesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x82\x01\n" +
+	"\x13BuildPromptResponse\x120\n" +
+	"\x06tokens\x18\x01 \x01(\v2\x18.search.TokenizeResponseR\x06tokens\x12\x16\n" +
+	"\x06prompt\x18\x02 \x01(\tR\x06prompt\x12!\n" +
+	"\fmodel_family\x18\x03 \x01(\tR\vmodelFamily\"\xde\x02\n" +
 	"\x10SummarizeRequest\x12\x1b\n" +
 	"\ttoken_ids\x18\x01 \x03(\x05R\btokenIds\x12\x1d\n" +
 	"\n" +
@@ -1954,7 +4995,22 @@ const file_proto_search_proto_rawDesc = "" +
 	"max_length\x18\x04 \x01(\x05R\tmaxLength\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x05 \x01(\tR\trequestId\x12#\n" +
-	"\roriginal_text\x18\x06 \x01(\tR\foriginalText\"\xce\x01\n" +
+	"\roriginal_text\x18\x06 \x01(\tR\foriginalText\x12,\n" +
+	"\x12prefix_token_count\x18\a \x01(\x05R\x10prefixTokenCount\x122\n" +
+	"\bsampling\x18\b \x01(\v2\x16.search.SamplingParamsR\bsampling\x12+\n" +
+	"\x11tokenizer_version\x18\t \x01(\tR\x10tokenizerVersion\"\xae\x02\n" +
+	"\x0eSamplingParams\x12%\n" +
+	"\vtemperature\x18\x01 \x01(\x02H\x00R\vtemperature\x88\x01\x01\x12\x18\n" +
+	"\x05top_p\x18\x02 \x01(\x02H\x01R\x04topP\x88\x01\x01\x120\n" +
+	"\x11frequency_penalty\x18\x03 \x01(\x02H\x02R\x10frequencyPenalty\x88\x01\x01\x12.\n" +
+	"\x10presence_penalty\x18\x04 \x01(\x02H\x03R\x0fpresencePenalty\x88\x01\x01\x12\x12\n" +
+	"\x04stop\x18\x05 \x03(\tR\x04stop\x12\x17\n" +
+	"\x04seed\x18\x06 \x01(\x03H\x04R\x04seed\x88\x01\x01B\x0e\n" +
+	"\f_temperatureB\b\n" +
+	"\x06_top_pB\x14\n" +
+	"\x12_frequency_penaltyB\x13\n" +
+	"\x11_presence_penaltyB\a\n" +
+	"\x05_seed\"\xce\x01\n" +
 	"\x11SummarizeResponse\x12\x18\n" +
 	"\asummary\x18\x01 \x01(\tR\asummary\x12\x18\n" +
 	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x14\n" +
@@ -1964,29 +5020,141 @@ const file_proto_search_proto_rawDesc = "" +
 	"\n" +
 	"confidence\x18\x05 \x01(\x02R\n" +
 	"confidence\x12.\n" +
-	"\x13generated_token_ids\x18\x06 \x03(\x05R\x11generatedTokenIds\"\xaa\x01\n" +
+	"\x13generated_token_ids\x18\x06 \x03(\x05R\x11generatedTokenIds\"\xef\x01\n" +
 	"\x17SummarizeStreamResponse\x12\x14\n" +
 	"\x05token\x18\x01 \x01(\tR\x05token\x12\x19\n" +
 	"\bis_final\x18\x02 \x01(\bR\aisFinal\x12\x14\n" +
 	"\x05error\x18\x03 \x01(\tR\x05error\x12\x1a\n" +
 	"\bposition\x18\x04 \x01(\x05R\bposition\x12,\n" +
-	"\x12generated_token_id\x18\x05 \x01(\x05R\x10generatedTokenId\"h\n" +
+	"\x12generated_token_id\x18\x05 \x01(\x05R\x10generatedTokenId\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x06 \x01(\x02R\n" +
+	"confidence\x12#\n" +
+	"\rsession_ready\x18\a \x01(\bR\fsessionReady\"C\n" +
+	"\fEmbedRequest\x12\x14\n" +
+	"\x05texts\x18\x01 \x03(\tR\x05texts\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x02 \x01(\tR\tmodelName\"#\n" +
+	"\tEmbedding\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\x02R\x06values\"a\n" +
+	"\rEmbedResponse\x121\n" +
+	"\n" +
+	"embeddings\x18\x01 \x03(\v2\x11.search.EmbeddingR\n" +
+	"embeddings\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x02 \x01(\tR\tmodelName\"}\n" +
+	"\x16ExtractEntitiesRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12.\n" +
+	"\aresults\x18\x02 \x03(\v2\x14.search.SearchResultR\aresults\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x03 \x01(\tR\tmodelName\";\n" +
+	"\x0fEntityAttribute\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"\xc7\x01\n" +
+	"\x06Entity\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x127\n" +
+	"\n" +
+	"attributes\x18\x04 \x03(\v2\x17.search.EntityAttributeR\n" +
+	"attributes\x12\x1b\n" +
+	"\timage_url\x18\x05 \x01(\tR\bimageUrl\x12\x1d\n" +
+	"\n" +
+	"source_url\x18\x06 \x01(\tR\tsourceUrl\"`\n" +
+	"\x17ExtractEntitiesResponse\x12&\n" +
+	"\x06entity\x18\x01 \x01(\v2\x0e.search.EntityR\x06entity\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x02 \x01(\tR\tmodelName\"o\n" +
+	"\x1cPolishRelatedSearchesRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1a\n" +
+	"\bsearches\x18\x02 \x03(\tR\bsearches\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x03 \x01(\tR\tmodelName\"Z\n" +
+	"\x1dPolishRelatedSearchesResponse\x12\x1a\n" +
+	"\bsearches\x18\x01 \x03(\tR\bsearches\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x02 \x01(\tR\tmodelName\"b\n" +
+	"\rRerankRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1c\n" +
+	"\tdocuments\x18\x02 \x03(\tR\tdocuments\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x03 \x01(\tR\tmodelName\"G\n" +
+	"\x0eRerankResponse\x12\x16\n" +
+	"\x06scores\x18\x01 \x03(\x02R\x06scores\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x02 \x01(\tR\tmodelName\"V\n" +
+	"\x12SwitchModelRequest\x12\x18\n" +
+	"\abackend\x18\x01 \x01(\tR\abackend\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\"\xff\x01\n" +
+	"\x13SwitchModelResponse\x12\x1a\n" +
+	"\bswitched\x18\x01 \x01(\bR\bswitched\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\x12%\n" +
+	"\x0eprevious_model\x18\x05 \x01(\tR\rpreviousModel\x12!\n" +
+	"\fprevious_url\x18\x06 \x01(\tR\vpreviousUrl\x12#\n" +
+	"\rcanary_output\x18\a \x01(\tR\fcanaryOutput\x12\x1f\n" +
+	"\vwatch_until\x18\b \x01(\x03R\n" +
+	"watchUntil\"\x13\n" +
+	"\x11ListModelsRequest\"\x86\x01\n" +
+	"\tModelInfo\x12\x18\n" +
+	"\abackend\x18\x01 \x01(\tR\abackend\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x12\x16\n" +
+	"\x06warmed\x18\x04 \x01(\bR\x06warmed\x12\x1f\n" +
+	"\vwatch_until\x18\x05 \x01(\x03R\n" +
+	"watchUntil\"?\n" +
+	"\x12ListModelsResponse\x12)\n" +
+	"\x06models\x18\x01 \x03(\v2\x11.search.ModelInfoR\x06models\"\x93\x01\n" +
+	"\fSafetyPolicy\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rblocked_terms\x18\x02 \x03(\tR\fblockedTerms\x12)\n" +
+	"\x10blocked_patterns\x18\x03 \x03(\tR\x0fblockedPatterns\x12#\n" +
+	"\rallowed_terms\x18\x04 \x03(\tR\fallowedTerms\"\xbb\x01\n" +
 	"\x14ValidateInputRequest\x12\x12\n" +
 	"\x04text\x18\x01 \x01(\tR\x04text\x12\x1b\n" +
 	"\tclient_ip\x18\x02 \x01(\tR\bclientIp\x12\x1f\n" +
 	"\vsafe_search\x18\x03 \x01(\bR\n" +
-	"safeSearch\"\x89\x01\n" +
+	"safeSearch\x12#\n" +
+	"\rblocked_terms\x18\x04 \x03(\tR\fblockedTerms\x12,\n" +
+	"\x06policy\x18\x05 \x01(\v2\x14.search.SafetyPolicyR\x06policy\"\x89\x01\n" +
 	"\x15ValidateInputResponse\x12\x17\n" +
 	"\ais_safe\x18\x01 \x01(\bR\x06isSafe\x12%\n" +
 	"\x0esanitized_text\x18\x02 \x01(\tR\rsanitizedText\x12\x1a\n" +
 	"\bwarnings\x18\x03 \x03(\tR\bwarnings\x12\x14\n" +
-	"\x05error\x18\x04 \x01(\tR\x05error\"+\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"Y\n" +
 	"\x15SanitizeOutputRequest\x12\x12\n" +
-	"\x04text\x18\x01 \x01(\tR\x04text\"q\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12,\n" +
+	"\x06policy\x18\x02 \x01(\v2\x14.search.SafetyPolicyR\x06policy\"\x90\x02\n" +
 	"\x16SanitizeOutputResponse\x12%\n" +
 	"\x0esanitized_text\x18\x01 \x01(\tR\rsanitizedText\x12\x1a\n" +
 	"\bwarnings\x18\x02 \x03(\tR\bwarnings\x12\x14\n" +
-	"\x05error\x18\x03 \x01(\tR\x05error\"\x86\x01\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12H\n" +
+	"\btoxicity\x18\x04 \x03(\v2,.search.SanitizeOutputResponse.ToxicityEntryR\btoxicity\x12\x16\n" +
+	"\x06action\x18\x05 \x01(\tR\x06action\x1a;\n" +
+	"\rToxicityEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x02R\x05value:\x028\x01\"\x9d\x01\n" +
+	"\x19BatchValidateInputRequest\x12\x14\n" +
+	"\x05texts\x18\x01 \x03(\tR\x05texts\x12\x1b\n" +
+	"\tclient_ip\x18\x02 \x01(\tR\bclientIp\x12\x1f\n" +
+	"\vsafe_search\x18\x03 \x01(\bR\n" +
+	"safeSearch\x12,\n" +
+	"\x06policy\x18\x04 \x01(\v2\x14.search.SafetyPolicyR\x06policy\"U\n" +
+	"\x1aBatchValidateInputResponse\x127\n" +
+	"\aresults\x18\x01 \x03(\v2\x1d.search.ValidateInputResponseR\aresults\"`\n" +
+	"\x1aBatchSanitizeOutputRequest\x12\x14\n" +
+	"\x05texts\x18\x01 \x03(\tR\x05texts\x12,\n" +
+	"\x06policy\x18\x02 \x01(\v2\x14.search.SafetyPolicyR\x06policy\"W\n" +
+	"\x1bBatchSanitizeOutputResponse\x128\n" +
+	"\aresults\x18\x01 \x03(\v2\x1e.search.SanitizeOutputResponseR\aresults\"\x8f\x01\n" +
+	"\x18FilterSuggestionsRequest\x12 \n" +
+	"\vsuggestions\x18\x01 \x03(\tR\vsuggestions\x12#\n" +
+	"\rblocked_terms\x18\x02 \x03(\tR\fblockedTerms\x12,\n" +
+	"\x06policy\x18\x03 \x01(\v2\x14.search.SafetyPolicyR\x06policy\"5\n" +
+	"\x19FilterSuggestionsResponse\x12\x18\n" +
+	"\aallowed\x18\x01 \x03(\tR\aallowed\"\xb4\x02\n" +
 	"\n" +
 	"LLMRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
@@ -1995,13 +5163,27 @@ const file_proto_search_proto_rawDesc = "" +
 	"max_tokens\x18\x03 \x01(\x05R\tmaxTokens\x12\x16\n" +
 	"\x06stream\x18\x04 \x01(\bR\x06stream\x12\x1d\n" +
 	"\n" +
-	"created_at\x18\x05 \x01(\x03R\tcreatedAt\"\x81\x01\n" +
+	"created_at\x18\x05 \x01(\x03R\tcreatedAt\x12\"\n" +
+	"\fcontinuation\x18\x06 \x01(\tR\fcontinuation\x12&\n" +
+	"\x0fprior_llm_calls\x18\a \x01(\x05R\rpriorLlmCalls\x12\x14\n" +
+	"\x05model\x18\b \x01(\tR\x05model\x122\n" +
+	"\bsampling\x18\t \x01(\v2\x16.search.SamplingParamsR\bsampling\x12\x16\n" +
+	"\x06shadow\x18\n" +
+	" \x01(\bR\x06shadow\"\xa3\x02\n" +
 	"\vLLMResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
 	"\x06tokens\x18\x02 \x03(\tR\x06tokens\x12\x18\n" +
 	"\asummary\x18\x03 \x01(\tR\asummary\x12\x14\n" +
 	"\x05error\x18\x04 \x01(\tR\x05error\x12\x1a\n" +
-	"\bcomplete\x18\x05 \x01(\bR\bcomplete\"1\n" +
+	"\bcomplete\x18\x05 \x01(\bR\bcomplete\x12-\n" +
+	"\x06budget\x18\x06 \x01(\v2\x15.search.BudgetOutcomeR\x06budget\x12)\n" +
+	"\x10model_confidence\x18\a \x01(\x02R\x0fmodelConfidence\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\b \x01(\tR\terrorCode\x12'\n" +
+	"\x0finput_truncated\x18\t \x01(\bR\x0einputTruncated\"A\n" +
+	"\rBudgetOutcome\x12\x16\n" +
+	"\x06limits\x18\x01 \x03(\tR\x06limits\x12\x18\n" +
+	"\askipped\x18\x02 \x03(\tR\askipped\"1\n" +
 	"\x10LLMStatusRequest\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\"\xb7\x01\n" +
@@ -2011,37 +5193,120 @@ const file_proto_search_proto_rawDesc = "" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12%\n" +
 	"\x0equeue_position\x18\x03 \x01(\x05R\rqueuePosition\x12.\n" +
 	"\x13estimated_wait_time\x18\x04 \x01(\x05R\x11estimatedWaitTime\x12\x14\n" +
-	"\x05error\x18\x05 \x01(\tR\x05error\"\x86\x01\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\xcd\x03\n" +
 	"\x11LLMStreamResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
 	"\x05token\x18\x02 \x01(\tR\x05token\x12\x19\n" +
 	"\bis_final\x18\x03 \x01(\bR\aisFinal\x12\x14\n" +
 	"\x05error\x18\x04 \x01(\tR\x05error\x12\x1a\n" +
-	"\bposition\x18\x05 \x01(\x05R\bposition2\x90\x01\n" +
+	"\bposition\x18\x05 \x01(\x05R\bposition\x12-\n" +
+	"\x06budget\x18\x06 \x01(\v2\x15.search.BudgetOutcomeR\x06budget\x12)\n" +
+	"\x10model_confidence\x18\a \x01(\x02R\x0fmodelConfidence\x12\x1c\n" +
+	"\ttruncated\x18\b \x01(\bR\ttruncated\x12)\n" +
+	"\x10truncated_reason\x18\t \x01(\tR\x0ftruncatedReason\x12\x1c\n" +
+	"\trecovered\x18\n" +
+	" \x01(\bR\trecovered\x12\x1e\n" +
+	"\n" +
+	"generation\x18\v \x01(\x05R\n" +
+	"generation\x12\x1c\n" +
+	"\tcancelled\x18\f \x01(\bR\tcancelled\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\r \x01(\tR\terrorCode\x12'\n" +
+	"\x0finput_truncated\x18\x0e \x01(\bR\x0einputTruncated\"t\n" +
+	"\x11LLMSessionMessage\x12(\n" +
+	"\x05start\x18\x01 \x01(\v2\x12.search.LLMRequestR\x05start\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\tR\x06action\x12\x1d\n" +
+	"\n" +
+	"max_tokens\x18\x03 \x01(\x05R\tmaxTokens\"\xee\x01\n" +
+	"\x13VLLMGenerateRequest\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12\x1b\n" +
+	"\ttoken_ids\x18\x03 \x03(\x05R\btokenIds\x12\x16\n" +
+	"\x06prompt\x18\x04 \x01(\tR\x06prompt\x12\x1d\n" +
+	"\n" +
+	"max_tokens\x18\x05 \x01(\x05R\tmaxTokens\x122\n" +
+	"\bsampling\x18\x06 \x01(\v2\x16.search.SamplingParamsR\bsampling\x12\x1a\n" +
+	"\blogprobs\x18\a \x01(\x05R\blogprobs\"\xe5\x01\n" +
+	"\x14VLLMGenerateResponse\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x1b\n" +
+	"\ttoken_ids\x18\x02 \x03(\x05R\btokenIds\x12%\n" +
+	"\x0etoken_logprobs\x18\x03 \x03(\x02R\rtokenLogprobs\x12#\n" +
+	"\rfinish_reason\x18\x04 \x01(\tR\ffinishReason\x12#\n" +
+	"\rprompt_tokens\x18\x05 \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x06 \x01(\x05R\x10completionTokens\"G\n" +
+	"\x17TritonModelReadyRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\"0\n" +
+	"\x18TritonModelReadyResponse\x12\x14\n" +
+	"\x05ready\x18\x01 \x01(\bR\x05ready\"\xd4\x01\n" +
+	"\x12TritonInferRequest\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x01 \x01(\tR\tmodelName\x12#\n" +
+	"\rmodel_version\x18\x02 \x01(\tR\fmodelVersion\x12\x0e\n" +
+	"\x02id\x18\x03 \x01(\tR\x02id\x121\n" +
+	"\x06inputs\x18\x05 \x03(\v2\x19.search.TritonInferTensorR\x06inputs\x127\n" +
+	"\aoutputs\x18\x06 \x03(\v2\x1d.search.TritonRequestedOutputR\aoutputs\"\x93\x01\n" +
+	"\x11TritonInferTensor\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bdatatype\x18\x02 \x01(\tR\bdatatype\x12\x14\n" +
+	"\x05shape\x18\x03 \x03(\x03R\x05shape\x128\n" +
+	"\bcontents\x18\x05 \x01(\v2\x1c.search.TritonTensorContentsR\bcontents\"+\n" +
+	"\x15TritonRequestedOutput\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"`\n" +
+	"\x14TritonTensorContents\x12!\n" +
+	"\fint_contents\x18\x02 \x03(\x05R\vintContents\x12%\n" +
+	"\x0eint64_contents\x18\x03 \x03(\x03R\rint64Contents\"\xce\x01\n" +
+	"\x13TritonInferResponse\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x01 \x01(\tR\tmodelName\x12#\n" +
+	"\rmodel_version\x18\x02 \x01(\tR\fmodelVersion\x12\x0e\n" +
+	"\x02id\x18\x03 \x01(\tR\x02id\x123\n" +
+	"\aoutputs\x18\x05 \x03(\v2\x19.search.TritonInferTensorR\aoutputs\x12.\n" +
+	"\x13raw_output_contents\x18\x06 \x03(\fR\x11rawOutputContents2\x9d\x02\n" +
 	"\rSearchService\x127\n" +
-	"\x06Search\x12\x15.search.SearchRequest\x1a\x16.search.SearchResponse\x12F\n" +
-	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\xd4\x03\n" +
+	"\x06Search\x12\x15.search.SearchRequest\x1a\x16.search.SearchResponse\x12O\n" +
+	"\x0eIngestDocument\x12\x1d.search.IngestDocumentRequest\x1a\x1e.search.IngestDocumentResponse\x12:\n" +
+	"\aSuggest\x12\x16.search.SuggestRequest\x1a\x17.search.SuggestResponse\x12F\n" +
+	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\xeb\x04\n" +
 	"\x10TokenizerService\x12=\n" +
 	"\bTokenize\x12\x17.search.TokenizeRequest\x1a\x18.search.TokenizeResponse\x12L\n" +
 	"\rBatchTokenize\x12\x1c.search.BatchTokenizeRequest\x1a\x1d.search.BatchTokenizeResponse\x12R\n" +
 	"\x11GetVocabularyInfo\x12\x1d.search.VocabularyInfoRequest\x1a\x1e.search.VocabularyInfoResponse\x12C\n" +
 	"\n" +
 	"Detokenize\x12\x19.search.DetokenizeRequest\x1a\x1a.search.DetokenizeResponse\x12R\n" +
-	"\x0fBatchDetokenize\x12\x1e.search.BatchDetokenizeRequest\x1a\x1f.search.BatchDetokenizeResponse\x12F\n" +
-	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\xec\x01\n" +
+	"\x0fBatchDetokenize\x12\x1e.search.BatchDetokenizeRequest\x1a\x1f.search.BatchDetokenizeResponse\x12M\n" +
+	"\fDecodeStream\x12\x1b.search.DecodeStreamRequest\x1a\x1c.search.DecodeStreamResponse(\x010\x01\x12F\n" +
+	"\vBuildPrompt\x12\x1a.search.BuildPromptRequest\x1a\x1b.search.BuildPromptResponse\x12F\n" +
+	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\xf5\x05\n" +
 	"\x10InferenceService\x12@\n" +
 	"\tSummarize\x12\x18.search.SummarizeRequest\x1a\x19.search.SummarizeResponse\x12N\n" +
-	"\x0fSummarizeStream\x12\x18.search.SummarizeRequest\x1a\x1f.search.SummarizeStreamResponse0\x01\x12F\n" +
-	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\xf6\x01\n" +
+	"\x0fSummarizeStream\x12\x18.search.SummarizeRequest\x1a\x1f.search.SummarizeStreamResponse0\x01\x12Q\n" +
+	"\x10SummarizeSession\x12\x18.search.SummarizeRequest\x1a\x1f.search.SummarizeStreamResponse(\x010\x01\x124\n" +
+	"\x05Embed\x12\x14.search.EmbedRequest\x1a\x15.search.EmbedResponse\x12R\n" +
+	"\x0fExtractEntities\x12\x1e.search.ExtractEntitiesRequest\x1a\x1f.search.ExtractEntitiesResponse\x12d\n" +
+	"\x15PolishRelatedSearches\x12$.search.PolishRelatedSearchesRequest\x1a%.search.PolishRelatedSearchesResponse\x127\n" +
+	"\x06Rerank\x12\x15.search.RerankRequest\x1a\x16.search.RerankResponse\x12F\n" +
+	"\vSwitchModel\x12\x1a.search.SwitchModelRequest\x1a\x1b.search.SwitchModelResponse\x12C\n" +
+	"\n" +
+	"ListModels\x12\x19.search.ListModelsRequest\x1a\x1a.search.ListModelsResponse\x12F\n" +
+	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\x8d\x04\n" +
 	"\rSafetyService\x12L\n" +
 	"\rValidateInput\x12\x1c.search.ValidateInputRequest\x1a\x1d.search.ValidateInputResponse\x12O\n" +
-	"\x0eSanitizeOutput\x12\x1d.search.SanitizeOutputRequest\x1a\x1e.search.SanitizeOutputResponse\x12F\n" +
-	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\x9f\x02\n" +
+	"\x0eSanitizeOutput\x12\x1d.search.SanitizeOutputRequest\x1a\x1e.search.SanitizeOutputResponse\x12X\n" +
+	"\x11FilterSuggestions\x12 .search.FilterSuggestionsRequest\x1a!.search.FilterSuggestionsResponse\x12[\n" +
+	"\x12BatchValidateInput\x12!.search.BatchValidateInputRequest\x1a\".search.BatchValidateInputResponse\x12^\n" +
+	"\x13BatchSanitizeOutput\x12\".search.BatchSanitizeOutputRequest\x1a#.search.BatchSanitizeOutputResponse\x12F\n" +
+	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\xea\x02\n" +
 	"\x16LLMOrchestratorService\x129\n" +
 	"\x0eProcessRequest\x12\x12.search.LLMRequest\x1a\x13.search.LLMResponse\x12@\n" +
-	"\rStreamRequest\x12\x12.search.LLMRequest\x1a\x19.search.LLMStreamResponse0\x01\x12@\n" +
+	"\rStreamRequest\x12\x12.search.LLMRequest\x1a\x19.search.LLMStreamResponse0\x01\x12I\n" +
+	"\rStreamSession\x12\x19.search.LLMSessionMessage\x1a\x19.search.LLMStreamResponse(\x010\x01\x12@\n" +
 	"\tGetStatus\x12\x18.search.LLMStatusRequest\x1a\x19.search.LLMStatusResponse\x12F\n" +
-	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponseB\tZ\a./protob\x06proto3"
+	"\vHealthCheck\x12\x1a.search.HealthCheckRequest\x1a\x1b.search.HealthCheckResponse2\xad\x01\n" +
+	"\x15VLLMGenerationService\x12E\n" +
+	"\bGenerate\x12\x1b.search.VLLMGenerateRequest\x1a\x1c.search.VLLMGenerateResponse\x12M\n" +
+	"\x0eGenerateStream\x12\x1b.search.VLLMGenerateRequest\x1a\x1c.search.VLLMGenerateResponse0\x01B\tZ\a./protob\x06proto3"
 
 var (
 	file_proto_search_proto_rawDescOnce sync.Once
@@ -2055,83 +5320,189 @@ func file_proto_search_proto_rawDescGZIP() []byte {
 	return file_proto_search_proto_rawDescData
 }
 
-var file_proto_search_proto_msgTypes = make([]protoimpl.MessageInfo, 27)
+var file_proto_search_proto_msgTypes = make([]protoimpl.MessageInfo, 73)
 var file_proto_search_proto_goTypes = []any{
-	(*HealthCheckRequest)(nil),      // 0: search.HealthCheckRequest
-	(*HealthCheckResponse)(nil),     // 1: search.HealthCheckResponse
-	(*SearchRequest)(nil),           // 2: search.SearchRequest
-	(*SearchResponse)(nil),          // 3: search.SearchResponse
-	(*SearchResult)(nil),            // 4: search.SearchResult
-	(*TokenizeRequest)(nil),         // 5: search.TokenizeRequest
-	(*TokenizeResponse)(nil),        // 6: search.TokenizeResponse
-	(*BatchTokenizeRequest)(nil),    // 7: search.BatchTokenizeRequest
-	(*BatchTokenizeResponse)(nil),   // 8: search.BatchTokenizeResponse
-	(*VocabularyInfoRequest)(nil),   // 9: search.VocabularyInfoRequest
-	(*VocabularyInfoResponse)(nil),  // 10: search.VocabularyInfoResponse
-	(*DetokenizeRequest)(nil),       // 11: search.DetokenizeRequest
-	(*DetokenizeResponse)(nil),      // 12: search.DetokenizeResponse
-	(*BatchDetokenizeRequest)(nil),  // 13: search.BatchDetokenizeRequest
-	(*BatchDetokenizeResponse)(nil), // 14: search.BatchDetokenizeResponse
-	(*SummarizeRequest)(nil),        // 15: search.SummarizeRequest
-	(*SummarizeResponse)(nil),       // 16: search.SummarizeResponse
-	(*SummarizeStreamResponse)(nil), // 17: search.SummarizeStreamResponse
-	(*ValidateInputRequest)(nil),    // 18: search.ValidateInputRequest
-	(*ValidateInputResponse)(nil),   // 19: search.ValidateInputResponse
-	(*SanitizeOutputRequest)(nil),   // 20: search.SanitizeOutputRequest
-	(*SanitizeOutputResponse)(nil),  // 21: search.SanitizeOutputResponse
-	(*LLMRequest)(nil),              // 22: search.LLMRequest
-	(*LLMResponse)(nil),             // 23: search.LLMResponse
-	(*LLMStatusRequest)(nil),        // 24: search.LLMStatusRequest
-	(*LLMStatusResponse)(nil),       // 25: search.LLMStatusResponse
-	(*LLMStreamResponse)(nil),       // 26: search.LLMStreamResponse
+	(*HealthCheckRequest)(nil),            // 0: search.HealthCheckRequest
+	(*HealthCheckResponse)(nil),           // 1: search.HealthCheckResponse
+	(*SearchRequest)(nil),                 // 2: search.SearchRequest
+	(*SearchResponse)(nil),                // 3: search.SearchResponse
+	(*SearchResult)(nil),                  // 4: search.SearchResult
+	(*SuggestRequest)(nil),                // 5: search.SuggestRequest
+	(*SuggestResponse)(nil),               // 6: search.SuggestResponse
+	(*IngestDocumentRequest)(nil),         // 7: search.IngestDocumentRequest
+	(*IngestDocumentResponse)(nil),        // 8: search.IngestDocumentResponse
+	(*TokenizeRequest)(nil),               // 9: search.TokenizeRequest
+	(*TokenizeResponse)(nil),              // 10: search.TokenizeResponse
+	(*BatchTokenizeRequest)(nil),          // 11: search.BatchTokenizeRequest
+	(*BatchTokenizeResponse)(nil),         // 12: search.BatchTokenizeResponse
+	(*VocabularyInfoRequest)(nil),         // 13: search.VocabularyInfoRequest
+	(*VocabularyInfoResponse)(nil),        // 14: search.VocabularyInfoResponse
+	(*DetokenizeRequest)(nil),             // 15: search.DetokenizeRequest
+	(*DetokenizeResponse)(nil),            // 16: search.DetokenizeResponse
+	(*BatchDetokenizeRequest)(nil),        // 17: search.BatchDetokenizeRequest
+	(*BatchDetokenizeResponse)(nil),       // 18: search.BatchDetokenizeResponse
+	(*DecodeStreamRequest)(nil),           // 19: search.DecodeStreamRequest
+	(*DecodeStreamResponse)(nil),          // 20: search.DecodeStreamResponse
+	(*BuildPromptRequest)(nil),            // 21: search.BuildPromptRequest
+	(*BuildPromptResponse)(nil),           // 22: search.BuildPromptResponse
+	(*SummarizeRequest)(nil),              // 23: search.SummarizeRequest
+	(*SamplingParams)(nil),                // 24: search.SamplingParams
+	(*SummarizeResponse)(nil),             // 25: search.SummarizeResponse
+	(*SummarizeStreamResponse)(nil),       // 26: search.SummarizeStreamResponse
+	(*EmbedRequest)(nil),                  // 27: search.EmbedRequest
+	(*Embedding)(nil),                     // 28: search.Embedding
+	(*EmbedResponse)(nil),                 // 29: search.EmbedResponse
+	(*ExtractEntitiesRequest)(nil),        // 30: search.ExtractEntitiesRequest
+	(*EntityAttribute)(nil),               // 31: search.EntityAttribute
+	(*Entity)(nil),                        // 32: search.Entity
+	(*ExtractEntitiesResponse)(nil),       // 33: search.ExtractEntitiesResponse
+	(*PolishRelatedSearchesRequest)(nil),  // 34: search.PolishRelatedSearchesRequest
+	(*PolishRelatedSearchesResponse)(nil), // 35: search.PolishRelatedSearchesResponse
+	(*RerankRequest)(nil),                 // 36: search.RerankRequest
+	(*RerankResponse)(nil),                // 37: search.RerankResponse
+	(*SwitchModelRequest)(nil),            // 38: search.SwitchModelRequest
+	(*SwitchModelResponse)(nil),           // 39: search.SwitchModelResponse
+	(*ListModelsRequest)(nil),             // 40: search.ListModelsRequest
+	(*ModelInfo)(nil),                     // 41: search.ModelInfo
+	(*ListModelsResponse)(nil),            // 42: search.ListModelsResponse
+	(*SafetyPolicy)(nil),                  // 43: search.SafetyPolicy
+	(*ValidateInputRequest)(nil),          // 44: search.ValidateInputRequest
+	(*ValidateInputResponse)(nil),         // 45: search.ValidateInputResponse
+	(*SanitizeOutputRequest)(nil),         // 46: search.SanitizeOutputRequest
+	(*SanitizeOutputResponse)(nil),        // 47: search.SanitizeOutputResponse
+	(*BatchValidateInputRequest)(nil),     // 48: search.BatchValidateInputRequest
+	(*BatchValidateInputResponse)(nil),    // 49: search.BatchValidateInputResponse
+	(*BatchSanitizeOutputRequest)(nil),    // 50: search.BatchSanitizeOutputRequest
+	(*BatchSanitizeOutputResponse)(nil),   // 51: search.BatchSanitizeOutputResponse
+	(*FilterSuggestionsRequest)(nil),      // 52: search.FilterSuggestionsRequest
+	(*FilterSuggestionsResponse)(nil),     // 53: search.FilterSuggestionsResponse
+	(*LLMRequest)(nil),                    // 54: search.LLMRequest
+	(*LLMResponse)(nil),                   // 55: search.LLMResponse
+	(*BudgetOutcome)(nil),                 // 56: search.BudgetOutcome
+	(*LLMStatusRequest)(nil),              // 57: search.LLMStatusRequest
+	(*LLMStatusResponse)(nil),             // 58: search.LLMStatusResponse
+	(*LLMStreamResponse)(nil),             // 59: search.LLMStreamResponse
+	(*LLMSessionMessage)(nil),             // 60: search.LLMSessionMessage
+	(*VLLMGenerateRequest)(nil),           // 61: search.VLLMGenerateRequest
+	(*VLLMGenerateResponse)(nil),          // 62: search.VLLMGenerateResponse
+	(*TritonModelReadyRequest)(nil),       // 63: search.TritonModelReadyRequest
+	(*TritonModelReadyResponse)(nil),      // 64: search.TritonModelReadyResponse
+	(*TritonInferRequest)(nil),            // 65: search.TritonInferRequest
+	(*TritonInferTensor)(nil),             // 66: search.TritonInferTensor
+	(*TritonRequestedOutput)(nil),         // 67: search.TritonRequestedOutput
+	(*TritonTensorContents)(nil),          // 68: search.TritonTensorContents
+	(*TritonInferResponse)(nil),           // 69: search.TritonInferResponse
+	nil,                                   // 70: search.HealthCheckResponse.DetailsEntry
+	nil,                                   // 71: search.BuildPromptRequest.VariablesEntry
+	nil,                                   // 72: search.SanitizeOutputResponse.ToxicityEntry
 }
 var file_proto_search_proto_depIdxs = []int32{
-	4,  // 0: search.SearchResponse.results:type_name -> search.SearchResult
-	5,  // 1: search.BatchTokenizeRequest.requests:type_name -> search.TokenizeRequest
-	6,  // 2: search.BatchTokenizeResponse.responses:type_name -> search.TokenizeResponse
-	11, // 3: search.BatchDetokenizeRequest.requests:type_name -> search.DetokenizeRequest
-	12, // 4: search.BatchDetokenizeResponse.responses:type_name -> search.DetokenizeResponse
-	2,  // 5: search.SearchService.Search:input_type -> search.SearchRequest
-	0,  // 6: search.SearchService.HealthCheck:input_type -> search.HealthCheckRequest
-	5,  // 7: search.TokenizerService.Tokenize:input_type -> search.TokenizeRequest
-	7,  // 8: search.TokenizerService.BatchTokenize:input_type -> search.BatchTokenizeRequest
-	9,  // 9: search.TokenizerService.GetVocabularyInfo:input_type -> search.VocabularyInfoRequest
-	11, // 10: search.TokenizerService.Detokenize:input_type -> search.DetokenizeRequest
-	13, // 11: search.TokenizerService.BatchDetokenize:input_type -> search.BatchDetokenizeRequest
-	0,  // 12: search.TokenizerService.HealthCheck:input_type -> search.HealthCheckRequest
-	15, // 13: search.InferenceService.Summarize:input_type -> search.SummarizeRequest
-	15, // 14: search.InferenceService.SummarizeStream:input_type -> search.SummarizeRequest
-	0,  // 15: search.InferenceService.HealthCheck:input_type -> search.HealthCheckRequest
-	18, // 16: search.SafetyService.ValidateInput:input_type -> search.ValidateInputRequest
-	20, // 17: search.SafetyService.SanitizeOutput:input_type -> search.SanitizeOutputRequest
-	0,  // 18: search.SafetyService.HealthCheck:input_type -> search.HealthCheckRequest
-	22, // 19: search.LLMOrchestratorService.ProcessRequest:input_type -> search.LLMRequest
-	22, // 20: search.LLMOrchestratorService.StreamRequest:input_type -> search.LLMRequest
-	24, // 21: search.LLMOrchestratorService.GetStatus:input_type -> search.LLMStatusRequest
-	0,  // 22: search.LLMOrchestratorService.HealthCheck:input_type -> search.HealthCheckRequest
-	3,  // 23: search.SearchService.Search:output_type -> search.SearchResponse
-	1,  // 24: search.SearchService.HealthCheck:output_type -> search.HealthCheckResponse
-	6,  // 25: search.TokenizerService.Tokenize:output_type -> search.TokenizeResponse
-	8,  // 26: search.TokenizerService.BatchTokenize:output_type -> search.BatchTokenizeResponse
-	10, // 27: search.TokenizerService.GetVocabularyInfo:output_type -> search.VocabularyInfoResponse
-	12, // 28: search.TokenizerService.Detokenize:output_type -> search.DetokenizeResponse
-	14, // 29: search.TokenizerService.BatchDetokenize:output_type -> search.BatchDetokenizeResponse
-	1,  // 30: search.TokenizerService.HealthCheck:output_type -> search.HealthCheckResponse
-	16, // 31: search.InferenceService.Summarize:output_type -> search.SummarizeResponse
-	17, // 32: search.InferenceService.SummarizeStream:output_type -> search.SummarizeStreamResponse
-	1,  // 33: search.InferenceService.HealthCheck:output_type -> search.HealthCheckResponse
-	19, // 34: search.SafetyService.ValidateInput:output_type -> search.ValidateInputResponse
-	21, // 35: search.SafetyService.SanitizeOutput:output_type -> search.SanitizeOutputResponse
-	1,  // 36: search.SafetyService.HealthCheck:output_type -> search.HealthCheckResponse
-	23, // 37: search.LLMOrchestratorService.ProcessRequest:output_type -> search.LLMResponse
-	26, // 38: search.LLMOrchestratorService.StreamRequest:output_type -> search.LLMStreamResponse
-	25, // 39: search.LLMOrchestratorService.GetStatus:output_type -> search.LLMStatusResponse
-	1,  // 40: search.LLMOrchestratorService.HealthCheck:output_type -> search.HealthCheckResponse
-	23, // [23:41] is the sub-list for method output_type
-	5,  // [5:23] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+	70, // 0: search.HealthCheckResponse.details:type_name -> search.HealthCheckResponse.DetailsEntry
+	4,  // 1: search.SearchResponse.results:type_name -> search.SearchResult
+	9,  // 2: search.BatchTokenizeRequest.requests:type_name -> search.TokenizeRequest
+	10, // 3: search.BatchTokenizeResponse.responses:type_name -> search.TokenizeResponse
+	15, // 4: search.BatchDetokenizeRequest.requests:type_name -> search.DetokenizeRequest
+	16, // 5: search.BatchDetokenizeResponse.responses:type_name -> search.DetokenizeResponse
+	71, // 6: search.BuildPromptRequest.variables:type_name -> search.BuildPromptRequest.VariablesEntry
+	10, // 7: search.BuildPromptResponse.tokens:type_name -> search.TokenizeResponse
+	24, // 8: search.SummarizeRequest.sampling:type_name -> search.SamplingParams
+	28, // 9: search.EmbedResponse.embeddings:type_name -> search.Embedding
+	4,  // 10: search.ExtractEntitiesRequest.results:type_name -> search.SearchResult
+	31, // 11: search.Entity.attributes:type_name -> search.EntityAttribute
+	32, // 12: search.ExtractEntitiesResponse.entity:type_name -> search.Entity
+	41, // 13: search.ListModelsResponse.models:type_name -> search.ModelInfo
+	43, // 14: search.ValidateInputRequest.policy:type_name -> search.SafetyPolicy
+	43, // 15: search.SanitizeOutputRequest.policy:type_name -> search.SafetyPolicy
+	72, // 16: search.SanitizeOutputResponse.toxicity:type_name -> search.SanitizeOutputResponse.ToxicityEntry
+	43, // 17: search.BatchValidateInputRequest.policy:type_name -> search.SafetyPolicy
+	45, // 18: search.BatchValidateInputResponse.results:type_name -> search.ValidateInputResponse
+	43, // 19: search.BatchSanitizeOutputRequest.policy:type_name -> search.SafetyPolicy
+	47, // 20: search.BatchSanitizeOutputResponse.results:type_name -> search.SanitizeOutputResponse
+	43, // 21: search.FilterSuggestionsRequest.policy:type_name -> search.SafetyPolicy
+	24, // 22: search.LLMRequest.sampling:type_name -> search.SamplingParams
+	56, // 23: search.LLMResponse.budget:type_name -> search.BudgetOutcome
+	56, // 24: search.LLMStreamResponse.budget:type_name -> search.BudgetOutcome
+	54, // 25: search.LLMSessionMessage.start:type_name -> search.LLMRequest
+	24, // 26: search.VLLMGenerateRequest.sampling:type_name -> search.SamplingParams
+	66, // 27: search.TritonInferRequest.inputs:type_name -> search.TritonInferTensor
+	67, // 28: search.TritonInferRequest.outputs:type_name -> search.TritonRequestedOutput
+	68, // 29: search.TritonInferTensor.contents:type_name -> search.TritonTensorContents
+	66, // 30: search.TritonInferResponse.outputs:type_name -> search.TritonInferTensor
+	2,  // 31: search.SearchService.Search:input_type -> search.SearchRequest
+	7,  // 32: search.SearchService.IngestDocument:input_type -> search.IngestDocumentRequest
+	5,  // 33: search.SearchService.Suggest:input_type -> search.SuggestRequest
+	0,  // 34: search.SearchService.HealthCheck:input_type -> search.HealthCheckRequest
+	9,  // 35: search.TokenizerService.Tokenize:input_type -> search.TokenizeRequest
+	11, // 36: search.TokenizerService.BatchTokenize:input_type -> search.BatchTokenizeRequest
+	13, // 37: search.TokenizerService.GetVocabularyInfo:input_type -> search.VocabularyInfoRequest
+	15, // 38: search.TokenizerService.Detokenize:input_type -> search.DetokenizeRequest
+	17, // 39: search.TokenizerService.BatchDetokenize:input_type -> search.BatchDetokenizeRequest
+	19, // 40: search.TokenizerService.DecodeStream:input_type -> search.DecodeStreamRequest
+	21, // 41: search.TokenizerService.BuildPrompt:input_type -> search.BuildPromptRequest
+	0,  // 42: search.TokenizerService.HealthCheck:input_type -> search.HealthCheckRequest
+	23, // 43: search.InferenceService.Summarize:input_type -> search.SummarizeRequest
+	23, // 44: search.InferenceService.SummarizeStream:input_type -> search.SummarizeRequest
+	23, // 45: search.InferenceService.SummarizeSession:input_type -> search.SummarizeRequest
+	27, // 46: search.InferenceService.Embed:input_type -> search.EmbedRequest
+	30, // 47: search.InferenceService.ExtractEntities:input_type -> search.ExtractEntitiesRequest
+	34, // 48: search.InferenceService.PolishRelatedSearches:input_type -> search.PolishRelatedSearchesRequest
+	36, // 49: search.InferenceService.Rerank:input_type -> search.RerankRequest
+	38, // 50: search.InferenceService.SwitchModel:input_type -> search.SwitchModelRequest
+	40, // 51: search.InferenceService.ListModels:input_type -> search.ListModelsRequest
+	0,  // 52: search.InferenceService.HealthCheck:input_type -> search.HealthCheckRequest
+	44, // 53: search.SafetyService.ValidateInput:input_type -> search.ValidateInputRequest
+	46, // 54: search.SafetyService.SanitizeOutput:input_type -> search.SanitizeOutputRequest
+	52, // 55: search.SafetyService.FilterSuggestions:input_type -> search.FilterSuggestionsRequest
+	48, // 56: search.SafetyService.BatchValidateInput:input_type -> search.BatchValidateInputRequest
+	50, // 57: search.SafetyService.BatchSanitizeOutput:input_type -> search.BatchSanitizeOutputRequest
+	0,  // 58: search.SafetyService.HealthCheck:input_type -> search.HealthCheckRequest
+	54, // 59: search.LLMOrchestratorService.ProcessRequest:input_type -> search.LLMRequest
+	54, // 60: search.LLMOrchestratorService.StreamRequest:input_type -> search.LLMRequest
+	60, // 61: search.LLMOrchestratorService.StreamSession:input_type -> search.LLMSessionMessage
+	57, // 62: search.LLMOrchestratorService.GetStatus:input_type -> search.LLMStatusRequest
+	0,  // 63: search.LLMOrchestratorService.HealthCheck:input_type -> search.HealthCheckRequest
+	61, // 64: search.VLLMGenerationService.Generate:input_type -> search.VLLMGenerateRequest
+	61, // 65: search.VLLMGenerationService.GenerateStream:input_type -> search.VLLMGenerateRequest
+	3,  // 66: search.SearchService.Search:output_type -> search.SearchResponse
+	8,  // 67: search.SearchService.IngestDocument:output_type -> search.IngestDocumentResponse
+	6,  // 68: search.SearchService.Suggest:output_type -> search.SuggestResponse
+	1,  // 69: search.SearchService.HealthCheck:output_type -> search.HealthCheckResponse
+	10, // 70: search.TokenizerService.Tokenize:output_type -> search.TokenizeResponse
+	12, // 71: search.TokenizerService.BatchTokenize:output_type -> search.BatchTokenizeResponse
+	14, // 72: search.TokenizerService.GetVocabularyInfo:output_type -> search.VocabularyInfoResponse
+	16, // 73: search.TokenizerService.Detokenize:output_type -> search.DetokenizeResponse
+	18, // 74: search.TokenizerService.BatchDetokenize:output_type -> search.BatchDetokenizeResponse
+	20, // 75: search.TokenizerService.DecodeStream:output_type -> search.DecodeStreamResponse
+	22, // 76: search.TokenizerService.BuildPrompt:output_type -> search.BuildPromptResponse
+	1,  // 77: search.TokenizerService.HealthCheck:output_type -> search.HealthCheckResponse
+	25, // 78: search.InferenceService.Summarize:output_type -> search.SummarizeResponse
+	26, // 79: search.InferenceService.SummarizeStream:output_type -> search.SummarizeStreamResponse
+	26, // 80: search.InferenceService.SummarizeSession:output_type -> search.SummarizeStreamResponse
+	29, // 81: search.InferenceService.Embed:output_type -> search.EmbedResponse
+	33, // 82: search.InferenceService.ExtractEntities:output_type -> search.ExtractEntitiesResponse
+	35, // 83: search.InferenceService.PolishRelatedSearches:output_type -> search.PolishRelatedSearchesResponse
+	37, // 84: search.InferenceService.Rerank:output_type -> search.RerankResponse
+	39, // 85: search.InferenceService.SwitchModel:output_type -> search.SwitchModelResponse
+	42, // 86: search.InferenceService.ListModels:output_type -> search.ListModelsResponse
+	1,  // 87: search.InferenceService.HealthCheck:output_type -> search.HealthCheckResponse
+	45, // 88: search.SafetyService.ValidateInput:output_type -> search.ValidateInputResponse
+	47, // 89: search.SafetyService.SanitizeOutput:output_type -> search.SanitizeOutputResponse
+	53, // 90: search.SafetyService.FilterSuggestions:output_type -> search.FilterSuggestionsResponse
+	49, // 91: search.SafetyService.BatchValidateInput:output_type -> search.BatchValidateInputResponse
+	51, // 92: search.SafetyService.BatchSanitizeOutput:output_type -> search.BatchSanitizeOutputResponse
+	1,  // 93: search.SafetyService.HealthCheck:output_type -> search.HealthCheckResponse
+	55, // 94: search.LLMOrchestratorService.ProcessRequest:output_type -> search.LLMResponse
+	59, // 95: search.LLMOrchestratorService.StreamRequest:output_type -> search.LLMStreamResponse
+	59, // 96: search.LLMOrchestratorService.StreamSession:output_type -> search.LLMStreamResponse
+	58, // 97: search.LLMOrchestratorService.GetStatus:output_type -> search.LLMStatusResponse
+	1,  // 98: search.LLMOrchestratorService.HealthCheck:output_type -> search.HealthCheckResponse
+	62, // 99: search.VLLMGenerationService.Generate:output_type -> search.VLLMGenerateResponse
+	62, // 100: search.VLLMGenerationService.GenerateStream:output_type -> search.VLLMGenerateResponse
+	66, // [66:101] is the sub-list for method output_type
+	31, // [31:66] is the sub-list for method input_type
+	31, // [31:31] is the sub-list for extension type_name
+	31, // [31:31] is the sub-list for extension extendee
+	0,  // [0:31] is the sub-list for field type_name
 }
 
 func init() { file_proto_search_proto_init() }
@@ -2139,15 +5510,16 @@ func file_proto_search_proto_init() {
 	if File_proto_search_proto != nil {
 		return
 	}
+	file_proto_search_proto_msgTypes[24].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_search_proto_rawDesc), len(file_proto_search_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   27,
+			NumMessages:   73,
 			NumExtensions: 0,
-			NumServices:   5,
+			NumServices:   6,
 		},
 		GoTypes:           file_proto_search_proto_goTypes,
 		DependencyIndexes: file_proto_search_proto_depIdxs,
@@ -19,8 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	SearchService_Search_FullMethodName      = "/search.SearchService/Search"
-	SearchService_HealthCheck_FullMethodName = "/search.SearchService/HealthCheck"
+	SearchService_Search_FullMethodName         = "/search.SearchService/Search"
+	SearchService_IngestDocument_FullMethodName = "/search.SearchService/IngestDocument"
+	SearchService_Suggest_FullMethodName        = "/search.SearchService/Suggest"
+	SearchService_HealthCheck_FullMethodName    = "/search.SearchService/HealthCheck"
 )
 
 // SearchServiceClient is the client API for SearchService service.
@@ -30,6 +32,8 @@ const (
 // Search service definitions
 type SearchServiceClient interface {
 	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	IngestDocument(ctx context.Context, in *IngestDocumentRequest, opts ...grpc.CallOption) (*IngestDocumentResponse, error)
+	Suggest(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*SuggestResponse, error)
 	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
 }
 
@@ -51,6 +55,26 @@ func (c *searchServiceClient) Search(ctx context.Context, in *SearchRequest, opt
 	return out, nil
 }
 
+func (c *searchServiceClient) IngestDocument(ctx context.Context, in *IngestDocumentRequest, opts ...grpc.CallOption) (*IngestDocumentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IngestDocumentResponse)
+	err := c.cc.Invoke(ctx, SearchService_IngestDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *searchServiceClient) Suggest(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*SuggestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SuggestResponse)
+	err := c.cc.Invoke(ctx, SearchService_Suggest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *searchServiceClient) HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(HealthCheckResponse)
@@ -68,6 +92,8 @@ func (c *searchServiceClient) HealthCheck(ctx context.Context, in *HealthCheckRe
 // Search service definitions
 type SearchServiceServer interface {
 	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	IngestDocument(context.Context, *IngestDocumentRequest) (*IngestDocumentResponse, error)
+	Suggest(context.Context, *SuggestRequest) (*SuggestResponse, error)
 	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
 	mustEmbedUnimplementedSearchServiceServer()
 }
@@ -82,6 +108,12 @@ type UnimplementedSearchServiceServer struct{}
 func (UnimplementedSearchServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
 }
+func (UnimplementedSearchServiceServer) IngestDocument(context.Context, *IngestDocumentRequest) (*IngestDocumentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IngestDocument not implemented")
+}
+func (UnimplementedSearchServiceServer) Suggest(context.Context, *SuggestRequest) (*SuggestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Suggest not implemented")
+}
 func (UnimplementedSearchServiceServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
 }
@@ -124,6 +156,42 @@ func _SearchService_Search_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SearchService_IngestDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IngestDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchServiceServer).IngestDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchService_IngestDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchServiceServer).IngestDocument(ctx, req.(*IngestDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SearchService_Suggest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SuggestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchServiceServer).Suggest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchService_Suggest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchServiceServer).Suggest(ctx, req.(*SuggestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SearchService_HealthCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HealthCheckRequest)
 	if err := dec(in); err != nil {
@@ -153,6 +221,14 @@ var SearchService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Search",
 			Handler:    _SearchService_Search_Handler,
 		},
+		{
+			MethodName: "IngestDocument",
+			Handler:    _SearchService_IngestDocument_Handler,
+		},
+		{
+			MethodName: "Suggest",
+			Handler:    _SearchService_Suggest_Handler,
+		},
 		{
 			MethodName: "HealthCheck",
 			Handler:    _SearchService_HealthCheck_Handler,
@@ -168,6 +244,8 @@ const (
 	TokenizerService_GetVocabularyInfo_FullMethodName = "/search.TokenizerService/GetVocabularyInfo"
 	TokenizerService_Detokenize_FullMethodName        = "/search.TokenizerService/Detokenize"
 	TokenizerService_BatchDetokenize_FullMethodName   = "/search.TokenizerService/BatchDetokenize"
+	TokenizerService_DecodeStream_FullMethodName      = "/search.TokenizerService/DecodeStream"
+	TokenizerService_BuildPrompt_FullMethodName       = "/search.TokenizerService/BuildPrompt"
 	TokenizerService_HealthCheck_FullMethodName       = "/search.TokenizerService/HealthCheck"
 )
 
@@ -183,6 +261,8 @@ type TokenizerServiceClient interface {
 	// Detokenization methods (industry standard)
 	Detokenize(ctx context.Context, in *DetokenizeRequest, opts ...grpc.CallOption) (*DetokenizeResponse, error)
 	BatchDetokenize(ctx context.Context, in *BatchDetokenizeRequest, opts ...grpc.CallOption) (*BatchDetokenizeResponse, error)
+	DecodeStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DecodeStreamRequest, DecodeStreamResponse], error)
+	BuildPrompt(ctx context.Context, in *BuildPromptRequest, opts ...grpc.CallOption) (*BuildPromptResponse, error)
 	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
 }
 
@@ -244,6 +324,29 @@ func (c *tokenizerServiceClient) BatchDetokenize(ctx context.Context, in *BatchD
 	return out, nil
 }
 
+func (c *tokenizerServiceClient) DecodeStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DecodeStreamRequest, DecodeStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TokenizerService_ServiceDesc.Streams[0], TokenizerService_DecodeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DecodeStreamRequest, DecodeStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TokenizerService_DecodeStreamClient = grpc.BidiStreamingClient[DecodeStreamRequest, DecodeStreamResponse]
+
+func (c *tokenizerServiceClient) BuildPrompt(ctx context.Context, in *BuildPromptRequest, opts ...grpc.CallOption) (*BuildPromptResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BuildPromptResponse)
+	err := c.cc.Invoke(ctx, TokenizerService_BuildPrompt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *tokenizerServiceClient) HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(HealthCheckResponse)
@@ -266,6 +369,8 @@ type TokenizerServiceServer interface {
 	// Detokenization methods (industry standard)
 	Detokenize(context.Context, *DetokenizeRequest) (*DetokenizeResponse, error)
 	BatchDetokenize(context.Context, *BatchDetokenizeRequest) (*BatchDetokenizeResponse, error)
+	DecodeStream(grpc.BidiStreamingServer[DecodeStreamRequest, DecodeStreamResponse]) error
+	BuildPrompt(context.Context, *BuildPromptRequest) (*BuildPromptResponse, error)
 	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
 	mustEmbedUnimplementedTokenizerServiceServer()
 }
@@ -292,6 +397,12 @@ func (UnimplementedTokenizerServiceServer) Detokenize(context.Context, *Detokeni
 func (UnimplementedTokenizerServiceServer) BatchDetokenize(context.Context, *BatchDetokenizeRequest) (*BatchDetokenizeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BatchDetokenize not implemented")
 }
+func (UnimplementedTokenizerServiceServer) DecodeStream(grpc.BidiStreamingServer[DecodeStreamRequest, DecodeStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method DecodeStream not implemented")
+}
+func (UnimplementedTokenizerServiceServer) BuildPrompt(context.Context, *BuildPromptRequest) (*BuildPromptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuildPrompt not implemented")
+}
 func (UnimplementedTokenizerServiceServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
 }
@@ -406,6 +517,31 @@ func _TokenizerService_BatchDetokenize_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TokenizerService_DecodeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TokenizerServiceServer).DecodeStream(&grpc.GenericServerStream[DecodeStreamRequest, DecodeStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TokenizerService_DecodeStreamServer = grpc.BidiStreamingServer[DecodeStreamRequest, DecodeStreamResponse]
+
+func _TokenizerService_BuildPrompt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BuildPromptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenizerServiceServer).BuildPrompt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenizerService_BuildPrompt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenizerServiceServer).BuildPrompt(ctx, req.(*BuildPromptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TokenizerService_HealthCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HealthCheckRequest)
 	if err := dec(in); err != nil {
@@ -451,19 +587,37 @@ var TokenizerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "BatchDetokenize",
 			Handler:    _TokenizerService_BatchDetokenize_Handler,
 		},
+		{
+			MethodName: "BuildPrompt",
+			Handler:    _TokenizerService_BuildPrompt_Handler,
+		},
 		{
 			MethodName: "HealthCheck",
 			Handler:    _TokenizerService_HealthCheck_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "DecodeStream",
+			Handler:       _TokenizerService_DecodeStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "proto/search.proto",
 }
 
 const (
-	InferenceService_Summarize_FullMethodName       = "/search.InferenceService/Summarize"
-	InferenceService_SummarizeStream_FullMethodName = "/search.InferenceService/SummarizeStream"
-	InferenceService_HealthCheck_FullMethodName     = "/search.InferenceService/HealthCheck"
+	InferenceService_Summarize_FullMethodName             = "/search.InferenceService/Summarize"
+	InferenceService_SummarizeStream_FullMethodName       = "/search.InferenceService/SummarizeStream"
+	InferenceService_SummarizeSession_FullMethodName      = "/search.InferenceService/SummarizeSession"
+	InferenceService_Embed_FullMethodName                 = "/search.InferenceService/Embed"
+	InferenceService_ExtractEntities_FullMethodName       = "/search.InferenceService/ExtractEntities"
+	InferenceService_PolishRelatedSearches_FullMethodName = "/search.InferenceService/PolishRelatedSearches"
+	InferenceService_Rerank_FullMethodName                = "/search.InferenceService/Rerank"
+	InferenceService_SwitchModel_FullMethodName           = "/search.InferenceService/SwitchModel"
+	InferenceService_ListModels_FullMethodName            = "/search.InferenceService/ListModels"
+	InferenceService_HealthCheck_FullMethodName           = "/search.InferenceService/HealthCheck"
 )
 
 // InferenceServiceClient is the client API for InferenceService service.
@@ -474,6 +628,13 @@ const (
 type InferenceServiceClient interface {
 	Summarize(ctx context.Context, in *SummarizeRequest, opts ...grpc.CallOption) (*SummarizeResponse, error)
 	SummarizeStream(ctx context.Context, in *SummarizeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SummarizeStreamResponse], error)
+	SummarizeSession(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SummarizeRequest, SummarizeStreamResponse], error)
+	Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error)
+	ExtractEntities(ctx context.Context, in *ExtractEntitiesRequest, opts ...grpc.CallOption) (*ExtractEntitiesResponse, error)
+	PolishRelatedSearches(ctx context.Context, in *PolishRelatedSearchesRequest, opts ...grpc.CallOption) (*PolishRelatedSearchesResponse, error)
+	Rerank(ctx context.Context, in *RerankRequest, opts ...grpc.CallOption) (*RerankResponse, error)
+	SwitchModel(ctx context.Context, in *SwitchModelRequest, opts ...grpc.CallOption) (*SwitchModelResponse, error)
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
 	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
 }
 
@@ -514,6 +675,79 @@ func (c *inferenceServiceClient) SummarizeStream(ctx context.Context, in *Summar
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type InferenceService_SummarizeStreamClient = grpc.ServerStreamingClient[SummarizeStreamResponse]
 
+func (c *inferenceServiceClient) SummarizeSession(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SummarizeRequest, SummarizeStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &InferenceService_ServiceDesc.Streams[1], InferenceService_SummarizeSession_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SummarizeRequest, SummarizeStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type InferenceService_SummarizeSessionClient = grpc.BidiStreamingClient[SummarizeRequest, SummarizeStreamResponse]
+
+func (c *inferenceServiceClient) Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EmbedResponse)
+	err := c.cc.Invoke(ctx, InferenceService_Embed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) ExtractEntities(ctx context.Context, in *ExtractEntitiesRequest, opts ...grpc.CallOption) (*ExtractEntitiesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExtractEntitiesResponse)
+	err := c.cc.Invoke(ctx, InferenceService_ExtractEntities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) PolishRelatedSearches(ctx context.Context, in *PolishRelatedSearchesRequest, opts ...grpc.CallOption) (*PolishRelatedSearchesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PolishRelatedSearchesResponse)
+	err := c.cc.Invoke(ctx, InferenceService_PolishRelatedSearches_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) Rerank(ctx context.Context, in *RerankRequest, opts ...grpc.CallOption) (*RerankResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RerankResponse)
+	err := c.cc.Invoke(ctx, InferenceService_Rerank_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) SwitchModel(ctx context.Context, in *SwitchModelRequest, opts ...grpc.CallOption) (*SwitchModelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SwitchModelResponse)
+	err := c.cc.Invoke(ctx, InferenceService_SwitchModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, InferenceService_ListModels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *inferenceServiceClient) HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(HealthCheckResponse)
@@ -532,6 +766,13 @@ func (c *inferenceServiceClient) HealthCheck(ctx context.Context, in *HealthChec
 type InferenceServiceServer interface {
 	Summarize(context.Context, *SummarizeRequest) (*SummarizeResponse, error)
 	SummarizeStream(*SummarizeRequest, grpc.ServerStreamingServer[SummarizeStreamResponse]) error
+	SummarizeSession(grpc.BidiStreamingServer[SummarizeRequest, SummarizeStreamResponse]) error
+	Embed(context.Context, *EmbedRequest) (*EmbedResponse, error)
+	ExtractEntities(context.Context, *ExtractEntitiesRequest) (*ExtractEntitiesResponse, error)
+	PolishRelatedSearches(context.Context, *PolishRelatedSearchesRequest) (*PolishRelatedSearchesResponse, error)
+	Rerank(context.Context, *RerankRequest) (*RerankResponse, error)
+	SwitchModel(context.Context, *SwitchModelRequest) (*SwitchModelResponse, error)
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
 	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
 	mustEmbedUnimplementedInferenceServiceServer()
 }
@@ -549,6 +790,27 @@ func (UnimplementedInferenceServiceServer) Summarize(context.Context, *Summarize
 func (UnimplementedInferenceServiceServer) SummarizeStream(*SummarizeRequest, grpc.ServerStreamingServer[SummarizeStreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method SummarizeStream not implemented")
 }
+func (UnimplementedInferenceServiceServer) SummarizeSession(grpc.BidiStreamingServer[SummarizeRequest, SummarizeStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method SummarizeSession not implemented")
+}
+func (UnimplementedInferenceServiceServer) Embed(context.Context, *EmbedRequest) (*EmbedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Embed not implemented")
+}
+func (UnimplementedInferenceServiceServer) ExtractEntities(context.Context, *ExtractEntitiesRequest) (*ExtractEntitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtractEntities not implemented")
+}
+func (UnimplementedInferenceServiceServer) PolishRelatedSearches(context.Context, *PolishRelatedSearchesRequest) (*PolishRelatedSearchesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PolishRelatedSearches not implemented")
+}
+func (UnimplementedInferenceServiceServer) Rerank(context.Context, *RerankRequest) (*RerankResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rerank not implemented")
+}
+func (UnimplementedInferenceServiceServer) SwitchModel(context.Context, *SwitchModelRequest) (*SwitchModelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SwitchModel not implemented")
+}
+func (UnimplementedInferenceServiceServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
 func (UnimplementedInferenceServiceServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
 }
@@ -602,6 +864,121 @@ func _InferenceService_SummarizeStream_Handler(srv interface{}, stream grpc.Serv
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type InferenceService_SummarizeStreamServer = grpc.ServerStreamingServer[SummarizeStreamResponse]
 
+func _InferenceService_SummarizeSession_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InferenceServiceServer).SummarizeSession(&grpc.GenericServerStream[SummarizeRequest, SummarizeStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type InferenceService_SummarizeSessionServer = grpc.BidiStreamingServer[SummarizeRequest, SummarizeStreamResponse]
+
+func _InferenceService_Embed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmbedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).Embed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InferenceService_Embed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).Embed(ctx, req.(*EmbedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_ExtractEntities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractEntitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).ExtractEntities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InferenceService_ExtractEntities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).ExtractEntities(ctx, req.(*ExtractEntitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_PolishRelatedSearches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PolishRelatedSearchesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).PolishRelatedSearches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InferenceService_PolishRelatedSearches_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).PolishRelatedSearches(ctx, req.(*PolishRelatedSearchesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_Rerank_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RerankRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).Rerank(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InferenceService_Rerank_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).Rerank(ctx, req.(*RerankRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_SwitchModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwitchModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).SwitchModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InferenceService_SwitchModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).SwitchModel(ctx, req.(*SwitchModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InferenceService_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _InferenceService_HealthCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HealthCheckRequest)
 	if err := dec(in); err != nil {
@@ -631,6 +1008,30 @@ var InferenceService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Summarize",
 			Handler:    _InferenceService_Summarize_Handler,
 		},
+		{
+			MethodName: "Embed",
+			Handler:    _InferenceService_Embed_Handler,
+		},
+		{
+			MethodName: "ExtractEntities",
+			Handler:    _InferenceService_ExtractEntities_Handler,
+		},
+		{
+			MethodName: "PolishRelatedSearches",
+			Handler:    _InferenceService_PolishRelatedSearches_Handler,
+		},
+		{
+			MethodName: "Rerank",
+			Handler:    _InferenceService_Rerank_Handler,
+		},
+		{
+			MethodName: "SwitchModel",
+			Handler:    _InferenceService_SwitchModel_Handler,
+		},
+		{
+			MethodName: "ListModels",
+			Handler:    _InferenceService_ListModels_Handler,
+		},
 		{
 			MethodName: "HealthCheck",
 			Handler:    _InferenceService_HealthCheck_Handler,
@@ -642,14 +1043,23 @@ var InferenceService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _InferenceService_SummarizeStream_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SummarizeSession",
+			Handler:       _InferenceService_SummarizeSession_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "proto/search.proto",
 }
 
 const (
-	SafetyService_ValidateInput_FullMethodName  = "/search.SafetyService/ValidateInput"
-	SafetyService_SanitizeOutput_FullMethodName = "/search.SafetyService/SanitizeOutput"
-	SafetyService_HealthCheck_FullMethodName    = "/search.SafetyService/HealthCheck"
+	SafetyService_ValidateInput_FullMethodName       = "/search.SafetyService/ValidateInput"
+	SafetyService_SanitizeOutput_FullMethodName      = "/search.SafetyService/SanitizeOutput"
+	SafetyService_FilterSuggestions_FullMethodName   = "/search.SafetyService/FilterSuggestions"
+	SafetyService_BatchValidateInput_FullMethodName  = "/search.SafetyService/BatchValidateInput"
+	SafetyService_BatchSanitizeOutput_FullMethodName = "/search.SafetyService/BatchSanitizeOutput"
+	SafetyService_HealthCheck_FullMethodName         = "/search.SafetyService/HealthCheck"
 )
 
 // SafetyServiceClient is the client API for SafetyService service.
@@ -660,6 +1070,9 @@ const (
 type SafetyServiceClient interface {
 	ValidateInput(ctx context.Context, in *ValidateInputRequest, opts ...grpc.CallOption) (*ValidateInputResponse, error)
 	SanitizeOutput(ctx context.Context, in *SanitizeOutputRequest, opts ...grpc.CallOption) (*SanitizeOutputResponse, error)
+	FilterSuggestions(ctx context.Context, in *FilterSuggestionsRequest, opts ...grpc.CallOption) (*FilterSuggestionsResponse, error)
+	BatchValidateInput(ctx context.Context, in *BatchValidateInputRequest, opts ...grpc.CallOption) (*BatchValidateInputResponse, error)
+	BatchSanitizeOutput(ctx context.Context, in *BatchSanitizeOutputRequest, opts ...grpc.CallOption) (*BatchSanitizeOutputResponse, error)
 	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
 }
 
@@ -691,6 +1104,36 @@ func (c *safetyServiceClient) SanitizeOutput(ctx context.Context, in *SanitizeOu
 	return out, nil
 }
 
+func (c *safetyServiceClient) FilterSuggestions(ctx context.Context, in *FilterSuggestionsRequest, opts ...grpc.CallOption) (*FilterSuggestionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterSuggestionsResponse)
+	err := c.cc.Invoke(ctx, SafetyService_FilterSuggestions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *safetyServiceClient) BatchValidateInput(ctx context.Context, in *BatchValidateInputRequest, opts ...grpc.CallOption) (*BatchValidateInputResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchValidateInputResponse)
+	err := c.cc.Invoke(ctx, SafetyService_BatchValidateInput_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *safetyServiceClient) BatchSanitizeOutput(ctx context.Context, in *BatchSanitizeOutputRequest, opts ...grpc.CallOption) (*BatchSanitizeOutputResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchSanitizeOutputResponse)
+	err := c.cc.Invoke(ctx, SafetyService_BatchSanitizeOutput_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *safetyServiceClient) HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(HealthCheckResponse)
@@ -709,6 +1152,9 @@ func (c *safetyServiceClient) HealthCheck(ctx context.Context, in *HealthCheckRe
 type SafetyServiceServer interface {
 	ValidateInput(context.Context, *ValidateInputRequest) (*ValidateInputResponse, error)
 	SanitizeOutput(context.Context, *SanitizeOutputRequest) (*SanitizeOutputResponse, error)
+	FilterSuggestions(context.Context, *FilterSuggestionsRequest) (*FilterSuggestionsResponse, error)
+	BatchValidateInput(context.Context, *BatchValidateInputRequest) (*BatchValidateInputResponse, error)
+	BatchSanitizeOutput(context.Context, *BatchSanitizeOutputRequest) (*BatchSanitizeOutputResponse, error)
 	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
 	mustEmbedUnimplementedSafetyServiceServer()
 }
@@ -726,6 +1172,15 @@ func (UnimplementedSafetyServiceServer) ValidateInput(context.Context, *Validate
 func (UnimplementedSafetyServiceServer) SanitizeOutput(context.Context, *SanitizeOutputRequest) (*SanitizeOutputResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SanitizeOutput not implemented")
 }
+func (UnimplementedSafetyServiceServer) FilterSuggestions(context.Context, *FilterSuggestionsRequest) (*FilterSuggestionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FilterSuggestions not implemented")
+}
+func (UnimplementedSafetyServiceServer) BatchValidateInput(context.Context, *BatchValidateInputRequest) (*BatchValidateInputResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchValidateInput not implemented")
+}
+func (UnimplementedSafetyServiceServer) BatchSanitizeOutput(context.Context, *BatchSanitizeOutputRequest) (*BatchSanitizeOutputResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchSanitizeOutput not implemented")
+}
 func (UnimplementedSafetyServiceServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
 }
@@ -786,6 +1241,60 @@ func _SafetyService_SanitizeOutput_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SafetyService_FilterSuggestions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilterSuggestionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafetyServiceServer).FilterSuggestions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafetyService_FilterSuggestions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafetyServiceServer).FilterSuggestions(ctx, req.(*FilterSuggestionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SafetyService_BatchValidateInput_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchValidateInputRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafetyServiceServer).BatchValidateInput(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafetyService_BatchValidateInput_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafetyServiceServer).BatchValidateInput(ctx, req.(*BatchValidateInputRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SafetyService_BatchSanitizeOutput_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchSanitizeOutputRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafetyServiceServer).BatchSanitizeOutput(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafetyService_BatchSanitizeOutput_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafetyServiceServer).BatchSanitizeOutput(ctx, req.(*BatchSanitizeOutputRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SafetyService_HealthCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HealthCheckRequest)
 	if err := dec(in); err != nil {
@@ -819,6 +1328,18 @@ var SafetyService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SanitizeOutput",
 			Handler:    _SafetyService_SanitizeOutput_Handler,
 		},
+		{
+			MethodName: "FilterSuggestions",
+			Handler:    _SafetyService_FilterSuggestions_Handler,
+		},
+		{
+			MethodName: "BatchValidateInput",
+			Handler:    _SafetyService_BatchValidateInput_Handler,
+		},
+		{
+			MethodName: "BatchSanitizeOutput",
+			Handler:    _SafetyService_BatchSanitizeOutput_Handler,
+		},
 		{
 			MethodName: "HealthCheck",
 			Handler:    _SafetyService_HealthCheck_Handler,
@@ -831,6 +1352,7 @@ var SafetyService_ServiceDesc = grpc.ServiceDesc{
 const (
 	LLMOrchestratorService_ProcessRequest_FullMethodName = "/search.LLMOrchestratorService/ProcessRequest"
 	LLMOrchestratorService_StreamRequest_FullMethodName  = "/search.LLMOrchestratorService/StreamRequest"
+	LLMOrchestratorService_StreamSession_FullMethodName  = "/search.LLMOrchestratorService/StreamSession"
 	LLMOrchestratorService_GetStatus_FullMethodName      = "/search.LLMOrchestratorService/GetStatus"
 	LLMOrchestratorService_HealthCheck_FullMethodName    = "/search.LLMOrchestratorService/HealthCheck"
 )
@@ -843,6 +1365,7 @@ const (
 type LLMOrchestratorServiceClient interface {
 	ProcessRequest(ctx context.Context, in *LLMRequest, opts ...grpc.CallOption) (*LLMResponse, error)
 	StreamRequest(ctx context.Context, in *LLMRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LLMStreamResponse], error)
+	StreamSession(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LLMSessionMessage, LLMStreamResponse], error)
 	GetStatus(ctx context.Context, in *LLMStatusRequest, opts ...grpc.CallOption) (*LLMStatusResponse, error)
 	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
 }
@@ -884,6 +1407,19 @@ func (c *lLMOrchestratorServiceClient) StreamRequest(ctx context.Context, in *LL
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LLMOrchestratorService_StreamRequestClient = grpc.ServerStreamingClient[LLMStreamResponse]
 
+func (c *lLMOrchestratorServiceClient) StreamSession(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LLMSessionMessage, LLMStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LLMOrchestratorService_ServiceDesc.Streams[1], LLMOrchestratorService_StreamSession_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LLMSessionMessage, LLMStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LLMOrchestratorService_StreamSessionClient = grpc.BidiStreamingClient[LLMSessionMessage, LLMStreamResponse]
+
 func (c *lLMOrchestratorServiceClient) GetStatus(ctx context.Context, in *LLMStatusRequest, opts ...grpc.CallOption) (*LLMStatusResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(LLMStatusResponse)
@@ -912,6 +1448,7 @@ func (c *lLMOrchestratorServiceClient) HealthCheck(ctx context.Context, in *Heal
 type LLMOrchestratorServiceServer interface {
 	ProcessRequest(context.Context, *LLMRequest) (*LLMResponse, error)
 	StreamRequest(*LLMRequest, grpc.ServerStreamingServer[LLMStreamResponse]) error
+	StreamSession(grpc.BidiStreamingServer[LLMSessionMessage, LLMStreamResponse]) error
 	GetStatus(context.Context, *LLMStatusRequest) (*LLMStatusResponse, error)
 	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
 	mustEmbedUnimplementedLLMOrchestratorServiceServer()
@@ -930,6 +1467,9 @@ func (UnimplementedLLMOrchestratorServiceServer) ProcessRequest(context.Context,
 func (UnimplementedLLMOrchestratorServiceServer) StreamRequest(*LLMRequest, grpc.ServerStreamingServer[LLMStreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamRequest not implemented")
 }
+func (UnimplementedLLMOrchestratorServiceServer) StreamSession(grpc.BidiStreamingServer[LLMSessionMessage, LLMStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSession not implemented")
+}
 func (UnimplementedLLMOrchestratorServiceServer) GetStatus(context.Context, *LLMStatusRequest) (*LLMStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
 }
@@ -987,6 +1527,13 @@ func _LLMOrchestratorService_StreamRequest_Handler(srv interface{}, stream grpc.
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LLMOrchestratorService_StreamRequestServer = grpc.ServerStreamingServer[LLMStreamResponse]
 
+func _LLMOrchestratorService_StreamSession_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LLMOrchestratorServiceServer).StreamSession(&grpc.GenericServerStream[LLMSessionMessage, LLMStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LLMOrchestratorService_StreamSessionServer = grpc.BidiStreamingServer[LLMSessionMessage, LLMStreamResponse]
+
 func _LLMOrchestratorService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(LLMStatusRequest)
 	if err := dec(in); err != nil {
@@ -1049,6 +1596,166 @@ var LLMOrchestratorService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _LLMOrchestratorService_StreamRequest_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamSession",
+			Handler:       _LLMOrchestratorService_StreamSession_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/search.proto",
+}
+
+const (
+	VLLMGenerationService_Generate_FullMethodName       = "/search.VLLMGenerationService/Generate"
+	VLLMGenerationService_GenerateStream_FullMethodName = "/search.VLLMGenerationService/GenerateStream"
+)
+
+// VLLMGenerationServiceClient is the client API for VLLMGenerationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Generation API of a vLLM gRPC frontend (or Triton serving vLLM behind an
+// adapter), which the inference service calls instead of vLLM's HTTP API
+// with inference.backend.transport set to grpc. Servers also implement the
+// standard grpc.health.v1 Health service.
+type VLLMGenerationServiceClient interface {
+	Generate(ctx context.Context, in *VLLMGenerateRequest, opts ...grpc.CallOption) (*VLLMGenerateResponse, error)
+	GenerateStream(ctx context.Context, in *VLLMGenerateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[VLLMGenerateResponse], error)
+}
+
+type vLLMGenerationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVLLMGenerationServiceClient(cc grpc.ClientConnInterface) VLLMGenerationServiceClient {
+	return &vLLMGenerationServiceClient{cc}
+}
+
+func (c *vLLMGenerationServiceClient) Generate(ctx context.Context, in *VLLMGenerateRequest, opts ...grpc.CallOption) (*VLLMGenerateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VLLMGenerateResponse)
+	err := c.cc.Invoke(ctx, VLLMGenerationService_Generate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vLLMGenerationServiceClient) GenerateStream(ctx context.Context, in *VLLMGenerateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[VLLMGenerateResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VLLMGenerationService_ServiceDesc.Streams[0], VLLMGenerationService_GenerateStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[VLLMGenerateRequest, VLLMGenerateResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VLLMGenerationService_GenerateStreamClient = grpc.ServerStreamingClient[VLLMGenerateResponse]
+
+// VLLMGenerationServiceServer is the server API for VLLMGenerationService service.
+// All implementations must embed UnimplementedVLLMGenerationServiceServer
+// for forward compatibility.
+//
+// Generation API of a vLLM gRPC frontend (or Triton serving vLLM behind an
+// adapter), which the inference service calls instead of vLLM's HTTP API
+// with inference.backend.transport set to grpc. Servers also implement the
+// standard grpc.health.v1 Health service.
+type VLLMGenerationServiceServer interface {
+	Generate(context.Context, *VLLMGenerateRequest) (*VLLMGenerateResponse, error)
+	GenerateStream(*VLLMGenerateRequest, grpc.ServerStreamingServer[VLLMGenerateResponse]) error
+	mustEmbedUnimplementedVLLMGenerationServiceServer()
+}
+
+// UnimplementedVLLMGenerationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVLLMGenerationServiceServer struct{}
+
+func (UnimplementedVLLMGenerationServiceServer) Generate(context.Context, *VLLMGenerateRequest) (*VLLMGenerateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Generate not implemented")
+}
+func (UnimplementedVLLMGenerationServiceServer) GenerateStream(*VLLMGenerateRequest, grpc.ServerStreamingServer[VLLMGenerateResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GenerateStream not implemented")
+}
+func (UnimplementedVLLMGenerationServiceServer) mustEmbedUnimplementedVLLMGenerationServiceServer() {}
+func (UnimplementedVLLMGenerationServiceServer) testEmbeddedByValue()                               {}
+
+// UnsafeVLLMGenerationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VLLMGenerationServiceServer will
+// result in compilation errors.
+type UnsafeVLLMGenerationServiceServer interface {
+	mustEmbedUnimplementedVLLMGenerationServiceServer()
+}
+
+func RegisterVLLMGenerationServiceServer(s grpc.ServiceRegistrar, srv VLLMGenerationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedVLLMGenerationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&VLLMGenerationService_ServiceDesc, srv)
+}
+
+func _VLLMGenerationService_Generate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VLLMGenerateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VLLMGenerationServiceServer).Generate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VLLMGenerationService_Generate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VLLMGenerationServiceServer).Generate(ctx, req.(*VLLMGenerateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VLLMGenerationService_GenerateStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(VLLMGenerateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VLLMGenerationServiceServer).GenerateStream(m, &grpc.GenericServerStream[VLLMGenerateRequest, VLLMGenerateResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VLLMGenerationService_GenerateStreamServer = grpc.ServerStreamingServer[VLLMGenerateResponse]
+
+// VLLMGenerationService_ServiceDesc is the grpc.ServiceDesc for VLLMGenerationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VLLMGenerationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "search.VLLMGenerationService",
+	HandlerType: (*VLLMGenerationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Generate",
+			Handler:    _VLLMGenerationService_Generate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GenerateStream",
+			Handler:       _VLLMGenerationService_GenerateStream_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/search.proto",
 }